@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/backup"
+	"github.com/spf13/cobra"
+)
+
+// passphraseEnv supplies the archive passphrase when --passphrase-file isn't given
+const passphraseEnv = "RELAI_BACKUP_PASSPHRASE"
+
+func backupCommand() *cobra.Command {
+	var output, passphraseFile string
+	var sections []string
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Write the gateway configuration to an encrypted archive",
+		Long: fmt.Sprintf(`Write organizations, users, models (with their secrets), API key hashes, endpoints and
+email settings to an archive encrypted with a passphrase. Sections: %s.`, strings.Join(backup.Sections(), ", ")),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			passphrase, err := readPassphrase(passphraseFile)
+			if err != nil {
+				return err
+			}
+
+			archive, err := backup.Dump(conn, sections)
+			if err != nil {
+				return err
+			}
+			sealed, err := backup.Seal(archive, passphrase)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(output, sealed, 0600); err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				return printJSON(tableCounts(archive))
+			}
+			printTableCounts(tableCounts(archive))
+			fmt.Printf("Wrote %s\n", output)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "archive file to write")
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file holding the passphrase (default $"+passphraseEnv+")")
+	cmd.Flags().StringSliceVar(&sections, "only", nil, "back up only these sections")
+	cmd.MarkFlagRequired("output")
+	return cmd
+}
+
+func restoreCommand() *cobra.Command {
+	var passphraseFile string
+	var sections []string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "restore ARCHIVE",
+		Short: "Restore gateway configuration from an encrypted archive",
+		Long: `Restore the tables of an archive written by backup. Rows are inserted, or updated when they
+already exist; rows not in the archive are kept. Secrets are re-encrypted with this gateway's
+ENCRYPTION_KEY. Everything is restored in one transaction.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			passphrase, err := readPassphrase(passphraseFile)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			archive, err := backup.Open(data, passphrase)
+			if err != nil {
+				return err
+			}
+
+			results, err := backup.Restore(conn, archive, sections, dryRun)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(results)
+			}
+			printTableCounts(results)
+			if dryRun {
+				fmt.Printf("Dry run: nothing written (archive from %s)\n", archive.CreatedAt.Format("2006-01-02 15:04 MST"))
+			} else {
+				fmt.Printf("Restored archive from %s\n", archive.CreatedAt.Format("2006-01-02 15:04 MST"))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file holding the passphrase (default $"+passphraseEnv+")")
+	cmd.Flags().StringSliceVar(&sections, "only", nil, "restore only these sections")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "check the archive restores cleanly without writing anything")
+	return cmd
+}
+
+// readPassphrase reads the first line of path, or the environment when no path is given
+func readPassphrase(path string) (string, error) {
+	if path == "" {
+		if p := os.Getenv(passphraseEnv); p != "" {
+			return p, nil
+		}
+		return "", fmt.Errorf("set %s or pass --passphrase-file", passphraseEnv)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Scan()
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	passphrase := strings.TrimSpace(scanner.Text())
+	if passphrase == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return passphrase, nil
+}
+
+func tableCounts(archive *backup.Archive) []backup.TableResult {
+	counts := make([]backup.TableResult, 0, len(archive.Tables))
+	for _, t := range archive.Tables {
+		counts = append(counts, backup.TableResult{Name: t.Name, Rows: len(t.Rows)})
+	}
+	return counts
+}
+
+func printTableCounts(counts []backup.TableResult) {
+	rows := make([][]string, 0, len(counts))
+	for _, c := range counts {
+		rows = append(rows, []string{c.Name, fmt.Sprint(c.Rows)})
+	}
+	printTable([]string{"TABLE", "ROWS"}, rows)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/spf13/cobra"
+)
+
+func keyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "Manage API keys",
+	}
+
+	var listOrgID string
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the active API keys of an organization",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keys, err := db.GetAPIKeysByOrganization(conn, listOrgID)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(keys)
+			}
+
+			rows := make([][]string, 0, len(keys))
+			for _, key := range keys {
+				expires := "never"
+				if key.ExpiresAt != nil {
+					expires = key.ExpiresAt.Format("2006-01-02")
+				}
+				lastUsed := "never"
+				if key.LastUsed != nil {
+					lastUsed = key.LastUsed.Format("2006-01-02")
+				}
+				createdBy := ""
+				if key.User != nil {
+					createdBy = key.User.Email
+				}
+				rows = append(rows, []string{key.ID, key.Name, deref(key.ProjectName), strings.Join(key.Scopes, ","), expires,
+					createdBy, lastUsed, strconv.Itoa(key.RecentRequests())})
+			}
+			printTable([]string{"ID", "NAME", "PROJECT", "SCOPES", "EXPIRES", "CREATED BY", "LAST USED", "REQUESTS (30D)"}, rows)
+			return nil
+		},
+	}
+	list.Flags().StringVar(&listOrgID, "org", "", "organization ID")
+	list.MarkFlagRequired("org")
+	cmd.AddCommand(list)
+
+	var req models.CreateAPIKeyRequest
+	var description, expires, projectID string
+	create := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create an API key and print it (it is not shown again)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req.Name = args[0]
+			if description != "" {
+				req.Description = &description
+			}
+			if projectID != "" {
+				req.ProjectID = &projectID
+			}
+			if expires != "" {
+				t, err := time.Parse("2006-01-02", expires)
+				if err != nil {
+					return fmt.Errorf("--expires must be a date like 2006-01-02")
+				}
+				req.ExpiresAt = &t
+			}
+			if err := req.Validate(); err != nil {
+				return err
+			}
+
+			if _, err := db.GetOrganizationByID(conn, req.OrganizationID); err != nil {
+				return fmt.Errorf("organization %s not found", req.OrganizationID)
+			}
+			if req.ProjectID != nil {
+				project, err := db.GetProjectByID(conn, *req.ProjectID)
+				if err != nil || project.OrganizationID != req.OrganizationID {
+					return fmt.Errorf("project %s not found in the organization", *req.ProjectID)
+				}
+			}
+
+			resp, err := db.CreateAPIKey(conn, req)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(resp)
+			}
+			fmt.Println(resp.FullKey)
+			return nil
+		},
+	}
+	create.Flags().StringVar(&req.OrganizationID, "org", "", "organization ID")
+	create.Flags().StringVar(&description, "description", "", "key description")
+	create.Flags().StringVar(&expires, "expires", "", "expiry date (YYYY-MM-DD)")
+	create.Flags().StringSliceVar(&req.Scopes, "scopes", nil, "endpoint families the key may call (default all)")
+	create.Flags().StringVar(&projectID, "project", "", "project ID within the organization")
+	create.MarkFlagRequired("org")
+	cmd.AddCommand(create)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "revoke KEY_ID",
+		Short: "Deactivate an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := db.GetAPIKey(conn, args[0]); err == sql.ErrNoRows {
+				return fmt.Errorf("API key %s not found", args[0])
+			} else if err != nil {
+				return err
+			}
+			return db.DeleteAPIKey(conn, args[0])
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,97 @@
+// Command relai-admin runs common gateway administration tasks against the database: creating
+// organizations and keys, granting model access, setting quotas, following usage and backing up
+// configuration. It reads the same POSTGRES_DSN / DB_* settings as the gateway and UI.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	conn       *sql.DB
+	jsonOutput bool
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	root := &cobra.Command{
+		Use:           "relai-admin",
+		Short:         "Administer a RelAI gateway",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if cmd.Parent() != nil && cmd.Parent().Name() == "schema" {
+				// The schema commands are how drift gets repaired, so drift mustn't stop them
+				conn, err = db.InitDBWithDrift()
+			} else {
+				conn, err = db.InitDB()
+			}
+			return err
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if conn != nil {
+				conn.Close()
+			}
+		},
+	}
+	root.PersistentFlags().BoolVar(&jsonOutput, "json", false, "print results as JSON")
+
+	root.AddCommand(orgCommand(), keyCommand(), modelCommand(), quotaCommand(), usageCommand(),
+		backupCommand(), restoreCommand(), schemaCommand())
+
+	// Interrupting ends `usage tail` cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := root.ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// printJSON writes v as indented JSON
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printTable writes a header and rows as aligned columns
+func printTable(header []string, rows [][]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	printRow(w, header)
+	for _, row := range rows {
+		printRow(w, row)
+	}
+	w.Flush()
+}
+
+func printRow(w *tabwriter.Writer, cells []string) {
+	for i, cell := range cells {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, cell)
+	}
+	fmt.Fprintln(w)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/spf13/cobra"
+)
+
+func modelCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "model",
+		Short: "Manage model access",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List models and the organizations granted access",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			list, err := db.GetModelsWithOrganizations(conn)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(list)
+			}
+
+			rows := make([][]string, 0, len(list))
+			for _, m := range list {
+				names := make([]string, 0, len(m.Organizations))
+				for _, org := range m.Organizations {
+					names = append(names, org.Name)
+				}
+				rows = append(rows, []string{m.ID, m.ModelID, m.Provider, strings.Join(m.Metadata.Tags, ", "), strings.Join(names, ", ")})
+			}
+			printTable([]string{"ID", "MODEL", "PROVIDER", "TAGS", "ORGANIZATIONS"}, rows)
+			return nil
+		},
+	})
+
+	cmd.AddCommand(
+		accessCommand("grant", "Grant an organization access to a model", "add"),
+		accessCommand("revoke", "Remove an organization's grant or denial for a model", "remove"),
+		accessCommand("deny", "Block access an organization would inherit from its parent", "deny"),
+	)
+
+	return cmd
+}
+
+func accessCommand(use, short, action string) *cobra.Command {
+	var orgID string
+	cmd := &cobra.Command{
+		Use:   use + " MODEL",
+		Short: short + " (MODEL is the model's ID or its provider model name)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			model, err := findModel(args[0])
+			if err != nil {
+				return err
+			}
+			if _, err := db.GetOrganizationByID(conn, orgID); err != nil {
+				return fmt.Errorf("organization %s not found", orgID)
+			}
+			return db.ManageModelAccess(conn, model.ID, []db.ModelAccessChange{{OrgID: orgID, Action: action}})
+		},
+	}
+	cmd.Flags().StringVar(&orgID, "org", "", "organization ID")
+	cmd.MarkFlagRequired("org")
+	return cmd
+}
+
+// findModel looks a model up by ID or by its provider model name
+func findModel(ref string) (*models.Model, error) {
+	list, err := db.GetModelsWithOrganizations(conn)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].ID == ref || list[i].ModelID == ref {
+			return &list[i], nil
+		}
+	}
+	return nil, fmt.Errorf("model %s not found", ref)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/spf13/cobra"
+)
+
+func orgCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "org",
+		Short: "Manage organizations",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List active organizations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orgs, err := db.GetAllOrganizations(conn)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(orgs)
+			}
+
+			rows := make([][]string, 0, len(orgs))
+			for _, org := range orgs {
+				rows = append(rows, []string{org.ID, org.Name, deref(org.Description)})
+			}
+			printTable([]string{"ID", "NAME", "DESCRIPTION"}, rows)
+			return nil
+		},
+	})
+
+	var description, parentID string
+	var quota int
+	create := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create an organization",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if parentID != "" {
+				if _, err := db.GetOrganizationByID(conn, parentID); err != nil {
+					return fmt.Errorf("parent organization %s not found", parentID)
+				}
+			}
+
+			orgID, err := db.CreateOrganization(conn, args[0], description, parentID, quota)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(map[string]string{"id": orgID, "name": args[0]})
+			}
+			fmt.Println(orgID)
+			return nil
+		},
+	}
+	create.Flags().StringVar(&description, "description", "", "organization description")
+	create.Flags().StringVar(&parentID, "parent", "", "nest the organization under this organization ID")
+	create.Flags().IntVar(&quota, "quota", 100000, "token quota for a top-level organization")
+	cmd.AddCommand(create)
+
+	return cmd
+}
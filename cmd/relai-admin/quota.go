@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/spf13/cobra"
+)
+
+func quotaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "quota",
+		Short: "Show and set organization token quotas",
+	}
+
+	var getOrgID string
+	get := &cobra.Command{
+		Use:   "get",
+		Short: "Show the quotas an organization draws on, its own first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			quotas, err := db.GetQuotaChain(conn, getOrgID)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(quotas)
+			}
+
+			rows := make([][]string, 0, len(quotas))
+			for _, q := range quotas {
+				rows = append(rows, []string{
+					q.OrganizationID, strconv.Itoa(q.TotalQuota), strconv.Itoa(q.UsedTokens),
+					strconv.Itoa(q.TotalQuota - q.UsedTokens), q.ResetDate.Format("2006-01-02"),
+				})
+			}
+			printTable([]string{"ORGANIZATION", "TOTAL", "USED", "REMAINING", "RESETS"}, rows)
+			return nil
+		},
+	}
+	get.Flags().StringVar(&getOrgID, "org", "", "organization ID")
+	get.MarkFlagRequired("org")
+	cmd.AddCommand(get)
+
+	var setOrgID string
+	var tokens int
+	set := &cobra.Command{
+		Use:   "set",
+		Short: "Set an organization's total token quota",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if tokens <= 0 {
+				return fmt.Errorf("--tokens must be positive")
+			}
+			quota, err := db.SetOrganizationQuota(conn, setOrgID, tokens)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(quota)
+			}
+			fmt.Printf("%s: %d of %d tokens used\n", quota.OrganizationID, quota.UsedTokens, quota.TotalQuota)
+			return nil
+		},
+	}
+	set.Flags().StringVar(&setOrgID, "org", "", "organization ID")
+	set.Flags().IntVar(&tokens, "tokens", 0, "total token quota")
+	set.MarkFlagRequired("org")
+	set.MarkFlagRequired("tokens")
+	cmd.AddCommand(set)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "reconcile",
+		Short: "Recompute quota and project usage from the usage logs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			corrected, err := db.ReconcileQuotaUsage(conn)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d counters corrected\n", corrected)
+			return nil
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/spf13/cobra"
+)
+
+func schemaCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Check the database schema against this release and repair drift",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "check",
+		Short: "List missing tables, columns, constraints and indexes with their fixes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := db.CheckSchemaDrift(conn)
+			if err != nil {
+				return err
+			}
+			return printSchemaDrift(report)
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "apply",
+		Short: "Run the upgrade steps and apply the generated fixes, then show what is left",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := db.ApplySchemaFixes(conn)
+			if err != nil {
+				return err
+			}
+			return printSchemaDrift(report)
+		},
+	})
+
+	return cmd
+}
+
+func printSchemaDrift(report *models.SchemaDriftReport) error {
+	if jsonOutput {
+		return printJSON(report)
+	}
+	if len(report.Drift) == 0 {
+		fmt.Println("The schema is up to date")
+		return nil
+	}
+
+	rows := make([][]string, 0, len(report.Drift))
+	for _, d := range report.Drift {
+		fix := d.Fix
+		if fix == "" {
+			fix = fmt.Sprintf("(manual: expected %s, found %s)", d.Expected, d.Actual)
+		}
+		rows = append(rows, []string{d.Kind, d.Table, d.Name, fix})
+	}
+	printTable([]string{"KIND", "TABLE", "NAME", "FIX"}, rows)
+	return nil
+}
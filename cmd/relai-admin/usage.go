@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/spf13/cobra"
+)
+
+func usageCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Inspect gateway usage",
+	}
+
+	var orgID string
+	var interval, since time.Duration
+	tail := &cobra.Command{
+		Use:   "tail",
+		Short: "Print usage logs as requests are recorded, until interrupted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cursor := time.Now().Add(-since)
+			enc := json.NewEncoder(os.Stdout)
+			for {
+				logs, err := db.GetUsageLogsSince(conn, orgID, cursor, 500)
+				if err != nil {
+					return err
+				}
+				for _, l := range logs {
+					if jsonOutput {
+						if err := enc.Encode(l); err != nil {
+							return err
+						}
+					} else {
+						cost := 0.0
+						if l.CostUSD != nil {
+							cost = *l.CostUSD
+						}
+						fmt.Printf("%s  org=%s key=%s model=%s %s status=%d tokens=%d cost=$%.6f\n",
+							l.CreatedAt.Format(time.RFC3339), l.OrganizationID, l.APIKeyID, l.ModelID,
+							l.Endpoint, l.ResponseStatus, l.TotalTokens, cost)
+					}
+					cursor = l.CreatedAt
+				}
+				// A full page means more are waiting
+				if len(logs) < 500 {
+					select {
+					case <-cmd.Context().Done():
+						return nil
+					case <-time.After(interval):
+					}
+				}
+			}
+		},
+	}
+	tail.Flags().StringVar(&orgID, "org", "", "only show this organization")
+	tail.Flags().DurationVar(&interval, "interval", 2*time.Second, "polling interval")
+	tail.Flags().DurationVar(&since, "since", 0, "also print logs from this far back, e.g. 10m")
+	cmd.AddCommand(tail)
+
+	return cmd
+}
@@ -0,0 +1,9 @@
+// Package docs embeds the API documentation served by the admin UI
+package docs
+
+import "embed"
+
+// Files holds swagger.yaml and the other docs served under /docs
+//
+//go:embed swagger.yaml README.md api-tests.http
+var Files embed.FS
@@ -28,4 +28,17 @@ var (
 		Help:    "Number of LLM tokens per completion",
 		Buckets: prometheus.LinearBuckets(0, 50, 20),
 	}, []string{"route"})
+	QoSRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_qos_rejected_total",
+		Help: "Requests refused with 429 while the gateway was saturated, by QoS class and reason",
+	}, []string{"class", "reason"})
 )
+
+// RegisterQoSQueueDepth exports how many requests of a QoS class wait for a slot
+func RegisterQoSQueueDepth(class string, depth func() float64) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "gateway_qos_queue_depth",
+		Help:        "Requests waiting for a slot, by QoS class",
+		ConstLabels: prometheus.Labels{"class": class},
+	}, depth)
+}
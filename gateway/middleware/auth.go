@@ -2,38 +2,69 @@ package middleware
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/ephemeraltoken"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+	"github.com/like-mike/relai-gateway/shared/servicetoken"
 )
 
 // AccessibleModel represents a model that the organization has access to
 type AccessibleModel struct {
-	ID                string   `json:"id"`
-	Name              string   `json:"name"`
-	ModelID           string   `json:"model_id"`
-	Provider          string   `json:"provider"`
-	IsActive          bool     `json:"is_active"`
-	ApiToken          string   `json:"api_token"`
-	ApiEndpoint       string   `json:"api_endpoint"`
-	TimeoutSeconds    *int     `json:"timeout_seconds,omitempty"`    // Optional timeout in seconds
-	MaxRetries        *int     `json:"max_retries,omitempty"`        // Optional max retries
-	RetryDelayMs      *int     `json:"retry_delay_ms,omitempty"`     // Optional retry delay in milliseconds
-	BackoffMultiplier *float64 `json:"backoff_multiplier,omitempty"` // Optional backoff
+	ID                string                `json:"id"`
+	Name              string                `json:"name"`
+	ModelID           string                `json:"model_id"`
+	Provider          string                `json:"provider"`
+	IsActive          bool                  `json:"is_active"`
+	ApiToken          string                `json:"api_token"`
+	ApiEndpoint       string                `json:"api_endpoint"`
+	TimeoutSeconds    *int                  `json:"timeout_seconds,omitempty"`    // Optional timeout in seconds
+	MaxRetries        *int                  `json:"max_retries,omitempty"`        // Optional max retries
+	RetryDelayMs      *int                  `json:"retry_delay_ms,omitempty"`     // Optional retry delay in milliseconds
+	BackoffMultiplier *float64              `json:"backoff_multiplier,omitempty"` // Optional backoff
+	AWSRegion         string                `json:"aws_region,omitempty"`         // Bedrock only
+	AWSAccessKeyID    string                `json:"aws_access_key_id,omitempty"`  // Bedrock only; empty uses AWS_* env vars
+	AWSSecretKey      string                `json:"-"`                            // Bedrock only; encrypted
+	AWSRoleARN        string                `json:"aws_role_arn,omitempty"`       // Bedrock only
+	GCPLocation       string                `json:"gcp_location,omitempty"`       // Vertex AI only
+	GCPServiceAccount string                `json:"-"`                            // Vertex AI only; encrypted
+	MaxRequestBytes   *int                  `json:"max_request_bytes,omitempty"`  // Optional request body limit
+	Metadata          models.ModelMetadata  `json:"metadata"`                     // Capabilities checked before proxying
+	Transport         models.ModelTransport `json:"-"`                            // Proxy, CA and TLS options for reaching the provider
 }
 
 // APIKeyAuth validates bearer tokens and stores accessible models in context
 func APIKeyAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 1. Extract bearer token
+		// 1. Extract bearer token, an ephemeral token minted from a key, or a service token from
+		// the admin UI naming a key by ID
 		token := extractBearerToken(c)
+		var claims *servicetoken.Claims
+		var ephemeral *ephemeraltoken.Claims
 		if token == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Missing or invalid authorization token",
-			})
+			credential := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			var err error
+			switch {
+			case ephemeraltoken.IsToken(credential):
+				if ephemeral, err = ephemeraltoken.Verify(credential); err != nil {
+					log.Printf("Rejected ephemeral token: %v", err)
+				}
+			case servicetoken.IsToken(credential):
+				if claims, err = servicetoken.Verify(credential); err != nil {
+					log.Printf("Rejected service token: %v", err)
+				}
+			}
+		}
+		if token == "" && claims == nil && ephemeral == nil {
+			AbortWithError(c, http.StatusUnauthorized, models.ErrorAuthenticationRequired, "Missing or invalid authorization token")
 			return
 		}
 
@@ -41,26 +72,48 @@ func APIKeyAuth() gin.HandlerFunc {
 		// 2. Get database connection
 		db := getDatabaseFromContext(c)
 		if db == nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "Internal server error",
-			})
+			AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Internal server error")
 			return
 		}
 		log.Println("Database connection found, proceeding with API key validation")
 
 		// 3. Validate token and get organization
-		orgID, keyID, err := validateAPIKeyAndGetOrg(db, token)
+		var orgID, keyID string
+		var scopes []string
+		var err error
+		switch {
+		case ephemeral != nil:
+			// The signed claims stand in for the key lookup; they expire within the hour
+			orgID, keyID, scopes = ephemeral.OrganizationID, ephemeral.APIKeyID(), ephemeral.Scopes
+		case claims != nil:
+			orgID, keyID, scopes, err = validateAPIKeyIDAndGetOrg(db, claims.APIKeyID(), claims.OrganizationID)
+		default:
+			orgID, keyID, scopes, err = validateAPIKeyAndGetOrg(db, token)
+		}
 		if err != nil {
+			// Canary keys get the same answer as any other invalid key
+			if err == sql.ErrNoRows && token != "" {
+				tripCanary(c, db, token)
+			}
 			log.Printf("API key validation failed: %v", err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid or inactive API key",
-			})
+			AbortWithError(c, http.StatusUnauthorized, models.ErrorInvalidAPIKey, "Invalid or inactive API key")
 			return
 		}
 		log.Printf("API key validated successfully for organization %s", orgID)
 
+		// Keys limited to some endpoint families can't call the others. Any key may mint ephemeral
+		// tokens, which can only narrow its scopes, and stop the requests it made.
+		path := c.Request.URL.Path
+		exempt := path == models.EphemeralTokenPath || strings.HasPrefix(path, models.StopRequestPathPrefix)
+		if scope := models.APIKeyScopeForPath(path); !exempt && !models.APIKeyAllows(scopes, scope) {
+			log.Printf("API key %s lacks scope %s", keyID, scope)
+			AbortWithError(c, http.StatusForbidden, models.ErrorScopeRequired,
+				fmt.Sprintf("API key is not allowed to use this endpoint (scope %s required)", scope))
+			return
+		}
+
 		// 4. Query accessible models for the organization
-		accessibleModels, err := getAccessibleModels(db, orgID)
+		accessibleModels, err := GetAccessibleModels(db, orgID)
 		if err != nil {
 			log.Printf("Warning: Could not fetch accessible models for org %s: %v", orgID, err)
 			accessibleModels = []AccessibleModel{} // Empty but not nil
@@ -70,13 +123,17 @@ func APIKeyAuth() gin.HandlerFunc {
 		// 5. Store in context for downstream handlers
 		c.Set("organization_id", orgID)
 		c.Set("api_key_id", keyID)
+		c.Set("api_key_scopes", scopes)
 		c.Set("accessible_models", accessibleModels)
 		c.Set("api_key", token)
+		c.Set("ephemeral_token", ephemeral != nil)
 
 		log.Printf("Authenticated organization %s with access to %d models", orgID, len(accessibleModels))
 
-		// 6. Update last used timestamp (async)
-		go updateAPIKeyLastUsed(db, keyID)
+		// 6. Update last used timestamp (async); the key was used when its ephemeral token was minted
+		if ephemeral == nil {
+			go updateAPIKeyLastUsed(db, keyID)
+		}
 
 		c.Next()
 	}
@@ -122,46 +179,90 @@ func getDatabaseFromContext(c *gin.Context) *sql.DB {
 	return sqlDB
 }
 
-// validateAPIKeyAndGetOrg validates the API key and returns organization ID and key ID
-func validateAPIKeyAndGetOrg(db *sql.DB, apiKey string) (orgID, keyID string, err error) {
+// validateAPIKeyAndGetOrg validates the API key and returns organization ID, key ID and the
+// key's scopes. Expired and canary keys are rejected.
+func validateAPIKeyAndGetOrg(db *sql.DB, apiKey string) (orgID, keyID string, scopes []string, err error) {
+	query := `
+		SELECT id, organization_id, scopes
+		FROM api_keys
+		WHERE api_key = $1 AND is_active = true AND is_canary = false
+		  AND (expires_at IS NULL OR expires_at > NOW())`
+
+	err = db.QueryRow(query, apiKey).Scan(&keyID, &orgID, pq.Array(&scopes))
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	return orgID, keyID, scopes, nil
+}
+
+// validateAPIKeyIDAndGetOrg is validateAPIKeyAndGetOrg for a key named by ID in a service token.
+// The key must still belong to the organization the token was signed for.
+func validateAPIKeyIDAndGetOrg(db *sql.DB, id, tokenOrgID string) (orgID, keyID string, scopes []string, err error) {
 	query := `
-		SELECT id, organization_id
+		SELECT id, organization_id, scopes
 		FROM api_keys
-		WHERE api_key = $1 AND is_active = true`
+		WHERE id = $1 AND organization_id = $2 AND is_active = true AND is_canary = false
+		  AND (expires_at IS NULL OR expires_at > NOW())`
 
-	err = db.QueryRow(query, apiKey).Scan(&keyID, &orgID)
+	err = db.QueryRow(query, id, tokenOrgID).Scan(&keyID, &orgID, pq.Array(&scopes))
 	if err != nil {
-		return "", "", err
+		return "", "", nil, err
 	}
 
-	return orgID, keyID, nil
+	return orgID, keyID, scopes, nil
 }
 
-// getAccessibleModels gets models directly from database
-func getAccessibleModels(db *sql.DB, orgID string) ([]AccessibleModel, error) {
+// GetAccessibleModels returns the active models the organization may use
+func GetAccessibleModels(db *sql.DB, orgID string) ([]AccessibleModel, error) {
 	return getAccessibleModelsFromDB(db, orgID)
 }
 
 // getAccessibleModelsFromDB directly queries database (fallback method)
-func getAccessibleModelsFromDB(db *sql.DB, orgID string) ([]AccessibleModel, error) {
-	query := `
+func getAccessibleModelsFromDB(sqlDB *sql.DB, orgID string) ([]AccessibleModel, error) {
+	// Grants made to a parent organization are inherited; the nearest level of the hierarchy with
+	// a grant or a denial decides, and a denial wins over a grant at the same level
+	query := db.OrganizationAncestorsCTE + `,
+		decisions AS (
+			SELECT moa.model_id, a.depth, true AS allowed
+			FROM model_organization_access moa
+			JOIN ancestors a ON moa.organization_id = a.id
+			UNION ALL
+			SELECT d.model_id, a.depth, false AS allowed
+			FROM model_organization_access_denials d
+			JOIN ancestors a ON d.organization_id = a.id
+		),
+		access AS (
+			SELECT DISTINCT ON (model_id) model_id, allowed
+			FROM decisions
+			ORDER BY model_id, depth, allowed
+		)
 		SELECT DISTINCT m.id, 
 		m.name, 
 		m.model_id, 
 		m.provider, 
 		m.is_active, 
-		m.api_token, 
+		COALESCE(m.api_token, ''), 
 		m.api_endpoint, 
 		m.timeout_seconds,
 		m.max_retries,
 		m.retry_delay_ms,
-		m.backoff_multiplier
+		m.backoff_multiplier,
+		COALESCE(m.aws_region, ''),
+		COALESCE(m.aws_access_key_id, ''),
+		COALESCE(m.aws_secret_access_key, ''),
+		COALESCE(m.aws_role_arn, ''),
+		COALESCE(m.gcp_location, ''),
+		COALESCE(m.gcp_service_account, ''),
+		m.max_request_bytes,
+		m.metadata,
+		m.transport
 		FROM models m
-		JOIN model_organization_access moa ON m.id = moa.model_id
-		WHERE moa.organization_id = $1 AND m.is_active = true
+		JOIN access ON m.id = access.model_id
+		WHERE access.allowed AND m.is_active = true
 		ORDER BY m.name`
 
-	rows, err := db.Query(query, orgID)
+	rows, err := sqlDB.Query(query, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -170,6 +271,7 @@ func getAccessibleModelsFromDB(db *sql.DB, orgID string) ([]AccessibleModel, err
 	var models []AccessibleModel
 	for rows.Next() {
 		var model AccessibleModel
+		var metadata, transport []byte
 		err := rows.Scan(
 			&model.ID,
 			&model.Name,
@@ -182,19 +284,37 @@ func getAccessibleModelsFromDB(db *sql.DB, orgID string) ([]AccessibleModel, err
 			&model.MaxRetries,
 			&model.RetryDelayMs,
 			&model.BackoffMultiplier, // Optional, can be nil
+			&model.AWSRegion,
+			&model.AWSAccessKeyID,
+			&model.AWSSecretKey,
+			&model.AWSRoleARN,
+			&model.GCPLocation,
+			&model.GCPServiceAccount,
+			&model.MaxRequestBytes, // Optional, can be nil
+			&metadata,
+			&transport,
 		)
 		if err != nil {
 			log.Printf("Error scanning model row: %v", err)
 			continue
 		}
+		if err := json.Unmarshal(metadata, &model.Metadata); err != nil {
+			log.Printf("Ignoring invalid metadata of model %s: %v", model.ModelID, err)
+		}
+		if err := json.Unmarshal(transport, &model.Transport); err != nil {
+			log.Printf("Ignoring invalid transport options of model %s: %v", model.ModelID, err)
+		}
 		models = append(models, model)
 	}
 
 	return models, nil
 }
 
-// updateAPIKeyLastUsed updates the last_used timestamp for the API key
+// updateAPIKeyLastUsed updates the last_used timestamp for the API key; skipped in read-only mode
 func updateAPIKeyLastUsed(db *sql.DB, keyID string) {
+	if readonly.Enabled() {
+		return
+	}
 	query := `UPDATE api_keys SET last_used = NOW(), updated_at = NOW() WHERE id = $1`
 	_, err := db.Exec(query, keyID)
 	if err != nil {
@@ -223,8 +343,11 @@ func OptionalAPIKeyAuth() gin.HandlerFunc {
 		}
 
 		// 3. Validate token and get organization
-		orgID, keyID, err := validateAPIKeyAndGetOrg(db, token)
+		orgID, keyID, _, err := validateAPIKeyAndGetOrg(db, token)
 		if err != nil {
+			if err == sql.ErrNoRows {
+				tripCanary(c, db, token)
+			}
 			log.Println("Invalid API key:", err)
 			// Invalid API key, but don't block the request for optional auth
 			c.Next()
@@ -232,7 +355,7 @@ func OptionalAPIKeyAuth() gin.HandlerFunc {
 		}
 
 		// 4. Query accessible models for the organization
-		accessibleModels, err := getAccessibleModels(db, orgID)
+		accessibleModels, err := GetAccessibleModels(db, orgID)
 		if err != nil {
 			log.Printf("Warning: Could not fetch accessible models for org %s: %v", orgID, err)
 			accessibleModels = []AccessibleModel{} // Empty but not nil
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// blockedIPsRefreshInterval is how stale the cached block list may get; blocks made by this
+// process apply at once, others (and removals) within this interval
+const blockedIPsRefreshInterval = 30 * time.Second
+
+// blockedIPCache holds the block list so checking a request doesn't cost a query
+type blockedIPCache struct {
+	mu       sync.RWMutex
+	ips      map[string]bool
+	loadedAt time.Time
+}
+
+var blockedIPs = &blockedIPCache{}
+
+func (b *blockedIPCache) add(ip string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ips == nil {
+		b.ips = map[string]bool{}
+	}
+	b.ips[ip] = true
+}
+
+// stale reports whether the list is due a reload, claiming the reload when it is so only one
+// request does it
+func (b *blockedIPCache) stale() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Since(b.loadedAt) < blockedIPsRefreshInterval {
+		return false
+	}
+	b.loadedAt = time.Now()
+	return true
+}
+
+func (b *blockedIPCache) blocked(ip string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.ips[ip]
+}
+
+// BlockedIPs rejects requests from addresses on the block list with 403
+func BlockedIPs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sqlDB := getDatabaseFromContext(c); sqlDB != nil && blockedIPs.stale() {
+			list, err := db.GetBlockedIPs(sqlDB)
+			if err != nil {
+				log.Printf("Failed to load blocked IPs: %v", err)
+			} else {
+				ips := make(map[string]bool, len(list))
+				for _, b := range list {
+					ips[b.IPAddress] = true
+				}
+				blockedIPs.mu.Lock()
+				blockedIPs.ips = ips
+				blockedIPs.mu.Unlock()
+			}
+		}
+
+		if blockedIPs.blocked(c.ClientIP()) {
+			AbortWithError(c, http.StatusForbidden, models.ErrorIPBlocked, "Forbidden")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// canaryAlertInterval limits alerts to one per canary key in this window, so a scraper retrying
+// a key doesn't flood the admins; every request is still recorded
+const canaryAlertInterval = 5 * time.Minute
+
+var (
+	canaryAlertsMu sync.Mutex
+	canaryAlerts   = map[string]time.Time{}
+)
+
+// sensitiveHeaders are masked in canary hit records
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+}
+
+// tripCanary checks whether token is a canary key and, if so, records the request, blocks the
+// caller when the key asks for it and alerts the organization's admins. The caller rejects the
+// request like any other invalid key so the holder can't tell the key is a decoy.
+func tripCanary(c *gin.Context, sqlDB *sql.DB, token string) bool {
+	key, err := db.GetCanaryKey(sqlDB, token)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to check for canary key: %v", err)
+		}
+		return false
+	}
+
+	hit := models.CanaryHit{
+		APIKeyID:       key.ID,
+		OrganizationID: key.OrganizationID,
+		IPAddress:      c.ClientIP(),
+		Method:         c.Request.Method,
+		Path:           c.Request.URL.Path,
+		Headers:        canaryHeaders(c),
+	}
+	log.Printf("Canary API key %s of organization %s used from %s", key.ID, key.OrganizationID, hit.IPAddress)
+
+	blocked := false
+	if !readonly.Enabled() {
+		if err := db.CreateCanaryHit(sqlDB, hit); err != nil {
+			log.Printf("Failed to record canary key hit: %v", err)
+		}
+		if key.CanaryBlockIP {
+			if err := db.BlockIP(sqlDB, hit.IPAddress, "Used canary API key "+key.Name, &key.ID, nil); err != nil {
+				log.Printf("Failed to block %s after canary key use: %v", hit.IPAddress, err)
+			} else {
+				blocked = true
+				blockedIPs.add(hit.IPAddress)
+			}
+		}
+	}
+
+	canaryAlertsMu.Lock()
+	due := time.Since(canaryAlerts[key.ID]) >= canaryAlertInterval
+	if due {
+		canaryAlerts[key.ID] = time.Now()
+	}
+	canaryAlertsMu.Unlock()
+	if due {
+		go func() {
+			if err := email.NewService(sqlDB).NotifyCanaryKeyUse(key, hit, blocked); err != nil {
+				log.Printf("Failed to alert on canary key %s: %v", key.ID, err)
+			}
+		}()
+	}
+
+	return true
+}
+
+// canaryHeaders flattens the request headers, masking credentials
+func canaryHeaders(c *gin.Context) map[string]string {
+	headers := make(map[string]string, len(c.Request.Header))
+	for name, values := range c.Request.Header {
+		value := strings.Join(values, ", ")
+		if sensitiveHeaders[name] {
+			value = models.MaskSecret(value)
+		}
+		headers[name] = value
+	}
+	return headers
+}
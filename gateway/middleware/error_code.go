@@ -0,0 +1,13 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// AbortWithError answers the request with an error carrying its catalog code, and keeps the
+// code in the context as error_code for the usage log
+func AbortWithError(c *gin.Context, status int, code models.ErrorCode, message string) {
+	c.Set("error_code", code.Code)
+	c.AbortWithStatusJSON(status, code.Body(message))
+}
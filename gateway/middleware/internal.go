@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// InternalAuth guards the /internal routes the admin UI calls. It needs GATEWAY_INTERNAL_TOKEN
+// as a bearer token; without the variable set the routes are off (404).
+func InternalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := os.Getenv("GATEWAY_INTERNAL_TOKEN")
+		if token == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		given := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			AbortWithError(c, http.StatusUnauthorized, models.ErrorAuthenticationRequired, "invalid internal token")
+			return
+		}
+		c.Next()
+	}
+}
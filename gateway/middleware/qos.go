@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/metrics"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/qos"
+)
+
+// qosClassRefreshInterval is how long an organization's QoS class is cached; changes apply
+// within this interval
+const qosClassRefreshInterval = 30 * time.Second
+
+const (
+	defaultQoSQueueDepth = 100
+	defaultQoSMaxWait    = 30 * time.Second
+)
+
+var (
+	qosScheduler     *qos.Scheduler
+	qosSchedulerOnce sync.Once
+)
+
+// newQoSSlots builds the pool the scheduler hands out; its slots are per-process unless
+// SetQoSSlots shares them across replicas
+var newQoSSlots = qos.NewLocalSlots
+
+// SetQoSSlots replaces how the pool of GATEWAY_MAX_CONCURRENT_REQUESTS slots is built; call
+// before serving
+func SetQoSSlots(newSlots func(capacity int) qos.Slots) {
+	newQoSSlots = newSlots
+}
+
+// scheduler returns the QoS scheduler, or nil when GATEWAY_MAX_CONCURRENT_REQUESTS doesn't
+// limit concurrency. Each class queues up to GATEWAY_QOS_QUEUE_DEPTH requests for at most
+// GATEWAY_QOS_MAX_WAIT.
+func scheduler() *qos.Scheduler {
+	qosSchedulerOnce.Do(func() {
+		capacity, err := strconv.Atoi(os.Getenv("GATEWAY_MAX_CONCURRENT_REQUESTS"))
+		if err != nil || capacity <= 0 {
+			return
+		}
+		depth, err := strconv.Atoi(os.Getenv("GATEWAY_QOS_QUEUE_DEPTH"))
+		if err != nil || depth < 0 {
+			depth = defaultQoSQueueDepth
+		}
+		wait, err := time.ParseDuration(os.Getenv("GATEWAY_QOS_MAX_WAIT"))
+		if err != nil || wait <= 0 {
+			wait = defaultQoSMaxWait
+		}
+
+		s := qos.NewSchedulerWithSlots(newQoSSlots(capacity), depth, wait)
+		for _, class := range models.QoSClasses {
+			metrics.RegisterQoSQueueDepth(class, func() float64 { return float64(s.QueueDepth(class)) })
+		}
+		qosScheduler = s
+		log.Printf("QoS scheduling %d concurrent requests, queueing up to %d per class for %v", capacity, depth, wait)
+	})
+	return qosScheduler
+}
+
+type cachedQoSClass struct {
+	class    string
+	loadedAt time.Time
+}
+
+// qosClasses caches organizations' QoS classes so scheduling a request doesn't cost a query
+var qosClasses = struct {
+	sync.Mutex
+	orgs map[string]cachedQoSClass
+}{orgs: map[string]cachedQoSClass{}}
+
+// organizationQoSClass returns the organization's QoS class, falling back to the default when it
+// can't be loaded
+func organizationQoSClass(c *gin.Context, orgID string) string {
+	qosClasses.Lock()
+	cached, ok := qosClasses.orgs[orgID]
+	qosClasses.Unlock()
+	if ok && time.Since(cached.loadedAt) < qosClassRefreshInterval {
+		return cached.class
+	}
+
+	sqlDB := getDatabaseFromContext(c)
+	if sqlDB == nil {
+		return models.DefaultQoSClass
+	}
+	class, err := db.GetOrganizationQoSClass(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to load QoS class for organization %s: %v", orgID, err)
+		if ok {
+			return cached.class
+		}
+		return models.DefaultQoSClass
+	}
+
+	qosClasses.Lock()
+	qosClasses.orgs[orgID] = cachedQoSClass{class: class, loadedAt: time.Now()}
+	qosClasses.Unlock()
+	return class
+}
+
+// QoS holds proxied requests to GATEWAY_MAX_CONCURRENT_REQUESTS at once. Once saturated, gold
+// organizations' requests are served before silver ones, and bronze ones get a 429 with
+// Retry-After, as do requests whose queue is full or that wait too long. Must run after
+// APIKeyAuth.
+func QoS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := scheduler()
+		if s == nil {
+			c.Next()
+			return
+		}
+
+		class := organizationQoSClass(c, c.GetString("organization_id"))
+		release, err := s.Acquire(c.Request.Context(), class)
+		if err != nil {
+			if c.Request.Context().Err() != nil {
+				// The client gave up while queued
+				c.Abort()
+				return
+			}
+
+			reason := "timeout"
+			switch {
+			case errors.Is(err, qos.ErrShed):
+				reason = "shed"
+			case errors.Is(err, qos.ErrQueueFull):
+				reason = "queue_full"
+			}
+			metrics.QoSRejectedTotal.WithLabelValues(class, reason).Inc()
+			log.Printf("QoS refused a %s request for organization %s: %v", class, c.GetString("organization_id"), err)
+
+			c.Header("Retry-After", "1")
+			AbortWithError(c, http.StatusTooManyRequests, models.ErrorGatewaySaturated, "The gateway is at capacity. Try again shortly.")
+			return
+		}
+		defer release()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// OpenAI-compatible rate limit headers. SDKs with built-in backoff read these.
+const (
+	headerLimitRequests     = "x-ratelimit-limit-requests"
+	headerRemainingRequests = "x-ratelimit-remaining-requests"
+	headerResetRequests     = "x-ratelimit-reset-requests"
+	headerLimitTokens       = "x-ratelimit-limit-tokens"
+	headerRemainingTokens   = "x-ratelimit-remaining-tokens"
+	headerResetTokens       = "x-ratelimit-reset-tokens"
+)
+
+const requestWindow = time.Minute
+
+type requestWindowCount struct {
+	start time.Time
+	count int
+}
+
+// requestCounts holds each API key's request count for the current one-minute window
+var requestCounts = struct {
+	sync.Mutex
+	keys map[string]*requestWindowCount
+}{keys: map[string]*requestWindowCount{}}
+
+// requestsPerMinute is the per-API-key request limit; 0 (the default) turns it off
+func requestsPerMinute() int {
+	if n, err := strconv.Atoi(os.Getenv("RATE_LIMIT_REQUESTS_PER_MINUTE")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// localRequestLimiter keeps the windows in this process, so each replica enforces the limit on
+// its own
+type localRequestLimiter struct{}
+
+func (localRequestLimiter) Take(_ context.Context, keyID string, limit int, now time.Time) (int, time.Duration, bool) {
+	return takeRequest(keyID, limit, now)
+}
+
+// takeRequest counts a request against the key's window and returns how many are left and when
+// the window resets. ok is false when the key is already at its limit.
+func takeRequest(keyID string, limit int, now time.Time) (remaining int, reset time.Duration, ok bool) {
+	requestCounts.Lock()
+	defer requestCounts.Unlock()
+
+	window, exists := requestCounts.keys[keyID]
+	if !exists || now.Sub(window.start) >= requestWindow {
+		if len(requestCounts.keys) > 10000 {
+			// Drop finished windows so keys that stopped calling don't accumulate
+			for k, w := range requestCounts.keys {
+				if now.Sub(w.start) >= requestWindow {
+					delete(requestCounts.keys, k)
+				}
+			}
+		}
+		window = &requestWindowCount{start: now}
+		requestCounts.keys[keyID] = window
+	}
+
+	reset = window.start.Add(requestWindow).Sub(now)
+	if window.count >= limit {
+		return 0, reset, false
+	}
+	window.count++
+	return limit - window.count, reset, true
+}
+
+// formatReset renders a reset interval the way OpenAI does, e.g. "1s" or "6m0s"
+func formatReset(d time.Duration) string {
+	if d < time.Second {
+		d = time.Second
+	}
+	return d.Round(time.Second).String()
+}
+
+// RateLimitHeaders sets x-ratelimit-* headers from the gateway's own state: the per-key request
+// limit (when RATE_LIMIT_REQUESTS_PER_MINUTE is set; shared across replicas when REDIS_URL is)
+// and the organization's token quota. Requests over the request limit get a 429 with
+// Retry-After. Must run after APIKeyAuth.
+func RateLimitHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.GetString("api_key_id")
+		orgID := c.GetString("organization_id")
+
+		if limit := requestsPerMinute(); limit > 0 && keyID != "" {
+			remaining, reset, ok := requestLimiter.Take(c.Request.Context(), keyID, limit, time.Now())
+			c.Header(headerLimitRequests, strconv.Itoa(limit))
+			c.Header(headerRemainingRequests, strconv.Itoa(remaining))
+			c.Header(headerResetRequests, formatReset(reset))
+
+			if !ok {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+				AbortWithError(c, http.StatusTooManyRequests, models.ErrorRateLimited,
+					"Rate limit reached for requests. Try again in "+formatReset(reset)+".")
+				return
+			}
+		}
+
+		if sqlDB := getDatabaseFromContext(c); sqlDB != nil && orgID != "" {
+			setQuotaHeaders(c, sqlDB, orgID, keyID)
+		}
+
+		c.Next()
+	}
+}
+
+// setQuotaHeaders maps the organization's token quota onto the token headers. A sub-team also
+// draws on its parents' quotas, and a key in a project with its own quota on the project's, so
+// the level with the least remaining is reported. Keys without a quota at any level get none.
+func setQuotaHeaders(c *gin.Context, sqlDB *sql.DB, orgID, keyID string) {
+	quotas, err := db.GetQuotaChain(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to load quota for rate limit headers (org %s): %v", orgID, err)
+		return
+	}
+
+	limit, remaining := 0, 0
+	var reset time.Duration
+	for i, q := range quotas {
+		if i == 0 || q.TotalQuota-q.UsedTokens < remaining {
+			limit, remaining = q.TotalQuota, q.TotalQuota-q.UsedTokens
+			reset = time.Until(q.ResetDate)
+		}
+	}
+
+	if keyID != "" {
+		project, err := db.GetAPIKeyProject(sqlDB, keyID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Failed to load project for rate limit headers (key %s): %v", keyID, err)
+		}
+		if err == nil && project.TokenQuota != nil {
+			projectRemaining := int(*project.TokenQuota - project.UsedTokens)
+			if len(quotas) == 0 || projectRemaining < remaining {
+				// Project quotas don't reset on a schedule
+				limit, remaining, reset = int(*project.TokenQuota), projectRemaining, 0
+			}
+		}
+	}
+
+	if limit == 0 {
+		return
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Header(headerLimitTokens, strconv.Itoa(limit))
+	c.Header(headerRemainingTokens, strconv.Itoa(remaining))
+	if reset > 0 {
+		c.Header(headerResetTokens, formatReset(reset))
+	}
+}
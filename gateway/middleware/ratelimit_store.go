@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RequestLimiter counts requests against each API key's one-minute window. It returns how many
+// requests are left and when the window resets; ok is false when the key is over its limit.
+type RequestLimiter interface {
+	Take(ctx context.Context, keyID string, limit int, now time.Time) (remaining int, reset time.Duration, ok bool)
+}
+
+// requestLimiter is per-process unless SetRequestLimiter shares it across replicas
+var requestLimiter RequestLimiter = localRequestLimiter{}
+
+// SetRequestLimiter replaces the per-process request limiter; call before serving
+func SetRequestLimiter(l RequestLimiter) {
+	requestLimiter = l
+}
+
+// takeRequestScript increments the key's window, starting it on the first request, and returns
+// the count and the milliseconds left in the window
+var takeRequestScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {n, redis.call('PTTL', KEYS[1])}
+`)
+
+// RedisRequestLimiter keeps the request windows in Redis so every gateway replica enforces the
+// same limit. If Redis can't be reached it falls back to counting in this process rather than
+// failing requests, logging once when it falls back and once when Redis is back.
+type RedisRequestLimiter struct {
+	client   *redis.Client
+	prefix   string
+	degraded atomic.Bool // Whether requests are being counted locally
+}
+
+// NewRedisRequestLimiter returns a limiter storing windows under prefix in client
+func NewRedisRequestLimiter(client *redis.Client, prefix string) *RedisRequestLimiter {
+	return &RedisRequestLimiter{client: client, prefix: prefix}
+}
+
+func (l *RedisRequestLimiter) Take(ctx context.Context, keyID string, limit int, now time.Time) (int, time.Duration, bool) {
+	result, err := takeRequestScript.Run(ctx, l.client, []string{l.prefix + "ratelimit:requests:" + keyID},
+		requestWindow.Milliseconds()).Int64Slice()
+	if err != nil || len(result) != 2 {
+		if l.degraded.CompareAndSwap(false, true) {
+			log.Printf("Redis rate limit unavailable, counting locally until it is back: %v", err)
+		}
+		return takeRequest(keyID, limit, now)
+	}
+	if l.degraded.CompareAndSwap(true, false) {
+		log.Printf("Redis rate limit available again, counting in Redis")
+	}
+
+	count, ttl := int(result[0]), time.Duration(result[1])*time.Millisecond
+	if ttl < 0 {
+		ttl = requestWindow
+	}
+	if count > limit {
+		return 0, ttl, false
+	}
+	return limit - count, ttl, true
+}
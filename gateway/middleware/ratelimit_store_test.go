@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisRequestLimiterLogsFallbackOnce(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	// Nothing listens on port 1, so every call fails
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 50 * time.Millisecond, MaxRetries: -1})
+	defer client.Close()
+	limiter := NewRedisRequestLimiter(client, "test:")
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		remaining, _, ok := limiter.Take(context.Background(), "fallback-key", 10, now)
+		assert.True(t, ok)
+		assert.Equal(t, 10-i-1, remaining)
+	}
+	assert.Equal(t, 1, strings.Count(logs.String(), "counting locally"))
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// RejectWrites is readonly.RejectWrites for the gateway's own endpoints: while read-only mode
+// is on, requests other than GET, HEAD and OPTIONS get a 503 with its catalog code
+func RejectWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if readonly.Enabled() {
+				c.Header("Retry-After", "300")
+				AbortWithError(c, http.StatusServiceUnavailable, models.ErrorReadOnly, readonly.RejectMessage)
+				return
+			}
+		}
+		c.Next()
+	}
+}
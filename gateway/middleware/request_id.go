@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the gateway's request ID on responses and provider calls
+const RequestIDHeader = "X-RelAI-Request-Id"
+
+// RequestID gives every request a fresh UUID, stored in the context as request_id and returned
+// in the X-RelAI-Request-Id header. It is the correlation key for logs, traces, usage logs and
+// the feedback API. IDs sent by clients are ignored so they can't collide with another request's.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := uuid.NewString()
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
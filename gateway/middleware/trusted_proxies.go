@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustProxies sets which proxies' X-Forwarded-For the router believes, from the
+// comma-separated addresses and CIDRs in GATEWAY_TRUSTED_PROXIES. None are trusted by default,
+// so the client IP that rate limits, canary blocks and the usage log see is the connection's
+// peer and can't be spoofed with a header.
+func TrustProxies(r *gin.Engine) error {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("GATEWAY_TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return r.SetTrustedProxies(proxies)
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTrustedProxiesRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := TrustProxies(r); err != nil {
+		t.Fatalf("TrustProxies: %v", err)
+	}
+	r.Use(BlockedIPs())
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+	return r
+}
+
+func request(r *gin.Engine, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestSpoofedForwardedForIsIgnored(t *testing.T) {
+	t.Setenv("GATEWAY_TRUSTED_PROXIES", "")
+	blockedIPs.add("203.0.113.9")
+	defer func() {
+		blockedIPs.mu.Lock()
+		delete(blockedIPs.ips, "203.0.113.9")
+		blockedIPs.mu.Unlock()
+	}()
+	r := newTrustedProxiesRouter(t)
+
+	// The IP a canary hit would block is the peer's, not the header's
+	w := request(r, "198.51.100.7:4000", "127.0.0.1")
+	if w.Code != http.StatusOK || w.Body.String() != "198.51.100.7" {
+		t.Errorf("client IP = %q (status %d), want the peer 198.51.100.7", w.Body.String(), w.Code)
+	}
+
+	// A blocked caller can't get around the block with the header
+	if w := request(r, "203.0.113.9:4000", "192.0.2.1"); w.Code != http.StatusForbidden {
+		t.Errorf("blocked caller with a spoofed header got %d, want 403", w.Code)
+	}
+}
+
+func TestTrustedProxyForwardedFor(t *testing.T) {
+	t.Setenv("GATEWAY_TRUSTED_PROXIES", "10.0.0.0/8")
+	r := newTrustedProxiesRouter(t)
+
+	if w := request(r, "10.1.2.3:4000", "198.51.100.7"); w.Body.String() != "198.51.100.7" {
+		t.Errorf("client IP behind a trusted proxy = %q, want 198.51.100.7", w.Body.String())
+	}
+	if w := request(r, "192.0.2.1:4000", "198.51.100.7"); w.Body.String() != "192.0.2.1" {
+		t.Errorf("client IP from an untrusted peer = %q, want 192.0.2.1", w.Body.String())
+	}
+}
@@ -0,0 +1,198 @@
+// Package preflight checks at startup that the gateway can serve traffic: the database answers,
+// active models load, the tokenizers build and, optionally, each provider accepts its
+// credentials. Readiness stays false until every check passes, so an orchestrator holds traffic
+// back from a bad deploy.
+package preflight
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+// retryInterval is how long a failed preflight waits before running again
+const retryInterval = 10 * time.Second
+
+// Check is the outcome of one preflight check
+type Check struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Detail   string `json:"detail,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of the latest preflight run
+type Report struct {
+	Ready     bool      `json:"ready"`
+	Checks    []Check   `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+var state = struct {
+	sync.RWMutex
+	report Report
+}{}
+
+// Status returns the latest report. Before the first run completes it reports not ready.
+func Status() Report {
+	state.RLock()
+	defer state.RUnlock()
+	return state.report
+}
+
+// Enabled reports whether preflight runs; GATEWAY_PREFLIGHT=off skips it and the gateway is
+// ready immediately
+func Enabled() bool {
+	return os.Getenv("GATEWAY_PREFLIGHT") != "off"
+}
+
+// Start runs the preflight in the background, retrying until it passes. With preflight off the
+// gateway is marked ready straight away.
+func Start(ctx context.Context, conn *sql.DB) {
+	if !Enabled() {
+		setReport(Report{Ready: true, CheckedAt: time.Now()})
+		return
+	}
+
+	go func() {
+		for {
+			report := Run(ctx, conn)
+			setReport(report)
+			if report.Ready {
+				log.Println("Preflight passed; gateway is ready")
+				return
+			}
+			for _, check := range report.Checks {
+				if !check.OK {
+					log.Printf("Preflight check %s failed: %s", check.Name, check.Detail)
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+		}
+	}()
+}
+
+func setReport(report Report) {
+	state.Lock()
+	state.report = report
+	state.Unlock()
+}
+
+// Run performs every check once. Provider checks run only when GATEWAY_PREFLIGHT_PROVIDERS is
+// true.
+func Run(ctx context.Context, conn *sql.DB) Report {
+	report := Report{Ready: true, CheckedAt: time.Now()}
+	add := func(name string, fn func() (string, error)) bool {
+		start := time.Now()
+		detail, err := fn()
+		check := Check{Name: name, OK: err == nil, Detail: detail, Duration: time.Since(start).Round(time.Millisecond).String()}
+		if err != nil {
+			check.Detail = err.Error()
+			report.Ready = false
+		}
+		report.Checks = append(report.Checks, check)
+		return err == nil
+	}
+
+	if !add("database", func() (string, error) { return "", conn.PingContext(ctx) }) {
+		return report
+	}
+
+	var active []providerModel
+	add("models", func() (string, error) {
+		var err error
+		active, err = loadActiveModels(ctx, conn)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d active", len(active)), nil
+	})
+
+	add("tokenizers", func() (string, error) { return "", usage.VerifyEncoders() })
+
+	if os.Getenv("GATEWAY_PREFLIGHT_PROVIDERS") == "true" {
+		for _, m := range active {
+			m := m
+			add("provider:"+m.Name, func() (string, error) { return checkProvider(ctx, m) })
+		}
+	}
+
+	return report
+}
+
+// providerModel is what a provider check needs to know about a model
+type providerModel struct {
+	Name     string
+	Provider string
+	Endpoint string
+	Token    string
+}
+
+func loadActiveModels(ctx context.Context, conn *sql.DB) ([]providerModel, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT name, provider, COALESCE(api_endpoint, ''), COALESCE(api_token, '')
+		FROM models WHERE is_active = true ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []providerModel
+	for rows.Next() {
+		var m providerModel
+		if err := rows.Scan(&m.Name, &m.Provider, &m.Endpoint, &m.Token); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// checkProvider lists the provider's models with the model's credentials. Bedrock and Vertex AI
+// sign requests with cloud credentials and aren't checked.
+func checkProvider(ctx context.Context, m providerModel) (string, error) {
+	if m.Provider == models.ProviderBedrock || m.Provider == models.ProviderVertex {
+		return "skipped: signed with cloud credentials", nil
+	}
+	if m.Endpoint == "" {
+		return "", fmt.Errorf("no API endpoint configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(m.Endpoint, "/")+"/models", nil)
+	if err != nil {
+		return "", err
+	}
+	if m.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.Token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", fmt.Errorf("provider rejected the API token (status %d)", resp.StatusCode)
+	case resp.StatusCode >= 500:
+		return "", fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode), nil
+}
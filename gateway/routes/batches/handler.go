@@ -0,0 +1,321 @@
+package batches
+
+import (
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+const (
+	maxFileBytes = 50 << 20
+
+	listDefaultLimit = 20
+	listMaxLimit     = 100
+)
+
+var idPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// requestContext returns the database, organization and API key of an authenticated request.
+// It writes the error response itself and returns ok=false on failure.
+func requestContext(c *gin.Context) (*sql.DB, string, *string, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return nil, "", nil, false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return nil, "", nil, false
+	}
+
+	orgID := c.GetString("organization_id")
+	if orgID == "" {
+		middleware.AbortWithError(c, http.StatusUnauthorized, models.ErrorAuthenticationRequired, "Authentication required")
+		return nil, "", nil, false
+	}
+
+	var apiKeyID *string
+	if keyID := c.GetString("api_key_id"); keyID != "" {
+		apiKeyID = &keyID
+	}
+
+	return sqlDB, orgID, apiKeyID, true
+}
+
+// UploadFileHandler accepts a JSONL batch input file as multipart form data (fields "file" and
+// "purpose", which must be "batch")
+func UploadFileHandler(c *gin.Context) {
+	sqlDB, orgID, apiKeyID, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileBytes+1<<20)
+	if purpose := c.PostForm("purpose"); purpose != models.BatchFilePurposeInput {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "purpose must be \"batch\"")
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "file is required")
+		return
+	}
+	if header.Size > maxFileBytes {
+		middleware.AbortWithError(c, http.StatusRequestEntityTooLarge, models.ErrorRequestTooLarge, "file is larger than 50 MB")
+		return
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "could not read file")
+		return
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "could not read file")
+		return
+	}
+
+	file := models.BatchFile{
+		OrganizationID: orgID,
+		APIKeyID:       apiKeyID,
+		Purpose:        models.BatchFilePurposeInput,
+		Filename:       header.Filename,
+		Content:        content,
+	}
+	if err := db.CreateBatchFile(sqlDB, &file); err != nil {
+		log.Printf("Failed to store batch file: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to store file")
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// GetFileHandler returns a batch file's metadata
+func GetFileHandler(c *gin.Context) {
+	if file, ok := loadFile(c); ok {
+		c.JSON(http.StatusOK, file)
+	}
+}
+
+// FileContentHandler downloads a batch file, e.g. a batch's output
+func FileContentHandler(c *gin.Context) {
+	if file, ok := loadFile(c); ok {
+		c.Data(http.StatusOK, "application/jsonl", file.Content)
+	}
+}
+
+func loadFile(c *gin.Context) (*models.BatchFile, bool) {
+	sqlDB, orgID, _, ok := requestContext(c)
+	if !ok {
+		return nil, false
+	}
+
+	fileID := c.Param("id")
+	if !idPattern.MatchString(fileID) {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such file")
+		return nil, false
+	}
+
+	file, err := db.GetBatchFile(sqlDB, orgID, fileID)
+	if err == sql.ErrNoRows {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such file")
+		return nil, false
+	} else if err != nil {
+		log.Printf("Failed to get batch file: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to load file")
+		return nil, false
+	}
+	return file, true
+}
+
+// CreateBatchHandler validates an uploaded input file and starts processing it in the
+// background. A file with invalid lines produces a failed batch listing every problem.
+func CreateBatchHandler(c *gin.Context) {
+	sqlDB, orgID, apiKeyID, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "input_file_id, endpoint and completion_window are required")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, err.Error())
+		return
+	}
+
+	// A key limited to some endpoints can only batch those
+	scopes, _ := c.Get("api_key_scopes")
+	keyScopes, _ := scopes.([]string)
+	if !models.APIKeyAllows(keyScopes, models.APIKeyScopeForPath(req.Endpoint)) {
+		middleware.AbortWithError(c, http.StatusForbidden, models.ErrorScopeRequired, "API key is not allowed to use "+req.Endpoint)
+		return
+	}
+
+	if !idPattern.MatchString(req.InputFileID) {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "No such file: "+req.InputFileID)
+		return
+	}
+	file, err := db.GetBatchFile(sqlDB, orgID, req.InputFileID)
+	if err == sql.ErrNoRows || (err == nil && file.Purpose != models.BatchFilePurposeInput) {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "No such batch input file: "+req.InputFileID)
+		return
+	} else if err != nil {
+		log.Printf("Failed to get batch input file: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to load input file")
+		return
+	}
+
+	lines, errs := models.ParseBatchInput(file.Content, req.Endpoint)
+
+	batch := &models.Batch{
+		OrganizationID:   orgID,
+		APIKeyID:         apiKeyID,
+		Endpoint:         req.Endpoint,
+		InputFileID:      file.ID,
+		CompletionWindow: req.CompletionWindow,
+		Status:           models.BatchStatusInProgress,
+		Errors:           errs,
+		RequestCounts:    models.BatchRequestCounts{Total: len(lines)},
+		Metadata:         req.Metadata,
+	}
+	if len(errs) > 0 {
+		batch.Status = models.BatchStatusFailed
+	}
+
+	batch, err = db.CreateBatch(sqlDB, batch)
+	if err != nil {
+		log.Printf("Failed to create batch: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to create batch")
+		return
+	}
+
+	if batch.Status == models.BatchStatusInProgress {
+		go process(sqlDB, batch, lines)
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// GetBatchHandler returns a batch with its progress and aggregate usage
+func GetBatchHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	batchID := c.Param("id")
+	if !idPattern.MatchString(batchID) {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such batch")
+		return
+	}
+
+	batch, err := db.GetBatch(sqlDB, orgID, batchID)
+	if err == sql.ErrNoRows {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such batch")
+		return
+	} else if err != nil {
+		log.Printf("Failed to get batch: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to load batch")
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// ListBatchesHandler lists the organization's batches, newest first. Query parameters: limit
+// (1-100, default 20) and after (the last batch ID of the previous page).
+func ListBatchesHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	limit := listDefaultLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > listMaxLimit {
+			middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+	after := c.Query("after")
+	if after != "" && !idPattern.MatchString(after) {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "Invalid after cursor")
+		return
+	}
+
+	// Fetch one extra to know whether there is another page
+	batches, err := db.GetBatches(sqlDB, orgID, after, limit+1)
+	if err != nil {
+		log.Printf("Failed to list batches: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to list batches")
+		return
+	}
+
+	hasMore := len(batches) > limit
+	if hasMore {
+		batches = batches[:limit]
+	}
+	response := gin.H{"object": "list", "data": batches, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(batches) > 0 {
+		response["first_id"] = batches[0].ID
+		response["last_id"] = batches[len(batches)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CancelBatchHandler stops an in-progress batch. Requests already sent finish and are included
+// in the output file; the rest are skipped.
+func CancelBatchHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	batchID := c.Param("id")
+	if !idPattern.MatchString(batchID) {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such batch")
+		return
+	}
+
+	status, err := db.CancelBatch(sqlDB, orgID, batchID)
+	if err == sql.ErrNoRows {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such batch")
+		return
+	} else if err != nil {
+		log.Printf("Failed to cancel batch: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to cancel batch")
+		return
+	}
+	if status != models.BatchStatusCancelling && status != models.BatchStatusCancelled {
+		middleware.AbortWithError(c, http.StatusConflict, models.ErrorConflict, "Cannot cancel a batch with status "+status)
+		return
+	}
+
+	batch, err := db.GetBatch(sqlDB, orgID, batchID)
+	if err != nil {
+		log.Printf("Failed to get batch: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to load batch")
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
@@ -0,0 +1,265 @@
+package batches
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/gateway/routes/proxy"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+const (
+	defaultConcurrency = 4
+
+	// cancelCheckInterval is how many requests run between checks for a cancel request
+	cancelCheckInterval = 20
+
+	// staleBatchAfter is how long a batch can go without progress before it is assumed lost
+	staleBatchAfter = 15 * time.Minute
+)
+
+var (
+	slotsOnce sync.Once
+	slots     chan struct{}
+)
+
+// requestSlots bounds how many batch requests run at once across all batches, so batch work
+// can't starve interactive traffic. Set with BATCH_CONCURRENCY.
+func requestSlots() chan struct{} {
+	slotsOnce.Do(func() {
+		n := defaultConcurrency
+		if v, err := strconv.Atoi(os.Getenv("BATCH_CONCURRENCY")); err == nil && v > 0 {
+			n = v
+		}
+		slots = make(chan struct{}, n)
+	})
+	return slots
+}
+
+// FailStale fails batches left unfinished by a gateway that stopped; run at startup
+func FailStale(sqlDB *sql.DB) {
+	n, err := db.FailStaleBatches(sqlDB, staleBatchAfter)
+	if err != nil {
+		log.Printf("Failed to clean up interrupted batches: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Marked %d interrupted batches as failed", n)
+	}
+}
+
+type lineResult struct {
+	done   bool
+	output models.BatchOutputLine
+}
+
+// process runs a batch's requests against their models and writes the output and error files
+func process(sqlDB *sql.DB, batch *models.Batch, lines []models.BatchRequestLine) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	accessibleModels, err := middleware.GetAccessibleModels(sqlDB, batch.OrganizationID)
+	if err != nil {
+		log.Printf("Batch %s: failed to load models: %v", batch.ID, err)
+		failBatch(sqlDB, batch.ID, "model_lookup_failed", "could not load the organization's models")
+		return
+	}
+
+	apiKeyID := ""
+	if batch.APIKeyID != nil {
+		apiKeyID = *batch.APIKeyID
+	}
+
+	results := make([]lineResult, len(lines))
+	var wg sync.WaitGroup
+
+dispatch:
+	for i, line := range lines {
+		if i > 0 && i%cancelCheckInterval == 0 && cancelRequested(sqlDB, batch.ID) {
+			cancel()
+		}
+
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case requestSlots() <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, line models.BatchRequestLine) {
+			defer wg.Done()
+			defer func() { <-requestSlots() }()
+
+			output, tokens := runLine(batch, apiKeyID, accessibleModels, line)
+			results[i] = lineResult{done: true, output: output}
+
+			completed, failed := 1, 0
+			if output.Error != nil || output.Response.StatusCode >= 400 {
+				completed, failed = 0, 1
+			}
+			if err := db.AddBatchProgress(sqlDB, batch.ID, completed, failed, tokens); err != nil {
+				log.Printf("Batch %s: failed to record progress: %v", batch.ID, err)
+			}
+		}(i, line)
+	}
+	wg.Wait()
+
+	status := models.BatchStatusCompleted
+	if ctx.Err() != nil || cancelRequested(sqlDB, batch.ID) {
+		status = models.BatchStatusCancelled
+	} else if err := db.SetBatchFinalizing(sqlDB, batch.ID); err != nil {
+		log.Printf("Batch %s: failed to mark finalizing: %v", batch.ID, err)
+	}
+
+	outputFileID, errorFileID, err := writeResults(sqlDB, batch, results)
+	if err != nil {
+		log.Printf("Batch %s: failed to write results: %v", batch.ID, err)
+		failBatch(sqlDB, batch.ID, "output_failed", "could not store the batch results")
+		return
+	}
+
+	if err := db.FinishBatch(sqlDB, batch.ID, status, outputFileID, errorFileID); err != nil {
+		log.Printf("Batch %s: failed to finish: %v", batch.ID, err)
+		return
+	}
+	log.Printf("Batch %s %s", batch.ID, status)
+}
+
+// runLine sends one batch request and returns its output line and token usage. Each request is
+// tracked as normal usage, tagged with the batch ID.
+func runLine(batch *models.Batch, apiKeyID string, accessibleModels []middleware.AccessibleModel, line models.BatchRequestLine) (models.BatchOutputLine, models.BatchUsage) {
+	output := models.BatchOutputLine{ID: "batch_req_" + batch.ID + "_" + line.CustomID, CustomID: line.CustomID}
+	var tokens models.BatchUsage
+
+	var cfg *middleware.AccessibleModel
+	for i := range accessibleModels {
+		if accessibleModels[i].ModelID == line.Model {
+			cfg = &accessibleModels[i]
+			break
+		}
+	}
+	if cfg == nil {
+		output.Error = &models.BatchError{Code: "model_not_found", Message: fmt.Sprintf("organization does not have access to model: %s", line.Model)}
+		return output, tokens
+	}
+
+	requestID := uuid.NewString()
+	start := time.Now()
+	resp, err := proxy.SendToModel(cfg, line.URL, line.Body, requestID)
+	if err != nil {
+		output.Error = &models.BatchError{Code: "provider_error", Message: "failed to reach provider"}
+		return output, tokens
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		output.Error = &models.BatchError{Code: "provider_error", Message: "failed to read provider response"}
+		return output, tokens
+	}
+	responseTimeMS := int(time.Since(start).Milliseconds())
+
+	metadata := map[string]interface{}{"batch_id": batch.ID}
+	if providerRequestID := resp.Header.Get("X-Request-Id"); providerRequestID != "" {
+		metadata["provider_request_id"] = providerRequestID
+	}
+	selfHosted := models.IsSelfHostedProvider(cfg.Provider)
+	if selfHosted {
+		usage.TrackUsageWithEstimate(batch.OrganizationID, apiKeyID, cfg.ID, cfg.Provider, line.URL, &requestID, resp.StatusCode,
+			&responseTimeMS, body, line.Body, metadata)
+	} else {
+		usage.TrackUsage(batch.OrganizationID, apiKeyID, cfg.ID, cfg.Provider, line.URL, &requestID, resp.StatusCode,
+			&responseTimeMS, body, metadata)
+	}
+
+	u, err := usage.ExtractUsageFromResponse(body, cfg.Provider)
+	if err != nil && selfHosted && resp.StatusCode < 400 {
+		u, err = usage.EstimateUsage(cfg.ModelID, line.Body, body)
+	}
+	if err == nil {
+		tokens = models.BatchUsage{InputTokens: int64(u.PromptTokens), OutputTokens: int64(u.CompletionTokens), TotalTokens: int64(u.TotalTokens)}
+	}
+
+	if !json.Valid(body) {
+		// Keep the output file valid JSONL even if the provider answered with plain text
+		body, _ = json.Marshal(string(body))
+	}
+	output.Response = &models.BatchResponse{StatusCode: resp.StatusCode, RequestID: requestID, Body: body}
+	return output, tokens
+}
+
+// writeResults stores successful responses in the output file and failures in the error file,
+// in input order. Requests that never ran (cancelled batches) appear in neither.
+func writeResults(sqlDB *sql.DB, batch *models.Batch, results []lineResult) (*string, *string, error) {
+	var outputBuf, errorBuf bytes.Buffer
+	for _, r := range results {
+		if !r.done {
+			continue
+		}
+		line, err := json.Marshal(r.output)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf := &outputBuf
+		if r.output.Error != nil || r.output.Response.StatusCode >= 400 {
+			buf = &errorBuf
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	save := func(buf *bytes.Buffer, name string) (*string, error) {
+		if buf.Len() == 0 {
+			return nil, nil
+		}
+		file := models.BatchFile{
+			OrganizationID: batch.OrganizationID,
+			APIKeyID:       batch.APIKeyID,
+			Purpose:        models.BatchFilePurposeOutput,
+			Filename:       fmt.Sprintf("batch_%s_%s.jsonl", batch.ID, name),
+			Content:        buf.Bytes(),
+		}
+		if err := db.CreateBatchFile(sqlDB, &file); err != nil {
+			return nil, err
+		}
+		return &file.ID, nil
+	}
+
+	outputFileID, err := save(&outputBuf, "output")
+	if err != nil {
+		return nil, nil, err
+	}
+	errorFileID, err := save(&errorBuf, "error")
+	if err != nil {
+		return nil, nil, err
+	}
+	return outputFileID, errorFileID, nil
+}
+
+func cancelRequested(sqlDB *sql.DB, batchID string) bool {
+	status, err := db.GetBatchStatus(sqlDB, batchID)
+	if err != nil {
+		log.Printf("Batch %s: failed to check status: %v", batchID, err)
+		return false
+	}
+	return status == models.BatchStatusCancelling
+}
+
+func failBatch(sqlDB *sql.DB, batchID, code, message string) {
+	if err := db.FailBatch(sqlDB, batchID, []models.BatchError{{Code: code, Message: message}}); err != nil {
+		log.Printf("Batch %s: failed to mark failed: %v", batchID, err)
+	}
+}
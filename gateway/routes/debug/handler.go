@@ -0,0 +1,25 @@
+// Package debug serves the traces of requests sent with X-RelAI-Debug, for admins working out
+// which model a request went to, what retried and which policy stepped in
+package debug
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/gateway/routes/proxy"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// TraceHandler returns the debug trace of a request the key's organization sent, given its
+// X-RelAI-Request-Id, from whichever replica handled it when traces are shared. Needs the debug scope, which the auth middleware checks.
+func TraceHandler(c *gin.Context) {
+	requestID := c.Param("request_id")
+	trace, ok := proxy.DebugTrace(c.Request.Context(), requestID, c.GetString("organization_id"))
+	if !ok {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No debug trace for this request")
+		return
+	}
+
+	c.JSON(http.StatusOK, trace)
+}
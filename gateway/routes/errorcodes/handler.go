@@ -0,0 +1,15 @@
+// Package errorcodes serves the catalog of error codes the gateway answers with, so client teams
+// can program against the codes rather than the messages
+package errorcodes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Handler lists every error code with its status, type and meaning. It needs no API key.
+func Handler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": models.ErrorCodes})
+}
@@ -0,0 +1,65 @@
+package feedback
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Handler records end-user feedback (thumbs up/down, 1-5 rating, comment) for a previous
+// gateway response, identified by its request ID
+func Handler(c *gin.Context) {
+	database, exists := c.Get("db")
+	if !exists {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return
+	}
+
+	orgID, _ := c.Get("organization_id")
+	orgIDStr, ok := orgID.(string)
+	if !ok || orgIDStr == "" {
+		middleware.AbortWithError(c, http.StatusUnauthorized, models.ErrorAuthenticationRequired, "Authentication required")
+		return
+	}
+
+	var apiKeyID *string
+	if keyID, exists := c.Get("api_key_id"); exists {
+		if keyIDStr, ok := keyID.(string); ok && keyIDStr != "" {
+			apiKeyID = &keyIDStr
+		}
+	}
+
+	var req models.CreateFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "request_id is required")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, err.Error())
+		return
+	}
+
+	feedback, err := db.CreateResponseFeedback(sqlDB, orgIDStr, apiKeyID, req)
+	if err != nil {
+		log.Printf("Failed to record feedback: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to record feedback")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"feedback": feedback,
+		"message":  "Feedback recorded successfully",
+	})
+}
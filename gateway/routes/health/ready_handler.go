@@ -0,0 +1,18 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/preflight"
+)
+
+// ReadyHandler reports readiness: 503 with the failing checks until the startup preflight passes
+func ReadyHandler(c *gin.Context) {
+	report := preflight.Status()
+	if !report.Ready {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,33 @@
+package maintenance
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// ReadOnlyRequest turns read-only mode on or off
+type ReadOnlyRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// ReadOnlyHandler reports whether this gateway is in read-only mode
+func ReadOnlyHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enabled": readonly.Enabled()})
+}
+
+// SetReadOnlyHandler turns this gateway's read-only mode on or off; the admin UI calls it when
+// an administrator flips the switch
+func SetReadOnlyHandler(c *gin.Context) {
+	var req ReadOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "enabled is required")
+		return
+	}
+
+	readonly.Set(*req.Enabled)
+	c.JSON(http.StatusOK, gin.H{"enabled": readonly.Enabled()})
+}
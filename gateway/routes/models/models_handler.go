@@ -6,7 +6,9 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
 	"github.com/like-mike/relai-gateway/shared/db"
+	sharedmodels "github.com/like-mike/relai-gateway/shared/models"
 )
 
 // ModelsResponse represents the OpenAI-compatible models response
@@ -15,12 +17,13 @@ type ModelsResponse struct {
 	Data   []Model `json:"data"`
 }
 
-// Model represents an OpenAI-compatible model
+// Model represents an OpenAI-compatible model, extended with the gateway's model metadata
 type Model struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	OwnedBy string `json:"owned_by"`
+	ID       string                     `json:"id"`
+	Object   string                     `json:"object"`
+	Created  int64                      `json:"created"`
+	OwnedBy  string                     `json:"owned_by"`
+	Metadata sharedmodels.ModelMetadata `json:"metadata"`
 }
 
 func Handler(c *gin.Context) {
@@ -28,15 +31,16 @@ func Handler(c *gin.Context) {
 	accessibleModelsInterface, hasAuth := c.Get("accessible_models")
 	if hasAuth {
 		// User is authenticated, return only models they have access to
-		if accessibleModels, ok := accessibleModelsInterface.([]AccessibleModel); ok {
+		if accessibleModels, ok := accessibleModelsInterface.([]middleware.AccessibleModel); ok {
 			var models []Model
 			for _, accessibleModel := range accessibleModels {
 				if accessibleModel.IsActive {
 					models = append(models, Model{
-						ID:      accessibleModel.ModelID, // Use the actual model ID (e.g., "gpt-4")
-						Object:  "model",
-						Created: 1677657600, // Use a default timestamp for now
-						OwnedBy: accessibleModel.Provider,
+						ID:       accessibleModel.ModelID, // Use the actual model ID (e.g., "gpt-4")
+						Object:   "model",
+						Created:  1677657600, // Use a default timestamp for now
+						OwnedBy:  accessibleModel.Provider,
+						Metadata: accessibleModel.Metadata,
 					})
 				}
 			}
@@ -83,10 +87,11 @@ func Handler(c *gin.Context) {
 	for _, dbModel := range dbModels {
 		if dbModel.IsActive {
 			models = append(models, Model{
-				ID:      dbModel.ModelID, // Use the actual model ID (e.g., "gpt-4")
-				Object:  "model",
-				Created: dbModel.CreatedAt.Unix(),
-				OwnedBy: dbModel.Provider,
+				ID:       dbModel.ModelID, // Use the actual model ID (e.g., "gpt-4")
+				Object:   "model",
+				Created:  dbModel.CreatedAt.Unix(),
+				OwnedBy:  dbModel.Provider,
+				Metadata: dbModel.Metadata,
 			})
 		}
 	}
@@ -100,16 +105,6 @@ func Handler(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// AccessibleModel represents a model that the organization has access to
-// This should match the type in gateway/middleware/auth.go
-type AccessibleModel struct {
-	ID       string
-	Name     string
-	ModelID  string
-	Provider string
-	IsActive bool
-}
-
 // getStaticModels returns fallback static models when database is unavailable
 func getStaticModels() ModelsResponse {
 	return ModelsResponse{
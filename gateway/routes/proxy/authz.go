@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/opa"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+const (
+	decisionLogQueue     = 1024
+	decisionLogBatch     = 100
+	decisionLogFlushTick = time.Second
+)
+
+var (
+	authzClient atomic.Pointer[opa.Client]
+	decisionLog chan models.AuthzDecision
+)
+
+// StartAuthorization delegates authorization decisions to OPA when OPA_URL is set, recording
+// them in the decision log in batches. The returned func stops asking OPA and flushes the log.
+func StartAuthorization(conn *sql.DB) (stop func()) {
+	cfg := opa.ConfigFromEnv()
+	if !cfg.Enabled() {
+		return func() {}
+	}
+
+	decisionLog = make(chan models.AuthzDecision, decisionLogQueue)
+	authzClient.Store(opa.NewClient(cfg))
+	log.Printf("Authorizing requests with OPA at %s (data.%s, timeout %s, fail open: %t)",
+		cfg.URL, cfg.Path, cfg.Timeout, cfg.FailOpen)
+
+	// The queue is never closed, as requests already past the client check may still send to it
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		writeDecisionLog(conn, decisionLog, done)
+	}()
+	return func() {
+		authzClient.Store(nil)
+		close(done)
+		wg.Wait()
+	}
+}
+
+// writeDecisionLog stores queued decisions until done, then what is left in the queue
+func writeDecisionLog(conn *sql.DB, queue <-chan models.AuthzDecision, done <-chan struct{}) {
+	ticker := time.NewTicker(decisionLogFlushTick)
+	defer ticker.Stop()
+
+	var batch []models.AuthzDecision
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if readonly.Enabled() {
+			// The database is paused; keep the record in the log instead
+			for _, d := range batch {
+				log.Printf("Authorization decision: request %s key %s model %s path %s allowed=%t reason=%q error=%q",
+					d.RequestID, d.APIKeyID, d.Model, d.Path, d.Allowed, d.Reason, d.Error)
+			}
+		} else if err := db.CreateAuthzDecisions(conn, batch); err != nil {
+			log.Printf("Failed to record %d authorization decisions: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-done:
+			for {
+				select {
+				case d := <-queue:
+					batch = append(batch, d)
+				default:
+					flush()
+					return
+				}
+			}
+		case d := <-queue:
+			batch = append(batch, d)
+			if len(batch) >= decisionLogBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// recordDecision queues a decision for the log without ever blocking the request
+func recordDecision(d models.AuthzDecision) {
+	select {
+	case decisionLog <- d:
+	default:
+		log.Printf("Authorization decision log full; dropped decision for request %s (allowed=%t)", d.RequestID, d.Allowed)
+	}
+}
+
+// authorize asks OPA whether the key may make this request. It returns false after answering
+// with the policy's refusal, or with 503 when OPA can't decide and the gateway doesn't fail open.
+func authorize(c *gin.Context, cfg *middleware.AccessibleModel, bodyBytes []byte) bool {
+	client := authzClient.Load()
+	if client == nil {
+		return true
+	}
+	settings := client.Config()
+
+	scopes, _ := c.Get("api_key_scopes")
+	scopeList, _ := scopes.([]string)
+	input := opa.Input{
+		RequestID:      c.GetString("request_id"),
+		OrganizationID: c.GetString("organization_id"),
+		APIKeyID:       c.GetString("api_key_id"),
+		Scopes:         scopeList,
+		Ephemeral:      c.GetBool("ephemeral_token"),
+		Model:          cfg.ModelID,
+		Provider:       cfg.Provider,
+		Method:         c.Request.Method,
+		Path:           c.Request.URL.Path,
+		PayloadBytes:   len(bodyBytes),
+		ClientIP:       c.ClientIP(),
+	}
+
+	start := time.Now()
+	decision, err := client.Decide(c.Request.Context(), input)
+	record := models.AuthzDecision{
+		RequestID:      input.RequestID,
+		OrganizationID: input.OrganizationID,
+		APIKeyID:       input.APIKeyID,
+		Model:          input.Model,
+		Method:         input.Method,
+		Path:           input.Path,
+		PayloadBytes:   input.PayloadBytes,
+		Policy:         settings.Path,
+		DurationMS:     int(time.Since(start).Milliseconds()),
+		ClientIP:       input.ClientIP,
+		CreatedAt:      start,
+	}
+
+	if err != nil {
+		log.Printf("OPA decision failed for request %s: %v", input.RequestID, err)
+		record.Error = err.Error()
+		record.Allowed = settings.FailOpen
+		if !settings.FailOpen {
+			record.Status = http.StatusServiceUnavailable
+		}
+		recordDecision(record)
+		debugPolicy(c, "opa", settings.FailOpen, record.Error)
+		if !settings.FailOpen {
+			middleware.AbortWithError(c, http.StatusServiceUnavailable, models.ErrorAuthorizationUnavailable, "Authorization service unavailable")
+			return false
+		}
+		return true
+	}
+
+	record.Allowed = decision.Allow
+	record.Reason = decision.Reason
+	record.OPADecisionID = decision.DecisionID
+	if !decision.Allow {
+		record.Status = decision.Status
+	}
+	if !decision.Allow || settings.LogAll {
+		recordDecision(record)
+	}
+	debugPolicy(c, "opa", decision.Allow, decision.Reason)
+	if decision.Allow {
+		return true
+	}
+
+	message := decision.Reason
+	if message == "" {
+		message = "Request denied by authorization policy"
+	}
+	middleware.AbortWithError(c, decision.Status, models.ErrorAuthorizationDenied, message)
+	return false
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// imagePartTypes are the content part types that carry an image
+var imagePartTypes = map[string]bool{
+	"image_url":   true,
+	"image":       true,
+	"input_image": true,
+}
+
+// checkModelCapabilities refuses requests needing something the model's metadata says it lacks,
+// such as tools sent to a model without tool support, instead of letting the provider fail them.
+// It returns false after writing a 400 response.
+func checkModelCapabilities(c *gin.Context, cfg *middleware.AccessibleModel, bodyBytes []byte) bool {
+	if err := cfg.Metadata.CheckRequest(cfg.ModelID, requestNeeds(bodyBytes)); err != nil {
+		rejectInvalidRequest(c, err.Error())
+		return false
+	}
+	return true
+}
+
+// unsupportedParamsAction returns what to do with parameters the model's provider doesn't
+// accept: the model's own action, else GATEWAY_UNSUPPORTED_PARAMS_ACTION, else reject
+func unsupportedParamsAction(cfg *middleware.AccessibleModel) string {
+	if cfg.Metadata.UnsupportedParamsAction != "" {
+		return cfg.Metadata.UnsupportedParamsAction
+	}
+	if a := os.Getenv("GATEWAY_UNSUPPORTED_PARAMS_ACTION"); models.IsValidUnsupportedParamsAction(a) {
+		return a
+	}
+	return models.UnsupportedParamsReject
+}
+
+// applyUnsupportedParams refuses, or strips, the request parameters the model's metadata lists
+// as unsupported by its provider, instead of letting the provider answer with a confusing 400.
+// It returns false after writing a 400 response.
+func applyUnsupportedParams(c *gin.Context, cfg *middleware.AccessibleModel, bodyBytes []byte) ([]byte, bool) {
+	if len(cfg.Metadata.UnsupportedParams) == 0 {
+		return bodyBytes, true
+	}
+	var body map[string]json.RawMessage
+	if json.Unmarshal(bodyBytes, &body) != nil {
+		return bodyBytes, true
+	}
+	params := cfg.Metadata.UnsupportedParamsIn(body)
+	if len(params) == 0 {
+		return bodyBytes, true
+	}
+
+	action := unsupportedParamsAction(cfg)
+	debugPolicy(c, "unsupported_params", action == models.UnsupportedParamsDrop, action+": "+strings.Join(params, ", "))
+	if action == models.UnsupportedParamsReject {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorUnsupportedParameter,
+			fmt.Sprintf("Model %s does not support the parameters: %s", cfg.ModelID, strings.Join(params, ", ")))
+		return nil, false
+	}
+
+	for _, param := range params {
+		delete(body, param)
+	}
+	stripped, err := json.Marshal(body)
+	if err != nil {
+		return bodyBytes, true
+	}
+	log.Printf("Dropped parameters %v unsupported by model %s", params, cfg.ModelID)
+	c.Set("dropped_params", params)
+	c.Set("request_body", stripped)
+	return stripped, true
+}
+
+// requestNeeds reads what a completion request asks of the model; bodies that aren't JSON ask
+// for nothing
+func requestNeeds(bodyBytes []byte) models.ModelRequestNeeds {
+	var body struct {
+		Tools               []json.RawMessage `json:"tools"`
+		Functions           []json.RawMessage `json:"functions"`
+		MaxTokens           int               `json:"max_tokens"`
+		MaxCompletionTokens int               `json:"max_completion_tokens"`
+		Messages            []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	var needs models.ModelRequestNeeds
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return needs
+	}
+
+	needs.Tools = len(body.Tools) > 0 || len(body.Functions) > 0
+	needs.MaxTokens = body.MaxTokens
+	if body.MaxCompletionTokens > needs.MaxTokens {
+		needs.MaxTokens = body.MaxCompletionTokens
+	}
+	for _, message := range body.Messages {
+		var parts []struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(message.Content, &parts) != nil {
+			continue
+		}
+		for _, part := range parts {
+			if imagePartTypes[part.Type] {
+				needs.Vision = true
+			}
+		}
+	}
+	return needs
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Upstream compression policies, chosen with GATEWAY_UPSTREAM_COMPRESSION. The client's own
+// Accept-Encoding is never forwarded: the gateway reads provider bodies for usage and schema
+// checks, so it always handles the encoding itself and answers the client uncompressed.
+const (
+	// compressionGzip leaves Accept-Encoding unset so Go's transport asks for gzip and inflates
+	// the response transparently, saving bandwidth on the provider hop
+	compressionGzip = "gzip"
+
+	// compressionIdentity asks providers not to compress at all
+	compressionIdentity = "identity"
+)
+
+func upstreamCompression() string {
+	if os.Getenv("GATEWAY_UPSTREAM_COMPRESSION") == compressionIdentity {
+		return compressionIdentity
+	}
+	return compressionGzip
+}
+
+// setUpstreamAcceptEncoding applies the compression policy to an outgoing provider request
+func setUpstreamAcceptEncoding(req *http.Request) {
+	req.Header.Del("Accept-Encoding")
+	if upstreamCompression() == compressionIdentity {
+		req.Header.Set("Accept-Encoding", compressionIdentity)
+	}
+}
+
+// decodeResponse replaces a provider body sent with a Content-Encoding the transport didn't
+// already undo, so everything downstream reads plain bytes. Unknown encodings pass through
+// untouched.
+func decodeResponse(resp *http.Response) *http.Response {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == compressionIdentity {
+		return resp
+	}
+
+	var decoded io.Reader
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			log.Printf("Failed to read gzip response from provider: %v", err)
+			return resp
+		}
+		decoded = reader
+	case "deflate":
+		// Servers disagree on whether deflate means zlib-wrapped or raw; zlib is the standard
+		reader, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			log.Printf("Failed to read deflate response from provider: %v", err)
+			return resp
+		}
+		decoded = reader
+	case "br":
+		decoded = brotli.NewReader(resp.Body)
+	default:
+		log.Printf("Provider response has unsupported Content-Encoding %q, passing it through", encoding)
+		return resp
+	}
+
+	resp.Body = decodedBody{Reader: decoded, closer: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp
+}
+
+// decodedBody reads the decompressed stream and closes the underlying provider body
+type decodedBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b decodedBody) Close() error {
+	return b.closer.Close()
+}
@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+const (
+	// summaryPath is where summary requests are sent on the summary model's provider
+	summaryPath = "/v1/chat/completions"
+
+	// maxSummaryTokens caps a summary; less is asked for when the context window has less room
+	maxSummaryTokens = 512
+
+	// minSummaryTokens is the least room worth writing a summary into
+	minSummaryTokens = 64
+
+	summaryInstructions = "Summarize the following earlier part of a conversation in a few sentences. " +
+		"Keep names, facts, decisions and open questions the rest of the conversation may rely on."
+)
+
+// contextStrategy returns how to handle conversations too long for the model: its own
+// strategy, else GATEWAY_CONTEXT_STRATEGY, else reject
+func contextStrategy(cfg *middleware.AccessibleModel) string {
+	if cfg.Metadata.ContextStrategy != "" {
+		return cfg.Metadata.ContextStrategy
+	}
+	if s := os.Getenv("GATEWAY_CONTEXT_STRATEGY"); models.IsValidContextStrategy(s) {
+		return s
+	}
+	return models.ContextStrategyReject
+}
+
+// applyContextWindow fits a chat completion into the model's context window, leaving room for
+// max_tokens, by rejecting it or truncating or summarizing its oldest messages according to the
+// model's context strategy. What was done is recorded in the usage metadata. It returns the body
+// to send, or false after writing a 400 response.
+func applyContextWindow(c *gin.Context, cfg *middleware.AccessibleModel, bodyBytes []byte) ([]byte, bool) {
+	window := cfg.Metadata.ContextWindow
+	if window == nil || !strings.HasSuffix(c.Request.URL.Path, "/chat/completions") {
+		return bodyBytes, true
+	}
+
+	budget := *window - requestNeeds(bodyBytes).MaxTokens
+	promptTokens, err := usage.PromptTokens(cfg.ModelID, bodyBytes)
+	if err != nil || promptTokens <= budget {
+		return bodyBytes, true
+	}
+
+	strategy := contextStrategy(cfg)
+	if strategy == models.ContextStrategyReject {
+		rejectContextLength(c, cfg, promptTokens, budget)
+		return nil, false
+	}
+
+	var body map[string]json.RawMessage
+	var messages []json.RawMessage
+	if json.Unmarshal(bodyBytes, &body) != nil || json.Unmarshal(body["messages"], &messages) != nil {
+		return bodyBytes, true
+	}
+
+	fit, ok := usage.FitMessages(cfg.ModelID, messages, budget)
+	if !ok {
+		rejectContextLength(c, cfg, promptTokens, budget)
+		return nil, false
+	}
+
+	record := map[string]interface{}{
+		"strategy":       strategy,
+		"action":         "truncated",
+		"context_window": *window,
+		"prompt_tokens":  promptTokens,
+	}
+	dropped := len(fit.Dropped)
+	if strategy == models.ContextStrategySummarize {
+		summarized, err := summarizeDropped(c, cfg, fit, budget)
+		if err != nil {
+			log.Printf("Truncating instead of summarizing for model %s: %v", cfg.ModelID, err)
+			record["summary_error"] = err.Error()
+		} else {
+			fit = summarized
+			record["action"] = "summarized"
+			dropped += len(fit.Dropped)
+		}
+	}
+	record["messages_dropped"] = dropped
+	record["prompt_tokens_sent"] = fit.PromptTokens
+
+	if body["messages"], err = json.Marshal(fit.Messages); err != nil {
+		return bodyBytes, true
+	}
+	fitted, err := json.Marshal(body)
+	if err != nil {
+		return bodyBytes, true
+	}
+
+	log.Printf("Context window: %s %d messages of a %d token prompt for model %s", record["action"], dropped, promptTokens, cfg.ModelID)
+	c.Set("context_window", record)
+	c.Set("request_body", fitted)
+	return fitted, true
+}
+
+// summarizeDropped asks the summary model to summarize the messages fit dropped and puts the
+// summary after the system messages, dropping more if the summary doesn't fit. The returned
+// fit's Dropped holds only what was dropped to make room for the summary.
+func summarizeDropped(c *gin.Context, cfg *middleware.AccessibleModel, fit usage.ContextFit, budget int) (usage.ContextFit, error) {
+	room := min(budget-fit.PromptTokens, maxSummaryTokens)
+	if room < minSummaryTokens {
+		return fit, errors.New("no room in the context window for a summary")
+	}
+
+	summaryModelID := cfg.Metadata.SummaryModel
+	if summaryModelID == "" {
+		summaryModelID = os.Getenv("GATEWAY_CONTEXT_SUMMARY_MODEL")
+	}
+	if summaryModelID == "" {
+		return fit, errors.New("no summary model configured")
+	}
+	summaryCfg := accessibleModel(c, summaryModelID)
+	if summaryCfg == nil {
+		return fit, fmt.Errorf("summary model %s is not available to the organization", summaryModelID)
+	}
+
+	summary, err := requestSummary(c, summaryCfg, usage.ConversationText(fit.Dropped), room)
+	if err != nil {
+		return fit, err
+	}
+
+	message, err := json.Marshal(map[string]string{
+		"role":    "system",
+		"content": "Summary of the earlier conversation: " + summary,
+	})
+	if err != nil {
+		return fit, err
+	}
+	messages := make([]json.RawMessage, 0, len(fit.Messages)+1)
+	messages = append(messages, fit.Messages[:fit.Instructions]...)
+	messages = append(messages, message)
+	messages = append(messages, fit.Messages[fit.Instructions:]...)
+
+	summarized, ok := usage.FitMessages(cfg.ModelID, messages, budget)
+	if !ok {
+		return fit, errors.New("the summary doesn't fit in the context window")
+	}
+	return summarized, nil
+}
+
+// requestSummary has the summary model summarize conversation in at most maxTokens tokens and
+// records the call's usage against the request
+func requestSummary(c *gin.Context, summaryCfg *middleware.AccessibleModel, conversation string, maxTokens int) (string, error) {
+	request, err := json.Marshal(map[string]interface{}{
+		"model": summaryCfg.ModelID,
+		"messages": []map[string]string{
+			{"role": "system", "content": summaryInstructions},
+			{"role": "user", "content": conversation},
+		},
+		"max_tokens": maxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	resp, err := SendToModel(summaryCfg, summaryPath, request, c.GetString("request_id"))
+	if err != nil {
+		return "", fmt.Errorf("summary request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read summary: %w", err)
+	}
+
+	orgID, apiKeyID, provider, requestID := usageRequestInfo(summaryCfg, c)
+	responseTimeMS := int(time.Since(start).Milliseconds())
+	usage.TrackUsage(orgID, apiKeyID, summaryCfg.ID, provider, summaryPath, requestID, resp.StatusCode,
+		&responseTimeMS, responseBody, map[string]interface{}{"context_summary": true})
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("summary model returned HTTP %d", resp.StatusCode)
+	}
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(responseBody, &completion); err != nil || len(completion.Choices) == 0 {
+		return "", errors.New("summary model returned no completion")
+	}
+	summary := strings.TrimSpace(completion.Choices[0].Message.Content)
+	if summary == "" {
+		return "", errors.New("summary model returned an empty summary")
+	}
+	return summary, nil
+}
+
+// accessibleModel returns the organization's model with the given model ID, or nil
+func accessibleModel(c *gin.Context, modelID string) *middleware.AccessibleModel {
+	value, _ := c.Get("accessible_models")
+	accessibleModels, _ := value.([]middleware.AccessibleModel)
+	for i := range accessibleModels {
+		if accessibleModels[i].ModelID == modelID {
+			return &accessibleModels[i]
+		}
+	}
+	return nil
+}
+
+func rejectContextLength(c *gin.Context, cfg *middleware.AccessibleModel, promptTokens, budget int) {
+	middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorContextLengthExceeded,
+		fmt.Sprintf("The conversation is %d tokens, but model %s has room for %d tokens of prompt after max_tokens",
+			promptTokens, cfg.ModelID, budget))
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+// checkRequestCost refuses a chat or legacy completion whose worst-case cost, its prompt plus
+// max_tokens at the model's prices, could exceed the organization's per-request ceiling. Other
+// requests, and organizations without a ceiling, pass. It returns false after writing a 400
+// response.
+func checkRequestCost(c *gin.Context, cfg *middleware.AccessibleModel, bodyBytes []byte) bool {
+	database, exists := c.Get("db")
+	if !exists {
+		return true
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return true
+	}
+	orgID := c.GetString("organization_id")
+	if orgID == "" {
+		return true
+	}
+
+	ceiling, err := db.GetOrganizationMaxRequestCost(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to load max request cost for organization %s: %v", orgID, err)
+		return true
+	}
+	if ceiling == nil {
+		return true
+	}
+
+	promptTokens, err := usage.PromptTokens(cfg.ModelID, bodyBytes)
+	if err != nil {
+		return true
+	}
+	completionTokens, bounded := models.WorstCaseCompletionTokens(requestNeeds(bodyBytes).MaxTokens, promptTokens, cfg.Metadata.ContextWindow)
+	if !bounded {
+		debugPolicy(c, "max_request_cost", false, "unbounded: no max_tokens or context window")
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorRequestCostExceeded,
+			fmt.Sprintf("Your organization limits each request to $%.4f; set max_tokens so the cost of a request to model %s can be bounded",
+				*ceiling, cfg.ModelID))
+		return false
+	}
+
+	cost, err := usage.NewDatabaseCostCalculator(sqlDB, cfg.Provider).CalculateCost(&models.AIProviderUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}, cfg.ID)
+	if err != nil {
+		log.Printf("Failed to estimate request cost for model %s: %v", cfg.ModelID, err)
+		return true
+	}
+
+	reason := fmt.Sprintf("worst case $%.6f of $%.6f", cost, *ceiling)
+	if cost <= *ceiling {
+		debugPolicy(c, "max_request_cost", true, reason)
+		return true
+	}
+	debugPolicy(c, "max_request_cost", false, reason)
+	log.Printf("Rejected request for organization %s: %s", orgID, reason)
+	middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorRequestCostExceeded,
+		fmt.Sprintf("This request could cost up to $%.4f (%d prompt tokens plus %d completion tokens on model %s), over your organization's limit of $%.4f per request. Lower max_tokens or shorten the prompt.",
+			cost, promptTokens, completionTokens, cfg.ModelID, *ceiling))
+	return false
+}
@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// DebugHeader asks the gateway to trace a request; only keys with the debug scope may send it
+const DebugHeader = "X-RelAI-Debug"
+
+// DebugTraceHeader answers a traced request with where its trace can be looked up
+const DebugTraceHeader = "X-RelAI-Debug-Trace"
+
+const (
+	// debugTraceTTL is how long a finished trace can be looked up
+	debugTraceTTL = 15 * time.Minute
+
+	// maxDebugTraces bounds the traces kept, dropping the oldest first
+	maxDebugTraces = 1000
+)
+
+type debugTraceKey struct{}
+
+// storedTrace is a finished trace and the organization allowed to look it up
+type storedTrace struct {
+	organizationID string
+	trace          *models.DebugTrace
+	expires        time.Time
+}
+
+// DebugTraceStore shares finished traces across gateway replicas, so a trace can be looked up
+// on any replica
+type DebugTraceStore interface {
+	// Save keeps a finished trace for debugTraceTTL
+	Save(organizationID string, trace *models.DebugTrace)
+	// Load returns a trace the organization sent, reporting false when there is none
+	Load(ctx context.Context, requestID, organizationID string) (*models.DebugTrace, bool)
+}
+
+// debugTraceStore is nil while traces are only kept per process
+var debugTraceStore DebugTraceStore
+
+// SetDebugTraceStore shares traces through s; call before serving
+func SetDebugTraceStore(s DebugTraceStore) {
+	debugTraceStore = s
+}
+
+// debugTraces holds this process's finished traces by gateway request ID, in the order they
+// were stored, which is also the order they expire in
+var debugTraces = struct {
+	sync.Mutex
+	traces map[string]storedTrace
+	order  []string
+}{traces: map[string]storedTrace{}}
+
+// startDebugTrace starts tracing the request when it is sent with X-RelAI-Debug. It returns
+// false after refusing the header from a key without the debug scope.
+func startDebugTrace(c *gin.Context) bool {
+	switch strings.ToLower(c.GetHeader(DebugHeader)) {
+	case "1", "true":
+	default:
+		return true
+	}
+
+	scopes, _ := c.Get("api_key_scopes")
+	scopeList, _ := scopes.([]string)
+	if !models.APIKeyAllows(scopeList, models.APIKeyScopeDebug) {
+		middleware.AbortWithError(c, http.StatusForbidden, models.ErrorScopeRequired,
+			"API key is not allowed to debug requests (scope debug required)")
+		return false
+	}
+
+	requestID := c.GetString("request_id")
+	if requestID == "" {
+		return true
+	}
+	trace := &models.DebugTrace{
+		RequestID: requestID,
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		StartedAt: time.Now(),
+	}
+	// The upstream call's context derives from the request's, so retries can find the trace too
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), debugTraceKey{}, trace))
+	c.Header(DebugTraceHeader, models.DebugTracePathPrefix+requestID)
+	trace.Stage("prepare", trace.StartedAt)
+	return true
+}
+
+// debugTraceFrom returns the trace of the request ctx belongs to, or nil when it isn't traced
+func debugTraceFrom(ctx context.Context) *models.DebugTrace {
+	trace, _ := ctx.Value(debugTraceKey{}).(*models.DebugTrace)
+	return trace
+}
+
+// debugStage starts the named stage of a traced request
+func debugStage(c *gin.Context, name string) {
+	if trace := debugTraceFrom(c.Request.Context()); trace != nil {
+		trace.Stage(name, time.Now())
+	}
+}
+
+// debugPolicy records a policy's decision on a traced request
+func debugPolicy(c *gin.Context, policy string, allowed bool, reason string) {
+	if trace := debugTraceFrom(c.Request.Context()); trace != nil {
+		trace.Policy(policy, allowed, reason)
+	}
+}
+
+// debugModel records the model a traced request resolved to and where it is sent
+func debugModel(c *gin.Context, cfg *middleware.AccessibleModel, req *http.Request) {
+	trace := debugTraceFrom(c.Request.Context())
+	if trace == nil {
+		return
+	}
+	trace.Model = &models.DebugModel{
+		ID:       cfg.ID,
+		ModelID:  cfg.ModelID,
+		Name:     cfg.Name,
+		Provider: cfg.Provider,
+		Upstream: req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+	}
+}
+
+// debugAttempt records a call to the provider made for a traced request
+func debugAttempt(ctx context.Context, resp *http.Response, err error, duration time.Duration) {
+	trace := debugTraceFrom(ctx)
+	if trace == nil {
+		return
+	}
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	trace.Attempt(status, err, duration)
+}
+
+// finishDebugTrace completes a traced request's trace and keeps it for lookup
+func finishDebugTrace(c *gin.Context) {
+	trace := debugTraceFrom(c.Request.Context())
+	if trace == nil {
+		return
+	}
+	if annotations := usageMetadataFromContext(c); len(annotations) > 0 {
+		trace.Annotations = annotations
+	}
+	now := time.Now()
+	trace.Finish(c.Writer.Status(), now)
+	organizationID := c.GetString("organization_id")
+	if debugTraceStore != nil {
+		debugTraceStore.Save(organizationID, trace)
+	}
+
+	debugTraces.Lock()
+	defer debugTraces.Unlock()
+	for len(debugTraces.order) > 0 {
+		oldest := debugTraces.order[0]
+		if len(debugTraces.order) < maxDebugTraces && debugTraces.traces[oldest].expires.After(now) {
+			break
+		}
+		delete(debugTraces.traces, oldest)
+		debugTraces.order = debugTraces.order[1:]
+	}
+	debugTraces.traces[trace.RequestID] = storedTrace{
+		organizationID: organizationID,
+		trace:          trace,
+		expires:        now.Add(debugTraceTTL),
+	}
+	debugTraces.order = append(debugTraces.order, trace.RequestID)
+}
+
+// DebugTrace returns the trace of a request the organization sent with X-RelAI-Debug, from this
+// process or, when a DebugTraceStore is set, whichever replica handled it. It reports false when
+// there is no such trace, or it has expired.
+func DebugTrace(ctx context.Context, requestID, organizationID string) (*models.DebugTrace, bool) {
+	debugTraces.Lock()
+	stored, ok := debugTraces.traces[requestID]
+	debugTraces.Unlock()
+	if ok && stored.organizationID == organizationID && stored.expires.After(time.Now()) {
+		return stored.trace, true
+	}
+	if debugTraceStore == nil {
+		return nil, false
+	}
+	return debugTraceStore.Load(ctx, requestID, organizationID)
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// storedDebugTrace is a trace as kept in Redis, with the organization allowed to look it up
+type storedDebugTrace struct {
+	OrganizationID string             `json:"organization_id"`
+	Trace          *models.DebugTrace `json:"trace"`
+}
+
+// RedisDebugTraceStore keeps finished traces in Redis under their request ID, expiring after
+// debugTraceTTL
+type RedisDebugTraceStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisDebugTraceStore returns a store keeping traces under prefix in client
+func NewRedisDebugTraceStore(client *redis.Client, prefix string) *RedisDebugTraceStore {
+	return &RedisDebugTraceStore{client: client, prefix: prefix}
+}
+
+func (s *RedisDebugTraceStore) key(requestID string) string {
+	return s.prefix + "debug:" + requestID
+}
+
+// Save stores the trace in the background, so the request doesn't wait on Redis
+func (s *RedisDebugTraceStore) Save(organizationID string, trace *models.DebugTrace) {
+	payload, err := json.Marshal(storedDebugTrace{OrganizationID: organizationID, Trace: trace})
+	if err != nil {
+		log.Printf("Failed to encode debug trace %s: %v", trace.RequestID, err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), inflightRedisTimeout)
+		defer cancel()
+		if err := s.client.Set(ctx, s.key(trace.RequestID), payload, debugTraceTTL).Err(); err != nil {
+			log.Printf("Failed to share debug trace %s through Redis: %v", trace.RequestID, err)
+		}
+	}()
+}
+
+// Load returns the trace when the organization sent the request. It reports false when there is
+// no such trace, or Redis can't be reached.
+func (s *RedisDebugTraceStore) Load(ctx context.Context, requestID, organizationID string) (*models.DebugTrace, bool) {
+	payload, err := s.client.Get(ctx, s.key(requestID)).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Failed to look up debug trace %s in Redis: %v", requestID, err)
+		}
+		return nil, false
+	}
+	var stored storedDebugTrace
+	if err := json.Unmarshal(payload, &stored); err != nil {
+		log.Printf("Ignoring malformed debug trace %s: %v", requestID, err)
+		return nil, false
+	}
+	if stored.OrganizationID != organizationID || stored.Trace == nil {
+		return nil, false
+	}
+	return stored.Trace, true
+}
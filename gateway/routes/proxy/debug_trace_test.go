@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDebugTraceStore stands in for the other replicas: it holds the traces they saved
+type fakeDebugTraceStore struct {
+	traces map[string]storedDebugTrace
+}
+
+func (s *fakeDebugTraceStore) Save(organizationID string, trace *models.DebugTrace) {
+	s.traces[trace.RequestID] = storedDebugTrace{OrganizationID: organizationID, Trace: trace}
+}
+
+func (s *fakeDebugTraceStore) Load(_ context.Context, requestID, organizationID string) (*models.DebugTrace, bool) {
+	stored, ok := s.traces[requestID]
+	if !ok || stored.OrganizationID != organizationID {
+		return nil, false
+	}
+	return stored.Trace, true
+}
+
+func TestDebugTraceReachesOtherReplicas(t *testing.T) {
+	store := &fakeDebugTraceStore{traces: map[string]storedDebugTrace{
+		"remote": {OrganizationID: "org", Trace: &models.DebugTrace{RequestID: "remote"}},
+	}}
+	SetDebugTraceStore(store)
+	defer SetDebugTraceStore(nil)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	trace := &models.DebugTrace{RequestID: "local"}
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), debugTraceKey{}, trace))
+	c.Set("organization_id", "org")
+	finishDebugTrace(c)
+
+	// A finished trace is kept here and handed to the store for the other replicas
+	got, ok := DebugTrace(context.Background(), "local", "org")
+	assert.True(t, ok)
+	assert.Same(t, trace, got)
+	assert.Contains(t, store.traces, "local")
+
+	// Traces of other replicas come from the store, for their organization only
+	got, ok = DebugTrace(context.Background(), "remote", "org")
+	assert.True(t, ok)
+	assert.Equal(t, "remote", got.RequestID)
+	_, ok = DebugTrace(context.Background(), "remote", "other org")
+	assert.False(t, ok)
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+// embeddingsPath is where texts are embedded on the embedding model's provider
+const embeddingsPath = "/v1/embeddings"
+
+// Embed embeds texts with the embedding model for a gateway feature, such as the semantic cache
+// or vector collections, and records the call's usage against the request with metadata. The
+// embeddings are returned in the order of texts.
+func Embed(c *gin.Context, embeddingCfg *middleware.AccessibleModel, texts []string, metadata map[string]interface{}) ([][]float32, error) {
+	request, err := json.Marshal(map[string]interface{}{
+		"model": embeddingCfg.ModelID,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := SendToModel(embeddingCfg, embeddingsPath, request, c.GetString("request_id"))
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings: %w", err)
+	}
+
+	orgID, apiKeyID, provider, requestID := usageRequestInfo(embeddingCfg, c)
+	responseTimeMS := int(time.Since(start).Milliseconds())
+	usage.TrackUsage(orgID, apiKeyID, embeddingCfg.ID, provider, embeddingsPath, requestID, resp.StatusCode,
+		&responseTimeMS, responseBody, metadata)
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("embedding model returned HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, fmt.Errorf("embedding model returned an unreadable response: %w", err)
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index >= 0 && item.Index < len(embeddings) {
+			embeddings[item.Index] = item.Embedding
+		}
+	}
+	for i, embedding := range embeddings {
+		if len(embedding) == 0 {
+			return nil, fmt.Errorf("embedding model returned no embedding for input %d", i)
+		}
+	}
+	return embeddings, nil
+}
+
+// AccessibleModel returns the organization's model with the given model ID, or nil
+func AccessibleModel(c *gin.Context, modelID string) *middleware.AccessibleModel {
+	return accessibleModel(c, modelID)
+}
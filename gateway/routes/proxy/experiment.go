@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math/rand"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// applyExperiment checks for a running experiment on the request path and, if one exists,
+// assigns the request to a variant. The body is rewritten with the variant's model and
+// system prompt, and the assignment is stored in the context for usage logging.
+func applyExperiment(c *gin.Context, bodyBytes []byte) []byte {
+	database, exists := c.Get("db")
+	if !exists {
+		return bodyBytes
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return bodyBytes
+	}
+
+	orgID, _ := c.Get("organization_id")
+	orgIDStr, ok := orgID.(string)
+	if !ok || orgIDStr == "" {
+		return bodyBytes
+	}
+
+	experiment, err := db.GetRunningExperimentForEndpoint(sqlDB, orgIDStr, c.Request.URL.Path)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to look up experiment for %s: %v", c.Request.URL.Path, err)
+		}
+		return bodyBytes
+	}
+
+	variant := pickVariant(experiment.Variants, rand.Intn(100))
+	if variant == nil {
+		return bodyBytes
+	}
+
+	rewritten, err := rewriteBodyForVariant(bodyBytes, variant)
+	if err != nil {
+		log.Printf("Failed to apply experiment variant %s: %v", variant.Name, err)
+		return bodyBytes
+	}
+
+	c.Set("experiment_id", experiment.ID)
+	c.Set("experiment_variant_id", variant.ID)
+	c.Header("X-Experiment-Variant", variant.Name)
+	log.Printf("Experiment %s: request assigned to variant %s (model: %s)", experiment.Name, variant.Name, variant.ModelID)
+
+	return rewritten
+}
+
+// pickVariant selects the variant whose cumulative traffic bucket contains roll (0-99)
+func pickVariant(variants []models.ExperimentVariant, roll int) *models.ExperimentVariant {
+	cumulative := 0
+	for i := range variants {
+		cumulative += variants[i].TrafficPercent
+		if roll < cumulative {
+			return &variants[i]
+		}
+	}
+	return nil
+}
+
+// rewriteBodyForVariant swaps in the variant's model and system prompt
+func rewriteBodyForVariant(bodyBytes []byte, variant *models.ExperimentVariant) ([]byte, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return nil, err
+	}
+
+	body["model"] = variant.ModelID
+
+	if variant.SystemPrompt != nil && *variant.SystemPrompt != "" {
+		if messages, ok := body["messages"].([]interface{}); ok {
+			replaced := false
+			for _, m := range messages {
+				if msg, ok := m.(map[string]interface{}); ok && msg["role"] == "system" {
+					msg["content"] = *variant.SystemPrompt
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				systemMessage := map[string]interface{}{"role": "system", "content": *variant.SystemPrompt}
+				body["messages"] = append([]interface{}{systemMessage}, messages...)
+			}
+		}
+	}
+
+	return json.Marshal(body)
+}
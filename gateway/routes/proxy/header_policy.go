@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+)
+
+// hopByHopHeaders only describe the provider connection and must not be forwarded (RFC 9110)
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// providerHeaderPrefixes identify the provider and its infrastructure; they are dropped when
+// GATEWAY_STRIP_PROVIDER_HEADERS is true
+var providerHeaderPrefixes = []string{
+	"Openai-",
+	"Anthropic-",
+	"Azureml-",
+	"Apim-",
+	"X-Ms-",
+	"X-Amzn-",
+	"X-Amz-",
+	"X-Goog-",
+	"X-Envoy-",
+	"Cf-",
+	"Server",
+	"Via",
+	"Alt-Svc",
+}
+
+// Headers the gateway adds to every proxied response, besides X-RelAI-Request-Id
+const (
+	headerModel        = "X-Gateway-Model"
+	headerOrganization = "X-Gateway-Organization"
+)
+
+func stripProviderHeaders() bool {
+	return os.Getenv("GATEWAY_STRIP_PROVIDER_HEADERS") == "true"
+}
+
+// copyResponseHeaders copies the provider's response headers to the client, minus hop-by-hop
+// headers, cookies and, optionally, provider identifiers. The gateway's own rate limit headers
+// win over the provider's, which describe the shared upstream account rather than this key.
+func copyResponseHeaders(c *gin.Context, header http.Header) {
+	skip := make(map[string]bool, len(hopByHopHeaders)+1)
+	for _, name := range hopByHopHeaders {
+		skip[name] = true
+	}
+	skip["Set-Cookie"] = true
+	// Connection may name further hop-by-hop headers
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				skip[textproto.CanonicalMIMEHeaderKey(name)] = true
+			}
+		}
+	}
+
+	stripProvider := stripProviderHeaders()
+	for name, values := range header {
+		if skip[name] || (stripProvider && isProviderHeader(name)) {
+			continue
+		}
+		if strings.HasPrefix(name, "X-Ratelimit-") && c.Writer.Header().Get(name) != "" {
+			continue
+		}
+		for _, v := range values {
+			c.Writer.Header().Add(name, v)
+		}
+	}
+}
+
+func isProviderHeader(name string) bool {
+	for _, prefix := range providerHeaderPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setGatewayHeaders tells the client which model served the request and for which organization
+func setGatewayHeaders(c *gin.Context, cfg *middleware.AccessibleModel) {
+	if cfg != nil && cfg.ModelID != "" {
+		c.Header(headerModel, cfg.ModelID)
+	}
+	if orgID, ok := c.Get("organization_id"); ok {
+		if orgIDStr, ok := orgID.(string); ok && orgIDStr != "" {
+			c.Header(headerOrganization, orgIDStr)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/hooks"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// hookRequest describes the proxied request to the deployment's hooks. The header is the one
+// going upstream, less the provider's token.
+func hookRequest(c *gin.Context, cfg *middleware.AccessibleModel, req *http.Request, bodyBytes []byte) *hooks.Request {
+	header := req.Header.Clone()
+	header.Del("Authorization")
+	return &hooks.Request{
+		RequestID:      c.GetString("request_id"),
+		OrganizationID: c.GetString("organization_id"),
+		APIKeyID:       c.GetString("api_key_id"),
+		Model:          cfg.ModelID,
+		Method:         req.Method,
+		Path:           c.Request.URL.Path,
+		Header:         header,
+		Body:           bodyBytes,
+	}
+}
+
+// applyRequestHooks runs the request hooks and applies their changes to the upstream request.
+// It returns the body to send, or false when a hook rejected the request and it was answered.
+func applyRequestHooks(c *gin.Context, cfg *middleware.AccessibleModel, req *http.Request, bodyBytes []byte) ([]byte, bool) {
+	if len(hooks.Registered()) == 0 {
+		return bodyBytes, true
+	}
+
+	hr := hookRequest(c, cfg, req, bodyBytes)
+	if err := hooks.RunRequest(c.Request.Context(), hr); err != nil {
+		debugPolicy(c, "request_hooks", false, err.Error())
+		rejectByHook(c, err)
+		return nil, false
+	}
+	debugPolicy(c, "request_hooks", true, "")
+
+	// The provider's token always wins over anything a hook set
+	token := req.Header.Get("Authorization")
+	req.Header = hr.Header
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	if string(hr.Body) != string(bodyBytes) {
+		c.Set("request_body", hr.Body)
+		req.ContentLength = int64(len(hr.Body))
+	}
+	c.Set("hook_request", hr)
+	return hr.Body, true
+}
+
+// applyResponseHooks runs the response hooks on a provider response before it is relayed,
+// editing the downstream headers in place. It returns the status and body to send, or false when
+// a hook rejected the response and it was answered. Streamed responses pass a nil body.
+func applyResponseHooks(c *gin.Context, status int, body []byte, streaming bool) (int, []byte, bool) {
+	if !hooks.HasResponseHooks() {
+		return status, body, true
+	}
+
+	hr, _ := c.Get("hook_request")
+	request, _ := hr.(*hooks.Request)
+	if request == nil {
+		request = &hooks.Request{RequestID: c.GetString("request_id"), Path: c.Request.URL.Path}
+	}
+	resp := &hooks.Response{
+		Request:    request,
+		StatusCode: status,
+		Header:     c.Writer.Header(),
+		Body:       body,
+		Streaming:  streaming,
+	}
+	if err := hooks.RunResponse(c.Request.Context(), resp); err != nil {
+		debugPolicy(c, "response_hooks", false, err.Error())
+		rejectByHook(c, err)
+		return 0, nil, false
+	}
+	debugPolicy(c, "response_hooks", true, "")
+
+	if !streaming && string(resp.Body) != string(body) {
+		c.Writer.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	}
+	return resp.StatusCode, resp.Body, true
+}
+
+// rejectByHook answers with a hook's rejection
+func rejectByHook(c *gin.Context, err error) {
+	rejection := &hooks.Rejection{Status: http.StatusForbidden, Message: err.Error()}
+	errors.As(err, &rejection)
+	log.Printf("Request %s rejected by hook: %d %s", c.GetString("request_id"), rejection.Status, rejection.Message)
+	// Drop the provider's framing of the body this replaces
+	c.Writer.Header().Del("Content-Length")
+	c.Writer.Header().Del("Content-Type")
+	middleware.AbortWithError(c, rejection.Status, models.ErrorPolicyRejected, rejection.Message)
+}
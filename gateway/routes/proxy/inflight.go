@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errRequestStopped is the cause of an upstream call cancelled through StopRequest
+var errRequestStopped = errors.New("request stopped by client")
+
+// statusRequestStopped answers a stopped request that had no response yet (nginx's "client
+// closed request")
+const statusRequestStopped = 499
+
+// inflightRequest is an upstream call in progress, with the API key allowed to stop it
+type inflightRequest struct {
+	organizationID string
+	apiKeyID       string
+	cancel         context.CancelCauseFunc
+}
+
+// InflightStore shares in-flight requests across gateway replicas, so a stop reaches whichever
+// replica is running the request
+type InflightStore interface {
+	// Track records a request running in this process until done is closed
+	Track(requestID, organizationID, apiKeyID string, done <-chan struct{})
+	// Stop has the replica running the request stop it, reporting false when none is running
+	// it for the API key
+	Stop(ctx context.Context, requestID, organizationID, apiKeyID string) bool
+}
+
+// inflightStore is nil while requests are only tracked per process
+var inflightStore InflightStore
+
+// SetInflightStore shares in-flight requests through s; call before serving
+func SetInflightStore(s InflightStore) {
+	inflightStore = s
+}
+
+// inflight holds this process's upstream calls by gateway request ID
+var inflight = struct {
+	sync.Mutex
+	requests map[string]inflightRequest
+}{requests: map[string]inflightRequest{}}
+
+// trackInflight derives the upstream call's context from the client request and registers it
+// under the request ID so StopRequest can cancel it. done must be called once the response has
+// been relayed.
+func trackInflight(c *gin.Context) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancelCause(c.Request.Context())
+	requestID := c.GetString("request_id")
+	if requestID == "" {
+		return ctx, func() { cancel(nil) }
+	}
+
+	inflight.Lock()
+	inflight.requests[requestID] = inflightRequest{
+		organizationID: c.GetString("organization_id"),
+		apiKeyID:       c.GetString("api_key_id"),
+		cancel:         cancel,
+	}
+	inflight.Unlock()
+
+	finished := make(chan struct{})
+	if inflightStore != nil {
+		inflightStore.Track(requestID, c.GetString("organization_id"), c.GetString("api_key_id"), finished)
+	}
+
+	return ctx, func() {
+		inflight.Lock()
+		delete(inflight.requests, requestID)
+		inflight.Unlock()
+		close(finished)
+		cancel(nil)
+	}
+}
+
+// StopRequest cancels an in-flight upstream call made with the same API key, on whichever
+// replica runs it when an InflightStore is set. It reports false when no such call is running.
+func StopRequest(ctx context.Context, requestID, organizationID, apiKeyID string) bool {
+	if stopLocalRequest(requestID, organizationID, apiKeyID) {
+		return true
+	}
+	return inflightStore != nil && inflightStore.Stop(ctx, requestID, organizationID, apiKeyID)
+}
+
+// stopLocalRequest cancels an upstream call running in this process
+func stopLocalRequest(requestID, organizationID, apiKeyID string) bool {
+	inflight.Lock()
+	request, ok := inflight.requests[requestID]
+	inflight.Unlock()
+	if !ok || request.organizationID != organizationID || request.apiKeyID != apiKeyID {
+		return false
+	}
+	request.cancel(errRequestStopped)
+	return true
+}
+
+// requestStopped reports whether the request's upstream call was cancelled through StopRequest
+func requestStopped(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRequestStopped)
+}
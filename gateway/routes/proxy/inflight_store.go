@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// inflightTTL bounds how long a request stays registered if its replica dies mid-request
+	inflightTTL = time.Hour
+	// inflightRedisTimeout bounds registering and removing a request, which run off the request path
+	inflightRedisTimeout = 5 * time.Second
+)
+
+// stopMessage is published to have the replica running a request stop it
+type stopMessage struct {
+	RequestID      string `json:"request_id"`
+	OrganizationID string `json:"organization_id"`
+	APIKeyID       string `json:"api_key_id"`
+}
+
+// RedisInflightStore registers each in-flight request in Redis under its ID, with the key
+// allowed to stop it, and publishes stops for every replica to match against its own requests
+type RedisInflightStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisInflightStore returns a store keeping requests under prefix in client
+func NewRedisInflightStore(client *redis.Client, prefix string) *RedisInflightStore {
+	return &RedisInflightStore{client: client, prefix: prefix}
+}
+
+func (s *RedisInflightStore) key(requestID string) string {
+	return s.prefix + "inflight:" + requestID
+}
+
+func (s *RedisInflightStore) channel() string {
+	return s.prefix + "inflight:stop"
+}
+
+func inflightOwner(organizationID, apiKeyID string) string {
+	return organizationID + " " + apiKeyID
+}
+
+// Track registers the request until done is closed. Both happen in the background, in order,
+// so the request doesn't wait on Redis.
+func (s *RedisInflightStore) Track(requestID, organizationID, apiKeyID string, done <-chan struct{}) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), inflightRedisTimeout)
+		err := s.client.Set(ctx, s.key(requestID), inflightOwner(organizationID, apiKeyID), inflightTTL).Err()
+		cancel()
+		if err != nil {
+			log.Printf("Failed to share in-flight request %s through Redis: %v", requestID, err)
+		}
+
+		<-done
+		ctx, cancel = context.WithTimeout(context.Background(), inflightRedisTimeout)
+		defer cancel()
+		s.client.Del(ctx, s.key(requestID))
+	}()
+}
+
+// Stop publishes a stop for a request registered to the API key. It reports false when no
+// replica has registered the request for that key, or Redis can't be reached.
+func (s *RedisInflightStore) Stop(ctx context.Context, requestID, organizationID, apiKeyID string) bool {
+	owner, err := s.client.Get(ctx, s.key(requestID)).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Failed to look up in-flight request %s in Redis: %v", requestID, err)
+		}
+		return false
+	}
+	if owner != inflightOwner(organizationID, apiKeyID) {
+		return false
+	}
+
+	payload, err := json.Marshal(stopMessage{RequestID: requestID, OrganizationID: organizationID, APIKeyID: apiKeyID})
+	if err != nil {
+		return false
+	}
+	if err := s.client.Publish(ctx, s.channel(), payload).Err(); err != nil {
+		log.Printf("Failed to publish stop of request %s: %v", requestID, err)
+		return false
+	}
+	return true
+}
+
+// Listen stops the requests of this process that other replicas publish stops for. The
+// returned func stops listening.
+func (s *RedisInflightStore) Listen() (stop func()) {
+	sub := s.client.Subscribe(context.Background(), s.channel())
+	go func() {
+		for msg := range sub.Channel() {
+			var stop stopMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &stop); err != nil {
+				log.Printf("Ignoring malformed stop message: %v", err)
+				continue
+			}
+			stopLocalRequest(stop.RequestID, stop.OrganizationID, stop.APIKeyID)
+		}
+	}()
+	return func() { sub.Close() }
+}
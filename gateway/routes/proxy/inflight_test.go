@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeInflightStore stands in for the other replicas: it knows of the requests in remote
+type fakeInflightStore struct {
+	tracked map[string]<-chan struct{}
+	remote  map[string]string // Request ID -> API key ID
+	stopped []string
+}
+
+func (s *fakeInflightStore) Track(requestID, _, _ string, done <-chan struct{}) {
+	s.tracked[requestID] = done
+}
+
+func (s *fakeInflightStore) Stop(_ context.Context, requestID, _, apiKeyID string) bool {
+	if s.remote[requestID] != apiKeyID {
+		return false
+	}
+	s.stopped = append(s.stopped, requestID)
+	return true
+}
+
+func TestStopRequestReachesOtherReplicas(t *testing.T) {
+	store := &fakeInflightStore{tracked: map[string]<-chan struct{}{}, remote: map[string]string{"remote": "key"}}
+	SetInflightStore(store)
+	defer SetInflightStore(nil)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	c.Set("request_id", "local")
+	c.Set("organization_id", "org")
+	c.Set("api_key_id", "key")
+	ctx, done := trackInflight(c)
+
+	// A request of this process is stopped here, without the store
+	assert.True(t, StopRequest(context.Background(), "local", "org", "key"))
+	assert.True(t, requestStopped(ctx))
+	assert.Empty(t, store.stopped)
+
+	// Others are handed to the store, for the replica running them
+	assert.True(t, StopRequest(context.Background(), "remote", "org", "key"))
+	assert.False(t, StopRequest(context.Background(), "remote", "org", "other key"))
+	assert.Equal(t, []string{"remote"}, store.stopped)
+
+	// The store hears when the request finishes
+	finished := store.tracked["local"]
+	done()
+	select {
+	case <-finished:
+	default:
+		t.Error("the store wasn't told the request finished")
+	}
+}
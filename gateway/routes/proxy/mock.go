@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/like-mike/relai-gateway/shared/mockprovider"
+)
+
+// mockBaseURL is the upstream base URL in mock provider mode; requests never leave the process
+const mockBaseURL = "http://mock-provider"
+
+var (
+	mockOnce      sync.Once
+	mockTransport http.RoundTripper
+)
+
+// useMockProvider reports whether USE_DUMMY_BACKEND=mock, which answers every model's requests
+// with the embedded mock provider instead of a real one
+func useMockProvider() bool {
+	return os.Getenv("USE_DUMMY_BACKEND") == "mock"
+}
+
+// mockProviderTransport returns the shared mock provider, loading the scenarios in
+// MOCK_SCENARIO_FILE on first use. A file that fails to load fails every request rather than
+// silently running the default scenario.
+func mockProviderTransport() http.RoundTripper {
+	mockOnce.Do(func() {
+		path := os.Getenv("MOCK_SCENARIO_FILE")
+		if path == "" {
+			mockTransport = mockprovider.NewTransport(nil)
+			return
+		}
+		cfg, err := mockprovider.LoadConfig(path)
+		if err != nil {
+			log.Printf("Failed to load mock scenarios from %s: %v", path, err)
+			mockTransport = failingTransport{fmt.Errorf("load mock scenarios: %w", err)}
+			return
+		}
+		log.Printf("Mock provider loaded %d scenarios from %s", len(cfg.Scenarios), path)
+		mockTransport = mockprovider.NewTransport(cfg)
+	})
+	return mockTransport
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// maxLoggedPayloadBytes caps each stored request and response body
+const maxLoggedPayloadBytes = 256 * 1024
+
+// payloadLoggingEnabled reports whether request and response bodies are kept for replay
+func payloadLoggingEnabled() bool {
+	switch strings.ToLower(os.Getenv("PAYLOAD_LOGGING_ENABLED")) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
+
+// logPayload stores the request and response bodies when payload logging is on. Requests with
+// detected secrets and non-text bodies (audio, images) are skipped, as is everything in read-only
+// mode.
+func logPayload(c *gin.Context, modelID, endpoint string, responseBody []byte, responseTimeMS int) {
+	if !payloadLoggingEnabled() || readonly.Enabled() {
+		return
+	}
+	if _, flagged := c.Get("secret_scan"); flagged {
+		return
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		return
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return
+	}
+
+	orgID, _ := c.Get("organization_id")
+	orgIDStr, ok := orgID.(string)
+	if !ok || orgIDStr == "" {
+		return
+	}
+	requestBody, _ := c.Get("request_body")
+	requestBytes, ok := requestBody.([]byte)
+	if !ok || !utf8.Valid(requestBytes) || !utf8.Valid(responseBody) {
+		return
+	}
+
+	payload := models.RequestPayload{
+		OrganizationID: orgIDStr,
+		Endpoint:       endpoint,
+		ResponseStatus: c.Writer.Status(),
+		ResponseTimeMS: &responseTimeMS,
+	}
+	if modelID != "" {
+		payload.ModelID = &modelID
+	}
+	if keyID, ok := c.Get("api_key_id"); ok {
+		if keyIDStr, ok := keyID.(string); ok && keyIDStr != "" {
+			payload.APIKeyID = &keyIDStr
+		}
+	}
+
+	var truncated bool
+	payload.RequestBody, truncated = truncatePayload(requestBytes)
+	response, responseTruncated := truncatePayload(responseBody)
+	payload.ResponseBody = &response
+	payload.Truncated = truncated || responseTruncated
+
+	go func() {
+		if err := db.CreateRequestPayload(sqlDB, payload); err != nil {
+			log.Printf("Failed to log request payload: %v", err)
+		}
+	}()
+}
+
+// truncatePayload cuts body to maxLoggedPayloadBytes without splitting a UTF-8 character
+func truncatePayload(body []byte) (string, bool) {
+	if len(body) <= maxLoggedPayloadBytes {
+		return string(body), false
+	}
+	cut := maxLoggedPayloadBytes
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return string(body[:cut]), true
+}
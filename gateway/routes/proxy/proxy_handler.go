@@ -7,33 +7,123 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/awsauth"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+	"github.com/like-mike/relai-gateway/shared/gcpauth"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/vertex"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 )
 
-// createHTTPClientForModel creates an HTTP client with model-specific timeout
+// createHTTPClientForModel creates an HTTP client with model-specific timeout, reaching the
+// provider through the model's proxy and TLS options. Bedrock and Vertex AI models get a
+// transport that authenticates each request itself, and self-hosted models one that adapts
+// their OpenAI dialect. In mock provider mode every model is answered
+// in-process by the mock provider.
 func createHTTPClientForModel(cfg *middleware.AccessibleModel) *http.Client {
 	timeout := 30 * time.Second // default timeout
 	if cfg.ModelID != "" && cfg.TimeoutSeconds != nil {
 		timeout = time.Duration(*cfg.TimeoutSeconds) * time.Second
 	}
 
+	transport := providerTransport(cfg.Transport)
+	switch {
+	case useMockProvider():
+		transport = mockProviderTransport()
+	case os.Getenv("USE_DUMMY_BACKEND") != "1":
+		switch cfg.Provider {
+		case models.ProviderBedrock:
+			transport = bedrockTransport(cfg, transport)
+		case models.ProviderVertex:
+			transport = vertexTransport(cfg, transport)
+		case models.ProviderOllama, models.ProviderVLLM:
+			transport = selfHostedTransport{base: transport}
+		}
+	}
+
 	return &http.Client{
-		Timeout: timeout,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 100,
-			IdleConnTimeout:     90 * time.Second,
-			DisableCompression:  false,
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// bedrockTransport signs requests with the model's AWS credentials, or the gateway's AWS_*
+// environment variables when the model has none. Requests are forwarded as-is to the model's
+// endpoint, so it must be one that accepts the client's request format.
+func bedrockTransport(cfg *middleware.AccessibleModel, base http.RoundTripper) http.RoundTripper {
+	secretKey := ""
+	if cfg.AWSSecretKey != "" {
+		decrypted, err := encryption.Decrypt(cfg.AWSSecretKey)
+		if err != nil {
+			// Fall back to environment credentials; signing fails upstream if there are none
+			log.Printf("Failed to decrypt AWS secret key for model %s: %v", cfg.ModelID, err)
+		} else {
+			secretKey = decrypted
+		}
+	}
+
+	return &awsauth.Transport{
+		Base: base,
+		Config: awsauth.Config{
+			Region:          cfg.AWSRegion,
+			AccessKeyID:     cfg.AWSAccessKeyID,
+			SecretAccessKey: secretKey,
+			RoleARN:         cfg.AWSRoleARN,
 		},
+		Service: "bedrock",
 	}
 }
 
+// vertexTransport translates chat completions to the model's Vertex AI publisher endpoint,
+// authenticated with its service account
+func vertexTransport(cfg *middleware.AccessibleModel, base http.RoundTripper) http.RoundTripper {
+	keyJSON, err := encryption.Decrypt(cfg.GCPServiceAccount)
+	if err != nil {
+		return failingTransport{fmt.Errorf("decrypt service account for model %s: %w", cfg.ModelID, err)}
+	}
+	account, err := gcpauth.ParseServiceAccount([]byte(keyJSON))
+	if err != nil {
+		return failingTransport{fmt.Errorf("service account for model %s: %w", cfg.ModelID, err)}
+	}
+
+	return &vertex.Transport{
+		Base:      &gcpauth.Transport{Base: base, Account: account},
+		ProjectID: account.ProjectID,
+		Location:  cfg.GCPLocation,
+		Model:     vertex.ModelName(cfg.ModelID),
+	}
+}
+
+// failingTransport fails every request, for models whose credentials can't be loaded
+type failingTransport struct{ err error }
+
+func (t failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	log.Printf("Provider credentials unavailable: %v", t.err)
+	return nil, t.err
+}
+
+// authenticatesInTransport reports whether the model's client transport adds its own
+// credentials, so the request must not carry the model's bearer token
+func authenticatesInTransport(cfg *middleware.AccessibleModel) bool {
+	return cfg.Provider == models.ProviderBedrock || cfg.Provider == models.ProviderVertex
+}
+
+// modelBaseURL returns the upstream base URL for a model. Vertex AI models default to their
+// location's regional endpoint.
+func modelBaseURL(cfg *middleware.AccessibleModel) string {
+	if cfg.Provider == models.ProviderVertex && cfg.ApiEndpoint == "" && cfg.GCPLocation != "" {
+		return vertex.BaseURL(cfg.GCPLocation)
+	}
+	return cfg.ApiEndpoint
+}
+
 // makeRequestWithRetry executes HTTP request with model-specific retry logic
 func makeRequestWithRetry(client *http.Client, req *http.Request, bodyBytes []byte, cfg *middleware.AccessibleModel) (*http.Response, error) {
 	// Default retry settings
@@ -79,12 +169,14 @@ func makeRequestWithRetry(client *http.Client, req *http.Request, bodyBytes []by
 		// Copy context
 		reqClone = reqClone.WithContext(req.Context())
 
+		attemptStart := time.Now()
 		resp, err := client.Do(reqClone)
+		debugAttempt(req.Context(), resp, err, time.Since(attemptStart))
 		if err == nil {
 			// Check if response indicates success or retryable error
 			if resp.StatusCode < 500 {
 				// Success or client error (don't retry 4xx)
-				return resp, nil
+				return decodeResponse(resp), nil
 			}
 			// Server error (5xx) - close body and retry
 			if lastResp != nil {
@@ -94,6 +186,10 @@ func makeRequestWithRetry(client *http.Client, req *http.Request, bodyBytes []by
 		}
 
 		lastErr = err
+		if err != nil && req.Context().Err() != nil {
+			// Stopped by the client or gone with it; another attempt would be cancelled too
+			return nil, err
+		}
 		log.Printf("Request failed on attempt %d: status=%d, error=%v", attempt+1,
 			func() int {
 				if resp != nil {
@@ -106,7 +202,7 @@ func makeRequestWithRetry(client *http.Client, req *http.Request, bodyBytes []by
 	// All retries exhausted
 	if lastResp != nil {
 		// Return the last response even if it's an error
-		return lastResp, nil
+		return decodeResponse(lastResp), nil
 	}
 
 	return nil, fmt.Errorf("request failed after %d retries: %v", maxRetries+1, lastErr)
@@ -147,10 +243,77 @@ func Handler(c *gin.Context) {
 
 	// cfg = provider.CreateProxyConfigFromModel(model)
 
+	// Trace the request's stages for X-RelAI-Debug, kept for lookup once it is answered
+	if !startDebugTrace(c) {
+		return
+	}
+	defer finishDebugTrace(c)
+
+	// Reject oversized or malformed bodies before anything buffers or parses them
+	if !bufferRequestBody(c) {
+		return
+	}
+
 	// Build proxy request
 	cfg, req, bodyBytes, err := prepareRequest(c, target)
 	if err != nil {
-		c.String(http.StatusInternalServerError, err.Error())
+		rejectRequest(c, err)
+		return
+	}
+	debugModel(c, cfg, req)
+	if !checkModelRequestSize(c, cfg, bodyBytes) {
+		return
+	}
+	if !checkModelCapabilities(c, cfg, bodyBytes) {
+		return
+	}
+	var supported bool
+	if bodyBytes, supported = applyUnsupportedParams(c, cfg, bodyBytes); !supported {
+		return
+	}
+
+	// Ask the deployment's OPA policies whether this key may make this request
+	debugStage(c, "authorize")
+	if !authorize(c, cfg, bodyBytes) {
+		return
+	}
+
+	// Let the deployment's own policy hooks check or rewrite the request
+	debugStage(c, "request_hooks")
+	var allowed bool
+	if bodyBytes, allowed = applyRequestHooks(c, cfg, req, bodyBytes); !allowed {
+		return
+	}
+
+	// Scan the outgoing prompt for credentials (blocks or flags per organization policy)
+	debugStage(c, "secret_scan")
+	if scanRequestForSecrets(c, bodyBytes, cfg.ModelID) {
+		return
+	}
+
+	// Put the session's history in front of the new messages
+	debugStage(c, "session_history")
+	var applied bool
+	if bodyBytes, applied = applySessionHistory(c, cfg, bodyBytes); !applied {
+		return
+	}
+
+	// Fit the conversation into the model's context window
+	debugStage(c, "context_window")
+	var fits bool
+	if bodyBytes, fits = applyContextWindow(c, cfg, bodyBytes); !fits {
+		return
+	}
+
+	// Answer repeated or near-identical prompts from the semantic cache
+	debugStage(c, "semantic_cache")
+	if serveFromSemanticCache(c, cfg, bodyBytes) {
+		return
+	}
+
+	// Refuse requests that could cost more than the organization allows per request
+	debugStage(c, "cost_guard")
+	if !checkRequestCost(c, cfg, bodyBytes) {
 		return
 	}
 
@@ -163,19 +326,31 @@ func Handler(c *gin.Context) {
 
 	recordTracingMetadata(cfg, spanInvoke, spanExec, req, bodyBytes)
 
+	// Let DELETE /v1/requests/:request_id cancel the call until its response is relayed
+	upstreamCtx, done := trackInflight(c)
+	defer done()
+	req = req.WithContext(upstreamCtx)
+
 	// Send request with model-specific retry/timeout
+	debugStage(c, "upstream")
 	start := time.Now()
 
 	client := createHTTPClientForModel(cfg)
 
 	// Execute request with retry logic
 	resp, err := makeRequestWithRetry(client, req, bodyBytes, cfg)
+	if err == nil {
+		// Validate structured output against the endpoint's response schema, if declared
+		debugStage(c, "response_schema")
+		resp = applyResponseSchema(c, client, req, bodyBytes, cfg, resp)
+	}
 
 	duration := time.Since(start).Milliseconds()
 	spanInvoke.SetAttributes(attribute.Int64("llm.request.duration_ms", duration))
 
 	// Build response
-	writeDownstreamResponse(cfg, c, resp, err, tracer, start)
+	debugStage(c, "response")
+	writeDownstreamResponse(cfg, c, upstreamCtx, resp, err, tracer, start)
 }
 
 // CustomEndpoint represents a custom endpoint from the database
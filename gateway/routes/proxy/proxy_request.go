@@ -2,32 +2,61 @@ package proxy
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/models"
 	"github.com/like-mike/relai-gateway/shared/usage"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// requestError is an error in the client's request, answered with its catalog code rather than
+// as an internal error
+type requestError struct {
+	status int
+	code   models.ErrorCode
+	err    error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+
+func (e *requestError) Unwrap() error { return e.err }
+
+// rejectRequest answers a request prepareRequest failed on
+func rejectRequest(c *gin.Context, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		middleware.AbortWithError(c, reqErr.status, reqErr.code, err.Error())
+		return
+	}
+	middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, err.Error())
+}
+
 func prepareRequest(c *gin.Context, target string) (*middleware.AccessibleModel, *http.Request, []byte, error) {
 	var cfg *middleware.AccessibleModel
 
 	bodyBytes, _ := io.ReadAll(c.Request.Body)
+
+	// Route the request through a running A/B experiment, if any
+	bodyBytes = applyExperiment(c, bodyBytes)
 	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 
 	// 1. Detect the model requested in the body
 	modelName, err := DetectModel(bodyBytes)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to detect model: %w", err)
+		return nil, nil, nil, &requestError{http.StatusBadRequest, models.ErrorInvalidModel, fmt.Errorf("failed to detect model: %w", err)}
 	}
 
 	fmt.Println("Did you get this far? Model detected:", modelName)
@@ -62,7 +91,8 @@ func prepareRequest(c *gin.Context, target string) (*middleware.AccessibleModel,
 	log.Println("cfg", cfg)
 
 	if !hasAccess {
-		return nil, nil, nil, fmt.Errorf("organization does not have access to model: %s", modelName)
+		return nil, nil, nil, &requestError{http.StatusForbidden, models.ErrorModelAccessDenied,
+			fmt.Errorf("organization does not have access to model: %s", modelName)}
 	}
 
 	// Store model ID in context for usage logging
@@ -84,8 +114,10 @@ func prepareRequest(c *gin.Context, target string) (*middleware.AccessibleModel,
 		if baseURL == "" {
 			return nil, nil, nil, fmt.Errorf("DUMMY_BACKEND_HOST environment variable is not set")
 		}
+	} else if useMockProvider() {
+		baseURL = mockBaseURL
 	} else {
-		baseURL = cfg.ApiEndpoint
+		baseURL = modelBaseURL(cfg)
 	}
 
 	// TODO: something here for when users enter /v1 in the ui, route already captures everything after host
@@ -104,9 +136,17 @@ func prepareRequest(c *gin.Context, target string) (*middleware.AccessibleModel,
 
 		}
 	}
+	setUpstreamAcceptEncoding(req)
 
-	// 5. Set the correct API token for the model (not dummy backend)
-	if dummyBackend != "1" {
+	// Let the provider (or anything in between) log the gateway's request ID
+	if requestID := c.GetString("request_id"); requestID != "" {
+		req.Header.Set(middleware.RequestIDHeader, requestID)
+	}
+
+	// 5. Set the correct API token for the model (not dummy backend). Bedrock and Vertex AI
+	// requests are authenticated by the client's transport instead, and self-hosted servers
+	// without a token get none.
+	if dummyBackend != "1" && !useMockProvider() && sendsBearerToken(cfg) {
 		req.Header.Set("Authorization", "Bearer "+cfg.ApiToken)
 		log.Printf("Using model-specific API token for %s", modelName)
 	}
@@ -128,33 +168,40 @@ func DetectModel(jsonInput []byte) (string, error) {
 	return req.Model, nil
 }
 
-func writeDownstreamResponse(cfg *middleware.AccessibleModel, c *gin.Context, resp *http.Response, err error, tracer trace.Tracer, startTime time.Time) {
+func writeDownstreamResponse(cfg *middleware.AccessibleModel, c *gin.Context, upstream context.Context, resp *http.Response, err error, tracer trace.Tracer, startTime time.Time) {
 	_, span := tracer.Start(c.Request.Context(), "build_response")
 	defer span.End()
 
+	if err != nil && requestStopped(upstream) {
+		log.Printf("Request %s stopped by client before the provider responded", c.GetString("request_id"))
+		c.Set("request_stopped", true)
+		setGatewayHeaders(c, cfg)
+		errorResponse := errorBody(c, models.ErrorRequestStopped, "request stopped")
+		c.Data(statusRequestStopped, "application/json", errorResponse)
+		trackUsageFromResponse(cfg, c, errorResponse, startTime)
+		return
+	}
 	if err != nil {
 		span.SetAttributes(
 			attribute.String("error.message", err.Error()),
 			attribute.Int("http.status_code", http.StatusBadGateway),
 		)
-		c.String(http.StatusBadGateway, "failed to reach provider")
+		setGatewayHeaders(c, cfg)
+		errorResponse := errorBody(c, models.ErrorProviderUnreachable, "failed to reach provider")
+		c.Data(http.StatusBadGateway, "application/json", errorResponse)
 
 		// Track the failed request
-		c.Status(http.StatusBadGateway)
-		errorResponse := []byte(`{"error": {"message": "failed to reach provider", "type": "gateway_error"}}`)
 		trackUsageFromResponse(cfg, c, errorResponse, startTime)
 		return
 	}
 	defer resp.Body.Close()
 
-	// Copy headers to client
-	for hk, hv := range resp.Header {
-		for _, v := range hv {
-			if hk != "Set-Cookie" {
-				c.Writer.Header().Add(hk, v)
-			}
-		}
-	}
+	// The provider's own rate limit headers feed the live rate gauges' warnings
+	usage.Rates.ObserveProviderLimits(cfg.ID, resp.Header, time.Now())
+
+	// Copy headers to client through the header policy, then add the gateway's own
+	copyResponseHeaders(c, resp.Header)
+	setGatewayHeaders(c, cfg)
 
 	c.Status(resp.StatusCode)
 	span.SetAttributes(
@@ -172,61 +219,68 @@ func writeDownstreamResponse(cfg *middleware.AccessibleModel, c *gin.Context, re
 
 	if isStreamingResponse {
 		log.Printf("Detected streaming response, using optimized streaming with flushing")
-		// For streaming responses, use chunk-by-chunk reading with explicit flushing
-		var responseBuffer bytes.Buffer
-		buffer := make([]byte, 4096) // Optimized buffer size
-
-		for {
-			n, err := resp.Body.Read(buffer)
-			if n > 0 {
-				// Write to client immediately
-				if _, writeErr := c.Writer.Write(buffer[:n]); writeErr != nil {
-					span.SetAttributes(attribute.String("error.message", writeErr.Error()))
-					log.Printf("Failed to write streaming chunk: %v", writeErr)
-					return
-				}
-
-				// Flush immediately for real-time delivery
-				if flusher, ok := c.Writer.(http.Flusher); ok {
-					flusher.Flush()
-				}
-
-				// Also capture for token logging (efficient in-memory operation)
-				responseBuffer.Write(buffer[:n])
-			}
+		status, _, allowed := applyResponseHooks(c, resp.StatusCode, nil, true)
+		if !allowed {
+			errorResponse := errorBody(c, models.ErrorPolicyRejected, "rejected by policy hook")
+			trackUsageFromResponse(cfg, c, errorResponse, startTime)
+			return
+		}
+		c.Status(status)
+
+		// Tee the stream into a collector that keeps only the completion text for token
+		// counting. The raw body is only held when payload logging needs it.
+		stream := usage.NewStreamCollector()
+		var sinks io.Writer = stream
+		var raw *bytes.Buffer
+		if payloadLoggingEnabled() {
+			raw = &bytes.Buffer{}
+			sinks = io.MultiWriter(stream, raw)
+		}
 
-			if err != nil {
-				if err == io.EOF {
-					log.Printf("Streaming completed successfully")
-					break
-				}
-				span.SetAttributes(attribute.String("error.message", err.Error()))
-				log.Printf("Error reading streaming response: %v", err)
-				break
-			}
+		out, finish := streamWriter(c, upstream)
+		buffer := copyBufferPool.Get().(*[]byte)
+		_, err := io.CopyBuffer(out, io.TeeReader(resp.Body, sinks), *buffer)
+		copyBufferPool.Put(buffer)
+		finish()
+		if err != nil {
+			span.SetAttributes(attribute.String("error.message", err.Error()))
+			log.Printf("Error streaming response: %v", err)
+		} else {
+			log.Printf("Streaming completed successfully")
 		}
 
-		// Track usage with captured response data
-		responseBody := responseBuffer.Bytes()
-		log.Printf("Streaming response completed - Length: %d", len(responseBody))
-		trackUsageFromResponse(cfg, c, responseBody, startTime)
+		log.Printf("Streaming response completed - Length: %d", stream.Size())
+		if requestStopped(upstream) {
+			// Usage covers the tokens streamed before the stop
+			log.Printf("Request %s stopped by client mid-stream", c.GetString("request_id"))
+			c.Set("request_stopped", true)
+		}
+		trackStreamUsage(cfg, c, stream, raw, startTime)
+		saveSessionTurn(c, resp.StatusCode, streamedReply(stream.Completion()))
 	} else {
 		log.Printf("Detected non-streaming response, reading full body")
 		// For non-streaming responses, read all then write (existing behavior)
 		responseBody, err := io.ReadAll(resp.Body)
 		if err != nil {
 			span.SetAttributes(attribute.String("error.message", err.Error()))
-			c.String(http.StatusInternalServerError, "failed to read provider response")
+			errorResponse := errorBody(c, models.ErrorProviderResponse, "failed to read provider response")
+			c.Data(http.StatusInternalServerError, "application/json", errorResponse)
 
 			// Track the failed request
-			c.Status(http.StatusInternalServerError)
-			errorResponse := []byte(`{"error": {"message": "failed to read provider response", "type": "gateway_error"}}`)
 			trackUsageFromResponse(cfg, c, errorResponse, startTime)
 			return
 		}
 
+		// Usage is counted from the provider's body even if a hook rewrites what the client gets
+		status, downstreamBody, allowed := applyResponseHooks(c, resp.StatusCode, responseBody, false)
+		if !allowed {
+			trackUsageFromResponse(cfg, c, responseBody, startTime)
+			return
+		}
+		c.Status(status)
+
 		// Write response body to client
-		if _, err = c.Writer.Write(responseBody); err != nil {
+		if _, err = c.Writer.Write(downstreamBody); err != nil {
 			span.SetAttributes(attribute.String("error.message", err.Error()))
 			c.String(http.StatusInternalServerError, "failed to write provider response")
 			return
@@ -234,6 +288,8 @@ func writeDownstreamResponse(cfg *middleware.AccessibleModel, c *gin.Context, re
 
 		log.Printf("Non-streaming response completed - Length: %d", len(responseBody))
 		trackUsageFromResponse(cfg, c, responseBody, startTime)
+		storeSemanticCache(c, resp.StatusCode, responseBody)
+		saveSessionTurn(c, resp.StatusCode, completionReply(responseBody))
 	}
 }
 
@@ -245,34 +301,64 @@ func min(a, b int) int {
 	return b
 }
 
-// trackUsageFromResponse extracts and tracks usage from the provider response
-func trackUsageFromResponse(cfg *middleware.AccessibleModel, c *gin.Context, responseBody []byte, startTime time.Time) {
-	// Get context data for usage tracking
-	orgID, _ := c.Get("organization_id")
-	apiKeyID, _ := c.Get("api_key_id")
+// copyBufferPool holds the buffers streamed responses are copied through
+var copyBufferPool = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, 32*1024)
+		return &buffer
+	},
+}
 
-	// apiKeyID = cfg.
-	modelIDStr := cfg.ID
+// flushWriter flushes each write so stream chunks reach the client as soon as they arrive
+type flushWriter struct {
+	w gin.ResponseWriter
+}
 
-	orgIDStr, _ := orgID.(string)
-	apiKeyIDStr, _ := apiKeyID.(string)
-	// apiKeyIDStr := "empty"
-	// modelIDStr, ok3 := modelID.(string)
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.w.Flush()
+	return n, err
+}
 
-	// if !ok1 || !ok3 {
-	// 	log.Printf("Missing required context for usage tracking: org=%v, apiKey=%v, model=%v", ok1, ok2, ok3)
-	// 	return
-	// }
-	log.Println("Tracking usage for org:", orgIDStr, "apiKey:", apiKeyIDStr, "model:", modelIDStr)
+// trackStreamUsage tracks usage for a streamed response from the completion text its collector
+// gathered. raw holds the full response only when payload logging is on.
+func trackStreamUsage(cfg *middleware.AccessibleModel, c *gin.Context, stream *usage.StreamCollector, raw *bytes.Buffer, startTime time.Time) {
+	orgIDStr, apiKeyIDStr, provider, requestID := usageRequestInfo(cfg, c)
+	responseTimeMS := int(time.Since(startTime).Milliseconds())
+	endpoint := c.Request.URL.Path
+
+	if raw != nil {
+		logPayload(c, cfg.ID, endpoint, raw.Bytes(), responseTimeMS)
+	}
+
+	requestBody, _ := c.Get("request_body")
+	requestBodyBytes, ok := requestBody.([]byte)
+	if !ok {
+		log.Printf("Streaming detected but no request body available for tiktoken")
+		return
+	}
+	log.Printf("Using tiktoken for streaming response (model: %s)", cfg.ID)
+	usage.TrackStreamUsage(
+		orgIDStr, apiKeyIDStr, cfg.ID, provider, endpoint,
+		requestID, c.Writer.Status(), &responseTimeMS,
+		stream, requestBodyBytes, usageMetadataFromContext(c),
+	)
+}
+
+// usageRequestInfo returns the organization, API key, provider and gateway request ID a usage
+// record is filed under
+func usageRequestInfo(cfg *middleware.AccessibleModel, c *gin.Context) (orgID, apiKeyID, provider string, requestID *string) {
+	orgIDValue, _ := c.Get("organization_id")
+	apiKeyIDValue, _ := c.Get("api_key_id")
+	orgID, _ = orgIDValue.(string)
+	apiKeyID, _ = apiKeyIDValue.(string)
 
 	// Determine provider from accessible models
-	provider := "unknown"
-	accessibleModelsInterface, exists := c.Get("accessible_models")
-	if exists {
+	provider = "unknown"
+	if accessibleModelsInterface, exists := c.Get("accessible_models"); exists {
 		if accessibleModels, ok := accessibleModelsInterface.([]middleware.AccessibleModel); ok {
 			for _, model := range accessibleModels {
-				// log.Println("ID:", model.ID, "ModelID:", modelIDStr)
-				if model.ID == modelIDStr {
+				if model.ID == cfg.ID {
 					provider = model.Provider
 					break
 				}
@@ -280,17 +366,33 @@ func trackUsageFromResponse(cfg *middleware.AccessibleModel, c *gin.Context, res
 		}
 	}
 
+	if reqID := c.GetString("request_id"); reqID != "" {
+		requestID = &reqID
+	}
+	return orgID, apiKeyID, provider, requestID
+}
+
+// errorBody builds the body of an error the gateway answers with itself, keeping its code in the
+// context as error_code for the usage log
+func errorBody(c *gin.Context, code models.ErrorCode, message string) []byte {
+	c.Set("error_code", code.Code)
+	body, _ := json.Marshal(code.Body(message))
+	return body
+}
+
+// trackUsageFromResponse extracts and tracks usage from the provider response
+func trackUsageFromResponse(cfg *middleware.AccessibleModel, c *gin.Context, responseBody []byte, startTime time.Time) {
+	modelIDStr := cfg.ID
+	orgIDStr, apiKeyIDStr, provider, requestID := usageRequestInfo(cfg, c)
+	log.Println("Tracking usage for org:", orgIDStr, "apiKey:", apiKeyIDStr, "model:", modelIDStr)
+
 	// Calculate response time
 	responseTimeMS := int(time.Since(startTime).Milliseconds())
 
 	// Get endpoint from request
 	endpoint := c.Request.URL.Path
 
-	// Extract request ID from response headers (if available)
-	var requestID *string
-	if reqID := c.Writer.Header().Get("X-Request-Id"); reqID != "" {
-		requestID = &reqID
-	}
+	logPayload(c, modelIDStr, endpoint, responseBody, responseTimeMS)
 
 	// Check if this is a streaming response - use tiktoken for all streaming
 	isStreaming := len(responseBody) > 0 && strings.Contains(string(responseBody[:min(100, len(responseBody))]), "data:")
@@ -303,7 +405,7 @@ func trackUsageFromResponse(cfg *middleware.AccessibleModel, c *gin.Context, res
 				trackUsageWithTokenizer(
 					orgIDStr, apiKeyIDStr, modelIDStr, provider, endpoint,
 					requestID, c.Writer.Status(), &responseTimeMS,
-					responseBody, requestBodyBytes,
+					responseBody, requestBodyBytes, usageMetadataFromContext(c),
 				)
 				return
 			}
@@ -311,6 +413,18 @@ func trackUsageFromResponse(cfg *middleware.AccessibleModel, c *gin.Context, res
 		log.Printf("Streaming detected but no request body available for tiktoken")
 	}
 
+	// Self-hosted servers often leave out the usage block, so estimate it when missing
+	if models.IsSelfHostedProvider(provider) {
+		requestBody, _ := c.Get("request_body")
+		requestBodyBytes, _ := requestBody.([]byte)
+		usage.TrackUsageWithEstimate(
+			orgIDStr, apiKeyIDStr, modelIDStr, provider, endpoint,
+			requestID, c.Writer.Status(), &responseTimeMS,
+			responseBody, requestBodyBytes, usageMetadataFromContext(c),
+		)
+		return
+	}
+
 	// Use standard tracking for non-streaming responses
 	usage.TrackUsage(
 		orgIDStr,
@@ -322,6 +436,7 @@ func trackUsageFromResponse(cfg *middleware.AccessibleModel, c *gin.Context, res
 		c.Writer.Status(),
 		&responseTimeMS,
 		responseBody,
+		usageMetadataFromContext(c),
 	)
 }
 
@@ -329,12 +444,59 @@ func trackUsageFromResponse(cfg *middleware.AccessibleModel, c *gin.Context, res
 func trackUsageWithTokenizer(
 	orgID, apiKeyID, modelID, provider, endpoint string,
 	requestID *string, responseStatus int, responseTimeMS *int,
-	responseBody []byte, requestBody []byte,
+	responseBody []byte, requestBody []byte, extraMetadata map[string]interface{},
 ) {
 	// Use tiktoken for accurate token counting
 	usage.TrackUsageWithTiktoken(
 		orgID, apiKeyID, modelID, provider, endpoint,
 		requestID, responseStatus, responseTimeMS,
-		responseBody, requestBody,
+		responseBody, requestBody, extraMetadata,
 	)
 }
+
+// usageMetadataFromContext returns request-scoped data (experiment assignment, schema validation, secret scan findings, context window fitting, stream pacing, semantic cache hits, session, stops) to attach to the usage log
+func usageMetadataFromContext(c *gin.Context) map[string]interface{} {
+	metadata := map[string]interface{}{}
+
+	if c.GetBool("request_stopped") {
+		metadata["stopped"] = true
+	}
+	// The catalog code of the error the gateway answered with itself, if any
+	if code := c.GetString("error_code"); code != "" {
+		metadata["error_code"] = code
+	}
+
+	if experimentID, exists := c.Get("experiment_id"); exists {
+		metadata["experiment_id"] = experimentID
+	}
+	if variantID, exists := c.Get("experiment_variant_id"); exists {
+		metadata["experiment_variant_id"] = variantID
+	}
+	if validation, exists := c.Get("schema_validation"); exists {
+		metadata["schema_validation"] = validation
+	}
+	if findings, exists := c.Get("secret_scan"); exists {
+		metadata["secret_scan"] = findings
+	}
+	if params, exists := c.Get("dropped_params"); exists {
+		metadata["dropped_params"] = params
+	}
+	if pacing, exists := c.Get("stream_pacing"); exists {
+		metadata["stream_pacing"] = pacing
+	}
+	if contextWindow, exists := c.Get("context_window"); exists {
+		metadata["context_window"] = contextWindow
+	}
+	if cache, exists := c.Get("semantic_cache"); exists {
+		metadata["semantic_cache"] = cache
+	}
+	if session, exists := c.Get("session"); exists {
+		metadata["session"] = session
+	}
+	// The provider's own ID, for matching against its logs and for feedback sent with it
+	if providerRequestID := c.Writer.Header().Get("X-Request-Id"); providerRequestID != "" {
+		metadata["provider_request_id"] = providerRequestID
+	}
+
+	return metadata
+}
@@ -14,6 +14,7 @@ func recordTracingMetadata(cfg *middleware.AccessibleModel, span, childSpan trac
 	span.SetAttributes(
 		attribute.String("llm.provider", cfg.Name),
 		attribute.String("llm.auth_header", authHeader),
+		attribute.String("relai.request_id", req.Header.Get(middleware.RequestIDHeader)),
 	)
 
 	childSpan.SetAttributes(
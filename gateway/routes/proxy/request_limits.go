@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+const (
+	// defaultMaxRequestBytes caps request bodies when GATEWAY_MAX_REQUEST_BYTES isn't set
+	defaultMaxRequestBytes = 10 << 20
+
+	// defaultMaxMessages caps the messages of a chat completion when GATEWAY_MAX_MESSAGES isn't set
+	defaultMaxMessages = 1000
+)
+
+// maxRequestBytes returns the body limit for a path: GATEWAY_MAX_REQUEST_BYTES_BY_PATH entries
+// such as "/v1/embeddings=1048576" override the global GATEWAY_MAX_REQUEST_BYTES
+func maxRequestBytes(path string) int64 {
+	for _, entry := range strings.Split(os.Getenv("GATEWAY_MAX_REQUEST_BYTES_BY_PATH"), ",") {
+		prefix, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		if limit, err := strconv.ParseInt(value, 10, 64); err == nil && limit > 0 {
+			return limit
+		}
+		log.Printf("Ignoring invalid GATEWAY_MAX_REQUEST_BYTES_BY_PATH entry %q", entry)
+	}
+
+	if limit, err := strconv.ParseInt(os.Getenv("GATEWAY_MAX_REQUEST_BYTES"), 10, 64); err == nil && limit > 0 {
+		return limit
+	}
+	return defaultMaxRequestBytes
+}
+
+func maxMessages() int {
+	if n, err := strconv.Atoi(os.Getenv("GATEWAY_MAX_MESSAGES")); err == nil && n > 0 {
+		return n
+	}
+	return defaultMaxMessages
+}
+
+// bufferRequestBody reads the request body up to the path's limit and replaces it with the
+// buffered copy, so the proxy never holds more than the limit in memory. It returns false after
+// writing a 413 or 400 response.
+func bufferRequestBody(c *gin.Context) bool {
+	limit := maxRequestBytes(c.Request.URL.Path)
+	if c.Request.ContentLength > limit {
+		rejectTooLarge(c, limit)
+		return false
+	}
+
+	bodyBytes, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			rejectTooLarge(c, limit)
+		} else {
+			rejectInvalidRequest(c, "Failed to read request body")
+		}
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+
+	if err := validateRequestBody(c.Request.URL.Path, bodyBytes); err != nil {
+		rejectInvalidRequest(c, err.Error())
+		return false
+	}
+	return true
+}
+
+// checkModelRequestSize applies the model's own body limit, which may be tighter than the
+// gateway's. It returns false after writing a 413 response.
+func checkModelRequestSize(c *gin.Context, cfg *middleware.AccessibleModel, bodyBytes []byte) bool {
+	if cfg.MaxRequestBytes == nil || len(bodyBytes) <= *cfg.MaxRequestBytes {
+		return true
+	}
+	rejectTooLarge(c, int64(*cfg.MaxRequestBytes))
+	return false
+}
+
+// validateRequestBody checks a completion request is a JSON object and, for chat completions,
+// that the messages are well formed, before anything else parses it
+func validateRequestBody(path string, bodyBytes []byte) error {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return fmt.Errorf("request body must be a JSON object")
+	}
+
+	if model, ok := body["model"]; ok {
+		var name string
+		if err := json.Unmarshal(model, &name); err != nil {
+			return fmt.Errorf("model must be a string")
+		}
+	}
+
+	if !strings.HasSuffix(path, "/chat/completions") {
+		return nil
+	}
+
+	var messages []map[string]json.RawMessage
+	if err := json.Unmarshal(body["messages"], &messages); err != nil || body["messages"] == nil {
+		return fmt.Errorf("messages must be an array of message objects")
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("messages must not be empty")
+	}
+	if limit := maxMessages(); len(messages) > limit {
+		return fmt.Errorf("messages has %d entries; at most %d are allowed", len(messages), limit)
+	}
+
+	for i, message := range messages {
+		var role string
+		if err := json.Unmarshal(message["role"], &role); err != nil || role == "" {
+			return fmt.Errorf("messages[%d].role must be a non-empty string", i)
+		}
+		if err := validateMessageContent(message["content"]); err != nil {
+			return fmt.Errorf("messages[%d].content %s", i, err)
+		}
+	}
+	return nil
+}
+
+// validateMessageContent accepts a string, null (assistant tool calls) or an array of typed parts
+func validateMessageContent(raw json.RawMessage) error {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		return nil
+	}
+
+	switch trimmed[0] {
+	case '"':
+		return nil
+	case '[':
+		var parts []map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &parts); err != nil {
+			return fmt.Errorf("must be a string or an array of content parts")
+		}
+		for _, part := range parts {
+			var partType string
+			if err := json.Unmarshal(part["type"], &partType); err != nil || partType == "" {
+				return fmt.Errorf("parts must each have a type")
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("must be a string or an array of content parts")
+}
+
+func rejectTooLarge(c *gin.Context, limit int64) {
+	middleware.AbortWithError(c, http.StatusRequestEntityTooLarge, models.ErrorRequestTooLarge,
+		fmt.Sprintf("Request body exceeds the limit of %d bytes", limit))
+}
+
+func rejectInvalidRequest(c *gin.Context, message string) {
+	middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, message)
+}
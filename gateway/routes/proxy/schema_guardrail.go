@@ -0,0 +1,206 @@
+package proxy
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/jsonschema"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// applyResponseSchema validates a non-streaming response against the endpoint's declared JSON
+// schema. On failure it optionally re-asks the model with a corrective system message. The
+// outcome is stored in the context for usage logging and the returned response replaces resp.
+func applyResponseSchema(c *gin.Context, client *http.Client, req *http.Request, bodyBytes []byte, cfg *middleware.AccessibleModel, resp *http.Response) *http.Response {
+	if resp == nil || resp.StatusCode != http.StatusOK || isStreamingRequest(bodyBytes) {
+		return resp
+	}
+
+	responseSchema := lookupResponseSchema(c)
+	if responseSchema == nil {
+		return resp
+	}
+
+	schema, err := jsonschema.Parse(responseSchema.Schema)
+	if err != nil {
+		log.Printf("Response schema %s is invalid, skipping validation: %v", responseSchema.ID, err)
+		return resp
+	}
+
+	maxAttempts := 1
+	if responseSchema.RetryOnFailure {
+		maxAttempts += responseSchema.MaxRetries
+	}
+
+	result := models.SchemaValidationResult{SchemaID: responseSchema.ID}
+	requestBody := bodyBytes
+
+	for {
+		result.Attempts++
+
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("Failed to read response for schema validation: %v", err)
+			resp.Body = io.NopCloser(bytes.NewReader(respBody))
+			result.Skipped = true
+			break
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+		output, ok := extractCompletionOutput(respBody)
+		if !ok {
+			result.Skipped = true
+			break
+		}
+
+		result.Errors = schema.ValidateJSON([]byte(output))
+		result.Valid = len(result.Errors) == 0
+		if result.Valid || result.Attempts >= maxAttempts {
+			break
+		}
+
+		correctedBody, err := buildCorrectiveRequest(requestBody, output, result.Errors, responseSchema.Schema)
+		if err != nil {
+			// Not a chat request; corrective retries need a messages array
+			break
+		}
+
+		log.Printf("Response failed schema %s validation (attempt %d/%d), retrying with correction", responseSchema.Name, result.Attempts, maxAttempts)
+		retryResp, err := makeRequestWithRetry(client, req, correctedBody, cfg)
+		if err != nil || retryResp.StatusCode != http.StatusOK {
+			if retryResp != nil {
+				retryResp.Body.Close()
+			}
+			log.Printf("Corrective retry failed, returning last response: %v", err)
+			break
+		}
+
+		resp = retryResp
+		requestBody = correctedBody
+	}
+
+	if result.Valid {
+		c.Header("X-Schema-Validation", "passed")
+	} else if result.Skipped {
+		c.Header("X-Schema-Validation", "skipped")
+	} else {
+		c.Header("X-Schema-Validation", "failed")
+		log.Printf("Response failed schema %s validation after %d attempt(s): %v", responseSchema.Name, result.Attempts, result.Errors)
+	}
+
+	c.Set("schema_validation", result)
+	return resp
+}
+
+// lookupResponseSchema returns the active response schema for the request path, if any
+func lookupResponseSchema(c *gin.Context) *models.ResponseSchema {
+	database, exists := c.Get("db")
+	if !exists {
+		return nil
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return nil
+	}
+
+	orgID, _ := c.Get("organization_id")
+	orgIDStr, ok := orgID.(string)
+	if !ok || orgIDStr == "" {
+		return nil
+	}
+
+	responseSchema, err := db.GetActiveResponseSchemaForEndpoint(sqlDB, orgIDStr, c.Request.URL.Path)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to look up response schema for %s: %v", c.Request.URL.Path, err)
+		}
+		return nil
+	}
+
+	return responseSchema
+}
+
+func isStreamingRequest(bodyBytes []byte) bool {
+	var body struct {
+		Stream bool `json:"stream"`
+	}
+	return json.Unmarshal(bodyBytes, &body) == nil && body.Stream
+}
+
+// extractCompletionOutput returns the text of the first choice of a chat or text completion
+func extractCompletionOutput(respBody []byte) (string, bool) {
+	var completion struct {
+		Choices []struct {
+			Message *struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Text *string `json:"text"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(respBody, &completion); err != nil || len(completion.Choices) == 0 {
+		return "", false
+	}
+
+	choice := completion.Choices[0]
+	var output string
+	switch {
+	case choice.Message != nil:
+		output = choice.Message.Content
+	case choice.Text != nil:
+		output = *choice.Text
+	default:
+		return "", false
+	}
+
+	return stripCodeFence(output), true
+}
+
+// stripCodeFence removes a surrounding ```json ... ``` block that models often add
+func stripCodeFence(output string) string {
+	trimmed := strings.TrimSpace(output)
+	if !strings.HasPrefix(trimmed, "```") {
+		return trimmed
+	}
+	trimmed = strings.TrimPrefix(trimmed, "```")
+	if newline := strings.Index(trimmed, "\n"); newline >= 0 {
+		trimmed = trimmed[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(trimmed), "```"))
+}
+
+// buildCorrectiveRequest appends the invalid answer and a corrective system message to a chat request
+func buildCorrectiveRequest(requestBody []byte, output string, validationErrors []string, schema json.RawMessage) ([]byte, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(requestBody, &body); err != nil {
+		return nil, err
+	}
+
+	messages, ok := body["messages"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("request has no messages")
+	}
+
+	correction := fmt.Sprintf(
+		"Your previous response did not match the required JSON schema:\n- %s\n\nRespond again with only a JSON document that matches this schema, without any other text:\n%s",
+		strings.Join(validationErrors, "\n- "), string(schema),
+	)
+
+	messages = append(messages,
+		map[string]interface{}{"role": "assistant", "content": output},
+		map[string]interface{}{"role": "system", "content": correction},
+	)
+	body["messages"] = messages
+
+	return json.Marshal(body)
+}
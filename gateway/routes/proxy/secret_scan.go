@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+	"github.com/like-mike/relai-gateway/shared/secrets"
+)
+
+// defaultSecretScanMode applies to organizations without a configured policy
+func defaultSecretScanMode() string {
+	if mode := os.Getenv("SECRET_SCAN_DEFAULT_MODE"); models.IsValidSecretScanMode(mode) {
+		return mode
+	}
+	return models.SecretScanModeFlag
+}
+
+// scanRequestForSecrets checks the outgoing prompt for credentials according to the organization's
+// policy. It records an incident when secrets are found and returns true if the request was
+// rejected (the response has already been written).
+func scanRequestForSecrets(c *gin.Context, bodyBytes []byte, modelName string) bool {
+	database, exists := c.Get("db")
+	if !exists {
+		return false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return false
+	}
+
+	orgID, _ := c.Get("organization_id")
+	orgIDStr, ok := orgID.(string)
+	if !ok || orgIDStr == "" {
+		return false
+	}
+
+	mode := defaultSecretScanMode()
+	var customRules []secrets.Rule
+	policy, err := db.GetSecretScanPolicy(sqlDB, orgIDStr)
+	if err == nil {
+		mode = policy.Mode
+		for _, p := range policy.CustomPatterns {
+			customRules = append(customRules, secrets.Rule{Name: p.Name, Pattern: p.Pattern})
+		}
+	} else if err != sql.ErrNoRows {
+		log.Printf("Failed to load secret scan policy for organization %s: %v", orgIDStr, err)
+	}
+
+	if mode == models.SecretScanModeOff {
+		return false
+	}
+
+	detector, err := secrets.NewDetector(customRules)
+	if err != nil {
+		log.Printf("Invalid custom secret patterns for organization %s, using built-in rules: %v", orgIDStr, err)
+		detector, _ = secrets.NewDetector(nil)
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return false
+	}
+
+	findings := detector.ScanAll(secrets.ExtractStrings(body))
+	if len(findings) == 0 {
+		return false
+	}
+
+	incident := models.SecretScanIncident{
+		OrganizationID: orgIDStr,
+		Endpoint:       c.Request.URL.Path,
+		Action:         "flagged",
+	}
+	if mode == models.SecretScanModeBlock {
+		incident.Action = "blocked"
+	}
+	if keyID, ok := c.Get("api_key_id"); ok {
+		if keyIDStr, ok := keyID.(string); ok && keyIDStr != "" {
+			incident.APIKeyID = &keyIDStr
+		}
+	}
+	if modelName != "" {
+		incident.ModelID = &modelName
+	}
+
+	types := make([]string, 0, len(findings))
+	for _, f := range findings {
+		incident.Findings = append(incident.Findings, models.SecretFinding{Type: f.Type, Preview: f.Preview})
+		types = append(types, f.Type)
+	}
+
+	// The policy still applies in read-only mode; only the incident record is skipped
+	if !readonly.Enabled() {
+		go func() {
+			if err := db.CreateSecretScanIncident(sqlDB, incident); err != nil {
+				log.Printf("Failed to record secret scan incident: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("Secret scan %s request for organization %s: %v", incident.Action, orgIDStr, types)
+	debugPolicy(c, "secret_scan", mode != models.SecretScanModeBlock, incident.Action+": "+joinUnique(types))
+
+	if mode == models.SecretScanModeBlock {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorSecretDetected,
+			"Request blocked: the prompt appears to contain credentials ("+joinUnique(types)+"). Remove them and try again.")
+		return true
+	}
+
+	c.Header("X-Secret-Scan", "flagged")
+	c.Set("secret_scan", incident.Findings)
+	return false
+}
+
+func joinUnique(values []string) string {
+	seen := make(map[string]bool)
+	out := ""
+	for _, v := range values {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		if out != "" {
+			out += ", "
+		}
+		out += v
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// selfHostedTransport smooths over where Ollama, vLLM and llama.cpp servers differ from the
+// OpenAI API: max_completion_tokens is sent as max_tokens, which all of them accept, and
+// their error bodies are rewritten to OpenAI's {"error": {...}} shape so SDKs can parse them.
+// Missing usage blocks are estimated when usage is tracked.
+type selfHostedTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t selfHostedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = adaptSelfHostedRequest(body)
+
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < 400 {
+		return resp, err
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if normalized, ok := normalizeSelfHostedError(raw); ok {
+		raw = normalized
+		resp.Header.Set("Content-Type", "application/json")
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	resp.ContentLength = int64(len(raw))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+	return resp, nil
+}
+
+// adaptSelfHostedRequest renames max_completion_tokens to max_tokens; older Ollama and
+// llama.cpp releases ignore the newer name and generate without a limit
+func adaptSelfHostedRequest(body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	limit, ok := fields["max_completion_tokens"]
+	if !ok {
+		return body
+	}
+	if _, ok := fields["max_tokens"]; !ok {
+		fields["max_tokens"] = limit
+	}
+	delete(fields, "max_completion_tokens")
+
+	adapted, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return adapted
+}
+
+// normalizeSelfHostedError rewrites vLLM's flat {"object": "error", "message": ...} and
+// Ollama's {"error": "..."} bodies; OpenAI-shaped errors are left alone
+func normalizeSelfHostedError(body []byte) ([]byte, bool) {
+	var flat struct {
+		Object  string          `json:"object"`
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &flat); err != nil {
+		return nil, false
+	}
+
+	message, errorType := flat.Message, flat.Type
+	var text string
+	if flat.Object != "error" && json.Unmarshal(flat.Error, &text) == nil {
+		message = text
+	} else if flat.Object != "error" {
+		return nil, false
+	}
+	if message == "" {
+		return nil, false
+	}
+	if errorType == "" {
+		errorType = "invalid_request_error"
+	}
+
+	normalized, err := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{"message": message, "type": errorType, "code": flat.Code},
+	})
+	if err != nil {
+		return nil, false
+	}
+	return normalized, true
+}
+
+// sendsBearerToken reports whether the model's API token goes in the Authorization header.
+// Self-hosted servers usually run without one.
+func sendsBearerToken(cfg *middleware.AccessibleModel) bool {
+	if authenticatesInTransport(cfg) {
+		return false
+	}
+	return !(models.IsSelfHostedProvider(cfg.Provider) && cfg.ApiToken == "")
+}
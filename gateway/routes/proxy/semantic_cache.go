@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+const (
+	// headerCache says whether a chat completion came from the semantic cache: hit or miss
+	headerCache           = "X-RelAI-Cache"
+	headerCacheSimilarity = "X-RelAI-Cache-Similarity"
+
+	defaultSemanticCacheThreshold = 0.95
+	defaultSemanticCacheTTL       = 24 * time.Hour
+	semanticCachePruneInterval    = time.Hour
+
+	// maxCachedResponseBytes keeps large completions out of the cache
+	maxCachedResponseBytes = 256 * 1024
+)
+
+// semanticCacheReady is set once the semantic cache table exists
+var semanticCacheReady atomic.Bool
+
+// semanticCacheModel returns the model ID prompts are embedded with; empty turns the cache off
+func semanticCacheModel() string {
+	return strings.TrimSpace(os.Getenv("GATEWAY_SEMANTIC_CACHE_MODEL"))
+}
+
+// semanticCacheThreshold returns the cosine similarity a cached prompt needs to answer a request,
+// from GATEWAY_SEMANTIC_CACHE_THRESHOLD
+func semanticCacheThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("GATEWAY_SEMANTIC_CACHE_THRESHOLD"), 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		return defaultSemanticCacheThreshold
+	}
+	return threshold
+}
+
+// semanticCacheTTL returns how long cached completions are served, from GATEWAY_SEMANTIC_CACHE_TTL
+func semanticCacheTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("GATEWAY_SEMANTIC_CACHE_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultSemanticCacheTTL
+	}
+	return ttl
+}
+
+// StartSemanticCache creates the semantic cache table when GATEWAY_SEMANTIC_CACHE_MODEL is set
+// and removes expired entries every hour. The cache stays off if pgvector can't be installed.
+// The returned func stops the pruning.
+func StartSemanticCache(conn *sql.DB) (stop func()) {
+	if semanticCacheModel() == "" {
+		return func() {}
+	}
+	if err := db.EnsureSemanticCacheSchema(conn); err != nil {
+		log.Printf("Semantic cache disabled: failed to create its table (is pgvector installed?): %v", err)
+		return func() {}
+	}
+	semanticCacheReady.Store(true)
+	log.Printf("Semantic cache enabled with embedding model %s, similarity threshold %.2f and TTL %s",
+		semanticCacheModel(), semanticCacheThreshold(), semanticCacheTTL())
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(semanticCachePruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if readonly.Enabled() {
+					continue
+				}
+				removed, err := db.DeleteSemanticCacheEntriesBefore(conn, time.Now().Add(-semanticCacheTTL()))
+				if err != nil {
+					log.Printf("Failed to prune the semantic cache: %v", err)
+				} else if removed > 0 {
+					log.Printf("Pruned %d expired semantic cache entries", removed)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// semanticCacheMiss is what storeSemanticCache needs to cache the completion of a request the
+// cache couldn't answer
+type semanticCacheMiss struct {
+	entry     models.SemanticCacheEntry
+	embedding []float32
+}
+
+// serveFromSemanticCache answers a non-streamed chat completion from the cache when the model has
+// the semantic cache on and an earlier prompt with the same parameters matches exactly or has an
+// embedding at least as similar as the threshold. It returns true once it has written the
+// response. On a miss it leaves the prompt's embedding in the context so the completion can be
+// stored. Cache-Control: no-cache skips the lookup and no-store the storing.
+func serveFromSemanticCache(c *gin.Context, cfg *middleware.AccessibleModel, bodyBytes []byte) bool {
+	if !semanticCacheReady.Load() || !cfg.Metadata.SemanticCache || !strings.HasSuffix(c.Request.URL.Path, "/chat/completions") {
+		return false
+	}
+	// Prompts with credentials in them are never kept
+	if _, flagged := c.Get("secret_scan"); flagged {
+		return false
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		return false
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return false
+	}
+	orgID := c.GetString("organization_id")
+	if orgID == "" {
+		return false
+	}
+
+	promptHash, paramsHash, messages, ok := models.SemanticCacheKeys(bodyBytes)
+	if !ok {
+		return false
+	}
+
+	start := time.Now()
+	cacheControl := strings.ToLower(c.GetHeader("Cache-Control"))
+	lookup := !strings.Contains(cacheControl, "no-cache")
+	since := start.Add(-semanticCacheTTL())
+
+	if lookup {
+		entry, err := db.FindExactCacheEntry(sqlDB, orgID, cfg.ID, promptHash, since)
+		if err != nil {
+			log.Printf("Semantic cache lookup failed for model %s: %v", cfg.ModelID, err)
+		} else if entry != nil {
+			serveCachedCompletion(c, cfg, entry, "exact", start)
+			return true
+		}
+	}
+
+	embeddingModelID := semanticCacheModel()
+	embeddingCfg := accessibleModel(c, embeddingModelID)
+	if embeddingCfg == nil {
+		log.Printf("Semantic cache skipped: embedding model %s is not available to organization %s", embeddingModelID, orgID)
+		return false
+	}
+	embeddings, err := Embed(c, embeddingCfg, []string{usage.ConversationText(messages)},
+		map[string]interface{}{"semantic_cache_embedding": true})
+	if err != nil {
+		log.Printf("Semantic cache skipped for model %s: %v", cfg.ModelID, err)
+		return false
+	}
+	embedding := embeddings[0]
+
+	if lookup {
+		entry, err := db.FindSimilarCacheEntry(sqlDB, orgID, cfg.ID, paramsHash, embeddingModelID, embedding,
+			semanticCacheThreshold(), since)
+		if err != nil {
+			log.Printf("Semantic cache lookup failed for model %s: %v", cfg.ModelID, err)
+		} else if entry != nil {
+			serveCachedCompletion(c, cfg, entry, "semantic", start)
+			return true
+		}
+	}
+
+	c.Header(headerCache, "miss")
+	c.Set("semantic_cache", map[string]interface{}{"hit": false})
+	if !strings.Contains(cacheControl, "no-store") {
+		c.Set("semantic_cache_miss", &semanticCacheMiss{
+			entry: models.SemanticCacheEntry{
+				OrganizationID: orgID,
+				ModelID:        cfg.ID,
+				EmbeddingModel: embeddingModelID,
+				PromptHash:     promptHash,
+				ParamsHash:     paramsHash,
+			},
+			embedding: embedding,
+		})
+	}
+	return false
+}
+
+// serveCachedCompletion writes a cached completion and logs the request with no tokens, flagged
+// as a cache hit in its usage metadata
+func serveCachedCompletion(c *gin.Context, cfg *middleware.AccessibleModel, entry *models.SemanticCacheEntry, match string, start time.Time) {
+	c.Set("semantic_cache", map[string]interface{}{
+		"hit":        true,
+		"match":      match,
+		"similarity": entry.Similarity,
+		"entry_id":   entry.ID,
+		"cached_at":  entry.CreatedAt.UTC().Format(time.RFC3339),
+	})
+	setGatewayHeaders(c, cfg)
+	c.Header(headerCache, "hit")
+	c.Header(headerCacheSimilarity, strconv.FormatFloat(entry.Similarity, 'f', 4, 64))
+	c.Data(http.StatusOK, "application/json", entry.ResponseBody)
+	saveSessionTurn(c, http.StatusOK, completionReply(entry.ResponseBody))
+
+	log.Printf("Semantic cache %s hit for model %s (similarity %.4f)", match, cfg.ModelID, entry.Similarity)
+	orgID, apiKeyID, provider, requestID := usageRequestInfo(cfg, c)
+	responseTimeMS := int(time.Since(start).Milliseconds())
+	usage.TrackCachedResponse(orgID, apiKeyID, cfg.ID, provider, c.Request.URL.Path, requestID, http.StatusOK,
+		&responseTimeMS, usageMetadataFromContext(c))
+}
+
+// storeSemanticCache caches a successful completion for a request the semantic cache missed
+func storeSemanticCache(c *gin.Context, status int, responseBody []byte) {
+	value, exists := c.Get("semantic_cache_miss")
+	if !exists || status != http.StatusOK || readonly.Enabled() {
+		return
+	}
+	miss, ok := value.(*semanticCacheMiss)
+	if !ok || len(responseBody) > maxCachedResponseBytes || !json.Valid(responseBody) {
+		return
+	}
+	database, _ := c.Get("db")
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return
+	}
+
+	entry := miss.entry
+	entry.ResponseBody = responseBody
+	go func() {
+		if err := db.CreateSemanticCacheEntry(sqlDB, entry, miss.embedding); err != nil {
+			log.Printf("Failed to store semantic cache entry for model %s: %v", entry.ModelID, err)
+		}
+	}()
+}
@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+)
+
+// SendToModel posts a JSON body to one of the model's provider endpoints outside of an HTTP
+// request, using the model's token, timeout and retry settings. It is used by background jobs
+// such as batches; the caller closes the response body. requestID is sent as X-RelAI-Request-Id.
+func SendToModel(cfg *middleware.AccessibleModel, path string, body []byte, requestID string) (*http.Response, error) {
+	baseURL := modelBaseURL(cfg)
+	dummyBackend := os.Getenv("USE_DUMMY_BACKEND") == "1"
+	if dummyBackend {
+		baseURL = os.Getenv("DUMMY_BACKEND_HOST")
+		if baseURL == "" {
+			return nil, fmt.Errorf("DUMMY_BACKEND_HOST environment variable is not set")
+		}
+	} else if useMockProvider() {
+		baseURL = mockBaseURL
+	}
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.RequestIDHeader, requestID)
+	setUpstreamAcceptEncoding(req)
+	if !dummyBackend && !useMockProvider() && sendsBearerToken(cfg) {
+		req.Header.Set("Authorization", "Bearer "+cfg.ApiToken)
+	}
+
+	return makeRequestWithRetry(createHTTPClientForModel(cfg), req, body, cfg)
+}
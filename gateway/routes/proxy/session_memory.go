@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// HeaderSession names the conversation a chat completion belongs to
+const HeaderSession = "X-RelAI-Session-Id"
+
+const (
+	defaultSessionTTL         = 24 * time.Hour
+	defaultSessionMaxMessages = 100
+	defaultSessionMaxBytes    = 256 * 1024
+	sessionPruneInterval      = time.Hour
+)
+
+// SessionMemoryEnabled reports whether GATEWAY_SESSION_MEMORY turns server-side conversation
+// history on
+func SessionMemoryEnabled() bool {
+	return os.Getenv("GATEWAY_SESSION_MEMORY") == "true"
+}
+
+// sessionTTL returns how long an idle session is kept, from GATEWAY_SESSION_TTL
+func sessionTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("GATEWAY_SESSION_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultSessionTTL
+	}
+	return ttl
+}
+
+// sessionLimit reads a positive size limit from the environment
+func sessionLimit(name string, fallback int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+// StartSessionMemory removes expired sessions every hour when session memory is on. The returned
+// func stops it.
+func StartSessionMemory(conn *sql.DB) (stop func()) {
+	if !SessionMemoryEnabled() {
+		return func() {}
+	}
+	log.Printf("Session memory enabled with TTL %s", sessionTTL())
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(sessionPruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if readonly.Enabled() {
+					continue
+				}
+				removed, err := db.DeleteExpiredConversationSessions(conn, time.Now())
+				if err != nil {
+					log.Printf("Failed to prune conversation sessions: %v", err)
+				} else if removed > 0 {
+					log.Printf("Pruned %d expired conversation sessions", removed)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// sessionTurn is a chat completion in a session, kept until its reply can be saved
+type sessionTurn struct {
+	session  models.ConversationSession
+	history  []json.RawMessage
+	messages []json.RawMessage // The request's new messages, without its system messages
+}
+
+// LoadSession returns an organization's session with its messages opened, or sql.ErrNoRows
+func LoadSession(sqlDB *sql.DB, orgID, sessionID string) (*models.ConversationSession, error) {
+	session, sealed, err := db.GetConversationSession(sqlDB, orgID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := encryption.DecryptForOrganization(orgID, sealed)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(plaintext), &session.Messages); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// applySessionHistory puts the session's history in front of the new messages of a chat
+// completion sent with X-RelAI-Session-Id, keeping the request's own system messages first. The
+// reply is added to the session by saveSessionTurn. It returns the body to send, or false after
+// writing an error response.
+func applySessionHistory(c *gin.Context, cfg *middleware.AccessibleModel, bodyBytes []byte) ([]byte, bool) {
+	sessionID := c.GetHeader(HeaderSession)
+	if sessionID == "" || !SessionMemoryEnabled() || !strings.HasSuffix(c.Request.URL.Path, "/chat/completions") {
+		return bodyBytes, true
+	}
+	if !models.IsValidSessionID(sessionID) {
+		rejectSession(c, http.StatusBadRequest, models.ErrorInvalidSession, HeaderSession+" must be 1-128 letters, digits, '.', '_', ':' or '-'")
+		return nil, false
+	}
+
+	database, _ := c.Get("db")
+	sqlDB, ok := database.(*sql.DB)
+	orgID := c.GetString("organization_id")
+	if !ok || orgID == "" {
+		return bodyBytes, true
+	}
+
+	var body map[string]json.RawMessage
+	var messages []json.RawMessage
+	if json.Unmarshal(bodyBytes, &body) != nil || json.Unmarshal(body["messages"], &messages) != nil {
+		return bodyBytes, true
+	}
+
+	var history []json.RawMessage
+	session, err := LoadSession(sqlDB, orgID, sessionID)
+	switch {
+	case err == sql.ErrNoRows:
+	case err == encryption.ErrNoKey:
+		rejectSession(c, http.StatusServiceUnavailable, models.ErrorFeatureUnavailable, "Session memory is unavailable")
+		return nil, false
+	case err != nil:
+		// History sealed with a rotated key can't be read; the session starts over
+		log.Printf("Starting session %s of organization %s over: %v", sessionID, orgID, err)
+	default:
+		history = session.Messages
+	}
+
+	_, newMessages := models.SplitInstructions(messages)
+	turn := &sessionTurn{
+		session:  models.ConversationSession{OrganizationID: orgID, SessionID: sessionID},
+		history:  history,
+		messages: newMessages,
+	}
+	if keyID := c.GetString("api_key_id"); keyID != "" {
+		turn.session.APIKeyID = &keyID
+	}
+	c.Set("session_turn", turn)
+	c.Set("session", map[string]interface{}{"id": sessionID, "history_messages": len(history)})
+	c.Header(HeaderSession, sessionID)
+
+	if len(history) == 0 {
+		return bodyBytes, true
+	}
+	if body["messages"], err = json.Marshal(models.SessionPrompt(history, messages)); err != nil {
+		return bodyBytes, true
+	}
+	merged, err := json.Marshal(body)
+	if err != nil {
+		return bodyBytes, true
+	}
+	c.Set("request_body", merged)
+	return merged, true
+}
+
+// saveSessionTurn adds a successful turn's new messages and the assistant's reply to its
+// session, dropping the oldest messages beyond the size limits, and extends the session's TTL
+func saveSessionTurn(c *gin.Context, status int, reply json.RawMessage) {
+	value, exists := c.Get("session_turn")
+	turn, ok := value.(*sessionTurn)
+	if !exists || !ok || status != http.StatusOK || len(reply) == 0 || readonly.Enabled() {
+		return
+	}
+	database, _ := c.Get("db")
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return
+	}
+
+	messages := make([]json.RawMessage, 0, len(turn.history)+len(turn.messages)+1)
+	messages = append(messages, turn.history...)
+	messages = append(messages, turn.messages...)
+	messages = append(messages, reply)
+	messages = models.TrimSessionHistory(messages,
+		sessionLimit("GATEWAY_SESSION_MAX_MESSAGES", defaultSessionMaxMessages),
+		sessionLimit("GATEWAY_SESSION_MAX_BYTES", defaultSessionMaxBytes))
+
+	plaintext, err := json.Marshal(messages)
+	if err != nil {
+		return
+	}
+	sealed, err := encryption.EncryptForOrganization(turn.session.OrganizationID, string(plaintext))
+	if err != nil {
+		log.Printf("Failed to seal session %s: %v", turn.session.SessionID, err)
+		return
+	}
+
+	session := turn.session
+	session.SizeBytes = len(plaintext)
+	session.ExpiresAt = time.Now().Add(sessionTTL())
+	if err := db.SaveConversationSession(sqlDB, &session, sealed); err != nil {
+		log.Printf("Failed to save session %s: %v", session.SessionID, err)
+	}
+}
+
+// completionReply returns the assistant message of a chat completion response
+func completionReply(responseBody []byte) json.RawMessage {
+	var completion struct {
+		Choices []struct {
+			Message json.RawMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(responseBody, &completion) != nil || len(completion.Choices) == 0 {
+		return nil
+	}
+	return completion.Choices[0].Message
+}
+
+// streamedReply returns the assistant message of a streamed chat completion's text
+func streamedReply(completion string) json.RawMessage {
+	if completion == "" {
+		return nil
+	}
+	reply, err := json.Marshal(map[string]string{"role": "assistant", "content": completion})
+	if err != nil {
+		return nil
+	}
+	return reply
+}
+
+func rejectSession(c *gin.Context, status int, code models.ErrorCode, message string) {
+	middleware.AbortWithError(c, status, code, message)
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/pacing"
+)
+
+// streamWriter returns the writer a streamed response is copied to, and a function waiting until
+// the client has everything it holds once the stream ends. Streams are flushed to the client
+// chunk by chunk as they arrive, unless the organization paces the endpoint to a token rate.
+// A paced stream is still read from the provider as fast as it arrives, so the model's timeout
+// applies as usual, and delivery stops when the request does.
+func streamWriter(c *gin.Context, upstream context.Context) (io.Writer, func()) {
+	unpaced := func() (io.Writer, func()) { return flushWriter{c.Writer}, func() {} }
+
+	database, exists := c.Get("db")
+	if !exists {
+		return unpaced()
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return unpaced()
+	}
+	orgID := c.GetString("organization_id")
+	if orgID == "" {
+		return unpaced()
+	}
+
+	rule, err := db.GetActiveStreamPacing(sqlDB, orgID, c.Request.URL.Path)
+	if err != nil {
+		log.Printf("Failed to load stream pacing for organization %s: %v", orgID, err)
+		return unpaced()
+	}
+	if rule == nil {
+		return unpaced()
+	}
+
+	c.Set("stream_pacing", map[string]interface{}{
+		"tokens_per_second": rule.TokensPerSecond,
+		"burst_tokens":      rule.BurstTokens,
+	})
+	w := pacing.NewWriter(upstream, c.Writer, c.Writer.Flush, rule.TokensPerSecond, rule.BurstTokens)
+	return w, func() {
+		if err := w.Close(); err != nil {
+			log.Printf("Error finishing paced stream: %v", err)
+		}
+	}
+}
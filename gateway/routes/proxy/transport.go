@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// transportOptions are a model's transport options merged over the gateway's GATEWAY_* settings
+type transportOptions struct {
+	ProxyURL           string
+	CABundle           string // The model's PEM roots
+	CAFile             string // GATEWAY_CA_BUNDLE, a PEM file of roots every model trusts
+	TLSMinVersion      string
+	InsecureSkipVerify bool
+	DisableHTTP2       bool
+}
+
+// providerTransports holds a transport for each distinct set of options, so requests to a
+// provider share its connections and CA bundles are parsed once. Entries for options no model
+// uses any more just idle out their connections.
+var providerTransports sync.Map // JSON of transportOptions -> *http.Transport
+
+// providerTransport returns the transport reaching a model's provider. Without proxy options it
+// honours HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+func providerTransport(model models.ModelTransport) http.RoundTripper {
+	options := resolveTransportOptions(model)
+	key, err := json.Marshal(options)
+	if err != nil {
+		return failingTransport{err}
+	}
+	if transport, ok := providerTransports.Load(string(key)); ok {
+		return transport.(*http.Transport)
+	}
+
+	transport, err := newProviderTransport(options)
+	if err != nil {
+		return failingTransport{fmt.Errorf("provider transport: %w", err)}
+	}
+	actual, _ := providerTransports.LoadOrStore(string(key), transport)
+	return actual.(*http.Transport)
+}
+
+// resolveTransportOptions fills the options a model leaves unset from the gateway's settings.
+// Skipping verification or HTTP/2 in either applies.
+func resolveTransportOptions(model models.ModelTransport) transportOptions {
+	options := transportOptions{
+		ProxyURL:           model.ProxyURL,
+		CABundle:           model.CABundle,
+		CAFile:             os.Getenv("GATEWAY_CA_BUNDLE"),
+		TLSMinVersion:      model.TLSMinVersion,
+		InsecureSkipVerify: model.InsecureSkipVerify || envEnabled("GATEWAY_TLS_INSECURE_SKIP_VERIFY"),
+		DisableHTTP2:       model.DisableHTTP2 || envEnabled("GATEWAY_DISABLE_HTTP2"),
+	}
+	if options.ProxyURL == "" {
+		options.ProxyURL = os.Getenv("GATEWAY_PROXY_URL")
+	}
+	if options.TLSMinVersion == "" {
+		options.TLSMinVersion = os.Getenv("GATEWAY_TLS_MIN_VERSION")
+	}
+	return options
+}
+
+func newProviderTransport(options transportOptions) (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: options.InsecureSkipVerify}
+	if options.TLSMinVersion != "" {
+		version, ok := models.TLSVersion(options.TLSMinVersion)
+		if !ok {
+			return nil, fmt.Errorf("unknown minimum TLS version %q", options.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if options.CABundle != "" || options.CAFile != "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if options.CAFile != "" {
+			pem, err := os.ReadFile(options.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read GATEWAY_CA_BUNDLE: %w", err)
+			}
+			if !roots.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("GATEWAY_CA_BUNDLE %s holds no PEM certificates", options.CAFile)
+			}
+		}
+		if options.CABundle != "" && !roots.AppendCertsFromPEM([]byte(options.CABundle)) {
+			return nil, fmt.Errorf("model CA bundle holds no PEM certificates")
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		ForceAttemptHTTP2:   !options.DisableHTTP2,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+		DisableCompression:  false,
+	}
+	if options.DisableHTTP2 {
+		// A non-nil, empty map keeps the transport from negotiating HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if options.ProxyURL != "" {
+		proxyURL, err := models.ParseProxyURL(options.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport, nil
+}
+
+func envEnabled(name string) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
+}
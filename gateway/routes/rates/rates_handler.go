@@ -0,0 +1,15 @@
+package rates
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+// Handler returns this gateway's in-memory request and token counters for the admin UI's live
+// rate gauges
+func Handler(c *gin.Context) {
+	c.JSON(http.StatusOK, usage.Rates.Snapshot(time.Now()))
+}
@@ -0,0 +1,46 @@
+package rates
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+// streamKeepAlive is how often an idle usage stream sends a blank line, so proxies keep it open
+// and a UI that went away is noticed
+const streamKeepAlive = 15 * time.Second
+
+// UsageStreamHandler streams this gateway's recorded usage as newline-delimited JSON deltas until
+// the caller disconnects. The admin UI relays them to live dashboards.
+func UsageStreamHandler(c *gin.Context) {
+	deltas, unsubscribe := usage.Live.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	encoder := json.NewEncoder(c.Writer)
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := c.Writer.Write([]byte("\n")); err != nil {
+				return
+			}
+		case delta := <-deltas:
+			if err := encoder.Encode(delta); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
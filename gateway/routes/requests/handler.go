@@ -0,0 +1,25 @@
+// Package requests lets clients stop a request they sent while the provider is still answering,
+// such as a chat UI's stop button
+package requests
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/gateway/routes/proxy"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// StopHandler cancels an in-flight request made with the same API key, given its
+// X-RelAI-Request-Id, on whichever replica runs it when in-flight requests are shared. A
+// streamed reply ends where it was, and its usage covers the tokens already sent.
+func StopHandler(c *gin.Context) {
+	requestID := c.Param("request_id")
+	if !proxy.StopRequest(c.Request.Context(), requestID, c.GetString("organization_id"), c.GetString("api_key_id")) {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such in-flight request")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": requestID, "stopped": true})
+}
@@ -0,0 +1,39 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+func TestStopUnknownRequestAnswersWithErrorCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var errorCode string
+	r := gin.New()
+	r.POST("/v1/requests/:request_id/stop", func(c *gin.Context) {
+		StopHandler(c)
+		errorCode = c.GetString("error_code")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/requests/missing/stop", nil))
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+	var body struct {
+		Error struct {
+			Code      string `json:"code"`
+			RelaiCode string `json:"relai_code"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, models.ErrorNotFound.Code, body.Error.RelaiCode)
+	assert.Equal(t, "not_found", body.Error.Code)
+	assert.Equal(t, models.ErrorNotFound.Code, errorCode, "recorded for the usage log")
+}
@@ -0,0 +1,88 @@
+// Package sessions lets clients read and clear the conversation history the gateway keeps for
+// chat completions sent with X-RelAI-Session-Id
+package sessions
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/gateway/routes/proxy"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// requestContext returns the database, organization and session ID of an authenticated
+// request. It writes the error response itself and returns ok=false on failure.
+func requestContext(c *gin.Context) (*sql.DB, string, string, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return nil, "", "", false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return nil, "", "", false
+	}
+
+	orgID := c.GetString("organization_id")
+	if orgID == "" {
+		middleware.AbortWithError(c, http.StatusUnauthorized, models.ErrorAuthenticationRequired, "Authentication required")
+		return nil, "", "", false
+	}
+
+	sessionID := c.Param("id")
+	if !models.IsValidSessionID(sessionID) {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such session")
+		return nil, "", "", false
+	}
+
+	return sqlDB, orgID, sessionID, true
+}
+
+// GetHandler returns a session's history
+func GetHandler(c *gin.Context) {
+	sqlDB, orgID, sessionID, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	session, err := proxy.LoadSession(sqlDB, orgID, sessionID)
+	if err == sql.ErrNoRows {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such session")
+		return
+	} else if err == encryption.ErrNoKey {
+		middleware.AbortWithError(c, http.StatusServiceUnavailable, models.ErrorFeatureUnavailable, "Session memory is unavailable")
+		return
+	} else if err != nil {
+		log.Printf("Failed to load session %s: %v", sessionID, err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to load session")
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// DeleteHandler forgets a session's history
+func DeleteHandler(c *gin.Context) {
+	sqlDB, orgID, sessionID, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteConversationSession(sqlDB, orgID, sessionID); err == sql.ErrNoRows {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such session")
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete session %s: %v", sessionID, err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to delete session")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": sessionID, "deleted": true})
+}
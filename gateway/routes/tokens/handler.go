@@ -0,0 +1,76 @@
+// Package tokens exchanges API keys for ephemeral tokens
+package tokens
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/ephemeraltoken"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// CreateEphemeralHandler mints a short-lived token acting as the request's API key, limited to
+// some of its scopes, for clients that can't be trusted with the key itself. The token never
+// outlives the key.
+func CreateEphemeralHandler(c *gin.Context) {
+	if c.GetBool("ephemeral_token") || c.GetString("api_key") == "" {
+		middleware.AbortWithError(c, http.StatusForbidden, models.ErrorAPIKeyRequired, "Ephemeral tokens can only be minted with an API key")
+		return
+	}
+
+	database, _ := c.Get("db")
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return
+	}
+	keyID := c.GetString("api_key_id")
+	orgID := c.GetString("organization_id")
+	scopes, _ := c.Get("api_key_scopes")
+	parentScopes, _ := scopes.([]string)
+
+	var req models.CreateEphemeralTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "scopes are required")
+		return
+	}
+	if err := req.Validate(parentScopes); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, err.Error())
+		return
+	}
+
+	key, err := db.GetAPIKey(sqlDB, keyID)
+	if err != nil {
+		log.Printf("Failed to load API key %s for an ephemeral token: %v", keyID, err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to mint token")
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(expiresAt) {
+		expiresAt = *key.ExpiresAt
+	}
+
+	token, err := ephemeraltoken.Sign(keyID, orgID, req.Scopes, expiresAt)
+	if err == ephemeraltoken.ErrNotConfigured {
+		middleware.AbortWithError(c, http.StatusServiceUnavailable, models.ErrorFeatureUnavailable, "Ephemeral tokens are not enabled on this gateway")
+		return
+	} else if err != nil {
+		log.Printf("Failed to sign ephemeral token: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to mint token")
+		return
+	}
+
+	log.Printf("Minted ephemeral token for API key %s with scopes %v until %s", keyID, req.Scopes, expiresAt.Format(time.RFC3339))
+	c.JSON(http.StatusCreated, models.EphemeralToken{
+		Object:    "ephemeral_token",
+		Token:     token,
+		Scopes:    req.Scopes,
+		ExpiresIn: int(time.Until(expiresAt).Seconds()),
+		ExpiresAt: expiresAt.UTC(),
+	})
+}
@@ -0,0 +1,299 @@
+// Package vectors serves /v1/vector/collections: per-organization collections of embedded
+// documents stored with pgvector, and similarity queries over them. It is off unless
+// GATEWAY_VECTOR_STORE is true.
+package vectors
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/gateway/routes/proxy"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// maxRequestBytes caps document and query bodies; 100 documents of 3072 dimensions fit easily
+const maxRequestBytes = 16 << 20
+
+// ready is set once the vector tables exist
+var ready atomic.Bool
+
+// Enabled reports whether GATEWAY_VECTOR_STORE turns the vector store on
+func Enabled() bool {
+	return os.Getenv("GATEWAY_VECTOR_STORE") == "true"
+}
+
+// Start creates the vector tables when the vector store is enabled. The routes answer 503 if
+// pgvector can't be installed.
+func Start(conn *sql.DB) {
+	if !Enabled() {
+		return
+	}
+	if err := db.EnsureVectorStoreSchema(conn); err != nil {
+		log.Printf("Vector store unavailable: failed to create its tables (is pgvector installed?): %v", err)
+		return
+	}
+	ready.Store(true)
+	log.Printf("Vector store enabled at /v1/vector/collections")
+}
+
+// requestContext returns the database and organization of an authenticated request. It writes
+// the error response itself and returns ok=false on failure.
+func requestContext(c *gin.Context) (*sql.DB, string, bool) {
+	if !ready.Load() {
+		middleware.AbortWithError(c, http.StatusServiceUnavailable, models.ErrorFeatureUnavailable, "The vector store is unavailable")
+		return nil, "", false
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return nil, "", false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Database connection error")
+		return nil, "", false
+	}
+
+	orgID := c.GetString("organization_id")
+	if orgID == "" {
+		middleware.AbortWithError(c, http.StatusUnauthorized, models.ErrorAuthenticationRequired, "Authentication required")
+		return nil, "", false
+	}
+
+	return sqlDB, orgID, true
+}
+
+// loadCollection returns the collection named in the path, writing a 404 if the organization
+// has none by that name
+func loadCollection(c *gin.Context) (*sql.DB, *models.VectorCollection, bool) {
+	sqlDB, orgID, ok := requestContext(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	collection, err := db.GetVectorCollection(sqlDB, orgID, c.Param("name"))
+	if err == sql.ErrNoRows {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such collection: "+c.Param("name"))
+		return nil, nil, false
+	} else if err != nil {
+		log.Printf("Failed to get vector collection: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to load collection")
+		return nil, nil, false
+	}
+	return sqlDB, collection, true
+}
+
+// ListCollectionsHandler lists the organization's collections
+func ListCollectionsHandler(c *gin.Context) {
+	sqlDB, orgID, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	collections, err := db.GetVectorCollections(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to list vector collections: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to list collections")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": collections})
+}
+
+// CreateCollectionHandler creates a collection. Its embedding_model, when set, must be a model
+// the organization can use.
+func CreateCollectionHandler(c *gin.Context) {
+	sqlDB, orgID, ok := requestContext(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateVectorCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "name and dimensions are required")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, err.Error())
+		return
+	}
+	if req.EmbeddingModel != "" && proxy.AccessibleModel(c, req.EmbeddingModel) == nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorModelAccessDenied, "embedding_model "+req.EmbeddingModel+" is not available to this organization")
+		return
+	}
+
+	collection := models.VectorCollection{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Dimensions:     req.Dimensions,
+		EmbeddingModel: req.EmbeddingModel,
+	}
+	if err := db.CreateVectorCollection(sqlDB, &collection); errors.Is(err, db.ErrVectorCollectionNameTaken) {
+		middleware.AbortWithError(c, http.StatusConflict, models.ErrorConflict, err.Error())
+		return
+	} else if err != nil {
+		log.Printf("Failed to create vector collection: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to create collection")
+		return
+	}
+
+	c.JSON(http.StatusCreated, collection)
+}
+
+// GetCollectionHandler returns a collection with its document count
+func GetCollectionHandler(c *gin.Context) {
+	if _, collection, ok := loadCollection(c); ok {
+		c.JSON(http.StatusOK, collection)
+	}
+}
+
+// DeleteCollectionHandler deletes a collection and its documents
+func DeleteCollectionHandler(c *gin.Context) {
+	sqlDB, collection, ok := loadCollection(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteVectorCollection(sqlDB, collection.ID); err != nil {
+		log.Printf("Failed to delete vector collection: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to delete collection")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": collection.ID, "name": collection.Name, "deleted": true})
+}
+
+// UpsertDocumentsHandler adds documents to a collection, replacing any with the same IDs.
+// Documents sent with text but no embedding are embedded by the collection's embedding model.
+func UpsertDocumentsHandler(c *gin.Context) {
+	sqlDB, collection, ok := loadCollection(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBytes)
+	var req models.UpsertVectorDocumentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "documents are required")
+		return
+	}
+	needsEmbedding, err := req.Validate(collection)
+	if err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, err.Error())
+		return
+	}
+
+	if needsEmbedding {
+		var texts []string
+		var indexes []int
+		for i, doc := range req.Documents {
+			if len(doc.Embedding) == 0 {
+				texts = append(texts, doc.Text)
+				indexes = append(indexes, i)
+			}
+		}
+		embeddings, ok := embed(c, collection, texts)
+		if !ok {
+			return
+		}
+		for i, embedding := range embeddings {
+			req.Documents[indexes[i]].Embedding = embedding
+		}
+	}
+
+	if err := db.UpsertVectorDocuments(sqlDB, collection.ID, req.Documents); err != nil {
+		log.Printf("Failed to store vector documents: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to store documents")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"collection": collection.Name, "upserted": len(req.Documents)})
+}
+
+// DeleteDocumentHandler removes a document from a collection
+func DeleteDocumentHandler(c *gin.Context) {
+	sqlDB, collection, ok := loadCollection(c)
+	if !ok {
+		return
+	}
+
+	documentID := c.Param("id")
+	if err := db.DeleteVectorDocument(sqlDB, collection.ID, documentID); err == sql.ErrNoRows {
+		middleware.AbortWithError(c, http.StatusNotFound, models.ErrorNotFound, "No such document: "+documentID)
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete vector document: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to delete document")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": documentID, "deleted": true})
+}
+
+// QueryHandler returns the documents closest to the query's embedding, or to its text embedded
+// by the collection's embedding model, best first
+func QueryHandler(c *gin.Context) {
+	sqlDB, collection, ok := loadCollection(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBytes)
+	var req models.VectorQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "Invalid query")
+		return
+	}
+	if err := req.Validate(collection); err != nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, err.Error())
+		return
+	}
+
+	embedding := req.Embedding
+	if len(embedding) == 0 {
+		embeddings, ok := embed(c, collection, []string{req.Text})
+		if !ok {
+			return
+		}
+		embedding = embeddings[0]
+	}
+
+	matches, err := db.QueryVectorDocuments(sqlDB, collection.ID, embedding, req.TopK, req.Filter, req.MinScore, req.IncludeEmbeddings)
+	if err != nil {
+		log.Printf("Failed to query vector collection: %v", err)
+		middleware.AbortWithError(c, http.StatusInternalServerError, models.ErrorInternal, "Failed to query collection")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "collection": collection.Name, "data": matches})
+}
+
+// embed embeds texts with the collection's embedding model, writing the error response itself
+// and returning ok=false on failure. The embedding call is logged as usage of the request's key.
+func embed(c *gin.Context, collection *models.VectorCollection, texts []string) ([][]float32, bool) {
+	embeddingCfg := proxy.AccessibleModel(c, collection.EmbeddingModel)
+	if embeddingCfg == nil {
+		middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorModelAccessDenied, "embedding_model "+collection.EmbeddingModel+" is not available to this organization")
+		return nil, false
+	}
+
+	embeddings, err := proxy.Embed(c, embeddingCfg, texts, map[string]interface{}{"vector_collection": collection.Name})
+	if err != nil {
+		log.Printf("Failed to embed documents for vector collection %s: %v", collection.ID, err)
+		middleware.AbortWithError(c, http.StatusBadGateway, models.ErrorProviderUnreachable, "Failed to embed text with "+collection.EmbeddingModel)
+		return nil, false
+	}
+	for _, embedding := range embeddings {
+		if len(embedding) != collection.Dimensions {
+			middleware.AbortWithError(c, http.StatusBadRequest, models.ErrorInvalidRequest, "embedding_model "+collection.EmbeddingModel+
+				" does not return embeddings with the collection's dimensions")
+			return nil, false
+		}
+	}
+	return embeddings, true
+}
@@ -0,0 +1,311 @@
+// Package server assembles the gateway: the usage tracker, tracing and the proxy routes. The
+// gateway binary runs it, optionally next to the admin UI in combined mode.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/like-mike/relai-gateway/gateway/middleware"
+	"github.com/like-mike/relai-gateway/gateway/preflight"
+	"github.com/like-mike/relai-gateway/gateway/routes/batches"
+	"github.com/like-mike/relai-gateway/gateway/routes/debug"
+	"github.com/like-mike/relai-gateway/gateway/routes/errorcodes"
+	"github.com/like-mike/relai-gateway/gateway/routes/feedback"
+	"github.com/like-mike/relai-gateway/gateway/routes/health"
+	"github.com/like-mike/relai-gateway/gateway/routes/maintenance"
+	"github.com/like-mike/relai-gateway/gateway/routes/models"
+	"github.com/like-mike/relai-gateway/gateway/routes/proxy"
+	"github.com/like-mike/relai-gateway/gateway/routes/rates"
+	"github.com/like-mike/relai-gateway/gateway/routes/requests"
+	"github.com/like-mike/relai-gateway/gateway/routes/sessions"
+	"github.com/like-mike/relai-gateway/gateway/routes/tokens"
+	"github.com/like-mike/relai-gateway/gateway/routes/vectors"
+	"github.com/like-mike/relai-gateway/shared/hooks"
+	sharedmw "github.com/like-mike/relai-gateway/shared/middleware"
+	"github.com/like-mike/relai-gateway/shared/qos"
+	"github.com/like-mike/relai-gateway/shared/secretfile"
+	"github.com/like-mike/relai-gateway/shared/servicetoken"
+	"github.com/like-mike/relai-gateway/shared/tracer"
+	"github.com/like-mike/relai-gateway/shared/usage"
+	"github.com/like-mike/relai-gateway/shared/wasmpolicy"
+)
+
+// Port returns the port the gateway listens on, from GATEWAY_PORT
+func Port() string {
+	if port := os.Getenv("GATEWAY_PORT"); port != "" {
+		return port
+	}
+	return "8080"
+}
+
+// getUsageConfig returns usage tracking configuration from environment variables
+func getUsageConfig() *usage.WorkerConfig {
+	config := usage.DefaultWorkerConfig()
+
+	// Override with environment variables if set
+	if workerCountStr := os.Getenv("USAGE_WORKER_COUNT"); workerCountStr != "" {
+		if count, err := strconv.Atoi(workerCountStr); err == nil && count > 0 {
+			config.WorkerCount = count
+		}
+	}
+
+	if queueSizeStr := os.Getenv("USAGE_QUEUE_SIZE"); queueSizeStr != "" {
+		if size, err := strconv.Atoi(queueSizeStr); err == nil && size > 0 {
+			config.QueueSize = size
+		}
+	}
+
+	if maxRetriesStr := os.Getenv("USAGE_MAX_RETRIES"); maxRetriesStr != "" {
+		if retries, err := strconv.Atoi(maxRetriesStr); err == nil && retries >= 0 {
+			config.MaxRetries = retries
+		}
+	}
+
+	if retryDelayStr := os.Getenv("USAGE_RETRY_DELAY"); retryDelayStr != "" {
+		if delay, err := time.ParseDuration(retryDelayStr); err == nil {
+			config.RetryDelay = delay
+		}
+	}
+
+	// Check if usage tracking is disabled
+	if disabled := os.Getenv("USAGE_TRACKING_DISABLED"); disabled == "true" || disabled == "1" {
+		log.Println("Usage tracking disabled via environment variable")
+		config.WorkerCount = 0 // Disable workers
+	}
+
+	return config
+}
+
+// Start initializes tracing and the usage tracker, warms the tokenizers and fails batches left
+// mid-run by a previous process. The returned func flushes usage and shuts tracing down.
+func Start(conn *sql.DB) (stop func()) {
+	// Initialize OpenTelemetry tracer
+	tp := tracer.InitTracer()
+
+	// Pick up secrets rotated in their *_FILE mounts
+	stopSecretFiles := secretfile.Start()
+
+	// Initialize usage tracking
+	usageConfig := getUsageConfig()
+	usage.InitGlobalUsageTracker(conn, usageConfig)
+	log.Printf("Usage tracking initialized with %d workers", usageConfig.WorkerCount)
+
+	// Load tokenizers up front rather than on the first streamed request; the preflight
+	// builds them while checking them
+	usage.ConfigureEncoders()
+	if !preflight.Enabled() {
+		go usage.WarmEncoders()
+	}
+
+	// Batches left mid-run by a previous gateway process can't resume
+	batches.FailStale(conn)
+
+	// Repair quota counters that drifted from usage_logs
+	quotaReconciler := usage.NewQuotaReconcilerFromEnv(conn)
+	if quotaReconciler != nil {
+		quotaReconciler.Start()
+	}
+
+	// Share rate limit windows, concurrency slots and in-flight requests across replicas when Redis is configured
+	stopRedis := configureRedis()
+
+	// Create the semantic cache table when GATEWAY_SEMANTIC_CACHE_MODEL is set
+	stopSemanticCache := proxy.StartSemanticCache(conn)
+
+	// Expire idle conversation sessions when GATEWAY_SESSION_MEMORY is true
+	stopSessionMemory := proxy.StartSessionMemory(conn)
+
+	// Delegate authorization decisions to OPA when OPA_URL is set
+	stopAuthorization := proxy.StartAuthorization(conn)
+
+	// Load the deployment's policy hooks: Go plugins and a gRPC sidecar
+	stopHooks := hooks.LoadFromEnv()
+
+	// Run organizations' uploaded WASM policies when WASM_POLICIES_ENABLED is true
+	stopWasmPolicies := wasmpolicy.Start(conn)
+
+	// Create the vector collection tables when GATEWAY_VECTOR_STORE is true
+	vectors.Start(conn)
+
+	// /ready stays 503 until the database, models and tokenizers check out
+	preflightCtx, cancelPreflight := context.WithCancel(context.Background())
+	preflight.Start(preflightCtx, conn)
+
+	return func() {
+		cancelPreflight()
+		stopSemanticCache()
+		stopSessionMemory()
+		stopHooks()
+		stopWasmPolicies()
+		stopAuthorization()
+		stopSecretFiles()
+		if quotaReconciler != nil {
+			quotaReconciler.Stop()
+		}
+		stopRedis()
+		usage.StopGlobalUsageTracker()
+		if err := tp.Shutdown(context.Background()); err != nil {
+			log.Printf("Error shutting down tracer provider: %v", err)
+		}
+	}
+}
+
+// configureRedis connects to REDIS_URL, when set, and moves the per-key request limiter, the
+// GATEWAY_MAX_CONCURRENT_REQUESTS slots, the in-flight requests behind
+// DELETE /v1/requests/:request_id, debug traces and the IDs of used service tokens into it. Keys are prefixed with REDIS_KEY_PREFIX (default "relai:") so gateways
+// can share a Redis. The returned func disconnects.
+func configureRedis() (stop func()) {
+	redisURL := os.Getenv("REDIS_URL")
+	if redisURL == "" {
+		return func() {}
+	}
+
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		log.Printf("Warning: invalid REDIS_URL, rate limits, concurrency caps, in-flight requests, debug traces and used service tokens stay per-process: %v", err)
+		return func() {}
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		// The limiter falls back to local counting until Redis answers
+		log.Printf("Warning: Redis not reachable at startup: %v", err)
+	}
+
+	prefix := os.Getenv("REDIS_KEY_PREFIX")
+	if prefix == "" {
+		prefix = "relai:"
+	}
+	middleware.SetRequestLimiter(middleware.NewRedisRequestLimiter(client, prefix))
+	inflightStore := proxy.NewRedisInflightStore(client, prefix)
+	proxy.SetInflightStore(inflightStore)
+	stopListening := inflightStore.Listen()
+	proxy.SetDebugTraceStore(proxy.NewRedisDebugTraceStore(client, prefix))
+	servicetoken.SetReplayGuard(servicetoken.NewRedisReplayGuard(client, prefix))
+	middleware.SetQoSSlots(func(capacity int) qos.Slots { return qos.NewRedisSlots(client, prefix, capacity) })
+	log.Printf("Rate limits, concurrency caps, in-flight requests, debug traces and used service tokens shared through Redis at %s", opts.Addr)
+	return func() {
+		stopListening()
+		client.Close()
+	}
+}
+
+// NewRouter builds the gateway router with the OpenAI-compatible and custom endpoint routes
+func NewRouter(conn *sql.DB) *gin.Engine {
+	// Setup Gin router
+	r := gin.New()
+	if err := middleware.TrustProxies(r); err != nil {
+		log.Fatalf("Invalid GATEWAY_TRUSTED_PROXIES: %v", err)
+	}
+	r.Use(sharedmw.CORSMiddleware())
+	r.Use(middleware.RequestID())
+	r.Use(sharedmw.CustomLogger())
+	r.Use(gin.Recovery())
+
+	// Attach DB to Gin context
+	r.Use(sharedmw.DBMiddleware(conn))
+
+	// Static health check (no auth required)
+	r.GET("/health", health.Handler)
+	r.GET("/ready", health.ReadyHandler)
+
+	// Live rate counters, the usage stream and the read-only switch for the admin UI (requires GATEWAY_INTERNAL_TOKEN)
+	internal := r.Group("/internal", middleware.InternalAuth())
+	internal.GET("/rates", rates.Handler)
+	internal.GET("/usage/stream", rates.UsageStreamHandler)
+	internal.GET("/read-only", maintenance.ReadOnlyHandler)
+	internal.PUT("/read-only", maintenance.SetReadOnlyHandler)
+
+	// Callers blocked after using a canary key are refused everything below
+	r.Use(middleware.BlockedIPs())
+
+	// Prometheus and tracing
+	r.Use(sharedmw.PrometheusMiddleware())
+	r.Use(sharedmw.TracingMiddleware())
+
+	// Public model routes (optional auth - works with or without API key)
+	r.GET("/v1/models", middleware.OptionalAPIKeyAuth(), models.Handler)
+	r.GET("/models", middleware.OptionalAPIKeyAuth(), models.Handler)
+
+	// The error code catalog (no auth required)
+	r.GET("/v1/errors", errorcodes.Handler)
+
+	// Standard OpenAI API pass-through routes (requires API key from database)
+	api := r.Group("/v1")
+	api.Use(middleware.APIKeyAuth()) // Requires valid API key from database
+	api.Use(middleware.RateLimitHeaders())
+	{
+		// Standard OpenAI API endpoints, scheduled by QoS class when the gateway is saturated
+		scheduled := middleware.QoS()
+		api.POST("/chat/completions", scheduled, proxy.Handler)
+		api.POST("/completions", scheduled, proxy.Handler)
+		api.POST("/embeddings", scheduled, proxy.Handler)
+		api.POST("/moderations", scheduled, proxy.Handler)
+		api.POST("/images/generations", scheduled, proxy.Handler)
+		api.POST("/audio/transcriptions", scheduled, proxy.Handler)
+		api.POST("/audio/translations", scheduled, proxy.Handler)
+
+		// Short-lived tokens with narrower scopes, for browsers and mobile apps
+		api.POST("/auth/ephemeral", tokens.CreateEphemeralHandler)
+
+		// Response feedback (ratings feed satisfaction analytics and experiment reports)
+		api.POST("/feedback", middleware.RejectWrites(), feedback.Handler)
+
+		// Stop an in-flight request by its X-RelAI-Request-Id
+		api.DELETE("/requests/:request_id", requests.StopHandler)
+
+		// Debug trace of a request sent with X-RelAI-Debug, for keys with the debug scope
+		api.GET("/debug/:request_id", debug.TraceHandler)
+
+		// Batch API: upload a JSONL file, then run it as an asynchronous batch. Unlike the
+		// proxy routes these need the database, so they pause in read-only mode.
+		api.POST("/files", middleware.RejectWrites(), batches.UploadFileHandler)
+		api.GET("/files/:id", batches.GetFileHandler)
+		api.GET("/files/:id/content", batches.FileContentHandler)
+		api.POST("/batches", middleware.RejectWrites(), batches.CreateBatchHandler)
+		api.GET("/batches", batches.ListBatchesHandler)
+		api.GET("/batches/:id", batches.GetBatchHandler)
+		api.POST("/batches/:id/cancel", middleware.RejectWrites(), batches.CancelBatchHandler)
+
+		// Conversation history kept for X-RelAI-Session-Id
+		if proxy.SessionMemoryEnabled() {
+			api.GET("/sessions/:id", sessions.GetHandler)
+			api.DELETE("/sessions/:id", middleware.RejectWrites(), sessions.DeleteHandler)
+		}
+
+		// Vector collections: governed storage and retrieval for the organization's embeddings
+		if vectors.Enabled() {
+			api.GET("/vector/collections", vectors.ListCollectionsHandler)
+			api.POST("/vector/collections", middleware.RejectWrites(), vectors.CreateCollectionHandler)
+			api.GET("/vector/collections/:name", vectors.GetCollectionHandler)
+			api.DELETE("/vector/collections/:name", middleware.RejectWrites(), vectors.DeleteCollectionHandler)
+			api.POST("/vector/collections/:name/documents", middleware.RejectWrites(), vectors.UpsertDocumentsHandler)
+			api.DELETE("/vector/collections/:name/documents/:id", middleware.RejectWrites(), vectors.DeleteDocumentHandler)
+			api.POST("/vector/collections/:name/query", vectors.QueryHandler)
+		}
+	}
+
+	// Protected routes group (requires API key authentication)
+	protected := r.Group("/")
+	protected.Use(middleware.APIKeyAuth())
+	{
+		// Removed completions proxy endpoint registration
+		// Add any other protected endpoints here in the future
+	}
+
+	// Custom endpoints and catch-all - requires API key from database
+	// This handles both custom organization endpoints and any other API calls
+	r.NoRoute(middleware.APIKeyAuth(), middleware.RateLimitHeaders(), middleware.QoS(), proxy.Handler)
+
+	return r
+}
@@ -0,0 +1,136 @@
+package awsauth
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	assumeRoleDuration = time.Hour
+	// refreshBefore renews temporary credentials this long before they expire
+	refreshBefore = 5 * time.Minute
+)
+
+// Config describes how to obtain credentials: a static key pair (or the AWS_* environment
+// variables when none is given), optionally exchanged for a role via STS AssumeRole
+type Config struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	RoleARN         string
+}
+
+// EnvironmentCredentials reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+func EnvironmentCredentials() (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("no AWS credentials configured on the model or in the environment")
+	}
+	return creds, nil
+}
+
+func (cfg Config) baseCredentials() (Credentials, error) {
+	if cfg.AccessKeyID != "" && cfg.SecretAccessKey != "" {
+		return Credentials{AccessKeyID: cfg.AccessKeyID, SecretAccessKey: cfg.SecretAccessKey}, nil
+	}
+	return EnvironmentCredentials()
+}
+
+// roleCache keeps assumed-role credentials per base key and role until shortly before expiry
+var roleCache = struct {
+	sync.Mutex
+	entries map[string]Credentials
+}{entries: map[string]Credentials{}}
+
+// Retrieve returns credentials for signing, assuming the configured role if there is one
+func (cfg Config) Retrieve() (Credentials, error) {
+	base, err := cfg.baseCredentials()
+	if err != nil || cfg.RoleARN == "" {
+		return base, err
+	}
+
+	cacheKey := base.AccessKeyID + "|" + cfg.RoleARN
+	roleCache.Lock()
+	cached, ok := roleCache.entries[cacheKey]
+	roleCache.Unlock()
+	if ok && time.Until(cached.Expires) > refreshBefore {
+		return cached, nil
+	}
+
+	creds, err := assumeRole(base, cfg.Region, cfg.RoleARN)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	roleCache.Lock()
+	roleCache.entries[cacheKey] = creds
+	roleCache.Unlock()
+	return creds, nil
+}
+
+type assumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+var stsClient = &http.Client{Timeout: 15 * time.Second}
+
+// assumeRole exchanges base credentials for temporary role credentials via the regional STS
+// endpoint
+func assumeRole(base Credentials, region, roleARN string) (Credentials, error) {
+	form := url.Values{}
+	form.Set("Action", "AssumeRole")
+	form.Set("Version", "2011-06-15")
+	form.Set("RoleArn", roleARN)
+	form.Set("RoleSessionName", "relai-gateway")
+	form.Set("DurationSeconds", fmt.Sprintf("%d", int(assumeRoleDuration.Seconds())))
+
+	endpoint := fmt.Sprintf("https://sts.%s.amazonaws.com/", region)
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if err := Sign(req, base, region, "sts", time.Now()); err != nil {
+		return Credentials{}, err
+	}
+
+	resp, err := stsClient.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("sts assume role: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("sts assume role: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("sts assume role %s: status %d: %s", roleARN, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed assumeRoleResponse
+	if err := xml.Unmarshal(body, &parsed); err != nil {
+		return Credentials{}, fmt.Errorf("sts assume role: invalid response: %w", err)
+	}
+	c := parsed.Credentials
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("sts assume role: response has no credentials")
+	}
+	return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken, Expires: c.Expiration}, nil
+}
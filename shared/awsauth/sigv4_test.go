@@ -0,0 +1,54 @@
+package awsauth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Cases from the AWS SigV4 test suite
+var testCreds = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+func TestSign(t *testing.T) {
+	cases := []struct {
+		name, url, signature string
+	}{
+		{"get-vanilla", "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+		if err := Sign(req, testCreds, "us-east-1", "service", now); err != nil {
+			t.Fatalf("%s: Sign() error = %v", tc.name, err)
+		}
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tc.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("%s: Authorization = %q, want %q", tc.name, got, want)
+		}
+	}
+}
+
+func TestSignSessionToken(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://bedrock-runtime.us-east-1.amazonaws.com/model/x/invoke", strings.NewReader("{}"))
+	creds := testCreds
+	creds.SessionToken = "token"
+	if err := Sign(req, creds, "us-east-1", "bedrock", time.Now()); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Error("expected the session token header")
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("expected the session token to be signed: %s", req.Header.Get("Authorization"))
+	}
+}
+
+func TestEncodePath(t *testing.T) {
+	got := EncodePath("/model/anthropic.claude-3-sonnet-20240229-v1:0/invoke")
+	if want := "/model/anthropic.claude-3-sonnet-20240229-v1%3A0/invoke"; got != want {
+		t.Errorf("EncodePath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package awsauth
+
+import (
+	"net/http"
+	"time"
+)
+
+// Transport signs every outgoing request with SigV4 before handing it to Base. Each retry is
+// signed afresh, so signatures never go stale.
+type Transport struct {
+	Base    http.RoundTripper
+	Config  Config
+	Service string // e.g. "bedrock"
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	creds, err := t.Config.Retrieve()
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the caller's request
+	signed := req.Clone(req.Context())
+	signed.URL.RawPath = EncodePath(signed.URL.Path)
+	if err := Sign(signed, creds, t.Config.Region, t.Service, time.Now()); err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(signed)
+}
@@ -0,0 +1,134 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// archiveMagic starts every archive file and names its format version
+const archiveMagic = "RELAIBK1"
+
+const (
+	saltSize = 16
+	// scrypt cost parameters, per the package's recommendation for interactive use
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	// ErrNotArchive is returned for files that aren't gateway backup archives
+	ErrNotArchive = errors.New("not a gateway backup archive")
+	// ErrWrongPassphrase is returned when an archive can't be decrypted with the passphrase
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted archive")
+)
+
+// Archive is the decrypted content of a backup file
+type Archive struct {
+	CreatedAt time.Time   `json:"created_at"`
+	Sections  []string    `json:"sections"`
+	Tables    []TableDump `json:"tables"`
+}
+
+// TableDump is one table's rows, each a JSON object keyed by column name
+type TableDump struct {
+	Name string            `json:"name"`
+	Rows []json.RawMessage `json:"rows"`
+}
+
+// Seal compresses the archive and encrypts it with AES-256-GCM under a key derived from the
+// passphrase with scrypt
+func Seal(archive *Archive, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("a passphrase is required")
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if err := json.NewEncoder(zw).Encode(archive); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := archiveCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(archiveMagic)+saltSize+len(nonce)+compressed.Len()+gcm.Overhead())
+	out = append(out, archiveMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	// The header is authenticated too, so it can't be swapped onto another archive
+	return gcm.Seal(out, nonce, compressed.Bytes(), out[:len(out):len(out)]), nil
+}
+
+// Open decrypts and decompresses an archive produced by Seal
+func Open(data []byte, passphrase string) (*Archive, error) {
+	if len(data) < len(archiveMagic)+saltSize || string(data[:len(archiveMagic)]) != archiveMagic {
+		return nil, ErrNotArchive
+	}
+	salt := data[len(archiveMagic) : len(archiveMagic)+saltSize]
+
+	gcm, err := archiveCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	headerSize := len(archiveMagic) + saltSize + gcm.NonceSize()
+	if len(data) < headerSize {
+		return nil, ErrNotArchive
+	}
+	nonce := data[len(archiveMagic)+saltSize : headerSize]
+
+	compressed, err := gcm.Open(nil, nonce, data[headerSize:], data[:headerSize])
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive Archive
+	if err := json.Unmarshal(plain, &archive); err != nil {
+		return nil, fmt.Errorf("failed to decode archive: %w", err)
+	}
+	return &archive, nil
+}
+
+func archiveCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
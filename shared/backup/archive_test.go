@@ -0,0 +1,58 @@
+package backup
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSealOpen(t *testing.T) {
+	archive := &Archive{
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Sections:  []string{"models"},
+		Tables: []TableDump{{
+			Name: "models",
+			Rows: []json.RawMessage{json.RawMessage(`{"id":"m1","aws_secret_access_key":"secret"}`)},
+		}},
+	}
+
+	sealed, err := Seal(archive, "correct horse")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	got, err := Open(sealed, "correct horse")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(archive.CreatedAt) || len(got.Tables) != 1 || got.Tables[0].Name != "models" {
+		t.Errorf("Open() = %+v", got)
+	}
+	if string(got.Tables[0].Rows[0]) != string(archive.Tables[0].Rows[0]) {
+		t.Errorf("row = %s", got.Tables[0].Rows[0])
+	}
+
+	if _, err := Open(sealed, "wrong"); err != ErrWrongPassphrase {
+		t.Errorf("Open() with wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := Open([]byte("{}"), "correct horse"); err != ErrNotArchive {
+		t.Errorf("Open() of non-archive: err = %v, want ErrNotArchive", err)
+	}
+	if _, err := Seal(archive, ""); err == nil {
+		t.Error("Seal() with empty passphrase succeeded")
+	}
+}
+
+func TestTablesFor(t *testing.T) {
+	selected, err := tablesFor([]string{"keys"})
+	if err != nil {
+		t.Fatalf("tablesFor() error = %v", err)
+	}
+	if len(selected) != 1 || selected[0].name != "api_keys" {
+		t.Errorf("tablesFor(keys) = %+v", selected)
+	}
+
+	if _, err := tablesFor([]string{"keys", "payloads"}); err == nil {
+		t.Error("tablesFor() with unknown section succeeded")
+	}
+}
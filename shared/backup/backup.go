@@ -0,0 +1,298 @@
+package backup
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+)
+
+// Dump reads the tables of the given sections (all when empty) into an archive. Tables missing
+// from this database are skipped. Sealed secrets are decrypted with ENCRYPTION_KEY.
+func Dump(conn *sql.DB, sections []string) (*Archive, error) {
+	selected, err := tablesFor(sections)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &Archive{CreatedAt: time.Now().UTC(), Sections: sections}
+	if len(sections) == 0 {
+		archive.Sections = Sections()
+	}
+
+	// One snapshot, so rows referencing each other are consistent
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`); err != nil {
+		return nil, err
+	}
+
+	for _, t := range selected {
+		exists, err := tableExists(tx, t.name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		rows, err := dumpTable(tx, t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", t.name, err)
+		}
+		archive.Tables = append(archive.Tables, TableDump{Name: t.name, Rows: rows})
+	}
+	return archive, nil
+}
+
+func dumpTable(tx *sql.Tx, t table) ([]json.RawMessage, error) {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT row_to_json(t)::text FROM %s t`, pq.QuoteIdentifier(t.name)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dumped := []json.RawMessage{}
+	for rows.Next() {
+		var row string
+		if err := rows.Scan(&row); err != nil {
+			return nil, err
+		}
+		raw := json.RawMessage(row)
+		if len(t.sealed) > 0 {
+			if raw, err = transformSealed(raw, t.sealed, encryption.Decrypt); err != nil {
+				return nil, err
+			}
+		}
+		dumped = append(dumped, raw)
+	}
+	return dumped, rows.Err()
+}
+
+// transformSealed applies fn (Decrypt or Encrypt) to the row's non-empty sealed columns
+func transformSealed(raw json.RawMessage, columns []string, fn func(string) (string, error)) (json.RawMessage, error) {
+	var row map[string]interface{}
+	if err := json.Unmarshal(raw, &row); err != nil {
+		return nil, err
+	}
+	for _, column := range columns {
+		value, ok := row[column].(string)
+		if !ok || value == "" {
+			continue
+		}
+		transformed, err := fn(value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		row[column] = transformed
+	}
+	return json.Marshal(row)
+}
+
+// TableResult is how many rows of a table a restore wrote
+type TableResult struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+}
+
+// Restore writes the archive's tables of the given sections (all when empty) back, in one
+// transaction: rows are inserted, or updated when a row with the same primary key exists, and
+// rows not in the archive are left alone. Sealed secrets are encrypted with this database's
+// ENCRYPTION_KEY. With dryRun the transaction is rolled back after counting.
+func Restore(conn *sql.DB, archive *Archive, sections []string, dryRun bool) ([]TableResult, error) {
+	selected, err := tablesFor(sections)
+	if err != nil {
+		return nil, err
+	}
+	dumps := make(map[string]TableDump, len(archive.Tables))
+	for _, d := range archive.Tables {
+		dumps[d.Name] = d
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var results []TableResult
+	for _, t := range selected {
+		dump, ok := dumps[t.name]
+		if !ok {
+			continue
+		}
+		exists, err := tableExists(tx, t.name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("table %s is in the archive but not in this database; run `relai-admin schema apply` first", t.name)
+		}
+
+		if err := restoreTable(tx, t, dump.Rows); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", t.name, err)
+		}
+		results = append(results, TableResult{Name: t.name, Rows: len(dump.Rows)})
+	}
+
+	if dryRun {
+		return results, nil
+	}
+	return results, tx.Commit()
+}
+
+func restoreTable(tx *sql.Tx, t table, rows []json.RawMessage) error {
+	columns, primaryKey, err := tableColumns(tx, t.name)
+	if err != nil {
+		return err
+	}
+	if len(primaryKey) == 0 {
+		return fmt.Errorf("table has no primary key")
+	}
+	deferred := map[string]bool{}
+	for _, c := range t.deferred {
+		deferred[c] = true
+	}
+
+	quotedTable := pq.QuoteIdentifier(t.name)
+	if t.replace {
+		if _, err := tx.Exec(`DELETE FROM ` + quotedTable); err != nil {
+			return err
+		}
+	}
+
+	var clearNaturalKey string
+	if len(t.naturalKey) > 0 {
+		var match, differs []string
+		for _, c := range t.naturalKey {
+			match = append(match, fmt.Sprintf("%s.%s = r.%s", quotedTable, pq.QuoteIdentifier(c), pq.QuoteIdentifier(c)))
+		}
+		for _, pk := range primaryKey {
+			differs = append(differs, fmt.Sprintf("%s.%s IS DISTINCT FROM r.%s", quotedTable, pq.QuoteIdentifier(pk), pq.QuoteIdentifier(pk)))
+		}
+		clearNaturalKey = fmt.Sprintf(`DELETE FROM %s USING json_populate_record(NULL::%s, $1::json) r WHERE %s AND (%s)`,
+			quotedTable, quotedTable, strings.Join(match, " AND "), strings.Join(differs, " OR "))
+	}
+
+	for _, raw := range rows {
+		if len(t.sealed) > 0 {
+			var err error
+			if raw, err = transformSealed(raw, t.sealed, encryption.Encrypt); err != nil {
+				return err
+			}
+		}
+
+		// Only the columns both the archive and this schema have, so archives from older or
+		// newer gateways restore with defaults for the difference
+		var present map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &present); err != nil {
+			return err
+		}
+		var insert, update []string
+		for _, c := range columns {
+			if _, ok := present[c]; !ok || deferred[c] {
+				continue
+			}
+			insert = append(insert, pq.QuoteIdentifier(c))
+			if !contains(primaryKey, c) {
+				update = append(update, fmt.Sprintf("%s = EXCLUDED.%s", pq.QuoteIdentifier(c), pq.QuoteIdentifier(c)))
+			}
+		}
+		if len(insert) == 0 {
+			continue
+		}
+
+		if clearNaturalKey != "" {
+			if _, err := tx.Exec(clearNaturalKey, string(raw)); err != nil {
+				return err
+			}
+		}
+
+		conflict := "DO NOTHING"
+		if len(update) > 0 {
+			conflict = "DO UPDATE SET " + strings.Join(update, ", ")
+		}
+		query := fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM json_populate_record(NULL::%s, $1::json) ON CONFLICT (%s) %s`,
+			quotedTable, strings.Join(insert, ", "), strings.Join(insert, ", "), quotedTable,
+			strings.Join(quoteAll(primaryKey), ", "), conflict)
+		if _, err := tx.Exec(query, string(raw)); err != nil {
+			return err
+		}
+	}
+
+	// Self-references, now that every row they can point to exists
+	for _, c := range t.deferred {
+		if !contains(columns, c) {
+			continue
+		}
+		var match []string
+		for _, pk := range primaryKey {
+			match = append(match, fmt.Sprintf("%s.%s = r.%s", quotedTable, pq.QuoteIdentifier(pk), pq.QuoteIdentifier(pk)))
+		}
+		query := fmt.Sprintf(`UPDATE %s SET %s = r.%s FROM json_populate_record(NULL::%s, $1::json) r WHERE %s`,
+			quotedTable, pq.QuoteIdentifier(c), pq.QuoteIdentifier(c), quotedTable, strings.Join(match, " AND "))
+		for _, raw := range rows {
+			if _, err := tx.Exec(query, string(raw)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tableColumns returns a table's columns and its primary key columns
+func tableColumns(tx *sql.Tx, name string) (columns, primaryKey []string, err error) {
+	rows, err := tx.Query(`
+		SELECT a.attname, COALESCE(a.attnum = ANY(i.indkey), false)
+		FROM pg_attribute a
+		LEFT JOIN pg_index i ON i.indrelid = a.attrelid AND i.indisprimary
+		WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column string
+		var isKey bool
+		if err := rows.Scan(&column, &isKey); err != nil {
+			return nil, nil, err
+		}
+		columns = append(columns, column)
+		if isKey {
+			primaryKey = append(primaryKey, column)
+		}
+	}
+	return columns, primaryKey, rows.Err()
+}
+
+func tableExists(tx *sql.Tx, name string) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists)
+	return exists, err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = pq.QuoteIdentifier(n)
+	}
+	return quoted
+}
@@ -0,0 +1,115 @@
+// Package backup dumps the gateway's configuration tables into an encrypted archive and
+// restores them, for disaster recovery or moving a gateway to a new database. Usage logs,
+// payloads and other traffic history are not included.
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// table is one table in the archive
+type table struct {
+	name    string
+	section string
+	// sealed columns are encrypted with ENCRYPTION_KEY in the database. They are stored in
+	// the archive in the clear (the archive itself is encrypted) and sealed again with the
+	// restoring gateway's key, so a restore doesn't depend on the old key.
+	sealed []string
+	// deferred columns reference rows of the same table; they are set after all of its rows
+	// are in, so rows can be restored in any order
+	deferred []string
+	// naturalKey is a unique key other than the primary key. A row already holding the same
+	// natural key under another ID (such as one seeded by a fresh schema) is replaced.
+	naturalKey []string
+	// replace clears the table before restoring it; for tables without any unique key other
+	// than the ID, where seeded rows would otherwise be duplicated
+	replace bool
+}
+
+// tables lists the configuration tables in restore order: every table comes after the tables
+// it references
+var tables = []table{
+	{name: "users", section: "users"},
+	{name: "roles", section: "users", naturalKey: []string{"name"}},
+	{name: "user_system_roles", section: "users", naturalKey: []string{"user_id", "role_id"}},
+	{name: "system_ad_groups", section: "users", naturalKey: []string{"ad_group_id"}},
+
+	{name: "organizations", section: "orgs", deferred: []string{"parent_id"}},
+	{name: "user_organizations", section: "orgs", naturalKey: []string{"user_id", "organization_id"}},
+	{name: "organization_quotas", section: "orgs", naturalKey: []string{"organization_id"}},
+	{name: "organization_roles", section: "orgs", naturalKey: []string{"organization_id", "name"}},
+	{name: "organization_ad_groups", section: "orgs", naturalKey: []string{"organization_id", "ad_group_id", "role_type"}},
+	{name: "organization_branding", section: "orgs"},
+	{name: "projects", section: "orgs"},
+	{name: "secret_scan_policies", section: "orgs"},
+	{name: "wasm_policies", section: "orgs"},
+	{name: "quota_notification_settings", section: "orgs"},
+	{name: "notification_channels", section: "orgs"},
+
+	{name: "models", section: "models", sealed: []string{"aws_secret_access_key", "gcp_service_account"}},
+	{name: "model_organization_access", section: "models", naturalKey: []string{"model_id", "organization_id"}},
+	{name: "model_organization_access_denials", section: "models", naturalKey: []string{"model_id", "organization_id"}},
+
+	{name: "api_keys", section: "keys", naturalKey: []string{"api_key"}},
+
+	{name: "endpoints", section: "endpoints"},
+	{name: "response_schemas", section: "endpoints"},
+	{name: "experiments", section: "endpoints"},
+	{name: "experiment_variants", section: "endpoints"},
+
+	{name: "email_settings", section: "email"},
+	{name: "email_templates", section: "email"},
+	{name: "email_template_versions", section: "email", naturalKey: []string{"template_id", "version"}},
+	{name: "email_schedules", section: "email", replace: true},
+	{name: "dkim_keys", section: "email", sealed: []string{"private_key"}, naturalKey: []string{"domain"}},
+}
+
+// Sections returns the names tables are grouped under for selective backup and restore
+func Sections() []string {
+	seen := map[string]bool{}
+	var sections []string
+	for _, t := range tables {
+		if !seen[t.section] {
+			seen[t.section] = true
+			sections = append(sections, t.section)
+		}
+	}
+	return sections
+}
+
+// tablesFor returns the tables of the given sections in restore order; no sections means all
+func tablesFor(sections []string) ([]table, error) {
+	if len(sections) == 0 {
+		return tables, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, s := range sections {
+		wanted[s] = true
+	}
+	for _, s := range Sections() {
+		delete(wanted, s)
+	}
+	if len(wanted) > 0 {
+		var unknown []string
+		for s := range wanted {
+			unknown = append(unknown, s)
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown section %s (known: %s)", strings.Join(unknown, ", "), strings.Join(Sections(), ", "))
+	}
+
+	selected := map[string]bool{}
+	for _, s := range sections {
+		selected[s] = true
+	}
+	var result []table
+	for _, t := range tables {
+		if selected[t.section] {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
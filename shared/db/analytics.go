@@ -3,8 +3,11 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/like-mike/relai-gateway/shared/models"
 )
 
@@ -24,7 +27,7 @@ func GetDashboardMetrics(db *sql.DB, filter models.AnalyticsFilter) (*models.Das
 			COALESCE(SUM(cost_usd), 0) as total_cost
 		FROM usage_logs
 		WHERE created_at >= $1
-		  AND ($2 = '' OR organization_id = $2::uuid)`
+		  AND ` + organizationFilter("organization_id", filter)
 
 	var metrics models.DashboardMetrics
 	err = db.QueryRow(query, startTime, filter.Organization).Scan(
@@ -66,7 +69,7 @@ func GetDailyCostTrend(db *sql.DB, filter models.AnalyticsFilter) ([]models.Dail
 				COUNT(*) as daily_requests
 			FROM usage_logs
 			WHERE created_at >= $1
-			  AND ($2 = '' OR organization_id = $2::uuid)
+			  AND ` + organizationFilter("organization_id", filter) + `
 			GROUP BY DATE_TRUNC('hour', created_at)
 			ORDER BY DATE_TRUNC('hour', created_at)`
 	default:
@@ -78,7 +81,7 @@ func GetDailyCostTrend(db *sql.DB, filter models.AnalyticsFilter) ([]models.Dail
 				COUNT(*) as daily_requests
 			FROM usage_logs
 			WHERE created_at >= $1
-			  AND ($2 = '' OR organization_id = $2::uuid)
+			  AND ` + organizationFilter("organization_id", filter) + `
 			GROUP BY DATE(created_at)
 			ORDER BY DATE(created_at)`
 	}
@@ -117,7 +120,7 @@ func GetTopModelsBySpend(db *sql.DB, filter models.AnalyticsFilter, limit int) (
 		FROM usage_logs ul
 		JOIN models m ON ul.model_id = m.id
 		WHERE ul.created_at >= $1
-		  AND ($2 = '' OR ul.organization_id = $2::uuid)
+		  AND ` + organizationFilter("ul.organization_id", filter) + `
 		GROUP BY m.id, m.name, m.model_id
 		ORDER BY total_cost DESC
 		LIMIT $3`
@@ -156,7 +159,7 @@ func GetTopAPIKeysBySpend(db *sql.DB, filter models.AnalyticsFilter, limit int)
 		FROM usage_logs ul
 		JOIN api_keys ak ON ul.api_key_id = ak.id
 		WHERE ul.created_at >= $1
-		  AND ($2 = '' OR ul.organization_id = $2::uuid)
+		  AND ` + organizationFilter("ul.organization_id", filter) + `
 		GROUP BY ak.id, ak.name
 		ORDER BY total_cost DESC
 		LIMIT $3`
@@ -193,7 +196,7 @@ func GetProviderSpendBreakdown(db *sql.DB, filter models.AnalyticsFilter) ([]mod
 		FROM usage_logs ul
 		JOIN models m ON ul.model_id = m.id
 		WHERE ul.created_at >= $1
-		  AND ($2 = '' OR ul.organization_id = $2::uuid)`
+		  AND ` + organizationFilter("ul.organization_id", filter)
 
 	err = db.QueryRow(totalQuery, startTime, filter.Organization).Scan(&totalSpend)
 	if err != nil {
@@ -208,7 +211,7 @@ func GetProviderSpendBreakdown(db *sql.DB, filter models.AnalyticsFilter) ([]mod
 		FROM usage_logs ul
 		JOIN models m ON ul.model_id = m.id
 		WHERE ul.created_at >= $1
-		  AND ($2 = '' OR ul.organization_id = $2::uuid)
+		  AND ` + organizationFilter("ul.organization_id", filter) + `
 		GROUP BY m.provider
 		ORDER BY total_cost DESC`
 
@@ -237,6 +240,83 @@ func GetProviderSpendBreakdown(db *sql.DB, filter models.AnalyticsFilter) ([]mod
 	return providerSpend, nil
 }
 
+// GetProjectSpendBreakdown groups usage by the project of the key that made it
+func GetProjectSpendBreakdown(db *sql.DB, filter models.AnalyticsFilter) ([]models.ProjectSpendData, error) {
+	startTime, err := parseTimeRange(filter.TimeRange, filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			COALESCE(p.id::text, '') as project_id,
+			COALESCE(p.name, 'No project') as name,
+			COALESCE(SUM(ul.total_tokens), 0) as total_tokens,
+			COALESCE(SUM(ul.cost_usd), 0) as total_cost,
+			COUNT(ul.id) as request_count
+		FROM usage_logs ul
+		JOIN api_keys ak ON ul.api_key_id = ak.id
+		LEFT JOIN projects p ON ak.project_id = p.id
+		WHERE ul.created_at >= $1
+		  AND ` + organizationFilter("ul.organization_id", filter) + `
+		GROUP BY p.id, p.name
+		ORDER BY total_cost DESC`
+
+	rows, err := db.Query(query, startTime, filter.Organization)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projectSpend := []models.ProjectSpendData{}
+	for rows.Next() {
+		var project models.ProjectSpendData
+		err := rows.Scan(&project.ProjectID, &project.Name, &project.TotalTokens, &project.TotalCost, &project.RequestCount)
+		if err != nil {
+			return nil, err
+		}
+		projectSpend = append(projectSpend, project)
+	}
+
+	return projectSpend, rows.Err()
+}
+
+// organizationFilter matches rows of the organization in $2, or every organization when $2 is
+// empty. With IncludeChildren it also matches the organization's sub-teams, rolling analytics up
+// the hierarchy. With Models set it also narrows the rows to those models, read from the
+// model_id column next to the organization column.
+func organizationFilter(column string, filter models.AnalyticsFilter) string {
+	clause := fmt.Sprintf("($2 = '' OR %s = $2::uuid)", column)
+	if filter.IncludeChildren {
+		clause = fmt.Sprintf(`($2 = '' OR %s IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM organizations WHERE id = $2::uuid
+				UNION
+				SELECT o.id FROM organizations o JOIN subtree s ON o.parent_id = s.id
+			)
+			SELECT id FROM subtree))`, column)
+	}
+	return clause + modelFilter(strings.TrimSuffix(column, "organization_id")+"model_id", filter.Models)
+}
+
+// modelFilter restricts column to the given model IDs. Only IDs that parse as UUIDs are inlined,
+// so the list can't carry SQL; with none valid the filter matches nothing rather than everything.
+func modelFilter(column string, modelIDs []string) string {
+	if len(modelIDs) == 0 {
+		return ""
+	}
+	var ids []string
+	for _, id := range modelIDs {
+		if parsed, err := uuid.Parse(id); err == nil {
+			ids = append(ids, "'"+parsed.String()+"'")
+		}
+	}
+	if len(ids) == 0 {
+		return " AND false"
+	}
+	return fmt.Sprintf(" AND %s IN (%s)", column, strings.Join(ids, ", "))
+}
+
 func parseTimeRange(timeRange, startDate string) (time.Time, error) {
 	now := time.Now()
 
@@ -260,3 +340,331 @@ func parseTimeRange(timeRange, startDate string) (time.Time, error) {
 		return now.Add(-7 * 24 * time.Hour), nil // Default to 7 days
 	}
 }
+
+// GetAPIKeyUsage returns a key's totals, an hourly (for ranges up to a day) or daily series,
+// and its top models by spend
+func GetAPIKeyUsage(db *sql.DB, key *models.APIKey, filter models.AnalyticsFilter, topModels int) (*models.KeyUsage, error) {
+	startTime, err := parseTimeRange(filter.TimeRange, filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := `DATE(created_at)::text`
+	switch filter.TimeRange {
+	case "6h", "12h", "24h":
+		bucket = `TO_CHAR(DATE_TRUNC('hour', created_at), 'YYYY-MM-DD HH24:00')`
+	}
+
+	usage := &models.KeyUsage{
+		APIKeyID:       key.ID,
+		Name:           key.Name,
+		KeyPrefix:      key.KeyPrefix,
+		OrganizationID: key.OrganizationID,
+		Series:         []models.KeyUsagePoint{},
+		TopModels:      []models.TopModelData{},
+		TimeRange:      filter.TimeRange,
+		GeneratedAt:    time.Now(),
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			`+bucket+` as date,
+			COUNT(*) as requests,
+			COALESCE(SUM(total_tokens), 0) as tokens,
+			COALESCE(SUM(cost_usd), 0) as cost,
+			COUNT(CASE WHEN response_status >= 400 THEN 1 END) as errors
+		FROM usage_logs
+		WHERE api_key_id = $1 AND created_at >= $2
+		GROUP BY 1
+		ORDER BY 1`, key.ID, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.KeyUsagePoint
+		if err := rows.Scan(&p.Date, &p.Requests, &p.Tokens, &p.Cost, &p.Errors); err != nil {
+			return nil, err
+		}
+		if p.Requests > 0 {
+			p.ErrorRate = float64(p.Errors) / float64(p.Requests) * 100
+		}
+		usage.Series = append(usage.Series, p)
+
+		usage.Requests += p.Requests
+		usage.Tokens += p.Tokens
+		usage.Cost += p.Cost
+		usage.Errors += p.Errors
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if usage.Requests > 0 {
+		usage.ErrorRate = float64(usage.Errors) / float64(usage.Requests) * 100
+	}
+
+	modelRows, err := db.Query(`
+		SELECT
+			m.name,
+			m.model_id,
+			COALESCE(SUM(ul.cost_usd), 0) as total_cost,
+			COUNT(ul.id) as request_count
+		FROM usage_logs ul
+		JOIN models m ON ul.model_id = m.id
+		WHERE ul.api_key_id = $1 AND ul.created_at >= $2
+		GROUP BY m.id, m.name, m.model_id
+		ORDER BY request_count DESC, total_cost DESC
+		LIMIT $3`, key.ID, startTime, topModels)
+	if err != nil {
+		return nil, err
+	}
+	defer modelRows.Close()
+
+	for modelRows.Next() {
+		var model models.TopModelData
+		if err := modelRows.Scan(&model.Name, &model.ModelID, &model.TotalCost, &model.RequestCount); err != nil {
+			return nil, err
+		}
+		usage.TopModels = append(usage.TopModels, model)
+	}
+
+	return usage, modelRows.Err()
+}
+
+// GetDailySpend returns cost and tokens per day since the given time, for the organization and
+// its sub-teams (whose usage counts against its quota), or for every organization when orgID is
+// empty. Days without usage are omitted.
+func GetDailySpend(db *sql.DB, orgID string, since time.Time) ([]models.DailySpend, error) {
+	filter := models.AnalyticsFilter{Organization: orgID, IncludeChildren: true}
+	query := `
+		SELECT DATE(created_at) AS day,
+		       COALESCE(SUM(cost_usd), 0),
+		       COALESCE(SUM(total_tokens), 0)
+		FROM usage_logs
+		WHERE created_at >= $1
+		  AND ` + organizationFilter("organization_id", filter) + `
+		GROUP BY DATE(created_at)
+		ORDER BY day`
+
+	rows, err := db.Query(query, since, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []models.DailySpend
+	for rows.Next() {
+		var day models.DailySpend
+		if err := rows.Scan(&day.Date, &day.Cost, &day.Tokens); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// endpointJoinSQL matches usage logs to the custom endpoint whose /api/{path_prefix} route
+// served them
+const endpointJoinSQL = `
+		LEFT JOIN endpoints e ON e.organization_id = ul.organization_id
+		     AND (ul.endpoint = '/api/' || e.path_prefix OR ul.endpoint LIKE '/api/' || e.path_prefix || '/%')`
+
+// endpointGroupSQL names each usage log's group for the grouping
+func endpointGroupSQL(grouping string) string {
+	if grouping == models.EndpointGroupCustomEndpoint {
+		return "COALESCE(e.name, ul.endpoint)"
+	}
+	return "ul.endpoint"
+}
+
+// GetEndpointSpendBreakdown groups spend by request path or, with the custom_endpoint grouping,
+// by the custom endpoint that served it
+func GetEndpointSpendBreakdown(db *sql.DB, filter models.AnalyticsFilter, grouping string, limit int) ([]models.EndpointSpendData, error) {
+	startTime, err := parseTimeRange(filter.TimeRange, filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	group := endpointGroupSQL(grouping)
+	query := `
+		WITH grouped AS (
+			SELECT ` + group + ` AS name,
+			       BOOL_OR(e.id IS NOT NULL) AS custom_endpoint,
+			       COALESCE(SUM(ul.total_tokens), 0) AS total_tokens,
+			       COALESCE(SUM(ul.cost_usd), 0) AS total_cost,
+			       COUNT(ul.id) AS request_count
+			FROM usage_logs ul` + endpointJoinSQL + `
+			WHERE ul.created_at >= $1
+			  AND ul.endpoint IS NOT NULL
+			  AND ` + organizationFilter("ul.organization_id", filter) + `
+			GROUP BY 1
+		)
+		SELECT name, custom_endpoint, total_tokens, total_cost, request_count,
+		       COALESCE(total_cost / NULLIF(SUM(total_cost) OVER (), 0) * 100, 0)
+		FROM grouped
+		ORDER BY total_cost DESC, request_count DESC
+		LIMIT $3`
+
+	rows, err := db.Query(query, startTime, filter.Organization, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spend []models.EndpointSpendData
+	for rows.Next() {
+		var data models.EndpointSpendData
+		if err := rows.Scan(&data.Name, &data.CustomEndpoint, &data.TotalTokens, &data.TotalCost, &data.RequestCount, &data.Percentage); err != nil {
+			return nil, err
+		}
+		spend = append(spend, data)
+	}
+	return spend, rows.Err()
+}
+
+// GetEndpointSpendSeries returns spend per hour (for ranges up to a day) or day for the top
+// endpoint groups, with the rest combined under "Other"
+func GetEndpointSpendSeries(db *sql.DB, filter models.AnalyticsFilter, grouping string, topGroups int) ([]models.EndpointSeriesPoint, error) {
+	startTime, err := parseTimeRange(filter.TimeRange, filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := "DATE(ul.created_at)::text"
+	switch filter.TimeRange {
+	case "6h", "12h", "24h":
+		bucket = "TO_CHAR(DATE_TRUNC('hour', ul.created_at), 'YYYY-MM-DD HH24:00')"
+	}
+
+	query := `
+		WITH grouped AS (
+			SELECT ` + bucket + ` AS bucket,
+			       ` + endpointGroupSQL(grouping) + ` AS name,
+			       ul.cost_usd
+			FROM usage_logs ul` + endpointJoinSQL + `
+			WHERE ul.created_at >= $1
+			  AND ul.endpoint IS NOT NULL
+			  AND ` + organizationFilter("ul.organization_id", filter) + `
+		),
+		top AS (
+			SELECT name FROM grouped
+			GROUP BY name
+			ORDER BY COALESCE(SUM(cost_usd), 0) DESC, COUNT(*) DESC
+			LIMIT $3
+		)
+		SELECT bucket,
+		       CASE WHEN name IN (SELECT name FROM top) THEN name ELSE 'Other' END AS series,
+		       COALESCE(SUM(cost_usd), 0),
+		       COUNT(*)
+		FROM grouped
+		GROUP BY bucket, series
+		ORDER BY bucket, series`
+
+	rows, err := db.Query(query, startTime, filter.Organization, topGroups)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []models.EndpointSeriesPoint
+	for rows.Next() {
+		var point models.EndpointSeriesPoint
+		if err := rows.Scan(&point.Date, &point.Name, &point.TotalCost, &point.RequestCount); err != nil {
+			return nil, err
+		}
+		series = append(series, point)
+	}
+	return series, rows.Err()
+}
+
+// GetRateGaugeNames returns the names of organizations and models by ID, to label live rate
+// gauges; inactive models are included as their traffic may still be in the window
+func GetRateGaugeNames(db *sql.DB) (orgNames, modelNames map[string]string, err error) {
+	orgNames, modelNames = map[string]string{}, map[string]string{}
+	rows, err := db.Query(`
+		SELECT 'org', id::text, name FROM organizations
+		UNION ALL
+		SELECT 'model', id::text, name FROM models`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind, id, name string
+		if err := rows.Scan(&kind, &id, &name); err != nil {
+			return nil, nil, err
+		}
+		if kind == "org" {
+			orgNames[id] = name
+		} else {
+			modelNames[id] = name
+		}
+	}
+	return orgNames, modelNames, rows.Err()
+}
+
+// GetPeriodComparison compares the filter's window with the window of the same length before
+// it. A custom range runs through the end of end_date, or up to now without one.
+func GetPeriodComparison(db *sql.DB, filter models.AnalyticsFilter) (*models.PeriodComparison, error) {
+	start, err := parseTimeRange(filter.TimeRange, filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now()
+	if filter.TimeRange == "custom" && filter.EndDate != "" {
+		endDate, err := time.Parse("2006-01-02", filter.EndDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_date: %w", err)
+		}
+		end = endDate.AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("end_date must not be before start_date")
+	}
+
+	current, err := getPeriodMetrics(db, filter, start, end)
+	if err != nil {
+		return nil, err
+	}
+	previousStart, previousEnd := models.PreviousWindow(start, end)
+	previous, err := getPeriodMetrics(db, filter, previousStart, previousEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := models.ComparePeriods(*current, *previous)
+	return &comparison, nil
+}
+
+// getPeriodMetrics totals usage logged in [start, end)
+func getPeriodMetrics(db *sql.DB, filter models.AnalyticsFilter, start, end time.Time) (*models.PeriodMetrics, error) {
+	query := `
+		SELECT
+			COUNT(*),
+			COUNT(CASE WHEN response_status >= 400 THEN 1 END),
+			COALESCE(SUM(total_tokens), 0),
+			COALESCE(SUM(cost_usd), 0),
+			COALESCE(AVG(response_time_ms), 0)
+		FROM usage_logs
+		WHERE created_at >= $1 AND created_at < $3
+		  AND ` + organizationFilter("organization_id", filter)
+
+	metrics := &models.PeriodMetrics{Start: start, End: end}
+	var failed int64
+	err := db.QueryRow(query, start, filter.Organization, end).Scan(
+		&metrics.Requests,
+		&failed,
+		&metrics.Tokens,
+		&metrics.Cost,
+		&metrics.AvgLatencyMs,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if metrics.Requests > 0 {
+		metrics.ErrorRate = float64(failed) / float64(metrics.Requests) * 100
+	}
+	return metrics, nil
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Analytics view operations
+
+// ErrAnalyticsViewNameTaken is returned when the user already has a view with the name
+var ErrAnalyticsViewNameTaken = errors.New("a view with this name already exists")
+
+const analyticsViewColumns = `v.id, v.user_id, v.organization_id, v.name, v.filters, v.shared, v.created_at, v.updated_at,
+	COALESCE((SELECT u.name FROM users u WHERE u.id = v.user_id), '') AS owner_name`
+
+func scanAnalyticsView(row interface{ Scan(...interface{}) error }) (*models.AnalyticsView, error) {
+	var v models.AnalyticsView
+	var filters []byte
+	err := row.Scan(&v.ID, &v.UserID, &v.OrganizationID, &v.Name, &filters, &v.Shared, &v.CreatedAt, &v.UpdatedAt, &v.OwnerName)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(filters, &v.Filters); err != nil {
+		return nil, fmt.Errorf("failed to decode filters of analytics view %s: %w", v.ID, err)
+	}
+	v.URL = models.AnalyticsViewURL(v.ID)
+	return &v, nil
+}
+
+// CreateAnalyticsView saves a view for the user, scoped to the organization its filters select
+func CreateAnalyticsView(db *sql.DB, userID string, req models.CreateAnalyticsViewRequest) (*models.AnalyticsView, error) {
+	filters, err := json.Marshal(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		WITH v AS (
+			INSERT INTO analytics_views (user_id, organization_id, name, filters, shared)
+			VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5)
+			ON CONFLICT (user_id, name) DO NOTHING
+			RETURNING *
+		)
+		SELECT ` + analyticsViewColumns + ` FROM v`
+
+	view, err := scanAnalyticsView(db.QueryRow(query, userID, req.Filters.Organization, req.Name, filters, req.Shared))
+	if err == sql.ErrNoRows {
+		return nil, ErrAnalyticsViewNameTaken
+	}
+	return view, err
+}
+
+// GetAnalyticsView returns a view by ID regardless of owner; callers check access
+func GetAnalyticsView(db *sql.DB, viewID string) (*models.AnalyticsView, error) {
+	return scanAnalyticsView(db.QueryRow(`SELECT `+analyticsViewColumns+` FROM analytics_views v WHERE v.id = $1`, viewID))
+}
+
+// GetAnalyticsViews returns the user's own views followed by views other users shared in the
+// given organizations
+func GetAnalyticsViews(db *sql.DB, userID string, orgIDs []string) ([]models.AnalyticsView, error) {
+	query := `
+		SELECT ` + analyticsViewColumns + `
+		FROM analytics_views v
+		WHERE v.user_id = $1
+		   OR (v.shared = true AND v.organization_id = ANY($2::uuid[]))
+		ORDER BY v.user_id <> $1, v.name`
+
+	rows, err := db.Query(query, userID, pq.Array(orgIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := []models.AnalyticsView{}
+	for rows.Next() {
+		v, err := scanAnalyticsView(rows)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, *v)
+	}
+	return views, rows.Err()
+}
+
+// UpdateAnalyticsView changes the fields given in the request. New filters also move the view
+// to the organization they select.
+func UpdateAnalyticsView(db *sql.DB, viewID string, req models.UpdateAnalyticsViewRequest) (*models.AnalyticsView, error) {
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *req.Name)
+		argIndex++
+	}
+	if req.Filters != nil {
+		filters, err := json.Marshal(req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		setParts = append(setParts, fmt.Sprintf("filters = $%d, organization_id = NULLIF($%d, '')::uuid", argIndex, argIndex+1))
+		args = append(args, filters, req.Filters.Organization)
+		argIndex += 2
+	}
+	if req.Shared != nil {
+		setParts = append(setParts, fmt.Sprintf("shared = $%d", argIndex))
+		args = append(args, *req.Shared)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated_at = NOW()")
+	args = append(args, viewID)
+
+	query := fmt.Sprintf(`
+		WITH v AS (
+			UPDATE analytics_views SET %s WHERE id = $%d RETURNING *
+		)
+		SELECT %s FROM v`, strings.Join(setParts, ", "), argIndex, analyticsViewColumns)
+
+	view, err := scanAnalyticsView(db.QueryRow(query, args...))
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrAnalyticsViewNameTaken
+	}
+	return view, err
+}
+
+// DeleteAnalyticsView removes a view
+func DeleteAnalyticsView(db *sql.DB, viewID string) error {
+	result, err := db.Exec(`DELETE FROM analytics_views WHERE id = $1`, viewID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// CreateAuditLog records a sensitive administrative action
+func CreateAuditLog(db *sql.DB, userID, action, resourceType, resourceID, ipAddress string, details map[string]interface{}) error {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_logs (user_id, action, resource_type, resource_id, details, ip_address)
+		VALUES (NULLIF($1, '')::uuid, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''))`
+
+	_, err = db.Exec(query, userID, action, resourceType, resourceID, detailsJSON, ipAddress)
+	return err
+}
+
+// GetAuditLogs returns the most recent audit log entries
+func GetAuditLogs(db *sql.DB, limit int) ([]models.AuditLog, error) {
+	query := `
+		SELECT id, user_id, action, resource_type, resource_id, details, ip_address, created_at
+		FROM audit_logs
+		ORDER BY created_at DESC
+		LIMIT $1`
+
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []models.AuditLog{}
+	for rows.Next() {
+		var entry models.AuditLog
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Action, &entry.ResourceType,
+			&entry.ResourceID, &details, &entry.IPAddress, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Details = details
+		logs = append(logs, entry)
+	}
+
+	return logs, rows.Err()
+}
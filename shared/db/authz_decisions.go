@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// CreateAuthzDecisions records a batch of authorization decisions
+func CreateAuthzDecisions(db *sql.DB, decisions []models.AuthzDecision) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO authz_decisions (request_id, organization_id, api_key_id, model, method, path, payload_bytes,
+			allowed, reason, status, policy, opa_decision_id, error, duration_ms, client_ip, created_at)
+		VALUES (NULLIF($1, ''), NULLIF($2, '')::uuid, NULLIF($3, '')::uuid, NULLIF($4, ''), $5, $6, $7,
+			$8, NULLIF($9, ''), NULLIF($10, 0), $11, NULLIF($12, ''), NULLIF($13, ''), $14, NULLIF($15, ''), $16)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, d := range decisions {
+		if _, err := stmt.Exec(d.RequestID, d.OrganizationID, d.APIKeyID, d.Model, d.Method, d.Path, d.PayloadBytes,
+			d.Allowed, d.Reason, d.Status, d.Policy, d.OPADecisionID, d.Error, d.DurationMS, d.ClientIP, d.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAuthzDecisions returns the decisions matching filter, newest first
+func GetAuthzDecisions(db *sql.DB, filter models.AuthzDecisionFilter) ([]models.AuthzDecision, error) {
+	conditions := []string{"TRUE"}
+	args := []interface{}{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.OrganizationID != "" {
+		add("organization_id = $%d", filter.OrganizationID)
+	}
+	if filter.APIKeyID != "" {
+		add("api_key_id = $%d", filter.APIKeyID)
+	}
+	if filter.Allowed != nil {
+		add("allowed = $%d", *filter.Allowed)
+	}
+	if !filter.SinceTime.IsZero() {
+		add("created_at >= $%d", filter.SinceTime)
+	}
+	if !filter.UntilTime.IsZero() {
+		add("created_at < $%d", filter.UntilTime)
+	}
+	args = append(args, filter.Limit)
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT id, COALESCE(request_id, ''), COALESCE(organization_id::text, ''), COALESCE(api_key_id::text, ''),
+			COALESCE(model, ''), method, path, payload_bytes, allowed, COALESCE(reason, ''), COALESCE(status, 0),
+			policy, COALESCE(opa_decision_id, ''), COALESCE(error, ''), duration_ms, COALESCE(client_ip, ''), created_at
+		FROM authz_decisions
+		WHERE %s
+		ORDER BY created_at DESC
+		LIMIT $%d`, strings.Join(conditions, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []models.AuthzDecision{}
+	for rows.Next() {
+		var d models.AuthzDecision
+		if err := rows.Scan(&d.ID, &d.RequestID, &d.OrganizationID, &d.APIKeyID, &d.Model, &d.Method, &d.Path,
+			&d.PayloadBytes, &d.Allowed, &d.Reason, &d.Status, &d.Policy, &d.OPADecisionID, &d.Error, &d.DurationMS,
+			&d.ClientIP, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		decisions = append(decisions, d)
+	}
+
+	return decisions, rows.Err()
+}
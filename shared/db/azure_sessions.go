@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// azureSessionRefreshClaim is how long one request may hold a session's refresh before another
+// can try; refresh tokens rotate, so two requests redeeming the same one would race
+const azureSessionRefreshClaim = 30 * time.Second
+
+// CreateAzureSession stores a new Azure AD session; its refresh token must already be sealed
+func CreateAzureSession(db *sql.DB, s *models.AzureSession) error {
+	query := `
+		INSERT INTO azure_sessions (azure_oid, email, refresh_token, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	return db.QueryRow(query, s.AzureOID, s.Email, s.RefreshToken, s.ExpiresAt).Scan(&s.ID, &s.CreatedAt)
+}
+
+// GetAzureSession returns a session, or sql.ErrNoRows once it has ended
+func GetAzureSession(db *sql.DB, sessionID string) (*models.AzureSession, error) {
+	var s models.AzureSession
+	err := db.QueryRow(`
+		SELECT id, azure_oid, email, refresh_token, expires_at, refreshed_at, created_at
+		FROM azure_sessions
+		WHERE id = $1`, sessionID).Scan(&s.ID, &s.AzureOID, &s.Email, &s.RefreshToken, &s.ExpiresAt,
+		&s.RefreshedAt, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ClaimAzureSessionRefresh reports whether the caller may refresh the session now: false while
+// another request, possibly on another replica, is refreshing it
+func ClaimAzureSessionRefresh(db *sql.DB, sessionID string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE azure_sessions SET refresh_claimed_until = $2
+		WHERE id = $1 AND (refresh_claimed_until IS NULL OR refresh_claimed_until < NOW())`,
+		sessionID, time.Now().Add(azureSessionRefreshClaim))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// UpdateAzureSessionTokens records a refresh: the new sealed refresh token and access token
+// expiry. It releases the refresh claim.
+func UpdateAzureSessionTokens(db *sql.DB, sessionID, refreshToken string, expiresAt time.Time) error {
+	_, err := db.Exec(`
+		UPDATE azure_sessions
+		SET refresh_token = $2, expires_at = $3, refreshed_at = NOW(), refresh_claimed_until = NULL
+		WHERE id = $1`, sessionID, refreshToken, expiresAt)
+	return err
+}
+
+// DeleteAzureSession ends a session
+func DeleteAzureSession(db *sql.DB, sessionID string) error {
+	_, err := db.Exec(`DELETE FROM azure_sessions WHERE id = $1`, sessionID)
+	return err
+}
+
+// DeleteAzureSessionsBefore removes sessions begun before cutoff, which can no longer be
+// extended, and returns how many were removed
+func DeleteAzureSessionsBefore(db *sql.DB, cutoff time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM azure_sessions WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Batch API operations
+
+// CreateBatchFile stores an uploaded or generated batch file and fills in its ID and creation time
+func CreateBatchFile(db *sql.DB, file *models.BatchFile) error {
+	query := `
+		INSERT INTO batch_files (organization_id, api_key_id, purpose, filename, bytes, content)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	file.Bytes = len(file.Content)
+	return db.QueryRow(query, file.OrganizationID, file.APIKeyID, file.Purpose, file.Filename, file.Bytes, file.Content).
+		Scan(&file.ID, &file.CreatedAt)
+}
+
+// GetBatchFile returns one of the organization's batch files, with its content
+func GetBatchFile(db *sql.DB, orgID, fileID string) (*models.BatchFile, error) {
+	query := `
+		SELECT id, organization_id, api_key_id, purpose, filename, bytes, content, created_at
+		FROM batch_files
+		WHERE id = $1 AND organization_id = $2`
+
+	var f models.BatchFile
+	err := db.QueryRow(query, fileID, orgID).Scan(&f.ID, &f.OrganizationID, &f.APIKeyID, &f.Purpose, &f.Filename,
+		&f.Bytes, &f.Content, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+const batchSelect = `
+	SELECT id, organization_id, api_key_id, endpoint, input_file_id, output_file_id, error_file_id,
+	       completion_window, status, errors, total_requests, completed_requests, failed_requests,
+	       prompt_tokens, completion_tokens, total_tokens, metadata, created_at, in_progress_at,
+	       finalizing_at, completed_at, failed_at, cancelling_at, cancelled_at, expires_at
+	FROM batches`
+
+func scanBatch(row interface{ Scan(...interface{}) error }) (*models.Batch, error) {
+	var b models.Batch
+	var errs, metadata []byte
+	err := row.Scan(&b.ID, &b.OrganizationID, &b.APIKeyID, &b.Endpoint, &b.InputFileID, &b.OutputFileID, &b.ErrorFileID,
+		&b.CompletionWindow, &b.Status, &errs, &b.RequestCounts.Total, &b.RequestCounts.Completed, &b.RequestCounts.Failed,
+		&b.Usage.InputTokens, &b.Usage.OutputTokens, &b.Usage.TotalTokens, &metadata, &b.CreatedAt, &b.InProgressAt,
+		&b.FinalizingAt, &b.CompletedAt, &b.FailedAt, &b.CancellingAt, &b.CancelledAt, &b.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		if err := json.Unmarshal(errs, &b.Errors); err != nil {
+			return nil, err
+		}
+	}
+	b.Metadata = map[string]string{}
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &b.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &b, nil
+}
+
+// CreateBatch inserts a batch. Batches with validation errors are stored as failed; others start
+// in progress.
+func CreateBatch(db *sql.DB, batch *models.Batch) (*models.Batch, error) {
+	if batch.Errors == nil {
+		batch.Errors = []models.BatchError{}
+	}
+	if batch.Metadata == nil {
+		batch.Metadata = map[string]string{}
+	}
+	errs, err := json.Marshal(batch.Errors)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := json.Marshal(batch.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO batches (organization_id, api_key_id, endpoint, input_file_id, completion_window, status, errors,
+		                     total_requests, metadata, in_progress_at, failed_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9,
+		        CASE WHEN $6 = 'in_progress' THEN NOW() END,
+		        CASE WHEN $6 = 'failed' THEN NOW() END,
+		        NOW() + INTERVAL '24 hours')
+		RETURNING id`
+
+	var id string
+	err = db.QueryRow(query, batch.OrganizationID, batch.APIKeyID, batch.Endpoint, batch.InputFileID, batch.CompletionWindow,
+		batch.Status, errs, batch.RequestCounts.Total, metadata).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return GetBatch(db, batch.OrganizationID, id)
+}
+
+// GetBatch returns one of the organization's batches
+func GetBatch(db *sql.DB, orgID, batchID string) (*models.Batch, error) {
+	return scanBatch(db.QueryRow(batchSelect+` WHERE id = $1 AND organization_id = $2`, batchID, orgID))
+}
+
+// GetBatches lists the organization's batches, newest first. after is the ID of the last batch
+// of the previous page.
+func GetBatches(db *sql.DB, orgID, after string, limit int) ([]models.Batch, error) {
+	query := batchSelect + `
+		WHERE organization_id = $1
+		  AND ($2 = '' OR created_at < (SELECT created_at FROM batches WHERE id = NULLIF($2, '')::uuid))
+		ORDER BY created_at DESC
+		LIMIT $3`
+
+	rows, err := db.Query(query, orgID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := []models.Batch{}
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, *b)
+	}
+	return batches, rows.Err()
+}
+
+// GetBatchStatus returns a batch's current status
+func GetBatchStatus(db *sql.DB, batchID string) (string, error) {
+	var status string
+	err := db.QueryRow(`SELECT status FROM batches WHERE id = $1`, batchID).Scan(&status)
+	return status, err
+}
+
+// AddBatchProgress records finished requests and their token usage against a batch
+func AddBatchProgress(db *sql.DB, batchID string, completed, failed int, usage models.BatchUsage) error {
+	query := `
+		UPDATE batches
+		SET completed_requests = completed_requests + $2,
+		    failed_requests = failed_requests + $3,
+		    prompt_tokens = prompt_tokens + $4,
+		    completion_tokens = completion_tokens + $5,
+		    total_tokens = total_tokens + $6,
+		    updated_at = NOW()
+		WHERE id = $1`
+
+	_, err := db.Exec(query, batchID, completed, failed, usage.InputTokens, usage.OutputTokens, usage.TotalTokens)
+	return err
+}
+
+// SetBatchFinalizing marks a batch as writing its result files
+func SetBatchFinalizing(db *sql.DB, batchID string) error {
+	_, err := db.Exec(`UPDATE batches SET status = 'finalizing', finalizing_at = NOW(), updated_at = NOW() WHERE id = $1`, batchID)
+	return err
+}
+
+// FinishBatch records a batch's result files and its final status, completed or cancelled
+func FinishBatch(db *sql.DB, batchID, status string, outputFileID, errorFileID *string) error {
+	query := `
+		UPDATE batches
+		SET status = $2, output_file_id = $3, error_file_id = $4, updated_at = NOW(),
+		    completed_at = CASE WHEN $2 = 'completed' THEN NOW() ELSE completed_at END,
+		    cancelled_at = CASE WHEN $2 = 'cancelled' THEN NOW() ELSE cancelled_at END
+		WHERE id = $1`
+
+	_, err := db.Exec(query, batchID, status, outputFileID, errorFileID)
+	return err
+}
+
+// FailBatch marks a batch as failed with the given errors
+func FailBatch(db *sql.DB, batchID string, errs []models.BatchError) error {
+	data, err := json.Marshal(errs)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE batches SET status = 'failed', errors = $2, failed_at = NOW(), updated_at = NOW() WHERE id = $1`, batchID, data)
+	return err
+}
+
+// CancelBatch asks an in-progress batch to stop. It returns the batch's status afterwards, or
+// sql.ErrNoRows if the organization has no such batch.
+func CancelBatch(db *sql.DB, orgID, batchID string) (string, error) {
+	query := `
+		UPDATE batches
+		SET status = CASE WHEN status = 'in_progress' THEN 'cancelling' ELSE status END,
+		    cancelling_at = CASE WHEN status = 'in_progress' THEN NOW() ELSE cancelling_at END,
+		    updated_at = NOW()
+		WHERE id = $1 AND organization_id = $2
+		RETURNING status`
+
+	var status string
+	err := db.QueryRow(query, batchID, orgID).Scan(&status)
+	return status, err
+}
+
+// FailStaleBatches fails batches that have made no progress for longer than staleAfter, which
+// happens when the gateway processing them stopped. It returns how many were failed.
+func FailStaleBatches(db *sql.DB, staleAfter time.Duration) (int64, error) {
+	errs, err := json.Marshal([]models.BatchError{{Code: "interrupted", Message: "processing stopped before the batch finished; submit it again"}})
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		UPDATE batches
+		SET status = CASE WHEN status = 'cancelling' THEN 'cancelled' ELSE 'failed' END,
+		    cancelled_at = CASE WHEN status = 'cancelling' THEN NOW() ELSE cancelled_at END,
+		    failed_at = CASE WHEN status = 'cancelling' THEN failed_at ELSE NOW() END,
+		    errors = CASE WHEN status = 'cancelling' THEN errors ELSE $1 END,
+		    updated_at = NOW()
+		WHERE status IN ('in_progress', 'finalizing', 'cancelling')
+		  AND updated_at < $2`
+
+	result, err := db.Exec(query, errs, time.Now().Add(-staleAfter))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
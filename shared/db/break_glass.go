@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// The break-glass account is a single row; until a System Admin sets the policy it is enabled.
+
+// GetBreakGlassAccount returns the break-glass policy and session
+func GetBreakGlassAccount(db *sql.DB) (*models.BreakGlassAccount, error) {
+	account := models.BreakGlassAccount{Enabled: true}
+	var tokenHash sql.NullString
+	err := db.QueryRow(`
+		SELECT enabled, session_token_hash, session_expires_at, last_used_at, updated_by, updated_at
+		FROM break_glass_account`).Scan(&account.Enabled, &tokenHash, &account.SessionExpiresAt,
+		&account.LastUsedAt, &account.UpdatedBy, &account.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &account, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	account.SessionTokenHash = tokenHash.String
+	return &account, nil
+}
+
+// SetBreakGlassEnabled sets whether the break-glass account may be used. Disabling it ends its
+// session.
+func SetBreakGlassEnabled(db *sql.DB, enabled bool, updatedBy string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO break_glass_account (id, enabled, updated_by, updated_at)
+		VALUES (true, $1, $2, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW(),
+			session_token_hash = CASE WHEN EXCLUDED.enabled THEN break_glass_account.session_token_hash END,
+			session_expires_at = CASE WHEN EXCLUDED.enabled THEN break_glass_account.session_expires_at END`,
+		enabled, updatedBy)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		if err := setBreakGlassUserActive(tx, false); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// StartBreakGlassSession replaces the account's session with a new one and activates its user,
+// refusing with sql.ErrNoRows if the policy disabled the account meanwhile
+func StartBreakGlassSession(db *sql.DB, tokenHash string, expiresAt time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO break_glass_account (id, session_token_hash, session_expires_at, last_used_at)
+		VALUES (true, $1, $2, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			session_token_hash = EXCLUDED.session_token_hash,
+			session_expires_at = EXCLUDED.session_expires_at,
+			last_used_at = NOW()
+		WHERE break_glass_account.enabled`, tokenHash, expiresAt)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := setBreakGlassUserActive(tx, true); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EndBreakGlassSession signs the break-glass account out and deactivates its user
+func EndBreakGlassSession(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE break_glass_account SET session_token_hash = NULL, session_expires_at = NULL`); err != nil {
+		return err
+	}
+	if err := setBreakGlassUserActive(tx, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeactivateBreakGlassUser deactivates the break-glass account's user once its session has
+// expired, so it can't be resolved again until the next sign-in
+func DeactivateBreakGlassUser(db *sql.DB) error {
+	_, err := db.Exec(`
+		UPDATE users SET is_active = false, updated_at = NOW()
+		WHERE azure_oid = $1 AND is_active
+		AND NOT EXISTS (SELECT 1 FROM break_glass_account WHERE session_expires_at > NOW())`,
+		models.BreakGlassAzureOID)
+	return err
+}
+
+// setBreakGlassUserActive activates the break-glass account's user for a session, or
+// deactivates it when the session ends
+func setBreakGlassUserActive(tx *sql.Tx, active bool) error {
+	_, err := tx.Exec(`UPDATE users SET is_active = $1, updated_at = NOW() WHERE azure_oid = $2`,
+		active, models.BreakGlassAzureOID)
+	return err
+}
+
+// EnsureBreakGlassUser returns the break-glass account's user, creating it as a System Admin
+// on first use. The user stays inactive until StartBreakGlassSession activates it.
+func EnsureBreakGlassUser(db *sql.DB) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	err = tx.QueryRow(`
+		INSERT INTO users (azure_oid, email, name, is_active)
+		VALUES ($1, $2, $3, false)
+		ON CONFLICT (azure_oid) DO UPDATE SET updated_at = NOW()
+		RETURNING id`, models.BreakGlassAzureOID, models.BreakGlassEmail, models.BreakGlassName).Scan(&userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_system_roles (user_id, role_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, role_id) DO NOTHING`, userID, systemAdminRoleID)
+	if err != nil {
+		return "", err
+	}
+
+	return userID, tx.Commit()
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Canary key operations
+
+// GetCanaryKey returns the active canary key whose value is token, or sql.ErrNoRows
+func GetCanaryKey(db *sql.DB, token string) (*models.APIKey, error) {
+	var key models.APIKey
+	var orgName string
+	err := db.QueryRow(`
+		SELECT ak.id, ak.name, ak.organization_id, o.name, ak.canary_block_ip, ak.created_at
+		FROM api_keys ak
+		JOIN organizations o ON ak.organization_id = o.id
+		WHERE ak.api_key = $1 AND ak.is_canary = true AND ak.is_active = true`, token).Scan(
+		&key.ID, &key.Name, &key.OrganizationID, &orgName, &key.CanaryBlockIP, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	key.IsCanary = true
+	key.IsActive = true
+	key.KeyPrefix = "sk-" + key.ID[:8] + "..."
+	key.Organization = &models.Organization{ID: key.OrganizationID, Name: orgName}
+	return &key, nil
+}
+
+// CreateCanaryHit records a request made with a canary key
+func CreateCanaryHit(db *sql.DB, hit models.CanaryHit) error {
+	headers, err := json.Marshal(hit.Headers)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO canary_key_hits (api_key_id, organization_id, ip_address, method, path, headers)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		hit.APIKeyID, hit.OrganizationID, hit.IPAddress, hit.Method, hit.Path, headers)
+	return err
+}
+
+// GetCanaryHits returns the latest requests made with a canary key, newest first
+func GetCanaryHits(db *sql.DB, keyID string, limit int) ([]models.CanaryHit, error) {
+	rows, err := db.Query(`
+		SELECT id, api_key_id, organization_id, ip_address, method, path, headers, created_at
+		FROM canary_key_hits
+		WHERE api_key_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, keyID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.CanaryHit{}
+	for rows.Next() {
+		var hit models.CanaryHit
+		var headers []byte
+		if err := rows.Scan(&hit.ID, &hit.APIKeyID, &hit.OrganizationID, &hit.IPAddress, &hit.Method, &hit.Path,
+			&headers, &hit.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(headers, &hit.Headers); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// BlockIP adds an address to the block list, or refreshes an existing block. A nil expiresAt
+// blocks it until it is removed.
+func BlockIP(db *sql.DB, ip, reason string, apiKeyID *string, expiresAt *time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO blocked_ips (ip_address, reason, api_key_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (ip_address) DO UPDATE
+		SET reason = EXCLUDED.reason, api_key_id = EXCLUDED.api_key_id, expires_at = EXCLUDED.expires_at,
+		    created_at = NOW()`, ip, reason, apiKeyID, expiresAt)
+	return err
+}
+
+// UnblockIP removes an address from the block list; sql.ErrNoRows if it wasn't blocked
+func UnblockIP(db *sql.DB, ip string) error {
+	result, err := db.Exec(`DELETE FROM blocked_ips WHERE ip_address = $1`, ip)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetBlockedIPs returns the blocks that haven't expired, newest first
+func GetBlockedIPs(db *sql.DB) ([]models.BlockedIP, error) {
+	rows, err := db.Query(`
+		SELECT ip_address, reason, api_key_id, created_at, expires_at
+		FROM blocked_ips
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocked := []models.BlockedIP{}
+	for rows.Next() {
+		var b models.BlockedIP
+		if err := rows.Scan(&b.IPAddress, &b.Reason, &b.APIKeyID, &b.CreatedAt, &b.ExpiresAt); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, b)
+	}
+
+	return blocked, rows.Err()
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Conversation session operations. Messages are passed in and out sealed; the gateway seals
+// them with the organization's key.
+
+// GetConversationSession returns an organization's unexpired session and its sealed messages,
+// or sql.ErrNoRows
+func GetConversationSession(db *sql.DB, orgID, sessionID string) (*models.ConversationSession, string, error) {
+	query := `
+		SELECT id, organization_id, session_id, api_key_id, messages, size_bytes, expires_at, created_at, updated_at
+		FROM conversation_sessions
+		WHERE organization_id = $1 AND session_id = $2 AND expires_at > NOW()`
+
+	var s models.ConversationSession
+	var sealed string
+	err := db.QueryRow(query, orgID, sessionID).Scan(&s.ID, &s.OrganizationID, &s.SessionID, &s.APIKeyID, &sealed,
+		&s.SizeBytes, &s.ExpiresAt, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	return &s, sealed, nil
+}
+
+// SaveConversationSession creates or replaces a session's messages and moves its expiry to
+// expiresAt. An expired session by the same ID starts over.
+func SaveConversationSession(db *sql.DB, session *models.ConversationSession, sealed string) error {
+	query := `
+		INSERT INTO conversation_sessions (organization_id, session_id, api_key_id, messages, size_bytes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (organization_id, session_id) DO UPDATE SET
+			api_key_id = EXCLUDED.api_key_id, messages = EXCLUDED.messages, size_bytes = EXCLUDED.size_bytes,
+			expires_at = EXCLUDED.expires_at, updated_at = NOW(),
+			created_at = CASE WHEN conversation_sessions.expires_at <= NOW() THEN NOW() ELSE conversation_sessions.created_at END
+		RETURNING id, created_at, updated_at`
+
+	return db.QueryRow(query, session.OrganizationID, session.SessionID, session.APIKeyID, sealed, session.SizeBytes,
+		session.ExpiresAt).Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt)
+}
+
+// DeleteConversationSession removes a session, returning sql.ErrNoRows if there is none
+func DeleteConversationSession(db *sql.DB, orgID, sessionID string) error {
+	result, err := db.Exec(`DELETE FROM conversation_sessions WHERE organization_id = $1 AND session_id = $2`, orgID, sessionID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteExpiredConversationSessions removes sessions that expired before now and returns how
+// many were removed
+func DeleteExpiredConversationSessions(db *sql.DB, now time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM conversation_sessions WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
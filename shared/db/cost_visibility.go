@@ -0,0 +1,24 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// GetOrganizationHideCosts reports whether the organization shows its non-admin members tokens
+// only, withholding costs:read from every role but admin
+func GetOrganizationHideCosts(db *sql.DB, orgID string) (bool, error) {
+	var hide bool
+	err := db.QueryRow(`SELECT hide_costs FROM organizations WHERE id = $1`, orgID).Scan(&hide)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return hide, err
+}
+
+// UpdateOrganizationHideCosts sets whether non-admin members see model prices and spend
+func UpdateOrganizationHideCosts(db *sql.DB, orgID string, hide bool) error {
+	if _, err := db.Exec(`UPDATE organizations SET hide_costs = $2, updated_at = NOW() WHERE id = $1`, orgID, hide); err != nil {
+		return err
+	}
+	return bumpOrganizationPermissionsVersions(db, orgID)
+}
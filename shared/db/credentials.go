@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"log"
+	"os"
+	"sync/atomic"
+
+	"github.com/lib/pq"
+
+	"github.com/like-mike/relai-gateway/shared/secretfile"
+)
+
+// credentialSecrets are the connection settings that may be mounted from files and rotated
+var credentialSecrets = []string{"POSTGRES_DSN", "DB_USER", "DB_PASSWORD"}
+
+// credentialsFromFiles reports whether any of the connection's credentials are read from files
+func credentialsFromFiles() bool {
+	for _, name := range credentialSecrets {
+		if os.Getenv(name+"_FILE") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialConnector opens each connection with the credentials current at the time. When
+// they're rotated, connections opened with the old ones are retired as they come back to the
+// pool instead of all at once, so requests in flight aren't cut off.
+type credentialConnector struct {
+	dsn        func() string
+	generation atomic.Uint64
+}
+
+func newCredentialConnector(dsn func() string) *credentialConnector {
+	c := &credentialConnector{dsn: dsn}
+	secretfile.OnChange(func(name string) {
+		for _, secret := range credentialSecrets {
+			if name == secret {
+				c.generation.Add(1)
+				log.Printf("Database credentials rotated; reconnecting as connections are released")
+				return
+			}
+		}
+	})
+	return c
+}
+
+func (c *credentialConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	generation := c.generation.Load()
+	connector, err := pq.NewConnector(c.dsn())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &credentialConn{Conn: conn, connector: c, generation: generation}, nil
+}
+
+func (c *credentialConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// credentialConn is a connection that reports itself bad once the credentials it was opened
+// with have been rotated
+type credentialConn struct {
+	driver.Conn
+	connector  *credentialConnector
+	generation uint64
+}
+
+func (c *credentialConn) stale() bool {
+	return c.generation != c.connector.generation.Load()
+}
+
+func (c *credentialConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *credentialConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		return execer.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *credentialConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *credentialConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *credentialConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession runs before a pooled connection is reused; a stale one is discarded and the
+// pool opens a new one in its place
+func (c *credentialConn) ResetSession(ctx context.Context) error {
+	if c.stale() {
+		return driver.ErrBadConn
+	}
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid runs as a connection is returned to the pool; a stale one is closed
+func (c *credentialConn) IsValid() bool {
+	if c.stale() {
+		return false
+	}
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetOrganizationCurrency returns the currency the organization's reports are shown in
+func GetOrganizationCurrency(db *sql.DB, orgID string) (string, error) {
+	var currency string
+	err := db.QueryRow(`SELECT currency FROM organizations WHERE id = $1`, orgID).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return models.BaseCurrency, nil
+	}
+	return currency, err
+}
+
+// UpdateOrganizationCurrency sets the currency the organization's reports are shown in,
+// returning sql.ErrNoRows if it doesn't exist
+func UpdateOrganizationCurrency(db *sql.DB, orgID, currency string) error {
+	result, err := db.Exec(`UPDATE organizations SET currency = $2, updated_at = NOW() WHERE id = $1`, orgID, currency)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetFXRate returns the latest rate for the currency, or sql.ErrNoRows if none was fetched or
+// set
+func GetFXRate(db *sql.DB, currency string) (*models.FXRate, error) {
+	var rate models.FXRate
+	err := db.QueryRow(`SELECT currency, rate_per_usd, source, fetched_at FROM fx_rates WHERE currency = $1`, currency).
+		Scan(&rate.Currency, &rate.RatePerUSD, &rate.Source, &rate.FetchedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// ListFXRates returns every currency's latest rate
+func ListFXRates(db *sql.DB) ([]models.FXRate, error) {
+	rows, err := db.Query(`SELECT currency, rate_per_usd, source, fetched_at FROM fx_rates ORDER BY currency`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []models.FXRate{}
+	for rows.Next() {
+		var rate models.FXRate
+		if err := rows.Scan(&rate.Currency, &rate.RatePerUSD, &rate.Source, &rate.FetchedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// SaveFXRates stores the latest rates per US dollar, replacing earlier ones for the same
+// currencies
+func SaveFXRates(db *sql.DB, rates map[string]float64, source string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO fx_rates (currency, rate_per_usd, source, fetched_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (currency) DO UPDATE SET
+			rate_per_usd = EXCLUDED.rate_per_usd,
+			source = EXCLUDED.source,
+			fetched_at = EXCLUDED.fetched_at`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for currency, rate := range rates {
+		if _, err := stmt.Exec(currency, rate, source); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
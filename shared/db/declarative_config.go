@@ -0,0 +1,124 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// declarativeConfigLockKey keeps replicas from reconciling the same config at once
+const declarativeConfigLockKey = 7263810455
+
+// WithDeclarativeConfigLock runs fn while holding the declarative config lock. It returns false,
+// without running fn, while another replica holds it.
+func WithDeclarativeConfigLock(db *sql.DB, fn func() error) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, declarativeConfigLockKey).Scan(&locked); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	if err := fn(); err != nil {
+		return true, err
+	}
+	return true, tx.Commit()
+}
+
+// GetDeclaredResources returns the IDs of the resources of a kind the config manages, by name
+func GetDeclaredResources(db *sql.DB, kind string) (map[string]string, error) {
+	rows, err := db.Query(`SELECT name, resource_id FROM declared_resources WHERE kind = $1`, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	declared := map[string]string{}
+	for rows.Next() {
+		var name, id string
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, err
+		}
+		declared[name] = id
+	}
+	return declared, rows.Err()
+}
+
+// SetDeclaredResource records that the config manages the resource under name
+func SetDeclaredResource(db *sql.DB, kind, name, id string) error {
+	_, err := db.Exec(`
+		INSERT INTO declared_resources (kind, name, resource_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (kind, name) DO UPDATE SET resource_id = EXCLUDED.resource_id, applied_at = NOW()`,
+		kind, name, id)
+	return err
+}
+
+// DeleteDeclaredResource hands the resource back to the admin console
+func DeleteDeclaredResource(db *sql.DB, kind, name string) error {
+	_, err := db.Exec(`DELETE FROM declared_resources WHERE kind = $1 AND name = $2`, kind, name)
+	return err
+}
+
+// FindOrganizationIDByName returns the ID of the organization with the name, preferring an
+// active one, or "" when there is none
+func FindOrganizationIDByName(db *sql.DB, name string) (string, error) {
+	var id string
+	err := db.QueryRow(`
+		SELECT id FROM organizations WHERE name = $1
+		ORDER BY is_active DESC, created_at LIMIT 1`, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// FindModelIDByName returns the ID of the model with the name, preferring an active one, or ""
+// when there is none
+func FindModelIDByName(db *sql.DB, name string) (string, error) {
+	var id string
+	err := db.QueryRow(`
+		SELECT id FROM models WHERE name = $1
+		ORDER BY is_active DESC, created_at LIMIT 1`, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// UpdateDeclaredOrganization sets what the config declares of an existing organization. It
+// returns sql.ErrNoRows when the organization no longer exists.
+func UpdateDeclaredOrganization(db *sql.DB, id string, spec models.DeclaredOrganizationSpec, active bool) error {
+	result, err := db.Exec(`
+		UPDATE organizations SET description = NULLIF($2, ''), is_active = $3, updated_at = NOW()
+		WHERE id = $1 AND (description IS DISTINCT FROM NULLIF($2, '') OR is_active IS DISTINCT FROM $3)`,
+		id, spec.Description, active)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	// Nothing changed; make sure that's because it's already up to date
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM organizations WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeactivateOrganization marks an organization inactive
+func DeactivateOrganization(db *sql.DB, id string) error {
+	_, err := db.Exec(`UPDATE organizations SET is_active = false, updated_at = NOW() WHERE id = $1`, id)
+	return err
+}
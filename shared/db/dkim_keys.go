@@ -0,0 +1,65 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+const dkimKeyColumns = `id, domain, selector, private_key, public_key, is_enabled, created_at, updated_at`
+
+func scanDKIMKey(row interface{ Scan(...interface{}) error }) (*models.DKIMKey, error) {
+	var k models.DKIMKey
+	if err := row.Scan(&k.ID, &k.Domain, &k.Selector, &k.PrivateKey, &k.PublicKey, &k.IsEnabled,
+		&k.CreatedAt, &k.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// GetDKIMKeys returns the DKIM keys for all sending domains
+func GetDKIMKeys(db *sql.DB) ([]models.DKIMKey, error) {
+	rows, err := db.Query(`SELECT ` + dkimKeyColumns + ` FROM dkim_keys ORDER BY domain`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.DKIMKey{}
+	for rows.Next() {
+		k, err := scanDKIMKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetDKIMKeyByDomain returns the enabled key for a domain, or nil when mail from it isn't signed
+func GetDKIMKeyByDomain(db *sql.DB, domain string) (*models.DKIMKey, error) {
+	k, err := scanDKIMKey(db.QueryRow(`
+		SELECT `+dkimKeyColumns+` FROM dkim_keys
+		WHERE domain = LOWER($1) AND is_enabled = true`, domain))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+// UpsertDKIMKey stores a domain's key, replacing any existing one. privateKey must already be encrypted.
+func UpsertDKIMKey(db *sql.DB, domain, selector, privateKey, publicKey string) (*models.DKIMKey, error) {
+	return scanDKIMKey(db.QueryRow(`
+		INSERT INTO dkim_keys (domain, selector, private_key, public_key)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (domain)
+		DO UPDATE SET selector = EXCLUDED.selector, private_key = EXCLUDED.private_key,
+		              public_key = EXCLUDED.public_key, is_enabled = true, updated_at = NOW()
+		RETURNING `+dkimKeyColumns, domain, selector, privateKey, publicKey))
+}
+
+// DeleteDKIMKey removes a key; mail from its domain goes out unsigned
+func DeleteDKIMKey(db *sql.DB, id string) (*models.DKIMKey, error) {
+	return scanDKIMKey(db.QueryRow(`DELETE FROM dkim_keys WHERE id = $1 RETURNING `+dkimKeyColumns, id))
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+const emailLogColumns = `id, recipient_email, subject, template_id, status, error_message, outbox_id, smtp_server, sent_at, created_at`
+
+func scanEmailLog(row interface{ Scan(...interface{}) error }) (*models.EmailLog, error) {
+	var l models.EmailLog
+	err := row.Scan(&l.ID, &l.RecipientEmail, &l.Subject, &l.TemplateID, &l.Status, &l.ErrorMessage,
+		&l.OutboxID, &l.SMTPServer, &l.SentAt, &l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// SearchEmailLogs returns a page of the email logs matching the search, newest first, with how
+// many match in all
+func SearchEmailLogs(db *sql.DB, search models.EmailLogSearch) ([]models.EmailLog, int, error) {
+	var args []interface{}
+	var conditions []string
+	if search.Recipient != "" {
+		args = append(args, search.Recipient)
+		conditions = append(conditions, fmt.Sprintf("STRPOS(LOWER(recipient_email), LOWER($%d)) > 0", len(args)))
+	}
+	if search.Status != "" {
+		args = append(args, search.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if search.TemplateID != "" {
+		args = append(args, search.TemplateID)
+		conditions = append(conditions, fmt.Sprintf("template_id = $%d", len(args)))
+	}
+	if search.FromTime != nil {
+		args = append(args, *search.FromTime)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if search.ToTime != nil {
+		args = append(args, *search.ToTime)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM email_logs `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, search.PageSize, search.Offset())
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM email_logs
+		%s
+		ORDER BY created_at DESC, id
+		LIMIT $%d OFFSET $%d`, emailLogColumns, where, len(args)-1, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	logs := []models.EmailLog{}
+	for rows.Next() {
+		l, err := scanEmailLog(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		logs = append(logs, *l)
+	}
+
+	return logs, total, rows.Err()
+}
+
+// GetEmailLog returns an email log by ID, or sql.ErrNoRows
+func GetEmailLog(db *sql.DB, id string) (*models.EmailLog, error) {
+	return scanEmailLog(db.QueryRow(`SELECT `+emailLogColumns+` FROM email_logs WHERE id = $1`, id))
+}
+
+// DeleteEmailLogsBefore removes email logs created before cutoff, with the outbox messages that
+// finished sending by then, and returns how many logs were removed. Pending and retrying outbox
+// messages are kept whatever their age.
+func DeleteEmailLogsBefore(db *sql.DB, cutoff time.Time) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM email_logs WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM email_outbox
+		WHERE status IN ('sent', 'failed', 'suppressed') AND updated_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	return removed, tx.Commit()
+}
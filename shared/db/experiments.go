@@ -0,0 +1,309 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Experiment operations
+
+// CreateExperiment creates an experiment together with its variants
+func CreateExperiment(db *sql.DB, req models.CreateExperimentRequest, createdBy *string) (*models.Experiment, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var experiment models.Experiment
+	query := `
+		INSERT INTO experiments (organization_id, name, description, endpoint, status, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, organization_id, name, description, endpoint, status, winner_variant_id, created_by, started_at, ended_at, created_at, updated_at`
+
+	err = tx.QueryRow(query, req.OrganizationID, req.Name, req.Description, req.Endpoint, models.ExperimentStatusDraft, createdBy).Scan(
+		&experiment.ID, &experiment.OrganizationID, &experiment.Name, &experiment.Description,
+		&experiment.Endpoint, &experiment.Status, &experiment.WinnerVariantID, &experiment.CreatedBy,
+		&experiment.StartedAt, &experiment.EndedAt, &experiment.CreatedAt, &experiment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	variantQuery := `
+		INSERT INTO experiment_variants (experiment_id, name, model_id, system_prompt, traffic_percent)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, experiment_id, name, model_id, system_prompt, traffic_percent, created_at`
+
+	for _, v := range req.Variants {
+		var variant models.ExperimentVariant
+		err = tx.QueryRow(variantQuery, experiment.ID, v.Name, v.ModelID, v.SystemPrompt, v.TrafficPercent).Scan(
+			&variant.ID, &variant.ExperimentID, &variant.Name, &variant.ModelID,
+			&variant.SystemPrompt, &variant.TrafficPercent, &variant.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		experiment.Variants = append(experiment.Variants, variant)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &experiment, nil
+}
+
+// GetExperimentsByOrganization returns the experiments of the given organizations, newest first
+func GetExperimentsByOrganization(db *sql.DB, orgIDs []string) ([]models.Experiment, error) {
+	if len(orgIDs) == 0 {
+		return []models.Experiment{}, nil
+	}
+
+	placeholders := make([]string, len(orgIDs))
+	args := make([]interface{}, len(orgIDs))
+	for i, id := range orgIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, organization_id, name, description, endpoint, status, winner_variant_id, created_by, started_at, ended_at, created_at, updated_at
+		FROM experiments
+		WHERE organization_id IN (%s)
+		ORDER BY created_at DESC`, strings.Join(placeholders, ", "))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []models.Experiment
+	for rows.Next() {
+		var e models.Experiment
+		err := rows.Scan(
+			&e.ID, &e.OrganizationID, &e.Name, &e.Description, &e.Endpoint, &e.Status,
+			&e.WinnerVariantID, &e.CreatedBy, &e.StartedAt, &e.EndedAt, &e.CreatedAt, &e.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, e)
+	}
+
+	return experiments, rows.Err()
+}
+
+// GetExperimentByID returns an experiment with its variants
+func GetExperimentByID(db *sql.DB, experimentID string) (*models.Experiment, error) {
+	var e models.Experiment
+	query := `
+		SELECT id, organization_id, name, description, endpoint, status, winner_variant_id, created_by, started_at, ended_at, created_at, updated_at
+		FROM experiments
+		WHERE id = $1`
+
+	err := db.QueryRow(query, experimentID).Scan(
+		&e.ID, &e.OrganizationID, &e.Name, &e.Description, &e.Endpoint, &e.Status,
+		&e.WinnerVariantID, &e.CreatedBy, &e.StartedAt, &e.EndedAt, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := getExperimentVariants(db, e.ID)
+	if err != nil {
+		return nil, err
+	}
+	e.Variants = variants
+
+	return &e, nil
+}
+
+func getExperimentVariants(db *sql.DB, experimentID string) ([]models.ExperimentVariant, error) {
+	query := `
+		SELECT id, experiment_id, name, model_id, system_prompt, traffic_percent, created_at
+		FROM experiment_variants
+		WHERE experiment_id = $1
+		ORDER BY created_at, name`
+
+	rows, err := db.Query(query, experimentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []models.ExperimentVariant
+	for rows.Next() {
+		var v models.ExperimentVariant
+		if err := rows.Scan(&v.ID, &v.ExperimentID, &v.Name, &v.ModelID, &v.SystemPrompt, &v.TrafficPercent, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+
+	return variants, rows.Err()
+}
+
+// UpdateExperiment updates an experiment; status transitions stamp started_at/ended_at
+func UpdateExperiment(db *sql.DB, experimentID string, req models.UpdateExperimentRequest) (*models.Experiment, error) {
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *req.Name)
+		argIndex++
+	}
+	if req.Description != nil {
+		setParts = append(setParts, fmt.Sprintf("description = $%d", argIndex))
+		args = append(args, *req.Description)
+		argIndex++
+	}
+	if req.Status != nil {
+		if !models.IsValidExperimentStatus(*req.Status) {
+			return nil, fmt.Errorf("invalid experiment status: %s", *req.Status)
+		}
+		setParts = append(setParts, fmt.Sprintf("status = $%d", argIndex))
+		args = append(args, *req.Status)
+		argIndex++
+
+		switch *req.Status {
+		case models.ExperimentStatusRunning:
+			setParts = append(setParts, "started_at = COALESCE(started_at, NOW())", "ended_at = NULL")
+		case models.ExperimentStatusStopped, models.ExperimentStatusCompleted:
+			setParts = append(setParts, "ended_at = NOW()")
+		}
+	}
+	if req.WinnerVariantID != nil {
+		setParts = append(setParts, fmt.Sprintf("winner_variant_id = $%d", argIndex))
+		args = append(args, *req.WinnerVariantID)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated_at = NOW()")
+	args = append(args, experimentID)
+
+	query := fmt.Sprintf(`UPDATE experiments SET %s WHERE id = $%d`, strings.Join(setParts, ", "), argIndex)
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return GetExperimentByID(db, experimentID)
+}
+
+// DeleteExperiment removes an experiment and its variants
+func DeleteExperiment(db *sql.DB, experimentID string) error {
+	_, err := db.Exec("DELETE FROM experiments WHERE id = $1", experimentID)
+	return err
+}
+
+// GetRunningExperimentForEndpoint returns the running experiment for an organization's endpoint.
+// Returns sql.ErrNoRows if there is none.
+func GetRunningExperimentForEndpoint(db *sql.DB, orgID, endpoint string) (*models.Experiment, error) {
+	var experimentID string
+	query := `
+		SELECT id FROM experiments
+		WHERE organization_id = $1 AND endpoint = $2 AND status = 'running'
+		LIMIT 1`
+
+	if err := db.QueryRow(query, orgID, endpoint).Scan(&experimentID); err != nil {
+		return nil, err
+	}
+
+	return GetExperimentByID(db, experimentID)
+}
+
+// GetExperimentReport aggregates usage logs and feedback per variant for comparison
+func GetExperimentReport(db *sql.DB, experimentID string) (*models.ExperimentReport, error) {
+	experiment, err := GetExperimentByID(db, experimentID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		WITH variant_logs AS (
+			SELECT ul.metadata->>'experiment_variant_id' AS variant_id,
+			       ul.request_id, ul.response_status, ul.response_time_ms,
+			       ul.cost_usd, ul.prompt_tokens, ul.completion_tokens
+			FROM usage_logs ul
+			WHERE ul.organization_id = $1
+			  AND ul.metadata->>'experiment_id' = $2
+		)
+		SELECT vl.variant_id,
+		       COUNT(*) AS request_count,
+		       COUNT(*) FILTER (WHERE vl.response_status >= 400) AS error_count,
+		       COALESCE(AVG(vl.response_time_ms), 0) AS avg_latency,
+		       COALESCE(PERCENTILE_CONT(0.95) WITHIN GROUP (ORDER BY vl.response_time_ms), 0) AS p95_latency,
+		       COALESCE(SUM(vl.cost_usd), 0) AS total_cost,
+		       COALESCE(AVG(vl.cost_usd), 0) AS avg_cost,
+		       COALESCE(AVG(vl.prompt_tokens), 0) AS avg_prompt_tokens,
+		       COALESCE(AVG(vl.completion_tokens), 0) AS avg_completion_tokens,
+		       COUNT(rf.id) FILTER (WHERE COALESCE(rf.thumbs_up, rf.rating >= 4)) AS thumbs_up,
+		       COUNT(rf.id) FILTER (WHERE NOT COALESCE(rf.thumbs_up, rf.rating >= 4)) AS thumbs_down,
+		       COALESCE(AVG(rf.rating), 0) AS avg_rating
+		FROM variant_logs vl
+		LEFT JOIN response_feedback rf
+		       ON rf.organization_id = $1 AND rf.request_id = vl.request_id
+		GROUP BY vl.variant_id`
+
+	rows, err := db.Query(query, experiment.OrganizationID, experiment.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statsByVariant := make(map[string]models.ExperimentVariantStats)
+	for rows.Next() {
+		var variantID sql.NullString
+		var s models.ExperimentVariantStats
+		err := rows.Scan(
+			&variantID, &s.RequestCount, &s.ErrorCount, &s.AvgLatencyMS, &s.P95LatencyMS,
+			&s.TotalCost, &s.AvgCost, &s.AvgPromptTokens, &s.AvgCompletionTokens,
+			&s.ThumbsUp, &s.ThumbsDown, &s.AvgRating,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if variantID.Valid {
+			statsByVariant[variantID.String] = s
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &models.ExperimentReport{
+		Experiment:  *experiment,
+		Variants:    make([]models.ExperimentVariantStats, 0, len(experiment.Variants)),
+		GeneratedAt: time.Now(),
+	}
+
+	for _, v := range experiment.Variants {
+		s := statsByVariant[v.ID]
+		s.VariantID = v.ID
+		s.VariantName = v.Name
+		s.ModelID = v.ModelID
+		s.TrafficPercent = v.TrafficPercent
+		if rated := s.ThumbsUp + s.ThumbsDown; rated > 0 {
+			s.SatisfactionRate = float64(s.ThumbsUp) / float64(rated) * 100
+		}
+		report.Variants = append(report.Variants, s)
+	}
+
+	return report, nil
+}
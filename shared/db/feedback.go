@@ -0,0 +1,182 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Feedback operations
+
+// CreateResponseFeedback records (or replaces) feedback for a request and links it to the
+// matching usage log, found by the gateway's request ID or, for older clients, the provider's.
+// Usage logs are written asynchronously, so the link may be filled in by a later submission;
+// analytics fall back to matching on request_id.
+func CreateResponseFeedback(db *sql.DB, orgID string, apiKeyID *string, req models.CreateFeedbackRequest) (*models.ResponseFeedback, error) {
+	var f models.ResponseFeedback
+	query := `
+		INSERT INTO response_feedback (organization_id, api_key_id, request_id, usage_log_id, thumbs_up, rating, comment)
+		VALUES ($1, $2, $3,
+			(SELECT id FROM usage_logs
+			 WHERE organization_id = $1 AND (request_id = $3 OR metadata->>'provider_request_id' = $3)
+			 ORDER BY created_at DESC LIMIT 1),
+			$4, $5, $6)
+		ON CONFLICT (organization_id, request_id)
+		DO UPDATE SET thumbs_up = EXCLUDED.thumbs_up,
+		              rating = EXCLUDED.rating,
+		              comment = EXCLUDED.comment,
+		              api_key_id = EXCLUDED.api_key_id,
+		              usage_log_id = COALESCE(EXCLUDED.usage_log_id, response_feedback.usage_log_id),
+		              created_at = NOW()
+		RETURNING id, organization_id, api_key_id, request_id, usage_log_id, thumbs_up, rating, comment, created_at`
+
+	err := db.QueryRow(query, orgID, apiKeyID, req.RequestID, req.ThumbsUp, req.Rating, req.Comment).Scan(
+		&f.ID, &f.OrganizationID, &f.APIKeyID, &f.RequestID, &f.UsageLogID,
+		&f.ThumbsUp, &f.Rating, &f.Comment, &f.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// feedbackWithUsageCTE joins feedback to its usage log (by id, or by request_id until linked)
+const feedbackWithUsageCTE = `
+	WITH fb AS (
+		SELECT rf.request_id, rf.thumbs_up, rf.rating, rf.comment, rf.created_at,
+		       COALESCE(rf.thumbs_up, rf.rating >= 4) AS positive,
+		       ul.model_id AS log_model_id, ul.endpoint
+		FROM response_feedback rf
+		LEFT JOIN LATERAL (
+			SELECT u.model_id, u.endpoint
+			FROM usage_logs u
+			WHERE u.id = rf.usage_log_id
+			   OR (rf.usage_log_id IS NULL AND u.organization_id = rf.organization_id AND u.request_id = rf.request_id)
+			ORDER BY u.created_at DESC
+			LIMIT 1
+		) ul ON true
+		WHERE rf.created_at >= $1
+		  AND ($2 = '' OR rf.organization_id = $2::uuid)
+	)`
+
+const feedbackStatsColumns = `
+		COUNT(*) AS feedback_count,
+		COUNT(*) FILTER (WHERE fb.positive) AS positive_count,
+		COUNT(*) FILTER (WHERE NOT fb.positive) AS negative_count,
+		COUNT(fb.rating) AS rating_count,
+		COALESCE(AVG(fb.rating), 0) AS avg_rating`
+
+// GetFeedbackAnalytics summarizes response satisfaction overall, by model and by endpoint
+func GetFeedbackAnalytics(db *sql.DB, filter models.AnalyticsFilter) (*models.FeedbackAnalytics, error) {
+	startTime, err := parseTimeRange(filter.TimeRange, filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	analytics := &models.FeedbackAnalytics{
+		ByModel:        []models.FeedbackStats{},
+		ByEndpoint:     []models.FeedbackStats{},
+		RecentComments: []models.FeedbackComment{},
+		TimeRange:      filter.TimeRange,
+		Organization:   filter.Organization,
+		GeneratedAt:    time.Now(),
+	}
+
+	// Overall
+	overall := models.FeedbackStats{Name: "All"}
+	err = db.QueryRow(feedbackWithUsageCTE+`SELECT `+feedbackStatsColumns+` FROM fb`, startTime, filter.Organization).Scan(
+		&overall.FeedbackCount, &overall.PositiveCount, &overall.NegativeCount, &overall.RatingCount, &overall.AvgRating,
+	)
+	if err != nil {
+		return nil, err
+	}
+	setSatisfactionRate(&overall)
+	analytics.Overall = overall
+
+	// By model
+	modelQuery := feedbackWithUsageCTE + `
+		SELECT COALESCE(m.name, 'Unknown'), COALESCE(m.model_id, ''),` + feedbackStatsColumns + `
+		FROM fb
+		LEFT JOIN models m ON fb.log_model_id = m.id
+		GROUP BY m.name, m.model_id
+		ORDER BY feedback_count DESC`
+
+	rows, err := db.Query(modelQuery, startTime, filter.Organization)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.FeedbackStats
+		err := rows.Scan(&s.Name, &s.ModelID, &s.FeedbackCount, &s.PositiveCount, &s.NegativeCount, &s.RatingCount, &s.AvgRating)
+		if err != nil {
+			return nil, err
+		}
+		setSatisfactionRate(&s)
+		analytics.ByModel = append(analytics.ByModel, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// By endpoint
+	endpointQuery := feedbackWithUsageCTE + `
+		SELECT COALESCE(fb.endpoint, 'Unknown'),` + feedbackStatsColumns + `
+		FROM fb
+		GROUP BY fb.endpoint
+		ORDER BY feedback_count DESC`
+
+	endpointRows, err := db.Query(endpointQuery, startTime, filter.Organization)
+	if err != nil {
+		return nil, err
+	}
+	defer endpointRows.Close()
+
+	for endpointRows.Next() {
+		var s models.FeedbackStats
+		err := endpointRows.Scan(&s.Name, &s.FeedbackCount, &s.PositiveCount, &s.NegativeCount, &s.RatingCount, &s.AvgRating)
+		if err != nil {
+			return nil, err
+		}
+		setSatisfactionRate(&s)
+		analytics.ByEndpoint = append(analytics.ByEndpoint, s)
+	}
+	if err := endpointRows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Most recent comments
+	commentQuery := feedbackWithUsageCTE + `
+		SELECT fb.request_id, m.model_id, fb.endpoint, fb.thumbs_up, fb.rating, fb.comment, fb.created_at
+		FROM fb
+		LEFT JOIN models m ON fb.log_model_id = m.id
+		WHERE fb.comment IS NOT NULL AND fb.comment <> ''
+		ORDER BY fb.created_at DESC
+		LIMIT 20`
+
+	commentRows, err := db.Query(commentQuery, startTime, filter.Organization)
+	if err != nil {
+		return nil, err
+	}
+	defer commentRows.Close()
+
+	for commentRows.Next() {
+		var fc models.FeedbackComment
+		err := commentRows.Scan(&fc.RequestID, &fc.ModelID, &fc.Endpoint, &fc.ThumbsUp, &fc.Rating, &fc.Comment, &fc.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		analytics.RecentComments = append(analytics.RecentComments, fc)
+	}
+
+	return analytics, commentRows.Err()
+}
+
+func setSatisfactionRate(s *models.FeedbackStats) {
+	if rated := s.PositiveCount + s.NegativeCount; rated > 0 {
+		s.SatisfactionRate = float64(s.PositiveCount) / float64(rated) * 100
+	}
+}
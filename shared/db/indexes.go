@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"strings"
+)
+
+// hotQueryIndexes are the indexes the busiest queries depend on: usage analytics filter
+// usage_logs by organization and time, and list pages sort newest first. A database missing one
+// still works, just with sequential scans, so they are checked separately from schema drift to
+// make the warning stand out.
+var hotQueryIndexes = map[string]string{
+	"idx_usage_logs_org_created_at":      "usage_logs by organization and time",
+	"idx_usage_logs_created_at_org_id":   "usage_logs by time",
+	"idx_usage_logs_model_id_created_at": "usage_logs joined to models",
+	"idx_usage_logs_api_key_created_at":  "usage_logs joined to api_keys",
+	"idx_api_keys_active_created_at":     "API key list",
+	"idx_api_keys_org_active_created_at": "API key list by organization",
+	"idx_email_logs_created_at":          "email log list",
+}
+
+// missingHotQueryIndexes lists the hot query indexes absent from the database
+func missingHotQueryIndexes(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT indexname FROM pg_indexes WHERE schemaname = 'public'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	present := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		present[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for name := range hotQueryIndexes {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// logMissingHotQueryIndexes warns at startup about absent hot query indexes
+func logMissingHotQueryIndexes(db *sql.DB) {
+	missing, err := missingHotQueryIndexes(db)
+	if err != nil {
+		log.Printf("Warning: could not check for missing indexes: %v", err)
+		return
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	var details []string
+	for _, name := range missing {
+		details = append(details, name+" ("+hotQueryIndexes[name]+")")
+	}
+	log.Printf("Warning: %d indexes used by frequent queries are missing, expect slow analytics and list pages: %s. "+
+		"Create them with `relai-admin schema apply` or start with DB_AUTO_MIGRATE=true",
+		len(missing), strings.Join(details, ", "))
+}
@@ -1,57 +1,47 @@
 package db
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	_ "embed"
+	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
 
 	_ "github.com/lib/pq"
+
+	"github.com/like-mike/relai-gateway/shared/secretfile"
 )
 
-func InitDB() (*sql.DB, error) {
-	// Get database connection string from POSTGRES_DSN environment variable
-	connStr := os.Getenv("POSTGRES_DSN")
+// schemaSQL creates a fresh database, and is the expected schema existing ones are checked
+// against for drift
+//
+//go:embed schema.sql
+var schemaSQL string
 
-	// If POSTGRES_DSN is not set, fall back to individual environment variables
-	if connStr == "" {
-		// Database connection parameters
-		dbHost := os.Getenv("DB_HOST")
-		dbPort := os.Getenv("DB_PORT")
-		dbUser := os.Getenv("DB_USER")
-		dbPassword := os.Getenv("DB_PASSWORD")
-		dbName := os.Getenv("DB_NAME")
-		dbSSLMode := os.Getenv("DB_SSLMODE")
+// SchemaVersion identifies the schema this build expects: a short hash of schema.sql, so two
+// builds report the same version exactly when they expect the same schema
+func SchemaVersion() string {
+	sum := sha256.Sum256([]byte(schemaSQL))
+	return hex.EncodeToString(sum[:6])
+}
 
-		// Set defaults if not provided
-		if dbHost == "" {
-			dbHost = "localhost"
-		}
-		if dbPort == "" {
-			dbPort = "5432"
-		}
-		if dbUser == "" {
-			dbUser = "postgres"
-		}
-		if dbPassword == "" {
-			dbPassword = "postgres"
-		}
-		if dbName == "" {
-			dbName = "relai_gateway"
-		}
-		if dbSSLMode == "" {
-			dbSSLMode = "disable"
-		}
+// InitDB connects to the database and creates the schema on a fresh one. An existing database
+// missing tables or columns this release needs fails unless DB_AUTO_MIGRATE applies them.
+func InitDB() (*sql.DB, error) {
+	return initDB(true)
+}
 
-		// Build connection string from individual components
-		connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-			dbHost, dbPort, dbUser, dbPassword, dbName, dbSSLMode)
-	}
+// InitDBWithDrift connects like InitDB, but a database missing tables or columns only logs a
+// warning, for tools that repair the schema
+func InitDBWithDrift() (*sql.DB, error) {
+	return initDB(false)
+}
 
+func initDB(strict bool) (*sql.DB, error) {
 	// Open database connection
-	db, err := sql.Open("postgres", connStr)
+	db, err := openDB(connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -62,7 +52,7 @@ func InitDB() (*sql.DB, error) {
 	}
 
 	// Initialize schema if needed
-	if err := initializeSchema(db); err != nil {
+	if err := initializeSchema(db, strict); err != nil {
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
@@ -70,7 +60,48 @@ func InitDB() (*sql.DB, error) {
 	return db, nil
 }
 
-func initializeSchema(db *sql.DB) error {
+// connectionString builds the DSN from POSTGRES_DSN, or the DB_* environment variables when
+// that isn't set. The DSN, user and password may be read from files (see secretfile), and are
+// read again for every new connection so rotated credentials are picked up.
+func connectionString() string {
+	if connStr := secretfile.Get("POSTGRES_DSN"); connStr != "" {
+		return connStr
+	}
+
+	// Database connection parameters
+	dbHost := os.Getenv("DB_HOST")
+	dbPort := os.Getenv("DB_PORT")
+	dbUser := secretfile.Get("DB_USER")
+	dbPassword := secretfile.Get("DB_PASSWORD")
+	dbName := os.Getenv("DB_NAME")
+	dbSSLMode := os.Getenv("DB_SSLMODE")
+
+	// Set defaults if not provided
+	if dbHost == "" {
+		dbHost = "localhost"
+	}
+	if dbPort == "" {
+		dbPort = "5432"
+	}
+	if dbUser == "" {
+		dbUser = "postgres"
+	}
+	if dbPassword == "" {
+		dbPassword = "postgres"
+	}
+	if dbName == "" {
+		dbName = "relai_gateway"
+	}
+	if dbSSLMode == "" {
+		dbSSLMode = "disable"
+	}
+
+	// Build connection string from individual components
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		dbHost, dbPort, dbUser, dbPassword, dbName, dbSSLMode)
+}
+
+func initializeSchema(db *sql.DB, strict bool) error {
 	// Check if the organizations table exists
 	var exists bool
 	query := `SELECT EXISTS (
@@ -92,53 +123,33 @@ func initializeSchema(db *sql.DB) error {
 			return err
 		}
 		log.Println("Database schema initialized successfully")
-	} else {
-		log.Println("Database schema already exists, checking for updates...")
-		err = updateSchema(db)
+	} else if autoMigrate() {
+		log.Println("Database schema already exists, applying updates (DB_AUTO_MIGRATE)...")
+		report, err := ApplySchemaFixes(db)
 		if err != nil {
 			return err
 		}
+		if len(report.Drift) > 0 {
+			log.Printf("Warning: %d schema differences need manual attention", len(report.Drift))
+		}
+	} else {
+		// Existing databases are only checked; fixes are applied on request, and until then a
+		// database missing tables or columns fails the boot rather than failing requests
+		log.Println("Database schema already exists, checking for drift...")
+		if err := checkSchemaAtBoot(db, strict); err != nil {
+			return err
+		}
+	}
+	if exists {
+		logMissingHotQueryIndexes(db)
 	}
 
 	return nil
 }
 
 func createSchema(db *sql.DB) error {
-	// Get the current working directory
-	wd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
-	}
-
-	// Try different possible paths for the schema file
-	schemaPaths := []string{
-		filepath.Join(wd, "shared", "db", "schema.sql"),
-		filepath.Join(wd, "..", "shared", "db", "schema.sql"),
-		filepath.Join(wd, "..", "..", "shared", "db", "schema.sql"),
-		"shared/db/schema.sql",
-	}
-
-	var schemaContent []byte
-	var schemaPath string
-
-	for _, path := range schemaPaths {
-		if _, err := os.Stat(path); err == nil {
-			schemaContent, err = ioutil.ReadFile(path)
-			if err == nil {
-				schemaPath = path
-				break
-			}
-		}
-	}
-
-	if schemaContent == nil {
-		return fmt.Errorf("schema.sql file not found in any of the expected locations: %v", schemaPaths)
-	}
-
-	log.Printf("Loading schema from: %s", schemaPath)
-
 	// Execute the schema
-	_, err = db.Exec(string(schemaContent))
+	_, err := db.Exec(schemaSQL)
 	if err != nil {
 		return fmt.Errorf("failed to execute schema: %w", err)
 	}
@@ -310,12 +321,1373 @@ func updateSchema(db *sql.DB) error {
 		log.Println("Email tables created successfully")
 	}
 
-	if !hasAPIEndpoint || !hasAPIToken || hasUniqueConstraint || !emailTablesExist {
-		log.Println("Schema updated successfully")
+	// Check if experiment tables exist
+	experimentTablesExist, err := tableExists(db, "experiments")
+	if err != nil {
+		return fmt.Errorf("failed to check experiments table: %w", err)
 	}
 
-	return nil
+	if !experimentTablesExist {
+		log.Println("Experiment tables not found, creating them...")
+		experimentSQL := `
+		CREATE TABLE IF NOT EXISTS experiments (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    name VARCHAR(255) NOT NULL,
+		    description TEXT,
+		    endpoint VARCHAR(255) NOT NULL,
+		    status VARCHAR(50) NOT NULL DEFAULT 'draft',
+		    winner_variant_id UUID,
+		    created_by UUID REFERENCES users(id),
+		    started_at TIMESTAMP WITH TIME ZONE,
+		    ended_at TIMESTAMP WITH TIME ZONE,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS experiment_variants (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    experiment_id UUID NOT NULL REFERENCES experiments(id) ON DELETE CASCADE,
+		    name VARCHAR(255) NOT NULL,
+		    model_id VARCHAR(255) NOT NULL,
+		    system_prompt TEXT,
+		    traffic_percent INTEGER NOT NULL CHECK (traffic_percent >= 0 AND traffic_percent <= 100),
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS response_feedback (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+		    request_id VARCHAR(255) NOT NULL,
+		    thumbs_up BOOLEAN NOT NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(organization_id, request_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_experiments_org_id ON experiments(organization_id);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_experiments_running_endpoint ON experiments(organization_id, endpoint) WHERE status = 'running';
+		CREATE INDEX IF NOT EXISTS idx_experiment_variants_experiment_id ON experiment_variants(experiment_id);
+		CREATE INDEX IF NOT EXISTS idx_response_feedback_request_id ON response_feedback(request_id);
+		CREATE INDEX IF NOT EXISTS idx_usage_logs_request_id ON usage_logs(request_id);
+		`
+
+		_, err = db.Exec(experimentSQL)
+		if err != nil {
+			return fmt.Errorf("failed to create experiment tables: %w", err)
+		}
+
+		log.Println("Experiment tables created successfully")
+	}
+
+	// Check if response_feedback supports ratings and comments
+	hasFeedbackRating, err := columnExists(db, "response_feedback", "rating")
+	if err != nil {
+		return fmt.Errorf("failed to check response_feedback.rating column: %w", err)
+	}
+
+	if !hasFeedbackRating {
+		log.Println("Adding rating, comment and usage_log_id columns to response_feedback table...")
+		_, err = db.Exec(`
+		ALTER TABLE response_feedback ALTER COLUMN thumbs_up DROP NOT NULL;
+		ALTER TABLE response_feedback ADD COLUMN IF NOT EXISTS usage_log_id UUID REFERENCES usage_logs(id) ON DELETE SET NULL;
+		ALTER TABLE response_feedback ADD COLUMN IF NOT EXISTS rating SMALLINT CHECK (rating >= 1 AND rating <= 5);
+		ALTER TABLE response_feedback ADD COLUMN IF NOT EXISTS comment TEXT;
+		CREATE INDEX IF NOT EXISTS idx_response_feedback_created_at ON response_feedback(created_at);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add response_feedback columns: %w", err)
+		}
+	}
+
+	// Check if response_schemas table exists
+	responseSchemasExist, err := tableExists(db, "response_schemas")
+	if err != nil {
+		return fmt.Errorf("failed to check response_schemas table: %w", err)
+	}
+
+	if !responseSchemasExist {
+		log.Println("Response schemas table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS response_schemas (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    name VARCHAR(255) NOT NULL,
+		    endpoint VARCHAR(255) NOT NULL, -- e.g., "/v1/chat/completions"
+		    schema JSONB NOT NULL,
+		    retry_on_failure BOOLEAN DEFAULT false,
+		    max_retries INTEGER DEFAULT 1 CHECK (max_retries >= 0 AND max_retries <= 3),
+		    is_active BOOLEAN DEFAULT true,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_response_schemas_active_endpoint ON response_schemas(organization_id, endpoint) WHERE is_active = true;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create response_schemas table: %w", err)
+		}
+	}
+
+	// Check if secret scanning tables exist
+	secretScanTablesExist, err := tableExists(db, "secret_scan_policies")
+	if err != nil {
+		return fmt.Errorf("failed to check secret_scan_policies table: %w", err)
+	}
+
+	if !secretScanTablesExist {
+		log.Println("Secret scanning tables not found, creating them...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS secret_scan_policies (
+		    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+		    mode VARCHAR(20) NOT NULL DEFAULT 'flag', -- 'off', 'flag', 'block'
+		    custom_patterns JSONB DEFAULT '[]', -- [{"name": "...", "pattern": "..."}]
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS secret_scan_incidents (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+		    model_id VARCHAR(255), -- Requested model name
+		    endpoint VARCHAR(255) NOT NULL,
+		    action VARCHAR(20) NOT NULL, -- 'blocked', 'flagged'
+		    findings JSONB NOT NULL, -- [{"type": "...", "preview": "..."}], secrets are masked
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_secret_scan_incidents_org_created ON secret_scan_incidents(organization_id, created_at);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create secret scanning tables: %w", err)
+		}
+	}
+
+	// Check if audit log table exists
+	auditLogsExist, err := tableExists(db, "audit_logs")
+	if err != nil {
+		return fmt.Errorf("failed to check audit_logs table: %w", err)
+	}
+
+	if !auditLogsExist {
+		log.Println("Audit log table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_logs (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		    action VARCHAR(100) NOT NULL, -- e.g. 'secret.reveal'
+		    resource_type VARCHAR(50) NOT NULL, -- e.g. 'model', 'email_settings'
+		    resource_id VARCHAR(255),
+		    details JSONB DEFAULT '{}',
+		    ip_address VARCHAR(45),
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create audit_logs table: %w", err)
+		}
+	}
+
+	// Check if email outbox table exists
+	emailOutboxExists, err := tableExists(db, "email_outbox")
+	if err != nil {
+		return fmt.Errorf("failed to check email_outbox table: %w", err)
+	}
+
+	if !emailOutboxExists {
+		log.Println("Email outbox table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS email_outbox (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    recipient_email VARCHAR(255) NOT NULL,
+		    subject VARCHAR(500) NOT NULL,
+		    html_body TEXT NOT NULL,
+		    template_id UUID REFERENCES email_templates(id) ON DELETE SET NULL,
+		    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'sending', 'sent', 'failed'
+		    attempts INTEGER NOT NULL DEFAULT 0,
+		    max_attempts INTEGER NOT NULL DEFAULT 5,
+		    scheduled_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(), -- Requested delivery time
+		    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(), -- Pushed back on each failed attempt
+		    last_error TEXT, -- Failure reason from the most recent attempt
+		    sent_at TIMESTAMP WITH TIME ZONE,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_email_outbox_status_next_attempt ON email_outbox(status, next_attempt_at);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create email_outbox table: %w", err)
+		}
+	}
+
+	// Check if API key expiry reminders are set up
+	hasAPIKeyExpiry, err := columnExists(db, "api_keys", "expires_at")
+	if err != nil {
+		return fmt.Errorf("failed to check api_keys.expires_at column: %w", err)
+	}
+
+	if !hasAPIKeyExpiry {
+		log.Println("Adding expires_at column to api_keys and creating expiry reminder table...")
+		_, err = db.Exec(`
+		ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+		CREATE INDEX IF NOT EXISTS idx_api_keys_expires_at ON api_keys(expires_at) WHERE expires_at IS NOT NULL;
+
+		CREATE TABLE IF NOT EXISTS api_key_reminders (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    api_key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+		    schedule_type VARCHAR(100) NOT NULL, -- 'api_key_warning', 'api_key_expiration'
+		    days_before INTEGER NOT NULL DEFAULT 0,
+		    expires_at TIMESTAMP WITH TIME ZONE NOT NULL, -- Expiry the reminder was sent for; a new expiry re-arms reminders
+		    outbox_id UUID REFERENCES email_outbox(id) ON DELETE SET NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(api_key_id, schedule_type, days_before, expires_at)
+		);
+
+		INSERT INTO email_schedules (schedule_type, days_before)
+		SELECT t.schedule_type, t.days_before
+		FROM (VALUES ('api_key_warning', 7), ('api_key_warning', 3), ('api_key_warning', 1), ('api_key_expiration', 0)) AS t(schedule_type, days_before)
+		WHERE NOT EXISTS (SELECT 1 FROM email_schedules);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to set up API key expiry reminders: %w", err)
+		}
+	}
+
+	// Check if quota notification tables exist
+	quotaNotificationsExist, err := tableExists(db, "quota_notifications")
+	if err != nil {
+		return fmt.Errorf("failed to check quota_notifications table: %w", err)
+	}
+
+	if !quotaNotificationsExist {
+		log.Println("Quota notification tables not found, creating them...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS quota_notification_settings (
+		    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+		    is_enabled BOOLEAN DEFAULT true,
+		    thresholds JSONB DEFAULT '[80, 90, 100]', -- Percent of quota
+		    recipients JSONB DEFAULT '[]', -- Email addresses; empty means the organization's admins
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS quota_notifications (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    threshold INTEGER NOT NULL,
+		    reset_date TIMESTAMP WITH TIME ZONE NOT NULL, -- Quota period the threshold was crossed in
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(organization_id, threshold, reset_date)
+		);
+
+		INSERT INTO email_templates (id, name, type, subject, html_body, text_body) VALUES
+		('10000000-0000-0000-0000-000000000003', 'Quota Usage Alert', 'usage',
+		 'Token quota {{.Threshold}}% used for {{.OrganizationName}}',
+		 '<!DOCTYPE html><html><head><style>body{font-family:Arial,sans-serif;margin:40px;color:#333}.header{background:#f8f9fa;padding:20px;border-radius:8px;margin-bottom:20px}.warning{background:#fff3cd;border:1px solid #ffeaa7;padding:15px;border-radius:5px;margin:20px 0}table{border-collapse:collapse;margin:10px 0}td,th{padding:6px 12px;border-bottom:1px solid #eee;text-align:left}.button{display:inline-block;background:#007bff;color:white;padding:10px 20px;text-decoration:none;border-radius:5px;margin:10px 0}</style></head><body><div class="header"><h2>📊 Token Quota Usage Alert</h2></div><p>Hello {{.UserName}},</p><p>{{.OrganizationName}} has used {{.Threshold}}% of its token quota:</p><div class="warning"><strong>Current usage:</strong> {{.UsedTokens}} of {{.TotalQuota}} tokens ({{.UsagePercent}})<br><strong>Quota resets:</strong> {{.ResetDate}}</div>{{if .TopAPIKeys}}<p>Top consuming API keys (last 30 days):</p><table><tr><th>API Key</th><th>Tokens</th></tr>{{range .TopAPIKeys}}<tr><td>{{.Name}}</td><td>{{.Tokens}}</td></tr>{{end}}</table>{{end}}<p>Requests may be rejected once the quota is exhausted.</p><a href="{{.ManagementURL}}" class="button">Manage API Keys</a><p>Best regards,<br>RelAI Gateway Team</p></body></html>',
+		 'Hello {{.UserName}}, {{.OrganizationName}} has used {{.Threshold}}% of its token quota ({{.UsedTokens}} of {{.TotalQuota}} tokens). The quota resets on {{.ResetDate}}. Manage API keys at: {{.ManagementURL}}')
+		ON CONFLICT (id) DO NOTHING;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create quota notification tables: %w", err)
+		}
+	}
+
+	// Check if notification channels table exists
+	notificationChannelsExist, err := tableExists(db, "notification_channels")
+	if err != nil {
+		return fmt.Errorf("failed to check notification_channels table: %w", err)
+	}
+
+	if !notificationChannelsExist {
+		log.Println("Notification channels table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS notification_channels (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    name VARCHAR(255) NOT NULL,
+		    type VARCHAR(20) NOT NULL, -- 'slack', 'teams'
+		    webhook_url TEXT NOT NULL,
+		    events JSONB DEFAULT '[]', -- e.g. ["api_key_expiry", "quota_usage"]; empty means all events
+		    is_enabled BOOLEAN DEFAULT true,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_notification_channels_org_id ON notification_channels(organization_id);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create notification_channels table: %w", err)
+		}
+	}
+
+	// Check if organization branding table exists
+	organizationBrandingExists, err := tableExists(db, "organization_branding")
+	if err != nil {
+		return fmt.Errorf("failed to check organization_branding table: %w", err)
+	}
+
+	if !organizationBrandingExists {
+		log.Println("Organization branding table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS organization_branding (
+		    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+		    logo_url TEXT,
+		    primary_color VARCHAR(7), -- #rrggbb
+		    secondary_color VARCHAR(7),
+		    accent_color VARCHAR(7),
+		    from_name VARCHAR(255), -- Overrides smtp_from_name for the organization's emails
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create organization_branding table: %w", err)
+		}
+	}
+
+	// Check if email template versioning is set up
+	templateVersionsExist, err := tableExists(db, "email_template_versions")
+	if err != nil {
+		return fmt.Errorf("failed to check email_template_versions table: %w", err)
+	}
+
+	if !templateVersionsExist {
+		log.Println("Email template versions table not found, creating it...")
+		_, err = db.Exec(`
+		ALTER TABLE email_templates ADD COLUMN IF NOT EXISTS current_version INTEGER NOT NULL DEFAULT 1;
+
+		CREATE TABLE IF NOT EXISTS email_template_versions (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    template_id UUID NOT NULL REFERENCES email_templates(id) ON DELETE CASCADE,
+		    version INTEGER NOT NULL,
+		    subject VARCHAR(500) NOT NULL,
+		    html_body TEXT NOT NULL,
+		    text_body TEXT,
+		    status VARCHAR(20) NOT NULL DEFAULT 'draft', -- 'draft', 'published', 'archived'
+		    source_version INTEGER, -- Version restored by a rollback
+		    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    published_at TIMESTAMP WITH TIME ZONE,
+		    UNIQUE(template_id, version)
+		);
+
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_email_template_versions_draft ON email_template_versions(template_id) WHERE status = 'draft';
+
+		INSERT INTO email_template_versions (template_id, version, subject, html_body, text_body, status, published_at)
+		SELECT id, 1, subject, html_body, text_body, 'published', updated_at FROM email_templates
+		ON CONFLICT (template_id, version) DO NOTHING;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create email_template_versions table: %w", err)
+		}
+	}
+
+	// Check if locale columns exist
+	hasTemplateLocale, err := columnExists(db, "email_templates", "locale")
+	if err != nil {
+		return fmt.Errorf("failed to check email_templates.locale column: %w", err)
+	}
+
+	if !hasTemplateLocale {
+		log.Println("Adding locale columns to users, organizations and email_templates...")
+		_, err = db.Exec(`
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(10);
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS locale VARCHAR(10);
+		ALTER TABLE email_templates ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en';
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add locale columns: %w", err)
+		}
+	}
+
+	// Check if notification preferences table exists
+	notificationPreferencesExist, err := tableExists(db, "notification_preferences")
+	if err != nil {
+		return fmt.Errorf("failed to check notification_preferences table: %w", err)
+	}
+
+	if !notificationPreferencesExist {
+		log.Println("Notification preferences table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS notification_preferences (
+		    email VARCHAR(255) NOT NULL, -- Lowercased; recipients need not be users
+		    event_type VARCHAR(50) NOT NULL, -- 'api_key_expiry', 'quota_usage'
+		    channel VARCHAR(20) NOT NULL, -- 'email'
+		    is_enabled BOOLEAN DEFAULT true,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    PRIMARY KEY (email, event_type, channel)
+		);
+
+		ALTER TABLE email_outbox ADD COLUMN IF NOT EXISTS notification_type VARCHAR(50);
+		ALTER TABLE email_settings ADD COLUMN IF NOT EXISTS unsubscribe_secret TEXT DEFAULT (gen_random_uuid()::text || gen_random_uuid()::text);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create notification_preferences table: %w", err)
+		}
+	}
+
+	// Check if DKIM keys table exists
+	dkimKeysExist, err := tableExists(db, "dkim_keys")
+	if err != nil {
+		return fmt.Errorf("failed to check dkim_keys table: %w", err)
+	}
+
+	if !dkimKeysExist {
+		log.Println("DKIM keys table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS dkim_keys (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    domain VARCHAR(255) NOT NULL UNIQUE,
+		    selector VARCHAR(63) NOT NULL,
+		    private_key TEXT NOT NULL, -- PEM, encrypted with ENCRYPTION_KEY
+		    public_key TEXT NOT NULL, -- Base64 DER, published as the DNS p= tag
+		    is_enabled BOOLEAN DEFAULT true,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		ALTER TABLE email_settings ADD COLUMN IF NOT EXISTS smtp_tls_mode VARCHAR(20) DEFAULT 'auto';
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create dkim_keys table: %w", err)
+		}
+	}
+
+	// Check if memberships record their source
+	hasMembershipSource, err := columnExists(db, "user_organizations", "source")
+	if err != nil {
+		return fmt.Errorf("failed to check user_organizations.source column: %w", err)
+	}
+
+	if !hasMembershipSource {
+		log.Println("Adding source column to user_organizations...")
+		_, err = db.Exec(`ALTER TABLE user_organizations ADD COLUMN IF NOT EXISTS source VARCHAR(20) NOT NULL DEFAULT 'ad_sync'`)
+		if err != nil {
+			return fmt.Errorf("failed to add user_organizations.source column: %w", err)
+		}
+	}
+
+	// Check if custom organization roles table exists
+	organizationRolesExist, err := tableExists(db, "organization_roles")
+	if err != nil {
+		return fmt.Errorf("failed to check organization_roles table: %w", err)
+	}
+
+	if !organizationRolesExist {
+		log.Println("Organization roles table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS organization_roles (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    name VARCHAR(50) NOT NULL, -- Stored in user_organizations.role_name
+		    description TEXT,
+		    permissions TEXT[] NOT NULL DEFAULT '{}', -- e.g. keys:read, keys:write, analytics:read
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(organization_id, name)
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create organization_roles table: %w", err)
+		}
+	}
+
+	// Check if AD group mappings have a priority for conflict resolution
+	hasADGroupPriority, err := columnExists(db, "organization_ad_groups", "priority")
+	if err != nil {
+		return fmt.Errorf("failed to check organization_ad_groups.priority column: %w", err)
+	}
+
+	if !hasADGroupPriority {
+		log.Println("Adding priority column to organization_ad_groups...")
+		_, err = db.Exec(`ALTER TABLE organization_ad_groups ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 100`)
+		if err != nil {
+			return fmt.Errorf("failed to add organization_ad_groups.priority column: %w", err)
+		}
+	}
 
+	// Check if API keys carry a description, owner and scopes
+	hasAPIKeyOwner, err := columnExists(db, "api_keys", "owner_user_id")
+	if err != nil {
+		return fmt.Errorf("failed to check api_keys.owner_user_id column: %w", err)
+	}
+
+	if !hasAPIKeyOwner {
+		log.Println("Adding description, owner_user_id and scopes columns to api_keys...")
+		_, err = db.Exec(`
+		ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS description TEXT;
+		ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS owner_user_id UUID REFERENCES users(id);
+		ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+		UPDATE api_keys SET owner_user_id = created_by_user_id WHERE owner_user_id IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_api_keys_owner_user_id ON api_keys(owner_user_id);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add api_keys owner columns: %w", err)
+		}
+	}
+
+	// Check if request payload logging and replay tables exist
+	requestReplayTablesExist, err := tableExists(db, "request_replays")
+	if err != nil {
+		return fmt.Errorf("failed to check request_replays table: %w", err)
+	}
+
+	if !requestReplayTablesExist {
+		log.Println("Request payload and replay tables not found, creating them...")
+		_, err = db.Exec(`
+		-- Logged request/response bodies, written by the gateway when payload logging is on
+		CREATE TABLE IF NOT EXISTS request_payloads (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+		    model_id UUID REFERENCES models(id) ON DELETE SET NULL,
+		    endpoint VARCHAR(255) NOT NULL,
+		    request_body TEXT NOT NULL,
+		    response_status INTEGER NOT NULL,
+		    response_body TEXT,
+		    response_time_ms INTEGER,
+		    truncated BOOLEAN NOT NULL DEFAULT false, -- A body was cut to the logging size limit
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		-- Sandbox replays of logged requests. Replays call the provider directly and are never written
+		-- to usage_logs, so they stay out of analytics, quotas and billing.
+		CREATE TABLE IF NOT EXISTS request_replays (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    payload_id UUID NOT NULL REFERENCES request_payloads(id) ON DELETE CASCADE,
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    model_id UUID REFERENCES models(id) ON DELETE SET NULL,
+		    request_body TEXT NOT NULL, -- As sent, with the model swapped in
+		    response_status INTEGER NOT NULL,
+		    response_body TEXT,
+		    response_time_ms INTEGER,
+		    error TEXT, -- Set when the provider couldn't be reached
+		    replayed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_request_payloads_org_created ON request_payloads(organization_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_request_replays_payload ON request_replays(payload_id, created_at);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create request replay tables: %w", err)
+		}
+	}
+
+	// Check if batch API tables exist
+	batchTablesExist, err := tableExists(db, "batches")
+	if err != nil {
+		return fmt.Errorf("failed to check batches table: %w", err)
+	}
+
+	if !batchTablesExist {
+		log.Println("Batch tables not found, creating them...")
+		_, err = db.Exec(`
+		-- Batch API input files and generated output/error files (JSONL)
+		CREATE TABLE IF NOT EXISTS batch_files (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+		    purpose VARCHAR(20) NOT NULL, -- 'batch', 'batch_output'
+		    filename VARCHAR(255) NOT NULL,
+		    bytes INTEGER NOT NULL,
+		    content BYTEA NOT NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		-- Batch API jobs, processed asynchronously by the gateway. Each request is also written to
+		-- usage_logs; the token totals here are the batch's aggregate.
+		CREATE TABLE IF NOT EXISTS batches (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+		    endpoint VARCHAR(255) NOT NULL,
+		    input_file_id UUID NOT NULL REFERENCES batch_files(id) ON DELETE CASCADE,
+		    output_file_id UUID REFERENCES batch_files(id) ON DELETE SET NULL,
+		    error_file_id UUID REFERENCES batch_files(id) ON DELETE SET NULL,
+		    completion_window VARCHAR(10) NOT NULL DEFAULT '24h',
+		    status VARCHAR(20) NOT NULL, -- 'failed', 'in_progress', 'finalizing', 'completed', 'cancelling', 'cancelled'
+		    errors JSONB DEFAULT '[]',
+		    total_requests INTEGER NOT NULL DEFAULT 0,
+		    completed_requests INTEGER NOT NULL DEFAULT 0,
+		    failed_requests INTEGER NOT NULL DEFAULT 0,
+		    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+		    completion_tokens BIGINT NOT NULL DEFAULT 0,
+		    total_tokens BIGINT NOT NULL DEFAULT 0,
+		    metadata JSONB DEFAULT '{}',
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    in_progress_at TIMESTAMP WITH TIME ZONE,
+		    finalizing_at TIMESTAMP WITH TIME ZONE,
+		    completed_at TIMESTAMP WITH TIME ZONE,
+		    failed_at TIMESTAMP WITH TIME ZONE,
+		    cancelling_at TIMESTAMP WITH TIME ZONE,
+		    cancelled_at TIMESTAMP WITH TIME ZONE,
+		    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_batches_org_created ON batches(organization_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_batches_status ON batches(status);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create batch tables: %w", err)
+		}
+	}
+
+	// Check if models carry AWS credentials for Bedrock request signing
+	hasModelAWSCredentials, err := columnExists(db, "models", "aws_region")
+	if err != nil {
+		return fmt.Errorf("failed to check models.aws_region column: %w", err)
+	}
+
+	if !hasModelAWSCredentials {
+		log.Println("Adding AWS credential columns to models...")
+		_, err = db.Exec(`
+		ALTER TABLE models ADD COLUMN IF NOT EXISTS aws_region VARCHAR(50);
+		ALTER TABLE models ADD COLUMN IF NOT EXISTS aws_access_key_id VARCHAR(128);
+		ALTER TABLE models ADD COLUMN IF NOT EXISTS aws_secret_access_key TEXT;
+		ALTER TABLE models ADD COLUMN IF NOT EXISTS aws_role_arn VARCHAR(255);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add models AWS credential columns: %w", err)
+		}
+	}
+
+	// Check if models carry service account keys for Vertex AI
+	hasModelGCPCredentials, err := columnExists(db, "models", "gcp_location")
+	if err != nil {
+		return fmt.Errorf("failed to check models.gcp_location column: %w", err)
+	}
+
+	if !hasModelGCPCredentials {
+		log.Println("Adding GCP credential columns to models...")
+		_, err = db.Exec(`
+		ALTER TABLE models ADD COLUMN IF NOT EXISTS gcp_location VARCHAR(50);
+		ALTER TABLE models ADD COLUMN IF NOT EXISTS gcp_service_account TEXT;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add models GCP credential columns: %w", err)
+		}
+	}
+
+	// Check if models carry their own request size limit
+	hasModelMaxRequestBytes, err := columnExists(db, "models", "max_request_bytes")
+	if err != nil {
+		return fmt.Errorf("failed to check models.max_request_bytes column: %w", err)
+	}
+
+	if !hasModelMaxRequestBytes {
+		log.Println("Adding max_request_bytes column to models...")
+		_, err = db.Exec(`ALTER TABLE models ADD COLUMN IF NOT EXISTS max_request_bytes INTEGER CHECK (max_request_bytes > 0)`)
+		if err != nil {
+			return fmt.Errorf("failed to add models.max_request_bytes column: %w", err)
+		}
+	}
+
+	// Check if the usage reconciliation table exists
+	usageDiscrepanciesExist, err := tableExists(db, "usage_discrepancies")
+	if err != nil {
+		return fmt.Errorf("failed to check usage_discrepancies table: %w", err)
+	}
+
+	if !usageDiscrepanciesExist {
+		log.Println("Usage reconciliation table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS usage_discrepancies (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    source VARCHAR(50) NOT NULL, -- 'openai_api', 'csv'
+		    provider VARCHAR(100) NOT NULL,
+		    usage_date DATE NOT NULL,
+		    model VARCHAR(255) NOT NULL,
+		    gateway_tokens BIGINT NOT NULL DEFAULT 0,
+		    provider_tokens BIGINT NOT NULL DEFAULT 0,
+		    gateway_requests BIGINT NOT NULL DEFAULT 0,
+		    provider_requests BIGINT NOT NULL DEFAULT 0,
+		    difference_pct REAL NOT NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(provider, usage_date, model)
+		);
+		CREATE INDEX IF NOT EXISTS idx_usage_discrepancies_date ON usage_discrepancies(usage_date);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create usage_discrepancies table: %w", err)
+		}
+	}
+
+	// Check if organizations can be nested
+	hasOrganizationParent, err := columnExists(db, "organizations", "parent_id")
+	if err != nil {
+		return fmt.Errorf("failed to check organizations.parent_id column: %w", err)
+	}
+
+	if !hasOrganizationParent {
+		log.Println("Adding organization hierarchy...")
+		_, err = db.Exec(`
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES organizations(id) ON DELETE SET NULL;
+		CREATE INDEX IF NOT EXISTS idx_organizations_parent_id ON organizations(parent_id);
+		CREATE TABLE IF NOT EXISTS model_organization_access_denials (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    model_id UUID NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(model_id, organization_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_model_org_access_denials_org_id ON model_organization_access_denials(organization_id);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add organization hierarchy: %w", err)
+		}
+	}
+
+	// Check if API keys can be grouped into projects
+	hasAPIKeyProject, err := columnExists(db, "api_keys", "project_id")
+	if err != nil {
+		return fmt.Errorf("failed to check api_keys.project_id column: %w", err)
+	}
+
+	if !hasAPIKeyProject {
+		log.Println("Adding projects...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS projects (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    name VARCHAR(255) NOT NULL,
+		    description TEXT,
+		    token_quota BIGINT,
+		    used_tokens BIGINT NOT NULL DEFAULT 0,
+		    is_active BOOLEAN DEFAULT true,
+		    created_by UUID REFERENCES users(id),
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_org_name ON projects(organization_id, name) WHERE is_active = true;
+		ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS project_id UUID REFERENCES projects(id) ON DELETE SET NULL;
+		CREATE INDEX IF NOT EXISTS idx_api_keys_project_id ON api_keys(project_id);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add projects: %w", err)
+		}
+	}
+
+	// Check if saved analytics views exist
+	analyticsViewsExist, err := tableExists(db, "analytics_views")
+	if err != nil {
+		return fmt.Errorf("failed to check analytics_views table: %w", err)
+	}
+
+	if !analyticsViewsExist {
+		log.Println("Analytics views table not found, creating it...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS analytics_views (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+		    name VARCHAR(255) NOT NULL,
+		    filters JSONB NOT NULL DEFAULT '{}',
+		    shared BOOLEAN NOT NULL DEFAULT false,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(user_id, name)
+		);
+		CREATE INDEX IF NOT EXISTS idx_analytics_views_org_shared ON analytics_views(organization_id) WHERE shared = true;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create analytics_views table: %w", err)
+		}
+	}
+
+	// Check if canary keys are supported
+	hasCanaryKeys, err := columnExists(db, "api_keys", "is_canary")
+	if err != nil {
+		return fmt.Errorf("failed to check api_keys.is_canary column: %w", err)
+	}
+
+	if !hasCanaryKeys {
+		log.Println("Adding canary keys...")
+		_, err = db.Exec(`
+		ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS is_canary BOOLEAN NOT NULL DEFAULT false;
+		ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS canary_block_ip BOOLEAN NOT NULL DEFAULT false;
+		CREATE TABLE IF NOT EXISTS canary_key_hits (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    api_key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    ip_address VARCHAR(45) NOT NULL,
+		    method VARCHAR(10) NOT NULL,
+		    path VARCHAR(500) NOT NULL,
+		    headers JSONB NOT NULL DEFAULT '{}',
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_canary_key_hits_api_key_created_at ON canary_key_hits(api_key_id, created_at DESC);
+		CREATE TABLE IF NOT EXISTS blocked_ips (
+		    ip_address VARCHAR(45) PRIMARY KEY,
+		    reason TEXT NOT NULL,
+		    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    expires_at TIMESTAMP WITH TIME ZONE
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add canary keys: %w", err)
+		}
+	}
+
+	// Check if the hot query indexes exist
+	hasHotQueryIndexes, err := indexExists(db, "idx_usage_logs_org_created_at")
+	if err != nil {
+		return fmt.Errorf("failed to check idx_usage_logs_org_created_at index: %w", err)
+	}
+
+	if !hasHotQueryIndexes {
+		log.Println("Adding indexes for usage and list queries...")
+		_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_usage_logs_org_created_at ON usage_logs(organization_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_api_keys_active_created_at ON api_keys(created_at DESC) WHERE is_active = true;
+		CREATE INDEX IF NOT EXISTS idx_api_keys_org_active_created_at ON api_keys(organization_id, created_at DESC) WHERE is_active = true;
+		CREATE INDEX IF NOT EXISTS idx_email_logs_created_at ON email_logs(created_at DESC);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add hot query indexes: %w", err)
+		}
+	}
+
+	// Check if organizations can hide costs from members
+	hasOrganizationHideCosts, err := columnExists(db, "organizations", "hide_costs")
+	if err != nil {
+		return fmt.Errorf("failed to check organizations.hide_costs column: %w", err)
+	}
+
+	if !hasOrganizationHideCosts {
+		log.Println("Adding cost visibility setting to organizations...")
+		// Custom roles that could see prices or spend before costs:read existed keep doing so
+		_, err = db.Exec(`
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS hide_costs BOOLEAN NOT NULL DEFAULT false;
+		UPDATE organization_roles SET permissions = array_append(permissions, 'costs:read')
+		WHERE permissions && ARRAY['models:read', 'analytics:read', 'keys:own']::TEXT[]
+		  AND NOT 'costs:read' = ANY(permissions);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add organizations.hide_costs column: %w", err)
+		}
+	}
+
+	// Check if models have metadata
+	hasModelMetadata, err := columnExists(db, "models", "metadata")
+	if err != nil {
+		return fmt.Errorf("failed to check models.metadata column: %w", err)
+	}
+
+	if !hasModelMetadata {
+		log.Println("Adding metadata column to models...")
+		_, err = db.Exec(`ALTER TABLE models ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'`)
+		if err != nil {
+			return fmt.Errorf("failed to add models.metadata column: %w", err)
+		}
+	}
+
+	hasModelTransport, err := columnExists(db, "models", "transport")
+	if err != nil {
+		return fmt.Errorf("failed to check models.transport column: %w", err)
+	}
+
+	if !hasModelTransport {
+		log.Println("Adding transport column to models...")
+		_, err = db.Exec(`ALTER TABLE models ADD COLUMN IF NOT EXISTS transport JSONB NOT NULL DEFAULT '{}'`)
+		if err != nil {
+			return fmt.Errorf("failed to add models.transport column: %w", err)
+		}
+	}
+
+	// Check if conversation sessions exist
+	conversationSessionsExist, err := tableExists(db, "conversation_sessions")
+	if err != nil {
+		return fmt.Errorf("failed to check conversation_sessions table: %w", err)
+	}
+
+	if !conversationSessionsExist {
+		log.Println("Creating conversation_sessions table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS conversation_sessions (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    session_id VARCHAR(128) NOT NULL,
+		    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+		    messages TEXT NOT NULL,
+		    size_bytes INTEGER NOT NULL DEFAULT 0,
+		    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(organization_id, session_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_conversation_sessions_expires_at ON conversation_sessions(expires_at);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create conversation_sessions table: %w", err)
+		}
+	}
+
+	// Check if the notification center tables exist
+	notificationsExist, err := tableExists(db, "notification_reads")
+	if err != nil {
+		return fmt.Errorf("failed to check notification_reads table: %w", err)
+	}
+
+	if !notificationsExist {
+		log.Println("Creating notification center tables...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS notifications (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+		    event_type VARCHAR(50) NOT NULL,
+		    severity VARCHAR(20) NOT NULL DEFAULT 'info',
+		    title VARCHAR(255) NOT NULL,
+		    body TEXT NOT NULL DEFAULT '',
+		    fields JSONB NOT NULL DEFAULT '[]',
+		    link_url TEXT,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_notifications_org_created ON notifications(organization_id, created_at DESC);
+
+		CREATE TABLE IF NOT EXISTS notification_reads (
+		    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+		    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		    read_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    PRIMARY KEY (notification_id, user_id)
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create notification center tables: %w", err)
+		}
+	}
+
+	// Check if email logs link back to their outbox message
+	hasEmailLogOutbox, err := columnExists(db, "email_logs", "outbox_id")
+	if err != nil {
+		return fmt.Errorf("failed to check email_logs.outbox_id column: %w", err)
+	}
+
+	if !hasEmailLogOutbox {
+		log.Println("Adding outbox reference to email logs...")
+		_, err = db.Exec(`
+		ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS outbox_id UUID REFERENCES email_outbox(id) ON DELETE SET NULL;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add email_logs.outbox_id column: %w", err)
+		}
+	}
+
+	// Check if email settings have a failover SMTP server
+	hasSecondarySMTP, err := columnExists(db, "email_settings", "secondary_smtp_host")
+	if err != nil {
+		return fmt.Errorf("failed to check email_settings.secondary_smtp_host column: %w", err)
+	}
+
+	if !hasSecondarySMTP {
+		log.Println("Adding secondary SMTP server to email settings...")
+		_, err = db.Exec(`
+		ALTER TABLE email_settings ADD COLUMN IF NOT EXISTS secondary_smtp_host VARCHAR(255);
+		ALTER TABLE email_settings ADD COLUMN IF NOT EXISTS secondary_smtp_port INTEGER DEFAULT 587;
+		ALTER TABLE email_settings ADD COLUMN IF NOT EXISTS secondary_smtp_username VARCHAR(255);
+		ALTER TABLE email_settings ADD COLUMN IF NOT EXISTS secondary_smtp_password VARCHAR(255);
+		ALTER TABLE email_settings ADD COLUMN IF NOT EXISTS secondary_smtp_tls_mode VARCHAR(20) DEFAULT 'auto';
+		ALTER TABLE email_logs ADD COLUMN IF NOT EXISTS smtp_server VARCHAR(20);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add secondary SMTP server columns: %w", err)
+		}
+	}
+
+	// Check if the organization contact roster exists
+	organizationContactsExist, err := tableExists(db, "organization_contacts")
+	if err != nil {
+		return fmt.Errorf("failed to check organization_contacts table: %w", err)
+	}
+
+	if !organizationContactsExist {
+		log.Println("Creating organization contacts table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS organization_contacts (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    role VARCHAR(20) NOT NULL,
+		    email VARCHAR(255) NOT NULL,
+		    name VARCHAR(255) NOT NULL DEFAULT '',
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE(organization_id, role, email)
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create organization_contacts table: %w", err)
+		}
+	}
+
+	// Check if sign-ins are recorded
+	loginEventsExist, err := tableExists(db, "login_events")
+	if err != nil {
+		return fmt.Errorf("failed to check login_events table: %w", err)
+	}
+
+	if !loginEventsExist {
+		log.Println("Creating login events table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS login_events (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+		    identity VARCHAR(255) NOT NULL DEFAULT '', -- Username or email the attempt was for
+		    method VARCHAR(20) NOT NULL, -- 'local', 'azure'
+		    success BOOLEAN NOT NULL,
+		    failure_reason TEXT,
+		    ip_address VARCHAR(45),
+		    user_agent TEXT,
+		    country VARCHAR(2), -- From the header named by LOGIN_COUNTRY_HEADER
+		    anomalies TEXT[] NOT NULL DEFAULT '{}', -- 'new_country', 'failures_then_success'
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_login_events_identity_created ON login_events(LOWER(identity), created_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_login_events_user_created ON login_events(user_id, created_at DESC);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create login_events table: %w", err)
+		}
+	}
+
+	// Check if users carry a permissions version for session invalidation
+	hasPermissionsVersion, err := columnExists(db, "users", "permissions_version")
+	if err != nil {
+		return fmt.Errorf("failed to check users.permissions_version column: %w", err)
+	}
+
+	if !hasPermissionsVersion {
+		log.Println("Adding permissions version to users...")
+		_, err = db.Exec(`
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS permissions_version BIGINT NOT NULL DEFAULT 0;
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add users.permissions_version column: %w", err)
+		}
+	}
+
+	// Check if Azure AD sessions are kept server-side for token refresh
+	azureSessionsExist, err := tableExists(db, "azure_sessions")
+	if err != nil {
+		return fmt.Errorf("failed to check azure_sessions table: %w", err)
+	}
+
+	if !azureSessionsExist {
+		log.Println("Creating Azure sessions table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS azure_sessions (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    azure_oid VARCHAR(255) NOT NULL,
+		    email VARCHAR(255) NOT NULL,
+		    refresh_token TEXT NOT NULL, -- Sealed with ENCRYPTION_KEY
+		    expires_at TIMESTAMP WITH TIME ZONE NOT NULL, -- When the current access token expires
+		    refreshed_at TIMESTAMP WITH TIME ZONE,
+		    refresh_claimed_until TIMESTAMP WITH TIME ZONE, -- Held by the request refreshing it
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create azure_sessions table: %w", err)
+		}
+	}
+
+	// Check if the break-glass account exists
+	breakGlassAccountExists, err := tableExists(db, "break_glass_account")
+	if err != nil {
+		return fmt.Errorf("failed to check break_glass_account table: %w", err)
+	}
+
+	if !breakGlassAccountExists {
+		log.Println("Creating break-glass account table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS break_glass_account (
+		    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id), -- Single row
+		    enabled BOOLEAN NOT NULL DEFAULT true, -- Policy set by System Admins
+		    session_token_hash VARCHAR(64), -- SHA-256 of the current session's token
+		    session_expires_at TIMESTAMP WITH TIME ZONE,
+		    last_used_at TIMESTAMP WITH TIME ZONE,
+		    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		    updated_at TIMESTAMP WITH TIME ZONE
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create break_glass_account table: %w", err)
+		}
+	}
+
+	// Check if organizations have a QoS class
+	hasOrganizationQoSClass, err := columnExists(db, "organizations", "qos_class")
+	if err != nil {
+		return fmt.Errorf("failed to check organizations.qos_class column: %w", err)
+	}
+
+	if !hasOrganizationQoSClass {
+		log.Println("Adding QoS class to organizations...")
+		_, err = db.Exec(`
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS qos_class VARCHAR(10) NOT NULL DEFAULT 'silver'
+		    CHECK (qos_class IN ('gold', 'silver', 'bronze'));
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add organizations.qos_class column: %w", err)
+		}
+	}
+
+	// Check if model prices exist
+	modelPricesExist, err := tableExists(db, "model_prices")
+	if err != nil {
+		return fmt.Errorf("failed to check model_prices table: %w", err)
+	}
+
+	if !modelPricesExist {
+		log.Println("Creating model prices table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS model_prices (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    model_id UUID NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+		    input_cost_per_1m DECIMAL(10,6) NOT NULL DEFAULT 0.0,
+		    output_cost_per_1m DECIMAL(10,6) NOT NULL DEFAULT 0.0,
+		    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+		    effective_to TIMESTAMP WITH TIME ZONE, -- NULL until a later price replaces it
+		    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC', -- Time zone of the windows
+		    windows JSONB NOT NULL DEFAULT '[]', -- [{"start": "09:00", "end": "17:00", "multiplier": 1.5}]
+		    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_model_prices_model ON model_prices(model_id, effective_from DESC);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create model_prices table: %w", err)
+		}
+	}
+
+	// Check if organizations have a display currency
+	hasOrganizationCurrency, err := columnExists(db, "organizations", "currency")
+	if err != nil {
+		return fmt.Errorf("failed to check organizations.currency column: %w", err)
+	}
+
+	if !hasOrganizationCurrency {
+		log.Println("Adding display currency to organizations...")
+		_, err = db.Exec(`
+		ALTER TABLE organizations ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD';
+		CREATE TABLE IF NOT EXISTS fx_rates (
+		    currency VARCHAR(3) PRIMARY KEY,
+		    rate_per_usd DOUBLE PRECISION NOT NULL CHECK (rate_per_usd > 0), -- Units of the currency one US dollar buys
+		    source VARCHAR(255) NOT NULL, -- Host the rate was fetched from, or 'manual'
+		    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to add organizations.currency column: %w", err)
+		}
+	}
+
+	// Check if organizations have a per-request cost ceiling
+	hasOrganizationMaxRequestCost, err := columnExists(db, "organizations", "max_request_cost")
+	if err != nil {
+		return fmt.Errorf("failed to check organizations.max_request_cost column: %w", err)
+	}
+
+	if !hasOrganizationMaxRequestCost {
+		log.Println("Adding max_request_cost column to organizations...")
+		_, err = db.Exec(`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS max_request_cost DECIMAL(12,6) CHECK (max_request_cost > 0)`)
+		if err != nil {
+			return fmt.Errorf("failed to add organizations.max_request_cost column: %w", err)
+		}
+	}
+
+	// Check if the wasm_policies table exists
+	wasmPoliciesExist, err := tableExists(db, "wasm_policies")
+	if err != nil {
+		return fmt.Errorf("failed to check wasm_policies table: %w", err)
+	}
+
+	if !wasmPoliciesExist {
+		log.Println("Creating WASM policies table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS wasm_policies (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    name VARCHAR(255) NOT NULL,
+		    module BYTEA NOT NULL,
+		    sha256 VARCHAR(64) NOT NULL,
+		    fuel_limit BIGINT CHECK (fuel_limit > 0), -- Function calls per call; NULL uses the gateway default
+		    max_memory_pages INTEGER CHECK (max_memory_pages > 0), -- 64 KiB pages; NULL uses the gateway default
+		    is_active BOOLEAN NOT NULL DEFAULT true,
+		    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_wasm_policies_org ON wasm_policies(organization_id, created_at);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create wasm_policies table: %w", err)
+		}
+	}
+
+	// Check if the authz_decisions table exists
+	authzDecisionsExist, err := tableExists(db, "authz_decisions")
+	if err != nil {
+		return fmt.Errorf("failed to check authz_decisions table: %w", err)
+	}
+
+	if !authzDecisionsExist {
+		log.Println("Creating authorization decision log table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS authz_decisions (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    request_id VARCHAR(255),
+		    organization_id UUID,
+		    api_key_id UUID,
+		    model VARCHAR(255),
+		    method VARCHAR(10) NOT NULL,
+		    path TEXT NOT NULL,
+		    payload_bytes INTEGER NOT NULL DEFAULT 0,
+		    allowed BOOLEAN NOT NULL,
+		    reason TEXT,
+		    status INTEGER, -- Status the request was refused with
+		    policy VARCHAR(255) NOT NULL, -- Decision path asked, e.g. relai/authz
+		    opa_decision_id VARCHAR(255),
+		    error TEXT, -- Why OPA couldn't decide
+		    duration_ms INTEGER NOT NULL DEFAULT 0,
+		    client_ip VARCHAR(45),
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_authz_decisions_created ON authz_decisions(created_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_authz_decisions_org ON authz_decisions(organization_id, created_at DESC);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create authz_decisions table: %w", err)
+		}
+	}
+
+	// Check if the declared_resources table exists
+	declaredResourcesExist, err := tableExists(db, "declared_resources")
+	if err != nil {
+		return fmt.Errorf("failed to check declared_resources table: %w", err)
+	}
+
+	if !declaredResourcesExist {
+		log.Println("Creating declared resources table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS declared_resources (
+		    kind VARCHAR(20) NOT NULL, -- organization or model
+		    name VARCHAR(255) NOT NULL,
+		    resource_id UUID NOT NULL,
+		    applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    PRIMARY KEY (kind, name)
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create declared_resources table: %w", err)
+		}
+	}
+
+	// Check if the slos table exists
+	slosExist, err := tableExists(db, "slos")
+	if err != nil {
+		return fmt.Errorf("failed to check slos table: %w", err)
+	}
+
+	if !slosExist {
+		log.Println("Creating SLO table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS slos (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    name VARCHAR(255) NOT NULL,
+		    model_id UUID REFERENCES models(id) ON DELETE CASCADE,
+		    endpoint VARCHAR(255) NOT NULL DEFAULT '', -- Empty means every endpoint
+		    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE, -- NULL means every organization
+		    objective VARCHAR(20) NOT NULL, -- availability or latency
+		    target NUMERIC(6,3) NOT NULL, -- Percentage of good requests, e.g. 99.9
+		    latency_threshold_ms INTEGER,
+		    window_days INTEGER NOT NULL DEFAULT 30,
+		    is_active BOOLEAN NOT NULL DEFAULT true,
+		    alert_level VARCHAR(20) NOT NULL DEFAULT '', -- Burn rate alert last raised: warning or critical
+		    alerted_at TIMESTAMP WITH TIME ZONE,
+		    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    CHECK (model_id IS NOT NULL OR endpoint <> '')
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create slos table: %w", err)
+		}
+	}
+
+	// Check if the stream_pacing table exists
+	streamPacingExist, err := tableExists(db, "stream_pacing")
+	if err != nil {
+		return fmt.Errorf("failed to check stream_pacing table: %w", err)
+	}
+
+	if !streamPacingExist {
+		log.Println("Creating stream pacing table...")
+		_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS stream_pacing (
+		    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+		    endpoint VARCHAR(255) NOT NULL, -- e.g. /v1/chat/completions
+		    tokens_per_second INTEGER NOT NULL CHECK (tokens_per_second > 0),
+		    burst_tokens INTEGER NOT NULL DEFAULT 0 CHECK (burst_tokens >= 0),
+		    is_active BOOLEAN NOT NULL DEFAULT true,
+		    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+		    UNIQUE (organization_id, endpoint)
+		);
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to create stream_pacing table: %w", err)
+		}
+	}
+
+	if !hasAPIEndpoint || !hasAPIToken || hasUniqueConstraint || !emailTablesExist || !experimentTablesExist || !hasFeedbackRating || !responseSchemasExist || !secretScanTablesExist || !auditLogsExist || !emailOutboxExists || !hasAPIKeyExpiry || !quotaNotificationsExist || !notificationChannelsExist || !organizationBrandingExists || !templateVersionsExist || !hasTemplateLocale || !notificationPreferencesExist || !dkimKeysExist || !hasMembershipSource || !organizationRolesExist || !hasADGroupPriority || !hasAPIKeyOwner || !requestReplayTablesExist || !batchTablesExist || !hasModelAWSCredentials || !hasModelGCPCredentials || !usageDiscrepanciesExist || !hasOrganizationParent || !hasAPIKeyProject || !hasModelMaxRequestBytes || !analyticsViewsExist || !hasHotQueryIndexes || !hasCanaryKeys || !hasOrganizationHideCosts || !hasModelMetadata || !conversationSessionsExist || !notificationsExist || !hasEmailLogOutbox || !hasSecondarySMTP || !organizationContactsExist || !loginEventsExist || !hasPermissionsVersion || !azureSessionsExist || !breakGlassAccountExists || !hasOrganizationQoSClass || !modelPricesExist || !hasOrganizationCurrency || !wasmPoliciesExist || !authzDecisionsExist || !declaredResourcesExist || !slosExist || !hasOrganizationMaxRequestCost || !streamPacingExist || !hasModelTransport {
+		log.Println("Schema updated successfully")
+	}
+
+	return nil
+
+}
+
+// tableExists reports whether a table exists in the public schema
+func tableExists(db *sql.DB, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (
+		SELECT FROM information_schema.tables
+		WHERE table_schema = 'public'
+		AND table_name = $1
+	);`, name).Scan(&exists)
+	return exists, err
+}
+
+// columnExists reports whether a column exists on a table in the public schema
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (
+		SELECT FROM information_schema.columns
+		WHERE table_schema = 'public'
+		AND table_name = $1
+		AND column_name = $2
+	);`, table, column).Scan(&exists)
+	return exists, err
+}
+
+// indexExists reports whether an index exists in the public schema
+func indexExists(db *sql.DB, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (
+		SELECT FROM pg_indexes
+		WHERE schemaname = 'public'
+		AND indexname = $1
+	)`, name).Scan(&exists)
+	return exists, err
 }
 
 // GetDB is a helper function to get database connection from context
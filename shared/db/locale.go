@@ -0,0 +1,37 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// GetUserLocale returns the user's preferred locale, or "" when unset
+func GetUserLocale(db *sql.DB, userID string) (string, error) {
+	var locale sql.NullString
+	err := db.QueryRow(`SELECT locale FROM users WHERE id = $1`, userID).Scan(&locale)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return locale.String, err
+}
+
+// UpdateUserLocale sets the user's preferred locale; "" clears it
+func UpdateUserLocale(db *sql.DB, userID, locale string) error {
+	_, err := db.Exec(`UPDATE users SET locale = NULLIF($2, ''), updated_at = NOW() WHERE id = $1`, userID, locale)
+	return err
+}
+
+// GetOrganizationLocale returns the organization's default locale, or "" when unset
+func GetOrganizationLocale(db *sql.DB, orgID string) (string, error) {
+	var locale sql.NullString
+	err := db.QueryRow(`SELECT locale FROM organizations WHERE id = $1`, orgID).Scan(&locale)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return locale.String, err
+}
+
+// UpdateOrganizationLocale sets the organization's default locale; "" clears it
+func UpdateOrganizationLocale(db *sql.DB, orgID, locale string) error {
+	_, err := db.Exec(`UPDATE organizations SET locale = NULLIF($2, ''), updated_at = NOW() WHERE id = $1`, orgID, locale)
+	return err
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// CreateLoginEvent records a sign-in attempt
+func CreateLoginEvent(db *sql.DB, e *models.LoginEvent) error {
+	if e.Anomalies == nil {
+		e.Anomalies = []string{}
+	}
+
+	query := `
+		INSERT INTO login_events (user_id, identity, method, success, failure_reason, ip_address, user_agent, country, anomalies)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9)
+		RETURNING id, created_at`
+
+	return db.QueryRow(query, e.UserID, e.Identity, e.Method, e.Success, e.FailureReason, e.IPAddress,
+		e.UserAgent, e.Country, pq.Array(e.Anomalies)).Scan(&e.ID, &e.CreatedAt)
+}
+
+// GetSignInHistory returns the identity's sign-in context: the countries it signed in from
+// successfully, and how many attempts failed since its last successful sign-in, counting only
+// those after since
+func GetSignInHistory(db *sql.DB, identity string, since time.Time) ([]string, int, error) {
+	var countries []string
+	err := db.QueryRow(`
+		SELECT COALESCE(ARRAY_AGG(DISTINCT country), '{}')
+		FROM login_events
+		WHERE LOWER(identity) = LOWER($1) AND success AND country IS NOT NULL`, identity).Scan(pq.Array(&countries))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var failures int
+	err = db.QueryRow(`
+		SELECT COUNT(*)
+		FROM login_events
+		WHERE LOWER(identity) = LOWER($1) AND NOT success AND created_at > $2
+		  AND created_at > COALESCE((
+			SELECT MAX(created_at) FROM login_events WHERE LOWER(identity) = LOWER($1) AND success
+		  ), '-infinity')`, identity, since).Scan(&failures)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return countries, failures, nil
+}
+
+// GetUserLoginEvents returns a user's sign-in attempts, newest first: those recorded for the
+// user and those for their email address before the account existed
+func GetUserLoginEvents(db *sql.DB, userID, email string, limit int) ([]models.LoginEvent, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, identity, method, success, COALESCE(failure_reason, ''), COALESCE(ip_address, ''),
+			COALESCE(user_agent, ''), COALESCE(country, ''), anomalies, created_at
+		FROM login_events
+		WHERE user_id = $1 OR (user_id IS NULL AND $2 <> '' AND LOWER(identity) = LOWER($2))
+		ORDER BY created_at DESC
+		LIMIT $3`, userID, email, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.LoginEvent{}
+	for rows.Next() {
+		var e models.LoginEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Identity, &e.Method, &e.Success, &e.FailureReason, &e.IPAddress,
+			&e.UserAgent, &e.Country, pq.Array(&e.Anomalies), &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,27 @@
+package db
+
+import "database/sql"
+
+// GetOrganizationMaxRequestCost returns the organization's ceiling on a request's worst-case
+// cost in US dollars, or nil when it has none
+func GetOrganizationMaxRequestCost(db *sql.DB, orgID string) (*float64, error) {
+	var ceiling *float64
+	err := db.QueryRow(`SELECT max_request_cost FROM organizations WHERE id = $1`, orgID).Scan(&ceiling)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return ceiling, err
+}
+
+// UpdateOrganizationMaxRequestCost sets the organization's per-request cost ceiling, or removes
+// it when ceiling is nil, returning sql.ErrNoRows if the organization doesn't exist
+func UpdateOrganizationMaxRequestCost(db *sql.DB, orgID string, ceiling *float64) error {
+	result, err := db.Exec(`UPDATE organizations SET max_request_cost = $2, updated_at = NOW() WHERE id = $1`, orgID, ceiling)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// decodeModelMetadata reads a models.metadata column into metadata
+func decodeModelMetadata(raw []byte, metadata *models.ModelMetadata) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, metadata)
+}
+
+// decodeModelTransport reads a models.transport column into transport
+func decodeModelTransport(raw []byte, transport *models.ModelTransport) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw, transport)
+}
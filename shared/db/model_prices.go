@@ -0,0 +1,85 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Model price operations
+
+const modelPriceColumns = `id, model_id, input_cost_per_1m, output_cost_per_1m, effective_from, effective_to,
+	timezone, windows, created_by, created_at`
+
+func scanModelPrice(row interface{ Scan(...interface{}) error }) (*models.ModelPrice, error) {
+	var p models.ModelPrice
+	var windows []byte
+	err := row.Scan(&p.ID, &p.ModelID, &p.InputCostPer1M, &p.OutputCostPer1M, &p.EffectiveFrom, &p.EffectiveTo,
+		&p.Timezone, &windows, &p.CreatedBy, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(windows, &p.Windows); err != nil {
+		return nil, fmt.Errorf("failed to decode windows of model price %s: %w", p.ID, err)
+	}
+	return &p, nil
+}
+
+// ListModelPrices returns the model's prices, newest first
+func ListModelPrices(db *sql.DB, modelID string) ([]models.ModelPrice, error) {
+	rows, err := db.Query(`SELECT `+modelPriceColumns+` FROM model_prices WHERE model_id = $1 ORDER BY effective_from DESC`, modelID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []models.ModelPrice{}
+	for rows.Next() {
+		p, err := scanModelPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, *p)
+	}
+	return prices, rows.Err()
+}
+
+// GetModelPriceAt returns the model's price in effect at t, or sql.ErrNoRows when none is
+func GetModelPriceAt(db *sql.DB, modelID string, t time.Time) (*models.ModelPrice, error) {
+	return scanModelPrice(db.QueryRow(`
+		SELECT `+modelPriceColumns+` FROM model_prices
+		WHERE model_id = $1 AND effective_from <= $2 AND (effective_to IS NULL OR effective_to > $2)
+		ORDER BY effective_from DESC
+		LIMIT 1`, modelID, t))
+}
+
+// CreateModelPrice adds a price to the model, returning sql.ErrNoRows if the model doesn't exist
+func CreateModelPrice(db *sql.DB, modelID, createdBy string, req models.CreateModelPriceRequest) (*models.ModelPrice, error) {
+	windows, err := json.Marshal(req.Windows)
+	if err != nil {
+		return nil, err
+	}
+	return scanModelPrice(db.QueryRow(`
+		INSERT INTO model_prices (model_id, input_cost_per_1m, output_cost_per_1m, effective_from, effective_to,
+			timezone, windows, created_by)
+		SELECT id, $2, $3, $4, $5, $6, $7, NULLIF($8, '')::uuid FROM models WHERE id = $1
+		RETURNING `+modelPriceColumns,
+		modelID, req.InputCostPer1M, req.OutputCostPer1M, *req.EffectiveFrom, req.EffectiveTo,
+		req.Timezone, windows, createdBy))
+}
+
+// DeleteModelPrice removes one of the model's prices, returning sql.ErrNoRows if it doesn't
+// exist
+func DeleteModelPrice(db *sql.DB, modelID, priceID string) error {
+	result, err := db.Exec(`DELETE FROM model_prices WHERE id = $1 AND model_id = $2`, priceID, modelID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
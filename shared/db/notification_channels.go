@@ -0,0 +1,164 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+const notificationChannelColumns = `id, organization_id, name, type, webhook_url, events, is_enabled, created_at, updated_at`
+
+func scanNotificationChannel(scanner interface{ Scan(...interface{}) error }) (*models.NotificationChannel, error) {
+	var channel models.NotificationChannel
+	var events []byte
+	err := scanner.Scan(&channel.ID, &channel.OrganizationID, &channel.Name, &channel.Type,
+		&channel.WebhookURL, &events, &channel.IsEnabled, &channel.CreatedAt, &channel.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	channel.Events = []string{}
+	if len(events) > 0 {
+		if err := json.Unmarshal(events, &channel.Events); err != nil {
+			return nil, err
+		}
+	}
+
+	return &channel, nil
+}
+
+// GetNotificationChannels returns all channels for an organization
+func GetNotificationChannels(db *sql.DB, orgID string) ([]models.NotificationChannel, error) {
+	query := `SELECT ` + notificationChannelColumns + ` FROM notification_channels WHERE organization_id = $1 ORDER BY name`
+
+	rows, err := db.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []models.NotificationChannel{}
+	for rows.Next() {
+		channel, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, *channel)
+	}
+
+	return channels, rows.Err()
+}
+
+// GetEnabledNotificationChannelsForEvent returns the organization's enabled channels subscribed to event
+func GetEnabledNotificationChannelsForEvent(db *sql.DB, orgID, event string) ([]models.NotificationChannel, error) {
+	query := `
+		SELECT ` + notificationChannelColumns + `
+		FROM notification_channels
+		WHERE organization_id = $1 AND is_enabled = true
+		AND (jsonb_array_length(events) = 0 OR events ? $2)`
+
+	rows, err := db.Query(query, orgID, event)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []models.NotificationChannel{}
+	for rows.Next() {
+		channel, err := scanNotificationChannel(rows)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, *channel)
+	}
+
+	return channels, rows.Err()
+}
+
+// GetNotificationChannel returns a single channel
+func GetNotificationChannel(db *sql.DB, id string) (*models.NotificationChannel, error) {
+	query := `SELECT ` + notificationChannelColumns + ` FROM notification_channels WHERE id = $1`
+	return scanNotificationChannel(db.QueryRow(query, id))
+}
+
+// CreateNotificationChannel stores a new channel
+func CreateNotificationChannel(db *sql.DB, req models.CreateNotificationChannelRequest) (*models.NotificationChannel, error) {
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+	events, err := json.Marshal(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	isEnabled := true
+	if req.IsEnabled != nil {
+		isEnabled = *req.IsEnabled
+	}
+
+	query := `
+		INSERT INTO notification_channels (organization_id, name, type, webhook_url, events, is_enabled)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + notificationChannelColumns
+
+	return scanNotificationChannel(db.QueryRow(query, req.OrganizationID, req.Name, req.Type,
+		strings.TrimSpace(req.WebhookURL), events, isEnabled))
+}
+
+// UpdateNotificationChannel applies the provided fields; a masked webhook URL leaves the stored one unchanged
+func UpdateNotificationChannel(db *sql.DB, id string, req models.UpdateNotificationChannelRequest) (*models.NotificationChannel, error) {
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *req.Name)
+		argIndex++
+	}
+	if req.WebhookURL != nil && !models.IsMaskedSecret(*req.WebhookURL) {
+		setParts = append(setParts, fmt.Sprintf("webhook_url = $%d", argIndex))
+		args = append(args, strings.TrimSpace(*req.WebhookURL))
+		argIndex++
+	}
+	if req.Events != nil {
+		events, err := json.Marshal(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		setParts = append(setParts, fmt.Sprintf("events = $%d", argIndex))
+		args = append(args, events)
+		argIndex++
+	}
+	if req.IsEnabled != nil {
+		setParts = append(setParts, fmt.Sprintf("is_enabled = $%d", argIndex))
+		args = append(args, *req.IsEnabled)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return GetNotificationChannel(db, id)
+	}
+
+	setParts = append(setParts, "updated_at = NOW()")
+	query := fmt.Sprintf(`UPDATE notification_channels SET %s WHERE id = $%d RETURNING `+notificationChannelColumns,
+		strings.Join(setParts, ", "), argIndex)
+	args = append(args, id)
+
+	return scanNotificationChannel(db.QueryRow(query, args...))
+}
+
+// DeleteNotificationChannel removes a channel
+func DeleteNotificationChannel(db *sql.DB, id string) error {
+	result, err := db.Exec(`DELETE FROM notification_channels WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetNotificationPreferences returns the recipient's preference for every event and channel,
+// defaulting to subscribed where nothing is stored
+func GetNotificationPreferences(db *sql.DB, email string) ([]models.NotificationPreference, error) {
+	email = strings.ToLower(email)
+
+	rows, err := db.Query(`
+		SELECT event_type, channel, is_enabled, updated_at
+		FROM notification_preferences
+		WHERE email = $1`, email)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[string]models.NotificationPreference)
+	for rows.Next() {
+		p := models.NotificationPreference{Email: email}
+		if err := rows.Scan(&p.EventType, &p.Channel, &p.IsEnabled, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		stored[p.EventType+"/"+p.Channel] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	preferences := []models.NotificationPreference{}
+	for _, event := range models.NotificationEvents {
+		for _, channel := range models.NotificationDeliveryChannels {
+			p, ok := stored[event+"/"+channel]
+			if !ok {
+				p = models.NotificationPreference{Email: email, EventType: event, Channel: channel, IsEnabled: true}
+			}
+			preferences = append(preferences, p)
+		}
+	}
+
+	return preferences, nil
+}
+
+// SetNotificationPreferences stores the recipient's preferences
+func SetNotificationPreferences(db *sql.DB, email string, settings []models.NotificationPreferenceSetting) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, s := range settings {
+		_, err := tx.Exec(`
+			INSERT INTO notification_preferences (email, event_type, channel, is_enabled)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (email, event_type, channel)
+			DO UPDATE SET is_enabled = EXCLUDED.is_enabled, updated_at = NOW()`,
+			strings.ToLower(email), s.EventType, s.Channel, s.IsEnabled)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// IsNotificationEnabled reports whether the recipient still receives event on channel
+func IsNotificationEnabled(db *sql.DB, email, event, channel string) (bool, error) {
+	var enabled bool
+	err := db.QueryRow(`
+		SELECT is_enabled FROM notification_preferences
+		WHERE email = $1 AND event_type = $2 AND channel = $3`,
+		strings.ToLower(email), event, channel).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	return enabled, err
+}
+
+// GetUnsubscribeSecret returns the key used to sign unsubscribe links
+func GetUnsubscribeSecret(db *sql.DB) (string, error) {
+	var secret sql.NullString
+	err := db.QueryRow(`
+		SELECT unsubscribe_secret FROM email_settings
+		ORDER BY created_at DESC
+		LIMIT 1`).Scan(&secret)
+	if err != nil {
+		return "", err
+	}
+	return secret.String, nil
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Notification center operations. A user sees the notifications of the organizations they
+// administer; System Admins see every notification, including gateway-wide ones.
+
+// visibleNotification restricts notifications n to those user $1 may see; $2 says whether the
+// user is a System Admin
+const visibleNotification = `(n.organization_id IN (
+		SELECT organization_id FROM user_organizations WHERE user_id = $1 AND role_name = 'admin'
+	) OR $2::boolean)`
+
+// CreateNotification adds a notification; a nil organization ID makes it gateway-wide
+func CreateNotification(db *sql.DB, n *models.Notification) error {
+	fields, err := json.Marshal(n.Fields)
+	if err != nil || n.Fields == nil {
+		fields = []byte("[]")
+	}
+
+	query := `
+		INSERT INTO notifications (organization_id, event_type, severity, title, body, fields, link_url)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+		RETURNING id, created_at`
+
+	return db.QueryRow(query, n.OrganizationID, n.EventType, n.Severity, n.Title, n.Body, fields, n.LinkURL).
+		Scan(&n.ID, &n.CreatedAt)
+}
+
+// GetNotifications returns the notifications the user can see, newest first, marked read or not
+func GetNotifications(db *sql.DB, userID string, isSystemAdmin bool, params models.NotificationListParams) ([]models.Notification, error) {
+	args := []interface{}{userID, isSystemAdmin}
+	conditions := []string{visibleNotification}
+	if params.OrganizationID != "" {
+		args = append(args, params.OrganizationID)
+		conditions = append(conditions, fmt.Sprintf("n.organization_id = $%d", len(args)))
+	}
+	if params.UnreadOnly {
+		conditions = append(conditions, "r.read_at IS NULL")
+	}
+	if params.BeforeTime != nil {
+		args = append(args, *params.BeforeTime)
+		conditions = append(conditions, fmt.Sprintf("n.created_at < $%d", len(args)))
+	}
+	args = append(args, params.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT n.id, n.organization_id, n.event_type, n.severity, n.title, n.body, n.fields,
+			COALESCE(n.link_url, ''), r.read_at IS NOT NULL, n.created_at
+		FROM notifications n
+		LEFT JOIN notification_reads r ON r.notification_id = n.id AND r.user_id = $1
+		WHERE %s
+		ORDER BY n.created_at DESC
+		LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var fields []byte
+		if err := rows.Scan(&n.ID, &n.OrganizationID, &n.EventType, &n.Severity, &n.Title, &n.Body, &fields,
+			&n.LinkURL, &n.Read, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		n.Fields = []models.NotificationField{}
+		if len(fields) > 0 {
+			if err := json.Unmarshal(fields, &n.Fields); err != nil {
+				return nil, err
+			}
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// CountUnreadNotifications returns how many of the notifications the user can see are unread
+func CountUnreadNotifications(db *sql.DB, userID string, isSystemAdmin bool) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM notifications n
+		LEFT JOIN notification_reads r ON r.notification_id = n.id AND r.user_id = $1
+		WHERE ` + visibleNotification + ` AND r.read_at IS NULL`
+
+	var count int
+	err := db.QueryRow(query, userID, isSystemAdmin).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationRead marks a notification the user can see as read, returning sql.ErrNoRows
+// if there is none. Marking it again keeps the first read time.
+func MarkNotificationRead(db *sql.DB, userID string, isSystemAdmin bool, notificationID string) error {
+	query := `
+		WITH visible AS (
+			SELECT n.id FROM notifications n WHERE n.id = $3 AND ` + visibleNotification + `
+		), marked AS (
+			INSERT INTO notification_reads (notification_id, user_id)
+			SELECT id, $1 FROM visible
+			ON CONFLICT (notification_id, user_id) DO NOTHING
+		)
+		SELECT COUNT(*) FROM visible`
+
+	var count int
+	if err := db.QueryRow(query, userID, isSystemAdmin, notificationID).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every unread notification the user can see as read, or only
+// the organization's when orgID is set, and returns how many were marked
+func MarkAllNotificationsRead(db *sql.DB, userID string, isSystemAdmin bool, orgID string) (int64, error) {
+	query := `
+		INSERT INTO notification_reads (notification_id, user_id)
+		SELECT n.id, $1
+		FROM notifications n
+		WHERE ` + visibleNotification + ` AND ($3 = '' OR n.organization_id::text = $3)
+		ON CONFLICT (notification_id, user_id) DO NOTHING`
+
+	result, err := db.Exec(query, userID, isSystemAdmin, orgID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteNotificationsBefore removes notifications created before cutoff and returns how many
+// were removed
+func DeleteNotificationsBefore(db *sql.DB, cutoff time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM notifications WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/like-mike/relai-gateway/shared/models"
@@ -47,12 +48,13 @@ func SyncUserOrganizationMemberships(db *sql.DB, userID string, userADGroups []s
 	}
 	defer tx.Rollback()
 
-	// Get current user organization memberships
+	// Get current user organization memberships. Manual memberships are pinned by admins and
+	// left alone by the sync.
 	currentMemberships := make(map[string]string) // orgID -> roleType
 	membershipQuery := `
 		SELECT organization_id, role_name
 		FROM user_organizations
-		WHERE user_id = $1`
+		WHERE user_id = $1 AND source = 'ad_sync'`
 
 	rows, err := tx.Query(membershipQuery, userID)
 	if err == nil {
@@ -65,91 +67,18 @@ func SyncUserOrganizationMemberships(db *sql.DB, userID string, userADGroups []s
 		}
 	}
 
-	// Get organization AD group mappings
-	// Changed to handle multiple roles per group: orgID -> {groupID -> []roleType}
-	orgMappings := make(map[string]map[string][]string) // orgID -> {groupID -> []roleType}
-
-	// Enhanced debug logging
+	// Get organization AD group mappings matching the user's groups
 	fmt.Printf("=== SYNC DEBUG: Looking for organizations mapped to user's %d AD groups ===\n", len(userADGroups))
-	for i, group := range userADGroups {
-		fmt.Printf("User AD Group %d: %s\n", i+1, group)
-	}
-
-	// First, let's check what's actually in the organization_ad_groups table
-	debugQuery := `SELECT organization_id, ad_group_id, role_type FROM organization_ad_groups WHERE is_active = true`
-	debugRows, err := tx.Query(debugQuery)
-	if err == nil {
-		defer debugRows.Close()
-		fmt.Printf("=== All active AD group mappings in database: ===\n")
-		for debugRows.Next() {
-			var orgID, groupID, roleType string
-			if err := debugRows.Scan(&orgID, &groupID, &roleType); err == nil {
-				fmt.Printf("DB Mapping: Org=%s, Group=%s, Role=%s\n", orgID, groupID, roleType)
-			}
-		}
-	} else {
-		fmt.Printf("Error querying organization_ad_groups: %v\n", err)
-	}
-
-	mappingQuery := `
-		SELECT organization_id, ad_group_id, role_type
-		FROM organization_ad_groups
-		WHERE is_active = true AND ad_group_id = ANY($1)`
-
-	if len(userADGroups) > 0 {
-		fmt.Printf("Executing query with user groups: %v\n", userADGroups)
-		rows, err = tx.Query(mappingQuery, pq.Array(userADGroups))
-		if err != nil {
-			fmt.Printf("Error in AD group mapping query: %v\n", err)
-		} else {
-			defer rows.Close()
-			matchCount := 0
-			for rows.Next() {
-				var orgID, groupID, roleType string
-				if err := rows.Scan(&orgID, &groupID, &roleType); err == nil {
-					if orgMappings[orgID] == nil {
-						orgMappings[orgID] = make(map[string][]string)
-					}
-					orgMappings[orgID][groupID] = append(orgMappings[orgID][groupID], roleType)
-					matchCount++
-					fmt.Printf("MATCHED: User group %s -> Org %s with role %s\n", groupID, orgID, roleType)
-				}
-			}
-			fmt.Printf("Total matches found: %d\n", matchCount)
-		}
-	} else {
-		fmt.Printf("No user AD groups to check\n")
+	orgMappings, err := matchADGroupMappings(tx, userADGroups)
+	if err != nil {
+		return err
 	}
 
-	// Determine new memberships based on AD groups
+	// Determine new memberships based on AD groups; see models.ResolveADGroupRole for ordering
 	newMemberships := make(map[string]string) // orgID -> roleType
-	fmt.Printf("=== PROCESSING NEW MEMBERSHIPS ===\n")
-
-	for orgID, groupMappings := range orgMappings {
-		fmt.Printf("Processing organization: %s\n", orgID)
-		userRolesInOrg := []string{} // Collect all roles user has in this org
-
-		for groupID, roleTypes := range groupMappings {
-			fmt.Printf("  Checking group %s with roles %v\n", groupID, roleTypes)
-			for _, userGroup := range userADGroups {
-				if userGroup == groupID {
-					fmt.Printf("  USER MATCH: User is in group %s, found roles %v\n", groupID, roleTypes)
-					userRolesInOrg = append(userRolesInOrg, roleTypes...)
-				}
-			}
-		}
-
-		// Now determine the highest privilege role for this organization
-		if len(userRolesInOrg) > 0 {
-			finalRole := "member" // default to lowest privilege
-			for _, role := range userRolesInOrg {
-				if role == "admin" {
-					finalRole = "admin" // admin always wins
-					break
-				}
-			}
-			fmt.Printf("  FINAL ROLE for org %s: %s (from roles: %v)\n", orgID, finalRole, userRolesInOrg)
-			newMemberships[orgID] = finalRole
+	for orgID, matches := range orgMappings {
+		if role, ok := models.ResolveADGroupRole(matches); ok {
+			newMemberships[orgID] = role
 		}
 	}
 
@@ -159,26 +88,41 @@ func SyncUserOrganizationMemberships(db *sql.DB, userID string, userADGroups []s
 	}
 
 	// Remove user from organizations they should no longer be in
+	changed := false
 	for orgID := range currentMemberships {
 		if _, shouldBeIn := newMemberships[orgID]; !shouldBeIn {
-			_, err = tx.Exec(`DELETE FROM user_organizations WHERE user_id = $1 AND organization_id = $2`, userID, orgID)
+			result, err := tx.Exec(`DELETE FROM user_organizations WHERE user_id = $1 AND organization_id = $2 AND source = 'ad_sync'`, userID, orgID)
 			if err != nil {
 				return err
 			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				changed = true
+			}
 		}
 	}
 
 	// Add or update user memberships for organizations they should be in
 	for orgID, roleType := range newMemberships {
-		// Insert or update membership using role_name directly
-		_, err = tx.Exec(`
-			INSERT INTO user_organizations (user_id, organization_id, role_name)
-			VALUES ($1, $2, $3)
+		// Insert or update membership using role_name directly, never overriding a manual one
+		result, err := tx.Exec(`
+			INSERT INTO user_organizations (user_id, organization_id, role_name, source)
+			VALUES ($1, $2, $3, 'ad_sync')
 			ON CONFLICT (user_id, organization_id)
-			DO UPDATE SET role_name = EXCLUDED.role_name`, userID, orgID, roleType)
+			DO UPDATE SET role_name = EXCLUDED.role_name
+			WHERE user_organizations.source = 'ad_sync' AND user_organizations.role_name <> EXCLUDED.role_name`, userID, orgID, roleType)
 		if err != nil {
 			return err
 		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			changed = true
+		}
+	}
+
+	// Signed-in sessions pick up the new memberships on their next request
+	if changed {
+		if err := bumpPermissionsVersion(tx, userID); err != nil {
+			return err
+		}
 	}
 
 	return tx.Commit()
@@ -210,28 +154,18 @@ func GetUserOrganizationMemberships(db *sql.DB, userID string) (map[string]strin
 	return memberships, nil
 }
 
-// GetAPIKeyByID fetches an API key by its ID
-func GetAPIKeyByID(db *sql.DB, id string) (string, error) {
-	var apiKey string
-	err := db.QueryRow(`SELECT api_key FROM api_keys WHERE id = $1`, id).Scan(&apiKey)
-	if err != nil {
-		return "", err
-	}
-	return apiKey, nil
-}
-
 // GetOrganizationByID retrieves a single organization by ID
 func GetOrganizationByID(db *sql.DB, id string) (*models.Organization, error) {
 	query := `
 		SELECT id, name, description, is_active, created_at, updated_at,
-		       ad_admin_group_id, ad_admin_group_name, ad_member_group_id, ad_member_group_name
+		       ad_admin_group_id, ad_admin_group_name, ad_member_group_id, ad_member_group_name, parent_id
 		FROM organizations
 		WHERE id = $1`
 
 	var org models.Organization
 	err := db.QueryRow(query, id).Scan(
 		&org.ID, &org.Name, &org.Description, &org.IsActive, &org.CreatedAt, &org.UpdatedAt,
-		&org.AdAdminGroupID, &org.AdAdminGroupName, &org.AdMemberGroupID, &org.AdMemberGroupName,
+		&org.AdAdminGroupID, &org.AdAdminGroupName, &org.AdMemberGroupID, &org.AdMemberGroupName, &org.ParentID,
 	)
 	if err != nil {
 		return nil, err
@@ -240,79 +174,104 @@ func GetOrganizationByID(db *sql.DB, id string) (*models.Organization, error) {
 	return &org, nil
 }
 
+// CreateOrganization creates an active organization, nested under parentID unless it is "".
+// A top-level organization gets a quota of totalQuota; a sub-team draws on its parent's until
+// SetOrganizationQuota gives it one.
+func CreateOrganization(db *sql.DB, name, description, parentID string, totalQuota int) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var orgID string
+	err = tx.QueryRow(`
+		INSERT INTO organizations (name, description, parent_id)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, '')::uuid)
+		RETURNING id`, name, description, parentID).Scan(&orgID)
+	if err != nil {
+		return "", err
+	}
+
+	if parentID == "" {
+		_, err = tx.Exec(`
+			INSERT INTO organization_quotas (organization_id, total_quota, used_tokens)
+			VALUES ($1, $2, 0)`, orgID, totalQuota)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return orgID, tx.Commit()
+}
+
 // API Keys operations
-func GetAPIKeysWithOrganizations(db *sql.DB) ([]models.APIKey, error) {
-	query := `
+
+// apiKeySelect joins a key with its organization, project, creator and owner; scan rows with scanAPIKey
+const apiKeySelect = `
 		SELECT
-			ak.id, ak.name, ak.organization_id, ak.is_active,
-			ak.last_used, ak.created_at, ak.updated_at, ak.created_by_user_id,
+			ak.id, ak.name, ak.description, ak.organization_id, ak.is_active, ak.expires_at,
+			ak.last_used, ak.created_at, ak.updated_at, ak.created_by_user_id, ak.owner_user_id, ak.scopes,
+			ak.project_id, p.name as project_name, ak.is_canary, ak.canary_block_ip,
 			o.name as org_name,
-			u.id as user_id, u.name as user_name, u.email as user_email
+			u.id as user_id, u.name as user_name, u.email as user_email,
+			ow.id as owner_id, ow.name as owner_name, ow.email as owner_email
 		FROM api_keys ak
 		JOIN organizations o ON ak.organization_id = o.id
 		LEFT JOIN users u ON ak.created_by_user_id = u.id
-		WHERE ak.is_active = true
-		ORDER BY ak.created_at DESC`
+		LEFT JOIN users ow ON ak.owner_user_id = ow.id
+		LEFT JOIN projects p ON ak.project_id = p.id`
 
-	rows, err := db.Query(query)
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	var key models.APIKey
+	var orgName string
+	var userID, userName, userEmail sql.NullString
+	var ownerID, ownerName, ownerEmail sql.NullString
+
+	err := row.Scan(
+		&key.ID, &key.Name, &key.Description, &key.OrganizationID, &key.IsActive, &key.ExpiresAt,
+		&key.LastUsed, &key.CreatedAt, &key.UpdatedAt, &key.UserID, &key.OwnerUserID, pq.Array(&key.Scopes),
+		&key.ProjectID, &key.ProjectName, &key.IsCanary, &key.CanaryBlockIP,
+		&orgName, &userID, &userName, &userEmail,
+		&ownerID, &ownerName, &ownerEmail,
+	)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var apiKeys []models.APIKey
-	for rows.Next() {
-		var key models.APIKey
-		var orgName string
-		var userID, userName, userEmail sql.NullString
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
 
-		err := rows.Scan(
-			&key.ID, &key.Name, &key.OrganizationID, &key.IsActive,
-			&key.LastUsed, &key.CreatedAt, &key.UpdatedAt, &key.UserID,
-			&orgName, &userID, &userName, &userEmail,
-		)
-		if err != nil {
-			return nil, err
-		}
+	// Create a display prefix from the key ID
+	key.KeyPrefix = "sk-" + key.ID[:8] + "..."
 
-		// Create a display prefix from the key ID
-		key.KeyPrefix = "sk-" + key.ID[:8] + "..."
+	// Attach organization info
+	key.Organization = &models.Organization{
+		ID:   key.OrganizationID,
+		Name: orgName,
+	}
 
-		// Attach organization info
-		key.Organization = &models.Organization{
-			ID:   key.OrganizationID,
-			Name: orgName,
+	// Attach creator and owner info if available
+	if userID.Valid && userName.Valid && userEmail.Valid {
+		key.User = &models.User{
+			ID:    userID.String,
+			Name:  userName.String,
+			Email: userEmail.String,
 		}
-
-		// Attach user info if available
-		if userID.Valid && userName.Valid && userEmail.Valid {
-			key.User = &models.User{
-				ID:    userID.String,
-				Name:  userName.String,
-				Email: userEmail.String,
-			}
+	}
+	if ownerID.Valid && ownerName.Valid && ownerEmail.Valid {
+		key.Owner = &models.User{
+			ID:    ownerID.String,
+			Name:  ownerName.String,
+			Email: ownerEmail.String,
 		}
-
-		apiKeys = append(apiKeys, key)
 	}
 
-	return apiKeys, nil
+	return &key, nil
 }
 
-func GetAPIKeysByOrganization(db *sql.DB, orgID string) ([]models.APIKey, error) {
-	query := `
-		SELECT
-			ak.id, ak.name, ak.organization_id, ak.is_active,
-			ak.last_used, ak.created_at, ak.updated_at, ak.created_by_user_id,
-			o.name as org_name,
-			u.id as user_id, u.name as user_name, u.email as user_email
-		FROM api_keys ak
-		JOIN organizations o ON ak.organization_id = o.id
-		LEFT JOIN users u ON ak.created_by_user_id = u.id
-		WHERE ak.is_active = true AND ak.organization_id = $1
-		ORDER BY ak.created_at DESC`
-
-	rows, err := db.Query(query, orgID)
+func queryAPIKeys(db *sql.DB, query string, args ...interface{}) ([]models.APIKey, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -320,41 +279,92 @@ func GetAPIKeysByOrganization(db *sql.DB, orgID string) ([]models.APIKey, error)
 
 	var apiKeys []models.APIKey
 	for rows.Next() {
-		var key models.APIKey
-		var orgName string
-		var userID, userName, userEmail sql.NullString
-
-		err := rows.Scan(
-			&key.ID, &key.Name, &key.OrganizationID, &key.IsActive,
-			&key.LastUsed, &key.CreatedAt, &key.UpdatedAt, &key.UserID,
-			&orgName, &userID, &userName, &userEmail,
-		)
+		key, err := scanAPIKey(rows)
 		if err != nil {
 			return nil, err
 		}
+		apiKeys = append(apiKeys, *key)
+	}
 
-		// Create a display prefix from the key ID
-		key.KeyPrefix = "sk-" + key.ID[:8] + "..."
+	return apiKeys, rows.Err()
+}
 
-		// Attach organization info
-		key.Organization = &models.Organization{
-			ID:   key.OrganizationID,
-			Name: orgName,
-		}
+func GetAPIKeysWithOrganizations(db *sql.DB) ([]models.APIKey, error) {
+	return queryAPIKeys(db, apiKeySelect+`
+		WHERE ak.is_active = true
+		ORDER BY ak.created_at DESC`)
+}
 
-		// Attach user info if available
-		if userID.Valid && userName.Valid && userEmail.Valid {
-			key.User = &models.User{
-				ID:    userID.String,
-				Name:  userName.String,
-				Email: userEmail.String,
-			}
+// GetAPIKeysByOrganization returns an organization's active keys with their creator, owner and
+// daily request counts for the last models.SparklineDays days
+func GetAPIKeysByOrganization(db *sql.DB, orgID string) ([]models.APIKey, error) {
+	keys, err := queryAPIKeys(db, apiKeySelect+`
+		WHERE ak.is_active = true AND ak.organization_id = $1
+		ORDER BY ak.created_at DESC`, orgID)
+	if err != nil || len(keys) == 0 {
+		return keys, err
+	}
+
+	// Days are UTC, counted back from the start of today
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-models.SparklineDays)
+	rows, err := db.Query(`
+		SELECT api_key_id, FLOOR(EXTRACT(EPOCH FROM created_at - $2) / 86400)::int AS day, COUNT(*)
+		FROM usage_logs
+		WHERE organization_id = $1 AND created_at >= $2
+		GROUP BY api_key_id, day`, orgID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key usage: %w", err)
+	}
+	defer rows.Close()
+
+	daily := make(map[string][]int, len(keys))
+	for rows.Next() {
+		var keyID string
+		var day, count int
+		if err := rows.Scan(&keyID, &day, &count); err != nil {
+			return nil, err
 		}
+		if day < 0 || day >= models.SparklineDays {
+			continue
+		}
+		if daily[keyID] == nil {
+			daily[keyID] = make([]int, models.SparklineDays)
+		}
+		daily[keyID][day] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		apiKeys = append(apiKeys, key)
+	for i := range keys {
+		keys[i].DailyRequests = daily[keys[i].ID]
+		if keys[i].DailyRequests == nil {
+			keys[i].DailyRequests = make([]int, models.SparklineDays)
+		}
 	}
+	return keys, nil
+}
 
-	return apiKeys, nil
+// GetAPIKey returns an active API key with its organization, creator and owner
+func GetAPIKey(db *sql.DB, keyID string) (*models.APIKey, error) {
+	return scanAPIKey(db.QueryRow(apiKeySelect+`
+		WHERE ak.is_active = true AND ak.id = $1`, keyID))
+}
+
+// TransferAPIKeyOwner makes ownerUserID responsible for a key. The caller checks the new owner
+// belongs to the key's organization.
+func TransferAPIKeyOwner(db *sql.DB, keyID, ownerUserID string) error {
+	result, err := db.Exec(`
+		UPDATE api_keys SET owner_user_id = $2, updated_at = NOW()
+		WHERE id = $1 AND is_active = true`, keyID, ownerUserID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
 func CreateAPIKey(db *sql.DB, req models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
@@ -364,13 +374,25 @@ func CreateAPIKey(db *sql.DB, req models.CreateAPIKeyRequest) (*models.CreateAPI
 		return nil, fmt.Errorf("failed to generate API key: %w", err)
 	}
 
+	// The creator owns the key unless someone else was named
+	ownerUserID := req.OwnerUserID
+	if ownerUserID == nil {
+		ownerUserID = req.UserID
+	}
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
 	query := `
-		INSERT INTO api_keys (name, organization_id, api_key, created_by_user_id)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO api_keys (name, description, organization_id, api_key, created_by_user_id, owner_user_id, expires_at, scopes, project_id,
+		                      is_canary, canary_block_ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id, created_at, updated_at`
 
 	var apiKey models.APIKey
-	err = db.QueryRow(query, req.Name, req.OrganizationID, fullKey, req.UserID).Scan(&apiKey.ID, &apiKey.CreatedAt, &apiKey.UpdatedAt)
+	err = db.QueryRow(query, req.Name, req.Description, req.OrganizationID, fullKey, req.UserID, ownerUserID,
+		req.ExpiresAt, pq.Array(scopes), req.ProjectID, req.IsCanary, req.CanaryBlockIP).Scan(&apiKey.ID, &apiKey.CreatedAt, &apiKey.UpdatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create API key: %w", err)
@@ -382,6 +404,12 @@ func CreateAPIKey(db *sql.DB, req models.CreateAPIKeyRequest) (*models.CreateAPI
 	apiKey.KeyPrefix = keyPrefix
 	apiKey.OrganizationID = req.OrganizationID
 	apiKey.UserID = req.UserID
+	apiKey.OwnerUserID = ownerUserID
+	apiKey.ExpiresAt = req.ExpiresAt
+	apiKey.Scopes = scopes
+	apiKey.ProjectID = req.ProjectID
+	apiKey.IsCanary = req.IsCanary
+	apiKey.CanaryBlockIP = req.CanaryBlockIP
 	apiKey.IsActive = true
 
 	// Get organization name
@@ -498,8 +526,10 @@ func RegenerateAPIKey(db *sql.DB, keyID string) (*models.CreateAPIKeyResponse, e
 func GetModelsWithOrganizations(db *sql.DB) ([]models.Model, error) {
 	// First get all active models (exclude soft-deleted ones)
 	query := `SELECT id, name, description, provider, model_id, api_endpoint, api_token,
+	          aws_region, aws_access_key_id, aws_secret_access_key, aws_role_arn,
+	          gcp_location, gcp_service_account,
 	          input_cost_per_1m, output_cost_per_1m, max_retries, timeout_seconds,
-	          retry_delay_ms, backoff_multiplier, is_active, created_at, updated_at
+	          retry_delay_ms, backoff_multiplier, max_request_bytes, metadata, transport, is_active, created_at, updated_at
 			  FROM models
 			  WHERE is_active = true
 			  ORDER BY name`
@@ -515,14 +545,23 @@ func GetModelsWithOrganizations(db *sql.DB) ([]models.Model, error) {
 
 	for rows.Next() {
 		var model models.Model
+		var metadata, transport []byte
 		err := rows.Scan(&model.ID, &model.Name, &model.Description, &model.Provider,
 			&model.ModelID, &model.APIEndpoint, &model.APIToken,
+			&model.AWSRegion, &model.AWSAccessKeyID, &model.AWSSecretKey, &model.AWSRoleARN,
+			&model.GCPLocation, &model.GCPServiceAccount,
 			&model.InputCostPer1M, &model.OutputCostPer1M,
 			&model.MaxRetries, &model.TimeoutSeconds, &model.RetryDelayMs, &model.BackoffMultiplier,
-			&model.IsActive, &model.CreatedAt, &model.UpdatedAt)
+			&model.MaxRequestBytes, &metadata, &transport, &model.IsActive, &model.CreatedAt, &model.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
+		if err := decodeModelMetadata(metadata, &model.Metadata); err != nil {
+			return nil, err
+		}
+		if err := decodeModelTransport(transport, &model.Transport); err != nil {
+			return nil, err
+		}
 		model.Organizations = []models.Organization{}
 		modelsMap[model.ID] = &model
 		modelsList = append(modelsList, model)
@@ -617,18 +656,47 @@ func CreateModel(db *sql.DB, req models.CreateModelRequest) (*models.Model, erro
 			backoffMultiplier = &multiplier
 		}
 	}
+	var maxRequestBytes *int
+	if req.MaxRequestBytes != nil && *req.MaxRequestBytes != "" {
+		limit, err := strconv.Atoi(*req.MaxRequestBytes)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("max_request_bytes must be a positive number of bytes")
+		}
+		maxRequestBytes = &limit
+	}
+
+	var metadata models.ModelMetadata
+	if req.Metadata != nil {
+		metadata = *req.Metadata
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	var transport models.ModelTransport
+	if req.Transport != nil {
+		transport = *req.Transport
+	}
+	transportJSON, err := json.Marshal(transport)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create the model
 	query := `
 		INSERT INTO models (name, description, provider, model_id, api_endpoint, api_token,
 		                   input_cost_per_1m, output_cost_per_1m, max_retries, timeout_seconds,
-		                   retry_delay_ms, backoff_multiplier)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		                   retry_delay_ms, backoff_multiplier,
+		                   aws_region, aws_access_key_id, aws_secret_access_key, aws_role_arn,
+		                   gcp_location, gcp_service_account, max_request_bytes, metadata, transport)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
 		RETURNING id, created_at, updated_at`
 
 	var model models.Model
 	err = tx.QueryRow(query, req.Name, req.Description, req.Provider, req.ModelID, req.APIEndpoint, req.APIToken,
-		inputCost, outputCost, maxRetries, timeoutSeconds, retryDelayMs, backoffMultiplier).
+		inputCost, outputCost, maxRetries, timeoutSeconds, retryDelayMs, backoffMultiplier,
+		req.AWSRegion, req.AWSAccessKeyID, req.AWSSecretKey, req.AWSRoleARN,
+		req.GCPLocation, req.GCPServiceAccount, maxRequestBytes, metadataJSON, transportJSON).
 		Scan(&model.ID, &model.CreatedAt, &model.UpdatedAt)
 	if err != nil {
 		return nil, err
@@ -641,12 +709,21 @@ func CreateModel(db *sql.DB, req models.CreateModelRequest) (*models.Model, erro
 	model.ModelID = req.ModelID
 	model.APIEndpoint = req.APIEndpoint
 	model.APIToken = req.APIToken
+	model.AWSRegion = req.AWSRegion
+	model.AWSAccessKeyID = req.AWSAccessKeyID
+	model.AWSSecretKey = req.AWSSecretKey
+	model.AWSRoleARN = req.AWSRoleARN
+	model.GCPLocation = req.GCPLocation
+	model.GCPServiceAccount = req.GCPServiceAccount
 	model.InputCostPer1M = inputCost
 	model.OutputCostPer1M = outputCost
 	model.MaxRetries = maxRetries
 	model.TimeoutSeconds = timeoutSeconds
 	model.RetryDelayMs = retryDelayMs
 	model.BackoffMultiplier = backoffMultiplier
+	model.MaxRequestBytes = maxRequestBytes
+	model.Metadata = metadata
+	model.Transport = transport
 	model.IsActive = true
 
 	// Add organization access
@@ -704,11 +781,42 @@ func UpdateModel(db *sql.DB, modelID string, req models.UpdateModelRequest) (*mo
 		args = append(args, *req.APIEndpoint)
 		argIndex++
 	}
-	if req.APIToken != nil {
+	// A masked token means the client echoed back what it was shown; keep the stored one
+	if req.APIToken != nil && !models.IsMaskedSecret(*req.APIToken) {
 		setParts = append(setParts, fmt.Sprintf("api_token = $%d", argIndex))
 		args = append(args, *req.APIToken)
 		argIndex++
 	}
+	if req.AWSRegion != nil {
+		setParts = append(setParts, fmt.Sprintf("aws_region = $%d", argIndex))
+		args = append(args, *req.AWSRegion)
+		argIndex++
+	}
+	if req.AWSAccessKeyID != nil {
+		setParts = append(setParts, fmt.Sprintf("aws_access_key_id = $%d", argIndex))
+		args = append(args, *req.AWSAccessKeyID)
+		argIndex++
+	}
+	if req.AWSSecretKey != nil && !models.IsMaskedSecret(*req.AWSSecretKey) {
+		setParts = append(setParts, fmt.Sprintf("aws_secret_access_key = $%d", argIndex))
+		args = append(args, *req.AWSSecretKey)
+		argIndex++
+	}
+	if req.AWSRoleARN != nil {
+		setParts = append(setParts, fmt.Sprintf("aws_role_arn = $%d", argIndex))
+		args = append(args, *req.AWSRoleARN)
+		argIndex++
+	}
+	if req.GCPLocation != nil {
+		setParts = append(setParts, fmt.Sprintf("gcp_location = $%d", argIndex))
+		args = append(args, *req.GCPLocation)
+		argIndex++
+	}
+	if req.GCPServiceAccount != nil && !models.IsMaskedSecret(*req.GCPServiceAccount) {
+		setParts = append(setParts, fmt.Sprintf("gcp_service_account = $%d", argIndex))
+		args = append(args, *req.GCPServiceAccount)
+		argIndex++
+	}
 	if req.InputCostPer1M != nil && *req.InputCostPer1M != "" {
 		if cost, err := strconv.ParseFloat(*req.InputCostPer1M, 64); err == nil {
 			setParts = append(setParts, fmt.Sprintf("input_cost_per_1m = $%d", argIndex))
@@ -758,6 +866,49 @@ func UpdateModel(db *sql.DB, modelID string, req models.UpdateModelRequest) (*mo
 			argIndex++
 		}
 	}
+	// An empty limit clears it, falling back to the gateway's
+	if req.MaxRequestBytes != nil {
+		var limit *int
+		if *req.MaxRequestBytes != "" {
+			n, err := strconv.Atoi(*req.MaxRequestBytes)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("max_request_bytes must be a positive number of bytes")
+			}
+			limit = &n
+		}
+		setParts = append(setParts, fmt.Sprintf("max_request_bytes = $%d", argIndex))
+		args = append(args, limit)
+		argIndex++
+	}
+	if req.Metadata != nil {
+		metadataJSON, err := json.Marshal(req.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		setParts = append(setParts, fmt.Sprintf("metadata = $%d", argIndex))
+		args = append(args, metadataJSON)
+		argIndex++
+	}
+	if req.Transport != nil {
+		// A redacted proxy URL sent back unchanged keeps the stored password
+		var stored []byte
+		if err := tx.QueryRow(`SELECT transport FROM models WHERE id = $1`, modelID).Scan(&stored); err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		var storedTransport models.ModelTransport
+		if err := decodeModelTransport(stored, &storedTransport); err != nil {
+			return nil, err
+		}
+		req.Transport.RestoreProxyPassword(storedTransport)
+
+		transportJSON, err := json.Marshal(req.Transport)
+		if err != nil {
+			return nil, err
+		}
+		setParts = append(setParts, fmt.Sprintf("transport = $%d", argIndex))
+		args = append(args, transportJSON)
+		argIndex++
+	}
 	if req.IsActive != nil {
 		setParts = append(setParts, fmt.Sprintf("is_active = $%d", argIndex))
 		args = append(args, *req.IsActive)
@@ -776,23 +927,32 @@ func UpdateModel(db *sql.DB, modelID string, req models.UpdateModelRequest) (*mo
 	whereClause := fmt.Sprintf("id = $%d", argIndex)
 
 	query := fmt.Sprintf(
-		`UPDATE models SET %s WHERE %s RETURNING id, name, description, provider, model_id, api_endpoint, api_token, input_cost_per_1m, output_cost_per_1m, max_retries, timeout_seconds, retry_delay_ms, backoff_multiplier, is_active, created_at, updated_at`,
+		`UPDATE models SET %s WHERE %s RETURNING id, name, description, provider, model_id, api_endpoint, api_token, aws_region, aws_access_key_id, aws_secret_access_key, aws_role_arn, gcp_location, gcp_service_account, input_cost_per_1m, output_cost_per_1m, max_retries, timeout_seconds, retry_delay_ms, backoff_multiplier, max_request_bytes, metadata, transport, is_active, created_at, updated_at`,
 		strings.Join(setParts, ", "),
 		whereClause,
 	)
 
 	var model models.Model
+	var metadata, transport []byte
 	err = tx.QueryRow(query, args...).Scan(
 		&model.ID, &model.Name, &model.Description, &model.Provider,
 		&model.ModelID, &model.APIEndpoint, &model.APIToken,
+		&model.AWSRegion, &model.AWSAccessKeyID, &model.AWSSecretKey, &model.AWSRoleARN,
+		&model.GCPLocation, &model.GCPServiceAccount,
 		&model.InputCostPer1M, &model.OutputCostPer1M,
 		&model.MaxRetries, &model.TimeoutSeconds, &model.RetryDelayMs, &model.BackoffMultiplier,
-		&model.IsActive, &model.CreatedAt, &model.UpdatedAt,
+		&model.MaxRequestBytes, &metadata, &transport, &model.IsActive, &model.CreatedAt, &model.UpdatedAt,
 	)
 
 	if err != nil {
 		return nil, err
 	}
+	if err := decodeModelMetadata(metadata, &model.Metadata); err != nil {
+		return nil, err
+	}
+	if err := decodeModelTransport(transport, &model.Transport); err != nil {
+		return nil, err
+	}
 
 	// Handle organization access updates if provided
 	if len(req.OrgIDs) > 0 {
@@ -829,21 +989,32 @@ func UpdateModel(db *sql.DB, modelID string, req models.UpdateModelRequest) (*mo
 func GetModelWithOrganizations(db *sql.DB, modelID string) (*models.Model, error) {
 	// Get the model
 	query := `SELECT id, name, description, provider, model_id, api_endpoint, api_token,
+	          aws_region, aws_access_key_id, aws_secret_access_key, aws_role_arn,
+	          gcp_location, gcp_service_account,
 	          input_cost_per_1m, output_cost_per_1m, max_retries, timeout_seconds,
-	          retry_delay_ms, backoff_multiplier, is_active, created_at, updated_at
+	          retry_delay_ms, backoff_multiplier, max_request_bytes, metadata, transport, is_active, created_at, updated_at
 			  FROM models WHERE id = $1`
 
 	var model models.Model
+	var metadata, transport []byte
 	err := db.QueryRow(query, modelID).Scan(
 		&model.ID, &model.Name, &model.Description, &model.Provider,
 		&model.ModelID, &model.APIEndpoint, &model.APIToken,
+		&model.AWSRegion, &model.AWSAccessKeyID, &model.AWSSecretKey, &model.AWSRoleARN,
+		&model.GCPLocation, &model.GCPServiceAccount,
 		&model.InputCostPer1M, &model.OutputCostPer1M,
 		&model.MaxRetries, &model.TimeoutSeconds, &model.RetryDelayMs, &model.BackoffMultiplier,
-		&model.IsActive, &model.CreatedAt, &model.UpdatedAt,
+		&model.MaxRequestBytes, &metadata, &transport, &model.IsActive, &model.CreatedAt, &model.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if err := decodeModelMetadata(metadata, &model.Metadata); err != nil {
+		return nil, err
+	}
+	if err := decodeModelTransport(transport, &model.Transport); err != nil {
+		return nil, err
+	}
 
 	// Get organization access
 	orgQuery := `
@@ -869,6 +1040,26 @@ func GetModelWithOrganizations(db *sql.DB, modelID string) (*models.Model, error
 	}
 
 	model.Organizations = organizations
+
+	// Get organizations denied access they would inherit from a parent
+	deniedRows, err := db.Query(`
+		SELECT o.id, o.name
+		FROM model_organization_access_denials d
+		JOIN organizations o ON d.organization_id = o.id
+		WHERE d.model_id = $1 AND o.is_active = true`, modelID)
+	if err != nil {
+		return &model, nil
+	}
+	defer deniedRows.Close()
+
+	for deniedRows.Next() {
+		var org models.Organization
+		if err := deniedRows.Scan(&org.ID, &org.Name); err != nil {
+			continue
+		}
+		model.DeniedOrganizations = append(model.DeniedOrganizations, org)
+	}
+
 	return &model, nil
 }
 
@@ -882,7 +1073,15 @@ func ManageModelAccess(db *sql.DB, modelID string, changes []ModelAccessChange)
 	for _, change := range changes {
 		switch change.Action {
 		case "add":
-			// Add organization access (ignore if already exists)
+			// Add organization access (ignore if already exists), lifting any denial
+			_, err = tx.Exec(
+				`DELETE FROM model_organization_access_denials
+				 WHERE model_id = $1 AND organization_id = $2`,
+				modelID, change.OrgID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to lift organization access denial: %w", err)
+			}
 			_, err = tx.Exec(
 				`INSERT INTO model_organization_access (model_id, organization_id)
 				 VALUES ($1, $2)
@@ -902,6 +1101,33 @@ func ManageModelAccess(db *sql.DB, modelID string, changes []ModelAccessChange)
 			if err != nil {
 				return fmt.Errorf("failed to remove organization access: %w", err)
 			}
+			_, err = tx.Exec(
+				`DELETE FROM model_organization_access_denials
+				 WHERE model_id = $1 AND organization_id = $2`,
+				modelID, change.OrgID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to remove organization access denial: %w", err)
+			}
+		case "deny":
+			// Block access the organization would inherit from a parent organization
+			_, err = tx.Exec(
+				`DELETE FROM model_organization_access
+				 WHERE model_id = $1 AND organization_id = $2`,
+				modelID, change.OrgID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to remove organization access: %w", err)
+			}
+			_, err = tx.Exec(
+				`INSERT INTO model_organization_access_denials (model_id, organization_id)
+				 VALUES ($1, $2)
+				 ON CONFLICT (model_id, organization_id) DO NOTHING`,
+				modelID, change.OrgID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to deny organization access: %w", err)
+			}
 		default:
 			return fmt.Errorf("invalid action: %s", change.Action)
 		}
@@ -913,7 +1139,7 @@ func ManageModelAccess(db *sql.DB, modelID string, changes []ModelAccessChange)
 // ModelAccessChange represents a change to model organization access
 type ModelAccessChange struct {
 	OrgID  string `json:"orgId"`
-	Action string `json:"action"` // "add" or "remove"
+	Action string `json:"action"` // "add", "remove" or "deny" (block access inherited from a parent)
 }
 
 func DeleteModel(db *sql.DB, modelID string) error {
@@ -1019,6 +1245,13 @@ func GetUserByEmail(db *sql.DB, email string) (*models.User, error) {
 }
 
 func CreateOrUpdateUser(db *sql.DB, req models.CreateUserRequest) (*models.User, error) {
+	// A user created by first-run setup gets their real object ID on first sign-in
+	_, err := db.Exec(`UPDATE users SET azure_oid = $1, updated_at = NOW() WHERE LOWER(email) = LOWER($2) AND azure_oid LIKE $3`,
+		req.AzureOID, req.Email, pendingAzureOIDPrefix+"%")
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		INSERT INTO users (azure_oid, email, name)
 		VALUES ($1, $2, $3)
@@ -1031,7 +1264,7 @@ func CreateOrUpdateUser(db *sql.DB, req models.CreateUserRequest) (*models.User,
 		RETURNING id, azure_oid, email, name, is_active, last_login, created_at, updated_at`
 
 	var user models.User
-	err := db.QueryRow(query, req.AzureOID, req.Email, req.Name).Scan(
+	err = db.QueryRow(query, req.AzureOID, req.Email, req.Name).Scan(
 		&user.ID, &user.AzureOID, &user.Email, &user.Name,
 		&user.IsActive, &user.LastLogin, &user.CreatedAt, &user.UpdatedAt,
 	)
@@ -1067,15 +1300,18 @@ func GetUserByID(db *sql.DB, userID string) (*models.User, error) {
 	return &user, nil
 }
 
+// AssignUserToOrganization adds or updates a manual membership, which AD group sync won't remove
 func AssignUserToOrganization(db *sql.DB, userID, orgID, roleName string, createdBy *string) error {
 	query := `
-		INSERT INTO user_organizations (user_id, organization_id, role_name, created_by)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO user_organizations (user_id, organization_id, role_name, created_by, source)
+		VALUES ($1, $2, $3, $4, 'manual')
 		ON CONFLICT (user_id, organization_id)
-		DO UPDATE SET role_name = EXCLUDED.role_name, created_by = EXCLUDED.created_by`
+		DO UPDATE SET role_name = EXCLUDED.role_name, created_by = EXCLUDED.created_by, source = 'manual'`
 
-	_, err := db.Exec(query, userID, orgID, roleName, createdBy)
-	return err
+	if _, err := db.Exec(query, userID, orgID, roleName, createdBy); err != nil {
+		return err
+	}
+	return bumpPermissionsVersion(db, userID)
 }
 
 func AssignSystemRole(db *sql.DB, userID, roleID string, createdBy *string) error {
@@ -1084,8 +1320,29 @@ func AssignSystemRole(db *sql.DB, userID, roleID string, createdBy *string) erro
 		VALUES ($1, $2, $3)
 		ON CONFLICT (user_id, role_id) DO NOTHING`
 
-	_, err := db.Exec(query, userID, roleID, createdBy)
-	return err
+	result, err := db.Exec(query, userID, roleID, createdBy)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil
+	}
+	return bumpPermissionsVersion(db, userID)
+}
+
+// IsSystemAdmin reports whether the user holds a system-level role (System Admin)
+func IsSystemAdmin(db *sql.DB, userID string) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM user_system_roles usr
+			JOIN roles r ON usr.role_id = r.id
+			WHERE usr.user_id = $1 AND r.is_system_role = true
+		)`
+
+	var isAdmin bool
+	err := db.QueryRow(query, userID).Scan(&isAdmin)
+	return isAdmin, err
 }
 
 // Legacy user function for backwards compatibility
@@ -1132,8 +1389,20 @@ func GetEndpointsWithModels(db *sql.DB) ([]models.Endpoint, error) {
 	return endpoints, nil
 }
 
-func GetEndpointsByOrganization(db *sql.DB, orgID string) ([]models.Endpoint, error) {
-	query := `
+// GetEndpointsByOrganization returns the active endpoints belonging to any of the given organizations
+func GetEndpointsByOrganization(db *sql.DB, orgIDs []string) ([]models.Endpoint, error) {
+	if len(orgIDs) == 0 {
+		return []models.Endpoint{}, nil
+	}
+
+	placeholders := make([]string, len(orgIDs))
+	args := make([]interface{}, len(orgIDs))
+	for i, id := range orgIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`
 		SELECT
 			e.id, e.organization_id, e.name, e.path_prefix, e.description,
 			e.primary_model_id, e.fallback_model_id, e.is_active, e.created_at, e.updated_at,
@@ -1141,10 +1410,10 @@ func GetEndpointsByOrganization(db *sql.DB, orgID string) ([]models.Endpoint, er
 		FROM endpoints e
 		LEFT JOIN models pm ON e.primary_model_id = pm.id
 		LEFT JOIN models fm ON e.fallback_model_id = fm.id
-		WHERE e.is_active = true AND e.organization_id = $1
-		ORDER BY e.created_at DESC`
+		WHERE e.is_active = true AND e.organization_id IN (%s)
+		ORDER BY e.created_at DESC`, strings.Join(placeholders, ", "))
 
-	rows, err := db.Query(query, orgID)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1252,7 +1521,7 @@ func UpdateEndpoint(db *sql.DB, endpointID string, req models.EndpointUpdate) (*
 
 	query := fmt.Sprintf(
 		`UPDATE endpoints SET %s WHERE %s RETURNING id, organization_id, name, path_prefix, description, primary_model_id, fallback_model_id, is_active, created_at, updated_at`,
-		fmt.Sprintf("%s", setParts),
+		strings.Join(setParts, ", "),
 		whereClause,
 	)
 
@@ -1304,29 +1573,34 @@ func GetEndpointByID(db *sql.DB, endpointID string) (*models.Endpoint, error) {
 
 // Usage tracking operations
 
-// CreateUsageLog records API usage for billing and analytics
-func CreateUsageLog(db *sql.DB, req CreateUsageLogRequest) error {
-	// Convert metadata to JSON
-	metadataJSON, err := json.Marshal(req.Metadata)
+// GetUsageLogsSince returns up to limit usage logs created after since, oldest first, optionally
+// limited to one organization. Metadata is not loaded.
+func GetUsageLogsSince(db *sql.DB, orgID string, since time.Time, limit int) ([]models.UsageLog, error) {
+	rows, err := db.Query(`
+		SELECT id, organization_id, api_key_id, model_id, endpoint, prompt_tokens, completion_tokens,
+		       total_tokens, request_id, response_status, response_time_ms, cost_usd, created_at
+		FROM usage_logs
+		WHERE created_at > $1 AND ($2 = '' OR organization_id = NULLIF($2, '')::uuid)
+		ORDER BY created_at
+		LIMIT $3`, since, orgID, limit)
 	if err != nil {
-		metadataJSON = []byte("{}")
+		return nil, err
 	}
+	defer rows.Close()
 
-	query := `
-		INSERT INTO usage_logs (
-			organization_id, api_key_id, model_id, endpoint,
-			prompt_tokens, completion_tokens, total_tokens,
-			request_id, response_status, response_time_ms, cost_usd, metadata
+	logs := []models.UsageLog{}
+	for rows.Next() {
+		var l models.UsageLog
+		err := rows.Scan(
+			&l.ID, &l.OrganizationID, &l.APIKeyID, &l.ModelID, &l.Endpoint, &l.PromptTokens, &l.CompletionTokens,
+			&l.TotalTokens, &l.RequestID, &l.ResponseStatus, &l.ResponseTimeMS, &l.CostUSD, &l.CreatedAt,
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
-
-	_, err = db.Exec(query,
-		req.OrganizationID, req.APIKeyID, req.ModelID, req.Endpoint,
-		req.PromptTokens, req.CompletionTokens, req.TotalTokens,
-		req.RequestID, req.ResponseStatus, req.ResponseTimeMS, req.CostUSD, metadataJSON,
-	)
-
-	return err
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, rows.Err()
 }
 
 // CreateUsageLogRequest represents the data needed to create a usage log
@@ -1343,17 +1617,7 @@ type CreateUsageLogRequest struct {
 	ResponseTimeMS   *int                   `json:"response_time_ms"`
 	CostUSD          *float64               `json:"cost_usd"`
 	Metadata         map[string]interface{} `json:"metadata"`
-}
-
-// UpdateOrganizationUsage updates the organization's token usage and quota
-func UpdateOrganizationUsage(db *sql.DB, orgID string, tokensUsed int) error {
-	query := `
-		UPDATE organization_quotas
-		SET used_tokens = used_tokens + $1, updated_at = NOW()
-		WHERE organization_id = $2`
-
-	_, err := db.Exec(query, tokensUsed, orgID)
-	return err
+	CreatedAt        time.Time              `json:"created_at"` // When the request was made; zero means now
 }
 
 // GetUsageStatsByOrganization retrieves usage statistics for an organization
@@ -1465,7 +1729,8 @@ func GetUsersWithOrganizations(db *sql.DB) ([]models.UserWithOrganizations, erro
 					JSON_BUILD_OBJECT(
 						'org_id', o.id,
 						'org_name', o.name,
-						'role_name', uo.role_name
+						'role_name', uo.role_name,
+						'source', uo.source
 					) ORDER BY o.name
 				) FILTER (WHERE o.id IS NOT NULL),
 				'[]'::json
@@ -1517,7 +1782,8 @@ func GetUsersByOrganization(db *sql.DB, orgID string) ([]models.UserWithOrganiza
 			JSON_BUILD_OBJECT(
 				'org_id', o.id,
 				'org_name', o.name,
-				'role_name', uo.role_name
+				'role_name', uo.role_name,
+				'source', uo.source
 			) as organization
 		FROM users u
 		JOIN user_organizations uo ON u.id = uo.user_id
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"database/sql"
+	"sort"
+
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+const orgADGroupColumns = `id, organization_id, ad_group_id, COALESCE(ad_group_name, ''), role_type, priority, is_active, created_at`
+
+func scanOrgADGroupMapping(row interface{ Scan(...interface{}) error }) (*models.OrgADGroupMapping, error) {
+	var m models.OrgADGroupMapping
+	if err := row.Scan(&m.ID, &m.OrganizationID, &m.ADGroupID, &m.ADGroupName, &m.RoleName, &m.Priority,
+		&m.IsActive, &m.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetOrganizationADGroupMappings returns an organization's AD group mappings in resolution order
+func GetOrganizationADGroupMappings(db *sql.DB, orgID string) ([]models.OrgADGroupMapping, error) {
+	rows, err := db.Query(`
+		SELECT `+orgADGroupColumns+` FROM organization_ad_groups
+		WHERE organization_id = $1
+		ORDER BY is_active DESC, priority, ad_group_name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mappings := []models.OrgADGroupMapping{}
+	for rows.Next() {
+		m, err := scanOrgADGroupMapping(rows)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, *m)
+	}
+
+	return mappings, rows.Err()
+}
+
+// CreateOrganizationADGroupMapping maps an AD group to a role, reactivating an identical
+// mapping if one was disabled
+func CreateOrganizationADGroupMapping(db *sql.DB, orgID string, req models.CreateOrgADGroupMappingRequest) (*models.OrgADGroupMapping, error) {
+	priority := models.DefaultADGroupPriority
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+
+	return scanOrgADGroupMapping(db.QueryRow(`
+		INSERT INTO organization_ad_groups (organization_id, ad_group_id, ad_group_name, role_type, priority, is_active)
+		VALUES ($1, $2, $3, $4, $5, true)
+		ON CONFLICT (organization_id, ad_group_id, role_type) DO UPDATE SET
+			ad_group_name = EXCLUDED.ad_group_name,
+			priority = EXCLUDED.priority,
+			is_active = true
+		RETURNING `+orgADGroupColumns, orgID, req.ADGroupID, req.ADGroupName, req.RoleName, priority))
+}
+
+// UpdateOrganizationADGroupMapping applies the set fields of req to a mapping
+func UpdateOrganizationADGroupMapping(db *sql.DB, orgID, mappingID string, req models.UpdateOrgADGroupMappingRequest) (*models.OrgADGroupMapping, error) {
+	return scanOrgADGroupMapping(db.QueryRow(`
+		UPDATE organization_ad_groups
+		SET ad_group_name = COALESCE($3, ad_group_name),
+		    role_type = COALESCE($4, role_type),
+		    priority = COALESCE($5, priority),
+		    is_active = COALESCE($6, is_active)
+		WHERE organization_id = $1 AND id = $2
+		RETURNING `+orgADGroupColumns, orgID, mappingID, req.ADGroupName, req.RoleName, req.Priority, req.IsActive))
+}
+
+// DeleteOrganizationADGroupMapping removes a mapping. Members it granted lose access at their next sign-in.
+func DeleteOrganizationADGroupMapping(db *sql.DB, orgID, mappingID string) error {
+	result, err := db.Exec(`DELETE FROM organization_ad_groups WHERE organization_id = $1 AND id = $2`, orgID, mappingID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// rowQuerier is satisfied by *sql.DB and *sql.Tx
+type rowQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// matchADGroupMappings returns the active mappings for any of the given groups, by organization
+func matchADGroupMappings(q rowQuerier, adGroupIDs []string) (map[string][]models.OrgADGroupMapping, error) {
+	matches := make(map[string][]models.OrgADGroupMapping)
+	if len(adGroupIDs) == 0 {
+		return matches, nil
+	}
+
+	rows, err := q.Query(`
+		SELECT `+orgADGroupColumns+` FROM organization_ad_groups
+		WHERE is_active = true AND ad_group_id = ANY($1)
+		ORDER BY priority, ad_group_name`, pq.Array(adGroupIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := scanOrgADGroupMapping(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches[m.OrganizationID] = append(matches[m.OrganizationID], *m)
+	}
+
+	return matches, rows.Err()
+}
+
+// SimulateADSync reports what SyncUserOrganizationMemberships would do for a user in the given
+// groups, without writing anything. userID may be empty to see only what the groups grant.
+func SimulateADSync(db *sql.DB, userID string, adGroupIDs []string) ([]models.ADSyncOrgResult, error) {
+	type membership struct{ role, source string }
+	current := map[string]membership{}
+	orgNames := map[string]string{}
+
+	if userID != "" {
+		rows, err := db.Query(`
+			SELECT uo.organization_id, o.name, uo.role_name, uo.source
+			FROM user_organizations uo
+			JOIN organizations o ON uo.organization_id = o.id
+			WHERE uo.user_id = $1`, userID)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var orgID, orgName string
+			var m membership
+			if err := rows.Scan(&orgID, &orgName, &m.role, &m.source); err != nil {
+				return nil, err
+			}
+			current[orgID] = m
+			orgNames[orgID] = orgName
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	matches, err := matchADGroupMappings(db, adGroupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for orgID := range matches {
+		if _, ok := orgNames[orgID]; !ok {
+			missing = append(missing, orgID)
+		}
+	}
+	if len(missing) > 0 {
+		rows, err := db.Query(`SELECT id, name FROM organizations WHERE id = ANY($1)`, pq.Array(missing))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return nil, err
+			}
+			orgNames[id] = name
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	results := []models.ADSyncOrgResult{}
+	for orgID, name := range orgNames {
+		result := models.ADSyncOrgResult{
+			OrganizationID:   orgID,
+			OrganizationName: name,
+			MatchedMappings:  matches[orgID],
+		}
+		if result.MatchedMappings == nil {
+			result.MatchedMappings = []models.OrgADGroupMapping{}
+		}
+
+		role, matched := models.ResolveADGroupRole(matches[orgID])
+		m, isMember := current[orgID]
+		result.CurrentRole, result.CurrentSource = m.role, m.source
+
+		switch {
+		case isMember && m.source == models.MembershipSourceManual:
+			result.ResultingRole = m.role
+			result.Action = models.ADSyncActionPinned
+		case matched && !isMember:
+			result.ResultingRole = role
+			result.Action = models.ADSyncActionAdd
+		case matched && m.role != role:
+			result.ResultingRole = role
+			result.Action = models.ADSyncActionUpdate
+		case matched:
+			result.ResultingRole = role
+			result.Action = models.ADSyncActionKeep
+		default:
+			result.Action = models.ADSyncActionRemove
+		}
+
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].OrganizationName < results[j].OrganizationName })
+	return results, nil
+}
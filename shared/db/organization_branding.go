@@ -0,0 +1,45 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetOrganizationBranding returns an organization's branding overrides, or nil when none are stored
+func GetOrganizationBranding(db *sql.DB, orgID string) (*models.OrganizationBranding, error) {
+	var b models.OrganizationBranding
+	query := `
+		SELECT organization_id, COALESCE(logo_url, ''), COALESCE(primary_color, ''), COALESCE(secondary_color, ''),
+		       COALESCE(accent_color, ''), COALESCE(from_name, ''), created_at, updated_at
+		FROM organization_branding
+		WHERE organization_id = $1`
+
+	err := db.QueryRow(query, orgID).Scan(&b.OrganizationID, &b.LogoURL, &b.PrimaryColor, &b.SecondaryColor,
+		&b.AccentColor, &b.FromName, &b.CreatedAt, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// UpsertOrganizationBranding stores an organization's branding overrides
+func UpsertOrganizationBranding(db *sql.DB, req models.UpdateOrganizationBrandingRequest) (*models.OrganizationBranding, error) {
+	query := `
+		INSERT INTO organization_branding (organization_id, logo_url, primary_color, secondary_color, accent_color, from_name)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''))
+		ON CONFLICT (organization_id)
+		DO UPDATE SET logo_url = EXCLUDED.logo_url, primary_color = EXCLUDED.primary_color,
+		              secondary_color = EXCLUDED.secondary_color, accent_color = EXCLUDED.accent_color,
+		              from_name = EXCLUDED.from_name, updated_at = NOW()`
+
+	if _, err := db.Exec(query, req.OrganizationID, req.LogoURL, req.PrimaryColor, req.SecondaryColor,
+		req.AccentColor, req.FromName); err != nil {
+		return nil, err
+	}
+
+	return GetOrganizationBranding(db, req.OrganizationID)
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// ErrContactExists is returned when the address is already a contact of the role
+var ErrContactExists = errors.New("this address is already a contact for the role")
+
+const organizationContactColumns = `id, organization_id, role, email, name, created_at`
+
+func scanOrganizationContact(scanner interface{ Scan(...interface{}) error }) (*models.OrganizationContact, error) {
+	var contact models.OrganizationContact
+	err := scanner.Scan(&contact.ID, &contact.OrganizationID, &contact.Role, &contact.Email, &contact.Name, &contact.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// GetOrganizationContacts returns the organization's contact roster, by role
+func GetOrganizationContacts(db *sql.DB, orgID string) ([]models.OrganizationContact, error) {
+	query := `SELECT ` + organizationContactColumns + ` FROM organization_contacts WHERE organization_id = $1 ORDER BY role, email`
+
+	rows, err := db.Query(query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []models.OrganizationContact{}
+	for rows.Next() {
+		contact, err := scanOrganizationContact(rows)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, *contact)
+	}
+
+	return contacts, rows.Err()
+}
+
+// GetOrganizationContactEmails returns email -> name for the organization's contacts of a role
+func GetOrganizationContactEmails(db *sql.DB, orgID, role string) (map[string]string, error) {
+	rows, err := db.Query(`SELECT email, name FROM organization_contacts WHERE organization_id = $1 AND role = $2`, orgID, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := make(map[string]string)
+	for rows.Next() {
+		var email, name string
+		if err := rows.Scan(&email, &name); err != nil {
+			return nil, err
+		}
+		contacts[email] = name
+	}
+
+	return contacts, rows.Err()
+}
+
+// CreateOrganizationContact adds an address to the organization's roster for a role
+func CreateOrganizationContact(db *sql.DB, req models.CreateOrganizationContactRequest) (*models.OrganizationContact, error) {
+	query := `
+		INSERT INTO organization_contacts (organization_id, role, email, name)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + organizationContactColumns
+
+	contact, err := scanOrganizationContact(db.QueryRow(query, req.OrganizationID, req.Role, req.Email, req.Name))
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrContactExists
+	}
+	return contact, err
+}
+
+// GetOrganizationContact returns a contact by ID, or sql.ErrNoRows
+func GetOrganizationContact(db *sql.DB, id string) (*models.OrganizationContact, error) {
+	return scanOrganizationContact(db.QueryRow(`SELECT `+organizationContactColumns+` FROM organization_contacts WHERE id = $1`, id))
+}
+
+// DeleteOrganizationContact removes a contact from its organization's roster
+func DeleteOrganizationContact(db *sql.DB, id string) error {
+	_, err := db.Exec(`DELETE FROM organization_contacts WHERE id = $1`, id)
+	return err
+}
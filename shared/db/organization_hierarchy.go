@@ -0,0 +1,241 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// ErrOrganizationCycle is returned when an organization would be nested under itself or one of
+// its descendants
+var ErrOrganizationCycle = errors.New("an organization cannot be nested under itself or its sub-teams")
+
+// ErrQuotaExceedsParent is returned when a quota would subdivide more than the parent has
+var ErrQuotaExceedsParent = errors.New("sub-team quotas would exceed the parent organization's quota")
+
+// ErrQuotaBelowChildren is returned when a quota would be smaller than what is already
+// subdivided to its sub-teams
+var ErrQuotaBelowChildren = errors.New("quota is smaller than the total already allocated to sub-teams")
+
+// OrganizationAncestorsCTE selects the organization $1 and its ancestors as ancestors(id,
+// parent_id, depth), depth 0 being $1 itself. The depth cap guards against cycles written
+// outside SetOrganizationParent.
+const OrganizationAncestorsCTE = `
+	WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id, 0 AS depth FROM organizations WHERE id = $1
+		UNION ALL
+		SELECT o.id, o.parent_id, a.depth + 1
+		FROM organizations o
+		JOIN ancestors a ON o.id = a.parent_id
+		WHERE a.depth < 16
+	)`
+
+// organizationSubtreeCTE selects the organization $1 and all of its descendants as subtree(id)
+const organizationSubtreeCTE = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM organizations WHERE id = $1
+		UNION
+		SELECT o.id FROM organizations o JOIN subtree s ON o.parent_id = s.id
+	)`
+
+// GetOrganizationParentID returns the organization's parent, or "" for a top-level organization
+func GetOrganizationParentID(db *sql.DB, orgID string) (string, error) {
+	var parentID sql.NullString
+	err := db.QueryRow(`SELECT parent_id FROM organizations WHERE id = $1`, orgID).Scan(&parentID)
+	return parentID.String, err
+}
+
+// GetOrganizationSubtreeIDs returns the organization and all of its descendants
+func GetOrganizationSubtreeIDs(db *sql.DB, orgID string) ([]string, error) {
+	rows, err := db.Query(organizationSubtreeCTE+` SELECT id FROM subtree`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SetOrganizationParent nests the organization under parentID; "" makes it top-level. An
+// organization with its own quota must fit in what the new parent has left to allocate.
+func SetOrganizationParent(db *sql.DB, orgID, parentID string) error {
+	if parentID != "" {
+		subtree, err := GetOrganizationSubtreeIDs(db, orgID)
+		if err != nil {
+			return err
+		}
+		for _, id := range subtree {
+			if id == parentID {
+				return ErrOrganizationCycle
+			}
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if parentID != "" {
+		var totalQuota sql.NullInt64
+		err := tx.QueryRow(`SELECT total_quota FROM organization_quotas WHERE organization_id = $1`, orgID).Scan(&totalQuota)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if totalQuota.Valid {
+			if err := checkQuotaFitsParent(tx, orgID, parentID, totalQuota.Int64); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE organizations SET parent_id = NULLIF($2, '')::uuid, updated_at = NOW() WHERE id = $1`, orgID, parentID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetQuotaChain returns the quotas of the organization and its ancestors, nearest first.
+// Levels without a quota are skipped.
+func GetQuotaChain(db *sql.DB, orgID string) ([]models.OrganizationQuota, error) {
+	rows, err := db.Query(OrganizationAncestorsCTE+`
+		SELECT oq.id, oq.organization_id, oq.total_quota, oq.used_tokens, oq.reset_date, oq.created_at, oq.updated_at
+		FROM organization_quotas oq
+		JOIN ancestors a ON oq.organization_id = a.id
+		ORDER BY a.depth`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanQuotas(rows)
+}
+
+// OrganizationCanUseModel reports whether the organization may use an active model, named by
+// its ID or its provider model ID. Grants are inherited as by the gateway: the nearest level of
+// the hierarchy with a grant or a denial decides, and a denial wins at the same level.
+func OrganizationCanUseModel(db *sql.DB, orgID, model string) (bool, error) {
+	var allowed bool
+	err := db.QueryRow(OrganizationAncestorsCTE+`,
+		decisions AS (
+			SELECT moa.model_id, a.depth, true AS allowed
+			FROM model_organization_access moa
+			JOIN ancestors a ON moa.organization_id = a.id
+			UNION ALL
+			SELECT d.model_id, a.depth, false AS allowed
+			FROM model_organization_access_denials d
+			JOIN ancestors a ON d.organization_id = a.id
+		),
+		access AS (
+			SELECT DISTINCT ON (model_id) model_id, allowed
+			FROM decisions
+			ORDER BY model_id, depth, allowed
+		)
+		SELECT EXISTS (
+			SELECT 1
+			FROM models m
+			JOIN access ON m.id = access.model_id
+			WHERE access.allowed AND m.is_active = true AND (m.model_id = $2 OR m.id::text = $2)
+		)`, orgID, model).Scan(&allowed)
+	return allowed, err
+}
+
+// SetOrganizationQuota sets the organization's total quota. A sub-team's quota is carved out of
+// its parent's: the sub-teams of one parent may not be allocated more than the parent has, and a
+// parent may not drop below what it has already allocated.
+func SetOrganizationQuota(db *sql.DB, orgID string, totalQuota int) (*models.OrganizationQuota, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var parentID sql.NullString
+	if err := tx.QueryRow(`SELECT parent_id FROM organizations WHERE id = $1 FOR UPDATE`, orgID).Scan(&parentID); err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		if err := checkQuotaFitsParent(tx, orgID, parentID.String, int64(totalQuota)); err != nil {
+			return nil, err
+		}
+	}
+
+	var childrenAllocated int64
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(oq.total_quota), 0)
+		FROM organization_quotas oq
+		JOIN organizations o ON oq.organization_id = o.id
+		WHERE o.parent_id = $1`, orgID).Scan(&childrenAllocated)
+	if err != nil {
+		return nil, err
+	}
+	if childrenAllocated > int64(totalQuota) {
+		return nil, ErrQuotaBelowChildren
+	}
+
+	var quota models.OrganizationQuota
+	err = tx.QueryRow(`
+		INSERT INTO organization_quotas (organization_id, total_quota, used_tokens)
+		VALUES ($1, $2, 0)
+		ON CONFLICT (organization_id) DO UPDATE SET total_quota = EXCLUDED.total_quota, updated_at = NOW()
+		RETURNING id, organization_id, total_quota, used_tokens, reset_date, created_at, updated_at`,
+		orgID, totalQuota).Scan(
+		&quota.ID, &quota.OrganizationID, &quota.TotalQuota,
+		&quota.UsedTokens, &quota.ResetDate, &quota.CreatedAt, &quota.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &quota, tx.Commit()
+}
+
+// checkQuotaFitsParent checks the parent has room for totalQuota next to what its other sub-teams
+// are allocated. A parent without a quota of its own places no limit.
+func checkQuotaFitsParent(tx *sql.Tx, orgID, parentID string, totalQuota int64) error {
+	var parentQuota sql.NullInt64
+	var siblingsAllocated int64
+	err := tx.QueryRow(`
+		SELECT
+			(SELECT total_quota FROM organization_quotas WHERE organization_id = $1),
+			COALESCE((SELECT SUM(oq.total_quota)
+			          FROM organization_quotas oq
+			          JOIN organizations o ON oq.organization_id = o.id
+			          WHERE o.parent_id = $1 AND o.id <> $2), 0)`,
+		parentID, orgID).Scan(&parentQuota, &siblingsAllocated)
+	if err != nil {
+		return err
+	}
+	if parentQuota.Valid && siblingsAllocated+totalQuota > parentQuota.Int64 {
+		return ErrQuotaExceedsParent
+	}
+	return nil
+}
+
+func scanQuotas(rows *sql.Rows) ([]models.OrganizationQuota, error) {
+	quotas := []models.OrganizationQuota{}
+	for rows.Next() {
+		var quota models.OrganizationQuota
+		err := rows.Scan(
+			&quota.ID, &quota.OrganizationID, &quota.TotalQuota,
+			&quota.UsedTokens, &quota.ResetDate, &quota.CreatedAt, &quota.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, rows.Err()
+}
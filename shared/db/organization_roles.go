@@ -0,0 +1,179 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// ErrRoleInUse is returned when deleting a custom role that members or AD group mappings still use
+var ErrRoleInUse = errors.New("role is in use")
+
+const orgRoleColumns = `id, organization_id, name, COALESCE(description, ''), permissions, created_at, updated_at`
+
+func scanOrgRole(row interface{ Scan(...interface{}) error }) (*models.OrgRole, error) {
+	var r models.OrgRole
+	if err := row.Scan(&r.ID, &r.OrganizationID, &r.Name, &r.Description, pq.Array(&r.Permissions),
+		&r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// builtinOrgRoles returns the built-in roles, which every organization has
+func builtinOrgRoles() []models.OrgRole {
+	return []models.OrgRole{
+		{Name: models.OrgRoleAdmin, Description: "Full access, including members and roles", Permissions: models.BuiltinRolePermissions[models.OrgRoleAdmin], IsBuiltin: true},
+		{Name: models.OrgRoleMember, Description: "Standard access", Permissions: models.BuiltinRolePermissions[models.OrgRoleMember], IsBuiltin: true},
+		{Name: models.OrgRoleDeveloper, Description: "Self-service: own API keys, own usage and the API docs", Permissions: models.BuiltinRolePermissions[models.OrgRoleDeveloper], IsBuiltin: true},
+	}
+}
+
+// GetOrganizationRoles returns the built-in roles followed by the organization's custom roles
+func GetOrganizationRoles(db *sql.DB, orgID string) ([]models.OrgRole, error) {
+	rows, err := db.Query(`SELECT `+orgRoleColumns+` FROM organization_roles WHERE organization_id = $1 ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := builtinOrgRoles()
+	for rows.Next() {
+		r, err := scanOrgRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, *r)
+	}
+
+	return roles, rows.Err()
+}
+
+// GetOrganizationRole returns a custom role by ID
+func GetOrganizationRole(db *sql.DB, orgID, roleID string) (*models.OrgRole, error) {
+	return scanOrgRole(db.QueryRow(`
+		SELECT `+orgRoleColumns+` FROM organization_roles
+		WHERE organization_id = $1 AND id = $2`, orgID, roleID))
+}
+
+// OrganizationRoleExists reports whether name is a built-in role or one of the organization's custom roles
+func OrganizationRoleExists(db *sql.DB, orgID, name string) (bool, error) {
+	if models.IsBuiltinOrgRole(name) {
+		return true, nil
+	}
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM organization_roles WHERE organization_id = $1 AND name = $2)`,
+		orgID, name).Scan(&exists)
+	return exists, err
+}
+
+// CreateOrganizationRole adds a custom role to an organization
+func CreateOrganizationRole(db *sql.DB, orgID string, req models.CreateOrgRoleRequest) (*models.OrgRole, error) {
+	return scanOrgRole(db.QueryRow(`
+		INSERT INTO organization_roles (organization_id, name, description, permissions)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+orgRoleColumns, orgID, req.Name, req.Description, pq.Array(models.NormalizePermissions(req.Permissions))))
+}
+
+// UpdateOrganizationRole replaces a custom role's description and permissions
+func UpdateOrganizationRole(db *sql.DB, orgID, roleID string, req models.UpdateOrgRoleRequest) (*models.OrgRole, error) {
+	role, err := scanOrgRole(db.QueryRow(`
+		UPDATE organization_roles
+		SET description = $3, permissions = $4, updated_at = NOW()
+		WHERE organization_id = $1 AND id = $2
+		RETURNING `+orgRoleColumns, orgID, roleID, req.Description, pq.Array(models.NormalizePermissions(req.Permissions))))
+	if err != nil {
+		return nil, err
+	}
+	if err := bumpRolePermissionsVersions(db, orgID, role.Name); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// DeleteOrganizationRole removes a custom role, refusing with ErrRoleInUse while members or AD
+// group mappings use it
+func DeleteOrganizationRole(db *sql.DB, orgID, roleID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRow(`
+		SELECT name FROM organization_roles
+		WHERE organization_id = $1 AND id = $2 FOR UPDATE`, orgID, roleID).Scan(&name)
+	if err != nil {
+		return err
+	}
+
+	var inUse bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM user_organizations WHERE organization_id = $1 AND role_name = $2)
+		    OR EXISTS (SELECT 1 FROM organization_ad_groups WHERE organization_id = $1 AND role_type = $2)`,
+		orgID, name).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrRoleInUse
+	}
+
+	if _, err := tx.Exec(`DELETE FROM organization_roles WHERE id = $1`, roleID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetUserPermissions returns what a user may do within an organization: everything for System
+// Admins, the role's permissions for members, and nothing otherwise. Organizations hiding costs
+// withhold costs:read from every role but admin.
+func GetUserPermissions(db *sql.DB, userID, orgID string) ([]string, error) {
+	isAdmin, err := IsSystemAdmin(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	if isAdmin {
+		return models.Permissions, nil
+	}
+
+	if orgID == "" {
+		return nil, nil
+	}
+
+	role, err := GetUserOrganizationRole(db, userID, orgID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	permissions, ok := models.BuiltinRolePermissions[role]
+	if !ok {
+		err = db.QueryRow(`
+			SELECT permissions FROM organization_roles
+			WHERE organization_id = $1 AND name = $2`, orgID, role).Scan(pq.Array(&permissions))
+		if err == sql.ErrNoRows {
+			// The membership names a role that no longer exists
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if role != models.OrgRoleAdmin {
+		hideCosts, err := GetOrganizationHideCosts(db, orgID)
+		if err != nil {
+			return nil, err
+		}
+		if hideCosts {
+			permissions = models.WithoutPermission(permissions, models.PermissionCostsRead)
+		}
+	}
+	return permissions, nil
+}
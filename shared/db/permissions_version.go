@@ -0,0 +1,46 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// A user's permissions version goes up whenever their memberships, roles or role permissions
+// change, so signed-in sessions know to drop what they have cached about the user.
+
+// execer is satisfied by *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// GetPermissionsVersion returns the user's current permissions version
+func GetPermissionsVersion(db *sql.DB, userID string) (int64, error) {
+	var version int64
+	err := db.QueryRow(`SELECT permissions_version FROM users WHERE id = $1`, userID).Scan(&version)
+	return version, err
+}
+
+// bumpPermissionsVersion invalidates the user's cached permissions
+func bumpPermissionsVersion(e execer, userID string) error {
+	_, err := e.Exec(`UPDATE users SET permissions_version = permissions_version + 1 WHERE id = $1`, userID)
+	return err
+}
+
+// bumpRolePermissionsVersions invalidates the cached permissions of every member holding the
+// role in the organization
+func bumpRolePermissionsVersions(e execer, orgID, roleName string) error {
+	_, err := e.Exec(`
+		UPDATE users SET permissions_version = permissions_version + 1
+		WHERE id IN (
+			SELECT user_id FROM user_organizations WHERE organization_id = $1 AND role_name = $2
+		)`, orgID, roleName)
+	return err
+}
+
+// bumpOrganizationPermissionsVersions invalidates the cached permissions of every member of the
+// organization
+func bumpOrganizationPermissionsVersions(e execer, orgID string) error {
+	_, err := e.Exec(`
+		UPDATE users SET permissions_version = permissions_version + 1
+		WHERE id IN (SELECT user_id FROM user_organizations WHERE organization_id = $1)`, orgID)
+	return err
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Project operations
+
+const projectColumns = `id, organization_id, name, description, token_quota, used_tokens, is_active, created_by, created_at, updated_at,
+	(SELECT COUNT(*) FROM api_keys ak WHERE ak.project_id = projects.id AND ak.is_active = true) AS api_key_count`
+
+func scanProject(row interface{ Scan(...interface{}) error }) (*models.Project, error) {
+	var p models.Project
+	err := row.Scan(
+		&p.ID, &p.OrganizationID, &p.Name, &p.Description, &p.TokenQuota, &p.UsedTokens,
+		&p.IsActive, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt, &p.APIKeyCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateProject creates a project in the request's organization
+func CreateProject(db *sql.DB, req models.CreateProjectRequest, createdBy string) (*models.Project, error) {
+	query := `
+		INSERT INTO projects (organization_id, name, description, token_quota, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + projectColumns
+
+	return scanProject(db.QueryRow(query, req.OrganizationID, req.Name, req.Description, req.TokenQuota, createdBy))
+}
+
+// GetProjectsByOrganization returns the active projects of the given organizations
+func GetProjectsByOrganization(db *sql.DB, orgIDs []string) ([]models.Project, error) {
+	if len(orgIDs) == 0 {
+		return []models.Project{}, nil
+	}
+
+	placeholders := make([]string, len(orgIDs))
+	args := make([]interface{}, len(orgIDs))
+	for i, id := range orgIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM projects WHERE is_active = true AND organization_id IN (%s) ORDER BY name`,
+		projectColumns, strings.Join(placeholders, ", "))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, *p)
+	}
+	return projects, rows.Err()
+}
+
+// GetProjectByID returns an active project
+func GetProjectByID(db *sql.DB, projectID string) (*models.Project, error) {
+	return scanProject(db.QueryRow(`SELECT `+projectColumns+` FROM projects WHERE id = $1 AND is_active = true`, projectID))
+}
+
+// UpdateProject changes the fields given in the request
+func UpdateProject(db *sql.DB, projectID string, req models.UpdateProjectRequest) (*models.Project, error) {
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *req.Name)
+		argIndex++
+	}
+	if req.Description != nil {
+		setParts = append(setParts, fmt.Sprintf("description = NULLIF($%d, '')", argIndex))
+		args = append(args, *req.Description)
+		argIndex++
+	}
+	if req.TokenQuota != nil {
+		setParts = append(setParts, fmt.Sprintf("token_quota = NULLIF($%d::bigint, 0)", argIndex))
+		args = append(args, *req.TokenQuota)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated_at = NOW()")
+	args = append(args, projectID)
+
+	query := fmt.Sprintf(`UPDATE projects SET %s WHERE id = $%d AND is_active = true RETURNING %s`,
+		strings.Join(setParts, ", "), argIndex, projectColumns)
+
+	return scanProject(db.QueryRow(query, args...))
+}
+
+// DeleteProject deactivates a project; its keys stay active and move back to the organization
+func DeleteProject(db *sql.DB, projectID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE api_keys SET project_id = NULL, updated_at = NOW() WHERE project_id = $1`, projectID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE projects SET is_active = false, updated_at = NOW() WHERE id = $1`, projectID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AssignAPIKeyProject moves a key into projectID, or out of any project when it is "". The
+// caller checks the project belongs to the key's organization.
+func AssignAPIKeyProject(db *sql.DB, keyID, projectID string) error {
+	result, err := db.Exec(`
+		UPDATE api_keys SET project_id = NULLIF($2, '')::uuid, updated_at = NOW()
+		WHERE id = $1 AND is_active = true`, keyID, projectID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAPIKeyProject returns the active project the key belongs to, or sql.ErrNoRows when it has none
+func GetAPIKeyProject(db *sql.DB, apiKeyID string) (*models.Project, error) {
+	return scanProject(db.QueryRow(`SELECT `+projectColumns+` FROM projects
+		WHERE is_active = true AND id = (SELECT project_id FROM api_keys WHERE id = $1)`, apiKeyID))
+}
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetOrganizationQoSClass returns the QoS class the organization's requests are scheduled with
+func GetOrganizationQoSClass(db *sql.DB, orgID string) (string, error) {
+	var class string
+	err := db.QueryRow(`SELECT qos_class FROM organizations WHERE id = $1`, orgID).Scan(&class)
+	if err == sql.ErrNoRows {
+		return models.DefaultQoSClass, nil
+	}
+	return class, err
+}
+
+// UpdateOrganizationQoSClass sets the organization's QoS class, returning sql.ErrNoRows if it
+// doesn't exist
+func UpdateOrganizationQoSClass(db *sql.DB, orgID, class string) error {
+	result, err := db.Exec(`UPDATE organizations SET qos_class = $2, updated_at = NOW() WHERE id = $1`, orgID, class)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Quota accounting
+//
+// RecordUsage writes the usage log and every counter it feeds in one transaction, and each
+// counter is a single atomic increment, so any number of workers and gateway replicas can record
+// usage concurrently without lost updates, and a failed write can be retried without counting
+// twice. Quota periods are monthly and end at reset_date; the first write after that, or the
+// next reconcile, moves reset_date on to start the next period. ReconcileQuotaUsage recomputes the counters from usage_logs to repair drift from writes
+// made outside RecordUsage (manual edits, restored backups, keys moved between projects).
+
+// nextResetDate is the end of the monthly quota period that contains NOW(), for a quota whose
+// reset_date has passed: reset_date moved on by whole months until it is in the future again
+const nextResetDate = `reset_date + INTERVAL '1 month' *
+	(EXTRACT(YEAR FROM AGE(NOW(), reset_date)) * 12 + EXTRACT(MONTH FROM AGE(NOW(), reset_date)) + 1)`
+
+// quotaReconcileLockKey keeps replicas from reconciling at the same time
+const quotaReconcileLockKey = 7263810453
+
+// RecordUsage logs the request's usage and adds its tokens to the organization's quota, the
+// quotas of its ancestors (a sub-team's usage also counts against its parents) and the key's
+// project. It returns the updated organization quotas; levels without a quota are skipped.
+func RecordUsage(db *sql.DB, req CreateUsageLogRequest) ([]models.OrganizationQuota, error) {
+	metadataJSON, err := json.Marshal(req.Metadata)
+	if err != nil {
+		metadataJSON = []byte("{}")
+	}
+
+	createdAt := req.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO usage_logs (
+			organization_id, api_key_id, model_id, endpoint,
+			prompt_tokens, completion_tokens, total_tokens,
+			request_id, response_status, response_time_ms, cost_usd, metadata, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		req.OrganizationID, req.APIKeyID, req.ModelID, req.Endpoint,
+		req.PromptTokens, req.CompletionTokens, req.TotalTokens,
+		req.RequestID, req.ResponseStatus, req.ResponseTimeMS, req.CostUSD, metadataJSON, createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock the chain's quotas in id order, as ReconcileQuotaUsage does, so the two can't deadlock
+	if _, err := tx.Exec(OrganizationAncestorsCTE+`
+		SELECT oq.id FROM organization_quotas oq
+		JOIN ancestors a ON oq.organization_id = a.id
+		ORDER BY oq.id
+		FOR UPDATE OF oq`, req.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	// A quota whose period has ended starts the next one. Usage from before the quota's current
+	// period, such as usage replayed from the read-only spool, is logged but not counted.
+	rows, err := tx.Query(OrganizationAncestorsCTE+`,
+		periods AS (
+			SELECT oq.id, oq.reset_date <= NOW() AS rolled,
+				CASE WHEN oq.reset_date <= NOW() THEN `+nextResetDate+` ELSE oq.reset_date END AS reset_date
+			FROM organization_quotas oq
+			JOIN ancestors a ON oq.organization_id = a.id
+		)
+		UPDATE organization_quotas oq
+		SET used_tokens = CASE WHEN p.rolled THEN 0 ELSE oq.used_tokens END
+				+ CASE WHEN p.reset_date IS NULL OR $3 >= p.reset_date - INTERVAL '1 month' THEN $2 ELSE 0 END,
+			reset_date = p.reset_date,
+			updated_at = NOW()
+		FROM periods p
+		WHERE oq.id = p.id
+		RETURNING oq.id, oq.organization_id, oq.total_quota, oq.used_tokens, oq.reset_date, oq.created_at, oq.updated_at`,
+		req.OrganizationID, req.TotalTokens, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	quotas, err := scanQuotas(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE projects p
+		SET used_tokens = p.used_tokens + $2, updated_at = NOW()
+		FROM api_keys ak
+		WHERE ak.id = $1 AND p.id = ak.project_id`, req.APIKeyID, req.TotalTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return quotas, tx.Commit()
+}
+
+// ReconcileQuotaUsage resets every organization quota and project counter to the tokens in
+// usage_logs: an organization counts its own and its sub-teams' logs since its current monthly
+// quota period began (a month before reset_date, when it ends), a project the logs of its keys
+// since it was created. Quotas whose period has ended are rolled over first. It returns how many
+// counters were corrected, and skips the run (returning 0) while another replica is reconciling.
+//
+// Counters are locked before they are summed, so usage recorded concurrently is either counted
+// in the sum or added on top of it afterwards, never lost.
+func ReconcileQuotaUsage(db *sql.DB) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRow(`SELECT pg_try_advisory_xact_lock($1)`, quotaReconcileLockKey).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	// Lock in the order RecordUsage does, quotas then projects and each by id, so the two can't
+	// deadlock
+	if _, err := tx.Exec(`SELECT id FROM organization_quotas ORDER BY id FOR UPDATE`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`SELECT id FROM projects ORDER BY id FOR UPDATE`); err != nil {
+		return 0, err
+	}
+
+	// Quotas whose period ended without usage to roll them over start the next one here
+	if _, err := tx.Exec(`
+		UPDATE organization_quotas
+		SET reset_date = ` + nextResetDate + `, updated_at = NOW()
+		WHERE reset_date <= NOW()`); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+		WITH RECURSIVE tree AS (
+			SELECT id AS root_id, id FROM organizations
+			UNION
+			SELECT t.root_id, o.id FROM organizations o JOIN tree t ON o.parent_id = t.id
+		),
+		totals AS (
+			SELECT oq.id, COALESCE(SUM(ul.total_tokens), 0) AS used
+			FROM organization_quotas oq
+			JOIN tree t ON t.root_id = oq.organization_id
+			LEFT JOIN usage_logs ul ON ul.organization_id = t.id
+				AND ul.created_at >= GREATEST(oq.created_at, oq.reset_date - INTERVAL '1 month')
+			GROUP BY oq.id
+		)
+		UPDATE organization_quotas oq
+		SET used_tokens = totals.used, updated_at = NOW()
+		FROM totals
+		WHERE oq.id = totals.id AND oq.used_tokens <> totals.used`)
+	if err != nil {
+		return 0, err
+	}
+	corrected, _ := result.RowsAffected()
+
+	result, err = tx.Exec(`
+		WITH totals AS (
+			SELECT p.id, COALESCE(SUM(ul.total_tokens), 0) AS used
+			FROM projects p
+			LEFT JOIN api_keys ak ON ak.project_id = p.id
+			LEFT JOIN usage_logs ul ON ul.api_key_id = ak.id AND ul.created_at >= p.created_at
+			GROUP BY p.id
+		)
+		UPDATE projects p
+		SET used_tokens = totals.used, updated_at = NOW()
+		FROM totals
+		WHERE p.id = totals.id AND p.used_tokens <> totals.used`)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+
+	return corrected + n, tx.Commit()
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetQuotaNotificationSettings returns an organization's quota notification settings,
+// falling back to the defaults (enabled, 80/90/100%, org admins) when none are stored
+func GetQuotaNotificationSettings(db *sql.DB, orgID string) (*models.QuotaNotificationSettings, error) {
+	settings := models.QuotaNotificationSettings{
+		OrganizationID: orgID,
+		IsEnabled:      true,
+		Thresholds:     models.DefaultQuotaNotificationThresholds,
+		Recipients:     []string{},
+	}
+
+	var thresholds, recipients []byte
+	query := `
+		SELECT is_enabled, thresholds, recipients, created_at, updated_at
+		FROM quota_notification_settings
+		WHERE organization_id = $1`
+
+	err := db.QueryRow(query, orgID).Scan(&settings.IsEnabled, &thresholds, &recipients, &settings.CreatedAt, &settings.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &settings, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(thresholds) > 0 {
+		if err := json.Unmarshal(thresholds, &settings.Thresholds); err != nil {
+			return nil, err
+		}
+	}
+	if len(recipients) > 0 {
+		if err := json.Unmarshal(recipients, &settings.Recipients); err != nil {
+			return nil, err
+		}
+	}
+
+	return &settings, nil
+}
+
+// UpsertQuotaNotificationSettings stores an organization's quota notification settings
+func UpsertQuotaNotificationSettings(db *sql.DB, req models.UpdateQuotaNotificationSettingsRequest) (*models.QuotaNotificationSettings, error) {
+	sort.Ints(req.Thresholds)
+	if req.Recipients == nil {
+		req.Recipients = []string{}
+	}
+
+	thresholds, err := json.Marshal(req.Thresholds)
+	if err != nil {
+		return nil, err
+	}
+	recipients, err := json.Marshal(req.Recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO quota_notification_settings (organization_id, is_enabled, thresholds, recipients)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id)
+		DO UPDATE SET is_enabled = EXCLUDED.is_enabled, thresholds = EXCLUDED.thresholds,
+		              recipients = EXCLUDED.recipients, updated_at = NOW()`
+
+	if _, err := db.Exec(query, req.OrganizationID, req.IsEnabled, thresholds, recipients); err != nil {
+		return nil, err
+	}
+
+	return GetQuotaNotificationSettings(db, req.OrganizationID)
+}
+
+// RecordQuotaNotifications marks thresholds as notified for the current quota period and
+// returns the ones that were not already recorded
+func RecordQuotaNotifications(db *sql.DB, orgID string, thresholds []int, periodResetDate time.Time) (map[int]bool, error) {
+	recorded := make(map[int]bool)
+	for _, threshold := range thresholds {
+		var inserted int
+		err := db.QueryRow(`
+			INSERT INTO quota_notifications (organization_id, threshold, reset_date)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (organization_id, threshold, reset_date) DO NOTHING
+			RETURNING threshold`, orgID, threshold, periodResetDate).Scan(&inserted)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return recorded, err
+		}
+		recorded[inserted] = true
+	}
+	return recorded, nil
+}
+
+// GetTopAPIKeysByUsage returns the organization's highest-consuming API keys over the last `days` days
+func GetTopAPIKeysByUsage(db *sql.DB, orgID string, days, limit int) ([]models.APIKeyUsageSummary, error) {
+	query := `
+		SELECT ak.name, COALESCE(SUM(ul.total_tokens), 0) AS tokens
+		FROM usage_logs ul
+		JOIN api_keys ak ON ul.api_key_id = ak.id
+		WHERE ul.organization_id = $1
+		AND ul.created_at >= NOW() - make_interval(days => $2)
+		GROUP BY ak.id, ak.name
+		ORDER BY tokens DESC
+		LIMIT $3`
+
+	rows, err := db.Query(query, orgID, days, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKeyUsageSummary{}
+	for rows.Next() {
+		var name string
+		var tokens int64
+		if err := rows.Scan(&name, &tokens); err != nil {
+			return nil, err
+		}
+		keys = append(keys, models.APIKeyUsageSummary{Name: name, Tokens: models.FormatTokenCount(tokens)})
+	}
+
+	return keys, rows.Err()
+}
+
+// GetOrganizationAdminEmails returns email -> name for the active admins of an organization
+func GetOrganizationAdminEmails(db *sql.DB, orgID string) (map[string]string, error) {
+	rows, err := db.Query(`
+		SELECT u.email, u.name
+		FROM user_organizations uo
+		JOIN users u ON uo.user_id = u.id
+		WHERE uo.organization_id = $1 AND uo.role_name = 'admin' AND u.is_active = true`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	admins := make(map[string]string)
+	for rows.Next() {
+		var email, name string
+		if err := rows.Scan(&email, &name); err != nil {
+			return nil, err
+		}
+		admins[email] = name
+	}
+
+	return admins, rows.Err()
+}
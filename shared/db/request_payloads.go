@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Request payload logging and replay operations
+
+// CreateRequestPayload stores a logged request and its response
+func CreateRequestPayload(db *sql.DB, payload models.RequestPayload) error {
+	query := `
+		INSERT INTO request_payloads (organization_id, api_key_id, model_id, endpoint, request_body,
+		                              response_status, response_body, response_time_ms, truncated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := db.Exec(query, payload.OrganizationID, payload.APIKeyID, payload.ModelID, payload.Endpoint, payload.RequestBody,
+		payload.ResponseStatus, payload.ResponseBody, payload.ResponseTimeMS, payload.Truncated)
+	return err
+}
+
+// GetRequestPayloads lists an organization's most recent logged requests without their bodies
+func GetRequestPayloads(db *sql.DB, orgID string, limit int) ([]models.RequestPayload, error) {
+	query := `
+		SELECT p.id, p.organization_id, p.api_key_id, ak.name, p.model_id, m.name, p.endpoint,
+		       p.response_status, p.response_time_ms, p.truncated, p.created_at
+		FROM request_payloads p
+		LEFT JOIN api_keys ak ON p.api_key_id = ak.id
+		LEFT JOIN models m ON p.model_id = m.id
+		WHERE p.organization_id = $1
+		ORDER BY p.created_at DESC
+		LIMIT $2`
+
+	rows, err := db.Query(query, orgID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payloads := []models.RequestPayload{}
+	for rows.Next() {
+		var p models.RequestPayload
+		if err := rows.Scan(&p.ID, &p.OrganizationID, &p.APIKeyID, &p.APIKeyName, &p.ModelID, &p.ModelName, &p.Endpoint,
+			&p.ResponseStatus, &p.ResponseTimeMS, &p.Truncated, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, p)
+	}
+	return payloads, rows.Err()
+}
+
+// GetRequestPayload returns a logged request with its bodies
+func GetRequestPayload(db *sql.DB, id string) (*models.RequestPayload, error) {
+	query := `
+		SELECT p.id, p.organization_id, p.api_key_id, ak.name, p.model_id, m.name, p.endpoint, p.request_body,
+		       p.response_status, p.response_body, p.response_time_ms, p.truncated, p.created_at
+		FROM request_payloads p
+		LEFT JOIN api_keys ak ON p.api_key_id = ak.id
+		LEFT JOIN models m ON p.model_id = m.id
+		WHERE p.id = $1`
+
+	var p models.RequestPayload
+	err := db.QueryRow(query, id).Scan(&p.ID, &p.OrganizationID, &p.APIKeyID, &p.APIKeyName, &p.ModelID, &p.ModelName, &p.Endpoint,
+		&p.RequestBody, &p.ResponseStatus, &p.ResponseBody, &p.ResponseTimeMS, &p.Truncated, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateRequestReplay records a sandbox replay. Replays are kept apart from usage_logs so they
+// never reach analytics, quotas or billing.
+func CreateRequestReplay(db *sql.DB, replay *models.RequestReplay) error {
+	query := `
+		INSERT INTO request_replays (payload_id, organization_id, model_id, request_body, response_status,
+		                             response_body, response_time_ms, error, replayed_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+
+	return db.QueryRow(query, replay.PayloadID, replay.OrganizationID, replay.ModelID, replay.RequestBody, replay.ResponseStatus,
+		replay.ResponseBody, replay.ResponseTimeMS, replay.Error, replay.ReplayedBy).Scan(&replay.ID, &replay.CreatedAt)
+}
+
+// GetRequestReplays lists the replays of a logged request, newest first
+func GetRequestReplays(db *sql.DB, payloadID string) ([]models.RequestReplay, error) {
+	query := `
+		SELECT r.id, r.payload_id, r.organization_id, r.model_id, m.name, r.request_body, r.response_status,
+		       r.response_body, r.response_time_ms, r.error, r.replayed_by, r.created_at
+		FROM request_replays r
+		LEFT JOIN models m ON r.model_id = m.id
+		WHERE r.payload_id = $1
+		ORDER BY r.created_at DESC`
+
+	rows, err := db.Query(query, payloadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replays := []models.RequestReplay{}
+	for rows.Next() {
+		var r models.RequestReplay
+		if err := rows.Scan(&r.ID, &r.PayloadID, &r.OrganizationID, &r.ModelID, &r.ModelName, &r.RequestBody, &r.ResponseStatus,
+			&r.ResponseBody, &r.ResponseTimeMS, &r.Error, &r.ReplayedBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		replays = append(replays, r)
+	}
+	return replays, rows.Err()
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Response schema operations
+
+const responseSchemaColumns = `id, organization_id, name, endpoint, schema, retry_on_failure, max_retries, is_active, created_at, updated_at`
+
+func scanResponseSchema(row interface{ Scan(...interface{}) error }) (*models.ResponseSchema, error) {
+	var s models.ResponseSchema
+	var schema []byte
+	err := row.Scan(
+		&s.ID, &s.OrganizationID, &s.Name, &s.Endpoint, &schema,
+		&s.RetryOnFailure, &s.MaxRetries, &s.IsActive, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = schema
+	return &s, nil
+}
+
+// CreateResponseSchema stores a response schema for an organization's endpoint
+func CreateResponseSchema(db *sql.DB, req models.CreateResponseSchemaRequest) (*models.ResponseSchema, error) {
+	maxRetries := 1
+	if req.MaxRetries != nil {
+		maxRetries = *req.MaxRetries
+	}
+
+	query := `
+		INSERT INTO response_schemas (organization_id, name, endpoint, schema, retry_on_failure, max_retries)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + responseSchemaColumns
+
+	return scanResponseSchema(db.QueryRow(query,
+		req.OrganizationID, req.Name, req.Endpoint, []byte(req.Schema), req.RetryOnFailure, maxRetries,
+	))
+}
+
+// GetResponseSchemasByOrganization returns the response schemas of the given organizations
+func GetResponseSchemasByOrganization(db *sql.DB, orgIDs []string) ([]models.ResponseSchema, error) {
+	if len(orgIDs) == 0 {
+		return []models.ResponseSchema{}, nil
+	}
+
+	placeholders := make([]string, len(orgIDs))
+	args := make([]interface{}, len(orgIDs))
+	for i, id := range orgIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM response_schemas WHERE organization_id IN (%s) ORDER BY endpoint, created_at DESC`,
+		responseSchemaColumns, strings.Join(placeholders, ", "))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemas := []models.ResponseSchema{}
+	for rows.Next() {
+		s, err := scanResponseSchema(rows)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, *s)
+	}
+
+	return schemas, rows.Err()
+}
+
+func GetResponseSchemaByID(db *sql.DB, schemaID string) (*models.ResponseSchema, error) {
+	query := `SELECT ` + responseSchemaColumns + ` FROM response_schemas WHERE id = $1`
+	return scanResponseSchema(db.QueryRow(query, schemaID))
+}
+
+// GetActiveResponseSchemaForEndpoint returns the active schema for an organization's endpoint.
+// Returns sql.ErrNoRows if there is none.
+func GetActiveResponseSchemaForEndpoint(db *sql.DB, orgID, endpoint string) (*models.ResponseSchema, error) {
+	query := `SELECT ` + responseSchemaColumns + `
+		FROM response_schemas
+		WHERE organization_id = $1 AND endpoint = $2 AND is_active = true
+		LIMIT 1`
+	return scanResponseSchema(db.QueryRow(query, orgID, endpoint))
+}
+
+func UpdateResponseSchema(db *sql.DB, schemaID string, req models.UpdateResponseSchemaRequest) (*models.ResponseSchema, error) {
+	setParts := []string{}
+	args := []interface{}{}
+	argIndex := 1
+
+	if req.Name != nil {
+		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
+		args = append(args, *req.Name)
+		argIndex++
+	}
+	if req.Schema != nil {
+		setParts = append(setParts, fmt.Sprintf("schema = $%d", argIndex))
+		args = append(args, []byte(*req.Schema))
+		argIndex++
+	}
+	if req.RetryOnFailure != nil {
+		setParts = append(setParts, fmt.Sprintf("retry_on_failure = $%d", argIndex))
+		args = append(args, *req.RetryOnFailure)
+		argIndex++
+	}
+	if req.MaxRetries != nil {
+		setParts = append(setParts, fmt.Sprintf("max_retries = $%d", argIndex))
+		args = append(args, *req.MaxRetries)
+		argIndex++
+	}
+	if req.IsActive != nil {
+		setParts = append(setParts, fmt.Sprintf("is_active = $%d", argIndex))
+		args = append(args, *req.IsActive)
+		argIndex++
+	}
+
+	if len(setParts) == 0 {
+		return nil, fmt.Errorf("no fields to update")
+	}
+
+	setParts = append(setParts, "updated_at = NOW()")
+	args = append(args, schemaID)
+
+	query := fmt.Sprintf(`UPDATE response_schemas SET %s WHERE id = $%d RETURNING %s`,
+		strings.Join(setParts, ", "), argIndex, responseSchemaColumns)
+
+	return scanResponseSchema(db.QueryRow(query, args...))
+}
+
+func DeleteResponseSchema(db *sql.DB, schemaID string) error {
+	_, err := db.Exec("DELETE FROM response_schemas WHERE id = $1", schemaID)
+	return err
+}
+
+// GetSchemaValidationStats aggregates the schema_validation usage metadata by endpoint and model
+func GetSchemaValidationStats(db *sql.DB, filter models.AnalyticsFilter) ([]models.SchemaValidationStats, error) {
+	startTime, err := parseTimeRange(filter.TimeRange, filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			ul.endpoint,
+			m.model_id,
+			COUNT(*) AS validated_count,
+			COUNT(*) FILTER (WHERE (ul.metadata->'schema_validation'->>'valid')::boolean = false) AS failed_count,
+			COUNT(*) FILTER (WHERE (ul.metadata->'schema_validation'->>'attempts')::int > 1) AS retried_count
+		FROM usage_logs ul
+		JOIN models m ON ul.model_id = m.id
+		WHERE ul.created_at >= $1
+		  AND ($2 = '' OR ul.organization_id = $2::uuid)
+		  AND ul.metadata ? 'schema_validation'
+		  AND COALESCE((ul.metadata->'schema_validation'->>'skipped')::boolean, false) = false
+		GROUP BY ul.endpoint, m.model_id
+		ORDER BY failed_count DESC, validated_count DESC`
+
+	rows, err := db.Query(query, startTime, filter.Organization)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []models.SchemaValidationStats{}
+	for rows.Next() {
+		var s models.SchemaValidationStats
+		if err := rows.Scan(&s.Endpoint, &s.ModelID, &s.ValidatedCount, &s.FailedCount, &s.RetriedCount); err != nil {
+			return nil, err
+		}
+		if s.ValidatedCount > 0 {
+			s.FailureRate = float64(s.FailedCount) / float64(s.ValidatedCount) * 100
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
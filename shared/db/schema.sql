@@ -7,7 +7,9 @@ CREATE TABLE IF NOT EXISTS users (
     email VARCHAR(255) UNIQUE NOT NULL,
     name VARCHAR(255) NOT NULL,
     is_active BOOLEAN DEFAULT true,
+    locale VARCHAR(10), -- Preferred UI language; falls back to the organization's
     last_login TIMESTAMP WITH TIME ZONE,
+    permissions_version BIGINT NOT NULL DEFAULT 0, -- Bumped when memberships or roles change; sessions reload on a new value
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
@@ -31,6 +33,12 @@ CREATE TABLE IF NOT EXISTS organizations (
     ad_admin_group_name VARCHAR(255),
     ad_member_group_id VARCHAR(255), -- AD group for org members
     ad_member_group_name VARCHAR(255),
+    locale VARCHAR(10), -- Default language for members and notification emails
+    parent_id UUID REFERENCES organizations(id) ON DELETE SET NULL, -- Parent in an organization hierarchy
+    hide_costs BOOLEAN NOT NULL DEFAULT false, -- Show members tokens only: no model prices or spend
+    qos_class VARCHAR(10) NOT NULL DEFAULT 'silver' CHECK (qos_class IN ('gold', 'silver', 'bronze')), -- Scheduling priority when the gateway is saturated
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD', -- Display currency of reports; costs are stored in USD
+    max_request_cost DECIMAL(12,6) CHECK (max_request_cost > 0), -- Ceiling on a request's worst-case cost in USD; NULL for none
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
@@ -40,7 +48,8 @@ CREATE TABLE IF NOT EXISTS user_organizations (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
-    role_name VARCHAR(50) NOT NULL, -- Direct role name: 'admin' or 'member'
+    role_name VARCHAR(50) NOT NULL, -- 'admin', 'member' or a custom organization_roles.name
+    source VARCHAR(20) NOT NULL DEFAULT 'ad_sync', -- 'ad_sync' (managed by AD group sync) or 'manual' (pinned by an admin)
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     created_by UUID REFERENCES users(id),
     UNIQUE(user_id, organization_id)
@@ -62,7 +71,8 @@ CREATE TABLE IF NOT EXISTS organization_ad_groups (
     organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
     ad_group_id VARCHAR(255) NOT NULL,
     ad_group_name VARCHAR(255),
-    role_type VARCHAR(50) NOT NULL, -- 'admin' or 'member'
+    role_type VARCHAR(50) NOT NULL, -- 'admin', 'member' or a custom organization_roles.name
+    priority INTEGER NOT NULL DEFAULT 100, -- Lowest wins when a user is in several mapped groups
     is_active BOOLEAN DEFAULT true,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     UNIQUE(organization_id, ad_group_id, role_type)
@@ -78,6 +88,21 @@ CREATE TABLE IF NOT EXISTS system_ad_groups (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Projects group an organization's API keys, with their own optional token quota and
+-- analytics, the way OpenAI projects sit between an organization and its keys
+CREATE TABLE IF NOT EXISTS projects (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    token_quota BIGINT, -- NULL means the project only draws on its organization's quota
+    used_tokens BIGINT NOT NULL DEFAULT 0,
+    is_active BOOLEAN DEFAULT true,
+    created_by UUID REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 -- API Keys table (using raw API keys)
 CREATE TABLE IF NOT EXISTS api_keys (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -87,6 +112,13 @@ CREATE TABLE IF NOT EXISTS api_keys (
     is_active BOOLEAN DEFAULT true,
     last_used TIMESTAMP WITH TIME ZONE,
     created_by_user_id UUID REFERENCES users(id), -- Link API keys to users
+    expires_at TIMESTAMP WITH TIME ZONE, -- NULL means the key never expires
+    description TEXT,
+    owner_user_id UUID REFERENCES users(id), -- Responsible user; defaults to the creator and can be transferred
+    scopes TEXT[] NOT NULL DEFAULT '{}', -- Gateway endpoint families the key may call; empty allows all
+    project_id UUID REFERENCES projects(id) ON DELETE SET NULL, -- NULL for keys outside any project
+    is_canary BOOLEAN NOT NULL DEFAULT false, -- Never handed out; any use raises an alert
+    canary_block_ip BOOLEAN NOT NULL DEFAULT false, -- Block the caller of a canary key
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
@@ -99,6 +131,12 @@ CREATE TABLE IF NOT EXISTS models (
     provider VARCHAR(100) NOT NULL,
     api_endpoint VARCHAR(500),
     api_token VARCHAR(500),
+    aws_region VARCHAR(50), -- Bedrock: requests are signed with SigV4 instead of api_token
+    aws_access_key_id VARCHAR(128), -- Empty means the gateway's AWS_* environment credentials
+    aws_secret_access_key TEXT, -- Encrypted with ENCRYPTION_KEY
+    aws_role_arn VARCHAR(255), -- Assumed through STS when set
+    gcp_location VARCHAR(50), -- Vertex AI: region such as us-central1, or global
+    gcp_service_account TEXT, -- Service account key JSON, encrypted with ENCRYPTION_KEY
     description TEXT,
     input_cost_per_1m DECIMAL(10,6) DEFAULT 0.0,
     output_cost_per_1m DECIMAL(10,6) DEFAULT 0.0,
@@ -106,11 +144,120 @@ CREATE TABLE IF NOT EXISTS models (
     timeout_seconds INTEGER DEFAULT 30 CHECK (timeout_seconds >= 5 AND timeout_seconds <= 300),
     retry_delay_ms INTEGER DEFAULT 1000 CHECK (retry_delay_ms >= 100 AND retry_delay_ms <= 10000),
     backoff_multiplier REAL DEFAULT 2.0 CHECK (backoff_multiplier >= 1.0 AND backoff_multiplier <= 5.0),
+    max_request_bytes INTEGER CHECK (max_request_bytes > 0), -- Largest accepted request body; NULL uses the gateway limit
+    metadata JSONB NOT NULL DEFAULT '{}', -- {"context_window": 128000, "supports_tools": true, "supports_vision": false, "region": "...", "tags": [...]}
+    transport JSONB NOT NULL DEFAULT '{}', -- {"proxy_url": "...", "ca_bundle": "-----BEGIN CERTIFICATE-----...", "tls_min_version": "1.2"}
     is_active BOOLEAN DEFAULT true,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Model prices with effective date ranges and time-of-day multipliers
+CREATE TABLE IF NOT EXISTS model_prices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    model_id UUID NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    input_cost_per_1m DECIMAL(10,6) NOT NULL DEFAULT 0.0,
+    output_cost_per_1m DECIMAL(10,6) NOT NULL DEFAULT 0.0,
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    effective_to TIMESTAMP WITH TIME ZONE, -- NULL until a later price replaces it
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC', -- Time zone of the windows
+    windows JSONB NOT NULL DEFAULT '[]', -- [{"start": "09:00", "end": "17:00", "multiplier": 1.5}]
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_model_prices_model ON model_prices(model_id, effective_from DESC);
+
+-- Latest exchange rates for converting USD costs in reports
+CREATE TABLE IF NOT EXISTS fx_rates (
+    currency VARCHAR(3) PRIMARY KEY,
+    rate_per_usd DOUBLE PRECISION NOT NULL CHECK (rate_per_usd > 0), -- Units of the currency one US dollar buys
+    source VARCHAR(255) NOT NULL, -- Host the rate was fetched from, or 'manual'
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- WebAssembly policy modules organizations run on their requests and responses
+CREATE TABLE IF NOT EXISTS wasm_policies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    module BYTEA NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    fuel_limit BIGINT CHECK (fuel_limit > 0), -- Function calls per call; NULL uses the gateway default
+    max_memory_pages INTEGER CHECK (max_memory_pages > 0), -- 64 KiB pages; NULL uses the gateway default
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_wasm_policies_org ON wasm_policies(organization_id, created_at);
+
+-- Authorization decisions asked of OPA, kept for compliance. IDs aren't foreign keys so the
+-- record outlives the keys and organizations it mentions.
+CREATE TABLE IF NOT EXISTS authz_decisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    request_id VARCHAR(255),
+    organization_id UUID,
+    api_key_id UUID,
+    model VARCHAR(255),
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    payload_bytes INTEGER NOT NULL DEFAULT 0,
+    allowed BOOLEAN NOT NULL,
+    reason TEXT,
+    status INTEGER, -- Status the request was refused with
+    policy VARCHAR(255) NOT NULL, -- Decision path asked, e.g. relai/authz
+    opa_decision_id VARCHAR(255),
+    error TEXT, -- Why OPA couldn't decide
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    client_ip VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_authz_decisions_created ON authz_decisions(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_authz_decisions_org ON authz_decisions(organization_id, created_at DESC);
+
+-- Organizations and models declarative config manages, by the name they're declared with
+CREATE TABLE IF NOT EXISTS declared_resources (
+    kind VARCHAR(20) NOT NULL, -- organization or model
+    name VARCHAR(255) NOT NULL,
+    resource_id UUID NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (kind, name)
+);
+
+-- Availability and latency objectives for models and endpoints, measured from usage_logs
+CREATE TABLE IF NOT EXISTS slos (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    model_id UUID REFERENCES models(id) ON DELETE CASCADE,
+    endpoint VARCHAR(255) NOT NULL DEFAULT '', -- Empty means every endpoint
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE, -- NULL means every organization
+    objective VARCHAR(20) NOT NULL, -- availability or latency
+    target NUMERIC(6,3) NOT NULL, -- Percentage of good requests, e.g. 99.9
+    latency_threshold_ms INTEGER,
+    window_days INTEGER NOT NULL DEFAULT 30,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    alert_level VARCHAR(20) NOT NULL DEFAULT '', -- Burn rate alert last raised: warning or critical
+    alerted_at TIMESTAMP WITH TIME ZONE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    CHECK (model_id IS NOT NULL OR endpoint <> '')
+);
+
+-- Per-endpoint pacing of an organization's streamed responses to a steady token rate
+CREATE TABLE IF NOT EXISTS stream_pacing (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    endpoint VARCHAR(255) NOT NULL, -- e.g. /v1/chat/completions
+    tokens_per_second INTEGER NOT NULL CHECK (tokens_per_second > 0),
+    burst_tokens INTEGER NOT NULL DEFAULT 0 CHECK (burst_tokens >= 0),
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE (organization_id, endpoint)
+);
+
 -- Model-Organization access table (many-to-many)
 CREATE TABLE IF NOT EXISTS model_organization_access (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -121,6 +268,16 @@ CREATE TABLE IF NOT EXISTS model_organization_access (
     UNIQUE(model_id, organization_id)
 );
 
+-- Model access blocked for an organization (and its children) that it would otherwise inherit
+-- from a parent's grant. The nearest level of the hierarchy with a grant or a denial wins.
+CREATE TABLE IF NOT EXISTS model_organization_access_denials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    model_id UUID NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(model_id, organization_id)
+);
+
 -- Usage tracking table for token consumption analytics and billing
 CREATE TABLE IF NOT EXISTS usage_logs (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -159,7 +316,14 @@ CREATE TABLE IF NOT EXISTS email_settings (
     smtp_password VARCHAR(255), -- Encrypted
     smtp_from_name VARCHAR(255),
     smtp_from_email VARCHAR(255),
+    smtp_tls_mode VARCHAR(20) DEFAULT 'auto', -- 'auto', 'implicit', 'starttls'
+    secondary_smtp_host VARCHAR(255), -- Failover server, tried when the primary can't be reached or refuses the login
+    secondary_smtp_port INTEGER DEFAULT 587,
+    secondary_smtp_username VARCHAR(255),
+    secondary_smtp_password VARCHAR(255),
+    secondary_smtp_tls_mode VARCHAR(20) DEFAULT 'auto',
     is_enabled BOOLEAN DEFAULT false,
+    unsubscribe_secret TEXT DEFAULT (gen_random_uuid()::text || gen_random_uuid()::text), -- Signs unsubscribe links
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
@@ -173,10 +337,29 @@ CREATE TABLE IF NOT EXISTS email_templates (
     html_body TEXT NOT NULL,
     text_body TEXT,
     is_active BOOLEAN DEFAULT true,
+    locale VARCHAR(10) NOT NULL DEFAULT 'en', -- Sent to organizations using this language
+    current_version INTEGER NOT NULL DEFAULT 1, -- Published version in email_template_versions
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Content history of email templates: one draft, one published, the rest archived
+CREATE TABLE IF NOT EXISTS email_template_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    template_id UUID NOT NULL REFERENCES email_templates(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    subject VARCHAR(500) NOT NULL,
+    html_body TEXT NOT NULL,
+    text_body TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft', -- 'draft', 'published', 'archived'
+    source_version INTEGER, -- Version restored by a rollback
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(template_id, version)
+);
+
 -- Email reminder schedules
 CREATE TABLE IF NOT EXISTS email_schedules (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -187,6 +370,26 @@ CREATE TABLE IF NOT EXISTS email_schedules (
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Outbox of emails waiting for the background sender
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    recipient_email VARCHAR(255) NOT NULL,
+    subject VARCHAR(500) NOT NULL,
+    html_body TEXT NOT NULL,
+    template_id UUID REFERENCES email_templates(id) ON DELETE SET NULL,
+    organization_id UUID REFERENCES organizations(id) ON DELETE SET NULL, -- Selects the organization's branded from-name
+    notification_type VARCHAR(50), -- Event type for notifications; NULL for transactional mail
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'sending', 'sent', 'failed', 'suppressed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    scheduled_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(), -- Requested delivery time
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(), -- Pushed back on each failed attempt
+    last_error TEXT, -- Failure reason from the most recent attempt
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 -- Email logs for tracking sent emails
 CREATE TABLE IF NOT EXISTS email_logs (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -195,10 +398,405 @@ CREATE TABLE IF NOT EXISTS email_logs (
     template_id UUID REFERENCES email_templates(id),
     status VARCHAR(50) NOT NULL, -- 'sent', 'failed', 'pending'
     error_message TEXT,
+    outbox_id UUID REFERENCES email_outbox(id) ON DELETE SET NULL, -- Outbox message it was sent from, for resending
+    smtp_server VARCHAR(20), -- 'primary' or 'secondary': the server that delivered it
     sent_at TIMESTAMP WITH TIME ZONE,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
 );
 
+-- Expiry reminders already sent, so a key is warned once per threshold
+CREATE TABLE IF NOT EXISTS api_key_reminders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    api_key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    schedule_type VARCHAR(100) NOT NULL, -- 'api_key_warning', 'api_key_expiration'
+    days_before INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL, -- Expiry the reminder was sent for; a new expiry re-arms reminders
+    outbox_id UUID REFERENCES email_outbox(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(api_key_id, schedule_type, days_before, expires_at)
+);
+
+-- Per-organization quota usage notification settings, and thresholds already notified per quota period
+CREATE TABLE IF NOT EXISTS quota_notification_settings (
+    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    is_enabled BOOLEAN DEFAULT true,
+    thresholds JSONB DEFAULT '[80, 90, 100]', -- Percent of quota
+    recipients JSONB DEFAULT '[]', -- Email addresses; empty means the organization's billing contacts, or admins
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS quota_notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    threshold INTEGER NOT NULL,
+    reset_date TIMESTAMP WITH TIME ZONE NOT NULL, -- Quota period the threshold was crossed in
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(organization_id, threshold, reset_date)
+);
+
+-- Slack / Microsoft Teams webhooks that receive gateway alerts
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL, -- 'slack', 'teams'
+    webhook_url TEXT NOT NULL,
+    events JSONB DEFAULT '[]', -- e.g. ["api_key_expiry", "quota_usage"]; empty means all events
+    is_enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Sign-in attempts to the admin UI, for the sign-in history and anomaly alerts
+CREATE TABLE IF NOT EXISTS login_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    identity VARCHAR(255) NOT NULL DEFAULT '', -- Username or email the attempt was for
+    method VARCHAR(20) NOT NULL, -- 'local', 'azure', 'break_glass'
+    success BOOLEAN NOT NULL,
+    failure_reason TEXT,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    country VARCHAR(2), -- From the header named by LOGIN_COUNTRY_HEADER
+    anomalies TEXT[] NOT NULL DEFAULT '{}', -- 'new_country', 'failures_then_success'
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Server side of Azure AD sign-ins: the refresh token that extends the session
+CREATE TABLE IF NOT EXISTS azure_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    azure_oid VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    refresh_token TEXT NOT NULL, -- Sealed with ENCRYPTION_KEY
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL, -- When the current access token expires
+    refreshed_at TIMESTAMP WITH TIME ZONE,
+    refresh_claimed_until TIMESTAMP WITH TIME ZONE, -- Held by the request refreshing it
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Emergency System Admin account for when Azure AD is down; credentials come from the environment
+CREATE TABLE IF NOT EXISTS break_glass_account (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id), -- Single row
+    enabled BOOLEAN NOT NULL DEFAULT true, -- Policy set by System Admins
+    session_token_hash VARCHAR(64), -- SHA-256 of the current session's token
+    session_expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Organization notification roster: notification emails go to the contacts of the event's role
+CREATE TABLE IF NOT EXISTS organization_contacts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL, -- 'billing', 'technical', 'security'
+    email VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(organization_id, role, email)
+);
+
+-- Per-organization branding overriding the global theme in the admin UI and emails
+CREATE TABLE IF NOT EXISTS organization_branding (
+    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    logo_url TEXT,
+    primary_color VARCHAR(7), -- #rrggbb
+    secondary_color VARCHAR(7),
+    accent_color VARCHAR(7),
+    from_name VARCHAR(255), -- Overrides smtp_from_name for the organization's emails
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- DKIM signing keys per sending domain
+CREATE TABLE IF NOT EXISTS dkim_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    domain VARCHAR(255) NOT NULL UNIQUE,
+    selector VARCHAR(63) NOT NULL,
+    private_key TEXT NOT NULL, -- PEM, encrypted with ENCRYPTION_KEY
+    public_key TEXT NOT NULL, -- Base64 DER, published as the DNS p= tag
+    is_enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Per-recipient notification opt-outs; a missing row means subscribed
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    email VARCHAR(255) NOT NULL, -- Lowercased; recipients need not be users
+    event_type VARCHAR(50) NOT NULL, -- 'api_key_expiry', 'quota_usage'
+    channel VARCHAR(20) NOT NULL, -- 'email'
+    is_enabled BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (email, event_type, channel)
+);
+
+-- A/B experiments splitting endpoint traffic between prompt/model variants
+CREATE TABLE IF NOT EXISTS experiments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    endpoint VARCHAR(255) NOT NULL, -- e.g., "/v1/chat/completions"
+    status VARCHAR(50) NOT NULL DEFAULT 'draft', -- 'draft', 'running', 'stopped', 'completed'
+    winner_variant_id UUID,
+    created_by UUID REFERENCES users(id),
+    started_at TIMESTAMP WITH TIME ZONE,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Experiment variants (one row per arm)
+CREATE TABLE IF NOT EXISTS experiment_variants (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    experiment_id UUID NOT NULL REFERENCES experiments(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    model_id VARCHAR(255) NOT NULL, -- Provider model name, e.g. "gpt-4"
+    system_prompt TEXT,
+    traffic_percent INTEGER NOT NULL CHECK (traffic_percent >= 0 AND traffic_percent <= 100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- End-user feedback on gateway responses
+CREATE TABLE IF NOT EXISTS response_feedback (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    request_id VARCHAR(255) NOT NULL,
+    usage_log_id UUID REFERENCES usage_logs(id) ON DELETE SET NULL,
+    thumbs_up BOOLEAN,
+    rating SMALLINT CHECK (rating >= 1 AND rating <= 5),
+    comment TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(organization_id, request_id)
+);
+
+-- Expected JSON schemas for model output, per endpoint
+CREATE TABLE IF NOT EXISTS response_schemas (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    endpoint VARCHAR(255) NOT NULL, -- e.g., "/v1/chat/completions"
+    schema JSONB NOT NULL,
+    retry_on_failure BOOLEAN DEFAULT false,
+    max_retries INTEGER DEFAULT 1 CHECK (max_retries >= 0 AND max_retries <= 3),
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Secret scanning policies and incidents for outgoing prompts
+CREATE TABLE IF NOT EXISTS secret_scan_policies (
+    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    mode VARCHAR(20) NOT NULL DEFAULT 'flag', -- 'off', 'flag', 'block'
+    custom_patterns JSONB DEFAULT '[]', -- [{"name": "...", "pattern": "..."}]
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS secret_scan_incidents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    model_id VARCHAR(255), -- Requested model name
+    endpoint VARCHAR(255) NOT NULL,
+    action VARCHAR(20) NOT NULL, -- 'blocked', 'flagged'
+    findings JSONB NOT NULL, -- [{"type": "...", "preview": "..."}], secrets are masked
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Audit log for sensitive admin actions (e.g. revealing stored secrets)
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(100) NOT NULL, -- e.g. 'secret.reveal'
+    resource_type VARCHAR(50) NOT NULL, -- e.g. 'model', 'email_settings'
+    resource_id VARCHAR(255),
+    details JSONB DEFAULT '{}',
+    ip_address VARCHAR(45),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Custom organization roles; the built-in admin and member roles are defined in code
+CREATE TABLE IF NOT EXISTS organization_roles (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL, -- Stored in user_organizations.role_name
+    description TEXT,
+    permissions TEXT[] NOT NULL DEFAULT '{}', -- e.g. keys:read, keys:write, analytics:read
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(organization_id, name)
+);
+
+-- Logged request/response bodies, written by the gateway when payload logging is on
+CREATE TABLE IF NOT EXISTS request_payloads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    model_id UUID REFERENCES models(id) ON DELETE SET NULL,
+    endpoint VARCHAR(255) NOT NULL,
+    request_body TEXT NOT NULL,
+    response_status INTEGER NOT NULL,
+    response_body TEXT,
+    response_time_ms INTEGER,
+    truncated BOOLEAN NOT NULL DEFAULT false, -- A body was cut to the logging size limit
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Sandbox replays of logged requests. Replays call the provider directly and are never written
+-- to usage_logs, so they stay out of analytics, quotas and billing.
+CREATE TABLE IF NOT EXISTS request_replays (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payload_id UUID NOT NULL REFERENCES request_payloads(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    model_id UUID REFERENCES models(id) ON DELETE SET NULL,
+    request_body TEXT NOT NULL, -- As sent, with the model swapped in
+    response_status INTEGER NOT NULL,
+    response_body TEXT,
+    response_time_ms INTEGER,
+    error TEXT, -- Set when the provider couldn't be reached
+    replayed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Batch API input files and generated output/error files (JSONL)
+CREATE TABLE IF NOT EXISTS batch_files (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    purpose VARCHAR(20) NOT NULL, -- 'batch', 'batch_output'
+    filename VARCHAR(255) NOT NULL,
+    bytes INTEGER NOT NULL,
+    content BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Batch API jobs, processed asynchronously by the gateway. Each request is also written to
+-- usage_logs; the token totals here are the batch's aggregate.
+CREATE TABLE IF NOT EXISTS batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    endpoint VARCHAR(255) NOT NULL,
+    input_file_id UUID NOT NULL REFERENCES batch_files(id) ON DELETE CASCADE,
+    output_file_id UUID REFERENCES batch_files(id) ON DELETE SET NULL,
+    error_file_id UUID REFERENCES batch_files(id) ON DELETE SET NULL,
+    completion_window VARCHAR(10) NOT NULL DEFAULT '24h',
+    status VARCHAR(20) NOT NULL, -- 'failed', 'in_progress', 'finalizing', 'completed', 'cancelling', 'cancelled'
+    errors JSONB DEFAULT '[]',
+    total_requests INTEGER NOT NULL DEFAULT 0,
+    completed_requests INTEGER NOT NULL DEFAULT 0,
+    failed_requests INTEGER NOT NULL DEFAULT 0,
+    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    total_tokens BIGINT NOT NULL DEFAULT 0,
+    metadata JSONB DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    in_progress_at TIMESTAMP WITH TIME ZONE,
+    finalizing_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    failed_at TIMESTAMP WITH TIME ZONE,
+    cancelling_at TIMESTAMP WITH TIME ZONE,
+    cancelled_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Days and models where the gateway's usage_logs and a provider's usage export disagree by more
+-- than the threshold, written by usage reconciliation runs. A later run over the same day
+-- replaces its rows.
+CREATE TABLE IF NOT EXISTS usage_discrepancies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source VARCHAR(50) NOT NULL, -- 'openai_api', 'csv'
+    provider VARCHAR(100) NOT NULL,
+    usage_date DATE NOT NULL,
+    model VARCHAR(255) NOT NULL,
+    gateway_tokens BIGINT NOT NULL DEFAULT 0,
+    provider_tokens BIGINT NOT NULL DEFAULT 0,
+    gateway_requests BIGINT NOT NULL DEFAULT 0,
+    provider_requests BIGINT NOT NULL DEFAULT 0,
+    difference_pct REAL NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(provider, usage_date, model)
+);
+
+-- Saved analytics filter combinations. Shared views are visible to members of the view's
+-- organization who can read its analytics.
+CREATE TABLE IF NOT EXISTS analytics_views (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    filters JSONB NOT NULL DEFAULT '{}',
+    shared BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(user_id, name)
+);
+CREATE INDEX IF NOT EXISTS idx_analytics_views_org_shared ON analytics_views(organization_id) WHERE shared = true;
+
+-- Requests made with canary keys; headers are stored with credentials masked
+CREATE TABLE IF NOT EXISTS canary_key_hits (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    api_key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    ip_address VARCHAR(45) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(500) NOT NULL,
+    headers JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_canary_key_hits_api_key_created_at ON canary_key_hits(api_key_id, created_at DESC);
+
+-- Client addresses the gateway refuses, such as callers of canary keys
+CREATE TABLE IF NOT EXISTS blocked_ips (
+    ip_address VARCHAR(45) PRIMARY KEY,
+    reason TEXT NOT NULL,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL, -- Canary key that caused the block
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE -- NULL blocks until removed
+);
+
+-- Chat history kept for clients that send X-RelAI-Session-Id, sealed with the organization's key
+CREATE TABLE IF NOT EXISTS conversation_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    session_id VARCHAR(128) NOT NULL,
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL, -- Key that last used the session
+    messages TEXT NOT NULL, -- Sealed JSON array of chat messages
+    size_bytes INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(organization_id, session_id)
+);
+CREATE INDEX IF NOT EXISTS idx_conversation_sessions_expires_at ON conversation_sessions(expires_at);
+
+-- Alerts shown in the admin UI's notification center, next to email and chat channels
+CREATE TABLE IF NOT EXISTS notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE, -- NULL for gateway-wide alerts, shown to System Admins
+    event_type VARCHAR(50) NOT NULL, -- 'quota_usage', 'api_key_expiry', 'sync_failure', ...
+    severity VARCHAR(20) NOT NULL DEFAULT 'info', -- 'info', 'warning', 'critical'
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    fields JSONB NOT NULL DEFAULT '[]',
+    link_url TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_notifications_org_created ON notifications(organization_id, created_at DESC);
+
+-- When each user read each notification; a notification without a row is unread
+CREATE TABLE IF NOT EXISTS notification_reads (
+    notification_id UUID NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    read_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (notification_id, user_id)
+);
+
 -- Indexes for performance
 -- RBAC indexes
 CREATE INDEX IF NOT EXISTS idx_users_azure_oid ON users(azure_oid);
@@ -211,16 +809,20 @@ CREATE INDEX IF NOT EXISTS idx_organization_ad_groups_ad_group_id ON organizatio
 CREATE INDEX IF NOT EXISTS idx_system_ad_groups_ad_group_id ON system_ad_groups(ad_group_id);
 CREATE INDEX IF NOT EXISTS idx_organizations_ad_admin_group ON organizations(ad_admin_group_id);
 CREATE INDEX IF NOT EXISTS idx_organizations_ad_member_group ON organizations(ad_member_group_id);
+CREATE INDEX IF NOT EXISTS idx_organizations_parent_id ON organizations(parent_id);
 
 -- API and models indexes
 CREATE INDEX IF NOT EXISTS idx_api_keys_api_key ON api_keys(api_key);
 CREATE INDEX IF NOT EXISTS idx_api_keys_organization_id ON api_keys(organization_id);
 CREATE INDEX IF NOT EXISTS idx_api_keys_is_active ON api_keys(is_active);
 CREATE INDEX IF NOT EXISTS idx_api_keys_created_by_user_id ON api_keys(created_by_user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_project_id ON api_keys(project_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_projects_org_name ON projects(organization_id, name) WHERE is_active = true;
 CREATE INDEX IF NOT EXISTS idx_models_model_id ON models(model_id);
 CREATE INDEX IF NOT EXISTS idx_models_is_active ON models(is_active);
 CREATE INDEX IF NOT EXISTS idx_model_org_access_model_id ON model_organization_access(model_id);
 CREATE INDEX IF NOT EXISTS idx_model_org_access_org_id ON model_organization_access(organization_id);
+CREATE INDEX IF NOT EXISTS idx_model_org_access_denials_org_id ON model_organization_access_denials(organization_id);
 
 -- Usage tracking indexes
 CREATE INDEX IF NOT EXISTS idx_usage_logs_organization_id ON usage_logs(organization_id);
@@ -232,6 +834,12 @@ CREATE INDEX IF NOT EXISTS idx_organization_quotas_org_id ON organization_quotas
 CREATE INDEX IF NOT EXISTS idx_usage_logs_created_at_org_id ON usage_logs(created_at, organization_id);
 CREATE INDEX IF NOT EXISTS idx_usage_logs_model_id_created_at ON usage_logs(model_id, created_at);
 CREATE INDEX IF NOT EXISTS idx_usage_logs_api_key_created_at ON usage_logs(api_key_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_logs_org_created_at ON usage_logs(organization_id, created_at);
+
+-- List page indexes (newest first)
+CREATE INDEX IF NOT EXISTS idx_api_keys_active_created_at ON api_keys(created_at DESC) WHERE is_active = true;
+CREATE INDEX IF NOT EXISTS idx_api_keys_org_active_created_at ON api_keys(organization_id, created_at DESC) WHERE is_active = true;
+CREATE INDEX IF NOT EXISTS idx_email_logs_created_at ON email_logs(created_at DESC);
 
 -- Email system indexes
 CREATE INDEX IF NOT EXISTS idx_email_templates_type ON email_templates(type);
@@ -240,6 +848,29 @@ CREATE INDEX IF NOT EXISTS idx_email_schedules_type ON email_schedules(schedule_
 CREATE INDEX IF NOT EXISTS idx_email_logs_recipient ON email_logs(recipient_email);
 CREATE INDEX IF NOT EXISTS idx_email_logs_status ON email_logs(status);
 CREATE INDEX IF NOT EXISTS idx_email_logs_sent_at ON email_logs(sent_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_template_versions_draft ON email_template_versions(template_id) WHERE status = 'draft';
+CREATE INDEX IF NOT EXISTS idx_email_outbox_status_next_attempt ON email_outbox(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_login_events_identity_created ON login_events(LOWER(identity), created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_login_events_user_created ON login_events(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notification_channels_org_id ON notification_channels(organization_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_owner_user_id ON api_keys(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_request_payloads_org_created ON request_payloads(organization_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_request_replays_payload ON request_replays(payload_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_batches_org_created ON batches(organization_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_batches_status ON batches(status);
+CREATE INDEX IF NOT EXISTS idx_usage_discrepancies_date ON usage_discrepancies(usage_date);
+CREATE INDEX IF NOT EXISTS idx_api_keys_expires_at ON api_keys(expires_at) WHERE expires_at IS NOT NULL;
+
+-- Experiment indexes
+CREATE INDEX IF NOT EXISTS idx_experiments_org_id ON experiments(organization_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_experiments_running_endpoint ON experiments(organization_id, endpoint) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS idx_experiment_variants_experiment_id ON experiment_variants(experiment_id);
+CREATE INDEX IF NOT EXISTS idx_response_feedback_request_id ON response_feedback(request_id);
+CREATE INDEX IF NOT EXISTS idx_response_feedback_created_at ON response_feedback(created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_response_schemas_active_endpoint ON response_schemas(organization_id, endpoint) WHERE is_active = true;
+CREATE INDEX IF NOT EXISTS idx_secret_scan_incidents_org_created ON secret_scan_incidents(organization_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_logs_request_id ON usage_logs(request_id);
 
 -- Insert default roles
 INSERT INTO roles (id, name, description, is_system_role) VALUES
@@ -302,10 +933,26 @@ INSERT INTO email_templates (id, name, type, subject, html_body, text_body) VALU
 ('10000000-0000-0000-0000-000000000002', 'API Key Expired', 'expiration',
  'Your API Key has expired',
  '<!DOCTYPE html><html><head><style>body{font-family:Arial,sans-serif;margin:40px;color:#333}.header{background:#f8f9fa;padding:20px;border-radius:8px;margin-bottom:20px}.alert{background:#f8d7da;border:1px solid #f5c6cb;padding:15px;border-radius:5px;margin:20px 0}.button{display:inline-block;background:#dc3545;color:white;padding:10px 20px;text-decoration:none;border-radius:5px;margin:10px 0}</style></head><body><div class="header"><h2>🚨 API Key Expired</h2></div><p>Hello {{.UserName}},</p><p>Your API key has expired and is no longer active:</p><div class="alert"><strong>API Key:</strong> {{.APIKeyName}}<br><strong>Organization:</strong> {{.OrganizationName}}<br><strong>Expired on:</strong> {{.ExpirationDate}}</div><p>Please create a new API key to restore service.</p><a href="{{.ManagementURL}}" class="button">Create New API Key</a><p>Best regards,<br>RelAI Gateway Team</p></body></html>',
- 'Hello {{.UserName}}, Your API key "{{.APIKeyName}}" for organization "{{.OrganizationName}}" has expired on {{.ExpirationDate}}. Please create a new one at: {{.ManagementURL}}')
+ 'Hello {{.UserName}}, Your API key "{{.APIKeyName}}" for organization "{{.OrganizationName}}" has expired on {{.ExpirationDate}}. Please create a new one at: {{.ManagementURL}}'),
+
+('10000000-0000-0000-0000-000000000003', 'Quota Usage Alert', 'usage',
+ 'Token quota {{.Threshold}}% used for {{.OrganizationName}}',
+ '<!DOCTYPE html><html><head><style>body{font-family:Arial,sans-serif;margin:40px;color:#333}.header{background:#f8f9fa;padding:20px;border-radius:8px;margin-bottom:20px}.warning{background:#fff3cd;border:1px solid #ffeaa7;padding:15px;border-radius:5px;margin:20px 0}table{border-collapse:collapse;margin:10px 0}td,th{padding:6px 12px;border-bottom:1px solid #eee;text-align:left}.button{display:inline-block;background:#007bff;color:white;padding:10px 20px;text-decoration:none;border-radius:5px;margin:10px 0}</style></head><body><div class="header"><h2>📊 Token Quota Usage Alert</h2></div><p>Hello {{.UserName}},</p><p>{{.OrganizationName}} has used {{.Threshold}}% of its token quota:</p><div class="warning"><strong>Current usage:</strong> {{.UsedTokens}} of {{.TotalQuota}} tokens ({{.UsagePercent}})<br><strong>Quota resets:</strong> {{.ResetDate}}</div>{{if .TopAPIKeys}}<p>Top consuming API keys (last 30 days):</p><table><tr><th>API Key</th><th>Tokens</th></tr>{{range .TopAPIKeys}}<tr><td>{{.Name}}</td><td>{{.Tokens}}</td></tr>{{end}}</table>{{end}}<p>Requests may be rejected once the quota is exhausted.</p><a href="{{.ManagementURL}}" class="button">Manage API Keys</a><p>Best regards,<br>RelAI Gateway Team</p></body></html>',
+ 'Hello {{.UserName}}, {{.OrganizationName}} has used {{.Threshold}}% of its token quota ({{.UsedTokens}} of {{.TotalQuota}} tokens). The quota resets on {{.ResetDate}}. Manage API keys at: {{.ManagementURL}}')
 
 ON CONFLICT (id) DO NOTHING;
 
+-- Record the default templates as their first published version
+INSERT INTO email_template_versions (template_id, version, subject, html_body, text_body, status, published_at)
+SELECT id, 1, subject, html_body, text_body, 'published', created_at FROM email_templates
+ON CONFLICT (template_id, version) DO NOTHING;
+
+-- Default API key expiry reminder schedules (apply to all organizations)
+INSERT INTO email_schedules (schedule_type, days_before)
+SELECT t.schedule_type, t.days_before
+FROM (VALUES ('api_key_warning', 7), ('api_key_warning', 3), ('api_key_warning', 1), ('api_key_expiration', 0)) AS t(schedule_type, days_before)
+WHERE NOT EXISTS (SELECT 1 FROM email_schedules);
+
 -- Insert default email settings (disabled by default)
 INSERT INTO email_settings (id, smtp_from_name, smtp_from_email, is_enabled) VALUES
 ('20000000-0000-0000-0000-000000000001', 'RelAI Gateway', 'noreply@relai-gateway.com', false)
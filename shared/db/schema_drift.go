@@ -0,0 +1,428 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Schema drift
+//
+// The expected schema is schema.sql itself: it is built in a scratch schema inside a transaction
+// that is rolled back, and the catalogs of the two schemas are compared. Existing databases are
+// no longer altered at boot; the drift is logged, reported to System Admins, and fixed on request
+// (or at boot with DB_AUTO_MIGRATE=true).
+
+// expectedSchemaName is the scratch schema schema.sql is built in; it never outlives its transaction
+const expectedSchemaName = "relai_expected_schema"
+
+// schemaFixLockKey keeps replicas from applying schema fixes at the same time
+const schemaFixLockKey = 7263810454
+
+// driftOrder is the order fixes run in: tables before their columns, keys before the foreign
+// keys referencing them, indexes last
+var driftOrder = map[string]int{
+	models.SchemaDriftTable:      0,
+	models.SchemaDriftColumn:     1,
+	models.SchemaDriftColumnType: 2,
+	models.SchemaDriftConstraint: 3,
+	models.SchemaDriftIndex:      4,
+}
+
+// constraintOrder runs primary keys, then unique and check constraints, then foreign keys
+var constraintOrder = map[string]int{"p": 0, "u": 1, "c": 2, "f": 3}
+
+type schemaColumn struct {
+	table, name, dataType string
+	notNull               bool
+	defaultExpr           sql.NullString
+}
+
+type schemaConstraint struct {
+	table, name, kind, definition string
+}
+
+type schemaIndex struct {
+	table, name, definition string
+}
+
+// autoMigrate reports whether DB_AUTO_MIGRATE asks for schema fixes to be applied at boot
+func autoMigrate() bool {
+	v := os.Getenv("DB_AUTO_MIGRATE")
+	return v == "true" || v == "1"
+}
+
+// CheckSchemaDrift compares the live database with schema.sql and reports missing tables,
+// columns, constraints and indexes, and columns whose type differs. Nothing is changed.
+func CheckSchemaDrift(db *sql.DB) (*models.SchemaDriftReport, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	drift, err := schemaDrift(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SchemaDriftReport{CheckedAt: time.Now().UTC(), Drift: drift}, nil
+}
+
+// ApplySchemaFixes brings the database up to date: it runs the upgrade steps of earlier releases,
+// which also backfill data, then applies the generated fix of any drift still left, in one
+// transaction. Drift without a fix is left for an administrator. It returns a fresh report.
+func ApplySchemaFixes(db *sql.DB) (*models.SchemaDriftReport, error) {
+	if err := updateSchema(db); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, schemaFixLockKey); err != nil {
+		return nil, err
+	}
+	drift, err := schemaDrift(tx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DROP SCHEMA ` + expectedSchemaName + ` CASCADE`); err != nil {
+		return nil, err
+	}
+
+	applied := 0
+	for _, d := range drift {
+		if d.Fix == "" {
+			continue
+		}
+		if _, err := tx.Exec(d.Fix); err != nil {
+			return nil, fmt.Errorf("failed to apply %q: %w", d.Fix, err)
+		}
+		log.Printf("Schema fix applied: %s", d.Fix)
+		applied++
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if applied > 0 {
+		log.Printf("Applied %d schema fixes", applied)
+	}
+
+	return CheckSchemaDrift(db)
+}
+
+// checkSchemaAtBoot logs the drift of an existing database at boot. With strict set, a missing
+// table or column fails the boot, since queries of this release would fail on it at request
+// time. Failing to check isn't fatal: the database user may lack permission to create the
+// scratch schema.
+func checkSchemaAtBoot(db *sql.DB, strict bool) error {
+	report, err := CheckSchemaDrift(db)
+	if err != nil {
+		log.Printf("Warning: could not check the database schema for drift: %v", err)
+		return nil
+	}
+	if len(report.Drift) == 0 {
+		log.Println("Database schema is up to date")
+		return nil
+	}
+
+	log.Printf("Warning: the database schema differs from this release in %d places (%d fixable). "+
+		"Review and apply fixes from Settings > System, `relai-admin schema apply`, or start with DB_AUTO_MIGRATE=true:",
+		len(report.Drift), report.Fixable())
+	missing := 0
+	for _, d := range report.Drift {
+		if d.Name != "" {
+			log.Printf("  missing or different %s %s.%s", d.Kind, d.Table, d.Name)
+		} else {
+			log.Printf("  missing %s %s", d.Kind, d.Table)
+		}
+		if d.Kind == models.SchemaDriftTable || d.Kind == models.SchemaDriftColumn {
+			missing++
+		}
+	}
+	if strict && missing > 0 {
+		return fmt.Errorf("the database is missing %d tables or columns this release needs; "+
+			"apply them with `relai-admin schema apply` or start with DB_AUTO_MIGRATE=true", missing)
+	}
+	return nil
+}
+
+// schemaDrift builds schema.sql in the scratch schema and compares it with public. The caller
+// rolls the transaction back (or drops the scratch schema) afterwards.
+func schemaDrift(tx *sql.Tx) ([]models.SchemaDrift, error) {
+	if _, err := tx.Exec(`CREATE SCHEMA ` + expectedSchemaName); err != nil {
+		return nil, fmt.Errorf("failed to create scratch schema: %w", err)
+	}
+	if _, err := tx.Exec(`SET LOCAL search_path TO ` + expectedSchemaName); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(schemaSQL); err != nil {
+		return nil, fmt.Errorf("failed to build the expected schema: %w", err)
+	}
+	// With only public on the search path, catalog functions qualify the scratch schema's names,
+	// so they can be told apart and stripped
+	if _, err := tx.Exec(`SET LOCAL search_path TO public`); err != nil {
+		return nil, err
+	}
+
+	expectedColumns, err := schemaColumns(tx, expectedSchemaName)
+	if err != nil {
+		return nil, err
+	}
+	liveColumns, err := schemaColumns(tx, "public")
+	if err != nil {
+		return nil, err
+	}
+	expectedConstraints, err := schemaConstraints(tx, expectedSchemaName)
+	if err != nil {
+		return nil, err
+	}
+	liveConstraints, err := schemaConstraints(tx, "public")
+	if err != nil {
+		return nil, err
+	}
+	expectedIndexes, err := schemaIndexes(tx, expectedSchemaName)
+	if err != nil {
+		return nil, err
+	}
+	liveIndexes, err := schemaIndexes(tx, "public")
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []models.SchemaDrift
+
+	liveTables := map[string]bool{}
+	liveColumnsByKey := map[string]schemaColumn{}
+	for _, c := range liveColumns {
+		liveTables[c.table] = true
+		liveColumnsByKey[c.table+"."+c.name] = c
+	}
+
+	// Tables and columns
+	missingTables := map[string][]schemaColumn{}
+	var missingTableNames []string
+	for _, c := range expectedColumns {
+		if !liveTables[c.table] {
+			if _, seen := missingTables[c.table]; !seen {
+				missingTableNames = append(missingTableNames, c.table)
+			}
+			missingTables[c.table] = append(missingTables[c.table], c)
+			continue
+		}
+
+		live, ok := liveColumnsByKey[c.table+"."+c.name]
+		if !ok {
+			drift = append(drift, models.SchemaDrift{
+				Kind:     models.SchemaDriftColumn,
+				Table:    c.table,
+				Name:     c.name,
+				Expected: c.dataType,
+				Fix:      fmt.Sprintf("ALTER TABLE public.%s ADD COLUMN IF NOT EXISTS %s", pq.QuoteIdentifier(c.table), columnDefinition(c)),
+			})
+		} else if live.dataType != c.dataType {
+			drift = append(drift, models.SchemaDrift{
+				Kind:     models.SchemaDriftColumnType,
+				Table:    c.table,
+				Name:     c.name,
+				Expected: c.dataType,
+				Actual:   live.dataType,
+			})
+		}
+	}
+	for _, table := range missingTableNames {
+		definitions := make([]string, 0, len(missingTables[table]))
+		for _, c := range missingTables[table] {
+			definitions = append(definitions, columnDefinition(c))
+		}
+		drift = append(drift, models.SchemaDrift{
+			Kind:  models.SchemaDriftTable,
+			Table: table,
+			Fix:   fmt.Sprintf("CREATE TABLE IF NOT EXISTS public.%s (%s)", pq.QuoteIdentifier(table), strings.Join(definitions, ", ")),
+		})
+	}
+
+	// Constraints match on what they do; names of older databases may differ
+	liveConstraintSet := map[string]bool{}
+	for _, c := range liveConstraints {
+		liveConstraintSet[c.table+"|"+c.kind+"|"+c.definition] = true
+	}
+	for _, c := range expectedConstraints {
+		if liveConstraintSet[c.table+"|"+c.kind+"|"+c.definition] {
+			continue
+		}
+		drift = append(drift, models.SchemaDrift{
+			Kind:     models.SchemaDriftConstraint,
+			Table:    c.table,
+			Name:     c.name,
+			Expected: c.definition,
+			Fix: fmt.Sprintf("ALTER TABLE public.%s ADD CONSTRAINT %s %s",
+				pq.QuoteIdentifier(c.table), pq.QuoteIdentifier(c.name), c.definition),
+		})
+	}
+
+	// Indexes match on name
+	liveIndexByName := map[string]schemaIndex{}
+	for _, i := range liveIndexes {
+		liveIndexByName[i.name] = i
+	}
+	for _, i := range expectedIndexes {
+		live, ok := liveIndexByName[i.name]
+		switch {
+		case !ok:
+			drift = append(drift, models.SchemaDrift{
+				Kind:     models.SchemaDriftIndex,
+				Table:    i.table,
+				Name:     i.name,
+				Expected: i.definition,
+				Fix:      strings.Replace(i.definition, " INDEX ", " INDEX IF NOT EXISTS ", 1),
+			})
+		case live.definition != i.definition:
+			// Rebuilding an index can lock a large table, so that is left to an administrator
+			drift = append(drift, models.SchemaDrift{
+				Kind:     models.SchemaDriftIndex,
+				Table:    i.table,
+				Name:     i.name,
+				Expected: i.definition,
+				Actual:   live.definition,
+			})
+		}
+	}
+
+	sort.SliceStable(drift, func(a, b int) bool {
+		if driftOrder[drift[a].Kind] != driftOrder[drift[b].Kind] {
+			return driftOrder[drift[a].Kind] < driftOrder[drift[b].Kind]
+		}
+		if drift[a].Kind == models.SchemaDriftConstraint {
+			ka, kb := constraintKind(drift[a].Expected), constraintKind(drift[b].Expected)
+			if constraintOrder[ka] != constraintOrder[kb] {
+				return constraintOrder[ka] < constraintOrder[kb]
+			}
+		}
+		return drift[a].Table < drift[b].Table
+	})
+	return drift, nil
+}
+
+// columnDefinition renders a column for CREATE TABLE or ADD COLUMN
+func columnDefinition(c schemaColumn) string {
+	definition := pq.QuoteIdentifier(c.name) + " " + c.dataType
+	if c.defaultExpr.Valid {
+		definition += " DEFAULT " + c.defaultExpr.String
+	}
+	if c.notNull {
+		definition += " NOT NULL"
+	}
+	return definition
+}
+
+// constraintKind recovers the pg_constraint type of a constraint definition
+func constraintKind(definition string) string {
+	switch {
+	case strings.HasPrefix(definition, "PRIMARY KEY"):
+		return "p"
+	case strings.HasPrefix(definition, "UNIQUE"):
+		return "u"
+	case strings.HasPrefix(definition, "FOREIGN KEY"):
+		return "f"
+	}
+	return "c"
+}
+
+// unqualify removes the scratch schema from names the catalog functions printed
+func unqualify(definition string) string {
+	return strings.ReplaceAll(definition, expectedSchemaName+".", "public.")
+}
+
+func schemaColumns(tx *sql.Tx, schema string) ([]schemaColumn, error) {
+	rows, err := tx.Query(`
+		SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+		       pg_get_expr(d.adbin, d.adrelid)
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE n.nspname = $1 AND c.relkind = 'r' AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum`, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []schemaColumn
+	for rows.Next() {
+		var c schemaColumn
+		if err := rows.Scan(&c.table, &c.name, &c.dataType, &c.notNull, &c.defaultExpr); err != nil {
+			return nil, err
+		}
+		c.defaultExpr.String = unqualify(c.defaultExpr.String)
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+func schemaConstraints(tx *sql.Tx, schema string) ([]schemaConstraint, error) {
+	rows, err := tx.Query(`
+		SELECT c.relname, con.conname, con.contype::text, pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND con.contype IN ('p', 'u', 'f', 'c')
+		ORDER BY c.relname, con.conname`, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var constraints []schemaConstraint
+	for rows.Next() {
+		var c schemaConstraint
+		if err := rows.Scan(&c.table, &c.name, &c.kind, &c.definition); err != nil {
+			return nil, err
+		}
+		// References to live tables print unqualified, since public is on the search path
+		c.definition = strings.ReplaceAll(c.definition, expectedSchemaName+".", "")
+		constraints = append(constraints, c)
+	}
+	return constraints, rows.Err()
+}
+
+// schemaIndexes lists indexes other than those backing primary key and unique constraints
+func schemaIndexes(tx *sql.Tx, schema string) ([]schemaIndex, error) {
+	rows, err := tx.Query(`
+		SELECT t.relname, ic.relname, pg_get_indexdef(ix.indexrelid)
+		FROM pg_index ix
+		JOIN pg_class ic ON ic.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = $1
+		  AND NOT EXISTS (
+		      SELECT 1 FROM pg_constraint con
+		      WHERE con.conindid = ix.indexrelid AND con.contype IN ('p', 'u', 'x'))
+		ORDER BY t.relname, ic.relname`, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []schemaIndex
+	for rows.Next() {
+		var i schemaIndex
+		if err := rows.Scan(&i.table, &i.name, &i.definition); err != nil {
+			return nil, err
+		}
+		i.definition = unqualify(i.definition)
+		indexes = append(indexes, i)
+	}
+	return indexes, rows.Err()
+}
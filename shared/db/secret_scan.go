@@ -0,0 +1,188 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Secret scanning operations
+
+// GetSecretScanPolicy returns the organization's policy, or sql.ErrNoRows if none is configured
+func GetSecretScanPolicy(db *sql.DB, orgID string) (*models.SecretScanPolicy, error) {
+	var policy models.SecretScanPolicy
+	var patterns []byte
+	query := `SELECT organization_id, mode, custom_patterns, updated_at FROM secret_scan_policies WHERE organization_id = $1`
+
+	err := db.QueryRow(query, orgID).Scan(&policy.OrganizationID, &policy.Mode, &patterns, &policy.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.CustomPatterns = []models.SecretPattern{}
+	if len(patterns) > 0 {
+		if err := json.Unmarshal(patterns, &policy.CustomPatterns); err != nil {
+			return nil, err
+		}
+	}
+
+	return &policy, nil
+}
+
+// UpsertSecretScanPolicy creates or replaces an organization's policy
+func UpsertSecretScanPolicy(db *sql.DB, req models.UpdateSecretScanPolicyRequest) (*models.SecretScanPolicy, error) {
+	if req.CustomPatterns == nil {
+		req.CustomPatterns = []models.SecretPattern{}
+	}
+	patterns, err := json.Marshal(req.CustomPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO secret_scan_policies (organization_id, mode, custom_patterns)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id)
+		DO UPDATE SET mode = EXCLUDED.mode, custom_patterns = EXCLUDED.custom_patterns, updated_at = NOW()`
+
+	if _, err := db.Exec(query, req.OrganizationID, req.Mode, patterns); err != nil {
+		return nil, err
+	}
+
+	return GetSecretScanPolicy(db, req.OrganizationID)
+}
+
+// CreateSecretScanIncident records detected secrets (masked) for the admin report
+func CreateSecretScanIncident(db *sql.DB, incident models.SecretScanIncident) error {
+	findings, err := json.Marshal(incident.Findings)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO secret_scan_incidents (organization_id, api_key_id, model_id, endpoint, action, findings)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err = db.Exec(query, incident.OrganizationID, incident.APIKeyID, incident.ModelID, incident.Endpoint, incident.Action, findings)
+	return err
+}
+
+// GetSecretScanReport returns incident counts and the most recent incidents
+func GetSecretScanReport(db *sql.DB, filter models.AnalyticsFilter, limit int) (*models.SecretScanReport, error) {
+	startTime, err := parseTimeRange(filter.TimeRange, filter.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.SecretScanReport{
+		ByType:       []models.SecretTypeCount{},
+		Incidents:    []models.SecretScanIncident{},
+		TimeRange:    filter.TimeRange,
+		Organization: filter.Organization,
+		GeneratedAt:  time.Now(),
+	}
+
+	countQuery := `
+		SELECT
+			COUNT(*) FILTER (WHERE action = 'blocked'),
+			COUNT(*) FILTER (WHERE action = 'flagged')
+		FROM secret_scan_incidents
+		WHERE created_at >= $1
+		  AND ($2 = '' OR organization_id = $2::uuid)`
+
+	if err := db.QueryRow(countQuery, startTime, filter.Organization).Scan(&report.BlockedCount, &report.FlaggedCount); err != nil {
+		return nil, err
+	}
+
+	typeQuery := `
+		SELECT f->>'type' AS secret_type, COUNT(*)
+		FROM secret_scan_incidents i, jsonb_array_elements(i.findings) f
+		WHERE i.created_at >= $1
+		  AND ($2 = '' OR i.organization_id = $2::uuid)
+		GROUP BY secret_type
+		ORDER BY COUNT(*) DESC`
+
+	typeRows, err := db.Query(typeQuery, startTime, filter.Organization)
+	if err != nil {
+		return nil, err
+	}
+	defer typeRows.Close()
+
+	for typeRows.Next() {
+		var tc models.SecretTypeCount
+		if err := typeRows.Scan(&tc.Type, &tc.Count); err != nil {
+			return nil, err
+		}
+		report.ByType = append(report.ByType, tc)
+	}
+	if err := typeRows.Err(); err != nil {
+		return nil, err
+	}
+
+	incidentQuery := `
+		SELECT i.id, i.organization_id, i.api_key_id, ak.name, i.model_id, i.endpoint, i.action, i.findings, i.created_at
+		FROM secret_scan_incidents i
+		LEFT JOIN api_keys ak ON i.api_key_id = ak.id
+		WHERE i.created_at >= $1
+		  AND ($2 = '' OR i.organization_id = $2::uuid)
+		ORDER BY i.created_at DESC
+		LIMIT $3`
+
+	rows, err := db.Query(incidentQuery, startTime, filter.Organization, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var incident models.SecretScanIncident
+		var findings []byte
+		err := rows.Scan(
+			&incident.ID, &incident.OrganizationID, &incident.APIKeyID, &incident.APIKeyName,
+			&incident.ModelID, &incident.Endpoint, &incident.Action, &findings, &incident.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(findings, &incident.Findings); err != nil {
+			return nil, err
+		}
+		report.Incidents = append(report.Incidents, incident)
+	}
+
+	return report, rows.Err()
+}
+
+// RevokeLeakedAPIKey deactivates the key whose value is token, as reported leaked by an external
+// secret scanner. It returns the key and whether this call revoked it (false if it was already
+// inactive), or sql.ErrNoRows if no key has that value.
+func RevokeLeakedAPIKey(db *sql.DB, token string) (*models.APIKey, bool, error) {
+	var key models.APIKey
+	var orgName string
+	err := db.QueryRow(`
+		SELECT ak.id, ak.name, ak.organization_id, o.name, ak.is_active, ak.last_used, ak.created_at
+		FROM api_keys ak
+		JOIN organizations o ON ak.organization_id = o.id
+		WHERE ak.api_key = $1`, token).Scan(
+		&key.ID, &key.Name, &key.OrganizationID, &orgName, &key.IsActive, &key.LastUsed, &key.CreatedAt)
+	if err != nil {
+		return nil, false, err
+	}
+	key.KeyPrefix = "sk-" + key.ID[:8] + "..."
+	key.Organization = &models.Organization{ID: key.OrganizationID, Name: orgName}
+	if !key.IsActive {
+		return &key, false, nil
+	}
+
+	result, err := db.Exec(`
+		UPDATE api_keys SET is_active = false, updated_at = NOW()
+		WHERE id = $1 AND is_active = true`, key.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	n, _ := result.RowsAffected()
+	key.IsActive = false
+	return &key, n > 0, nil
+}
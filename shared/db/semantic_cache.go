@@ -0,0 +1,125 @@
+package db
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Semantic cache operations. The table needs the pgvector extension, so it isn't in schema.sql:
+// the gateway creates it when the semantic cache is turned on.
+
+// EnsureSemanticCacheSchema installs pgvector and creates the semantic cache table
+func EnsureSemanticCacheSchema(db *sql.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		`CREATE TABLE IF NOT EXISTS semantic_cache (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			model_id UUID NOT NULL REFERENCES models(id) ON DELETE CASCADE,
+			embedding_model VARCHAR(255) NOT NULL,
+			prompt_hash CHAR(64) NOT NULL,
+			params_hash CHAR(64) NOT NULL,
+			embedding vector NOT NULL,
+			response_body BYTEA NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_semantic_cache_lookup
+			ON semantic_cache(organization_id, model_id, params_hash, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_semantic_cache_prompt
+			ON semantic_cache(organization_id, model_id, prompt_hash)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindExactCacheEntry returns the newest entry for the same prompt and parameters created after
+// since, or nil
+func FindExactCacheEntry(db *sql.DB, orgID, modelID, promptHash string, since time.Time) (*models.SemanticCacheEntry, error) {
+	query := `
+		SELECT id, embedding_model, response_body, created_at
+		FROM semantic_cache
+		WHERE organization_id = $1 AND model_id = $2 AND prompt_hash = $3 AND created_at > $4
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	entry := models.SemanticCacheEntry{OrganizationID: orgID, ModelID: modelID, PromptHash: promptHash, Similarity: 1}
+	err := db.QueryRow(query, orgID, modelID, promptHash, since).Scan(&entry.ID, &entry.EmbeddingModel, &entry.ResponseBody, &entry.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// FindSimilarCacheEntry returns the entry with the same parameters whose prompt embedding is
+// closest to embedding, if its cosine similarity is at least minSimilarity and it was created
+// after since. Only embeddings from the same embedding model are compared.
+func FindSimilarCacheEntry(db *sql.DB, orgID, modelID, paramsHash, embeddingModel string, embedding []float32,
+	minSimilarity float64, since time.Time) (*models.SemanticCacheEntry, error) {
+	query := `
+		SELECT id, prompt_hash, response_body, created_at, 1 - (embedding <=> $5::vector) AS similarity
+		FROM semantic_cache
+		WHERE organization_id = $1 AND model_id = $2 AND params_hash = $3 AND embedding_model = $4
+		  AND vector_dims(embedding) = $6 AND created_at > $7
+		ORDER BY embedding <=> $5::vector
+		LIMIT 1`
+
+	entry := models.SemanticCacheEntry{OrganizationID: orgID, ModelID: modelID, ParamsHash: paramsHash, EmbeddingModel: embeddingModel}
+	err := db.QueryRow(query, orgID, modelID, paramsHash, embeddingModel, vectorLiteral(embedding), len(embedding), since).
+		Scan(&entry.ID, &entry.PromptHash, &entry.ResponseBody, &entry.CreatedAt, &entry.Similarity)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if entry.Similarity < minSimilarity {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// CreateSemanticCacheEntry stores a completion with its prompt embedding
+func CreateSemanticCacheEntry(db *sql.DB, entry models.SemanticCacheEntry, embedding []float32) error {
+	query := `
+		INSERT INTO semantic_cache (organization_id, model_id, embedding_model, prompt_hash, params_hash, embedding, response_body)
+		VALUES ($1, $2, $3, $4, $5, $6::vector, $7)`
+
+	_, err := db.Exec(query, entry.OrganizationID, entry.ModelID, entry.EmbeddingModel, entry.PromptHash, entry.ParamsHash,
+		vectorLiteral(embedding), entry.ResponseBody)
+	return err
+}
+
+// DeleteSemanticCacheEntriesBefore removes entries created before cutoff and returns how many
+// were removed
+func DeleteSemanticCacheEntriesBefore(db *sql.DB, cutoff time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM semantic_cache WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// vectorLiteral formats an embedding in pgvector's text form, e.g. [0.1,0.2]
+func vectorLiteral(embedding []float32) string {
+	var builder strings.Builder
+	builder.WriteByte('[')
+	for i, value := range embedding {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(strconv.FormatFloat(float64(value), 'g', -1, 32))
+	}
+	builder.WriteByte(']')
+	return builder.String()
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// ErrSetupComplete is returned once the install has users, memberships, its own organizations
+// or a System Admin; first-run setup then stays locked
+var ErrSetupComplete = errors.New("initial setup has already been completed")
+
+const (
+	systemAdminRoleID   = "00000000-0000-0000-0000-000000000001"
+	defaultOrganization = "00000000-0000-0000-0000-000000000001"
+
+	// pendingAzureOIDPrefix marks a user created before their first Azure AD sign-in;
+	// CreateOrUpdateUser swaps in the real object ID when they sign in
+	pendingAzureOIDPrefix = "pending:"
+
+	// setupLockKey serialises concurrent setup attempts
+	setupLockKey = 7263810452
+)
+
+// setupStateQuery reads the models.SetupState of the install
+const setupStateQuery = `
+	SELECT
+		EXISTS (
+			SELECT 1 FROM user_system_roles usr
+			JOIN roles r ON usr.role_id = r.id
+			WHERE r.is_system_role = true
+		),
+		EXISTS (SELECT 1 FROM users),
+		EXISTS (SELECT 1 FROM user_organizations),
+		EXISTS (SELECT 1 FROM organizations WHERE id <> $1)`
+
+// singleRowQuerier is satisfied by *sql.DB and *sql.Tx
+type singleRowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func getSetupState(q singleRowQuerier) (models.SetupState, error) {
+	var state models.SetupState
+	err := q.QueryRow(setupStateQuery, defaultOrganization).Scan(
+		&state.HasSystemAdmin, &state.HasUsers, &state.HasMemberships, &state.HasOtherOrganizations)
+	return state, err
+}
+
+// IsSetupRequired reports whether the install is fresh, so first-run setup is open
+func IsSetupRequired(db *sql.DB) (bool, error) {
+	state, err := getSetupState(db)
+	return state.Open(), err
+}
+
+// RunInitialSetup creates the first organization with its quota and makes the admin a System
+// Admin and an admin of the organization. The seeded Default Organization is renamed rather than
+// left beside the new one. Returns ErrSetupComplete unless the install is fresh.
+func RunInitialSetup(db *sql.DB, req models.SetupRequest) (orgID, userID string, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, setupLockKey); err != nil {
+		return "", "", err
+	}
+
+	state, err := getSetupState(tx)
+	if err != nil {
+		return "", "", err
+	}
+	if !state.Open() {
+		return "", "", ErrSetupComplete
+	}
+
+	azureOID := req.AdminAzureOID
+	if azureOID == "" {
+		azureOID = pendingAzureOIDPrefix + req.AdminEmail
+	}
+	err = tx.QueryRow(`
+		INSERT INTO users (azure_oid, email, name)
+		VALUES ($1, $2, $3)
+		RETURNING id`, azureOID, req.AdminEmail, req.AdminName).Scan(&userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = tx.QueryRow(`
+		UPDATE organizations SET name = $2, updated_at = NOW()
+		WHERE id = $1
+		RETURNING id`, defaultOrganization, req.OrganizationName).Scan(&orgID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`INSERT INTO organizations (name) VALUES ($1) RETURNING id`, req.OrganizationName).Scan(&orgID)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO organization_quotas (organization_id, total_quota, used_tokens)
+		VALUES ($1, $2, 0)
+		ON CONFLICT (organization_id) DO UPDATE SET total_quota = EXCLUDED.total_quota, updated_at = NOW()`,
+		orgID, req.TotalQuota)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_organizations (user_id, organization_id, role_name, source, created_by)
+		VALUES ($1, $2, 'admin', 'manual', $1)
+		ON CONFLICT (user_id, organization_id) DO UPDATE SET role_name = 'admin', source = 'manual'`,
+		userID, orgID)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_system_roles (user_id, role_id, created_by)
+		VALUES ($1, $2, $1)
+		ON CONFLICT (user_id, role_id) DO NOTHING`, userID, systemAdminRoleID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return orgID, userID, tx.Commit()
+}
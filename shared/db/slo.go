@@ -0,0 +1,217 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// SLO operations
+
+const sloColumns = `s.id, s.name, s.model_id, m.name, s.endpoint, s.organization_id, s.objective, s.target,
+	s.latency_threshold_ms, s.window_days, s.is_active, s.alert_level, s.alerted_at, s.created_by, s.created_at, s.updated_at`
+
+const sloFrom = ` FROM slos s LEFT JOIN models m ON m.id = s.model_id`
+
+func scanSLO(row interface{ Scan(...interface{}) error }) (*models.SLO, error) {
+	var s models.SLO
+	err := row.Scan(&s.ID, &s.Name, &s.ModelID, &s.ModelName, &s.Endpoint, &s.OrganizationID, &s.Objective, &s.Target,
+		&s.LatencyThresholdMs, &s.WindowDays, &s.IsActive, &s.AlertLevel, &s.AlertedAt, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSLOs returns every SLO, or only the active ones, by name
+func ListSLOs(db *sql.DB, activeOnly bool) ([]models.SLO, error) {
+	query := `SELECT ` + sloColumns + sloFrom
+	if activeOnly {
+		query += ` WHERE s.is_active = true`
+	}
+	rows, err := db.Query(query + ` ORDER BY s.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slos := []models.SLO{}
+	for rows.Next() {
+		s, err := scanSLO(rows)
+		if err != nil {
+			return nil, err
+		}
+		slos = append(slos, *s)
+	}
+	return slos, rows.Err()
+}
+
+// GetSLO returns the SLO, or sql.ErrNoRows if it doesn't exist
+func GetSLO(db *sql.DB, id string) (*models.SLO, error) {
+	return scanSLO(db.QueryRow(`SELECT `+sloColumns+sloFrom+` WHERE s.id = $1`, id))
+}
+
+// CreateSLO stores a validated SLO
+func CreateSLO(db *sql.DB, s models.SLO, createdBy string) (*models.SLO, error) {
+	var id string
+	err := db.QueryRow(`
+		INSERT INTO slos (name, model_id, endpoint, organization_id, objective, target, latency_threshold_ms,
+			window_days, is_active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::uuid)
+		RETURNING id`,
+		s.Name, s.ModelID, s.Endpoint, s.OrganizationID, s.Objective, s.Target, s.LatencyThresholdMs,
+		s.WindowDays, s.IsActive, createdBy).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return GetSLO(db, id)
+}
+
+// UpdateSLO saves a validated SLO's target, window and state, returning sql.ErrNoRows if it
+// doesn't exist. Deactivating it clears its alert.
+func UpdateSLO(db *sql.DB, s models.SLO) (*models.SLO, error) {
+	result, err := db.Exec(`
+		UPDATE slos SET name = $2, target = $3, latency_threshold_ms = $4, window_days = $5, is_active = $6,
+			alert_level = CASE WHEN $6 THEN alert_level ELSE '' END, updated_at = NOW()
+		WHERE id = $1`,
+		s.ID, s.Name, s.Target, s.LatencyThresholdMs, s.WindowDays, s.IsActive)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return GetSLO(db, s.ID)
+}
+
+// DeleteSLO removes the SLO, returning sql.ErrNoRows if it doesn't exist
+func DeleteSLO(db *sql.DB, id string) error {
+	result, err := db.Exec(`DELETE FROM slos WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetSLOAlertLevel records the burn rate alert raised for the SLO. It reports whether the level
+// changed, so that with several UI replicas evaluating SLOs only one of them sends the alert.
+func SetSLOAlertLevel(db *sql.DB, id, level string) (bool, error) {
+	result, err := db.Exec(`
+		UPDATE slos SET alert_level = $2::varchar, alerted_at = CASE WHEN $2::varchar = '' THEN alerted_at ELSE NOW() END
+		WHERE id = $1 AND alert_level <> $2::varchar`, id, level)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// sloFilter returns the conditions selecting the usage logs the SLO measures, the condition
+// marking a bad request among them, and their arguments, numbered from next
+func sloFilter(s *models.SLO, next int) (string, string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", next+len(args)-1)
+	}
+
+	if s.ModelID != nil {
+		conditions = append(conditions, "model_id = "+arg(*s.ModelID))
+	}
+	if s.Endpoint != "" {
+		conditions = append(conditions, "endpoint = "+arg(s.Endpoint))
+	}
+	if s.OrganizationID != nil {
+		conditions = append(conditions, "organization_id = "+arg(*s.OrganizationID))
+	}
+
+	// Latency is only measured on requests the provider answered; failures count against
+	// availability instead
+	bad := "response_status >= 500"
+	if s.Objective == models.SLOObjectiveLatency {
+		conditions = append(conditions, "response_status < 500", "response_time_ms IS NOT NULL")
+		threshold := 0
+		if s.LatencyThresholdMs != nil {
+			threshold = *s.LatencyThresholdMs
+		}
+		bad = "response_time_ms > " + arg(threshold)
+	}
+	return strings.Join(conditions, " AND "), bad, args
+}
+
+// GetSLOCounts counts the requests the SLO measures over its window and the burn rate alerts'
+// short windows, as of now
+func GetSLOCounts(db *sql.DB, s *models.SLO, now time.Time) (*models.SLOCounts, error) {
+	conditions, bad, filterArgs := sloFilter(s, 7)
+	query := fmt.Sprintf(`
+		SELECT
+			COUNT(*), COUNT(*) FILTER (WHERE %[1]s),
+			COUNT(*) FILTER (WHERE created_at >= $2), COUNT(*) FILTER (WHERE created_at >= $2 AND %[1]s),
+			COUNT(*) FILTER (WHERE created_at >= $3), COUNT(*) FILTER (WHERE created_at >= $3 AND %[1]s),
+			COUNT(*) FILTER (WHERE created_at >= $4), COUNT(*) FILTER (WHERE created_at >= $4 AND %[1]s),
+			COUNT(*) FILTER (WHERE created_at >= $5), COUNT(*) FILTER (WHERE created_at >= $5 AND %[1]s)
+		FROM usage_logs
+		WHERE created_at >= $1 AND created_at < $6 AND %[2]s`, bad, conditions)
+
+	args := []interface{}{
+		now.AddDate(0, 0, -s.WindowDays),
+		now.Add(-6 * time.Hour),
+		now.Add(-time.Hour),
+		now.Add(-30 * time.Minute),
+		now.Add(-5 * time.Minute),
+		now,
+	}
+	args = append(args, filterArgs...)
+
+	var c models.SLOCounts
+	err := db.QueryRow(query, args...).Scan(
+		&c.Window.Total, &c.Window.Bad,
+		&c.SixHours.Total, &c.SixHours.Bad,
+		&c.OneHour.Total, &c.OneHour.Bad,
+		&c.ThirtyMinutes.Total, &c.ThirtyMinutes.Bad,
+		&c.FiveMinutes.Total, &c.FiveMinutes.Bad)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetSLODays counts the requests the SLO measures on each day of its window, as of now, oldest
+// first. Days without traffic are left out.
+func GetSLODays(db *sql.DB, s *models.SLO, now time.Time) ([]models.SLODay, error) {
+	conditions, bad, filterArgs := sloFilter(s, 3)
+	query := fmt.Sprintf(`
+		SELECT date_trunc('day', created_at) AS day, COUNT(*), COUNT(*) FILTER (WHERE %s)
+		FROM usage_logs
+		WHERE created_at >= $1 AND created_at < $2 AND %s
+		GROUP BY day
+		ORDER BY day`, bad, conditions)
+
+	args := append([]interface{}{now.AddDate(0, 0, -s.WindowDays), now}, filterArgs...)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []models.SLODay{}
+	for rows.Next() {
+		var d models.SLODay
+		if err := rows.Scan(&d.Date, &d.Total, &d.Bad); err != nil {
+			return nil, err
+		}
+		if d.Total > 0 {
+			attainment := float64(d.Total-d.Bad) / float64(d.Total) * 100
+			d.Attainment = &attainment
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// slowQueryExplainEvery limits how often the plan of the same slow query is logged
+const slowQueryExplainEvery = 10 * time.Minute
+
+// openDB opens the connection pool. When the database credentials come from files, each new
+// connection uses their current values. With DB_SLOW_QUERY_MS set, statements taking longer than
+// that are logged along with their EXPLAIN plan.
+func openDB(dsn func() string) (*sql.DB, error) {
+	var connector driver.Connector
+	if credentialsFromFiles() {
+		connector = newCredentialConnector(dsn)
+	} else {
+		pqConnector, err := pq.NewConnector(dsn())
+		if err != nil {
+			return nil, err
+		}
+		connector = pqConnector
+	}
+
+	threshold := slowQueryThreshold()
+	if threshold == 0 {
+		return sql.OpenDB(connector), nil
+	}
+
+	logger := &slowQueryLogger{threshold: threshold, explained: make(map[string]time.Time)}
+	db := sql.OpenDB(&slowQueryConnector{Connector: connector, logger: logger})
+	logger.db = db
+	log.Printf("Logging queries slower than %v", threshold)
+	return db, nil
+}
+
+func slowQueryThreshold() time.Duration {
+	v := os.Getenv("DB_SLOW_QUERY_MS")
+	if v == "" {
+		return 0
+	}
+	ms, err := strconv.Atoi(v)
+	if err != nil || ms <= 0 {
+		log.Printf("Warning: ignoring invalid DB_SLOW_QUERY_MS %q", v)
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// slowQueryLogger logs statements over the threshold. Plans are fetched in the background, one at
+// a time, so a burst of slow queries doesn't add load to a database that is already struggling.
+type slowQueryLogger struct {
+	threshold time.Duration
+	db        *sql.DB
+
+	mu         sync.Mutex
+	explaining bool
+	explained  map[string]time.Time
+}
+
+func (l *slowQueryLogger) observe(query string, args []driver.NamedValue, start time.Time) {
+	elapsed := time.Since(start)
+	if elapsed < l.threshold {
+		return
+	}
+	log.Printf("Slow query (%v): %s", elapsed.Round(time.Millisecond), compactQuery(query))
+
+	if !explainable(query) {
+		return
+	}
+	l.mu.Lock()
+	if l.explaining || time.Since(l.explained[query]) < slowQueryExplainEvery {
+		l.mu.Unlock()
+		return
+	}
+	l.explaining = true
+	l.explained[query] = time.Now()
+	l.mu.Unlock()
+
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	go l.explain(query, values)
+}
+
+func (l *slowQueryLogger) explain(query string, args []interface{}) {
+	defer func() {
+		l.mu.Lock()
+		l.explaining = false
+		l.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	rows, err := l.db.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		log.Printf("Failed to explain slow query: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			log.Printf("Failed to read slow query plan: %v", err)
+			return
+		}
+		plan = append(plan, line)
+	}
+	log.Printf("Plan for slow query %s:\n%s", compactQuery(query), strings.Join(plan, "\n"))
+}
+
+// explainable reports whether EXPLAIN (without ANALYZE, so nothing is executed) applies to query
+func explainable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "INSERT", "UPDATE", "DELETE":
+		return true
+	}
+	return false
+}
+
+// compactQuery collapses the whitespace of a multi-line query onto one line
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// slowQueryConnector hands out connections that time their statements
+type slowQueryConnector struct {
+	driver.Connector
+	logger *slowQueryLogger
+}
+
+func (c *slowQueryConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &slowQueryConn{Conn: conn, logger: c.logger}, nil
+}
+
+// slowQueryConn times queries and execs run directly on the connection; prepared statements
+// are passed through untimed
+type slowQueryConn struct {
+	driver.Conn
+	logger *slowQueryLogger
+}
+
+func (c *slowQueryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err == nil {
+		c.logger.observe(query, args, start)
+	}
+	return rows, err
+}
+
+func (c *slowQueryConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	if err == nil {
+		c.logger.observe(query, args, start)
+	}
+	return result, err
+}
+
+func (c *slowQueryConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *slowQueryConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *slowQueryConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *slowQueryConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *slowQueryConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+const streamPacingColumns = `id, organization_id, endpoint, tokens_per_second, burst_tokens, is_active, created_by, created_at, updated_at`
+
+func scanStreamPacing(scanner interface{ Scan(...interface{}) error }) (*models.StreamPacing, error) {
+	var pacing models.StreamPacing
+	err := scanner.Scan(&pacing.ID, &pacing.OrganizationID, &pacing.Endpoint, &pacing.TokensPerSecond, &pacing.BurstTokens,
+		&pacing.IsActive, &pacing.CreatedBy, &pacing.CreatedAt, &pacing.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &pacing, nil
+}
+
+// GetStreamPacing returns the organization's stream pacing rules, by endpoint
+func GetStreamPacing(db *sql.DB, orgID string) ([]models.StreamPacing, error) {
+	rows, err := db.Query(`SELECT `+streamPacingColumns+` FROM stream_pacing WHERE organization_id = $1 ORDER BY endpoint`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.StreamPacing{}
+	for rows.Next() {
+		pacing, err := scanStreamPacing(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *pacing)
+	}
+
+	return rules, rows.Err()
+}
+
+// GetActiveStreamPacing returns the organization's active pacing for an endpoint, or nil when
+// its streams there aren't paced
+func GetActiveStreamPacing(db *sql.DB, orgID, endpoint string) (*models.StreamPacing, error) {
+	pacing, err := scanStreamPacing(db.QueryRow(`SELECT `+streamPacingColumns+` FROM stream_pacing
+		WHERE organization_id = $1 AND endpoint = $2 AND is_active = true`, orgID, endpoint))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return pacing, err
+}
+
+// SaveStreamPacing sets the organization's pacing for an endpoint, replacing any it had
+func SaveStreamPacing(db *sql.DB, orgID, createdBy string, req models.SaveStreamPacingRequest) (*models.StreamPacing, error) {
+	query := `
+		INSERT INTO stream_pacing (organization_id, endpoint, tokens_per_second, burst_tokens, is_active, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::uuid)
+		ON CONFLICT (organization_id, endpoint) DO UPDATE SET
+			tokens_per_second = EXCLUDED.tokens_per_second,
+			burst_tokens = EXCLUDED.burst_tokens,
+			is_active = EXCLUDED.is_active,
+			updated_at = NOW()
+		RETURNING ` + streamPacingColumns
+
+	return scanStreamPacing(db.QueryRow(query, orgID, req.Endpoint, req.TokensPerSecond, req.BurstTokens, *req.IsActive, createdBy))
+}
+
+// DeleteStreamPacing removes one of the organization's pacing rules, returning sql.ErrNoRows if
+// it has no such rule
+func DeleteStreamPacing(db *sql.DB, orgID, id string) error {
+	result, err := db.Exec(`DELETE FROM stream_pacing WHERE id = $1 AND organization_id = $2`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetDeploymentStats counts what opt-in telemetry reports: organizations, active models by
+// provider, and the last 24 hours' requests
+func GetDeploymentStats(db *sql.DB) (*models.DeploymentStats, error) {
+	stats := &models.DeploymentStats{ProviderModels: map[string]int{}}
+
+	var deploymentID sql.NullString
+	err := db.QueryRow(`
+		SELECT (SELECT id::text FROM organizations ORDER BY created_at, id LIMIT 1),
+		       (SELECT COUNT(*) FROM organizations),
+		       (SELECT COUNT(*) FROM usage_logs WHERE created_at >= NOW() - INTERVAL '24 hours')`).
+		Scan(&deploymentID, &stats.Organizations, &stats.Requests24h)
+	if err != nil {
+		return nil, err
+	}
+	stats.DeploymentID = deploymentID.String
+
+	rows, err := db.Query(`SELECT provider, COUNT(*) FROM models WHERE is_active = true GROUP BY provider`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var provider string
+		var count int
+		if err := rows.Scan(&provider, &count); err != nil {
+			return nil, err
+		}
+		stats.ProviderModels[strings.ToLower(provider)] += count
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Usage reconciliation operations
+
+// GetDailyModelUsage sums the gateway's usage_logs per UTC day and provider model ID for a
+// provider's models, from start to end inclusive (YYYY-MM-DD). Requests counts only successful
+// calls, as providers bill no others.
+func GetDailyModelUsage(db *sql.DB, provider, start, end string) ([]models.DailyModelUsage, error) {
+	query := `
+		SELECT to_char(ul.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, m.model_id,
+		       COALESCE(SUM(ul.prompt_tokens), 0), COALESCE(SUM(ul.completion_tokens), 0),
+		       COUNT(*) FILTER (WHERE ul.response_status < 400)
+		FROM usage_logs ul
+		JOIN models m ON ul.model_id = m.id
+		WHERE m.provider = $1
+		  AND ul.created_at >= ($2::date AT TIME ZONE 'UTC')
+		  AND ul.created_at < (($3::date + 1) AT TIME ZONE 'UTC')
+		GROUP BY day, m.model_id
+		ORDER BY day, m.model_id`
+
+	rows, err := db.Query(query, provider, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []models.DailyModelUsage{}
+	for rows.Next() {
+		var u models.DailyModelUsage
+		if err := rows.Scan(&u.Date, &u.Model, &u.PromptTokens, &u.CompletionTokens, &u.Requests); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// ReplaceUsageDiscrepancies stores a reconciliation run's discrepancies for a provider,
+// replacing those recorded earlier for the same days
+func ReplaceUsageDiscrepancies(db *sql.DB, provider, start, end string, discrepancies []models.UsageDiscrepancy) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM usage_discrepancies WHERE provider = $1 AND usage_date BETWEEN $2 AND $3`,
+		provider, start, end); err != nil {
+		return err
+	}
+
+	for _, d := range discrepancies {
+		_, err := tx.Exec(`
+			INSERT INTO usage_discrepancies (source, provider, usage_date, model, gateway_tokens, provider_tokens,
+			                                 gateway_requests, provider_requests, difference_pct)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			d.Source, provider, d.UsageDate, d.Model, d.GatewayTokens, d.ProviderTokens,
+			d.GatewayRequests, d.ProviderRequests, d.DifferencePct)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetUsageDiscrepancies lists recorded discrepancies, most recent days first
+func GetUsageDiscrepancies(db *sql.DB, limit int) ([]models.UsageDiscrepancy, error) {
+	query := `
+		SELECT id, source, provider, to_char(usage_date, 'YYYY-MM-DD'), model, gateway_tokens, provider_tokens,
+		       gateway_requests, provider_requests, difference_pct, created_at
+		FROM usage_discrepancies
+		ORDER BY usage_date DESC, provider, model
+		LIMIT $1`
+
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discrepancies := []models.UsageDiscrepancy{}
+	for rows.Next() {
+		var d models.UsageDiscrepancy
+		if err := rows.Scan(&d.ID, &d.Source, &d.Provider, &d.UsageDate, &d.Model, &d.GatewayTokens, &d.ProviderTokens,
+			&d.GatewayRequests, &d.ProviderRequests, &d.DifferencePct, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, rows.Err()
+}
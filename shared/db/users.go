@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// SetUserActive deactivates or reactivates a user. Deactivated users can't sign in but keep
+// their memberships and API keys.
+func SetUserActive(db *sql.DB, userID string, active bool) error {
+	result, err := db.Exec(`UPDATE users SET is_active = $2, updated_at = NOW() WHERE id = $1`, userID, active)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// IsUserDeactivated reports whether the account for email exists but has been deactivated
+func IsUserDeactivated(db *sql.DB, email string) (bool, error) {
+	var deactivated bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND is_active = false)`, email).Scan(&deactivated)
+	return deactivated, err
+}
+
+// GetUserOrganizationRole returns the user's role in an organization, or sql.ErrNoRows if not a member
+func GetUserOrganizationRole(db *sql.DB, userID, orgID string) (string, error) {
+	var role string
+	err := db.QueryRow(`
+		SELECT role_name FROM user_organizations
+		WHERE user_id = $1 AND organization_id = $2`, userID, orgID).Scan(&role)
+	return role, err
+}
+
+// CountOrganizationAdmins returns how many active users other than excludeUserID administer an organization
+func CountOrganizationAdmins(db *sql.DB, orgID, excludeUserID string) (int, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*)
+		FROM user_organizations uo
+		JOIN users u ON uo.user_id = u.id
+		WHERE uo.organization_id = $1 AND uo.role_name = 'admin' AND u.is_active = true
+		  AND uo.user_id <> $2`, orgID, excludeUserID).Scan(&count)
+	return count, err
+}
+
+// UpdateUserOrganizationRole changes an existing member's role. The membership becomes manual
+// so the next AD group sync doesn't revert the change.
+func UpdateUserOrganizationRole(db *sql.DB, userID, orgID, roleName string) error {
+	result, err := db.Exec(`
+		UPDATE user_organizations SET role_name = $3, source = 'manual'
+		WHERE user_id = $1 AND organization_id = $2`, userID, orgID, roleName)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return bumpPermissionsVersion(db, userID)
+}
+
+// SetMembershipSource pins a membership (manual) or returns it to AD group sync (ad_sync), which
+// removes it at the user's next sign-in if they're no longer in a mapped group
+func SetMembershipSource(db *sql.DB, userID, orgID, source string) error {
+	result, err := db.Exec(`
+		UPDATE user_organizations SET source = $3
+		WHERE user_id = $1 AND organization_id = $2`, userID, orgID, source)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RemoveUserFromOrganization deletes a membership. AD-synced memberships come back at the
+// user's next sign-in while they remain in a mapped group.
+func RemoveUserFromOrganization(db *sql.DB, userID, orgID string) error {
+	result, err := db.Exec(`DELETE FROM user_organizations WHERE user_id = $1 AND organization_id = $2`, userID, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return bumpPermissionsVersion(db, userID)
+}
+
+// DeleteUser permanently removes a user. Memberships go with them; records they created
+// (API keys, experiments, grants) are kept with the creator cleared.
+func DeleteUser(db *sql.DB, userID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`UPDATE user_organizations SET created_by = NULL WHERE created_by = $1`,
+		`UPDATE user_system_roles SET created_by = NULL WHERE created_by = $1`,
+		`UPDATE api_keys SET created_by_user_id = NULL WHERE created_by_user_id = $1`,
+		`UPDATE api_keys SET owner_user_id = NULL WHERE owner_user_id = $1`,
+		`UPDATE experiments SET created_by = NULL WHERE created_by = $1`,
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Vector collection operations. Like the semantic cache, the tables need pgvector and are created
+// by the gateway when the vector store is turned on rather than by schema.sql.
+
+// EnsureVectorStoreSchema installs pgvector and creates the vector collection tables
+func EnsureVectorStoreSchema(db *sql.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		`CREATE TABLE IF NOT EXISTS vector_collections (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			name VARCHAR(64) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			dimensions INTEGER NOT NULL,
+			embedding_model VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(organization_id, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS vector_documents (
+			collection_id UUID NOT NULL REFERENCES vector_collections(id) ON DELETE CASCADE,
+			id VARCHAR(255) NOT NULL,
+			text TEXT NOT NULL DEFAULT '',
+			metadata JSONB NOT NULL DEFAULT '{}',
+			embedding vector NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (collection_id, id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_vector_documents_metadata ON vector_documents USING GIN (metadata)`,
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrVectorCollectionNameTaken is returned when the organization already has a collection by
+// that name
+var ErrVectorCollectionNameTaken = errors.New("a vector collection with this name already exists")
+
+const vectorCollectionSelect = `
+	SELECT c.id, c.organization_id, c.name, c.description, c.dimensions, c.embedding_model,
+	       (SELECT COUNT(*) FROM vector_documents d WHERE d.collection_id = c.id), c.created_at, c.updated_at
+	FROM vector_collections c`
+
+func scanVectorCollection(row interface{ Scan(...interface{}) error }) (*models.VectorCollection, error) {
+	var vc models.VectorCollection
+	err := row.Scan(&vc.ID, &vc.OrganizationID, &vc.Name, &vc.Description, &vc.Dimensions, &vc.EmbeddingModel,
+		&vc.DocumentCount, &vc.CreatedAt, &vc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &vc, nil
+}
+
+// CreateVectorCollection stores a new collection and fills in its ID and timestamps
+func CreateVectorCollection(db *sql.DB, collection *models.VectorCollection) error {
+	query := `
+		INSERT INTO vector_collections (organization_id, name, description, dimensions, embedding_model)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`
+
+	err := db.QueryRow(query, collection.OrganizationID, collection.Name, collection.Description, collection.Dimensions,
+		collection.EmbeddingModel).Scan(&collection.ID, &collection.CreatedAt, &collection.UpdatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrVectorCollectionNameTaken
+	}
+	return err
+}
+
+// GetVectorCollections lists an organization's collections by name
+func GetVectorCollections(db *sql.DB, orgID string) ([]models.VectorCollection, error) {
+	rows, err := db.Query(vectorCollectionSelect+` WHERE c.organization_id = $1 ORDER BY c.name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []models.VectorCollection{}
+	for rows.Next() {
+		vc, err := scanVectorCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, *vc)
+	}
+	return collections, rows.Err()
+}
+
+// GetVectorCollection returns one of the organization's collections by name
+func GetVectorCollection(db *sql.DB, orgID, name string) (*models.VectorCollection, error) {
+	return scanVectorCollection(db.QueryRow(vectorCollectionSelect+` WHERE c.organization_id = $1 AND c.name = $2`, orgID, name))
+}
+
+// DeleteVectorCollection removes a collection and its documents
+func DeleteVectorCollection(db *sql.DB, collectionID string) error {
+	_, err := db.Exec(`DELETE FROM vector_collections WHERE id = $1`, collectionID)
+	return err
+}
+
+// UpsertVectorDocuments stores documents in a collection in one transaction, replacing those
+// with the same IDs
+func UpsertVectorDocuments(db *sql.DB, collectionID string, documents []models.VectorDocument) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO vector_documents (collection_id, id, text, metadata, embedding)
+		VALUES ($1, $2, $3, $4, $5::vector)
+		ON CONFLICT (collection_id, id) DO UPDATE SET
+			text = EXCLUDED.text, metadata = EXCLUDED.metadata, embedding = EXCLUDED.embedding,
+			updated_at = CURRENT_TIMESTAMP`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, doc := range documents {
+		metadata := []byte("{}")
+		if len(doc.Metadata) > 0 {
+			if metadata, err = json.Marshal(doc.Metadata); err != nil {
+				return err
+			}
+		}
+		if _, err := stmt.Exec(collectionID, doc.ID, doc.Text, metadata, vectorLiteral(doc.Embedding)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE vector_collections SET updated_at = CURRENT_TIMESTAMP WHERE id = $1`, collectionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteVectorDocument removes a document from a collection, returning sql.ErrNoRows if it
+// isn't there
+func DeleteVectorDocument(db *sql.DB, collectionID, documentID string) error {
+	result, err := db.Exec(`DELETE FROM vector_documents WHERE collection_id = $1 AND id = $2`, collectionID, documentID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// QueryVectorDocuments returns the topK documents of a collection closest to embedding by cosine
+// similarity, best first. filter keeps documents whose metadata contains it, and minScore, when
+// set, drops matches less similar than it.
+func QueryVectorDocuments(db *sql.DB, collectionID string, embedding []float32, topK int, filter map[string]interface{},
+	minScore *float64, includeEmbeddings bool) ([]models.VectorQueryMatch, error) {
+	filterJSON := []byte("{}")
+	if len(filter) > 0 {
+		var err error
+		if filterJSON, err = json.Marshal(filter); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		SELECT id, text, metadata, CASE WHEN $5 THEN embedding::text ELSE '' END, created_at, updated_at, 1 - (embedding <=> $2::vector) AS score
+		FROM vector_documents
+		WHERE collection_id = $1 AND metadata @> $3::jsonb
+		ORDER BY embedding <=> $2::vector
+		LIMIT $4`
+
+	rows, err := db.Query(query, collectionID, vectorLiteral(embedding), filterJSON, topK, includeEmbeddings)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := []models.VectorQueryMatch{}
+	for rows.Next() {
+		var m models.VectorQueryMatch
+		var metadata []byte
+		var vector string
+		if err := rows.Scan(&m.ID, &m.Text, &metadata, &vector, &m.CreatedAt, &m.UpdatedAt, &m.Score); err != nil {
+			return nil, err
+		}
+		if minScore != nil && m.Score < *minScore {
+			break
+		}
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &m.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if includeEmbeddings {
+			// pgvector's text form is a JSON array
+			if err := json.Unmarshal([]byte(vector), &m.Embedding); err != nil {
+				return nil, err
+			}
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
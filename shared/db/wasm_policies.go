@@ -0,0 +1,114 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// WASM policy operations. Listings leave the module out; only the gateway loads it.
+
+const wasmPolicyColumns = `id, organization_id, name, sha256, octet_length(module), fuel_limit, max_memory_pages, is_active, created_by, created_at, updated_at`
+
+func scanWasmPolicy(row interface{ Scan(...interface{}) error }) (*models.WasmPolicy, error) {
+	var p models.WasmPolicy
+	if err := row.Scan(&p.ID, &p.OrganizationID, &p.Name, &p.SHA256, &p.SizeBytes, &p.FuelLimit,
+		&p.MaxMemoryPages, &p.IsActive, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func queryWasmPolicies(db *sql.DB, query string, args ...interface{}) ([]models.WasmPolicy, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []models.WasmPolicy{}
+	for rows.Next() {
+		p, err := scanWasmPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *p)
+	}
+
+	return policies, rows.Err()
+}
+
+func moduleDigest(module []byte) string {
+	sum := sha256.Sum256(module)
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateWasmPolicy stores an organization's policy module
+func CreateWasmPolicy(db *sql.DB, orgID, createdBy string, req models.CreateWasmPolicyRequest) (*models.WasmPolicy, error) {
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	return scanWasmPolicy(db.QueryRow(`
+		INSERT INTO wasm_policies (organization_id, name, module, sha256, fuel_limit, max_memory_pages, is_active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING `+wasmPolicyColumns,
+		orgID, req.Name, req.Module, moduleDigest(req.Module), req.FuelLimit, req.MaxMemoryPages, isActive, createdBy))
+}
+
+// GetWasmPolicies returns an organization's policies in the order they run
+func GetWasmPolicies(db *sql.DB, orgID string) ([]models.WasmPolicy, error) {
+	return queryWasmPolicies(db, `SELECT `+wasmPolicyColumns+` FROM wasm_policies WHERE organization_id = $1 ORDER BY created_at, id`, orgID)
+}
+
+// GetActiveWasmPolicies returns every organization's active policies in the order they run
+func GetActiveWasmPolicies(db *sql.DB) ([]models.WasmPolicy, error) {
+	return queryWasmPolicies(db, `SELECT `+wasmPolicyColumns+` FROM wasm_policies WHERE is_active = true ORDER BY organization_id, created_at, id`)
+}
+
+// GetWasmPolicyModule returns a policy's module
+func GetWasmPolicyModule(db *sql.DB, policyID string) ([]byte, error) {
+	var module []byte
+	err := db.QueryRow(`SELECT module FROM wasm_policies WHERE id = $1`, policyID).Scan(&module)
+	return module, err
+}
+
+// UpdateWasmPolicy applies the set fields of req to one of an organization's policies. Any change
+// bumps updated_at, which is how the gateway notices it should reload the module.
+func UpdateWasmPolicy(db *sql.DB, orgID, policyID string, req models.UpdateWasmPolicyRequest) (*models.WasmPolicy, error) {
+	// A nil []byte isn't sent as NULL, so keep the module out of the arguments unless replaced
+	var module, digest interface{}
+	if req.Module != nil {
+		module, digest = req.Module, moduleDigest(req.Module)
+	}
+
+	return scanWasmPolicy(db.QueryRow(`
+		UPDATE wasm_policies
+		SET name = COALESCE($3, name),
+		    module = COALESCE($4, module),
+		    sha256 = COALESCE($5, sha256),
+		    fuel_limit = COALESCE($6, fuel_limit),
+		    max_memory_pages = COALESCE($7, max_memory_pages),
+		    is_active = COALESCE($8, is_active),
+		    updated_at = NOW()
+		WHERE organization_id = $1 AND id = $2
+		RETURNING `+wasmPolicyColumns,
+		orgID, policyID, req.Name, module, digest, req.FuelLimit, req.MaxMemoryPages, req.IsActive))
+}
+
+// DeleteWasmPolicy removes one of an organization's policies
+func DeleteWasmPolicy(db *sql.DB, orgID, policyID string) error {
+	result, err := db.Exec(`DELETE FROM wasm_policies WHERE organization_id = $1 AND id = $2`, orgID, policyID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
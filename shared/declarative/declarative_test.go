@@ -0,0 +1,110 @@
+package declarative
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "orgs.yaml", `
+organizations:
+  - name: Acme
+    total_quota: 1000000
+  - name: Acme Research
+    parent: Acme
+`)
+	writeFile(t, dir, "models.json", `{"models": [{"name": "GPT-4o", "provider": "openai", "model_id": "gpt-4o",
+		"api_token_env": "OPENAI_API_KEY", "metadata": {"context_window": 128000, "tags": ["chat"]},
+		"organizations": ["Acme"]}]}`)
+	writeFile(t, dir, "README.md", "not config")
+	// Kubernetes keeps the real files behind hidden links in a mounted ConfigMap
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0o755))
+	writeFile(t, filepath.Join(dir, "..data"), "orgs.yaml", "organizations: [{name: Hidden}]")
+
+	config, digest, err := Load(dir)
+	require.NoError(t, err)
+	require.Len(t, config.Organizations, 2)
+	assert.Equal(t, "Acme Research", config.Organizations[1].Name)
+	assert.Equal(t, "Acme", config.Organizations[1].Parent)
+	assert.Equal(t, 1000000, config.Organizations[0].TotalQuota)
+	require.Len(t, config.Models, 1)
+	model := config.Models[0]
+	assert.Equal(t, "OPENAI_API_KEY", model.APITokenEnv)
+	require.NotNil(t, model.Metadata)
+	require.NotNil(t, model.Metadata.ContextWindow)
+	assert.Equal(t, 128000, *model.Metadata.ContextWindow)
+	assert.Equal(t, []string{"chat"}, model.Metadata.Tags)
+	assert.Equal(t, []string{"Acme"}, model.Organizations)
+
+	_, same, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, digest, same)
+
+	writeFile(t, dir, "orgs.yaml", "organizations: [{name: Acme}]")
+	_, changed, err := Load(dir)
+	require.NoError(t, err)
+	assert.NotEqual(t, digest, changed)
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown field": "organizations: [{name: Acme, quota: 5}]",
+		"invalid yaml":  "organizations: [",
+		"invalid":       "models: [{name: GPT-4o}]",
+	} {
+		dir := t.TempDir()
+		writeFile(t, dir, "config.yaml", content)
+		_, _, err := Load(dir)
+		assert.Error(t, err, name)
+	}
+
+	_, _, err := Load(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestModelRequests(t *testing.T) {
+	cost, timeout, inactive := 2.5, 30, false
+	spec := models.DeclaredModelSpec{
+		Name:            "Llama",
+		Provider:        models.ProviderOllama,
+		ModelID:         "llama3",
+		APIEndpoint:     "http://ollama:11434",
+		APITokenEnv:     "LLAMA_TOKEN",
+		OutputCostPer1M: &cost,
+		TimeoutSeconds:  &timeout,
+		Active:          &inactive,
+	}
+	env := map[string]string{"LLAMA_TOKEN": "secret-token"}
+
+	fields, err := resolveModelFields(spec, func(name string) string { return env[name] })
+	require.NoError(t, err)
+
+	create, err := createModelRequest(spec, fields)
+	require.NoError(t, err)
+	assert.Equal(t, "secret-token", *create.APIToken)
+	assert.Equal(t, "http://ollama:11434", *create.APIEndpoint)
+	assert.Equal(t, "2.5", *create.OutputCostPer1M)
+	assert.Equal(t, "0", *create.InputCostPer1M, "self-hosted models are free unless priced")
+	assert.Equal(t, "30", *create.TimeoutSeconds)
+	assert.Nil(t, create.AWSSecretKey)
+
+	update, err := updateModelRequest(spec, fields)
+	require.NoError(t, err)
+	assert.False(t, *update.IsActive)
+	assert.Nil(t, update.MaxRetries, "fields left out keep what's stored")
+
+	_, err = resolveModelFields(spec, func(string) string { return "" })
+	assert.ErrorContains(t, err, "LLAMA_TOKEN")
+}
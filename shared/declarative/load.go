@@ -0,0 +1,127 @@
+// Package declarative reconciles organizations and models declared in files into the database,
+// so a Kubernetes deployment can manage them from a ConfigMap with GitOps rather than through
+// the admin APIs.
+package declarative
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+	"gopkg.in/yaml.v2"
+)
+
+// Load reads every .yaml, .yml and .json file in dir, in name order, into one config, and
+// returns a digest of them that changes whenever any does. Hidden entries are skipped, which
+// leaves out the ..data links Kubernetes keeps in a mounted ConfigMap.
+func Load(dir string) (*models.DeclarativeConfig, string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".yaml", ".yml", ".json":
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	config := &models.DeclarativeConfig{}
+	digest := sha256.New()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if info.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(digest, "%s\x00%d\x00", name, len(data))
+		digest.Write(data)
+
+		var part models.DeclarativeConfig
+		if err := decode(data, &part); err != nil {
+			return nil, "", fmt.Errorf("%s: %w", name, err)
+		}
+		config.Organizations = append(config.Organizations, part.Organizations...)
+		config.Models = append(config.Models, part.Models...)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, "", err
+	}
+	return config, hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// decode parses YAML, or JSON as the YAML subset it is, through JSON so the config shares the
+// models' json field names. Unknown fields are refused so a typo doesn't go unnoticed.
+func decode(data []byte, into *models.DeclarativeConfig) error {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		return nil // Empty file
+	}
+	converted, err := jsonCompatible(raw)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(converted)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(into)
+}
+
+// jsonCompatible turns the map[interface{}]interface{} maps YAML decodes into string-keyed ones
+func jsonCompatible(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v must be a string", key)
+			}
+			converted, err := jsonCompatible(value)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = converted
+		}
+		return m, nil
+	case []interface{}:
+		for i, item := range v {
+			converted, err := jsonCompatible(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,435 @@
+package declarative
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+	"github.com/like-mike/relai-gateway/shared/gcpauth"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Result counts what a reconcile changed
+type Result struct {
+	Created  int
+	Updated  int // Already managed, and brought up to date
+	Adopted  int // Existing resources the config took over by name
+	Pruned   int // Deactivated because they were removed from the config
+	Released int // Removed from the config and handed back to the admin console
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d adopted, %d pruned, %d released",
+		r.Created, r.Updated, r.Adopted, r.Pruned, r.Released)
+}
+
+// Reconcile makes the database match the config. Resources are matched by name: one the config
+// already manages is updated, an existing one of the same name is adopted, and otherwise it is
+// created. A resource dropped from the config is deactivated when prune is set, and otherwise
+// left as it is for the admin console to manage. A resource that fails doesn't stop the others;
+// their errors are returned together.
+func Reconcile(conn *sql.DB, config *models.DeclarativeConfig, prune bool) (Result, error) {
+	var result Result
+	var errs []error
+
+	orgIDs, err := reconcileOrganizations(conn, config.Organizations, prune, &result, &errs)
+	if err != nil {
+		return result, err
+	}
+	if err := reconcileModels(conn, config.Models, orgIDs, prune, &result, &errs); err != nil {
+		return result, err
+	}
+	return result, errors.Join(errs...)
+}
+
+// reconcileOrganizations applies the declared organizations, parents first, and returns their
+// IDs by name. Only a failure to read what the config manages is returned; the rest is
+// collected in errs.
+func reconcileOrganizations(conn *sql.DB, specs []models.DeclaredOrganizationSpec, prune bool, result *Result, errs *[]error) (map[string]string, error) {
+	declared, err := db.GetDeclaredResources(conn, models.DeclaredOrganization)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]string{}
+	for _, spec := range specs {
+		id, err := applyOrganization(conn, spec, declared[spec.Name], ids, result)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("organization %q: %w", spec.Name, err))
+			continue
+		}
+		ids[spec.Name] = id
+	}
+
+	release(conn, models.DeclaredOrganization, declared, ids, prune, db.DeactivateOrganization, result, errs)
+	return ids, nil
+}
+
+func applyOrganization(conn *sql.DB, spec models.DeclaredOrganizationSpec, id string, ids map[string]string, result *Result) (string, error) {
+	active := spec.Active == nil || *spec.Active
+	parentID := ""
+	if spec.Parent != "" {
+		if parentID = ids[spec.Parent]; parentID == "" {
+			return "", fmt.Errorf("parent %q wasn't applied", spec.Parent)
+		}
+	}
+
+	if id != "" {
+		if err := db.UpdateDeclaredOrganization(conn, id, spec, active); err == sql.ErrNoRows {
+			id = "" // Deleted since; create it again
+		} else if err != nil {
+			return "", err
+		} else {
+			result.Updated++
+		}
+	}
+	if id == "" {
+		existing, err := db.FindOrganizationIDByName(conn, spec.Name)
+		if err != nil {
+			return "", err
+		}
+		if existing != "" {
+			if err := db.UpdateDeclaredOrganization(conn, existing, spec, active); err != nil {
+				return "", err
+			}
+			id = existing
+			result.Adopted++
+			log.Printf("Declarative config adopted existing organization %q", spec.Name)
+		} else {
+			if id, err = db.CreateOrganization(conn, spec.Name, spec.Description, parentID, spec.TotalQuota); err != nil {
+				return "", err
+			}
+			if !active {
+				if err := db.DeactivateOrganization(conn, id); err != nil {
+					return "", err
+				}
+			}
+			result.Created++
+		}
+	}
+
+	currentParent, err := db.GetOrganizationParentID(conn, id)
+	if err != nil {
+		return "", err
+	}
+	if currentParent != parentID {
+		if err := db.SetOrganizationParent(conn, id, parentID); err != nil {
+			return "", err
+		}
+	}
+
+	return id, db.SetDeclaredResource(conn, models.DeclaredOrganization, spec.Name, id)
+}
+
+// reconcileModels applies the declared models and the organizations that may use them
+func reconcileModels(conn *sql.DB, specs []models.DeclaredModelSpec, orgIDs map[string]string, prune bool, result *Result, errs *[]error) error {
+	declared, err := db.GetDeclaredResources(conn, models.DeclaredModel)
+	if err != nil {
+		return err
+	}
+
+	ids := map[string]string{}
+	for _, spec := range specs {
+		id, err := applyModel(conn, spec, declared[spec.Name], orgIDs, result)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("model %q: %w", spec.Name, err))
+			continue
+		}
+		ids[spec.Name] = id
+	}
+
+	release(conn, models.DeclaredModel, declared, ids, prune, db.DeleteModel, result, errs)
+	return nil
+}
+
+func applyModel(conn *sql.DB, spec models.DeclaredModelSpec, id string, orgIDs map[string]string, result *Result) (string, error) {
+	access, err := accessOrgIDs(conn, spec.Organizations, orgIDs)
+	if err != nil {
+		return "", err
+	}
+	fields, err := resolveModelFields(spec, os.Getenv)
+	if err != nil {
+		return "", err
+	}
+	update, err := updateModelRequest(spec, fields)
+	if err != nil {
+		return "", err
+	}
+
+	if id != "" {
+		if _, err := db.UpdateModel(conn, id, update); err == sql.ErrNoRows {
+			id = "" // Deleted since; create it again
+		} else if err != nil {
+			return "", err
+		} else {
+			result.Updated++
+		}
+	}
+	if id == "" {
+		existing, err := db.FindModelIDByName(conn, spec.Name)
+		if err != nil {
+			return "", err
+		}
+		if existing != "" {
+			if _, err := db.UpdateModel(conn, existing, update); err != nil {
+				return "", err
+			}
+			id = existing
+			result.Adopted++
+			log.Printf("Declarative config adopted existing model %q", spec.Name)
+		} else {
+			create, err := createModelRequest(spec, fields)
+			if err != nil {
+				return "", err
+			}
+			model, err := db.CreateModel(conn, create)
+			if err != nil {
+				return "", err
+			}
+			id = model.ID
+			if update.IsActive != nil && !*update.IsActive {
+				if err := db.DeleteModel(conn, id); err != nil {
+					return "", err
+				}
+			}
+			result.Created++
+		}
+	}
+
+	if err := setModelAccess(conn, id, access); err != nil {
+		return "", err
+	}
+	return id, db.SetDeclaredResource(conn, models.DeclaredModel, spec.Name, id)
+}
+
+// accessOrgIDs resolves the names of the organizations a model is shared with; they may be
+// declared or managed in the console
+func accessOrgIDs(conn *sql.DB, names []string, orgIDs map[string]string) (map[string]bool, error) {
+	access := map[string]bool{}
+	for _, name := range names {
+		id := orgIDs[name]
+		if id == "" {
+			var err error
+			if id, err = db.FindOrganizationIDByName(conn, name); err != nil {
+				return nil, err
+			}
+			if id == "" {
+				return nil, fmt.Errorf("organization %q not found", name)
+			}
+		}
+		access[id] = true
+	}
+	return access, nil
+}
+
+// setModelAccess grants exactly the given organizations direct access to the model
+func setModelAccess(conn *sql.DB, modelID string, access map[string]bool) error {
+	model, err := db.GetModelWithOrganizations(conn, modelID)
+	if err != nil {
+		return err
+	}
+
+	var changes []db.ModelAccessChange
+	current := map[string]bool{}
+	for _, org := range model.Organizations {
+		current[org.ID] = true
+		if !access[org.ID] {
+			changes = append(changes, db.ModelAccessChange{OrgID: org.ID, Action: "remove"})
+		}
+	}
+	for orgID := range access {
+		if !current[orgID] {
+			changes = append(changes, db.ModelAccessChange{OrgID: orgID, Action: "add"})
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return db.ManageModelAccess(conn, modelID, changes)
+}
+
+// release deals with resources dropped from the config: deactivated when pruning, and in
+// either case no longer managed by it
+func release(conn *sql.DB, kind string, declared, applied map[string]string, prune bool,
+	deactivate func(*sql.DB, string) error, result *Result, errs *[]error) {
+	for name, id := range declared {
+		if _, ok := applied[name]; ok {
+			continue
+		}
+		if prune {
+			if err := deactivate(conn, id); err != nil {
+				*errs = append(*errs, fmt.Errorf("%s %q: %w", kind, name, err))
+				continue
+			}
+			result.Pruned++
+			log.Printf("Declarative config deactivated %s %q, removed from the config", kind, name)
+		} else {
+			result.Released++
+		}
+		if err := db.DeleteDeclaredResource(conn, kind, name); err != nil {
+			*errs = append(*errs, fmt.Errorf("%s %q: %w", kind, name, err))
+		}
+	}
+}
+
+// modelFields is what create and update requests share, resolved from the spec
+type modelFields struct {
+	description, apiEndpoint, apiToken                     *string
+	awsRegion, awsAccessKeyID, awsSecretKey, awsRoleARN    *string
+	gcpLocation, gcpServiceAccount                         *string
+	inputCost, outputCost, maxRetries, timeout, retryDelay *string
+	backoffMultiplier, maxRequestBytes                     *string
+}
+
+// resolveModelFields reads the spec's credentials from the environment and seals those stored
+// encrypted. Fields the spec leaves out stay nil so updates keep what's stored, including
+// credentials entered in the console.
+func resolveModelFields(spec models.DeclaredModelSpec, getenv func(string) string) (*modelFields, error) {
+	f := &modelFields{
+		description:    &spec.Description,
+		apiEndpoint:    optional(spec.APIEndpoint),
+		awsRegion:      optional(spec.AWSRegion),
+		awsAccessKeyID: optional(spec.AWSAccessKeyID),
+		awsRoleARN:     optional(spec.AWSRoleARN),
+		gcpLocation:    optional(spec.GCPLocation),
+	}
+	if spec.InputCostPer1M != nil {
+		f.inputCost = formatFloat(*spec.InputCostPer1M)
+	}
+	if spec.OutputCostPer1M != nil {
+		f.outputCost = formatFloat(*spec.OutputCostPer1M)
+	}
+	if spec.BackoffMultiplier != nil {
+		f.backoffMultiplier = formatFloat(*spec.BackoffMultiplier)
+	}
+	f.maxRetries = formatInt(spec.MaxRetries)
+	f.timeout = formatInt(spec.TimeoutSeconds)
+	f.retryDelay = formatInt(spec.RetryDelayMs)
+	f.maxRequestBytes = formatInt(spec.MaxRequestBytes)
+
+	var err error
+	if f.apiToken, err = fromEnv(spec.APITokenEnv, getenv); err != nil {
+		return nil, err
+	}
+	if f.awsSecretKey, err = fromEnv(spec.AWSSecretKeyEnv, getenv); err != nil {
+		return nil, err
+	}
+	if f.gcpServiceAccount, err = fromEnv(spec.GCPServiceAccountEnv, getenv); err != nil {
+		return nil, err
+	}
+	if f.gcpServiceAccount != nil {
+		if _, err := gcpauth.ParseServiceAccount([]byte(*f.gcpServiceAccount)); err != nil {
+			return nil, fmt.Errorf("invalid gcp_service_account: %w", err)
+		}
+	}
+	for _, secret := range []*string{f.awsSecretKey, f.gcpServiceAccount} {
+		if secret == nil {
+			continue
+		}
+		sealed, err := encryption.Encrypt(*secret)
+		if err == encryption.ErrNoKey {
+			return nil, errors.New("ENCRYPTION_KEY must be set to store provider credentials")
+		} else if err != nil {
+			return nil, err
+		}
+		*secret = sealed
+	}
+	return f, nil
+}
+
+func createModelRequest(spec models.DeclaredModelSpec, f *modelFields) (models.CreateModelRequest, error) {
+	req := models.CreateModelRequest{
+		Name:              spec.Name,
+		Description:       f.description,
+		Provider:          spec.Provider,
+		ModelID:           spec.ModelID,
+		APIEndpoint:       f.apiEndpoint,
+		APIToken:          f.apiToken,
+		AWSRegion:         f.awsRegion,
+		AWSAccessKeyID:    f.awsAccessKeyID,
+		AWSSecretKey:      f.awsSecretKey,
+		AWSRoleARN:        f.awsRoleARN,
+		GCPLocation:       f.gcpLocation,
+		GCPServiceAccount: f.gcpServiceAccount,
+		InputCostPer1M:    f.inputCost,
+		OutputCostPer1M:   f.outputCost,
+		MaxRetries:        f.maxRetries,
+		TimeoutSeconds:    f.timeout,
+		RetryDelayMs:      f.retryDelay,
+		BackoffMultiplier: f.backoffMultiplier,
+		MaxRequestBytes:   f.maxRequestBytes,
+		Metadata:          spec.Metadata,
+		Transport:         spec.Transport,
+	}
+	if err := req.Validate(); err != nil {
+		return req, err
+	}
+	req.ApplyProviderDefaults()
+	return req, nil
+}
+
+func updateModelRequest(spec models.DeclaredModelSpec, f *modelFields) (models.UpdateModelRequest, error) {
+	active := spec.Active == nil || *spec.Active
+	req := models.UpdateModelRequest{
+		Name:              &spec.Name,
+		Description:       f.description,
+		Provider:          &spec.Provider,
+		ModelID:           &spec.ModelID,
+		APIEndpoint:       f.apiEndpoint,
+		APIToken:          f.apiToken,
+		AWSRegion:         f.awsRegion,
+		AWSAccessKeyID:    f.awsAccessKeyID,
+		AWSSecretKey:      f.awsSecretKey,
+		AWSRoleARN:        f.awsRoleARN,
+		GCPLocation:       f.gcpLocation,
+		GCPServiceAccount: f.gcpServiceAccount,
+		InputCostPer1M:    f.inputCost,
+		OutputCostPer1M:   f.outputCost,
+		MaxRetries:        f.maxRetries,
+		TimeoutSeconds:    f.timeout,
+		RetryDelayMs:      f.retryDelay,
+		BackoffMultiplier: f.backoffMultiplier,
+		MaxRequestBytes:   f.maxRequestBytes,
+		Metadata:          spec.Metadata,
+		Transport:         spec.Transport,
+		IsActive:          &active,
+	}
+	return req, req.Validate()
+}
+
+// fromEnv reads a credential from the named environment variable; no name means none is declared
+func fromEnv(name string, getenv func(string) string) (*string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	value := getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return &value, nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func formatFloat(f float64) *string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	return &s
+}
+
+func formatInt(i *int) *string {
+	if i == nil {
+		return nil
+	}
+	s := strconv.Itoa(*i)
+	return &s
+}
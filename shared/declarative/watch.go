@@ -0,0 +1,109 @@
+package declarative
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+const defaultInterval = 10 * time.Second
+
+// Config locates the declared config and how it is applied
+type Config struct {
+	Dir      string        // Directory of config files, e.g. a mounted ConfigMap; empty disables
+	Interval time.Duration // How often the files are checked for changes
+	Prune    bool          // Deactivate resources removed from the config
+}
+
+// ConfigFromEnv reads DECLARATIVE_CONFIG_DIR, DECLARATIVE_CONFIG_INTERVAL (default 10s) and
+// DECLARATIVE_CONFIG_PRUNE
+func ConfigFromEnv() Config {
+	cfg := Config{Dir: os.Getenv("DECLARATIVE_CONFIG_DIR")}
+	cfg.Interval, _ = time.ParseDuration(os.Getenv("DECLARATIVE_CONFIG_INTERVAL"))
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	switch os.Getenv("DECLARATIVE_CONFIG_PRUNE") {
+	case "1", "true":
+		cfg.Prune = true
+	}
+	return cfg
+}
+
+// watcher applies the config whenever its files change
+type watcher struct {
+	conn    *sql.DB
+	cfg     Config
+	applied string // Digest of the files last applied
+	failed  string // Last load error, so it's logged once rather than every check
+}
+
+// check reloads the files and reconciles them if they changed since they were last applied
+func (w *watcher) check() {
+	config, digest, err := Load(w.cfg.Dir)
+	if err != nil {
+		if msg := err.Error(); msg != w.failed {
+			log.Printf("Failed to load declarative config from %s: %v", w.cfg.Dir, err)
+			w.failed = msg
+		}
+		return
+	}
+	w.failed = ""
+	if digest == w.applied {
+		return
+	}
+
+	var result Result
+	ran, err := db.WithDeclarativeConfigLock(w.conn, func() error {
+		var err error
+		result, err = Reconcile(w.conn, config, w.cfg.Prune)
+		return err
+	})
+	if !ran {
+		if err != nil {
+			log.Printf("Failed to lock declarative config: %v", err)
+		}
+		return // Another replica is applying it; check again next time
+	}
+	// A config that partly failed isn't retried until it changes again, so a bad entry
+	// doesn't rewrite the good ones on every check
+	w.applied = digest
+	if err != nil {
+		log.Printf("Declarative config applied with errors (%s): %v", result, err)
+		return
+	}
+	log.Printf("Declarative config applied: %s", result)
+}
+
+// Start reconciles the organizations and models declared in DECLARATIVE_CONFIG_DIR into the
+// database, at startup and then whenever the files change. The returned func stops watching.
+func Start(conn *sql.DB) (stop func()) {
+	cfg := ConfigFromEnv()
+	if cfg.Dir == "" {
+		return func() {}
+	}
+	log.Printf("Applying declarative config from %s (checked every %s, prune: %t)", cfg.Dir, cfg.Interval, cfg.Prune)
+
+	w := &watcher{conn: conn, cfg: cfg}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			if !readonly.Enabled() {
+				w.check()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,35 @@
+package email
+
+import (
+	"log"
+
+	"github.com/like-mike/relai-gateway/shared/config"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// applyBranding fills the branding variables from the organization's overrides, falling back
+// to the active theme for anything the organization hasn't set
+func (s *Service) applyBranding(orgID string, variables *models.EmailTemplateVariables) {
+	var theme models.Theme
+	if active, err := config.GetActiveTheme(); err == nil {
+		theme = *active
+	}
+
+	if orgID != "" {
+		branding, err := db.GetOrganizationBranding(s.db, orgID)
+		if err != nil {
+			log.Printf("Failed to get branding for org %s: %v", orgID, err)
+		}
+		theme = branding.ApplyTo(theme)
+		if branding != nil && branding.FromName != "" {
+			variables.BrandName = branding.FromName
+		}
+	}
+
+	variables.LogoURL = theme.Branding.Logo
+	variables.BrandColor = theme.Primary["600"]
+	if variables.BrandName == "" {
+		variables.BrandName = theme.Branding.Company
+	}
+}
@@ -0,0 +1,93 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// NotifyCanaryKeyUse alerts an organization that one of its canary keys was used: its admins in
+// the notification center, its security contacts by email and its notification channels. In read-only mode, when
+// the database can't be written, only the channels are notified.
+func (s *Service) NotifyCanaryKeyUse(key *models.APIKey, hit models.CanaryHit, blocked bool) error {
+	message := canaryMessage(key, hit, blocked)
+	notify.Record(s.db, key.OrganizationID, models.NotificationEventCanaryKey, message)
+	notify.Dispatch(s.db, key.OrganizationID, models.NotificationEventCanaryKey, message)
+	if readonly.Enabled() {
+		return nil
+	}
+
+	settings, err := s.GetEmailSettings()
+	if err != nil || !settings.IsEnabled {
+		return nil
+	}
+
+	recipients, err := s.notificationRecipients(key.OrganizationID, models.NotificationEventCanaryKey)
+	if err != nil {
+		return fmt.Errorf("failed to get notification recipients: %v", err)
+	}
+
+	names := make([]string, 0, len(hit.Headers))
+	for name := range hit.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&headers, "%s: %s\n", name, hit.Headers[name])
+	}
+
+	action := "The address has not been blocked."
+	if blocked {
+		action = "The gateway now refuses every request from this address."
+	}
+
+	subject := fmt.Sprintf("Canary API key %q was used from %s", key.Name, hit.IPAddress)
+	for recipient, name := range recipients {
+		if name == "" {
+			name = "Administrator"
+		}
+		body := fmt.Sprintf(`<p>Hi %s,</p>
+<p>The canary API key <strong>%s</strong> of %s was used. Canary keys are never handed out, so
+whoever holds it most likely scraped it from somewhere it was planted.</p>
+<p>Caller: <strong>%s</strong><br>Request: %s %s<br>%s</p>
+<pre>%s</pre>
+<p><a href="%s">Manage API keys</a></p>`,
+			html.EscapeString(name), html.EscapeString(key.Name), html.EscapeString(key.Organization.Name),
+			html.EscapeString(hit.IPAddress), html.EscapeString(hit.Method), html.EscapeString(hit.Path), action,
+			html.EscapeString(headers.String()), managementURL())
+
+		if _, err := s.EnqueueNotification(recipient, subject, body, models.NotificationEventCanaryKey, nil, &key.OrganizationID); err != nil {
+			log.Printf("Failed to queue canary key alert to %s: %v", recipient, err)
+		}
+	}
+	return nil
+}
+
+// canaryMessage builds the chat notification for a canary key being used
+func canaryMessage(key *models.APIKey, hit models.CanaryHit, blocked bool) notify.Message {
+	msg := notify.Message{
+		Title:    fmt.Sprintf("Canary API key %q was used", key.Name),
+		Text:     "Canary keys are never handed out; whoever holds this one likely scraped it.",
+		Severity: notify.SeverityCritical,
+		URL:      managementURL(),
+		Fields: []notify.Field{
+			{Name: "Organization", Value: key.Organization.Name},
+			{Name: "Caller IP", Value: hit.IPAddress},
+			{Name: "Request", Value: hit.Method + " " + hit.Path},
+		},
+	}
+	if ua := hit.Headers["User-Agent"]; ua != "" {
+		msg.Fields = append(msg.Fields, notify.Field{Name: "User Agent", Value: ua})
+	}
+	if blocked {
+		msg.Fields = append(msg.Fields, notify.Field{Name: "Action", Value: "IP blocked"})
+	}
+	return msg
+}
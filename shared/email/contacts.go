@@ -0,0 +1,21 @@
+package email
+
+import (
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// notificationRecipients returns email -> name for the organization's contacts of the event's
+// role, falling back to its admins when it has none
+func (s *Service) notificationRecipients(orgID, event string) (map[string]string, error) {
+	if role := models.ContactRoleForEvent(event); role != "" {
+		contacts, err := db.GetOrganizationContactEmails(s.db, orgID, role)
+		if err != nil {
+			return nil, err
+		}
+		if len(contacts) > 0 {
+			return contacts, nil
+		}
+	}
+	return db.GetOrganizationAdminEmails(s.db, orgID)
+}
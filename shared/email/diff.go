@@ -0,0 +1,62 @@
+package email
+
+import (
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// diffLines returns a line-based diff turning a into b, using the longest common subsequence
+func diffLines(a, b string) []models.DiffLine {
+	from := splitLines(a)
+	to := splitLines(b)
+
+	// lcs[i][j] is the LCS length of from[i:] and to[j:]
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := []models.DiffLine{}
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			diff = append(diff, models.DiffLine{Op: models.DiffEqual, Text: from[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, models.DiffLine{Op: models.DiffDelete, Text: from[i]})
+			i++
+		default:
+			diff = append(diff, models.DiffLine{Op: models.DiffInsert, Text: to[j]})
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		diff = append(diff, models.DiffLine{Op: models.DiffDelete, Text: from[i]})
+	}
+	for ; j < len(to); j++ {
+		diff = append(diff, models.DiffLine{Op: models.DiffInsert, Text: to[j]})
+	}
+
+	return diff
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,54 @@
+package email
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []models.DiffLine
+	}{
+		{
+			name: "identical",
+			a:    "one\ntwo",
+			b:    "one\ntwo",
+			want: []models.DiffLine{{Op: models.DiffEqual, Text: "one"}, {Op: models.DiffEqual, Text: "two"}},
+		},
+		{
+			name: "changed line",
+			a:    "Hello\n{{.UserName}}\nBye",
+			b:    "Hello\n{{.UserName}}!\nBye",
+			want: []models.DiffLine{
+				{Op: models.DiffEqual, Text: "Hello"},
+				{Op: models.DiffDelete, Text: "{{.UserName}}"},
+				{Op: models.DiffInsert, Text: "{{.UserName}}!"},
+				{Op: models.DiffEqual, Text: "Bye"},
+			},
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "new",
+			want: []models.DiffLine{{Op: models.DiffInsert, Text: "new"}},
+		},
+		{
+			name: "both empty",
+			a:    "",
+			b:    "",
+			want: []models.DiffLine{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
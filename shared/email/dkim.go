@@ -0,0 +1,201 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+)
+
+// dkimSignedHeaders are signed when present, in this order
+var dkimSignedHeaders = []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "List-Unsubscribe", "List-Unsubscribe-Post"}
+
+var whitespaceRun = regexp.MustCompile(`[ \t]+`)
+
+// DKIMSigner signs outgoing messages for a domain (RFC 6376, rsa-sha256, relaxed/relaxed)
+type DKIMSigner struct {
+	Domain   string
+	Selector string
+	Key      *rsa.PrivateKey
+}
+
+// Sign returns msg, a CRLF-delimited message, with a DKIM-Signature header prepended
+func (d *DKIMSigner) Sign(msg []byte) ([]byte, error) {
+	headerBlock, body, found := bytes.Cut(msg, []byte("\r\n\r\n"))
+	if !found {
+		return nil, fmt.Errorf("message has no header/body separator")
+	}
+
+	bodyHash := sha256.Sum256([]byte(dkimCanonicalBody(string(body))))
+	fields := dkimHeaderFields(string(headerBlock))
+
+	var signedNames []string
+	var signedData strings.Builder
+	for _, name := range dkimSignedHeaders {
+		field, ok := fields[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		signedNames = append(signedNames, strings.ToLower(name))
+		signedData.WriteString(dkimCanonicalHeader(field) + "\r\n")
+	}
+
+	signature := fmt.Sprintf("DKIM-Signature: v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		d.Domain, d.Selector, time.Now().Unix(), strings.Join(signedNames, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+	// The signature header is signed last, with an empty b= and no trailing CRLF
+	signedData.WriteString(dkimCanonicalHeader(signature))
+
+	digest := sha256.Sum256([]byte(signedData.String()))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, d.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %v", err)
+	}
+
+	signed := signature + base64.StdEncoding.EncodeToString(sig) + "\r\n"
+	return append([]byte(signed), msg...), nil
+}
+
+// dkimHeaderFields maps lowercased header names to their raw (possibly folded) fields. With
+// repeated headers the last one wins, which is the one DKIM signs first.
+func dkimHeaderFields(headerBlock string) map[string]string {
+	fields := make(map[string]string)
+	var current string
+	flush := func() {
+		if name, _, ok := strings.Cut(current, ":"); ok {
+			fields[strings.ToLower(strings.TrimSpace(name))] = current
+		}
+	}
+
+	for _, line := range strings.Split(headerBlock, "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			current += "\r\n" + line
+			continue
+		}
+		flush()
+		current = line
+	}
+	flush()
+
+	return fields
+}
+
+// dkimCanonicalHeader applies relaxed header canonicalization (RFC 6376 section 3.4.2), without the trailing CRLF
+func dkimCanonicalHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.NewReplacer("\r\n", "").Replace(value)
+	value = strings.TrimSpace(whitespaceRun.ReplaceAllString(value, " "))
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value
+}
+
+// dkimCanonicalBody applies relaxed body canonicalization (RFC 6376 section 3.4.4)
+func dkimCanonicalBody(body string) string {
+	lines := strings.Split(body, "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(whitespaceRun.ReplaceAllString(line, " "), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// GenerateDKIMKey creates a 2048-bit RSA key, returning the PEM private key and the base64
+// public key for the DNS record
+func GenerateDKIMKey() (string, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	privatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	publicKey, err := dkimPublicKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return privatePEM, publicKey, nil
+}
+
+// ParseDKIMPrivateKey reads a PKCS#1 or PKCS#8 PEM RSA key
+func ParseDKIMPrivateKey(privatePEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privatePEM))
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key must be RSA")
+	}
+	return key, nil
+}
+
+// dkimPublicKey returns the base64 DER public key published in the p= tag
+func dkimPublicKey(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// DKIMPublicKey returns the DNS public key for a PEM private key
+func DKIMPublicKey(privatePEM string) (string, error) {
+	key, err := ParseDKIMPrivateKey(privatePEM)
+	if err != nil {
+		return "", err
+	}
+	return dkimPublicKey(key)
+}
+
+// dkimSigner returns the signer for fromEmail's domain, or nil when the domain has no key
+func (s *Service) dkimSigner(fromEmail string) (*DKIMSigner, error) {
+	_, domain, found := strings.Cut(fromEmail, "@")
+	if !found || domain == "" {
+		return nil, nil
+	}
+
+	key, err := db.GetDKIMKeyByDomain(s.db, domain)
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	privatePEM, err := encryption.Decrypt(key.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DKIM key for %s: %v", key.Domain, err)
+	}
+
+	rsaKey, err := ParseDKIMPrivateKey(privatePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DKIMSigner{Domain: key.Domain, Selector: key.Selector, Key: rsaKey}, nil
+}
@@ -0,0 +1,66 @@
+package email
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDKIMCanonicalization(t *testing.T) {
+	// Examples from RFC 6376 section 3.4.5
+	if got := dkimCanonicalHeader("A: X"); got != "a:X" {
+		t.Errorf("dkimCanonicalHeader(A) = %q", got)
+	}
+	if got := dkimCanonicalHeader("B : Y\t\r\n\tZ  "); got != "b:Y Z" {
+		t.Errorf("dkimCanonicalHeader(B) = %q", got)
+	}
+	if got := dkimCanonicalBody(" C \r\nD \t E\r\n\r\n\r\n"); got != " C\r\nD E\r\n" {
+		t.Errorf("dkimCanonicalBody() = %q", got)
+	}
+}
+
+func TestDKIMSignVerifies(t *testing.T) {
+	privatePEM, _, err := GenerateDKIMKey()
+	if err != nil {
+		t.Fatalf("GenerateDKIMKey() error = %v", err)
+	}
+	key, err := ParseDKIMPrivateKey(privatePEM)
+	if err != nil {
+		t.Fatalf("ParseDKIMPrivateKey() error = %v", err)
+	}
+
+	signer := &DKIMSigner{Domain: "example.com", Selector: "relai", Key: key}
+	msg := buildMessage(SMTPConfig{FromName: "RelAI", FromEmail: "noreply@example.com"},
+		EmailMessage{To: "admin@example.org", Subject: "Hello", Body: "<p>Hi</p>\n", IsHTML: true})
+
+	signed, err := signer.Sign(msg)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	sigLine, _, _ := strings.Cut(string(signed), "\r\n")
+	if !strings.Contains(sigLine, "d=example.com; s=relai;") || !strings.Contains(sigLine, "h=from:to:subject:date:message-id:mime-version:content-type;") {
+		t.Fatalf("unexpected signature header: %s", sigLine)
+	}
+
+	// Recompute the signed data the way a verifier would
+	fields := dkimHeaderFields(strings.SplitN(string(msg), "\r\n\r\n", 2)[0])
+	var data strings.Builder
+	for _, name := range []string{"from", "to", "subject", "date", "message-id", "mime-version", "content-type"} {
+		data.WriteString(dkimCanonicalHeader(fields[name]) + "\r\n")
+	}
+	unsigned, b64, _ := strings.Cut(sigLine, "; b=")
+	data.WriteString(dkimCanonicalHeader(unsigned + "; b="))
+
+	sig, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		t.Fatalf("signature is not base64: %v", err)
+	}
+	digest := sha256.Sum256([]byte(data.String()))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"log"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+)
+
+// NotifyAPIKeyLeak tells an organization that one of its keys was found in public and revoked: its
+// admins in the notification center, its security contacts by email and its notification channels
+func (s *Service) NotifyAPIKeyLeak(key *models.APIKey, sourceURL string) error {
+	message := keyLeakMessage(key, sourceURL)
+	notify.Record(s.db, key.OrganizationID, models.NotificationEventAPIKeyLeak, message)
+	notify.Dispatch(s.db, key.OrganizationID, models.NotificationEventAPIKeyLeak, message)
+
+	settings, err := s.GetEmailSettings()
+	if err != nil || !settings.IsEnabled {
+		return nil
+	}
+
+	recipients, err := s.notificationRecipients(key.OrganizationID, models.NotificationEventAPIKeyLeak)
+	if err != nil {
+		return fmt.Errorf("failed to get notification recipients: %v", err)
+	}
+
+	subject := fmt.Sprintf("API key %q was leaked and has been revoked", key.Name)
+	location := "a public location"
+	if sourceURL != "" {
+		location = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(sourceURL), html.EscapeString(sourceURL))
+	}
+	for recipient, name := range recipients {
+		if name == "" {
+			name = "Administrator"
+		}
+		body := fmt.Sprintf(`<p>Hi %s,</p>
+<p>GitHub secret scanning found the API key <strong>%s</strong> of %s in %s. The key has been revoked,
+so requests using it are now rejected.</p>
+<p>Create a replacement key for the applications that used it, and remove the leaked key from
+the repository and its history.</p>
+<p><a href="%s">Manage API keys</a></p>`,
+			html.EscapeString(name), html.EscapeString(key.Name), html.EscapeString(key.Organization.Name), location,
+			managementURL())
+
+		if _, err := s.EnqueueNotification(recipient, subject, body, models.NotificationEventAPIKeyLeak, nil, &key.OrganizationID); err != nil {
+			log.Printf("Failed to queue key leak notification to %s: %v", recipient, err)
+		}
+	}
+	return nil
+}
+
+// keyLeakMessage builds the chat notification for a leaked key
+func keyLeakMessage(key *models.APIKey, sourceURL string) notify.Message {
+	msg := notify.Message{
+		Title:    fmt.Sprintf("API key %q was leaked and has been revoked", key.Name),
+		Text:     "GitHub secret scanning found the key in public. Create a replacement for the applications that used it.",
+		Severity: notify.SeverityCritical,
+		URL:      managementURL(),
+		Fields: []notify.Field{
+			{Name: "Organization", Value: key.Organization.Name},
+			{Name: "Key", Value: key.KeyPrefix},
+		},
+	}
+	if sourceURL != "" {
+		msg.Fields = append(msg.Fields, notify.Field{Name: "Found At", Value: sourceURL})
+	}
+	return msg
+}
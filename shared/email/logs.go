@@ -0,0 +1,105 @@
+package email
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+const (
+	// defaultLogRetentionDays is how long email logs and finished outbox messages are kept
+	defaultLogRetentionDays = 90
+
+	logRetentionInterval = 24 * time.Hour
+)
+
+var (
+	// ErrNotFailed is returned when resending an email that didn't fail
+	ErrNotFailed = errors.New("only failed emails can be resent")
+	// ErrNotResendable is returned when a failed email's message is no longer in the outbox,
+	// because it was logged before outbox references or purged since
+	ErrNotResendable = errors.New("email is no longer in the outbox")
+)
+
+// ResendEmail puts the outbox message a failed email log was sent from back in the queue with
+// a fresh set of attempts. The sender logs the outcome as a new email log.
+func (s *Service) ResendEmail(logID string) (*models.EmailLog, error) {
+	entry, err := db.GetEmailLog(s.db, logID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Status != "failed" {
+		return nil, ErrNotFailed
+	}
+	if entry.OutboxID == nil {
+		return nil, ErrNotResendable
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE email_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), last_error = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'failed'`, *entry.OutboxID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		// Already requeued by an earlier resend, or gone
+		var status string
+		err := s.db.QueryRow(`SELECT status FROM email_outbox WHERE id = $1`, *entry.OutboxID).Scan(&status)
+		if err == sql.ErrNoRows {
+			return nil, ErrNotResendable
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFailed
+	}
+
+	return entry, nil
+}
+
+// logRetentionDays returns how many days of email logs are kept, from EMAIL_LOG_RETENTION_DAYS;
+// 0 keeps them forever
+func logRetentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("EMAIL_LOG_RETENTION_DAYS"))
+	if err != nil || days < 0 {
+		return defaultLogRetentionDays
+	}
+	return days
+}
+
+// StartLogRetention removes email logs, and the outbox messages that finished sending, older
+// than the retention period once a day. The returned func stops it.
+func StartLogRetention(database *sql.DB) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(logRetentionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				days := logRetentionDays()
+				if readonly.Enabled() || days == 0 {
+					continue
+				}
+				removed, err := db.DeleteEmailLogsBefore(database, time.Now().AddDate(0, 0, -days))
+				if err != nil {
+					log.Printf("Failed to prune email logs: %v", err)
+				} else if removed > 0 {
+					log.Printf("Pruned %d email logs older than %d days", removed, days)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
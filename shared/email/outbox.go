@@ -0,0 +1,367 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// SenderConfig configures the background outbox sender
+type SenderConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// StaleAfter reclaims messages left in 'sending' by a crashed sender
+	StaleAfter time.Duration
+}
+
+// DefaultSenderConfig returns a sensible default configuration
+func DefaultSenderConfig() *SenderConfig {
+	return &SenderConfig{
+		PollInterval: time.Second * 10,
+		BatchSize:    20,
+		MaxAttempts:  5,
+		BaseBackoff:  time.Second * 30,
+		MaxBackoff:   time.Hour,
+		StaleAfter:   time.Minute * 10,
+	}
+}
+
+// EnqueueEmail adds a message to the outbox. A nil sendAt delivers as soon as the sender runs;
+// a non-nil organizationID sends it under that organization's branded from-name.
+func (s *Service) EnqueueEmail(recipient, subject, htmlBody string, templateID, organizationID *string, sendAt *time.Time) (string, error) {
+	return s.enqueue(recipient, subject, htmlBody, "", templateID, organizationID, sendAt)
+}
+
+// EnqueueNotification queues a notification email of the given event type. It gets an
+// unsubscribe link, and the sender drops it if the recipient has opted out of the event.
+func (s *Service) EnqueueNotification(recipient, subject, htmlBody, eventType string, templateID, organizationID *string) (string, error) {
+	htmlBody = withUnsubscribeFooter(htmlBody, s.UnsubscribeURL(recipient, eventType))
+	return s.enqueue(recipient, subject, htmlBody, eventType, templateID, organizationID, nil)
+}
+
+func (s *Service) enqueue(recipient, subject, htmlBody, notificationType string, templateID, organizationID *string, sendAt *time.Time) (string, error) {
+	query := `
+		INSERT INTO email_outbox (recipient_email, subject, html_body, template_id, organization_id, notification_type, max_attempts, scheduled_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, COALESCE($8, NOW()), COALESCE($8, NOW()))
+		RETURNING id`
+
+	var id string
+	err := s.db.QueryRow(query, recipient, subject, htmlBody, templateID, organizationID, notificationType,
+		DefaultSenderConfig().MaxAttempts, sendAt).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("failed to enqueue email: %v", err)
+	}
+
+	return id, nil
+}
+
+// GetOutboxMessages returns recent outbox messages, optionally filtered by status
+func (s *Service) GetOutboxMessages(status string, limit int) ([]models.EmailOutboxMessage, error) {
+	query := `
+		SELECT id, recipient_email, subject, template_id, organization_id, notification_type, status, attempts, max_attempts,
+		       scheduled_at, next_attempt_at, last_error, sent_at, created_at, updated_at
+		FROM email_outbox
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC
+		LIMIT $2`
+
+	rows, err := s.db.Query(query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.EmailOutboxMessage{}
+	for rows.Next() {
+		var m models.EmailOutboxMessage
+		if err := rows.Scan(&m.ID, &m.RecipientEmail, &m.Subject, &m.TemplateID, &m.OrganizationID, &m.NotificationType, &m.Status,
+			&m.Attempts, &m.MaxAttempts, &m.ScheduledAt, &m.NextAttemptAt, &m.LastError,
+			&m.SentAt, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// Sender delivers queued outbox messages in the background, retrying failures with backoff
+type Sender struct {
+	service *Service
+	config  *SenderConfig
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewSender creates a background sender for the email outbox
+func NewSender(db *sql.DB, config *SenderConfig) *Sender {
+	if config == nil {
+		config = DefaultSenderConfig()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Sender{
+		service: NewService(db),
+		config:  config,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start begins polling the outbox
+func (s *Sender) Start() {
+	log.Printf("Starting email sender (poll interval %s)", s.config.PollInterval)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			// Sending marks messages in the outbox, so it waits out read-only mode
+			if !readonly.Enabled() {
+				s.processBatch()
+			}
+
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop gracefully shuts down the sender, letting an in-flight batch finish
+func (s *Sender) Stop() {
+	log.Println("Stopping email sender...")
+	s.cancel()
+	s.wg.Wait()
+	log.Println("Email sender stopped")
+}
+
+// processBatch claims due messages and attempts delivery
+func (s *Sender) processBatch() {
+	messages, err := s.claimDueMessages()
+	if err != nil {
+		log.Printf("Failed to claim outbox messages: %v", err)
+		return
+	}
+
+	if len(messages) == 0 {
+		return
+	}
+
+	settings, err := s.service.GetEmailSettings()
+	if err != nil || !settings.IsEnabled {
+		reason := "email service is disabled"
+		if err != nil {
+			reason = fmt.Sprintf("failed to get email settings: %v", err)
+		}
+		for _, m := range messages {
+			s.recordFailure(m, reason)
+		}
+		return
+	}
+
+	config := NewSMTPConfig(settings)
+
+	// A key that's configured but unusable is an error rather than a reason to send unsigned
+	// mail that fails DMARC
+	config.DKIM, err = s.service.dkimSigner(config.FromEmail)
+	if err != nil {
+		for _, m := range messages {
+			s.recordFailure(m, err.Error())
+		}
+		return
+	}
+
+	// Organization from-names, looked up once per batch
+	fromNames := make(map[string]string)
+
+	for _, m := range messages {
+		if s.ctx.Err() != nil {
+			// Shutting down; release unsent claims for the next run
+			s.release(m)
+			continue
+		}
+
+		if m.NotificationType != nil {
+			enabled, err := db.IsNotificationEnabled(s.service.db, m.RecipientEmail, *m.NotificationType, models.NotificationDeliveryEmail)
+			if err != nil {
+				s.recordFailure(m, fmt.Sprintf("failed to check notification preferences: %v", err))
+				continue
+			}
+			if !enabled {
+				s.recordSuppressed(m)
+				continue
+			}
+		}
+
+		msgConfig := config
+		if m.OrganizationID != nil {
+			fromName, cached := fromNames[*m.OrganizationID]
+			if !cached {
+				if branding, err := db.GetOrganizationBranding(s.service.db, *m.OrganizationID); err != nil {
+					log.Printf("Failed to get branding for org %s: %v", *m.OrganizationID, err)
+				} else if branding != nil {
+					fromName = branding.FromName
+				}
+				fromNames[*m.OrganizationID] = fromName
+			}
+			if fromName != "" {
+				msgConfig.FromName = fromName
+			}
+		}
+
+		message := EmailMessage{
+			To:      m.RecipientEmail,
+			Subject: m.Subject,
+			Body:    m.HTMLBody,
+			IsHTML:  true,
+		}
+		if m.NotificationType != nil {
+			// One-click unsubscribe for mail clients (RFC 8058)
+			if unsubscribeURL := s.service.UnsubscribeURL(m.RecipientEmail, *m.NotificationType); unsubscribeURL != "" {
+				message.Headers = map[string]string{
+					"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+					"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+				}
+			}
+		}
+
+		server, err := s.service.smtp.SendEmailWithFailover(msgConfig, NewSecondarySMTPConfig(settings, msgConfig), message)
+		if err != nil {
+			s.recordFailure(m, err.Error())
+			continue
+		}
+		if server != models.SMTPServerPrimary {
+			log.Printf("Email to %s delivered by the %s SMTP server", m.RecipientEmail, server)
+		}
+
+		s.recordSuccess(m, server)
+	}
+}
+
+// claimDueMessages marks due messages as 'sending' so concurrent senders don't pick them up twice
+func (s *Sender) claimDueMessages() ([]models.EmailOutboxMessage, error) {
+	query := `
+		UPDATE email_outbox
+		SET status = 'sending', attempts = attempts + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE (status = 'pending' AND next_attempt_at <= NOW())
+			   OR (status = 'sending' AND updated_at < NOW() - make_interval(secs => $2))
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient_email, subject, html_body, template_id, organization_id, notification_type, attempts, max_attempts`
+
+	rows, err := s.service.db.Query(query, s.config.BatchSize, s.config.StaleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.EmailOutboxMessage
+	for rows.Next() {
+		var m models.EmailOutboxMessage
+		if err := rows.Scan(&m.ID, &m.RecipientEmail, &m.Subject, &m.HTMLBody, &m.TemplateID,
+			&m.OrganizationID, &m.NotificationType, &m.Attempts, &m.MaxAttempts); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// recordSuccess marks a message sent and logs the server that delivered it
+func (s *Sender) recordSuccess(m models.EmailOutboxMessage, server string) {
+	_, err := s.service.db.Exec(`
+		UPDATE email_outbox
+		SET status = 'sent', sent_at = NOW(), last_error = NULL, updated_at = NOW()
+		WHERE id = $1`, m.ID)
+	if err != nil {
+		log.Printf("Failed to mark outbox message %s as sent: %v", m.ID, err)
+	}
+
+	s.service.logEmail(m.ID, m.RecipientEmail, m.Subject, m.TemplateID, server, nil)
+}
+
+// recordSuppressed drops a notification the recipient has unsubscribed from
+func (s *Sender) recordSuppressed(m models.EmailOutboxMessage) {
+	_, err := s.service.db.Exec(`
+		UPDATE email_outbox
+		SET status = 'suppressed', updated_at = NOW()
+		WHERE id = $1`, m.ID)
+	if err != nil {
+		log.Printf("Failed to mark outbox message %s as suppressed: %v", m.ID, err)
+	}
+}
+
+// recordFailure schedules a retry with exponential backoff, or gives up after max attempts
+func (s *Sender) recordFailure(m models.EmailOutboxMessage, reason string) {
+	if m.Attempts >= m.MaxAttempts {
+		_, err := s.service.db.Exec(`
+			UPDATE email_outbox
+			SET status = 'failed', last_error = $2, updated_at = NOW()
+			WHERE id = $1`, m.ID, reason)
+		if err != nil {
+			log.Printf("Failed to mark outbox message %s as failed: %v", m.ID, err)
+		}
+
+		log.Printf("Giving up on email to %s after %d attempts: %s", m.RecipientEmail, m.Attempts, reason)
+		s.service.logEmail(m.ID, m.RecipientEmail, m.Subject, m.TemplateID, "", fmt.Errorf("%s", reason))
+		return
+	}
+
+	delay := s.backoff(m.Attempts)
+	_, err := s.service.db.Exec(`
+		UPDATE email_outbox
+		SET status = 'pending', last_error = $2, next_attempt_at = NOW() + make_interval(secs => $3), updated_at = NOW()
+		WHERE id = $1`, m.ID, reason, delay.Seconds())
+	if err != nil {
+		log.Printf("Failed to reschedule outbox message %s: %v", m.ID, err)
+	}
+
+	log.Printf("Email to %s failed (attempt %d/%d), retrying in %s: %s", m.RecipientEmail, m.Attempts, m.MaxAttempts, delay, reason)
+}
+
+// release returns a claimed message to the queue without counting the attempt
+func (s *Sender) release(m models.EmailOutboxMessage) {
+	_, err := s.service.db.Exec(`
+		UPDATE email_outbox
+		SET status = 'pending', attempts = GREATEST(attempts - 1, 0), updated_at = NOW()
+		WHERE id = $1`, m.ID)
+	if err != nil {
+		log.Printf("Failed to release outbox message %s: %v", m.ID, err)
+	}
+}
+
+// backoff returns the delay before the next attempt: BaseBackoff doubled per attempt, capped at MaxBackoff
+func (s *Sender) backoff(attempts int) time.Duration {
+	delay := s.config.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= s.config.MaxBackoff {
+			return s.config.MaxBackoff
+		}
+	}
+	return delay
+}
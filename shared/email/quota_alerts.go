@@ -0,0 +1,171 @@
+package email
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+)
+
+// topKeysWindowDays is the lookback for the "top consuming keys" section of quota emails
+const topKeysWindowDays = 30
+
+// NotifyQuotaThresholds adds a notification to the organization's notification center, queues a
+// "usage" email and posts to its notification channels when its token usage crosses one of its
+// configured thresholds. Each threshold fires at most once per quota period (reset date); when
+// several are crossed at once only the highest is sent.
+func (s *Service) NotifyQuotaThresholds(quota *models.OrganizationQuota) error {
+	if quota == nil || quota.TotalQuota <= 0 {
+		return nil
+	}
+
+	percentUsed := float64(quota.UsedTokens) / float64(quota.TotalQuota) * 100
+
+	settings, err := db.GetQuotaNotificationSettings(s.db, quota.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to get quota notification settings: %v", err)
+	}
+	if !settings.IsEnabled {
+		return nil
+	}
+
+	var crossed []int
+	highest := 0
+	for _, threshold := range settings.Thresholds {
+		if percentUsed >= float64(threshold) {
+			crossed = append(crossed, threshold)
+			if threshold > highest {
+				highest = threshold
+			}
+		}
+	}
+	if len(crossed) == 0 {
+		return nil
+	}
+
+	// The notification center always receives the alert, so thresholds are recorded even while
+	// email and channels aren't configured
+	recorded, err := db.RecordQuotaNotifications(s.db, quota.OrganizationID, crossed, quota.ResetDate)
+	if err != nil {
+		return fmt.Errorf("failed to record quota notification: %v", err)
+	}
+	if !recorded[highest] {
+		return nil
+	}
+
+	message := quotaMessage(quota, highest, percentUsed)
+	notify.Record(s.db, quota.OrganizationID, models.NotificationEventQuotaUsage, message)
+	notify.Dispatch(s.db, quota.OrganizationID, models.NotificationEventQuotaUsage, message)
+
+	emailSettings, err := s.GetEmailSettings()
+	if err != nil || !emailSettings.IsEnabled {
+		return nil
+	}
+	if err := s.sendQuotaNotification(quota, settings, highest, percentUsed); err != nil {
+		return err
+	}
+
+	log.Printf("Queued %d%% quota notification for org %s", highest, quota.OrganizationID)
+	return nil
+}
+
+func (s *Service) sendQuotaNotification(quota *models.OrganizationQuota, settings *models.QuotaNotificationSettings, threshold int, percentUsed float64) error {
+	locale, err := db.GetOrganizationLocale(s.db, quota.OrganizationID)
+	if err != nil {
+		log.Printf("Failed to get locale for org %s: %v", quota.OrganizationID, err)
+	}
+
+	template, err := s.GetActiveEmailTemplateByType("usage", locale)
+	if err != nil {
+		return fmt.Errorf("no active usage template: %v", err)
+	}
+
+	recipients := make(map[string]string)
+	for _, recipient := range settings.Recipients {
+		recipients[recipient] = ""
+	}
+	if len(recipients) == 0 {
+		recipients, err = s.notificationRecipients(quota.OrganizationID, models.NotificationEventQuotaUsage)
+		if err != nil {
+			return fmt.Errorf("failed to get notification recipients: %v", err)
+		}
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("no recipients configured for organization %s", quota.OrganizationID)
+	}
+
+	var orgName string
+	if err := s.db.QueryRow(`SELECT name FROM organizations WHERE id = $1`, quota.OrganizationID).Scan(&orgName); err != nil {
+		return fmt.Errorf("failed to get organization: %v", err)
+	}
+
+	topKeys, err := db.GetTopAPIKeysByUsage(s.db, quota.OrganizationID, topKeysWindowDays, 5)
+	if err != nil {
+		log.Printf("Failed to get top API keys for quota notification: %v", err)
+		topKeys = []models.APIKeyUsageSummary{}
+	}
+
+	queued := 0
+	for recipient, name := range recipients {
+		if name == "" {
+			name = "Administrator"
+		}
+
+		variables := &models.EmailTemplateVariables{
+			UserName:         name,
+			OrganizationName: orgName,
+			ManagementURL:    managementURL(),
+			UnsubscribeURL:   s.UnsubscribeURL(recipient, models.NotificationEventQuotaUsage),
+			Threshold:        threshold,
+			UsagePercent:     fmt.Sprintf("%.1f%%", percentUsed),
+			UsedTokens:       models.FormatTokenCount(int64(quota.UsedTokens)),
+			TotalQuota:       models.FormatTokenCount(int64(quota.TotalQuota)),
+			ResetDate:        quota.ResetDate.Format("January 2, 2006"),
+			TopAPIKeys:       topKeys,
+		}
+		s.applyBranding(quota.OrganizationID, variables)
+
+		subject, err := s.renderer.RenderText(template.Subject, variables)
+		if err != nil {
+			return fmt.Errorf("failed to render subject: %v", err)
+		}
+
+		htmlBody, err := s.renderer.RenderHTML(template.HTMLBody, variables)
+		if err != nil {
+			return fmt.Errorf("failed to render HTML body: %v", err)
+		}
+
+		if _, err := s.EnqueueNotification(recipient, subject, htmlBody, models.NotificationEventQuotaUsage, &template.ID, &quota.OrganizationID); err != nil {
+			log.Printf("Failed to queue quota notification to %s: %v", recipient, err)
+			continue
+		}
+		queued++
+	}
+
+	if queued == 0 {
+		return fmt.Errorf("failed to queue quota notification to any recipient")
+	}
+
+	return nil
+}
+
+// quotaMessage builds the chat notification for a crossed quota threshold
+func quotaMessage(quota *models.OrganizationQuota, threshold int, percentUsed float64) notify.Message {
+	severity := notify.SeverityWarning
+	if threshold >= 100 {
+		severity = notify.SeverityCritical
+	}
+
+	return notify.Message{
+		Title:    fmt.Sprintf("Token quota %d%% used", threshold),
+		Text:     "Requests may be rejected once the quota is exhausted.",
+		Severity: severity,
+		URL:      managementURL(),
+		Fields: []notify.Field{
+			{Name: "Usage", Value: fmt.Sprintf("%s of %s tokens (%.1f%%)", models.FormatTokenCount(int64(quota.UsedTokens)), models.FormatTokenCount(int64(quota.TotalQuota)), percentUsed)},
+			{Name: "Quota Resets", Value: quota.ResetDate.Format("January 2, 2006")},
+		},
+	}
+}
@@ -0,0 +1,375 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/i18n"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// expiredReminderWindow limits expiration notices to keys that expired recently, so enabling
+// the scheduler doesn't mail about keys that lapsed long ago
+const expiredReminderWindow = 7
+
+// ReminderScheduler evaluates email_schedules and queues API key expiry reminders
+type ReminderScheduler struct {
+	service  *Service
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// expiringKey is an API key due a reminder under a schedule
+type expiringKey struct {
+	ID               string
+	Name             string
+	ExpiresAt        time.Time
+	OrganizationID   string
+	OrganizationName string
+	OwnerEmail       string
+	OwnerName        string
+	Locale           string // Owner's locale, else the organization's
+}
+
+// NewReminderScheduler creates a scheduler that runs every interval (daily if zero)
+func NewReminderScheduler(db *sql.DB, interval time.Duration) *ReminderScheduler {
+	if interval <= 0 {
+		interval = time.Hour * 24
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &ReminderScheduler{
+		service:  NewService(db),
+		interval: interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start runs the schedules immediately and then on every interval
+func (r *ReminderScheduler) Start() {
+	log.Printf("Starting API key expiry reminder scheduler (interval %s)", r.interval)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			if readonly.Enabled() {
+				log.Println("Skipping API key expiry reminders in read-only mode")
+			} else if err := r.RunOnce(); err != nil {
+				log.Printf("API key expiry reminder run failed: %v", err)
+			}
+
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop gracefully shuts down the scheduler
+func (r *ReminderScheduler) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	log.Println("API key expiry reminder scheduler stopped")
+}
+
+// RunOnce evaluates every enabled schedule and sends reminders that are due, to the notification
+// center, by email and to the organization's notification channels
+func (r *ReminderScheduler) RunOnce() error {
+	settings, err := r.service.GetEmailSettings()
+	emailEnabled := err == nil && settings.IsEnabled
+
+	schedules, err := r.getEnabledSchedules()
+	if err != nil {
+		return fmt.Errorf("failed to load email schedules: %v", err)
+	}
+
+	queued := 0
+	for _, schedule := range schedules {
+		if r.ctx.Err() != nil {
+			break
+		}
+
+		n, err := r.runSchedule(schedule, emailEnabled)
+		if err != nil {
+			log.Printf("Email schedule %s (%s) failed: %v", schedule.ID, schedule.ScheduleType, err)
+			continue
+		}
+		queued += n
+	}
+
+	if queued > 0 {
+		log.Printf("Sent %d API key expiry reminder(s)", queued)
+	}
+
+	return nil
+}
+
+// getEnabledSchedules returns enabled schedules, tightest warning threshold first so a key that is
+// already inside a smaller window isn't also sent the larger ones
+func (r *ReminderScheduler) getEnabledSchedules() ([]models.EmailSchedule, error) {
+	query := `
+		SELECT id, organization_id, schedule_type, days_before, is_enabled, created_at
+		FROM email_schedules
+		WHERE is_enabled = true AND schedule_type IN ($1, $2)
+		ORDER BY schedule_type, COALESCE(days_before, 0)`
+
+	rows, err := r.service.db.Query(query, models.EmailScheduleAPIKeyWarning, models.EmailScheduleAPIKeyExpiration)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []models.EmailSchedule
+	for rows.Next() {
+		var s models.EmailSchedule
+		if err := rows.Scan(&s.ID, &s.OrganizationID, &s.ScheduleType, &s.DaysBefore, &s.IsEnabled, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+
+	return schedules, rows.Err()
+}
+
+// runSchedule sends reminders for every key matching the schedule and returns how many were sent
+func (r *ReminderScheduler) runSchedule(schedule models.EmailSchedule, emailEnabled bool) (int, error) {
+	daysBefore := 0
+	if schedule.DaysBefore != nil {
+		daysBefore = *schedule.DaysBefore
+	}
+
+	templateType := "warning"
+	if schedule.ScheduleType == models.EmailScheduleAPIKeyExpiration {
+		templateType = "expiration"
+		daysBefore = 0
+	}
+
+	var template *models.EmailTemplate
+	if emailEnabled {
+		var err error
+		template, err = r.service.GetActiveEmailTemplateByType(templateType, i18n.DefaultLocale)
+		if err == sql.ErrNoRows {
+			log.Printf("No active %s email template; expiry reminders go to the notification center and channels only", templateType)
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	keys, err := r.findDueKeys(schedule, daysBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, key := range keys {
+		ok, err := r.sendReminder(schedule.ScheduleType, daysBefore, template, key)
+		if err != nil {
+			log.Printf("Failed to send expiry reminder for API key %s: %v", key.ID, err)
+			continue
+		}
+		if ok {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// findDueKeys returns keys in the schedule's window that haven't had this or a tighter reminder
+// for their current expiry date
+func (r *ReminderScheduler) findDueKeys(schedule models.EmailSchedule, daysBefore int) ([]expiringKey, error) {
+	window := `ak.expires_at > NOW() AND ak.expires_at <= NOW() + make_interval(days => $3)`
+	if schedule.ScheduleType == models.EmailScheduleAPIKeyExpiration {
+		window = fmt.Sprintf(`ak.expires_at <= NOW() AND ak.expires_at > NOW() - INTERVAL '%d days' AND $3 = 0`, expiredReminderWindow)
+	}
+
+	query := `
+		SELECT ak.id, ak.name, ak.expires_at, o.id, o.name, COALESCE(u.email, ''), COALESCE(u.name, ''),
+		       COALESCE(u.locale, o.locale, '')
+		FROM api_keys ak
+		JOIN organizations o ON ak.organization_id = o.id
+		LEFT JOIN users u ON COALESCE(ak.owner_user_id, ak.created_by_user_id) = u.id AND u.is_active = true
+		WHERE ak.is_active = true
+		  AND ak.expires_at IS NOT NULL
+		  AND ($1::uuid IS NULL OR ak.organization_id = $1::uuid)
+		  AND ` + window + `
+		  AND NOT EXISTS (
+		      SELECT 1 FROM api_key_reminders rem
+		      WHERE rem.api_key_id = ak.id
+		        AND rem.schedule_type = $2
+		        AND rem.expires_at = ak.expires_at
+		        AND rem.days_before <= $3
+		  )`
+
+	rows, err := r.service.db.Query(query, schedule.OrganizationID, schedule.ScheduleType, daysBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []expiringKey
+	for rows.Next() {
+		var k expiringKey
+		if err := rows.Scan(&k.ID, &k.Name, &k.ExpiresAt, &k.OrganizationID, &k.OrganizationName,
+			&k.OwnerEmail, &k.OwnerName, &k.Locale); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// sendReminder records the reminder (the dedupe point), adds it to the organization's
+// notification center, queues one email per recipient when email is available (template
+// non-nil) and posts to the organization's notification channels. It returns false if another
+// run already recorded the reminder.
+func (r *ReminderScheduler) sendReminder(scheduleType string, daysBefore int, template *models.EmailTemplate, key expiringKey) (bool, error) {
+	var reminderID string
+	err := r.service.db.QueryRow(`
+		INSERT INTO api_key_reminders (api_key_id, schedule_type, days_before, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (api_key_id, schedule_type, days_before, expires_at) DO NOTHING
+		RETURNING id`, key.ID, scheduleType, daysBefore, key.ExpiresAt).Scan(&reminderID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	daysUntil := int(math.Ceil(time.Until(key.ExpiresAt).Hours() / 24))
+	if daysUntil < 0 {
+		daysUntil = 0
+	}
+
+	message := expiryMessage(key, daysUntil)
+	notify.Record(r.service.db, key.OrganizationID, models.NotificationEventAPIKeyExpiry, message)
+
+	if template != nil {
+		// The notification center already has the reminder, so a failed email isn't retried
+		outboxID, err := r.queueReminderEmails(template, key, daysUntil)
+		if err != nil {
+			log.Printf("Failed to queue expiry reminder email for API key %s: %v", key.ID, err)
+		} else if _, err := r.service.db.Exec(`UPDATE api_key_reminders SET outbox_id = $1 WHERE id = $2`, outboxID, reminderID); err != nil {
+			log.Printf("Failed to link expiry reminder %s to outbox: %v", reminderID, err)
+		}
+	}
+
+	notify.Dispatch(r.service.db, key.OrganizationID, models.NotificationEventAPIKeyExpiry, message)
+
+	return true, nil
+}
+
+// queueReminderEmails queues the reminder to each recipient and returns the first outbox message ID
+func (r *ReminderScheduler) queueReminderEmails(template *models.EmailTemplate, key expiringKey, daysUntil int) (string, error) {
+	recipients, err := r.reminderRecipients(key)
+	if err != nil {
+		return "", err
+	}
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("no owner, technical contact or organization admin to notify")
+	}
+
+	template = r.service.localizedTemplate(template, key.Locale)
+
+	var firstOutboxID string
+	for email, name := range recipients {
+		variables := &models.EmailTemplateVariables{
+			UserName:            name,
+			APIKeyName:          key.Name,
+			ExpirationDate:      key.ExpiresAt.Format("January 2, 2006"),
+			OrganizationName:    key.OrganizationName,
+			DaysUntilExpiration: daysUntil,
+			ManagementURL:       managementURL(),
+			UnsubscribeURL:      r.service.UnsubscribeURL(email, models.NotificationEventAPIKeyExpiry),
+		}
+		r.service.applyBranding(key.OrganizationID, variables)
+
+		subject, err := r.service.renderer.RenderText(template.Subject, variables)
+		if err != nil {
+			return "", fmt.Errorf("failed to render subject: %v", err)
+		}
+
+		htmlBody, err := r.service.renderer.RenderHTML(template.HTMLBody, variables)
+		if err != nil {
+			return "", fmt.Errorf("failed to render HTML body: %v", err)
+		}
+
+		outboxID, err := r.service.EnqueueNotification(email, subject, htmlBody, models.NotificationEventAPIKeyExpiry, &template.ID, &key.OrganizationID)
+		if err != nil {
+			if firstOutboxID == "" {
+				return "", err
+			}
+			log.Printf("Failed to queue expiry reminder to %s: %v", email, err)
+			continue
+		}
+		if firstOutboxID == "" {
+			firstOutboxID = outboxID
+		}
+	}
+
+	return firstOutboxID, nil
+}
+
+// expiryMessage builds the chat notification for an expiring or expired key
+func expiryMessage(key expiringKey, daysUntil int) notify.Message {
+	msg := notify.Message{
+		Title:    fmt.Sprintf("API key %q expires in %d day(s)", key.Name, daysUntil),
+		Text:     "Renew the key before it expires to avoid service interruption.",
+		Severity: notify.SeverityWarning,
+		URL:      managementURL(),
+		Fields: []notify.Field{
+			{Name: "Organization", Value: key.OrganizationName},
+			{Name: "Expiration Date", Value: key.ExpiresAt.Format("January 2, 2006")},
+		},
+	}
+	if daysUntil == 0 {
+		msg.Title = fmt.Sprintf("API key %q has expired", key.Name)
+		msg.Text = "Create a new API key to restore service."
+		msg.Severity = notify.SeverityCritical
+	}
+	return msg
+}
+
+// reminderRecipients returns email -> name for the key owner, falling back to the organization's
+// technical contacts, then its admins
+func (r *ReminderScheduler) reminderRecipients(key expiringKey) (map[string]string, error) {
+	if key.OwnerEmail != "" {
+		return map[string]string{key.OwnerEmail: key.OwnerName}, nil
+	}
+	return r.service.notificationRecipients(key.OrganizationID, models.NotificationEventAPIKeyExpiry)
+}
+
+// uiBaseURL is the admin UI's public address, used for links in emails
+func uiBaseURL() string {
+	baseURL := os.Getenv("UI_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	return strings.TrimRight(baseURL, "/")
+}
+
+// managementURL links reminder emails to the API keys page
+func managementURL() string {
+	return uiBaseURL() + "/api-keys"
+}
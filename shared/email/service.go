@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/like-mike/relai-gateway/shared/i18n"
 	"github.com/like-mike/relai-gateway/shared/models"
 )
 
@@ -30,7 +31,9 @@ func NewService(db *sql.DB) *Service {
 func (s *Service) GetEmailSettings() (*models.EmailSettings, error) {
 	query := `
 		SELECT id, smtp_host, smtp_port, smtp_username, smtp_password, 
-		       smtp_from_name, smtp_from_email, is_enabled, created_at, updated_at
+		       smtp_from_name, smtp_from_email, COALESCE(smtp_tls_mode, 'auto'), is_enabled, created_at, updated_at,
+		       secondary_smtp_host, COALESCE(secondary_smtp_port, 587), secondary_smtp_username, secondary_smtp_password,
+		       COALESCE(secondary_smtp_tls_mode, 'auto')
 		FROM email_settings 
 		ORDER BY created_at DESC 
 		LIMIT 1`
@@ -39,8 +42,10 @@ func (s *Service) GetEmailSettings() (*models.EmailSettings, error) {
 	err := s.db.QueryRow(query).Scan(
 		&settings.ID, &settings.SMTPHost, &settings.SMTPPort,
 		&settings.SMTPUsername, &settings.SMTPPassword,
-		&settings.SMTPFromName, &settings.SMTPFromEmail,
+		&settings.SMTPFromName, &settings.SMTPFromEmail, &settings.SMTPTLSMode,
 		&settings.IsEnabled, &settings.CreatedAt, &settings.UpdatedAt,
+		&settings.SecondarySMTPHost, &settings.SecondarySMTPPort, &settings.SecondarySMTPUsername,
+		&settings.SecondarySMTPPassword, &settings.SecondarySMTPTLSMode,
 	)
 
 	if err != nil {
@@ -52,6 +57,21 @@ func (s *Service) GetEmailSettings() (*models.EmailSettings, error) {
 
 // UpdateEmailSettings updates the email configuration
 func (s *Service) UpdateEmailSettings(req models.UpdateEmailSettingsRequest) error {
+	if req.SMTPTLSMode != nil && !models.IsValidSMTPTLSMode(*req.SMTPTLSMode) {
+		return fmt.Errorf("invalid SMTP TLS mode: %s", *req.SMTPTLSMode)
+	}
+	if req.SecondarySMTPTLSMode != nil && !models.IsValidSMTPTLSMode(*req.SecondarySMTPTLSMode) {
+		return fmt.Errorf("invalid secondary SMTP TLS mode: %s", *req.SecondarySMTPTLSMode)
+	}
+	secondaryPort := 587
+	if req.SecondarySMTPPort != nil {
+		p, err := strconv.Atoi(*req.SecondarySMTPPort)
+		if err != nil {
+			return fmt.Errorf("invalid secondary SMTP port: %v", err)
+		}
+		secondaryPort = p
+	}
+
 	// Get existing settings or create new ones
 	settings, err := s.GetEmailSettings()
 	if err != nil && err != sql.ErrNoRows {
@@ -62,8 +82,10 @@ func (s *Service) UpdateEmailSettings(req models.UpdateEmailSettingsRequest) err
 		// Create new settings
 		query := `
 			INSERT INTO email_settings (smtp_host, smtp_port, smtp_username, smtp_password, 
-			                           smtp_from_name, smtp_from_email, is_enabled)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)`
+			                           smtp_from_name, smtp_from_email, smtp_tls_mode, is_enabled,
+			                           secondary_smtp_host, secondary_smtp_port, secondary_smtp_username,
+			                           secondary_smtp_password, secondary_smtp_tls_mode)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13)`
 
 		host := getStringOrDefault(req.SMTPHost, "smtp.gmail.com")
 		port := 587
@@ -76,12 +98,15 @@ func (s *Service) UpdateEmailSettings(req models.UpdateEmailSettingsRequest) err
 		password := getStringOrDefault(req.SMTPPassword, "")
 		fromName := getStringOrDefault(req.SMTPFromName, "RelAI Gateway")
 		fromEmail := getStringOrDefault(req.SMTPFromEmail, "")
+		tlsMode := getStringOrDefault(req.SMTPTLSMode, models.SMTPTLSAuto)
 		enabled := false
 		if req.IsEnabled != nil {
 			enabled = bool(*req.IsEnabled)
 		}
 
-		_, err = s.db.Exec(query, host, port, username, password, fromName, fromEmail, enabled)
+		_, err = s.db.Exec(query, host, port, username, password, fromName, fromEmail, tlsMode, enabled,
+			getStringOrDefault(req.SecondarySMTPHost, ""), secondaryPort, getStringOrDefault(req.SecondarySMTPUsername, ""),
+			getStringOrDefault(req.SecondarySMTPPassword, ""), getStringOrDefault(req.SecondarySMTPTLSMode, models.SMTPTLSAuto))
 		return err
 	}
 
@@ -112,7 +137,8 @@ func (s *Service) UpdateEmailSettings(req models.UpdateEmailSettingsRequest) err
 		argCount++
 	}
 
-	if req.SMTPPassword != nil {
+	// A masked password means the client echoed back what it was shown; keep the stored one
+	if req.SMTPPassword != nil && !models.IsMaskedSecret(*req.SMTPPassword) {
 		setParts = append(setParts, fmt.Sprintf("smtp_password = $%d", argCount))
 		args = append(args, *req.SMTPPassword)
 		argCount++
@@ -130,6 +156,42 @@ func (s *Service) UpdateEmailSettings(req models.UpdateEmailSettingsRequest) err
 		argCount++
 	}
 
+	if req.SMTPTLSMode != nil {
+		setParts = append(setParts, fmt.Sprintf("smtp_tls_mode = $%d", argCount))
+		args = append(args, *req.SMTPTLSMode)
+		argCount++
+	}
+
+	if req.SecondarySMTPHost != nil {
+		setParts = append(setParts, fmt.Sprintf("secondary_smtp_host = NULLIF($%d, '')", argCount))
+		args = append(args, *req.SecondarySMTPHost)
+		argCount++
+	}
+
+	if req.SecondarySMTPPort != nil {
+		setParts = append(setParts, fmt.Sprintf("secondary_smtp_port = $%d", argCount))
+		args = append(args, secondaryPort)
+		argCount++
+	}
+
+	if req.SecondarySMTPUsername != nil {
+		setParts = append(setParts, fmt.Sprintf("secondary_smtp_username = $%d", argCount))
+		args = append(args, *req.SecondarySMTPUsername)
+		argCount++
+	}
+
+	if req.SecondarySMTPPassword != nil && !models.IsMaskedSecret(*req.SecondarySMTPPassword) {
+		setParts = append(setParts, fmt.Sprintf("secondary_smtp_password = $%d", argCount))
+		args = append(args, *req.SecondarySMTPPassword)
+		argCount++
+	}
+
+	if req.SecondarySMTPTLSMode != nil {
+		setParts = append(setParts, fmt.Sprintf("secondary_smtp_tls_mode = $%d", argCount))
+		args = append(args, *req.SecondarySMTPTLSMode)
+		argCount++
+	}
+
 	if req.IsEnabled != nil {
 		enabled := bool(*req.IsEnabled)
 		setParts = append(setParts, fmt.Sprintf("is_enabled = $%d", argCount))
@@ -151,22 +213,22 @@ func (s *Service) UpdateEmailSettings(req models.UpdateEmailSettingsRequest) err
 	return err
 }
 
-// SendTestEmail sends a test email using the specified template
-func (s *Service) SendTestEmail(req models.SendTestEmailRequest) error {
+// SendTestEmail renders the specified template and queues it for delivery, returning the outbox message ID
+func (s *Service) SendTestEmail(req models.SendTestEmailRequest) (string, error) {
 	// Get email settings
 	settings, err := s.GetEmailSettings()
 	if err != nil {
-		return fmt.Errorf("failed to get email settings: %v", err)
+		return "", fmt.Errorf("failed to get email settings: %v", err)
 	}
 
 	if !settings.IsEnabled {
-		return fmt.Errorf("email service is disabled")
+		return "", fmt.Errorf("email service is disabled")
 	}
 
 	// Get template
 	template, err := s.GetEmailTemplate(req.TemplateID)
 	if err != nil {
-		return fmt.Errorf("failed to get email template: %v", err)
+		return "", fmt.Errorf("failed to get email template: %v", err)
 	}
 
 	// Use test data or default sample data
@@ -181,50 +243,60 @@ func (s *Service) SendTestEmail(req models.SendTestEmailRequest) error {
 			ManagementURL:       "https://your-gateway.com/admin",
 		}
 	}
+	if req.OrganizationID != nil {
+		s.applyBranding(*req.OrganizationID, variables)
+	}
 
 	// Render email content
 	subject, err := s.renderer.RenderText(template.Subject, variables)
 	if err != nil {
-		return fmt.Errorf("failed to render subject: %v", err)
+		return "", fmt.Errorf("failed to render subject: %v", err)
 	}
 
 	htmlBody, err := s.renderer.RenderHTML(template.HTMLBody, variables)
 	if err != nil {
-		return fmt.Errorf("failed to render HTML body: %v", err)
-	}
-
-	// Send email
-	err = s.smtp.SendEmail(SMTPConfig{
-		Host:      settings.SMTPHost,
-		Port:      settings.SMTPPort,
-		Username:  settings.SMTPUsername.String,
-		Password:  settings.SMTPPassword.String,
-		FromName:  settings.SMTPFromName.String,
-		FromEmail: settings.SMTPFromEmail.String,
-	}, EmailMessage{
-		To:      req.RecipientEmail,
-		Subject: subject,
-		Body:    htmlBody,
-		IsHTML:  true,
-	})
-
-	// Log the email attempt
-	s.logEmail(req.RecipientEmail, subject, &req.TemplateID, err)
+		return "", fmt.Errorf("failed to render HTML body: %v", err)
+	}
 
-	return err
+	// Queue for the background sender
+	return s.EnqueueEmail(req.RecipientEmail, subject, htmlBody, &req.TemplateID, req.OrganizationID, req.SendAt)
 }
 
 // GetEmailTemplate retrieves an email template by ID
 func (s *Service) GetEmailTemplate(id string) (*models.EmailTemplate, error) {
 	query := `
-		SELECT id, name, type, subject, html_body, text_body, is_active, created_at, updated_at
+		SELECT id, name, type, subject, html_body, text_body, is_active, locale, current_version, created_at, updated_at
 		FROM email_templates 
 		WHERE id = $1`
 
 	var template models.EmailTemplate
 	err := s.db.QueryRow(query, id).Scan(
 		&template.ID, &template.Name, &template.Type, &template.Subject,
-		&template.HTMLBody, &template.TextBody, &template.IsActive,
+		&template.HTMLBody, &template.TextBody, &template.IsActive, &template.Locale, &template.CurrentVersion,
+		&template.CreatedAt, &template.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// GetActiveEmailTemplateByType returns the most recently updated active template of a type,
+// preferring one in locale and then one in the default locale
+func (s *Service) GetActiveEmailTemplateByType(templateType, locale string) (*models.EmailTemplate, error) {
+	query := `
+		SELECT id, name, type, subject, html_body, text_body, is_active, locale, current_version, created_at, updated_at
+		FROM email_templates
+		WHERE type = $1 AND is_active = true
+		ORDER BY (locale = $2) DESC, (locale = $3) DESC, updated_at DESC
+		LIMIT 1`
+
+	var template models.EmailTemplate
+	err := s.db.QueryRow(query, templateType, i18n.Resolve(locale), i18n.DefaultLocale).Scan(
+		&template.ID, &template.Name, &template.Type, &template.Subject,
+		&template.HTMLBody, &template.TextBody, &template.IsActive, &template.Locale, &template.CurrentVersion,
 		&template.CreatedAt, &template.UpdatedAt,
 	)
 
@@ -235,10 +307,31 @@ func (s *Service) GetEmailTemplate(id string) (*models.EmailTemplate, error) {
 	return &template, nil
 }
 
+// localizedTemplate returns the active template of base's type in locale, or base when there
+// is no translation
+func (s *Service) localizedTemplate(base *models.EmailTemplate, locale string) *models.EmailTemplate {
+	locale = i18n.Resolve(locale)
+	if base == nil || base.Locale == locale {
+		return base
+	}
+
+	localized, err := s.GetActiveEmailTemplateByType(base.Type, locale)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to get %s email template for locale %s: %v", base.Type, locale, err)
+		}
+		return base
+	}
+	if localized.Locale != locale {
+		return base
+	}
+	return localized
+}
+
 // GetAllEmailTemplates retrieves all email templates
 func (s *Service) GetAllEmailTemplates() ([]models.EmailTemplate, error) {
 	query := `
-		SELECT id, name, type, subject, html_body, text_body, is_active, created_at, updated_at
+		SELECT id, name, type, subject, html_body, text_body, is_active, locale, current_version, created_at, updated_at
 		FROM email_templates 
 		ORDER BY created_at DESC`
 
@@ -253,7 +346,7 @@ func (s *Service) GetAllEmailTemplates() ([]models.EmailTemplate, error) {
 		var template models.EmailTemplate
 		err := rows.Scan(
 			&template.ID, &template.Name, &template.Type, &template.Subject,
-			&template.HTMLBody, &template.TextBody, &template.IsActive,
+			&template.HTMLBody, &template.TextBody, &template.IsActive, &template.Locale, &template.CurrentVersion,
 			&template.CreatedAt, &template.UpdatedAt,
 		)
 		if err != nil {
@@ -265,22 +358,30 @@ func (s *Service) GetAllEmailTemplates() ([]models.EmailTemplate, error) {
 	return templates, nil
 }
 
-// CreateEmailTemplate creates a new email template
-func (s *Service) CreateEmailTemplate(req models.CreateEmailTemplateRequest) (*models.EmailTemplate, error) {
+// CreateEmailTemplate creates a new email template, recording its content as version 1
+func (s *Service) CreateEmailTemplate(req models.CreateEmailTemplateRequest, userID string) (*models.EmailTemplate, error) {
 	query := `
-		INSERT INTO email_templates (name, type, subject, html_body, text_body, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, name, type, subject, html_body, text_body, is_active, created_at, updated_at`
+		INSERT INTO email_templates (name, type, subject, html_body, text_body, is_active, locale)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, name, type, subject, html_body, text_body, is_active, locale, current_version, created_at, updated_at`
 
 	isActive := true
 	if req.IsActive != nil {
 		isActive = *req.IsActive
 	}
 
+	locale := i18n.Resolve(req.Locale)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	var template models.EmailTemplate
-	err := s.db.QueryRow(query, req.Name, req.Type, req.Subject, req.HTMLBody, req.TextBody, isActive).Scan(
+	err = tx.QueryRow(query, req.Name, req.Type, req.Subject, req.HTMLBody, req.TextBody, isActive, locale).Scan(
 		&template.ID, &template.Name, &template.Type, &template.Subject,
-		&template.HTMLBody, &template.TextBody, &template.IsActive,
+		&template.HTMLBody, &template.TextBody, &template.IsActive, &template.Locale, &template.CurrentVersion,
 		&template.CreatedAt, &template.UpdatedAt,
 	)
 
@@ -288,11 +389,28 @@ func (s *Service) CreateEmailTemplate(req models.CreateEmailTemplateRequest) (*m
 		return nil, err
 	}
 
+	if _, err := publishNewVersion(tx, template.ID, template.Subject, template.HTMLBody, template.TextBody, nil, userID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return &template, nil
 }
 
-// UpdateEmailTemplate updates an existing email template
-func (s *Service) UpdateEmailTemplate(id string, req models.UpdateEmailTemplateRequest) (*models.EmailTemplate, error) {
+// UpdateEmailTemplate updates an existing email template. Content changes are published as a
+// new version, or saved as the draft when req.Draft is set; name, type and active state aren't
+// versioned and always apply immediately.
+func (s *Service) UpdateEmailTemplate(id string, req models.UpdateEmailTemplateRequest, userID string) (*models.EmailTemplate, error) {
+	if req.Draft {
+		if _, err := s.SaveEmailTemplateDraft(id, req, userID); err != nil {
+			return nil, err
+		}
+		req = models.UpdateEmailTemplateRequest{Name: req.Name, Type: req.Type, IsActive: req.IsActive, Locale: req.Locale}
+	}
+
 	setParts := []string{}
 	args := []interface{}{}
 	argCount := 1
@@ -333,6 +451,12 @@ func (s *Service) UpdateEmailTemplate(id string, req models.UpdateEmailTemplateR
 		argCount++
 	}
 
+	if req.Locale != nil {
+		setParts = append(setParts, fmt.Sprintf("locale = $%d", argCount))
+		args = append(args, i18n.Resolve(*req.Locale))
+		argCount++
+	}
+
 	if len(setParts) == 0 {
 		return s.GetEmailTemplate(id) // Nothing to update, return existing
 	}
@@ -343,14 +467,24 @@ func (s *Service) UpdateEmailTemplate(id string, req models.UpdateEmailTemplateR
 		UPDATE email_templates 
 		SET %s 
 		WHERE id = $%d
-		RETURNING id, name, type, subject, html_body, text_body, is_active, created_at, updated_at`,
+		RETURNING id, name, type, subject, html_body, text_body, is_active, locale, current_version, created_at, updated_at`,
 		strings.Join(setParts, ", "), argCount)
 	args = append(args, id)
 
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockEmailTemplate(tx, id); err != nil {
+		return nil, err
+	}
+
 	var template models.EmailTemplate
-	err := s.db.QueryRow(query, args...).Scan(
+	err = tx.QueryRow(query, args...).Scan(
 		&template.ID, &template.Name, &template.Type, &template.Subject,
-		&template.HTMLBody, &template.TextBody, &template.IsActive,
+		&template.HTMLBody, &template.TextBody, &template.IsActive, &template.Locale, &template.CurrentVersion,
 		&template.CreatedAt, &template.UpdatedAt,
 	)
 
@@ -358,11 +492,26 @@ func (s *Service) UpdateEmailTemplate(id string, req models.UpdateEmailTemplateR
 		return nil, err
 	}
 
+	if req.Subject != nil || req.HTMLBody != nil || req.TextBody != nil {
+		version, err := publishNewVersion(tx, id, template.Subject, template.HTMLBody, template.TextBody, nil, userID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`UPDATE email_templates SET current_version = $2 WHERE id = $1`, id, version.Version); err != nil {
+			return nil, err
+		}
+		template.CurrentVersion = version.Version
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
 	return &template, nil
 }
 
-// logEmail records an email send attempt
-func (s *Service) logEmail(recipient, subject string, templateID *string, sendErr error) {
+// logEmail records the outcome of sending an outbox message, and the server that delivered it
+func (s *Service) logEmail(outboxID, recipient, subject string, templateID *string, server string, sendErr error) {
 	status := "sent"
 	var errorMessage *string
 
@@ -373,15 +522,10 @@ func (s *Service) logEmail(recipient, subject string, templateID *string, sendEr
 	}
 
 	query := `
-		INSERT INTO email_logs (recipient_email, subject, template_id, status, error_message, sent_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`
-
-	var sentAt interface{}
-	if sendErr == nil {
-		sentAt = "NOW()"
-	}
+		INSERT INTO email_logs (recipient_email, subject, template_id, status, error_message, sent_at, outbox_id, smtp_server)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $6 THEN NOW() END, $7, NULLIF($8, ''))`
 
-	_, err := s.db.Exec(query, recipient, subject, templateID, status, errorMessage, sentAt)
+	_, err := s.db.Exec(query, recipient, subject, templateID, status, errorMessage, sendErr == nil, outboxID, server)
 	if err != nil {
 		log.Printf("Failed to log email: %v", err)
 	}
@@ -1,9 +1,20 @@
 package email
 
 import (
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"mime"
+	"net"
 	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
 )
 
 // SMTPConfig holds SMTP server configuration
@@ -14,6 +25,64 @@ type SMTPConfig struct {
 	Password  string
 	FromName  string
 	FromEmail string
+	// TLSMode is one of models.SMTPTLSModes; empty means auto
+	TLSMode string
+	// DKIM signs outgoing messages when set
+	DKIM *DKIMSigner
+}
+
+// NewSMTPConfig builds the client configuration from stored email settings
+func NewSMTPConfig(settings *models.EmailSettings) SMTPConfig {
+	return SMTPConfig{
+		Host:      settings.SMTPHost,
+		Port:      settings.SMTPPort,
+		Username:  settings.SMTPUsername.String,
+		Password:  settings.SMTPPassword.String,
+		FromName:  settings.SMTPFromName.String,
+		FromEmail: settings.SMTPFromEmail.String,
+		TLSMode:   settings.SMTPTLSMode,
+	}
+}
+
+// NewSecondarySMTPConfig builds the configuration of the secondary server from stored email
+// settings, sending like primary does, or returns nil when there is none
+func NewSecondarySMTPConfig(settings *models.EmailSettings, primary SMTPConfig) *SMTPConfig {
+	if !settings.HasSecondarySMTP() {
+		return nil
+	}
+	config := primary
+	config.Host = settings.SecondarySMTPHost.String
+	config.Port = settings.SecondarySMTPPort
+	config.Username = settings.SecondarySMTPUsername.String
+	config.Password = settings.SecondarySMTPPassword.String
+	config.TLSMode = settings.SecondarySMTPTLSMode
+	return &config
+}
+
+// serverError is a failure to reach or log in to an SMTP server, as opposed to the server
+// refusing the message; another server may still deliver it
+type serverError struct {
+	err error
+}
+
+func (e *serverError) Error() string { return e.err.Error() }
+func (e *serverError) Unwrap() error { return e.err }
+
+// isServerError reports whether err means the server couldn't be used at all
+func isServerError(err error) bool {
+	var target *serverError
+	return errors.As(err, &target)
+}
+
+// implicitTLS reports whether to connect over TLS from the start rather than upgrading with STARTTLS
+func (c SMTPConfig) implicitTLS() bool {
+	switch c.TLSMode {
+	case models.SMTPTLSImplicit:
+		return true
+	case models.SMTPTLSAuto, "":
+		return c.Port == 465
+	}
+	return false
 }
 
 // EmailMessage represents an email to be sent
@@ -22,6 +91,8 @@ type EmailMessage struct {
 	Subject string
 	Body    string
 	IsHTML  bool
+	// Headers are extra message headers, e.g. List-Unsubscribe
+	Headers map[string]string
 }
 
 // SMTPClient handles sending emails via SMTP
@@ -34,68 +105,175 @@ func NewSMTPClient() *SMTPClient {
 
 // SendEmail sends an email using the provided SMTP configuration
 func (c *SMTPClient) SendEmail(config SMTPConfig, message EmailMessage) error {
-	// Create the email headers and body
-	var body string
-	if message.IsHTML {
-		body = fmt.Sprintf("MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\nFrom: %s <%s>\nTo: %s\nSubject: %s\n\n%s",
-			config.FromName, config.FromEmail, message.To, message.Subject, message.Body)
-	} else {
-		body = fmt.Sprintf("From: %s <%s>\nTo: %s\nSubject: %s\n\n%s",
-			config.FromName, config.FromEmail, message.To, message.Subject, message.Body)
+	msg := buildMessage(config, message)
+
+	if config.DKIM != nil {
+		signed, err := config.DKIM.Sign(msg)
+		if err != nil {
+			return fmt.Errorf("failed to DKIM-sign email: %v", err)
+		}
+		msg = signed
 	}
 
-	// Send using STARTTLS
-	err := c.sendMailSTARTTLS(config, config.FromEmail, []string{message.To}, []byte(body), false)
-	if err != nil {
-		return fmt.Errorf("failed to send email: %v", err)
+	if err := c.send(config, config.FromEmail, []string{message.To}, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
 	}
 
 	return nil
 }
 
-// sendMailSTARTTLS sends email using STARTTLS (proper method for Gmail)
-func (c *SMTPClient) sendMailSTARTTLS(config SMTPConfig, from string, to []string, msg []byte, testOnly bool) error {
-	// Set up authentication
-	auth := smtp.PlainAuth("", config.Username, config.Password, config.Host)
+// SendEmailWithFailover sends through the primary server and, when it can't be reached or
+// refuses the login, through the secondary one if configured. It returns which server delivered
+// the message. A message the primary refuses isn't retried elsewhere.
+func (c *SMTPClient) SendEmailWithFailover(primary SMTPConfig, secondary *SMTPConfig, message EmailMessage) (string, error) {
+	err := c.SendEmail(primary, message)
+	if err == nil {
+		return models.SMTPServerPrimary, nil
+	}
+	if secondary == nil || !isServerError(err) {
+		return "", err
+	}
+
+	if secondaryErr := c.SendEmail(*secondary, message); secondaryErr != nil {
+		return "", fmt.Errorf("primary SMTP server: %v; secondary SMTP server: %w", err, secondaryErr)
+	}
+	return models.SMTPServerSecondary, nil
+}
+
+// buildMessage renders the message with CRLF line endings, as SMTP and DKIM require
+func buildMessage(config SMTPConfig, message EmailMessage) []byte {
+	contentType := "text/plain"
+	if message.IsHTML {
+		contentType = "text/html"
+	}
+
+	_, domain, _ := strings.Cut(config.FromEmail, "@")
+	if domain == "" {
+		domain = "localhost"
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	headers := []string{
+		fmt.Sprintf("From: %s <%s>", mime.QEncoding.Encode("utf-8", config.FromName), config.FromEmail),
+		"To: " + message.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", message.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%s@%s>", hex.EncodeToString(id), domain),
+		"MIME-Version: 1.0",
+		fmt.Sprintf("Content-Type: %s; charset=\"UTF-8\"", contentType),
+	}
+
+	names := make([]string, 0, len(message.Headers))
+	for name := range message.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		headers = append(headers, name+": "+message.Headers[name])
+	}
+
+	body := strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n")
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + body)
+}
 
-	// Gmail SMTP server address
-	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
+// dial connects and secures the session according to config.TLSMode: implicit TLS (port 465),
+// required STARTTLS, or STARTTLS whenever the server offers it
+func (c *SMTPClient) dial(config SMTPConfig) (*smtp.Client, error) {
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	tlsConfig := &tls.Config{ServerName: config.Host}
 
-	// For Gmail, use the built-in smtp.SendMail which handles STARTTLS properly
-	if !testOnly {
-		return smtp.SendMail(addr, auth, from, to, msg)
+	if config.implicitTLS() {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, &serverError{fmt.Errorf("failed to connect to SMTP server over TLS: %v", err)}
+		}
+		client, err := smtp.NewClient(conn, config.Host)
+		if err != nil {
+			conn.Close()
+			return nil, &serverError{fmt.Errorf("failed to start SMTP session: %v", err)}
+		}
+		return client, nil
 	}
 
-	// For connection testing, manually establish connection
 	client, err := smtp.Dial(addr)
 	if err != nil {
-		return fmt.Errorf("failed to connect to SMTP server: %v", err)
+		return nil, &serverError{fmt.Errorf("failed to connect to SMTP server: %v", err)}
 	}
-	defer client.Quit()
 
-	// Start TLS if available
 	if ok, _ := client.Extension("STARTTLS"); ok {
-		tlsConfig := &tls.Config{
-			ServerName: config.Host,
-		}
-		if err = client.StartTLS(tlsConfig); err != nil {
-			return fmt.Errorf("failed to start TLS: %v", err)
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, &serverError{fmt.Errorf("failed to start TLS: %v", err)}
 		}
+	} else if config.TLSMode == models.SMTPTLSStartTLS {
+		client.Close()
+		return nil, &serverError{fmt.Errorf("SMTP server does not support STARTTLS")}
+	}
+
+	return client, nil
+}
+
+// authenticate logs in when credentials are configured and the server supports AUTH
+func (c *SMTPClient) authenticate(client *smtp.Client, config SMTPConfig) error {
+	if config.Username == "" {
+		return nil
+	}
+	if ok, _ := client.Extension("AUTH"); !ok {
+		return nil
+	}
+	if err := client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host)); err != nil {
+		return &serverError{fmt.Errorf("SMTP authentication failed: %v", err)}
+	}
+	return nil
+}
+
+// send delivers msg over a new session
+func (c *SMTPClient) send(config SMTPConfig, from string, to []string, msg []byte) error {
+	client, err := c.dial(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := c.authenticate(client, config); err != nil {
+		return err
 	}
 
-	// Authenticate
-	if auth != nil {
-		if ok, _ := client.Extension("AUTH"); ok {
-			if err = client.Auth(auth); err != nil {
-				return fmt.Errorf("SMTP authentication failed: %v", err)
-			}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
 }
 
 // TestConnection tests the SMTP connection with the provided configuration
 func (c *SMTPClient) TestConnection(config SMTPConfig) error {
-	return c.sendMailSTARTTLS(config, "", []string{}, []byte(""), true)
+	client, err := c.dial(config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := c.authenticate(client, config); err != nil {
+		return err
+	}
+
+	return client.Quit()
 }
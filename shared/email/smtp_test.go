@@ -0,0 +1,141 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// fakeSMTPServer accepts mail on a local port, refusing every recipient when rejectRecipients
+// is set, and counts the messages delivered
+type fakeSMTPServer struct {
+	listener         net.Listener
+	rejectRecipients bool
+	delivered        atomic.Int32
+}
+
+func newFakeSMTPServer(t *testing.T, rejectRecipients bool) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	s := &fakeSMTPServer{listener: listener, rejectRecipients: rejectRecipients}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO", "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			if s.rejectRecipients {
+				reply("550 No such user")
+			} else {
+				reply("250 OK")
+			}
+		case "DATA":
+			reply("354 Go ahead")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+			}
+			s.delivered.Add(1)
+			reply("250 Queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) config() SMTPConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return SMTPConfig{Host: "127.0.0.1", Port: addr.Port, FromEmail: "noreply@example.com"}
+}
+
+// unreachableSMTPConfig points at a local port nothing listens on
+func unreachableSMTPConfig(t *testing.T) SMTPConfig {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return SMTPConfig{Host: "127.0.0.1", Port: port, FromEmail: "noreply@example.com"}
+}
+
+func TestSendEmailWithFailover(t *testing.T) {
+	client := NewSMTPClient()
+	message := EmailMessage{To: "admin@example.com", Subject: "Quota alert", Body: "90% used"}
+
+	primary := newFakeSMTPServer(t, false)
+	secondary := newFakeSMTPServer(t, false)
+	secondaryConfig := secondary.config()
+
+	server, err := client.SendEmailWithFailover(primary.config(), &secondaryConfig, message)
+	if err != nil || server != models.SMTPServerPrimary {
+		t.Fatalf("SendEmailWithFailover() = %q, %v; want the primary", server, err)
+	}
+
+	server, err = client.SendEmailWithFailover(unreachableSMTPConfig(t), &secondaryConfig, message)
+	if err != nil || server != models.SMTPServerSecondary {
+		t.Fatalf("SendEmailWithFailover() = %q, %v; want the secondary when the primary is down", server, err)
+	}
+	if primary.delivered.Load() != 1 || secondary.delivered.Load() != 1 {
+		t.Errorf("delivered %d by the primary and %d by the secondary, want 1 each",
+			primary.delivered.Load(), secondary.delivered.Load())
+	}
+
+	if _, err := client.SendEmailWithFailover(unreachableSMTPConfig(t), nil, message); err == nil {
+		t.Error("expected an error with the primary down and no secondary")
+	}
+}
+
+func TestSendEmailWithFailoverKeepsRefusedMessages(t *testing.T) {
+	client := NewSMTPClient()
+	primary := newFakeSMTPServer(t, true)
+	secondary := newFakeSMTPServer(t, false)
+	secondaryConfig := secondary.config()
+
+	_, err := client.SendEmailWithFailover(primary.config(), &secondaryConfig, EmailMessage{To: "nobody@example.com"})
+	if err == nil {
+		t.Fatal("expected the refused recipient to fail")
+	}
+	if secondary.delivered.Load() != 0 {
+		t.Error("a message the primary refused was sent through the secondary")
+	}
+}
@@ -66,6 +66,19 @@ func (r *TemplateRenderer) GetSampleVariables() models.EmailTemplateVariables {
 		OrganizationName:    "Acme Corporation",
 		DaysUntilExpiration: 7,
 		ManagementURL:       "https://your-gateway.com/admin",
+		UnsubscribeURL:      "https://your-gateway.com/unsubscribe?token=sample",
+		LogoURL:             "https://your-gateway.com/logo.png",
+		BrandColor:          "#2563eb",
+		BrandName:           "Acme Corporation",
+		Threshold:           90,
+		UsagePercent:        "91.2%",
+		UsedTokens:          "912.0K",
+		TotalQuota:          "1.0M",
+		ResetDate:           "February 1, 2024",
+		TopAPIKeys: []models.APIKeyUsageSummary{
+			{Name: "production-api-key", Tokens: "640.5K"},
+			{Name: "staging-api-key", Tokens: "271.5K"},
+		},
 	}
 }
 
@@ -95,5 +108,15 @@ func (r *TemplateRenderer) GetAvailableVariables() map[string]string {
 		"{{.OrganizationName}}":    "The name of the organization",
 		"{{.DaysUntilExpiration}}": "Number of days until the API key expires",
 		"{{.ManagementURL}}":       "URL to the API key management interface",
+		"{{.UnsubscribeURL}}":      "Link that unsubscribes the recipient from this notification (added as a footer if unused)",
+		"{{.LogoURL}}":             "The organization's logo URL (falls back to the theme logo)",
+		"{{.BrandColor}}":          "The organization's primary color, e.g. for buttons",
+		"{{.BrandName}}":           "The organization's sender name (falls back to the theme company name)",
+		"{{.Threshold}}":           "Quota percentage threshold that was crossed (usage emails)",
+		"{{.UsagePercent}}":        "Current percentage of the token quota used (usage emails)",
+		"{{.UsedTokens}}":          "Tokens used in the current quota period (usage emails)",
+		"{{.TotalQuota}}":          "Total token quota for the period (usage emails)",
+		"{{.ResetDate}}":           "Date the token quota resets (usage emails)",
+		"{{range .TopAPIKeys}}":    "Top consuming API keys, each with .Name and .Tokens (usage emails)",
 	}
 }
@@ -0,0 +1,108 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+)
+
+// ErrInvalidUnsubscribeToken is returned for tampered or malformed unsubscribe tokens
+var ErrInvalidUnsubscribeToken = errors.New("invalid unsubscribe token")
+
+// unsubscribeSecret returns the signing key: UNSUBSCRIBE_SECRET if set, else the key generated
+// with the email settings
+func (s *Service) unsubscribeSecret() (string, error) {
+	if secret := os.Getenv("UNSUBSCRIBE_SECRET"); secret != "" {
+		return secret, nil
+	}
+
+	secret, err := db.GetUnsubscribeSecret(s.db)
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", fmt.Errorf("no unsubscribe secret configured")
+	}
+	return secret, nil
+}
+
+// UnsubscribeURL returns a signed link that unsubscribes recipient from event ("" for all
+// notifications), or "" if links can't be signed
+func (s *Service) UnsubscribeURL(recipient, event string) string {
+	secret, err := s.unsubscribeSecret()
+	if err != nil {
+		return ""
+	}
+	return uiBaseURL() + "/unsubscribe?token=" + url.QueryEscape(signUnsubscribeToken(secret, recipient, event))
+}
+
+// ParseUnsubscribeToken verifies a token from an unsubscribe link and returns its recipient and event
+func (s *Service) ParseUnsubscribeToken(token string) (string, string, error) {
+	secret, err := s.unsubscribeSecret()
+	if err != nil {
+		return "", "", err
+	}
+	return verifyUnsubscribeToken(secret, token)
+}
+
+// signUnsubscribeToken encodes "recipient\nevent" with an HMAC-SHA256 signature
+func signUnsubscribeToken(secret, recipient, event string) string {
+	payload := strings.ToLower(recipient) + "\n" + event
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifyUnsubscribeToken(secret, token string) (string, string, error) {
+	encodedPayload, encodedSig, found := strings.Cut(token, ".")
+	if !found {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+
+	recipient, event, found := strings.Cut(string(payload), "\n")
+	if !found || recipient == "" {
+		return "", "", ErrInvalidUnsubscribeToken
+	}
+	return recipient, event, nil
+}
+
+// withUnsubscribeFooter appends an unsubscribe link to htmlBody unless the template already
+// includes it
+func withUnsubscribeFooter(htmlBody, unsubscribeURL string) string {
+	if unsubscribeURL == "" || strings.Contains(htmlBody, html.EscapeString(unsubscribeURL)) || strings.Contains(htmlBody, unsubscribeURL) {
+		return htmlBody
+	}
+
+	footer := fmt.Sprintf(`<p style="font-size:12px;color:#888;margin-top:30px">Don't want these emails? <a href="%s" style="color:#888">Unsubscribe</a>.</p>`,
+		html.EscapeString(unsubscribeURL))
+
+	if i := strings.LastIndex(strings.ToLower(htmlBody), "</body>"); i >= 0 {
+		return htmlBody[:i] + footer + htmlBody[i:]
+	}
+	return htmlBody + footer
+}
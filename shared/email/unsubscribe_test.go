@@ -0,0 +1,51 @@
+package email
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeTokenRoundTrip(t *testing.T) {
+	token := signUnsubscribeToken("secret", "Admin@Example.com", "quota_usage")
+
+	recipient, event, err := verifyUnsubscribeToken("secret", token)
+	if err != nil {
+		t.Fatalf("verifyUnsubscribeToken() error = %v", err)
+	}
+	if recipient != "admin@example.com" || event != "quota_usage" {
+		t.Errorf("got (%q, %q), want (admin@example.com, quota_usage)", recipient, event)
+	}
+}
+
+func TestUnsubscribeTokenRejectsTampering(t *testing.T) {
+	token := signUnsubscribeToken("secret", "admin@example.com", "")
+
+	if _, _, err := verifyUnsubscribeToken("other-secret", token); err != ErrInvalidUnsubscribeToken {
+		t.Errorf("wrong secret: err = %v, want ErrInvalidUnsubscribeToken", err)
+	}
+
+	forged := signUnsubscribeToken("secret", "victim@example.com", "")
+	payload, _, _ := strings.Cut(forged, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	if _, _, err := verifyUnsubscribeToken("secret", payload+"."+sig); err != ErrInvalidUnsubscribeToken {
+		t.Errorf("swapped payload: err = %v, want ErrInvalidUnsubscribeToken", err)
+	}
+
+	if _, _, err := verifyUnsubscribeToken("secret", "garbage"); err != ErrInvalidUnsubscribeToken {
+		t.Errorf("malformed: err = %v, want ErrInvalidUnsubscribeToken", err)
+	}
+}
+
+func TestWithUnsubscribeFooter(t *testing.T) {
+	url := "https://gw.example.com/unsubscribe?token=abc"
+
+	got := withUnsubscribeFooter("<html><body><p>Hi</p></body></html>", url)
+	if !strings.Contains(got, url+`"`) || !strings.HasSuffix(got, "</body></html>") {
+		t.Errorf("footer not inserted before </body>: %s", got)
+	}
+
+	body := `<a href="` + url + `">Unsubscribe</a>`
+	if got := withUnsubscribeFooter(body, url); got != body {
+		t.Errorf("footer added although the template links it: %s", got)
+	}
+}
@@ -0,0 +1,299 @@
+package email
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// ErrNoDraft is returned when publishing or discarding a template that has no draft
+var ErrNoDraft = errors.New("template has no draft")
+
+const templateVersionColumns = `id, template_id, version, subject, html_body, text_body, status, source_version,
+	created_by, created_at, updated_at, published_at`
+
+func scanTemplateVersion(row interface{ Scan(...interface{}) error }) (*models.EmailTemplateVersion, error) {
+	var v models.EmailTemplateVersion
+	err := row.Scan(&v.ID, &v.TemplateID, &v.Version, &v.Subject, &v.HTMLBody, &v.TextBody, &v.Status,
+		&v.SourceVersion, &v.CreatedBy, &v.CreatedAt, &v.UpdatedAt, &v.PublishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetEmailTemplateVersions returns a template's version history, newest first
+func (s *Service) GetEmailTemplateVersions(templateID string) ([]models.EmailTemplateVersion, error) {
+	rows, err := s.db.Query(`
+		SELECT `+templateVersionColumns+`
+		FROM email_template_versions
+		WHERE template_id = $1
+		ORDER BY version DESC`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []models.EmailTemplateVersion{}
+	for rows.Next() {
+		v, err := scanTemplateVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *v)
+	}
+
+	return versions, rows.Err()
+}
+
+// GetEmailTemplateVersion returns a single version of a template
+func (s *Service) GetEmailTemplateVersion(templateID string, version int) (*models.EmailTemplateVersion, error) {
+	return scanTemplateVersion(s.db.QueryRow(`
+		SELECT `+templateVersionColumns+`
+		FROM email_template_versions
+		WHERE template_id = $1 AND version = $2`, templateID, version))
+}
+
+// DiffEmailTemplateVersions compares the content of two versions of a template
+func (s *Service) DiffEmailTemplateVersions(templateID string, from, to int) (*models.EmailTemplateDiff, error) {
+	fromVersion, err := s.GetEmailTemplateVersion(templateID, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.GetEmailTemplateVersion(templateID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.EmailTemplateDiff{
+		FromVersion: from,
+		ToVersion:   to,
+		Subject:     diffLines(fromVersion.Subject, toVersion.Subject),
+		HTMLBody:    diffLines(fromVersion.HTMLBody, toVersion.HTMLBody),
+		TextBody:    diffLines(getStringOrDefault(fromVersion.TextBody, ""), getStringOrDefault(toVersion.TextBody, "")),
+	}, nil
+}
+
+// PreviewEmailTemplateVersion renders a version (typically the draft) with sample data
+func (s *Service) PreviewEmailTemplateVersion(templateID string, version int) (string, string, error) {
+	v, err := s.GetEmailTemplateVersion(templateID, version)
+	if err != nil {
+		return "", "", err
+	}
+	return s.renderer.PreviewTemplate(v.Subject, v.HTMLBody)
+}
+
+// SaveEmailTemplateDraft stores content changes as the template's draft without touching the
+// live template. Fields left nil keep the current draft's (or the published) content.
+func (s *Service) SaveEmailTemplateDraft(templateID string, req models.UpdateEmailTemplateRequest, userID string) (*models.EmailTemplateVersion, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	template, err := lockEmailTemplate(tx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	draft, err := scanTemplateVersion(tx.QueryRow(`
+		SELECT `+templateVersionColumns+`
+		FROM email_template_versions
+		WHERE template_id = $1 AND status = 'draft'`, templateID))
+	if err == sql.ErrNoRows {
+		draft = &models.EmailTemplateVersion{Subject: template.Subject, HTMLBody: template.HTMLBody, TextBody: template.TextBody}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if req.Subject != nil {
+		draft.Subject = *req.Subject
+	}
+	if req.HTMLBody != nil {
+		draft.HTMLBody = *req.HTMLBody
+	}
+	if req.TextBody != nil {
+		draft.TextBody = req.TextBody
+	}
+
+	if err := s.renderer.ValidateTemplate(draft.HTMLBody); err != nil {
+		return nil, err
+	}
+
+	var row *sql.Row
+	if draft.ID != "" {
+		row = tx.QueryRow(`
+			UPDATE email_template_versions
+			SET subject = $2, html_body = $3, text_body = $4, created_by = NULLIF($5, '')::uuid, updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+templateVersionColumns, draft.ID, draft.Subject, draft.HTMLBody, draft.TextBody, userID)
+	} else {
+		row = tx.QueryRow(`
+			INSERT INTO email_template_versions (template_id, version, subject, html_body, text_body, status, created_by)
+			VALUES ($1, (SELECT COALESCE(MAX(version), 0) + 1 FROM email_template_versions WHERE template_id = $1),
+			        $2, $3, $4, 'draft', NULLIF($5, '')::uuid)
+			RETURNING `+templateVersionColumns, templateID, draft.Subject, draft.HTMLBody, draft.TextBody, userID)
+	}
+
+	saved, err := scanTemplateVersion(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save draft: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+// PublishEmailTemplateDraft makes the draft the live template content
+func (s *Service) PublishEmailTemplateDraft(templateID string) (*models.EmailTemplate, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockEmailTemplate(tx, templateID); err != nil {
+		return nil, err
+	}
+
+	draft, err := scanTemplateVersion(tx.QueryRow(`
+		SELECT `+templateVersionColumns+`
+		FROM email_template_versions
+		WHERE template_id = $1 AND status = 'draft'`, templateID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNoDraft
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := archivePublishedVersion(tx, templateID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE email_template_versions
+		SET status = 'published', published_at = NOW(), updated_at = NOW()
+		WHERE id = $1`, draft.ID); err != nil {
+		return nil, fmt.Errorf("failed to publish draft: %v", err)
+	}
+
+	if err := setLiveTemplateContent(tx, templateID, draft); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetEmailTemplate(templateID)
+}
+
+// DiscardEmailTemplateDraft deletes the template's draft
+func (s *Service) DiscardEmailTemplateDraft(templateID string) error {
+	result, err := s.db.Exec(`DELETE FROM email_template_versions WHERE template_id = $1 AND status = 'draft'`, templateID)
+	if err != nil {
+		return err
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNoDraft
+	}
+	return nil
+}
+
+// RollbackEmailTemplate republishes a previous version's content as a new version, so the
+// history is never rewritten
+func (s *Service) RollbackEmailTemplate(templateID string, version int, userID string) (*models.EmailTemplate, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockEmailTemplate(tx, templateID); err != nil {
+		return nil, err
+	}
+
+	target, err := scanTemplateVersion(tx.QueryRow(`
+		SELECT `+templateVersionColumns+`
+		FROM email_template_versions
+		WHERE template_id = $1 AND version = $2 AND status <> 'draft'`, templateID, version))
+	if err != nil {
+		return nil, err
+	}
+
+	published, err := publishNewVersion(tx, templateID, target.Subject, target.HTMLBody, target.TextBody, &target.Version, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setLiveTemplateContent(tx, templateID, published); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetEmailTemplate(templateID)
+}
+
+// lockEmailTemplate locks the template row so concurrent edits allocate version numbers in turn
+func lockEmailTemplate(tx *sql.Tx, templateID string) (*models.EmailTemplate, error) {
+	var template models.EmailTemplate
+	err := tx.QueryRow(`
+		SELECT id, subject, html_body, text_body, current_version
+		FROM email_templates
+		WHERE id = $1
+		FOR UPDATE`, templateID).Scan(&template.ID, &template.Subject, &template.HTMLBody, &template.TextBody, &template.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func archivePublishedVersion(tx *sql.Tx, templateID string) error {
+	_, err := tx.Exec(`
+		UPDATE email_template_versions
+		SET status = 'archived', updated_at = NOW()
+		WHERE template_id = $1 AND status = 'published'`, templateID)
+	if err != nil {
+		return fmt.Errorf("failed to archive published version: %v", err)
+	}
+	return nil
+}
+
+// publishNewVersion archives the current published version and records content as the next one
+func publishNewVersion(tx *sql.Tx, templateID, subject, htmlBody string, textBody *string, sourceVersion *int, userID string) (*models.EmailTemplateVersion, error) {
+	if err := archivePublishedVersion(tx, templateID); err != nil {
+		return nil, err
+	}
+
+	v, err := scanTemplateVersion(tx.QueryRow(`
+		INSERT INTO email_template_versions (template_id, version, subject, html_body, text_body, status, source_version, created_by, published_at)
+		VALUES ($1, (SELECT COALESCE(MAX(version), 0) + 1 FROM email_template_versions WHERE template_id = $1),
+		        $2, $3, $4, 'published', $5, NULLIF($6, '')::uuid, NOW())
+		RETURNING `+templateVersionColumns, templateID, subject, htmlBody, textBody, sourceVersion, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record template version: %v", err)
+	}
+	return v, nil
+}
+
+// setLiveTemplateContent copies a published version onto the template used for sending
+func setLiveTemplateContent(tx *sql.Tx, templateID string, v *models.EmailTemplateVersion) error {
+	_, err := tx.Exec(`
+		UPDATE email_templates
+		SET subject = $2, html_body = $3, text_body = $4, current_version = $5, updated_at = NOW()
+		WHERE id = $1`, templateID, v.Subject, v.HTMLBody, v.TextBody, v.Version)
+	if err != nil {
+		return fmt.Errorf("failed to update template: %v", err)
+	}
+	return nil
+}
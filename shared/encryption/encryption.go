@@ -0,0 +1,149 @@
+// Package encryption seals secrets stored in the database with AES-256-GCM, keyed from the
+// ENCRYPTION_KEY secret. While the key is rotated, values sealed with the key in
+// ENCRYPTION_KEY_PREVIOUS can still be opened.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/secretfile"
+)
+
+// prefix marks values sealed by this package, leaving room for key rotation
+const prefix = "v1:"
+
+var (
+	// ErrNoKey is returned when ENCRYPTION_KEY is not configured
+	ErrNoKey = errors.New("ENCRYPTION_KEY is not set")
+	// ErrInvalidCiphertext is returned for values that weren't sealed with the current key
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// key derives the AES-256 key from ENCRYPTION_KEY, so any sufficiently random string works.
+// It's read on every use, so a rotated ENCRYPTION_KEY_FILE takes effect without a restart.
+func key() ([]byte, error) {
+	secret := secretfile.Get("ENCRYPTION_KEY")
+	if secret == "" {
+		return nil, ErrNoKey
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:], nil
+}
+
+// previousKey derives the key being rotated away from, if any
+func previousKey() []byte {
+	secret := secretfile.Get("ENCRYPTION_KEY_PREVIOUS")
+	if secret == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// openWithRotation opens ciphertext with the current key, falling back to the previous one
+func openWithRotation(ciphertext string, derive func(master []byte) []byte) (string, error) {
+	k, err := key()
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(derive(k), ciphertext)
+	if err == ErrInvalidCiphertext {
+		if previous := previousKey(); previous != nil {
+			return open(derive(previous), ciphertext)
+		}
+	}
+	return plaintext, err
+}
+
+// Encrypt seals plaintext for storage
+func Encrypt(plaintext string) (string, error) {
+	k, err := key()
+	if err != nil {
+		return "", err
+	}
+	return seal(k, plaintext)
+}
+
+// Decrypt opens a value produced by Encrypt
+func Decrypt(ciphertext string) (string, error) {
+	return openWithRotation(ciphertext, func(master []byte) []byte { return master })
+}
+
+// EncryptForOrganization seals plaintext with a key only the organization's data is sealed
+// with, so a value copied to another organization's row can't be opened there
+func EncryptForOrganization(orgID, plaintext string) (string, error) {
+	k, err := key()
+	if err != nil {
+		return "", err
+	}
+	return seal(organizationKey(k, orgID), plaintext)
+}
+
+// DecryptForOrganization opens a value produced by EncryptForOrganization for the same
+// organization
+func DecryptForOrganization(orgID, ciphertext string) (string, error) {
+	return openWithRotation(ciphertext, func(master []byte) []byte { return organizationKey(master, orgID) })
+}
+
+// organizationKey derives an organization's key from the master key
+func organizationKey(master []byte, orgID string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("organization:" + orgID))
+	return mac.Sum(nil)
+}
+
+func seal(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func open(key []byte, ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, prefix) {
+		return "", ErrInvalidCiphertext
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, prefix))
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, data := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
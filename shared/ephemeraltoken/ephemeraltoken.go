@@ -0,0 +1,107 @@
+// Package ephemeraltoken signs the short-lived tokens clients get from POST /v1/auth/ephemeral
+// in exchange for an API key, to hand to browsers and mobile apps. A token acts as its parent
+// key with narrower scopes, and the gateway checks it from its signed claims alone, without a
+// database lookup. Tokens are "ek-" followed by an HS256 JWT keyed with GATEWAY_TOKEN_SIGNING_KEY,
+// which every gateway replica must share.
+package ephemeraltoken
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	prefix   = "ek-"
+	issuer   = "relai-gateway"
+	audience = "relai-gateway-client"
+)
+
+// ErrNotConfigured means GATEWAY_TOKEN_SIGNING_KEY is not set, so tokens can't be signed or checked
+var ErrNotConfigured = errors.New("GATEWAY_TOKEN_SIGNING_KEY is not set")
+
+// Claims identify the parent API key a token acts as and the scopes it is limited to
+type Claims struct {
+	OrganizationID string   `json:"org"`
+	Scopes         []string `json:"scp"`
+	jwt.RegisteredClaims
+}
+
+// APIKeyID is the parent key the token acts as
+func (c *Claims) APIKeyID() string {
+	return c.Subject
+}
+
+func secret() ([]byte, error) {
+	s := os.Getenv("GATEWAY_TOKEN_SIGNING_KEY")
+	if s == "" {
+		return nil, ErrNotConfigured
+	}
+	return []byte(s), nil
+}
+
+// Sign returns a token that acts as the API key apiKeyID of organization orgID, limited to
+// scopes, until expiresAt
+func Sign(apiKeyID, orgID string, scopes []string, expiresAt time.Time) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+
+	claims := Claims{
+		OrganizationID: orgID,
+		Scopes:         scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			Subject:   apiKeyID,
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+	if err != nil {
+		return "", err
+	}
+	return prefix + signed, nil
+}
+
+// IsToken reports whether a bearer credential is an ephemeral token
+func IsToken(credential string) bool {
+	return strings.HasPrefix(credential, prefix)
+}
+
+// Verify checks a token's signature, issuer, audience and expiry and returns its claims
+func Verify(token string) (*Claims, error) {
+	key, err := secret()
+	if err != nil {
+		return nil, err
+	}
+	if !IsToken(token) {
+		return nil, errors.New("invalid ephemeral token")
+	}
+
+	var claims Claims
+	_, err = jwt.ParseWithClaims(strings.TrimPrefix(token, prefix), &claims, func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral token: %w", err)
+	}
+	if claims.Subject == "" || claims.OrganizationID == "" || len(claims.Scopes) == 0 {
+		return nil, errors.New("invalid ephemeral token: missing API key, organization or scopes")
+	}
+	return &claims, nil
+}
@@ -0,0 +1,51 @@
+package ephemeraltoken
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	t.Setenv("GATEWAY_TOKEN_SIGNING_KEY", "signing-secret")
+
+	token, err := Sign("key-1", "org-1", []string{"chat"}, time.Now().Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if !IsToken(token) {
+		t.Errorf("IsToken(%q) = false", token)
+	}
+	if IsToken("sk-0123456789abcdef") {
+		t.Error("IsToken() accepted an API key")
+	}
+
+	claims, err := Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.APIKeyID() != "key-1" || claims.OrganizationID != "org-1" || len(claims.Scopes) != 1 || claims.Scopes[0] != "chat" {
+		t.Errorf("Verify() claims = %+v", claims)
+	}
+
+	if _, err := Verify(token + "x"); err == nil {
+		t.Error("Verify() accepted a tampered token")
+	}
+
+	expired, err := Sign("key-1", "org-1", []string{"chat"}, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err := Verify(expired); err == nil {
+		t.Error("Verify() accepted an expired token")
+	}
+
+	t.Setenv("GATEWAY_TOKEN_SIGNING_KEY", "other-secret")
+	if _, err := Verify(token); err == nil {
+		t.Error("Verify() accepted a token signed with another secret")
+	}
+
+	t.Setenv("GATEWAY_TOKEN_SIGNING_KEY", "")
+	if _, err := Sign("key-1", "org-1", []string{"chat"}, time.Now().Add(time.Minute)); err != ErrNotConfigured {
+		t.Errorf("Sign() without a secret error = %v, want ErrNotConfigured", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Package fx keeps exchange rates against the US dollar, in which every cost is stored, so
+// reports can be shown in an organization's own currency
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+const defaultInterval = 12 * time.Hour
+
+// ratesResponse covers the common shapes of USD-based rate APIs: "rates" or
+// "conversion_rates" keyed by currency, with the base in "base" or "base_code"
+type ratesResponse struct {
+	Base            string             `json:"base"`
+	BaseCode        string             `json:"base_code"`
+	Rates           map[string]float64 `json:"rates"`
+	ConversionRates map[string]float64 `json:"conversion_rates"`
+}
+
+// Fetch gets the latest rates per US dollar from a rate API. Rates quoted against another base
+// are refused, and entries that aren't currency codes with positive rates are dropped.
+func Fetch(ctx context.Context, client *http.Client, source string) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rate source returned %s", resp.Status)
+	}
+
+	var body ratesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode rates: %w", err)
+	}
+	base := body.Base
+	if base == "" {
+		base = body.BaseCode
+	}
+	if base != "" && base != models.BaseCurrency {
+		return nil, fmt.Errorf("rates are quoted against %s, not %s", base, models.BaseCurrency)
+	}
+	quoted := body.Rates
+	if len(quoted) == 0 {
+		quoted = body.ConversionRates
+	}
+
+	rates := make(map[string]float64, len(quoted))
+	for code, rate := range quoted {
+		currency, err := models.NormalizeCurrencyCode(code)
+		if err != nil || rate <= 0 {
+			continue
+		}
+		rates[currency] = rate
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("rate source returned no rates")
+	}
+	return rates, nil
+}
+
+// sourceName labels fetched rates with their source's host
+func sourceName(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return source
+}
+
+// refresh fetches the rates and stores them
+func refresh(ctx context.Context, database *sql.DB, client *http.Client, source string) {
+	rates, err := Fetch(ctx, client, source)
+	if err != nil {
+		log.Printf("Failed to fetch exchange rates from %s: %v", sourceName(source), err)
+		return
+	}
+	if err := db.SaveFXRates(database, rates, sourceName(source)); err != nil {
+		log.Printf("Failed to save exchange rates: %v", err)
+		return
+	}
+	log.Printf("Updated %d exchange rates from %s", len(rates), sourceName(source))
+}
+
+// StartRefresh fetches rates from FX_RATES_URL at start and every FX_RATES_INTERVAL (default
+// 12h). Without FX_RATES_URL it does nothing, and rates can only be set by hand. The returned
+// func stops it.
+func StartRefresh(database *sql.DB) (stop func()) {
+	source := os.Getenv("FX_RATES_URL")
+	if source == "" {
+		return func() {}
+	}
+	interval, _ := time.ParseDuration(os.Getenv("FX_RATES_INTERVAL"))
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &http.Client{Timeout: 30 * time.Second}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if !readonly.Enabled() {
+				refresh(ctx, database, client, source)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,49 @@
+package fx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveRates(t *testing.T, body string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestFetch(t *testing.T) {
+	source := serveRates(t, `{"base_code": "USD", "rates": {"USD": 1, "eur": 0.92, "JPY": 151.3, "XX": 2, "GBP": 0}}`)
+
+	rates, err := Fetch(context.Background(), http.DefaultClient, source)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"USD": 1, "EUR": 0.92, "JPY": 151.3}, rates)
+}
+
+func TestFetchConversionRates(t *testing.T) {
+	source := serveRates(t, `{"conversion_rates": {"CHF": 0.88}}`)
+
+	rates, err := Fetch(context.Background(), http.DefaultClient, source)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"CHF": 0.88}, rates)
+}
+
+func TestFetchRefusesOtherBases(t *testing.T) {
+	_, err := Fetch(context.Background(), http.DefaultClient, serveRates(t, `{"base": "EUR", "rates": {"USD": 1.08}}`))
+	assert.Error(t, err)
+
+	_, err = Fetch(context.Background(), http.DefaultClient, serveRates(t, `{"rates": {}}`))
+	assert.Error(t, err)
+}
+
+func TestSourceName(t *testing.T) {
+	assert.Equal(t, "open.er-api.com", sourceName("https://open.er-api.com/v6/latest/USD"))
+}
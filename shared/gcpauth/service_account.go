@@ -0,0 +1,162 @@
+// Package gcpauth mints OAuth access tokens for Google Cloud service accounts using the JWT
+// bearer grant, caching them until shortly before they expire.
+package gcpauth
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// CloudPlatformScope grants access to Vertex AI and the other Cloud APIs
+	CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+	defaultTokenURI = "https://oauth2.googleapis.com/token"
+	assertionTTL    = time.Hour
+	// refreshBefore renews access tokens this long before they expire
+	refreshBefore = 5 * time.Minute
+)
+
+// ServiceAccount is a parsed service account key file
+type ServiceAccount struct {
+	ProjectID    string
+	ClientEmail  string
+	PrivateKeyID string
+	TokenURI     string
+	privateKey   *rsa.PrivateKey
+}
+
+type keyFile struct {
+	Type         string `json:"type"`
+	ProjectID    string `json:"project_id"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	ClientEmail  string `json:"client_email"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// ParseServiceAccount reads a service account key file as downloaded from the Cloud console
+func ParseServiceAccount(data []byte) (*ServiceAccount, error) {
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("service account key is not valid JSON: %w", err)
+	}
+	if f.Type != "service_account" {
+		return nil, fmt.Errorf("key file type is %q, want \"service_account\"", f.Type)
+	}
+	if f.ClientEmail == "" || f.PrivateKey == "" || f.ProjectID == "" {
+		return nil, fmt.Errorf("service account key must include client_email, private_key and project_id")
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(f.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("service account private key: %w", err)
+	}
+
+	tokenURI := f.TokenURI
+	if tokenURI == "" {
+		tokenURI = defaultTokenURI
+	}
+	return &ServiceAccount{
+		ProjectID:    f.ProjectID,
+		ClientEmail:  f.ClientEmail,
+		PrivateKeyID: f.PrivateKeyID,
+		TokenURI:     tokenURI,
+		privateKey:   key,
+	}, nil
+}
+
+type cachedToken struct {
+	value   string
+	expires time.Time
+}
+
+// tokenCache keeps access tokens per service account key and scope
+var tokenCache = struct {
+	sync.Mutex
+	entries map[string]cachedToken
+}{entries: map[string]cachedToken{}}
+
+var tokenClient = &http.Client{Timeout: 15 * time.Second}
+
+// Token returns an access token for the scope, minting a new one when the cached token is
+// missing or about to expire
+func (sa *ServiceAccount) Token(scope string) (string, error) {
+	cacheKey := sa.ClientEmail + "|" + sa.PrivateKeyID + "|" + scope
+	tokenCache.Lock()
+	cached, ok := tokenCache.entries[cacheKey]
+	tokenCache.Unlock()
+	if ok && time.Until(cached.expires) > refreshBefore {
+		return cached.value, nil
+	}
+
+	token, err := sa.mint(scope, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	tokenCache.Lock()
+	tokenCache.entries[cacheKey] = token
+	tokenCache.Unlock()
+	return token.value, nil
+}
+
+// assertion builds the signed JWT exchanged for an access token
+func (sa *ServiceAccount) assertion(scope string, now time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   sa.ClientEmail,
+		"scope": scope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(assertionTTL).Unix(),
+	})
+	if sa.PrivateKeyID != "" {
+		token.Header["kid"] = sa.PrivateKeyID
+	}
+	return token.SignedString(sa.privateKey)
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (sa *ServiceAccount) mint(scope string, now time.Time) (cachedToken, error) {
+	assertion, err := sa.assertion(scope, now)
+	if err != nil {
+		return cachedToken{}, fmt.Errorf("sign token assertion: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	resp, err := tokenClient.Post(sa.TokenURI, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return cachedToken{}, fmt.Errorf("fetch access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return cachedToken{}, fmt.Errorf("fetch access token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return cachedToken{}, fmt.Errorf("fetch access token for %s: status %d: %s", sa.ClientEmail, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed tokenResponse
+	if err := json.Unmarshal(body, &parsed); err != nil || parsed.AccessToken == "" {
+		return cachedToken{}, fmt.Errorf("fetch access token: response has no access_token")
+	}
+	return cachedToken{value: parsed.AccessToken, expires: now.Add(time.Duration(parsed.ExpiresIn) * time.Second)}, nil
+}
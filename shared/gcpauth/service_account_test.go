@@ -0,0 +1,90 @@
+package gcpauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testKeyFile(t *testing.T, tokenURI string) ([]byte, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "acme-prod",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "gateway@acme-prod.iam.gserviceaccount.com",
+		"token_uri":      tokenURI,
+	})
+	return data, key
+}
+
+func TestParseServiceAccountRejectsOtherKeyTypes(t *testing.T) {
+	if _, err := ParseServiceAccount([]byte(`{"type":"authorized_user","client_email":"a","private_key":"b","project_id":"c"}`)); err == nil {
+		t.Error("expected an error for a non service account key")
+	}
+	if _, err := ParseServiceAccount([]byte(`{"type":"service_account","client_email":"a","private_key":"not a key","project_id":"c"}`)); err == nil {
+		t.Error("expected an error for an invalid private key")
+	}
+}
+
+func TestTokenMintsAndCaches(t *testing.T) {
+	var key *rsa.PrivateKey
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type = %q", r.FormValue("grant_type"))
+		}
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(r.FormValue("assertion"), claims, func(*jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		if err != nil {
+			t.Errorf("assertion does not verify: %v", err)
+		}
+		if claims["iss"] != "gateway@acme-prod.iam.gserviceaccount.com" || claims["scope"] != CloudPlatformScope {
+			t.Errorf("unexpected claims %v", claims)
+		}
+		w.Write([]byte(`{"access_token":"ya29.token","expires_in":3600,"token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	data, k := testKeyFile(t, server.URL)
+	key = k
+	sa, err := ParseServiceAccount(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sa.ProjectID != "acme-prod" {
+		t.Errorf("ProjectID = %q", sa.ProjectID)
+	}
+
+	for i := 0; i < 2; i++ {
+		token, err := sa.Token(CloudPlatformScope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "ya29.token" {
+			t.Errorf("token = %q", token)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("token endpoint called %d times, want 1 (cached)", requests)
+	}
+}
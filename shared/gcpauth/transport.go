@@ -0,0 +1,33 @@
+package gcpauth
+
+import "net/http"
+
+// Transport adds a service account access token to every outgoing request before handing it
+// to Base
+type Transport struct {
+	Base    http.RoundTripper
+	Account *ServiceAccount
+	Scope   string // Defaults to CloudPlatformScope
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	scope := t.Scope
+	if scope == "" {
+		scope = CloudPlatformScope
+	}
+	token, err := t.Account.Token(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the caller's request
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", "Bearer "+token)
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(authed)
+}
@@ -0,0 +1,165 @@
+// Package githubscan implements the partner side of GitHub secret scanning. GitHub posts tokens
+// matching our pattern that it finds in public repositories, signed with one of the keys it
+// publishes; we verify the signature, check each token and answer whether it was a real key.
+package githubscan
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultKeysURL is where GitHub publishes its secret scanning signing keys
+const DefaultKeysURL = "https://api.github.com/meta/public_keys/secret_scanning"
+
+// Request headers GitHub signs alerts with
+const (
+	KeyIdentifierHeader = "Github-Public-Key-Identifier"
+	SignatureHeader     = "Github-Public-Key-Signature"
+)
+
+// Labels for a reported token
+const (
+	LabelTruePositive  = "true_positive"
+	LabelFalsePositive = "false_positive"
+)
+
+// keysRefreshInterval is how long fetched keys are trusted before an unknown identifier
+// triggers a refetch
+const keysRefreshInterval = time.Hour
+
+// ErrInvalidSignature means the alert was not signed by GitHub
+var ErrInvalidSignature = errors.New("invalid GitHub secret scanning signature")
+
+// Match is one token GitHub found
+type Match struct {
+	Token  string `json:"token"`
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Source string `json:"source"`
+}
+
+// Result tells GitHub whether a reported token was a real credential
+type Result struct {
+	TokenRaw  string `json:"token_raw"`
+	TokenType string `json:"token_type"`
+	Label     string `json:"label"`
+}
+
+// Verifier checks alert signatures against GitHub's published keys, which are fetched on first
+// use and refetched when an unknown key identifier shows up
+type Verifier struct {
+	keysURL    string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*ecdsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewVerifier returns a verifier for the keys at GITHUB_SECRET_SCANNING_KEYS_URL, or
+// DefaultKeysURL
+func NewVerifier() *Verifier {
+	keysURL := os.Getenv("GITHUB_SECRET_SCANNING_KEYS_URL")
+	if keysURL == "" {
+		keysURL = DefaultKeysURL
+	}
+	return &Verifier{keysURL: keysURL, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Verify checks that signature (base64 ASN.1 ECDSA over the SHA-256 of body) was made with the
+// GitHub key named keyID
+func (v *Verifier) Verify(body []byte, keyID, signature string) error {
+	if keyID == "" || signature == "" {
+		return ErrInvalidSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	key, err := v.key(keyID)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(body)
+	if !ecdsa.VerifyASN1(key, digest[:], sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// key returns the public key named keyID, refetching the published keys if it is unknown and
+// they were last fetched a while ago
+func (v *Verifier) key(keyID string) (*ecdsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if key, ok := v.keys[keyID]; ok {
+		return key, nil
+	}
+	if v.keys != nil && time.Since(v.fetchedAt) < keysRefreshInterval {
+		return nil, ErrInvalidSignature
+	}
+
+	keys, err := v.fetchKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch GitHub secret scanning keys: %w", err)
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+
+	if key, ok := v.keys[keyID]; ok {
+		return key, nil
+	}
+	return nil, ErrInvalidSignature
+}
+
+func (v *Verifier) fetchKeys() (map[string]*ecdsa.PublicKey, error) {
+	resp, err := v.httpClient.Get(v.keysURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var published struct {
+		PublicKeys []struct {
+			KeyIdentifier string `json:"key_identifier"`
+			Key           string `json:"key"`
+		} `json:"public_keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&published); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey, len(published.PublicKeys))
+	for _, k := range published.PublicKeys {
+		block, _ := pem.Decode([]byte(k.Key))
+		if block == nil {
+			continue
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if key, ok := parsed.(*ecdsa.PublicKey); ok {
+			keys[k.KeyIdentifier] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable keys published")
+	}
+	return keys, nil
+}
@@ -0,0 +1,60 @@
+package githubscan
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"public_keys": []map[string]interface{}{{
+				"key_identifier": "key-1",
+				"key":            string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+				"is_current":     true,
+			}},
+		})
+	}))
+	defer server.Close()
+
+	body := []byte(`[{"token":"sk-abc","type":"relai_api_key","url":"https://github.com/o/r","source":"content"}]`)
+	digest := sha256.Sum256(body)
+	sig, err := ecdsa.SignASN1(rand.Reader, private, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := base64.StdEncoding.EncodeToString(sig)
+
+	v := &Verifier{keysURL: server.URL, httpClient: server.Client()}
+	if err := v.Verify(body, "key-1", signature); err != nil {
+		t.Fatalf("Verify() = %v, want nil", err)
+	}
+	if err := v.Verify(append(body, ' '), "key-1", signature); err != ErrInvalidSignature {
+		t.Errorf("Verify() of a changed body = %v, want ErrInvalidSignature", err)
+	}
+	if err := v.Verify(body, "key-2", signature); err != ErrInvalidSignature {
+		t.Errorf("Verify() with an unknown key = %v, want ErrInvalidSignature", err)
+	}
+	if fetches != 1 {
+		t.Errorf("keys fetched %d times, want 1", fetches)
+	}
+}
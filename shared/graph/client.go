@@ -0,0 +1,298 @@
+// Package graph is a Microsoft Graph client using app-only (client credentials) tokens. One
+// client is shared by sign-in, AD group sync and group search so tokens are reused until they
+// expire and throttling is handled in one place.
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BaseURL is prefixed to relative request paths
+const BaseURL = "https://graph.microsoft.com/v1.0"
+
+const (
+	defaultMaxRetries = 3
+	// maxRetryWait caps how long one Retry-After may hold a request
+	maxRetryWait = 30 * time.Second
+	// tokenExpiryMargin renews tokens a little early so in-flight requests don't carry an expired one
+	tokenExpiryMargin = 2 * time.Minute
+)
+
+// Error is a non-success Graph response
+type Error struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("graph request failed (%d): %s", e.StatusCode, e.Body)
+}
+
+// Stats counts Graph traffic since the client was created
+type Stats struct {
+	Requests        int64      `json:"requests"`
+	Retries         int64      `json:"retries"`
+	Throttled       int64      `json:"throttled"`
+	Failures        int64      `json:"failures"`
+	TokenFetches    int64      `json:"token_fetches"`
+	LastThrottledAt *time.Time `json:"last_throttled_at,omitempty"`
+}
+
+// Client calls Graph with a cached app-only token, retrying throttled requests after the
+// interval Graph asks for
+type Client struct {
+	tenantID     string
+	clientID     string
+	clientSecret string
+
+	tokenURL   string
+	httpClient *http.Client
+	maxRetries int
+	sleep      func(time.Duration)
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+
+	requests, retries, throttled, failures, tokenFetches atomic.Int64
+	lastThrottledAt                                      atomic.Pointer[time.Time]
+}
+
+// NewClient creates a client for an app registration
+func NewClient(tenantID, clientID, clientSecret string) *Client {
+	return &Client{
+		tenantID:     tenantID,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		tokenURL:     fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		maxRetries:   defaultMaxRetries,
+		sleep:        time.Sleep,
+	}
+}
+
+// Stats returns a snapshot of the client's counters
+func (c *Client) Stats() Stats {
+	return Stats{
+		Requests:        c.requests.Load(),
+		Retries:         c.retries.Load(),
+		Throttled:       c.throttled.Load(),
+		Failures:        c.failures.Load(),
+		TokenFetches:    c.tokenFetches.Load(),
+		LastThrottledAt: c.lastThrottledAt.Load(),
+	}
+}
+
+// Token returns the cached app-only token, fetching a new one when it's about to expire
+func (c *Client) Token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", c.clientSecret)
+	form.Set("scope", "https://graph.microsoft.com/.default")
+
+	c.tokenFetches.Add(1)
+	resp, err := c.httpClient.PostForm(c.tokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s", string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", err
+	}
+
+	c.token = tokenResp.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second - tokenExpiryMargin)
+	return c.token, nil
+}
+
+// SetClientSecret replaces the app registration's secret for the tokens fetched from now on. A
+// token already issued stays in use until it expires.
+func (c *Client) SetClientSecret(secret string) {
+	c.mu.Lock()
+	c.clientSecret = secret
+	c.mu.Unlock()
+}
+
+func (c *Client) invalidateToken() {
+	c.mu.Lock()
+	c.token = ""
+	c.mu.Unlock()
+}
+
+// Get fetches path (relative to BaseURL, or an absolute Graph URL such as an @odata.nextLink)
+// and decodes the JSON response into out. 429 and 503/504 responses are retried after their
+// Retry-After interval; a 401 is retried once with a fresh token.
+func (c *Client) Get(path string, headers map[string]string, out interface{}) error {
+	requestURL := path
+	if strings.HasPrefix(path, "/") {
+		requestURL = BaseURL + path
+	}
+
+	start := time.Now()
+	refreshedToken := false
+	for attempt := 0; ; attempt++ {
+		token, err := c.Token()
+		if err != nil {
+			c.failures.Add(1)
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		c.requests.Add(1)
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			c.failures.Add(1)
+			return err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			if attempt > 0 {
+				log.Printf("Graph GET %s succeeded after %d retries in %s", req.URL.Path, attempt, time.Since(start))
+			}
+			if out == nil {
+				return nil
+			}
+			return json.Unmarshal(body, out)
+
+		case resp.StatusCode == http.StatusUnauthorized && !refreshedToken:
+			// The cached token was revoked or rotated early
+			refreshedToken = true
+			c.invalidateToken()
+			continue
+
+		case isRetryable(resp.StatusCode) && attempt < c.maxRetries:
+			if resp.StatusCode == http.StatusTooManyRequests {
+				now := time.Now()
+				c.throttled.Add(1)
+				c.lastThrottledAt.Store(&now)
+			}
+			wait := retryAfter(resp.Header.Get("Retry-After"), attempt)
+			log.Printf("Graph GET %s returned %d, retrying in %s (attempt %d of %d)",
+				req.URL.Path, resp.StatusCode, wait, attempt+1, c.maxRetries)
+			c.retries.Add(1)
+			c.sleep(wait)
+			continue
+		}
+
+		c.failures.Add(1)
+		return &Error{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+}
+
+func isRetryable(status int) bool {
+	return status == http.StatusTooManyRequests ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// retryAfter parses a Retry-After header (seconds or an HTTP date), falling back to
+// exponential backoff, and caps the result at maxRetryWait
+func retryAfter(header string, attempt int) time.Duration {
+	wait := time.Duration(1<<attempt) * time.Second
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(header); err == nil {
+		wait = time.Until(at)
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryWait {
+		wait = maxRetryWait
+	}
+	return wait
+}
+
+// Group is an Azure AD group
+type Group struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Description string `json:"description,omitempty"`
+}
+
+// GroupPage is one page of a group listing
+type GroupPage struct {
+	Groups   []Group `json:"value"`
+	NextLink string  `json:"@odata.nextLink,omitempty"`
+}
+
+// Groups fetches one page of groups. path is a /groups query or a previous page's NextLink.
+// Advanced queries ($search, $count) require consistencyLevel "eventual".
+func (c *Client) Groups(path string, consistencyLevel string) (*GroupPage, error) {
+	var headers map[string]string
+	if consistencyLevel != "" {
+		headers = map[string]string{"ConsistencyLevel": consistencyLevel}
+	}
+	var page GroupPage
+	if err := c.Get(path, headers, &page); err != nil {
+		return nil, err
+	}
+	if page.Groups == nil {
+		page.Groups = []Group{}
+	}
+	return &page, nil
+}
+
+// UserGroups returns the IDs of the groups a user, by Azure object ID, is a direct member of
+func (c *Client) UserGroups(userID string) ([]string, error) {
+	groups := []string{}
+	path := "/users/" + url.PathEscape(userID) + "/memberOf?$select=id,displayName"
+	for path != "" {
+		var page struct {
+			Value []struct {
+				ID        string `json:"id"`
+				OdataType string `json:"@odata.type"`
+			} `json:"value"`
+			NextLink string `json:"@odata.nextLink,omitempty"`
+		}
+		if err := c.Get(path, nil, &page); err != nil {
+			return groups, err
+		}
+		for _, item := range page.Value {
+			if item.OdataType == "#microsoft.graph.group" {
+				groups = append(groups, item.ID)
+			}
+		}
+		path = page.NextLink
+	}
+	return groups, nil
+}
@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *int32) {
+	t.Helper()
+	var tokenFetches int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&tokenFetches, 1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":3600}`, n)
+	})
+	mux.HandleFunc("/", handler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c := NewClient("tenant", "client", "secret")
+	c.tokenURL = server.URL + "/token"
+	c.sleep = func(time.Duration) {}
+	return c, &tokenFetches
+}
+
+func TestTokenIsCached(t *testing.T) {
+	c, fetches := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Token(); err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(fetches); got != 1 {
+		t.Errorf("token fetched %d times, want 1", got)
+	}
+}
+
+func TestGetRetriesThrottledRequests(t *testing.T) {
+	var calls int32
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"value":[{"id":"g1","displayName":"Engineering"}]}`)
+	})
+
+	var waits []time.Duration
+	c.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	page, err := c.Groups(testURL(t, c), "")
+	if err != nil {
+		t.Fatalf("Groups() error = %v", err)
+	}
+	if len(page.Groups) != 1 || page.Groups[0].ID != "g1" {
+		t.Errorf("Groups() = %+v", page.Groups)
+	}
+	if len(waits) != 2 || waits[0] != 2*time.Second {
+		t.Errorf("waits = %v, want two 2s waits", waits)
+	}
+	if stats := c.Stats(); stats.Throttled != 2 || stats.Retries != 2 || stats.LastThrottledAt == nil {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestGetGivesUpAfterMaxRetries(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	err := c.Get(testURL(t, c), nil, nil)
+	graphErr, ok := err.(*Error)
+	if !ok || graphErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Get() error = %v, want 503 *Error", err)
+	}
+	if got := c.Stats().Retries; got != defaultMaxRetries {
+		t.Errorf("retries = %d, want %d", got, defaultMaxRetries)
+	}
+}
+
+func TestGetRefreshesRejectedToken(t *testing.T) {
+	c, fetches := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	})
+
+	if err := c.Get(testURL(t, c), nil, nil); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := atomic.LoadInt32(fetches); got != 2 {
+		t.Errorf("token fetched %d times, want 2", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header  string
+		attempt int
+		want    time.Duration
+	}{
+		{"5", 0, 5 * time.Second},
+		{"", 0, time.Second},
+		{"", 2, 4 * time.Second},
+		{"3600", 0, maxRetryWait},
+		{"garbage", 1, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header, tt.attempt); got != tt.want {
+			t.Errorf("retryAfter(%q, %d) = %s, want %s", tt.header, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+// testURL returns an absolute URL on the test server, which serves both tokens and requests
+func testURL(t *testing.T, c *Client) string {
+	t.Helper()
+	return c.tokenURL[:len(c.tokenURL)-len("/token")] + "/groups"
+}
@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrRefreshRejected is returned when Azure AD refuses a refresh token, because it was revoked,
+// expired or the account was disabled; the user has to sign in again
+var ErrRefreshRejected = errors.New("refresh token rejected")
+
+// UserTokens are the delegated tokens of a signed-in user
+type UserTokens struct {
+	AccessToken  string
+	IDToken      string
+	RefreshToken string // Azure AD rotates it; empty when the old one stays valid
+	ExpiresAt    time.Time
+}
+
+// RefreshUserTokens redeems a user's refresh token with the client's app registration for new
+// tokens with the given scope
+func (c *Client) RefreshUserTokens(refreshToken, scope string) (*UserTokens, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", c.clientID)
+	form.Set("client_secret", c.clientSecret)
+	form.Set("refresh_token", refreshToken)
+	form.Set("scope", scope)
+
+	resp, err := c.httpClient.PostForm(c.tokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		// invalid_grant is final; anything else (throttling, outages) may pass
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error == "invalid_grant" {
+			return nil, ErrRefreshRejected
+		}
+		return nil, fmt.Errorf("token refresh failed (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		IDToken      string `json:"id_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, err
+	}
+
+	return &UserTokens{
+		AccessToken:  tokenResp.AccessToken,
+		IDToken:      tokenResp.IDToken,
+		RefreshToken: tokenResp.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,66 @@
+package graph
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newRefreshClient returns a client whose token endpoint is handler
+func newRefreshClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := NewClient("tenant", "client", "secret")
+	c.tokenURL = server.URL
+	return c
+}
+
+func TestRefreshUserTokens(t *testing.T) {
+	var form url.Values
+	c := newRefreshClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		w.Write([]byte(`{"access_token":"access","id_token":"id","refresh_token":"rotated","expires_in":3600}`))
+	})
+
+	tokens, err := c.RefreshUserTokens("old", "openid offline_access")
+	if err != nil {
+		t.Fatalf("RefreshUserTokens() error = %v", err)
+	}
+	if form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "old" || form.Get("scope") != "openid offline_access" {
+		t.Errorf("token request form = %v", form)
+	}
+	if tokens.AccessToken != "access" || tokens.IDToken != "id" || tokens.RefreshToken != "rotated" {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	if until := time.Until(tokens.ExpiresAt); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("ExpiresAt is %s away, want about an hour", until)
+	}
+}
+
+func TestRefreshUserTokensRejected(t *testing.T) {
+	c := newRefreshClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"AADSTS700082: The refresh token has expired"}`))
+	})
+
+	if _, err := c.RefreshUserTokens("old", "openid"); !errors.Is(err, ErrRefreshRejected) {
+		t.Errorf("RefreshUserTokens() error = %v, want ErrRefreshRejected", err)
+	}
+}
+
+func TestRefreshUserTokensOutage(t *testing.T) {
+	c := newRefreshClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	_, err := c.RefreshUserTokens("old", "openid")
+	if err == nil || errors.Is(err, ErrRefreshRejected) {
+		t.Errorf("RefreshUserTokens() error = %v, want a retryable error", err)
+	}
+}
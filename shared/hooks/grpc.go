@@ -0,0 +1,160 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// grpcService is the sidecar's service, defined in hooks.proto. Every method takes and returns
+// a google.protobuf.Struct, so a sidecar in any language only needs the well-known types.
+const grpcService = "/relai.hooks.v1.HookService/"
+
+// GRPCHook calls a sidecar hook service for every request, response and usage record
+type GRPCHook struct {
+	conn    *grpc.ClientConn
+	timeout time.Duration
+}
+
+// DialGRPC connects to a sidecar hook service, such as localhost:50051. The connection is
+// plaintext, as a sidecar runs next to the gateway.
+func DialGRPC(addr string, timeout time.Duration) (*GRPCHook, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCHook{conn: conn, timeout: timeout}, nil
+}
+
+// Name identifies the sidecar in logs
+func (g *GRPCHook) Name() string {
+	return "grpc:" + g.conn.Target()
+}
+
+// Close closes the connection to the sidecar
+func (g *GRPCHook) Close() error {
+	return g.conn.Close()
+}
+
+// decision is a sidecar's answer to a request or response: allow it, with optional changes,
+// or reject it
+type decision struct {
+	Action     string            `json:"action"` // "allow" (the default) or "reject"
+	Status     int               `json:"status"`
+	Message    string            `json:"message"`
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"` // Set on the request or response
+	Body       *string           `json:"body"`    // Replaces the body when present
+}
+
+// OnRequest asks the sidecar whether to send the request, and with what changes
+func (g *GRPCHook) OnRequest(ctx context.Context, req *Request) error {
+	var d decision
+	if err := g.call(ctx, "OnRequest", requestFields(req), &d); err != nil {
+		return err
+	}
+	if d.Action == "reject" {
+		return Reject(d.Status, d.Message)
+	}
+	for name, value := range d.Headers {
+		req.Header.Set(name, value)
+	}
+	if d.Body != nil {
+		req.Body = []byte(*d.Body)
+	}
+	return nil
+}
+
+// OnResponse asks the sidecar whether to relay the response, and with what changes
+func (g *GRPCHook) OnResponse(ctx context.Context, resp *Response) error {
+	fields := requestFields(resp.Request)
+	fields["status_code"] = resp.StatusCode
+	fields["response_headers"] = headerFields(resp.Header)
+	fields["response_body"] = string(resp.Body)
+	fields["streaming"] = resp.Streaming
+
+	var d decision
+	if err := g.call(ctx, "OnResponse", fields, &d); err != nil {
+		return err
+	}
+	if d.Action == "reject" {
+		return Reject(d.Status, d.Message)
+	}
+	if d.StatusCode != 0 {
+		resp.StatusCode = d.StatusCode
+	}
+	for name, value := range d.Headers {
+		resp.Header.Set(name, value)
+	}
+	if d.Body != nil && !resp.Streaming {
+		resp.Body = []byte(*d.Body)
+	}
+	return nil
+}
+
+// OnUsage sends the sidecar the stored usage record
+func (g *GRPCHook) OnUsage(ctx context.Context, usage *Usage) {
+	var fields map[string]interface{}
+	raw, _ := json.Marshal(usage)
+	_ = json.Unmarshal(raw, &fields)
+	if err := g.call(ctx, "OnUsage", fields, nil); err != nil {
+		// Usage is already stored; the sidecar just misses this record
+		log.Printf("Hook %s failed on usage: %v", g.Name(), err)
+	}
+}
+
+// call invokes a sidecar method and decodes its answer into out, when given
+func (g *GRPCHook) call(ctx context.Context, method string, fields map[string]interface{}, out interface{}) error {
+	in, err := structpb.NewStruct(fields)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	reply := &structpb.Struct{}
+	if err := g.conn.Invoke(ctx, grpcService+method, in, reply); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	raw, err := reply.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// requestFields is a request as the sidecar receives it
+func requestFields(req *Request) map[string]interface{} {
+	return map[string]interface{}{
+		"request_id":      req.RequestID,
+		"organization_id": req.OrganizationID,
+		"api_key_id":      req.APIKeyID,
+		"model":           req.Model,
+		"method":          req.Method,
+		"path":            req.Path,
+		"headers":         headerFields(req.Header),
+		"body":            string(req.Body),
+	}
+}
+
+// headerFields converts a header to a Struct-friendly map of name to values
+func headerFields(header http.Header) map[string]interface{} {
+	fields := make(map[string]interface{}, len(header))
+	for name, values := range header {
+		list := make([]interface{}, len(values))
+		for i, v := range values {
+			list[i] = v
+		}
+		fields[name] = list
+	}
+	return fields
+}
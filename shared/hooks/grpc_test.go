@@ -0,0 +1,104 @@
+package hooks
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeSidecar answers the hook service's methods with handle
+type fakeSidecar struct {
+	handle func(method string, in *structpb.Struct) *structpb.Struct
+}
+
+func (f *fakeSidecar) serve(t *testing.T) string {
+	t.Helper()
+	method := func(name string) grpc.MethodDesc {
+		return grpc.MethodDesc{
+			MethodName: name,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &structpb.Struct{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return f.handle(name, in), nil
+			},
+		}
+	}
+	desc := grpc.ServiceDesc{
+		ServiceName: "relai.hooks.v1.HookService",
+		HandlerType: (*interface{})(nil),
+		Methods:     []grpc.MethodDesc{method("OnRequest"), method("OnResponse"), method("OnUsage")},
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	server.RegisterService(&desc, f)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func mustStruct(t *testing.T, fields map[string]interface{}) *structpb.Struct {
+	s, err := structpb.NewStruct(fields)
+	require.NoError(t, err)
+	return s
+}
+
+func TestGRPCHook(t *testing.T) {
+	usages := make(chan float64, 1)
+	sidecar := &fakeSidecar{handle: func(method string, in *structpb.Struct) *structpb.Struct {
+		fields := in.AsMap()
+		switch method {
+		case "OnRequest":
+			if fields["organization_id"] == "blocked-org" {
+				return mustStruct(t, map[string]interface{}{"action": "reject", "status": 402, "message": "billing hold"})
+			}
+			return mustStruct(t, map[string]interface{}{"headers": map[string]interface{}{"X-Policy": "ok"}})
+		case "OnResponse":
+			return mustStruct(t, map[string]interface{}{"body": fields["response_body"].(string) + " [watermarked]"})
+		default:
+			usages <- fields["total_tokens"].(float64)
+			return &structpb.Struct{}
+		}
+	}}
+	addr := sidecar.serve(t)
+
+	hook, err := DialGRPC(addr, 5*time.Second)
+	require.NoError(t, err)
+	defer hook.Close()
+	ctx := context.Background()
+
+	req := &Request{OrganizationID: "org", Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{}`)}
+	require.NoError(t, hook.OnRequest(ctx, req))
+	assert.Equal(t, "ok", req.Header.Get("X-Policy"))
+
+	err = hook.OnRequest(ctx, &Request{OrganizationID: "blocked-org", Header: http.Header{}})
+	var rejection *Rejection
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, 402, rejection.Status)
+	assert.Equal(t, "billing hold", rejection.Message)
+
+	resp := &Response{Request: req, StatusCode: 200, Header: http.Header{}, Body: []byte("hello")}
+	require.NoError(t, hook.OnResponse(ctx, resp))
+	assert.Equal(t, "hello [watermarked]", string(resp.Body))
+
+	hook.OnUsage(ctx, &Usage{TotalTokens: 42})
+	assert.Equal(t, float64(42), <-usages)
+}
+
+func TestGRPCHookUnavailable(t *testing.T) {
+	hook, err := DialGRPC("127.0.0.1:1", 200*time.Millisecond)
+	require.NoError(t, err)
+	defer hook.Close()
+
+	assert.Error(t, hook.OnRequest(context.Background(), &Request{Header: http.Header{}}))
+}
@@ -0,0 +1,203 @@
+// Package hooks lets a deployment add its own policy to the gateway (custom auth, watermarking,
+// billing exports) without forking it. Hooks are Go plugins loaded at startup or a sidecar
+// gRPC service, and see each proxied request before it goes upstream, each response before it
+// is relayed, and each usage record once it is stored.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Request is a proxied request about to be sent upstream. Hooks may change Header and Body,
+// and what they leave is what the provider receives. Header never holds the provider's token.
+type Request struct {
+	RequestID      string
+	OrganizationID string
+	APIKeyID       string
+	Model          string
+	Method         string
+	Path           string
+	Header         http.Header
+	Body           []byte
+}
+
+// Response is a provider response about to be relayed. Hooks may change StatusCode, Header
+// and Body. Streamed responses are relayed as they arrive, so their Body is nil and only the
+// status and headers can be changed.
+type Response struct {
+	Request    *Request
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Streaming  bool
+}
+
+// Usage is a request's recorded usage
+type Usage struct {
+	RequestID        string    `json:"request_id"`
+	OrganizationID   string    `json:"organization_id"`
+	APIKeyID         string    `json:"api_key_id"`
+	ModelID          string    `json:"model_id"`
+	Provider         string    `json:"provider"`
+	Endpoint         string    `json:"endpoint"`
+	ResponseStatus   int       `json:"response_status"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+	ResponseTimeMS   int       `json:"response_time_ms"`
+	RecordedAt       time.Time `json:"recorded_at"`
+}
+
+// Hook is anything registered with the gateway. It implements any of RequestHook,
+// ResponseHook and UsageHook.
+type Hook interface {
+	Name() string
+}
+
+// RequestHook sees each request before it goes upstream. Returning a *Rejection answers the
+// client with it instead.
+type RequestHook interface {
+	Hook
+	OnRequest(ctx context.Context, req *Request) error
+}
+
+// ResponseHook sees each provider response before it is relayed. Returning a *Rejection
+// answers the client with it instead.
+type ResponseHook interface {
+	Hook
+	OnResponse(ctx context.Context, resp *Response) error
+}
+
+// UsageHook is told of each usage record once it is stored. It runs off the request path, so
+// it can't change or block anything.
+type UsageHook interface {
+	Hook
+	OnUsage(ctx context.Context, usage *Usage)
+}
+
+// Rejection stops a request with the given status and message
+type Rejection struct {
+	Status  int
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return fmt.Sprintf("rejected with %d: %s", r.Status, r.Message)
+}
+
+// Reject returns a Rejection for a hook to return
+func Reject(status int, message string) error {
+	if status < 400 || status > 599 {
+		status = http.StatusForbidden
+	}
+	return &Rejection{Status: status, Message: message}
+}
+
+var (
+	mu         sync.RWMutex
+	registered []Hook
+	// failOpen lets requests through when a hook fails rather than rejects; by default a broken
+	// policy hook blocks traffic instead of silently skipping the policy
+	failOpen bool
+)
+
+// Register adds a hook. Hooks run in the order they were registered.
+func Register(h Hook) {
+	mu.Lock()
+	defer mu.Unlock()
+	registered = append(registered, h)
+}
+
+// Registered returns the registered hooks
+func Registered() []Hook {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]Hook(nil), registered...)
+}
+
+// SetFailOpen sets whether a hook's failure, as opposed to a rejection, lets the request through
+func SetFailOpen(open bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	failOpen = open
+}
+
+// reset drops every hook; for tests
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	registered = nil
+	failOpen = false
+}
+
+// RunRequest runs the request hooks in order, stopping at the first rejection. A hook that
+// fails is reported as a 503 rejection unless hooks fail open.
+func RunRequest(ctx context.Context, req *Request) error {
+	for _, h := range Registered() {
+		if rh, ok := h.(RequestHook); ok {
+			if err := settle(h, rh.OnRequest(ctx, req)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunResponse runs the response hooks in order, stopping at the first rejection. A hook that
+// fails is reported as a 503 rejection unless hooks fail open.
+func RunResponse(ctx context.Context, resp *Response) error {
+	for _, h := range Registered() {
+		if rh, ok := h.(ResponseHook); ok {
+			if err := settle(h, rh.OnResponse(ctx, resp)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunUsage tells every usage hook of the record
+func RunUsage(ctx context.Context, usage *Usage) {
+	for _, h := range Registered() {
+		if uh, ok := h.(UsageHook); ok {
+			uh.OnUsage(ctx, usage)
+		}
+	}
+}
+
+// HasResponseHooks reports whether any hook wants to see responses, so the proxy can skip
+// building them otherwise
+func HasResponseHooks() bool {
+	for _, h := range Registered() {
+		if _, ok := h.(ResponseHook); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// settle turns a hook's error into the rejection the client gets, or nil to carry on
+func settle(h Hook, err error) error {
+	if err == nil {
+		return nil
+	}
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		return rejection
+	}
+	log.Printf("Hook %s failed: %v", h.Name(), err)
+	mu.RLock()
+	open := failOpen
+	mu.RUnlock()
+	if open {
+		return nil
+	}
+	return &Rejection{Status: http.StatusServiceUnavailable, Message: "policy hook unavailable"}
+}
@@ -0,0 +1,94 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// watermark tags JSON responses and refuses requests without a team header
+type watermark struct{ usages int }
+
+func (w *watermark) Name() string { return "watermark" }
+
+func (w *watermark) OnRequest(ctx context.Context, req *Request) error {
+	if req.Header.Get("X-Team") == "" {
+		return Reject(http.StatusUnauthorized, "X-Team header required")
+	}
+	req.Header.Set("X-Checked", "true")
+	return nil
+}
+
+func (w *watermark) OnResponse(ctx context.Context, resp *Response) error {
+	resp.Header.Set("X-Watermark", "acme")
+	return nil
+}
+
+func (w *watermark) OnUsage(ctx context.Context, usage *Usage) { w.usages++ }
+
+// broken fails every request without rejecting it
+type broken struct{}
+
+func (broken) Name() string { return "broken" }
+
+func (broken) OnRequest(ctx context.Context, req *Request) error {
+	return errors.New("policy store unreachable")
+}
+
+func TestRunRequest(t *testing.T) {
+	t.Cleanup(reset)
+	Register(&watermark{})
+
+	req := &Request{Header: http.Header{}}
+	err := RunRequest(context.Background(), req)
+	var rejection *Rejection
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, http.StatusUnauthorized, rejection.Status)
+
+	req.Header.Set("X-Team", "search")
+	require.NoError(t, RunRequest(context.Background(), req))
+	assert.Equal(t, "true", req.Header.Get("X-Checked"))
+}
+
+func TestRunResponseAndUsage(t *testing.T) {
+	t.Cleanup(reset)
+	w := &watermark{}
+	Register(w)
+	Register(broken{})
+
+	assert.True(t, HasResponseHooks())
+	resp := &Response{Request: &Request{}, StatusCode: http.StatusOK, Header: http.Header{}}
+	require.NoError(t, RunResponse(context.Background(), resp))
+	assert.Equal(t, "acme", resp.Header.Get("X-Watermark"))
+
+	RunUsage(context.Background(), &Usage{TotalTokens: 10})
+	assert.Equal(t, 1, w.usages)
+}
+
+func TestFailingHookFailsClosed(t *testing.T) {
+	t.Cleanup(reset)
+	Register(broken{})
+
+	err := RunRequest(context.Background(), &Request{Header: http.Header{}})
+	var rejection *Rejection
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, http.StatusServiceUnavailable, rejection.Status)
+
+	SetFailOpen(true)
+	assert.NoError(t, RunRequest(context.Background(), &Request{Header: http.Header{}}))
+}
+
+func TestRejectStatus(t *testing.T) {
+	assert.Equal(t, http.StatusForbidden, Reject(200, "no").(*Rejection).Status)
+	assert.Equal(t, http.StatusTooManyRequests, Reject(429, "slow down").(*Rejection).Status)
+}
+
+func TestNoHooks(t *testing.T) {
+	t.Cleanup(reset)
+	assert.False(t, HasResponseHooks())
+	assert.NoError(t, RunRequest(context.Background(), &Request{}))
+}
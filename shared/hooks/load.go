@@ -0,0 +1,47 @@
+package hooks
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultGRPCTimeout = 2 * time.Second
+
+// LoadFromEnv registers the hooks a deployment configured: the Go plugins listed in
+// GATEWAY_HOOK_PLUGINS (comma-separated .so paths), then the sidecar at GATEWAY_HOOK_GRPC_ADDR,
+// called with a GATEWAY_HOOK_TIMEOUT (default 2s) deadline. GATEWAY_HOOK_FAIL_OPEN=true lets
+// requests through when a hook fails. A plugin that won't load is fatal, as running without a
+// deployment's policy could let through traffic it is meant to block. The returned func closes
+// the sidecar connection.
+func LoadFromEnv() (stop func()) {
+	SetFailOpen(os.Getenv("GATEWAY_HOOK_FAIL_OPEN") == "true")
+
+	for _, path := range strings.Split(os.Getenv("GATEWAY_HOOK_PLUGINS"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		h, err := LoadPlugin(path)
+		if err != nil {
+			log.Fatalf("Failed to load hook plugin: %v", err)
+		}
+		log.Printf("Loaded hook plugin %s from %s", h.Name(), path)
+	}
+
+	addr := os.Getenv("GATEWAY_HOOK_GRPC_ADDR")
+	if addr == "" {
+		return func() {}
+	}
+	timeout, _ := time.ParseDuration(os.Getenv("GATEWAY_HOOK_TIMEOUT"))
+	if timeout <= 0 {
+		timeout = defaultGRPCTimeout
+	}
+	sidecar, err := DialGRPC(addr, timeout)
+	if err != nil {
+		log.Fatalf("Failed to set up hook service at %s: %v", addr, err)
+	}
+	Register(sidecar)
+	log.Printf("Calling hook service at %s (timeout %s)", addr, timeout)
+	return func() { sidecar.Close() }
+}
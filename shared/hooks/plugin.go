@@ -0,0 +1,38 @@
+package hooks
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// LoadPlugin opens a Go plugin built with -buildmode=plugin against the same gateway source and
+// registers the hook it exports. The plugin exports either a variable `Hook` holding a
+// hooks.Hook or a function `New() hooks.Hook`.
+func LoadPlugin(path string) (Hook, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var h Hook
+	if sym, err := p.Lookup("Hook"); err == nil {
+		ptr, ok := sym.(*Hook)
+		if !ok || *ptr == nil {
+			return nil, fmt.Errorf("plugin %s: Hook is not a hooks.Hook", path)
+		}
+		h = *ptr
+	} else if sym, err := p.Lookup("New"); err == nil {
+		newHook, ok := sym.(func() Hook)
+		if !ok {
+			return nil, fmt.Errorf("plugin %s: New is not a func() hooks.Hook", path)
+		}
+		if h = newHook(); h == nil {
+			return nil, fmt.Errorf("plugin %s: New returned nil", path)
+		}
+	} else {
+		return nil, fmt.Errorf("plugin %s exports neither Hook nor New", path)
+	}
+
+	Register(h)
+	return h, nil
+}
@@ -0,0 +1,143 @@
+// Package i18n holds the translation catalogs for admin UI strings and resolves which locale a
+// user sees (user preference, then organization, then the default).
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is used when neither the user nor the organization has chosen one
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// catalogs maps locale -> message key -> translation
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		log.Fatalf("Failed to read translation catalogs: %v", err)
+	}
+
+	result := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := localeFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			log.Fatalf("Failed to read translation catalog %s: %v", entry.Name(), err)
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			log.Fatalf("Failed to parse translation catalog %s: %v", entry.Name(), err)
+		}
+
+		result[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+
+	return result
+}
+
+// Normalize lowercases a locale and maps it to a supported one, e.g. "es-MX" -> "es".
+// It returns "" for unsupported locales.
+func Normalize(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if _, ok := catalogs[locale]; ok {
+		return locale
+	}
+	if i := strings.Index(locale, "-"); i > 0 {
+		if _, ok := catalogs[locale[:i]]; ok {
+			return locale[:i]
+		}
+	}
+	return ""
+}
+
+// IsSupported reports whether a catalog exists for the locale
+func IsSupported(locale string) bool {
+	return Normalize(locale) != ""
+}
+
+// SupportedLocales returns the locales with a catalog, sorted
+func SupportedLocales() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Locale describes a supported locale for language pickers
+type Locale struct {
+	Code string `json:"code"`
+	Name string `json:"name"` // In its own language
+}
+
+// Locales returns the supported locales with their display names
+func Locales() []Locale {
+	codes := SupportedLocales()
+	locales := make([]Locale, 0, len(codes))
+	for _, code := range codes {
+		locales = append(locales, Locale{Code: code, Name: T(code, "locale.name")})
+	}
+	return locales
+}
+
+// Resolve returns the first supported locale among candidates (most specific first), or the default
+func Resolve(candidates ...string) string {
+	for _, candidate := range candidates {
+		if locale := Normalize(candidate); locale != "" {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// T translates key into locale, falling back to the default locale and then to the key itself.
+// Extra args are applied with fmt.Sprintf.
+func T(locale, key string, args ...interface{}) string {
+	message, ok := catalogs[Resolve(locale)][key]
+	if !ok {
+		message, ok = catalogs[DefaultLocale][key]
+	}
+	if !ok {
+		message = key
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Catalog returns every message for locale, with untranslated keys filled from the default locale
+func Catalog(locale string) map[string]string {
+	messages := make(map[string]string, len(catalogs[DefaultLocale]))
+	for key, message := range catalogs[DefaultLocale] {
+		messages[key] = message
+	}
+	for key, message := range catalogs[Resolve(locale)] {
+		messages[key] = message
+	}
+	return messages
+}
+
+// FuncMap exposes T to html templates as {{t .Locale "key"}}. Pages rendered without a
+// Locale use the default.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"t": func(locale interface{}, key string, args ...interface{}) string {
+			l, _ := locale.(string)
+			return T(l, key, args...)
+		},
+	}
+}
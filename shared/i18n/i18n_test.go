@@ -0,0 +1,45 @@
+package i18n
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		candidates []string
+		want       string
+	}{
+		{[]string{"es"}, "es"},
+		{[]string{"es-MX"}, "es"},
+		{[]string{"pt_BR", "fr"}, "fr"},
+		{[]string{"", "de"}, "de"},
+		{[]string{"xx"}, DefaultLocale},
+		{nil, DefaultLocale},
+	}
+
+	for _, tt := range tests {
+		if got := Resolve(tt.candidates...); got != tt.want {
+			t.Errorf("Resolve(%v) = %q, want %q", tt.candidates, got, tt.want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T("es", "nav.models"); got != "Modelos" {
+		t.Errorf("T(es, nav.models) = %q", got)
+	}
+	if got := T("xx", "nav.models"); got != "Models" {
+		t.Errorf("T(xx, nav.models) = %q, want default locale", got)
+	}
+	if got := T("es", "missing.key"); got != "missing.key" {
+		t.Errorf("T(es, missing.key) = %q, want key", got)
+	}
+}
+
+func TestCatalogsHaveDefaultKeys(t *testing.T) {
+	for _, locale := range SupportedLocales() {
+		for key := range catalogs[DefaultLocale] {
+			if _, ok := catalogs[locale][key]; !ok {
+				t.Errorf("locale %s is missing %s", locale, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// Package jsonschema implements the subset of JSON Schema used to validate structured model output:
+// type, enum, const, properties, required, additionalProperties, items, min/max constraints,
+// pattern, and the allOf/anyOf/oneOf combinators. References ($ref) are not supported.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema is a parsed JSON Schema document
+type Schema struct {
+	root map[string]interface{}
+}
+
+// Parse parses a JSON Schema document. The schema must be a JSON object.
+func Parse(data []byte) (*Schema, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("schema must be a JSON object: %w", err)
+	}
+	if err := checkSchema(root, "$"); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// ValidateJSON parses data as JSON and validates it, returning one message per violation
+func (s *Schema) ValidateJSON(data []byte) []string {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return []string{fmt.Sprintf("$: invalid JSON: %v", err)}
+	}
+	return s.Validate(doc)
+}
+
+// Validate validates a decoded JSON value, returning one message per violation
+func (s *Schema) Validate(doc interface{}) []string {
+	var errs []string
+	validate(s.root, doc, "$", &errs)
+	return errs
+}
+
+// checkSchema rejects malformed keywords up front so bad schemas fail at save time
+func checkSchema(schema map[string]interface{}, path string) error {
+	if pattern, ok := schema["pattern"].(string); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", path, err)
+		}
+	}
+	if props, ok := schema["properties"]; ok {
+		propMap, ok := props.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: properties must be an object", path)
+		}
+		for name, sub := range propMap {
+			subSchema, ok := sub.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.%s: schema must be an object", path, name)
+			}
+			if err := checkSchema(subSchema, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		if err := checkSchema(items, path+"[]"); err != nil {
+			return err
+		}
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		if err := checkSchema(additional, path+".*"); err != nil {
+			return err
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		if list, ok := schema[keyword]; ok {
+			subs, ok := list.([]interface{})
+			if !ok {
+				return fmt.Errorf("%s: %s must be an array", path, keyword)
+			}
+			for i, sub := range subs {
+				subSchema, ok := sub.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("%s: %s[%d] must be an object", path, keyword, i)
+				}
+				if err := checkSchema(subSchema, path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validate(schema map[string]interface{}, value interface{}, path string, errs *[]string) {
+	addErr := func(format string, args ...interface{}) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		addErr("expected %s, got %s", describeType(t), typeOf(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if equal(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			addErr("value is not one of the allowed values")
+		}
+	}
+
+	if constant, ok := schema["const"]; ok && !equal(constant, value) {
+		addErr("value does not match the required constant")
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, errs)
+	case []interface{}:
+		validateArray(schema, v, path, errs)
+	case string:
+		length := len([]rune(v))
+		if min, ok := number(schema["minLength"]); ok && float64(length) < min {
+			addErr("string shorter than %v characters", min)
+		}
+		if max, ok := number(schema["maxLength"]); ok && float64(length) > max {
+			addErr("string longer than %v characters", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				addErr("string does not match pattern %q", pattern)
+			}
+		}
+	case float64:
+		if min, ok := number(schema["minimum"]); ok && v < min {
+			addErr("%v is less than minimum %v", v, min)
+		}
+		if max, ok := number(schema["maximum"]); ok && v > max {
+			addErr("%v is greater than maximum %v", v, max)
+		}
+		if min, ok := number(schema["exclusiveMinimum"]); ok && v <= min {
+			addErr("%v must be greater than %v", v, min)
+		}
+		if max, ok := number(schema["exclusiveMaximum"]); ok && v >= max {
+			addErr("%v must be less than %v", v, max)
+		}
+	}
+
+	if subs, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range subs {
+			if subSchema, ok := sub.(map[string]interface{}); ok {
+				validate(subSchema, value, path, errs)
+			}
+		}
+	}
+
+	if subs, ok := schema["anyOf"].([]interface{}); ok {
+		if countMatches(subs, value, path) == 0 {
+			addErr("value does not match any of the allowed schemas")
+		}
+	}
+
+	if subs, ok := schema["oneOf"].([]interface{}); ok {
+		if n := countMatches(subs, value, path); n != 1 {
+			addErr("value must match exactly one schema (matched %d)", n)
+		}
+	}
+}
+
+func validateObject(schema map[string]interface{}, obj map[string]interface{}, path string, errs *[]string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, present := obj[name]; !present {
+					*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+				}
+			}
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]interface{})
+
+	// Iterate in sorted order so error messages are stable
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		if propSchema, ok := props[key].(map[string]interface{}); ok {
+			validate(propSchema, obj[key], childPath, errs)
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*errs = append(*errs, fmt.Sprintf("%s: property %q is not allowed", path, key))
+			}
+		case map[string]interface{}:
+			validate(additional, obj[key], childPath, errs)
+		}
+	}
+
+	if min, ok := number(schema["minProperties"]); ok && float64(len(obj)) < min {
+		*errs = append(*errs, fmt.Sprintf("%s: object has fewer than %v properties", path, min))
+	}
+	if max, ok := number(schema["maxProperties"]); ok && float64(len(obj)) > max {
+		*errs = append(*errs, fmt.Sprintf("%s: object has more than %v properties", path, max))
+	}
+}
+
+func validateArray(schema map[string]interface{}, arr []interface{}, path string, errs *[]string) {
+	if min, ok := number(schema["minItems"]); ok && float64(len(arr)) < min {
+		*errs = append(*errs, fmt.Sprintf("%s: array has fewer than %v items", path, min))
+	}
+	if max, ok := number(schema["maxItems"]); ok && float64(len(arr)) > max {
+		*errs = append(*errs, fmt.Sprintf("%s: array has more than %v items", path, max))
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range arr {
+			validate(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+	if unique, ok := schema["uniqueItems"].(bool); ok && unique {
+		for i := 0; i < len(arr); i++ {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					*errs = append(*errs, fmt.Sprintf("%s: items %d and %d are not unique", path, i, j))
+					return
+				}
+			}
+		}
+	}
+}
+
+func countMatches(subs []interface{}, value interface{}, path string) int {
+	matches := 0
+	for _, sub := range subs {
+		subSchema, ok := sub.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var subErrs []string
+		validate(subSchema, value, path, &subErrs)
+		if len(subErrs) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+func matchesType(t interface{}, value interface{}) bool {
+	switch tv := t.(type) {
+	case string:
+		return matchesSingleType(tv, value)
+	case []interface{}:
+		for _, candidate := range tv {
+			if name, ok := candidate.(string); ok && matchesSingleType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesSingleType(name string, value interface{}) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func describeType(t interface{}) string {
+	if list, ok := t.([]interface{}); ok {
+		names := make([]string, 0, len(list))
+		for _, n := range list {
+			names = append(names, fmt.Sprint(n))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func number(v interface{}) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func equal(a, b interface{}) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aj) == string(bj)
+}
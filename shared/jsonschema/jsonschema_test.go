@@ -0,0 +1,57 @@
+package jsonschema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const personSchema = `{
+	"type": "object",
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "items": {"type": "string", "enum": ["a", "b"]}}
+	}
+}`
+
+func TestValidateJSON(t *testing.T) {
+	schema, err := Parse([]byte(personSchema))
+	require.NoError(t, err)
+
+	assert.Empty(t, schema.ValidateJSON([]byte(`{"name": "Ada", "age": 36, "tags": ["a"]}`)))
+
+	errs := schema.ValidateJSON([]byte(`{"name": "", "age": 1.5, "tags": ["c"], "extra": true}`))
+	assert.ElementsMatch(t, []string{
+		`$.name: string shorter than 1 characters`,
+		`$.age: expected integer, got number`,
+		`$.tags[0]: value is not one of the allowed values`,
+		`$: property "extra" is not allowed`,
+	}, errs)
+
+	errs = schema.ValidateJSON([]byte(`{"name": "Ada"}`))
+	assert.Equal(t, []string{`$: missing required property "age"`}, errs)
+
+	errs = schema.ValidateJSON([]byte(`not json`))
+	assert.Len(t, errs, 1)
+}
+
+func TestParseRejectsInvalidSchema(t *testing.T) {
+	_, err := Parse([]byte(`[]`))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"properties": {"a": {"pattern": "("}}}`))
+	assert.Error(t, err)
+}
+
+func TestCombinators(t *testing.T) {
+	schema, err := Parse([]byte(`{"oneOf": [{"type": "string"}, {"type": "integer"}]}`))
+	require.NoError(t, err)
+
+	assert.Empty(t, schema.ValidateJSON([]byte(`"x"`)))
+	assert.Empty(t, schema.ValidateJSON([]byte(`3`)))
+	assert.NotEmpty(t, schema.ValidateJSON([]byte(`true`)))
+}
@@ -20,6 +20,10 @@ func CustomLogger() gin.HandlerFunc {
 		status := c.Writer.Status()
 		method := c.Request.Method
 		clientIP := c.ClientIP()
+		if requestID := c.GetString("request_id"); requestID != "" {
+			log.Printf("%s %s %d %s %s request_id=%s", method, path, status, latency, clientIP, requestID)
+			return
+		}
 		log.Printf("%s %s %d %s %s", method, path, status, latency, clientIP)
 	}
 }
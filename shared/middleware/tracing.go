@@ -19,6 +19,9 @@ func TracingMiddleware() gin.HandlerFunc {
 		// fmt.Println(string(body))
 		ctx, span := otel.GetTracerProvider().Tracer("gateway").Start(c.Request.Context(), "handle_request")
 		span.SetAttributes(attribute.String("http.request.body", "blah"))
+		if requestID := c.GetString("request_id"); requestID != "" {
+			span.SetAttributes(attribute.String("relai.request_id", requestID))
+		}
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 		span.End()
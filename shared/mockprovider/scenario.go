@@ -0,0 +1,101 @@
+// Package mockprovider is an in-process stand-in for an OpenAI-compatible provider. Scenarios
+// script its latency, failures, malformed stream chunks and reported usage, so load and
+// integration tests can run through the whole gateway without provider spend.
+package mockprovider
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultScenario is used when a request doesn't name one
+const DefaultScenario = "default"
+
+// Scenario describes how the mock provider answers
+type Scenario struct {
+	LatencyMS       int `yaml:"latency_ms" json:"latency_ms"`               // Delay before the response starts
+	LatencyJitterMS int `yaml:"latency_jitter_ms" json:"latency_jitter_ms"` // Random extra delay, 0 to this
+
+	ErrorRate    float64 `yaml:"error_rate" json:"error_rate"`       // Fraction of requests that fail, 0-1
+	ErrorStatus  int     `yaml:"error_status" json:"error_status"`   // Defaults to 500
+	ErrorMessage string  `yaml:"error_message" json:"error_message"` // Defaults to a generic message
+
+	Response           string  `yaml:"response" json:"response"`                         // Completion text
+	Chunks             int     `yaml:"chunks" json:"chunks"`                             // Stream chunks the text is split into
+	ChunkDelayMS       int     `yaml:"chunk_delay_ms" json:"chunk_delay_ms"`             // Delay between stream chunks
+	MalformedChunkRate float64 `yaml:"malformed_chunk_rate" json:"malformed_chunk_rate"` // Fraction of chunks sent as invalid JSON
+
+	Usage     *Usage `yaml:"usage" json:"usage"`           // Reported usage; estimated from text length when unset
+	OmitUsage bool   `yaml:"omit_usage" json:"omit_usage"` // Leave the usage block out, like some self-hosted servers
+}
+
+// Usage is the token usage a scenario reports
+type Usage struct {
+	PromptTokens     int `yaml:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int `yaml:"completion_tokens" json:"completion_tokens"`
+}
+
+// Config is a scenario file: named scenarios and an optional random seed for reproducible runs
+type Config struct {
+	Seed      int64               `yaml:"seed"`
+	Scenarios map[string]Scenario `yaml:"scenarios"`
+}
+
+// LoadConfig reads a YAML (or JSON) scenario file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses and validates scenario file contents
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid mock scenario file: %w", err)
+	}
+	for name, s := range cfg.Scenarios {
+		if err := s.Validate(); err != nil {
+			return nil, fmt.Errorf("scenario %q: %w", name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// Validate checks rates and durations are in range
+func (s Scenario) Validate() error {
+	if s.ErrorRate < 0 || s.ErrorRate > 1 {
+		return fmt.Errorf("error_rate must be between 0 and 1")
+	}
+	if s.MalformedChunkRate < 0 || s.MalformedChunkRate > 1 {
+		return fmt.Errorf("malformed_chunk_rate must be between 0 and 1")
+	}
+	if s.LatencyMS < 0 || s.LatencyJitterMS < 0 || s.ChunkDelayMS < 0 || s.Chunks < 0 {
+		return fmt.Errorf("latencies and chunk counts can't be negative")
+	}
+	if s.ErrorStatus != 0 && (s.ErrorStatus < 400 || s.ErrorStatus > 599) {
+		return fmt.Errorf("error_status must be a 4xx or 5xx status")
+	}
+	return nil
+}
+
+// withDefaults fills in the values a scenario leaves unset
+func (s Scenario) withDefaults() Scenario {
+	if s.ErrorStatus == 0 {
+		s.ErrorStatus = 500
+	}
+	if s.ErrorMessage == "" {
+		s.ErrorMessage = "The mock provider failed this request as scripted"
+	}
+	if s.Response == "" {
+		s.Response = "Hello from the mock provider!"
+	}
+	if s.Chunks == 0 {
+		s.Chunks = 5
+	}
+	return s
+}
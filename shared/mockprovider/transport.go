@@ -0,0 +1,310 @@
+package mockprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScenarioHeader picks a scenario by name for one request; the gateway forwards it upstream
+const ScenarioHeader = "X-Mock-Scenario"
+
+// Transport answers chat completion, completion and embedding requests in-process according
+// to the scenario the request names, without any network traffic
+type Transport struct {
+	config *Config
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewTransport creates a mock provider for the scenarios in cfg. A nil cfg (or one without a
+// "default" scenario) answers instantly and successfully by default.
+func NewTransport(cfg *Config) *Transport {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Transport{config: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+type mockRequest struct {
+	Model         string `json:"model"`
+	Stream        bool   `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+	Messages []struct {
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+	Prompt json.RawMessage `json:"prompt"`
+	Input  json.RawMessage `json:"input"`
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	name := req.Header.Get(ScenarioHeader)
+	if name == "" {
+		name = DefaultScenario
+	}
+	scenario, ok := t.config.Scenarios[name]
+	if !ok && name != DefaultScenario {
+		return jsonResponse(req, http.StatusBadRequest, errorBody("unknown mock scenario: "+name)), nil
+	}
+	scenario = scenario.withDefaults()
+
+	if err := sleep(req.Context(), time.Duration(scenario.LatencyMS+t.intn(scenario.LatencyJitterMS+1))*time.Millisecond); err != nil {
+		return nil, err
+	}
+	if scenario.ErrorRate > 0 && t.float() < scenario.ErrorRate {
+		return jsonResponse(req, scenario.ErrorStatus, errorBody(scenario.ErrorMessage)), nil
+	}
+
+	var parsed mockRequest
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return jsonResponse(req, http.StatusBadRequest, errorBody("request body is not valid JSON")), nil
+	}
+
+	id := fmt.Sprintf("mock-%d", time.Now().UnixNano())
+	usage := scenario.usageFor(&parsed)
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/chat/completions"):
+		if parsed.Stream {
+			return t.streamResponse(req, scenario, &parsed, id, usage, true), nil
+		}
+		return jsonResponse(req, http.StatusOK, chatCompletion(scenario, &parsed, id, usage)), nil
+	case strings.HasSuffix(req.URL.Path, "/completions"):
+		if parsed.Stream {
+			return t.streamResponse(req, scenario, &parsed, id, usage, false), nil
+		}
+		return jsonResponse(req, http.StatusOK, textCompletion(scenario, &parsed, id, usage)), nil
+	case strings.HasSuffix(req.URL.Path, "/embeddings"):
+		return jsonResponse(req, http.StatusOK, embeddings(scenario, &parsed, usage)), nil
+	}
+	return jsonResponse(req, http.StatusNotFound, errorBody("the mock provider does not implement "+req.URL.Path)), nil
+}
+
+// usageFor returns the scripted usage, or one estimated at four characters per token
+func (s Scenario) usageFor(req *mockRequest) Usage {
+	if s.Usage != nil {
+		return *s.Usage
+	}
+	promptChars := len(req.Prompt) + len(req.Input)
+	for _, m := range req.Messages {
+		promptChars += len(m.Content)
+	}
+	return Usage{PromptTokens: promptChars/4 + 1, CompletionTokens: len(s.Response)/4 + 1}
+}
+
+func usageBlock(u Usage) map[string]int {
+	return map[string]int{
+		"prompt_tokens":     u.PromptTokens,
+		"completion_tokens": u.CompletionTokens,
+		"total_tokens":      u.PromptTokens + u.CompletionTokens,
+	}
+}
+
+func chatCompletion(s Scenario, req *mockRequest, id string, usage Usage) map[string]interface{} {
+	resp := map[string]interface{}{
+		"id":      "chatcmpl-" + id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": s.Response},
+			"finish_reason": "stop",
+		}},
+	}
+	if !s.OmitUsage {
+		resp["usage"] = usageBlock(usage)
+	}
+	return resp
+}
+
+func textCompletion(s Scenario, req *mockRequest, id string, usage Usage) map[string]interface{} {
+	resp := map[string]interface{}{
+		"id":      "cmpl-" + id,
+		"object":  "text_completion",
+		"created": time.Now().Unix(),
+		"model":   req.Model,
+		"choices": []map[string]interface{}{{"index": 0, "text": s.Response, "finish_reason": "stop"}},
+	}
+	if !s.OmitUsage {
+		resp["usage"] = usageBlock(usage)
+	}
+	return resp
+}
+
+func embeddings(s Scenario, req *mockRequest, usage Usage) map[string]interface{} {
+	var inputs []interface{}
+	if err := json.Unmarshal(req.Input, &inputs); err != nil {
+		inputs = []interface{}{nil}
+	}
+	data := make([]map[string]interface{}, len(inputs))
+	for i := range inputs {
+		data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": []float64{0.1, 0.2, 0.3, 0.4}}
+	}
+	resp := map[string]interface{}{"object": "list", "model": req.Model, "data": data}
+	if !s.OmitUsage {
+		resp["usage"] = map[string]int{"prompt_tokens": usage.PromptTokens, "total_tokens": usage.PromptTokens}
+	}
+	return resp
+}
+
+// streamResponse sends the scripted text as server-sent events, one chunk every ChunkDelayMS,
+// with a MalformedChunkRate share of them cut off mid-JSON
+func (t *Transport) streamResponse(req *http.Request, s Scenario, parsed *mockRequest, id string, usage Usage, chat bool) *http.Response {
+	pr, pw := io.Pipe()
+	object, prefix := "text_completion", "cmpl-"
+	if chat {
+		object, prefix = "chat.completion.chunk", "chatcmpl-"
+	}
+	includeUsage := parsed.StreamOptions != nil && parsed.StreamOptions.IncludeUsage && !s.OmitUsage
+
+	go func() {
+		write := func(chunk map[string]interface{}) error {
+			data, _ := json.Marshal(chunk)
+			if s.MalformedChunkRate > 0 && t.float() < s.MalformedChunkRate {
+				data = data[:len(data)/2]
+			}
+			_, err := io.WriteString(pw, "data: "+string(data)+"\n\n")
+			return err
+		}
+		base := func(choices []map[string]interface{}) map[string]interface{} {
+			return map[string]interface{}{"id": prefix + id, "object": object, "created": time.Now().Unix(), "model": parsed.Model, "choices": choices}
+		}
+
+		parts := splitText(s.Response, s.Chunks)
+		for i, part := range parts {
+			if i > 0 {
+				if err := sleep(req.Context(), time.Duration(s.ChunkDelayMS)*time.Millisecond); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			choice := map[string]interface{}{"index": 0, "text": part, "finish_reason": nil}
+			if chat {
+				delta := map[string]string{"content": part}
+				if i == 0 {
+					delta["role"] = "assistant"
+				}
+				choice = map[string]interface{}{"index": 0, "delta": delta, "finish_reason": nil}
+			}
+			if i == len(parts)-1 {
+				choice["finish_reason"] = "stop"
+			}
+			if err := write(base([]map[string]interface{}{choice})); err != nil {
+				return
+			}
+		}
+		if includeUsage {
+			chunk := base([]map[string]interface{}{})
+			chunk["usage"] = usageBlock(usage)
+			if err := write(chunk); err != nil {
+				return
+			}
+		}
+		io.WriteString(pw, "data: [DONE]\n\n")
+		pw.Close()
+	}()
+
+	resp := newResponse(req, http.StatusOK, pr)
+	resp.Header.Set("Content-Type", "text/event-stream")
+	resp.ContentLength = -1
+	return resp
+}
+
+// splitText cuts text into n roughly equal pieces on rune boundaries
+func splitText(text string, n int) []string {
+	runes := []rune(text)
+	if n > len(runes) {
+		n = len(runes)
+	}
+	if n <= 1 {
+		return []string{text}
+	}
+	parts := make([]string, 0, n)
+	size := (len(runes) + n - 1) / n
+	for start := 0; start < len(runes); start += size {
+		end := start + size
+		if end > len(runes) {
+			end = len(runes)
+		}
+		parts = append(parts, string(runes[start:end]))
+	}
+	return parts
+}
+
+func errorBody(message string) map[string]interface{} {
+	return map[string]interface{}{"error": map[string]string{"message": message, "type": "mock_error"}}
+}
+
+func jsonResponse(req *http.Request, status int, body interface{}) *http.Response {
+	data, _ := json.Marshal(body)
+	resp := newResponse(req, status, io.NopCloser(bytes.NewReader(data)))
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	resp.ContentLength = int64(len(data))
+	return resp
+}
+
+func newResponse(req *http.Request, status int, body io.ReadCloser) *http.Response {
+	return &http.Response{
+		Status:     strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"X-Request-Id": []string{fmt.Sprintf("mock-req-%d", time.Now().UnixNano())}},
+		Body:       body,
+		Request:    req,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Transport) float() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rng.Float64()
+}
+
+func (t *Transport) intn(n int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rng.Intn(n)
+}
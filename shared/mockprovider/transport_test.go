@@ -0,0 +1,126 @@
+package mockprovider
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func post(t *testing.T, tr *Transport, path, scenario, body string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, "http://mock-provider"+path, strings.NewReader(body))
+	if scenario != "" {
+		req.Header.Set(ScenarioHeader, scenario)
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+	return resp, string(out)
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte("seed: 7\nscenarios:\n  default:\n    latency_ms: 5\n    usage: {prompt_tokens: 10, completion_tokens: 20}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Seed != 7 || cfg.Scenarios["default"].Usage.CompletionTokens != 20 {
+		t.Errorf("config = %+v", cfg)
+	}
+
+	for _, bad := range []string{
+		"scenarios:\n  flaky:\n    error_rate: 1.5\n",
+		"scenarios:\n  flaky:\n    error_status: 200\n",
+		"scenarios:\n  slow:\n    latency: 5\n",
+	} {
+		if _, err := ParseConfig([]byte(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestChatCompletionUsage(t *testing.T) {
+	tr := NewTransport(&Config{Scenarios: map[string]Scenario{
+		"default": {Response: "Hi", Usage: &Usage{PromptTokens: 12, CompletionTokens: 34}},
+	}})
+	resp, body := post(t, tr, "/v1/chat/completions", "", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, body %s", resp.StatusCode, body)
+	}
+
+	var got struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message struct{ Content string } `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "gpt-4o" || got.Choices[0].Message.Content != "Hi" || got.Usage.TotalTokens != 46 {
+		t.Errorf("response = %s", body)
+	}
+}
+
+func TestErrorInjection(t *testing.T) {
+	tr := NewTransport(&Config{Scenarios: map[string]Scenario{
+		"rate-limited": {ErrorRate: 1, ErrorStatus: 429, ErrorMessage: "slow down"},
+	}})
+	resp, body := post(t, tr, "/v1/chat/completions", "rate-limited", `{"model":"m"}`)
+	if resp.StatusCode != http.StatusTooManyRequests || !strings.Contains(body, "slow down") {
+		t.Errorf("status = %d, body %s", resp.StatusCode, body)
+	}
+
+	resp, _ = post(t, tr, "/v1/chat/completions", "missing", `{"model":"m"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown scenario status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestStream(t *testing.T) {
+	body := `{"model":"m","stream":true,"stream_options":{"include_usage":true}}`
+
+	tr := NewTransport(&Config{Scenarios: map[string]Scenario{
+		"default":  {Response: "abcdef", Chunks: 3, Usage: &Usage{PromptTokens: 1, CompletionTokens: 2}},
+		"garbled":  {Response: "abcdef", Chunks: 3, MalformedChunkRate: 1},
+		"no-usage": {Response: "abcdef", Chunks: 3, OmitUsage: true},
+	}})
+
+	_, out := post(t, tr, "/v1/chat/completions", "", body)
+	events := strings.Split(strings.TrimSpace(out), "\n\n")
+	if len(events) != 5 || events[4] != "data: [DONE]" {
+		t.Fatalf("events = %q", events)
+	}
+	if !strings.Contains(events[0], `"content":"ab"`) || !strings.Contains(events[3], `"total_tokens":3`) {
+		t.Errorf("events = %q", events)
+	}
+
+	_, out = post(t, tr, "/v1/chat/completions", "garbled", body)
+	for _, event := range strings.Split(strings.TrimSpace(out), "\n\n") {
+		data := strings.TrimPrefix(event, "data: ")
+		if data != "[DONE]" && json.Valid([]byte(data)) {
+			t.Errorf("expected a malformed chunk, got %s", data)
+		}
+	}
+
+	_, out = post(t, tr, "/v1/chat/completions", "no-usage", body)
+	if strings.Contains(out, "usage") {
+		t.Errorf("usage should be omitted: %s", out)
+	}
+}
+
+func TestUnsupportedEndpoint(t *testing.T) {
+	resp, _ := post(t, NewTransport(nil), "/v1/audio/speech", "", `{}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
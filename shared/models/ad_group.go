@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultADGroupPriority is used when a mapping doesn't set one
+const DefaultADGroupPriority = 100
+
+// OrgADGroupMapping grants members of an AD group a role in an organization. When a user is in
+// several mapped groups, the mapping with the lowest Priority decides their role.
+type OrgADGroupMapping struct {
+	ID             string     `json:"id" db:"id"`
+	OrganizationID string     `json:"organization_id" db:"organization_id"`
+	ADGroupID      string     `json:"ad_group_id" db:"ad_group_id"`
+	ADGroupName    string     `json:"ad_group_name" db:"ad_group_name"`
+	RoleName       string     `json:"role_name" db:"role_type"`
+	Priority       int        `json:"priority" db:"priority"`
+	IsActive       bool       `json:"is_active" db:"is_active"`
+	CreatedAt      *time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateOrgADGroupMappingRequest maps an AD group to a built-in or custom role
+type CreateOrgADGroupMappingRequest struct {
+	ADGroupID   string `json:"ad_group_id" binding:"required"`
+	ADGroupName string `json:"ad_group_name"`
+	RoleName    string `json:"role_name" binding:"required"`
+	Priority    *int   `json:"priority"`
+}
+
+// Validate checks the role name is well-formed and the priority isn't negative
+func (r *CreateOrgADGroupMappingRequest) Validate() error {
+	if err := validateOrgRoleName(r.RoleName); err != nil {
+		return err
+	}
+	return validateADGroupPriority(r.Priority)
+}
+
+// UpdateOrgADGroupMappingRequest changes a mapping's role, priority or active state
+type UpdateOrgADGroupMappingRequest struct {
+	ADGroupName *string `json:"ad_group_name"`
+	RoleName    *string `json:"role_name"`
+	Priority    *int    `json:"priority"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+// Validate checks any new role name is well-formed and the priority isn't negative
+func (r *UpdateOrgADGroupMappingRequest) Validate() error {
+	if r.RoleName != nil {
+		if err := validateOrgRoleName(*r.RoleName); err != nil {
+			return err
+		}
+	}
+	return validateADGroupPriority(r.Priority)
+}
+
+func validateADGroupPriority(priority *int) error {
+	if priority != nil && *priority < 0 {
+		return fmt.Errorf("priority must be 0 or greater")
+	}
+	return nil
+}
+
+// ResolveADGroupRole picks the role for a user matched by several of an organization's mappings.
+// The lowest priority wins; at equal priority admin beats custom roles, custom roles beat
+// member, member beats developer, and custom roles are ordered by name so the outcome doesn't
+// depend on query order.
+func ResolveADGroupRole(matches []OrgADGroupMapping) (string, bool) {
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	best := matches[0]
+	for _, m := range matches[1:] {
+		if m.Priority < best.Priority ||
+			(m.Priority == best.Priority && roleOutranks(m.RoleName, best.RoleName)) {
+			best = m
+		}
+	}
+	return best.RoleName, true
+}
+
+// roleOutranks breaks priority ties between two role names
+func roleOutranks(a, b string) bool {
+	rank := func(role string) int {
+		switch role {
+		case OrgRoleAdmin:
+			return 0
+		case OrgRoleMember:
+			return 2
+		case OrgRoleDeveloper:
+			return 3
+		}
+		return 1
+	}
+	if rank(a) != rank(b) {
+		return rank(a) < rank(b)
+	}
+	return a < b
+}
+
+// AD sync simulation outcomes for one organization
+const (
+	ADSyncActionAdd    = "add"    // Not a member yet; would be added with the resolved role
+	ADSyncActionUpdate = "update" // Member with a different role; role would change
+	ADSyncActionKeep   = "keep"   // Already has the resolved role
+	ADSyncActionRemove = "remove" // Synced member no longer matched by any mapping
+	ADSyncActionPinned = "pinned" // Manual membership the sync leaves alone
+)
+
+// ADSyncSimulationRequest asks what AD group sync would do for a user, a set of groups, or a
+// user with substitute groups
+type ADSyncSimulationRequest struct {
+	Email      string   `json:"email"`
+	ADGroupIDs []string `json:"ad_group_ids"`
+}
+
+// Validate checks there's something to simulate
+func (r *ADSyncSimulationRequest) Validate() error {
+	if r.Email == "" && len(r.ADGroupIDs) == 0 {
+		return fmt.Errorf("email or ad_group_ids is required")
+	}
+	return nil
+}
+
+// ADSyncOrgResult is what the sync would do in one organization
+type ADSyncOrgResult struct {
+	OrganizationID   string              `json:"organization_id"`
+	OrganizationName string              `json:"organization_name"`
+	MatchedMappings  []OrgADGroupMapping `json:"matched_mappings"`
+	ResultingRole    string              `json:"resulting_role,omitempty"`
+	CurrentRole      string              `json:"current_role,omitempty"`
+	CurrentSource    string              `json:"current_source,omitempty"`
+	Action           string              `json:"action"`
+}
+
+// ADSyncSimulation is the dry-run outcome of AD group sync
+type ADSyncSimulation struct {
+	UserID        string            `json:"user_id,omitempty"`
+	Email         string            `json:"email,omitempty"`
+	ADGroupIDs    []string          `json:"ad_group_ids"`
+	Organizations []ADSyncOrgResult `json:"organizations"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestResolveADGroupRole(t *testing.T) {
+	mapping := func(role string, priority int) OrgADGroupMapping {
+		return OrgADGroupMapping{RoleName: role, Priority: priority}
+	}
+
+	tests := []struct {
+		name    string
+		matches []OrgADGroupMapping
+		want    string
+		wantOK  bool
+	}{
+		{"no matches", nil, "", false},
+		{"single", []OrgADGroupMapping{mapping("member", 100)}, "member", true},
+		{"admin wins tie", []OrgADGroupMapping{mapping("member", 100), mapping("admin", 100)}, "admin", true},
+		{"custom beats member on tie", []OrgADGroupMapping{mapping("member", 100), mapping("billing", 100)}, "billing", true},
+		{"member beats developer on tie", []OrgADGroupMapping{mapping("developer", 100), mapping("member", 100)}, "member", true},
+		{"custom ties ordered by name", []OrgADGroupMapping{mapping("support", 50), mapping("billing", 50)}, "billing", true},
+		{"lower priority wins", []OrgADGroupMapping{mapping("admin", 100), mapping("viewer", 10)}, "viewer", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ResolveADGroupRole(tt.matches)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ResolveADGroupRole() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
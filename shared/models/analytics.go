@@ -39,14 +39,59 @@ type ProviderSpendData struct {
 	Percentage   float64 `json:"percentage"`
 }
 
+// ProjectSpendData is one project's share of spend; usage by keys outside any project is
+// reported with an empty ProjectID
+type ProjectSpendData struct {
+	ProjectID    string  `json:"project_id"`
+	Name         string  `json:"name"`
+	TotalTokens  int64   `json:"total_tokens"`
+	TotalCost    float64 `json:"total_cost"`
+	RequestCount int64   `json:"request_count"`
+}
+
+// Endpoint analytics groupings: by request path, or by custom endpoint name with standard API
+// paths kept as they are
+const (
+	EndpointGroupPath           = "path"
+	EndpointGroupCustomEndpoint = "custom_endpoint"
+)
+
+// IsValidEndpointGrouping reports whether g is a known endpoint analytics grouping
+func IsValidEndpointGrouping(g string) bool {
+	return g == EndpointGroupPath || g == EndpointGroupCustomEndpoint
+}
+
+// EndpointSpendData is one endpoint's (or custom endpoint's) share of spend
+type EndpointSpendData struct {
+	Name           string  `json:"name"`
+	CustomEndpoint bool    `json:"custom_endpoint"` // Name is a custom endpoint rather than a path
+	TotalTokens    int64   `json:"total_tokens"`
+	TotalCost      float64 `json:"total_cost"`
+	RequestCount   int64   `json:"request_count"`
+	Percentage     float64 `json:"percentage"`
+}
+
+// EndpointSeriesPoint is one endpoint group's spend in one hour or day; groups outside the top
+// few are combined under "Other"
+type EndpointSeriesPoint struct {
+	Date         string  `json:"date"`
+	Name         string  `json:"name"`
+	TotalCost    float64 `json:"total_cost"`
+	RequestCount int64   `json:"request_count"`
+}
+
 type DashboardData struct {
 	Metrics       DashboardMetrics    `json:"metrics"`
 	DailyCosts    []DailyCostData     `json:"daily_costs"`
 	TopModels     []TopModelData      `json:"top_models"`
 	TopAPIKeys    []TopAPIKeyData     `json:"top_api_keys"`
 	ProviderSpend []ProviderSpendData `json:"provider_spend"`
+	ProjectSpend  []ProjectSpendData  `json:"project_spend"`
 	TimeRange     string              `json:"time_range"`
 	Organization  string              `json:"organization"`
+	CostsHidden   bool                `json:"costs_hidden"` // Cost figures are zeroed for a caller without costs:read
+	Currency      string              `json:"currency"`     // Currency the cost figures are in
+	FXRate        float64             `json:"fx_rate"`      // Units of Currency per USD they were converted at
 	GeneratedAt   time.Time           `json:"generated_at"`
 }
 
@@ -55,4 +100,38 @@ type AnalyticsFilter struct {
 	StartDate    string `json:"start_date,omitempty"`
 	EndDate      string `json:"end_date,omitempty"`
 	Organization string `json:"organization,omitempty"`
+	// IncludeChildren rolls the organization's sub-teams into its figures
+	IncludeChildren bool `json:"include_children,omitempty"`
+	// Models narrows the figures to these model IDs; empty means every model
+	Models []string `json:"models,omitempty"`
+}
+
+// KeyUsagePoint is one hour or day of a key's traffic
+type KeyUsagePoint struct {
+	Date      string  `json:"date"`
+	Requests  int64   `json:"requests"`
+	Tokens    int64   `json:"tokens"`
+	Cost      float64 `json:"cost"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"` // Percentage of requests with a 4xx/5xx response
+}
+
+// KeyUsage is the usage drill-down for a single API key
+type KeyUsage struct {
+	APIKeyID       string          `json:"api_key_id"`
+	Name           string          `json:"name"`
+	KeyPrefix      string          `json:"key_prefix"`
+	OrganizationID string          `json:"organization_id"`
+	Requests       int64           `json:"requests"`
+	Tokens         int64           `json:"tokens"`
+	Cost           float64         `json:"cost"`
+	Errors         int64           `json:"errors"`
+	ErrorRate      float64         `json:"error_rate"`
+	Series         []KeyUsagePoint `json:"series"`
+	TopModels      []TopModelData  `json:"top_models"`
+	TimeRange      string          `json:"time_range"`
+	CostsHidden    bool            `json:"costs_hidden"`
+	Currency       string          `json:"currency"`
+	FXRate         float64         `json:"fx_rate"`
+	GeneratedAt    time.Time       `json:"generated_at"`
 }
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnalyticsView is a named, saved combination of analytics filters. A shared view can be opened
+// by any member of its organization who can read analytics there.
+type AnalyticsView struct {
+	ID             string               `json:"id" db:"id"`
+	UserID         string               `json:"user_id" db:"user_id"`
+	OrganizationID *string              `json:"organization_id" db:"organization_id"` // The filtered organization; nil for gateway-wide views
+	Name           string               `json:"name" db:"name"`
+	Filters        AnalyticsViewFilters `json:"filters" db:"filters"`
+	Shared         bool                 `json:"shared" db:"shared"`
+	CreatedAt      time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at" db:"updated_at"`
+	OwnerName      string               `json:"owner_name,omitempty" db:"owner_name"`
+	URL            string               `json:"url,omitempty"`
+}
+
+// AnalyticsViewFilters is what a view restores on the analytics dashboard
+type AnalyticsViewFilters struct {
+	AnalyticsFilter
+	GroupBy string `json:"group_by,omitempty"` // Endpoint grouping
+}
+
+// Validate checks the time range, model IDs and grouping
+func (f *AnalyticsViewFilters) Validate() error {
+	switch f.TimeRange {
+	case "", "6h", "12h", "24h", "7d", "30d":
+	case "custom":
+		if _, err := time.Parse("2006-01-02", f.StartDate); err != nil {
+			return fmt.Errorf("start_date must be a YYYY-MM-DD date for a custom range")
+		}
+	default:
+		return fmt.Errorf("unknown time_range %q", f.TimeRange)
+	}
+	if f.Organization != "" {
+		if _, err := uuid.Parse(f.Organization); err != nil {
+			return fmt.Errorf("organization must be an organization ID")
+		}
+	}
+	for _, id := range f.Models {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("models must be model IDs, got %q", id)
+		}
+	}
+	if f.GroupBy != "" && !IsValidEndpointGrouping(f.GroupBy) {
+		return fmt.Errorf("group_by must be %q or %q", EndpointGroupPath, EndpointGroupCustomEndpoint)
+	}
+	return nil
+}
+
+type CreateAnalyticsViewRequest struct {
+	Name    string               `json:"name" binding:"required"`
+	Filters AnalyticsViewFilters `json:"filters"`
+	Shared  bool                 `json:"shared"`
+}
+
+// Validate trims the name and checks the filters; only views of one organization can be shared
+func (r *CreateAnalyticsViewRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > 255 {
+		return fmt.Errorf("name must be between 1 and 255 characters")
+	}
+	if err := r.Filters.Validate(); err != nil {
+		return err
+	}
+	if r.Shared && r.Filters.Organization == "" {
+		return fmt.Errorf("only views filtered to an organization can be shared")
+	}
+	return nil
+}
+
+// UpdateAnalyticsViewRequest changes the fields given; Filters replaces the saved filters whole
+type UpdateAnalyticsViewRequest struct {
+	Name    *string               `json:"name"`
+	Filters *AnalyticsViewFilters `json:"filters"`
+	Shared  *bool                 `json:"shared"`
+}
+
+// Validate trims the name and checks the filters
+func (r *UpdateAnalyticsViewRequest) Validate() error {
+	if r.Name != nil {
+		name := strings.TrimSpace(*r.Name)
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("name must be between 1 and 255 characters")
+		}
+		r.Name = &name
+	}
+	if r.Filters != nil {
+		if err := r.Filters.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnalyticsViewURL is the dashboard link that opens a saved view
+func AnalyticsViewURL(id string) string {
+	return "/admin/analytics/usage?view=" + id
+}
@@ -0,0 +1,57 @@
+package models
+
+import "testing"
+
+func TestCreateAnalyticsViewRequestValidate(t *testing.T) {
+	req := CreateAnalyticsViewRequest{
+		Name: "  weekly gpt spend  ",
+		Filters: AnalyticsViewFilters{
+			AnalyticsFilter: AnalyticsFilter{
+				TimeRange:    "7d",
+				Organization: "00000000-0000-0000-0000-000000000001",
+				Models:       []string{"00000000-0000-0000-0000-000000000002"},
+			},
+			GroupBy: EndpointGroupPath,
+		},
+		Shared: true,
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Name != "weekly gpt spend" {
+		t.Errorf("name = %q, want trimmed", req.Name)
+	}
+
+	if err := (&CreateAnalyticsViewRequest{Name: "all", Shared: true}).Validate(); err == nil {
+		t.Error("expected sharing a gateway-wide view to be rejected")
+	}
+	if err := (&CreateAnalyticsViewRequest{Name: " "}).Validate(); err == nil {
+		t.Error("expected a blank name to be rejected")
+	}
+}
+
+func TestAnalyticsViewFiltersValidate(t *testing.T) {
+	valid := []AnalyticsViewFilters{
+		{},
+		{AnalyticsFilter: AnalyticsFilter{TimeRange: "custom", StartDate: "2026-03-01"}},
+		{GroupBy: EndpointGroupCustomEndpoint},
+	}
+	for _, f := range valid {
+		if err := f.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error: %v", f, err)
+		}
+	}
+
+	invalid := []AnalyticsViewFilters{
+		{AnalyticsFilter: AnalyticsFilter{TimeRange: "90d"}},
+		{AnalyticsFilter: AnalyticsFilter{TimeRange: "custom"}},
+		{AnalyticsFilter: AnalyticsFilter{Organization: "default"}},
+		{AnalyticsFilter: AnalyticsFilter{Models: []string{"gpt-4o'; DROP TABLE models; --"}}},
+		{GroupBy: "provider"},
+	}
+	for _, f := range invalid {
+		if err := f.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", f)
+		}
+	}
+}
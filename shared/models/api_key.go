@@ -1,9 +1,99 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
+// API key scopes, one per gateway endpoint family. A key with no scopes may call every endpoint.
+// The debug scope is the exception: it is never implied, and only keys:write holders grant it.
+const (
+	APIKeyScopeChat        = "chat"
+	APIKeyScopeCompletions = "completions"
+	APIKeyScopeEmbeddings  = "embeddings"
+	APIKeyScopeModerations = "moderations"
+	APIKeyScopeImages      = "images"
+	APIKeyScopeAudio       = "audio"
+	APIKeyScopeFeedback    = "feedback"
+	APIKeyScopeBatches     = "batches"   // Batch jobs and their files
+	APIKeyScopeVectors     = "vectors"   // Vector collections and their documents
+	APIKeyScopeEndpoints   = "endpoints" // Organization custom endpoints
+	APIKeyScopeDebug       = "debug"     // X-RelAI-Debug traces of the key's organization's requests
+)
+
+// APIKeyScopes lists every scope a key can be limited to
+var APIKeyScopes = []string{
+	APIKeyScopeChat,
+	APIKeyScopeCompletions,
+	APIKeyScopeEmbeddings,
+	APIKeyScopeModerations,
+	APIKeyScopeImages,
+	APIKeyScopeAudio,
+	APIKeyScopeFeedback,
+	APIKeyScopeBatches,
+	APIKeyScopeVectors,
+	APIKeyScopeEndpoints,
+	APIKeyScopeDebug,
+}
+
+// IsValidAPIKeyScope reports whether scope is a known API key scope
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// StopRequestPathPrefix is where a key stops its own in-flight requests, whatever their scope
+const StopRequestPathPrefix = "/v1/requests/"
+
+// DebugTracePathPrefix is where a debug-scoped key looks up a request's debug trace
+const DebugTracePathPrefix = "/v1/debug/"
+
+// APIKeyScopeForPath returns the scope a gateway request path needs
+func APIKeyScopeForPath(path string) string {
+	switch {
+	case path == "/v1/chat/completions", strings.HasPrefix(path, "/v1/sessions/"):
+		return APIKeyScopeChat
+	case path == "/v1/completions":
+		return APIKeyScopeCompletions
+	case path == "/v1/embeddings":
+		return APIKeyScopeEmbeddings
+	case path == "/v1/moderations":
+		return APIKeyScopeModerations
+	case strings.HasPrefix(path, "/v1/images/"):
+		return APIKeyScopeImages
+	case strings.HasPrefix(path, "/v1/audio/"):
+		return APIKeyScopeAudio
+	case path == "/v1/feedback":
+		return APIKeyScopeFeedback
+	case path == "/v1/batches", strings.HasPrefix(path, "/v1/batches/"),
+		path == "/v1/files", strings.HasPrefix(path, "/v1/files/"):
+		return APIKeyScopeBatches
+	case strings.HasPrefix(path, "/v1/vector/"):
+		return APIKeyScopeVectors
+	case strings.HasPrefix(path, DebugTracePathPrefix):
+		return APIKeyScopeDebug
+	}
+	return APIKeyScopeEndpoints
+}
+
+// APIKeyAllows reports whether a key with the given scopes may use scope
+func APIKeyAllows(scopes []string, scope string) bool {
+	if len(scopes) == 0 {
+		return scope != APIKeyScopeDebug
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 type APIKey struct {
 	ID             string        `json:"id" db:"id"`
 	Name           string        `json:"name" db:"name"`
@@ -12,21 +102,123 @@ type APIKey struct {
 	KeyPrefix      string        `json:"key" db:"key_prefix"`
 	OrganizationID string        `json:"organization_id" db:"organization_id"`
 	UserID         *string       `json:"user_id" db:"user_id"`
+	OwnerUserID    *string       `json:"owner_user_id" db:"owner_user_id"`
+	Scopes         []string      `json:"scopes" db:"scopes"`
+	ProjectID      *string       `json:"project_id" db:"project_id"`
+	ProjectName    *string       `json:"project_name,omitempty" db:"project_name"`
 	MaxTokens      int           `json:"max_tokens" db:"max_tokens"`
 	IsActive       bool          `json:"active" db:"is_active"`
+	ExpiresAt      *time.Time    `json:"expires_at" db:"expires_at"`
 	LastUsed       *time.Time    `json:"last_used" db:"last_used"`
 	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
 	Organization   *Organization `json:"organization,omitempty"`
 	User           *User         `json:"user,omitempty"`
+	Owner          *User         `json:"owner,omitempty"`
+	IsCanary       bool          `json:"is_canary" db:"is_canary"`
+	CanaryBlockIP  bool          `json:"canary_block_ip" db:"canary_block_ip"`
+	// DailyRequests counts the key's requests for each of the last SparklineDays days, oldest
+	// first; only filled in organization listings
+	DailyRequests []int `json:"daily_requests,omitempty"`
+}
+
+// SparklineDays is how many days of usage organization key listings include
+const SparklineDays = 30
+
+// RecentRequests totals DailyRequests
+func (k *APIKey) RecentRequests() int {
+	total := 0
+	for _, n := range k.DailyRequests {
+		total += n
+	}
+	return total
+}
+
+// SparklinePoints draws DailyRequests as SVG polyline points in a 60x16 box
+func (k *APIKey) SparklinePoints() string {
+	if len(k.DailyRequests) < 2 {
+		return ""
+	}
+	max := 1
+	for _, n := range k.DailyRequests {
+		if n > max {
+			max = n
+		}
+	}
+	points := make([]string, len(k.DailyRequests))
+	step := 60.0 / float64(len(k.DailyRequests)-1)
+	for i, n := range k.DailyRequests {
+		points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, 15-14*float64(n)/float64(max))
+	}
+	return strings.Join(points, " ")
+}
+
+// IsExpired reports whether the key has passed its expiry date
+func (k *APIKey) IsExpired() bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now())
 }
 
 type CreateAPIKeyRequest struct {
-	Name           string  `json:"name" form:"name" binding:"required"`
-	Description    *string `json:"description" form:"description"`
-	MaxTokens      int     `json:"max_tokens" form:"max_tokens"`
-	OrganizationID string  `json:"organization_id" form:"organization_id"`
-	UserID         *string `json:"user_id" form:"user_id"`
+	Name           string     `json:"name" form:"name" binding:"required"`
+	Description    *string    `json:"description" form:"description"`
+	MaxTokens      int        `json:"max_tokens" form:"max_tokens"`
+	OrganizationID string     `json:"organization_id" form:"organization_id"`
+	UserID         *string    `json:"user_id" form:"user_id"`
+	OwnerUserID    *string    `json:"owner_user_id" form:"owner_user_id"` // Defaults to the creator
+	ExpiresAt      *time.Time `json:"expires_at" form:"expires_at" time_format:"2006-01-02"`
+	Scopes         []string   `json:"scopes" form:"scopes"`
+	ProjectID      *string    `json:"project_id" form:"project_id"`           // Must belong to the organization
+	IsCanary       bool       `json:"is_canary" form:"is_canary"`             // Decoy key; requires keys:write
+	CanaryBlockIP  bool       `json:"canary_block_ip" form:"canary_block_ip"` // Canary keys only
+}
+
+// Validate trims the description, drops an empty owner, project or expiry, and checks the expiry is in
+// the future and every scope is known
+func (r *CreateAPIKeyRequest) Validate() error {
+	if r.Description != nil {
+		d := strings.TrimSpace(*r.Description)
+		if d == "" {
+			r.Description = nil
+		} else {
+			r.Description = &d
+		}
+	}
+	if r.OwnerUserID != nil && *r.OwnerUserID == "" {
+		r.OwnerUserID = nil
+	}
+	if r.ProjectID != nil && *r.ProjectID == "" {
+		r.ProjectID = nil
+	}
+	if r.ExpiresAt != nil && r.ExpiresAt.IsZero() {
+		// An empty date field in the create form binds as the zero time
+		r.ExpiresAt = nil
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	for _, scope := range r.Scopes {
+		if !IsValidAPIKeyScope(scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if r.CanaryBlockIP && !r.IsCanary {
+		return fmt.Errorf("canary_block_ip applies to canary keys only")
+	}
+	return nil
+}
+
+// TransferAPIKeyRequest hands a key to another member of its organization, by user ID or email
+type TransferAPIKeyRequest struct {
+	OwnerUserID string `json:"owner_user_id"`
+	OwnerEmail  string `json:"owner_email"`
+}
+
+// Validate checks exactly one way of naming the new owner is given
+func (r *TransferAPIKeyRequest) Validate() error {
+	if (r.OwnerUserID == "") == (r.OwnerEmail == "") {
+		return fmt.Errorf("one of owner_user_id or owner_email is required")
+	}
+	return nil
 }
 
 type CreateAPIKeyResponse struct {
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPIKeyScopeForPath(t *testing.T) {
+	cases := map[string]string{
+		"/v1/chat/completions":     APIKeyScopeChat,
+		"/v1/sessions/support-42":  APIKeyScopeChat,
+		"/v1/completions":          APIKeyScopeCompletions,
+		"/v1/embeddings":           APIKeyScopeEmbeddings,
+		"/v1/images/generations":   APIKeyScopeImages,
+		"/v1/audio/transcriptions": APIKeyScopeAudio,
+		"/v1/feedback":             APIKeyScopeFeedback,
+		"/v1/batches/abc/cancel":   APIKeyScopeBatches,
+		"/v1/files":                APIKeyScopeBatches,
+		"/v1/vector/collections/a": APIKeyScopeVectors,
+		"/v1/debug/req-1":          APIKeyScopeDebug,
+		"/acme/summarize":          APIKeyScopeEndpoints,
+	}
+	for path, want := range cases {
+		if got := APIKeyScopeForPath(path); got != want {
+			t.Errorf("APIKeyScopeForPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAPIKeyAllows(t *testing.T) {
+	if !APIKeyAllows(nil, APIKeyScopeChat) {
+		t.Error("a key without scopes should allow every endpoint")
+	}
+	scopes := []string{APIKeyScopeEmbeddings}
+	if !APIKeyAllows(scopes, APIKeyScopeEmbeddings) {
+		t.Error("expected embeddings to be allowed")
+	}
+	if APIKeyAllows(scopes, APIKeyScopeChat) {
+		t.Error("expected chat to be refused")
+	}
+	if APIKeyAllows(nil, APIKeyScopeDebug) {
+		t.Error("a key without scopes should not allow debug traces")
+	}
+	if !APIKeyAllows([]string{APIKeyScopeChat, APIKeyScopeDebug}, APIKeyScopeDebug) {
+		t.Error("expected debug to be allowed when granted")
+	}
+}
+
+func TestCreateAPIKeyRequestValidate(t *testing.T) {
+	blank, zero := "  ", time.Time{}
+	req := CreateAPIKeyRequest{Name: "ci", Description: &blank, ExpiresAt: &zero}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Description != nil || req.ExpiresAt != nil {
+		t.Error("expected empty description and expiry to be dropped")
+	}
+
+	past := time.Now().Add(-time.Hour)
+	req = CreateAPIKeyRequest{Name: "ci", ExpiresAt: &past}
+	if err := req.Validate(); err == nil {
+		t.Error("expected a past expiry to be rejected")
+	}
+
+	req = CreateAPIKeyRequest{Name: "ci", Scopes: []string{"chat", "admin"}}
+	if err := req.Validate(); err == nil {
+		t.Error("expected an unknown scope to be rejected")
+	}
+
+	req = CreateAPIKeyRequest{Name: "ci", CanaryBlockIP: true}
+	if err := req.Validate(); err == nil {
+		t.Error("expected IP blocking on a regular key to be rejected")
+	}
+}
+
+func TestAPIKeySparkline(t *testing.T) {
+	key := APIKey{DailyRequests: []int{0, 2, 4}}
+	if got := key.RecentRequests(); got != 6 {
+		t.Errorf("RecentRequests() = %d, want 6", got)
+	}
+	if got, want := key.SparklinePoints(), "0.0,15.0 30.0,8.0 60.0,1.0"; got != want {
+		t.Errorf("SparklinePoints() = %q, want %q", got, want)
+	}
+	if got := (&APIKey{}).SparklinePoints(); got != "" {
+		t.Errorf("SparklinePoints() without usage = %q, want empty", got)
+	}
+}
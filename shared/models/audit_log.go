@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit log actions
+const (
+	AuditActionSecretReveal         = "secret.reveal"
+	AuditActionDKIMKeyCreate        = "dkim_key.create"
+	AuditActionDKIMKeyDelete        = "dkim_key.delete"
+	AuditActionUserDeactivate       = "user.deactivate"
+	AuditActionUserReactivate       = "user.reactivate"
+	AuditActionUserRoleChange       = "user.role_change"
+	AuditActionUserOrgRemove        = "user.org_remove"
+	AuditActionUserDelete           = "user.delete"
+	AuditActionUserOrgAdd           = "user.org_add"
+	AuditActionUserOrgSource        = "user.org_source"
+	AuditActionRoleCreate           = "role.create"
+	AuditActionRoleUpdate           = "role.update"
+	AuditActionRoleDelete           = "role.delete"
+	AuditActionADGroupMappingCreate = "ad_group_mapping.create"
+	AuditActionADGroupMappingUpdate = "ad_group_mapping.update"
+	AuditActionADGroupMappingDelete = "ad_group_mapping.delete"
+	AuditActionAPIKeyTransfer       = "api_key.transfer"
+	AuditActionRequestReplay        = "request.replay"
+	AuditActionQuotaAllocate        = "quota.allocate"
+	AuditActionSetupComplete        = "setup.complete"
+	AuditActionReadOnlyChange       = "system.read_only"
+	AuditActionSchemaFix            = "system.schema_fix"
+	AuditActionAPIKeyLeakRevoke     = "api_key.leak_revoke"
+	AuditActionIPUnblock            = "system.ip_unblock"
+	AuditActionCostVisibility       = "organization.cost_visibility"
+	AuditActionEmailResend          = "email.resend"
+	AuditActionEmailLogsPurge       = "email_logs.purge"
+	AuditActionBreakGlassLogin      = "break_glass.login"
+	AuditActionBreakGlassRequest    = "break_glass.request"
+	AuditActionBreakGlassPolicy     = "system.break_glass"
+	AuditActionQoSClass             = "organization.qos_class"
+	AuditActionCurrency             = "organization.currency"
+	AuditActionFXRate               = "system.fx_rate"
+	AuditActionWasmPolicyCreate     = "wasm_policy.create"
+	AuditActionWasmPolicyUpdate     = "wasm_policy.update"
+	AuditActionWasmPolicyDelete     = "wasm_policy.delete"
+	AuditActionSLOCreate            = "slo.create"
+	AuditActionSLOUpdate            = "slo.update"
+	AuditActionSLODelete            = "slo.delete"
+	AuditActionMaxRequestCost       = "organization.max_request_cost"
+	AuditActionStreamPacingUpdate   = "stream_pacing.update"
+	AuditActionStreamPacingDelete   = "stream_pacing.delete"
+)
+
+// AuditLog records a sensitive administrative action
+type AuditLog struct {
+	ID           string          `json:"id" db:"id"`
+	UserID       *string         `json:"user_id" db:"user_id"`
+	Action       string          `json:"action" db:"action"`
+	ResourceType string          `json:"resource_type" db:"resource_type"`
+	ResourceID   *string         `json:"resource_id" db:"resource_id"`
+	Details      json.RawMessage `json:"details" db:"details"`
+	IPAddress    *string         `json:"ip_address" db:"ip_address"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+}
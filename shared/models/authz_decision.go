@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultAuthzDecisionLimit and MaxAuthzDecisionLimit bound a page of the decision log
+	DefaultAuthzDecisionLimit = 100
+	MaxAuthzDecisionLimit     = 1000
+)
+
+// AuthzDecision is an authorization decision the gateway asked the policy engine for, kept
+// for compliance
+type AuthzDecision struct {
+	ID             string    `json:"id" db:"id"`
+	RequestID      string    `json:"request_id" db:"request_id"`
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	APIKeyID       string    `json:"api_key_id" db:"api_key_id"`
+	Model          string    `json:"model" db:"model"`
+	Method         string    `json:"method" db:"method"`
+	Path           string    `json:"path" db:"path"`
+	PayloadBytes   int       `json:"payload_bytes" db:"payload_bytes"`
+	Allowed        bool      `json:"allowed" db:"allowed"`
+	Reason         string    `json:"reason,omitempty" db:"reason"`
+	Status         int       `json:"status,omitempty" db:"status"` // Status the request was refused with
+	Policy         string    `json:"policy" db:"policy"`           // Decision path asked
+	OPADecisionID  string    `json:"opa_decision_id,omitempty" db:"opa_decision_id"`
+	Error          string    `json:"error,omitempty" db:"error"` // Why the policy engine couldn't decide
+	DurationMS     int       `json:"duration_ms" db:"duration_ms"`
+	ClientIP       string    `json:"client_ip,omitempty" db:"client_ip"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuthzDecisionFilter narrows the decision log
+type AuthzDecisionFilter struct {
+	OrganizationID string `form:"organization_id"`
+	APIKeyID       string `form:"api_key_id"`
+	Allowed        *bool  `form:"allowed"`
+	Since          string `form:"since"` // RFC 3339
+	Until          string `form:"until"`
+	Limit          int    `form:"limit"`
+
+	SinceTime, UntilTime time.Time `form:"-"`
+}
+
+// Validate checks the IDs and times and defaults the limit
+func (f *AuthzDecisionFilter) Validate() error {
+	for name, id := range map[string]string{"organization_id": f.OrganizationID, "api_key_id": f.APIKeyID} {
+		if id == "" {
+			continue
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("%s must be a UUID", name)
+		}
+	}
+	for _, t := range []struct {
+		name  string
+		value string
+		into  *time.Time
+	}{{"since", f.Since, &f.SinceTime}, {"until", f.Until, &f.UntilTime}} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return fmt.Errorf("%s must be an RFC 3339 time", t.name)
+		}
+		*t.into = parsed
+	}
+	switch {
+	case f.Limit <= 0:
+		f.Limit = DefaultAuthzDecisionLimit
+	case f.Limit > MaxAuthzDecisionLimit:
+		f.Limit = MaxAuthzDecisionLimit
+	}
+	return nil
+}
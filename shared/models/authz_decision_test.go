@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestAuthzDecisionFilterValidate(t *testing.T) {
+	f := AuthzDecisionFilter{OrganizationID: "00000000-0000-0000-0000-000000000001", Since: "2026-10-01T00:00:00Z"}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Limit != DefaultAuthzDecisionLimit {
+		t.Errorf("limit = %d, want the default", f.Limit)
+	}
+	if f.SinceTime.IsZero() || !f.UntilTime.IsZero() {
+		t.Errorf("since = %v, until = %v", f.SinceTime, f.UntilTime)
+	}
+
+	f = AuthzDecisionFilter{Limit: 1 << 20}
+	if err := f.Validate(); err != nil || f.Limit != MaxAuthzDecisionLimit {
+		t.Errorf("limit = %d, %v; want it capped", f.Limit, err)
+	}
+	if err := (&AuthzDecisionFilter{APIKeyID: "key"}).Validate(); err == nil {
+		t.Error("expected a malformed key ID to be rejected")
+	}
+	if err := (&AuthzDecisionFilter{Until: "yesterday"}).Validate(); err == nil {
+		t.Error("expected a malformed time to be rejected")
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// AzureSessionRefreshWindow is how long before its access token expires an Azure session is
+// refreshed, so requests never run on an expired one
+const AzureSessionRefreshWindow = 5 * time.Minute
+
+// AzureSession is the server side of an Azure AD sign-in. It keeps the user's refresh token
+// so the session can be extended, and their groups re-synced, without sending them back to
+// Azure AD.
+type AzureSession struct {
+	ID           string     `json:"id" db:"id"`
+	AzureOID     string     `json:"azure_oid" db:"azure_oid"`
+	Email        string     `json:"email" db:"email"`
+	RefreshToken string     `json:"-" db:"refresh_token"`       // Sealed with ENCRYPTION_KEY
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"` // When the current access token expires
+	RefreshedAt  *time.Time `json:"refreshed_at,omitempty" db:"refreshed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+// NeedsRefresh reports whether the session's access token expires within the refresh window
+func (s *AzureSession) NeedsRefresh(now time.Time) bool {
+	return !now.Before(s.ExpiresAt.Add(-AzureSessionRefreshWindow))
+}
+
+// Expired reports whether the session's access token has expired
+func (s *AzureSession) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// PastMaxAge reports whether the session began longer than maxAge ago; such sessions aren't
+// extended, so users sign in again at least that often
+func (s *AzureSession) PastMaxAge(now time.Time, maxAge time.Duration) bool {
+	return now.Sub(s.CreatedAt) >= maxAge
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAzureSessionRefresh(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		expiresIn    time.Duration
+		needsRefresh bool
+		expired      bool
+	}{
+		{"fresh", time.Hour, false, false},
+		{"just outside the window", AzureSessionRefreshWindow + time.Second, false, false},
+		{"inside the window", time.Minute, true, false},
+		{"expired", -time.Minute, true, true},
+	}
+	for _, tt := range tests {
+		s := AzureSession{ExpiresAt: now.Add(tt.expiresIn)}
+		if got := s.NeedsRefresh(now); got != tt.needsRefresh {
+			t.Errorf("%s: NeedsRefresh() = %v, want %v", tt.name, got, tt.needsRefresh)
+		}
+		if got := s.Expired(now); got != tt.expired {
+			t.Errorf("%s: Expired() = %v, want %v", tt.name, got, tt.expired)
+		}
+	}
+}
+
+func TestAzureSessionPastMaxAge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := AzureSession{CreatedAt: now.Add(-11 * time.Hour)}
+	if s.PastMaxAge(now, 12*time.Hour) {
+		t.Error("PastMaxAge() = true for an 11 hour old session with a 12 hour maximum")
+	}
+	s.CreatedAt = now.Add(-12 * time.Hour)
+	if !s.PastMaxAge(now, 12*time.Hour) {
+		t.Error("PastMaxAge() = false for a session at its maximum age")
+	}
+}
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Batch statuses, as in the OpenAI Batch API
+const (
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+// Batch file purposes
+const (
+	BatchFilePurposeInput  = "batch"
+	BatchFilePurposeOutput = "batch_output"
+)
+
+// BatchCompletionWindow is the only completion window offered
+const BatchCompletionWindow = "24h"
+
+// MaxBatchRequests caps the number of requests in one batch input file
+const MaxBatchRequests = 50000
+
+// BatchEndpoints are the gateway endpoints a batch can target
+var BatchEndpoints = []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings"}
+
+// BatchFile is an uploaded batch input file or a generated output/error file
+type BatchFile struct {
+	ID             string    `json:"id" db:"id"`
+	OrganizationID string    `json:"-" db:"organization_id"`
+	APIKeyID       *string   `json:"-" db:"api_key_id"`
+	Purpose        string    `json:"purpose" db:"purpose"`
+	Filename       string    `json:"filename" db:"filename"`
+	Bytes          int       `json:"bytes" db:"bytes"`
+	Content        []byte    `json:"-" db:"content"`
+	CreatedAt      time.Time `json:"-" db:"created_at"`
+}
+
+// MarshalJSON renders the file as an OpenAI file object
+func (f BatchFile) MarshalJSON() ([]byte, error) {
+	type Alias BatchFile
+	return json.Marshal(&struct {
+		Object    string `json:"object"`
+		CreatedAt int64  `json:"created_at"`
+		*Alias
+	}{
+		Object:    "file",
+		CreatedAt: f.CreatedAt.Unix(),
+		Alias:     (*Alias)(&f),
+	})
+}
+
+// BatchError is a problem with one line of a batch input file, or with the batch as a whole
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Line    *int   `json:"line"`
+}
+
+// BatchRequestCounts tracks progress through a batch
+type BatchRequestCounts struct {
+	Total     int `json:"total" db:"total_requests"`
+	Completed int `json:"completed" db:"completed_requests"`
+	Failed    int `json:"failed" db:"failed_requests"`
+}
+
+// BatchUsage is the aggregate token usage of a batch's requests
+type BatchUsage struct {
+	InputTokens  int64 `json:"input_tokens" db:"prompt_tokens"`
+	OutputTokens int64 `json:"output_tokens" db:"completion_tokens"`
+	TotalTokens  int64 `json:"total_tokens" db:"total_tokens"`
+}
+
+// Batch is an asynchronous job running the requests of an input file against their models
+type Batch struct {
+	ID               string             `json:"id" db:"id"`
+	OrganizationID   string             `json:"-" db:"organization_id"`
+	APIKeyID         *string            `json:"-" db:"api_key_id"`
+	Endpoint         string             `json:"endpoint" db:"endpoint"`
+	InputFileID      string             `json:"input_file_id" db:"input_file_id"`
+	OutputFileID     *string            `json:"output_file_id" db:"output_file_id"`
+	ErrorFileID      *string            `json:"error_file_id" db:"error_file_id"`
+	CompletionWindow string             `json:"completion_window" db:"completion_window"`
+	Status           string             `json:"status" db:"status"`
+	Errors           []BatchError       `json:"-" db:"errors"`
+	RequestCounts    BatchRequestCounts `json:"request_counts"`
+	Usage            BatchUsage         `json:"usage"`
+	Metadata         map[string]string  `json:"metadata" db:"metadata"`
+	CreatedAt        time.Time          `json:"-" db:"created_at"`
+	InProgressAt     *time.Time         `json:"-" db:"in_progress_at"`
+	FinalizingAt     *time.Time         `json:"-" db:"finalizing_at"`
+	CompletedAt      *time.Time         `json:"-" db:"completed_at"`
+	FailedAt         *time.Time         `json:"-" db:"failed_at"`
+	CancellingAt     *time.Time         `json:"-" db:"cancelling_at"`
+	CancelledAt      *time.Time         `json:"-" db:"cancelled_at"`
+	ExpiresAt        time.Time          `json:"-" db:"expires_at"`
+}
+
+// IsFinished reports whether the batch has stopped processing for good
+func (b *Batch) IsFinished() bool {
+	switch b.Status {
+	case BatchStatusFailed, BatchStatusCompleted, BatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// MarshalJSON renders the batch as an OpenAI batch object, with Unix timestamps
+func (b Batch) MarshalJSON() ([]byte, error) {
+	type Alias Batch
+	unix := func(t *time.Time) *int64 {
+		if t == nil {
+			return nil
+		}
+		u := t.Unix()
+		return &u
+	}
+
+	var errors interface{}
+	if len(b.Errors) > 0 {
+		errors = map[string]interface{}{"object": "list", "data": b.Errors}
+	}
+
+	return json.Marshal(&struct {
+		Object       string      `json:"object"`
+		Errors       interface{} `json:"errors"`
+		CreatedAt    int64       `json:"created_at"`
+		InProgressAt *int64      `json:"in_progress_at"`
+		FinalizingAt *int64      `json:"finalizing_at"`
+		CompletedAt  *int64      `json:"completed_at"`
+		FailedAt     *int64      `json:"failed_at"`
+		CancellingAt *int64      `json:"cancelling_at"`
+		CancelledAt  *int64      `json:"cancelled_at"`
+		ExpiresAt    int64       `json:"expires_at"`
+		*Alias
+	}{
+		Object:       "batch",
+		Errors:       errors,
+		CreatedAt:    b.CreatedAt.Unix(),
+		InProgressAt: unix(b.InProgressAt),
+		FinalizingAt: unix(b.FinalizingAt),
+		CompletedAt:  unix(b.CompletedAt),
+		FailedAt:     unix(b.FailedAt),
+		CancellingAt: unix(b.CancellingAt),
+		CancelledAt:  unix(b.CancelledAt),
+		ExpiresAt:    b.ExpiresAt.Unix(),
+		Alias:        (*Alias)(&b),
+	})
+}
+
+type CreateBatchRequest struct {
+	InputFileID      string            `json:"input_file_id" binding:"required"`
+	Endpoint         string            `json:"endpoint" binding:"required"`
+	CompletionWindow string            `json:"completion_window" binding:"required"`
+	Metadata         map[string]string `json:"metadata"`
+}
+
+// Validate checks the endpoint and completion window are supported
+func (r *CreateBatchRequest) Validate() error {
+	if !isBatchEndpoint(r.Endpoint) {
+		return fmt.Errorf("endpoint must be one of %v", BatchEndpoints)
+	}
+	if r.CompletionWindow != BatchCompletionWindow {
+		return fmt.Errorf("completion_window must be %s", BatchCompletionWindow)
+	}
+	if len(r.Metadata) > 16 {
+		return fmt.Errorf("metadata can have at most 16 keys")
+	}
+	return nil
+}
+
+func isBatchEndpoint(endpoint string) bool {
+	for _, e := range BatchEndpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchRequestLine is one request in a batch input file
+type BatchRequestLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+	Model    string          `json:"-"` // From the body
+}
+
+// BatchResponse is the provider's answer to one batch request
+type BatchResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// BatchOutputLine is one line of a batch output or error file
+type BatchOutputLine struct {
+	ID       string         `json:"id"`
+	CustomID string         `json:"custom_id"`
+	Response *BatchResponse `json:"response"`
+	Error    *BatchError    `json:"error"`
+}
+
+// ParseBatchInput reads a JSONL batch input file. Every line must be a POST to endpoint with a
+// unique custom_id and a body naming a model; blank lines are skipped. All problems are returned
+// together so the whole file can be fixed at once.
+func ParseBatchInput(content []byte, endpoint string) ([]BatchRequestLine, []BatchError) {
+	var lines []BatchRequestLine
+	var errs []BatchError
+	seen := make(map[string]bool)
+
+	fail := func(lineNo int, format string, args ...interface{}) {
+		n := lineNo
+		errs = append(errs, BatchError{Code: "invalid_request", Message: fmt.Sprintf(format, args...), Line: &n})
+	}
+
+	for i, raw := range bytes.Split(content, []byte("\n")) {
+		lineNo := i + 1
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 {
+			continue
+		}
+
+		var line BatchRequestLine
+		if err := json.Unmarshal(raw, &line); err != nil {
+			fail(lineNo, "line is not valid JSON")
+			continue
+		}
+		if line.CustomID == "" {
+			fail(lineNo, "custom_id is required")
+		} else if seen[line.CustomID] {
+			fail(lineNo, "custom_id %q is used more than once", line.CustomID)
+		}
+		seen[line.CustomID] = true
+		if line.Method != "POST" {
+			fail(lineNo, "method must be POST")
+		}
+		if line.URL != endpoint {
+			fail(lineNo, "url must match the batch endpoint %s", endpoint)
+		}
+
+		var body struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		if err := json.Unmarshal(line.Body, &body); err != nil {
+			fail(lineNo, "body must be a JSON object")
+			continue
+		}
+		if body.Model == "" {
+			fail(lineNo, "body.model is required")
+		}
+		if body.Stream {
+			fail(lineNo, "streaming is not supported in batches")
+		}
+		line.Model = body.Model
+		lines = append(lines, line)
+	}
+
+	if len(errs) == 0 && len(lines) == 0 {
+		errs = append(errs, BatchError{Code: "empty_file", Message: "the input file has no requests"})
+	}
+	if len(lines) > MaxBatchRequests {
+		errs = append(errs, BatchError{Code: "too_many_requests", Message: fmt.Sprintf("a batch can have at most %d requests", MaxBatchRequests)})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return lines, nil
+}
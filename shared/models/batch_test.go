@@ -0,0 +1,60 @@
+package models
+
+import "testing"
+
+func TestParseBatchInput(t *testing.T) {
+	content := []byte(`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","messages":[]}}
+
+{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o-mini","messages":[]}}
+`)
+	lines, errs := ParseBatchInput(content, "/v1/chat/completions")
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+	if len(lines) != 2 || lines[0].Model != "gpt-4o" || lines[1].CustomID != "b" {
+		t.Errorf("unexpected lines: %+v", lines)
+	}
+}
+
+func TestParseBatchInputErrors(t *testing.T) {
+	content := []byte(`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o"}}
+{"custom_id":"a","method":"GET","url":"/v1/embeddings","body":{"stream":true}}
+not json`)
+	lines, errs := ParseBatchInput(content, "/v1/chat/completions")
+	if lines != nil {
+		t.Error("expected no lines when the file has errors")
+	}
+
+	byLine := map[int]int{}
+	for _, e := range errs {
+		if e.Line == nil {
+			t.Fatalf("expected every error to name a line: %+v", e)
+		}
+		byLine[*e.Line]++
+	}
+	// Line 2: duplicate custom_id, method, url, missing model, stream. Line 3: invalid JSON.
+	if byLine[1] != 0 || byLine[2] != 5 || byLine[3] != 1 {
+		t.Errorf("unexpected errors per line: %v", byLine)
+	}
+}
+
+func TestParseBatchInputEmpty(t *testing.T) {
+	if _, errs := ParseBatchInput([]byte("\n\n"), "/v1/embeddings"); len(errs) != 1 || errs[0].Code != "empty_file" {
+		t.Errorf("expected an empty_file error, got %+v", errs)
+	}
+}
+
+func TestCreateBatchRequestValidate(t *testing.T) {
+	req := CreateBatchRequest{InputFileID: "f", Endpoint: "/v1/embeddings", CompletionWindow: "24h"}
+	if err := req.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	req.Endpoint = "/v1/images/generations"
+	if err := req.Validate(); err == nil {
+		t.Error("expected an unsupported endpoint to be rejected")
+	}
+	req = CreateBatchRequest{InputFileID: "f", Endpoint: "/v1/embeddings", CompletionWindow: "1h"}
+	if err := req.Validate(); err == nil {
+		t.Error("expected an unsupported completion window to be rejected")
+	}
+}
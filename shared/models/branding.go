@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// OrganizationBranding overrides the global theme for a single organization. Empty fields
+// fall back to the active theme from config.yml.
+type OrganizationBranding struct {
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	LogoURL        string    `json:"logo_url" db:"logo_url"`
+	PrimaryColor   string    `json:"primary_color" db:"primary_color"`
+	SecondaryColor string    `json:"secondary_color" db:"secondary_color"`
+	AccentColor    string    `json:"accent_color" db:"accent_color"`
+	FromName       string    `json:"from_name" db:"from_name"` // Sender name for the organization's emails
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateOrganizationBrandingRequest struct {
+	OrganizationID string `json:"organization_id" binding:"required"`
+	LogoURL        string `json:"logo_url"`
+	PrimaryColor   string `json:"primary_color"`
+	SecondaryColor string `json:"secondary_color"`
+	AccentColor    string `json:"accent_color"`
+	FromName       string `json:"from_name"`
+}
+
+// Validate checks colors are #rrggbb and the logo is an http(s) URL
+func (r *UpdateOrganizationBrandingRequest) Validate() error {
+	for name, color := range map[string]string{
+		"primary_color":   r.PrimaryColor,
+		"secondary_color": r.SecondaryColor,
+		"accent_color":    r.AccentColor,
+	} {
+		if color != "" && !hexColorPattern.MatchString(color) {
+			return fmt.Errorf("%s must be a hex color like #1a2b3c", name)
+		}
+	}
+
+	if r.LogoURL != "" {
+		u, err := url.Parse(r.LogoURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("logo_url must be an http or https URL")
+		}
+	}
+
+	if len(r.FromName) > 255 {
+		return fmt.Errorf("from_name must be at most 255 characters")
+	}
+
+	return nil
+}
+
+// ApplyTo returns a copy of theme with the organization's overrides applied
+func (b *OrganizationBranding) ApplyTo(theme Theme) Theme {
+	if b == nil {
+		return theme
+	}
+
+	if b.PrimaryColor != "" {
+		theme.Primary = ColorShades(b.PrimaryColor)
+	}
+	if b.SecondaryColor != "" {
+		theme.Secondary = ColorShades(b.SecondaryColor)
+	}
+	if b.AccentColor != "" {
+		theme.Accent.Info = b.AccentColor
+	}
+	if b.LogoURL != "" {
+		theme.Branding.Logo = b.LogoURL
+	}
+
+	return theme
+}
+
+// ColorShades builds a 50-900 palette around a base color, which becomes the 600 shade
+// (the one buttons and links use). Lighter shades are mixed with white, darker with black.
+func ColorShades(base string) map[string]string {
+	r, g, b, ok := parseHexColor(base)
+	if !ok {
+		return nil
+	}
+
+	mix := map[string]float64{
+		"50": 0.95, "100": 0.9, "200": 0.75, "300": 0.6, "400": 0.4, "500": 0.2,
+		"600": 0, "700": -0.2, "800": -0.4, "900": -0.55,
+	}
+
+	shades := make(map[string]string, len(mix))
+	for shade, amount := range mix {
+		shades[shade] = mixColor(r, g, b, amount)
+	}
+	return shades
+}
+
+func parseHexColor(color string) (r, g, b int64, ok bool) {
+	if !hexColorPattern.MatchString(color) {
+		return 0, 0, 0, false
+	}
+	r, _ = strconv.ParseInt(color[1:3], 16, 0)
+	g, _ = strconv.ParseInt(color[3:5], 16, 0)
+	b, _ = strconv.ParseInt(color[5:7], 16, 0)
+	return r, g, b, true
+}
+
+// mixColor tints towards white for positive amounts and shades towards black for negative ones
+func mixColor(r, g, b int64, amount float64) string {
+	channel := func(c int64) int64 {
+		if amount >= 0 {
+			return c + int64(float64(255-c)*amount)
+		}
+		return c + int64(float64(c)*amount)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", channel(r), channel(g), channel(b))
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+)
+
+// The break-glass account's user. It is created at its first sign-in and made a System Admin.
+const (
+	BreakGlassEmail    = "break-glass@localhost"
+	BreakGlassAzureOID = "break-glass"
+	BreakGlassName     = "Break-glass admin"
+)
+
+// BreakGlassSessionLifetime is how long a break-glass sign-in lasts; it isn't extended
+const BreakGlassSessionLifetime = time.Hour
+
+// BreakGlassAccount is the emergency System Admin account used when Azure AD is down. Its
+// credentials come from the environment; whether it may be used is a policy System Admins set.
+// It has at most one session, whose token is kept hashed.
+type BreakGlassAccount struct {
+	Enabled          bool       `json:"enabled" db:"enabled"`
+	Configured       bool       `json:"configured" db:"-"` // Whether the environment provisions credentials
+	SessionTokenHash string     `json:"-" db:"session_token_hash"`
+	SessionExpiresAt *time.Time `json:"session_expires_at,omitempty" db:"session_expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	UpdatedBy        *string    `json:"updated_by,omitempty" db:"updated_by"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// HashBreakGlassToken returns the stored form of a session token
+func HashBreakGlassToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SessionValid reports whether token is the account's current session and it hasn't expired
+func (a *BreakGlassAccount) SessionValid(token string, now time.Time) bool {
+	if token == "" || a.SessionTokenHash == "" || a.SessionExpiresAt == nil || !now.Before(*a.SessionExpiresAt) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashBreakGlassToken(token)), []byte(a.SessionTokenHash)) == 1
+}
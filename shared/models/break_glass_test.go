@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakGlassSessionValid(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	expires := now.Add(time.Minute)
+	account := BreakGlassAccount{SessionTokenHash: HashBreakGlassToken("token"), SessionExpiresAt: &expires}
+
+	if !account.SessionValid("token", now) {
+		t.Error("SessionValid() = false for the current token")
+	}
+	if account.SessionValid("other", now) {
+		t.Error("SessionValid() = true for another token")
+	}
+	if account.SessionValid("", now) {
+		t.Error("SessionValid() = true for no token")
+	}
+	if account.SessionValid("token", expires) {
+		t.Error("SessionValid() = true once expired")
+	}
+
+	ended := BreakGlassAccount{}
+	if ended.SessionValid("token", now) {
+		t.Error("SessionValid() = true without a session")
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// CanaryHit is a request made with a canary key
+type CanaryHit struct {
+	ID             string            `json:"id" db:"id"`
+	APIKeyID       string            `json:"api_key_id" db:"api_key_id"`
+	OrganizationID string            `json:"organization_id" db:"organization_id"`
+	IPAddress      string            `json:"ip_address" db:"ip_address"`
+	Method         string            `json:"method" db:"method"`
+	Path           string            `json:"path" db:"path"`
+	Headers        map[string]string `json:"headers" db:"headers"` // Credentials masked
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+}
+
+// BlockedIP is a client address the gateway refuses
+type BlockedIP struct {
+	IPAddress string     `json:"ip_address" db:"ip_address"`
+	Reason    string     `json:"reason" db:"reason"`
+	APIKeyID  *string    `json:"api_key_id,omitempty" db:"api_key_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
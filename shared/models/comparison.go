@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// PeriodMetrics totals usage over one time window
+type PeriodMetrics struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Requests     int64     `json:"requests"`
+	Tokens       int64     `json:"tokens"`
+	Cost         float64   `json:"cost"`
+	AvgLatencyMs float64   `json:"avg_latency_ms"`
+	ErrorRate    float64   `json:"error_rate"` // Percentage of requests answered with a 4xx or 5xx
+}
+
+// MetricChange compares one figure across two periods. PercentChange is nil when the previous
+// value is zero, as no percentage can describe growth from nothing.
+type MetricChange struct {
+	Current       float64  `json:"current"`
+	Previous      float64  `json:"previous"`
+	Delta         float64  `json:"delta"`
+	PercentChange *float64 `json:"percent_change"`
+}
+
+// PeriodComparison compares a window's usage with the window of the same length before it,
+// e.g. this week against last week
+type PeriodComparison struct {
+	Current      PeriodMetrics `json:"current"`
+	Previous     PeriodMetrics `json:"previous"`
+	Requests     MetricChange  `json:"requests"`
+	Tokens       MetricChange  `json:"tokens"`
+	Cost         MetricChange  `json:"cost"`
+	AvgLatencyMs MetricChange  `json:"avg_latency_ms"`
+	ErrorRate    MetricChange  `json:"error_rate"` // Change in percentage points; percent_change is relative
+	TimeRange    string        `json:"time_range"`
+	Organization string        `json:"organization"`
+	CostsHidden  bool          `json:"costs_hidden"`
+	Currency     string        `json:"currency"`
+	FXRate       float64       `json:"fx_rate"`
+	GeneratedAt  time.Time     `json:"generated_at"`
+}
+
+// NewMetricChange works out the delta and percentage change from previous to current
+func NewMetricChange(current, previous float64) MetricChange {
+	change := MetricChange{Current: current, Previous: previous, Delta: current - previous}
+	if previous != 0 {
+		percent := (current - previous) / previous * 100
+		change.PercentChange = &percent
+	}
+	return change
+}
+
+// ComparePeriods builds the comparison of current against previous
+func ComparePeriods(current, previous PeriodMetrics) PeriodComparison {
+	return PeriodComparison{
+		Current:      current,
+		Previous:     previous,
+		Requests:     NewMetricChange(float64(current.Requests), float64(previous.Requests)),
+		Tokens:       NewMetricChange(float64(current.Tokens), float64(previous.Tokens)),
+		Cost:         NewMetricChange(current.Cost, previous.Cost),
+		AvgLatencyMs: NewMetricChange(current.AvgLatencyMs, previous.AvgLatencyMs),
+		ErrorRate:    NewMetricChange(current.ErrorRate, previous.ErrorRate),
+	}
+}
+
+// PreviousWindow is the window of the same length that ends where [start, end) begins
+func PreviousWindow(start, end time.Time) (time.Time, time.Time) {
+	return start.Add(-end.Sub(start)), start
+}
+
+// HideCosts zeroes the cost figures, leaving request, token, latency and error comparisons
+func (p *PeriodComparison) HideCosts() {
+	p.CostsHidden = true
+	p.Current.Cost = 0
+	p.Previous.Cost = 0
+	p.Cost = MetricChange{}
+}
+
+// ConvertCosts converts the cost figures from US dollars at the rate. The percentage change
+// is the same in any currency.
+func (p *PeriodComparison) ConvertCosts(rate FXRate) {
+	p.Currency, p.FXRate = rate.Currency, rate.RatePerUSD
+	r := rate.RatePerUSD
+	p.Current.Cost *= r
+	p.Previous.Cost *= r
+	p.Cost.Current *= r
+	p.Cost.Previous *= r
+	p.Cost.Delta *= r
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewMetricChange(t *testing.T) {
+	change := NewMetricChange(123, 100)
+	if change.Delta != 23 || change.PercentChange == nil || *change.PercentChange != 23 {
+		t.Errorf("NewMetricChange(123, 100) = %+v", change)
+	}
+
+	change = NewMetricChange(50, 100)
+	if change.Delta != -50 || *change.PercentChange != -50 {
+		t.Errorf("NewMetricChange(50, 100) = %+v", change)
+	}
+
+	if change := NewMetricChange(10, 0); change.PercentChange != nil || change.Delta != 10 {
+		t.Errorf("growth from zero should have no percentage: %+v", change)
+	}
+}
+
+func TestComparePeriods(t *testing.T) {
+	comparison := ComparePeriods(
+		PeriodMetrics{Requests: 200, Tokens: 3000, Cost: 4, AvgLatencyMs: 150, ErrorRate: 2},
+		PeriodMetrics{Requests: 100, Tokens: 3000, Cost: 2, AvgLatencyMs: 300, ErrorRate: 4},
+	)
+	if *comparison.Requests.PercentChange != 100 || *comparison.Tokens.PercentChange != 0 ||
+		*comparison.Cost.PercentChange != 100 || *comparison.AvgLatencyMs.PercentChange != -50 ||
+		comparison.ErrorRate.Delta != -2 {
+		t.Errorf("unexpected comparison: %+v", comparison)
+	}
+
+	comparison.ConvertCosts(FXRate{Currency: "EUR", RatePerUSD: 0.5})
+	if comparison.Current.Cost != 2 || comparison.Cost.Delta != 1 || *comparison.Cost.PercentChange != 100 {
+		t.Errorf("costs not converted: %+v", comparison.Cost)
+	}
+
+	comparison.HideCosts()
+	if !comparison.CostsHidden || comparison.Cost.PercentChange != nil || comparison.Previous.Cost != 0 {
+		t.Errorf("costs not hidden: %+v", comparison)
+	}
+}
+
+func TestPreviousWindow(t *testing.T) {
+	start := time.Date(2026, 6, 8, 0, 0, 0, 0, time.UTC)
+	prevStart, prevEnd := PreviousWindow(start, start.AddDate(0, 0, 7))
+	if !prevStart.Equal(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)) || !prevEnd.Equal(start) {
+		t.Errorf("PreviousWindow = %v to %v", prevStart, prevEnd)
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"time"
+)
+
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// ConversationSession is the chat history the gateway keeps for a client-chosen session ID, so
+// the client only sends each new message. Messages are stored sealed with the organization's key.
+type ConversationSession struct {
+	ID             string            `json:"-"`
+	OrganizationID string            `json:"-"`
+	SessionID      string            `json:"id"`
+	APIKeyID       *string           `json:"-"` // Key that last used the session
+	Messages       []json.RawMessage `json:"messages"`
+	SizeBytes      int               `json:"size_bytes"`
+	ExpiresAt      time.Time         `json:"expires_at"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// IsValidSessionID reports whether id can name a session: 1-128 letters, digits, '.', '_', ':'
+// or '-'
+func IsValidSessionID(id string) bool {
+	return sessionIDPattern.MatchString(id)
+}
+
+// messageRole returns a chat message's role, or "" if it isn't a message
+func messageRole(raw json.RawMessage) string {
+	var message struct {
+		Role string `json:"role"`
+	}
+	if json.Unmarshal(raw, &message) != nil {
+		return ""
+	}
+	return message.Role
+}
+
+func isInstructionRole(role string) bool {
+	return role == "system" || role == "developer"
+}
+
+// SplitInstructions returns a conversation's leading system and developer messages and the
+// messages after them
+func SplitInstructions(messages []json.RawMessage) (instructions, rest []json.RawMessage) {
+	i := 0
+	for i < len(messages) && isInstructionRole(messageRole(messages[i])) {
+		i++
+	}
+	return messages[:i], messages[i:]
+}
+
+// SessionPrompt builds the conversation sent for a request in a session: the request's own
+// system messages, the session's history, then the request's new messages
+func SessionPrompt(history, request []json.RawMessage) []json.RawMessage {
+	instructions, rest := SplitInstructions(request)
+	prompt := make([]json.RawMessage, 0, len(instructions)+len(history)+len(rest))
+	prompt = append(prompt, instructions...)
+	prompt = append(prompt, history...)
+	return append(prompt, rest...)
+}
+
+// TrimSessionHistory drops the oldest messages until at most maxMessages remain and they total at
+// most maxBytes. The kept history always starts at a user message, so a tool result or reply is
+// never left without what it answered.
+func TrimSessionHistory(messages []json.RawMessage, maxMessages, maxBytes int) []json.RawMessage {
+	size := 0
+	for _, message := range messages {
+		size += len(message)
+	}
+
+	start := 0
+	for start < len(messages) && (len(messages)-start > maxMessages || size > maxBytes) {
+		size -= len(messages[start])
+		start++
+	}
+	for start < len(messages) && messageRole(messages[start]) != "user" {
+		start++
+	}
+	return messages[start:]
+}
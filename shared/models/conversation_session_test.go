@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func rawMessages(roles ...string) []json.RawMessage {
+	messages := make([]json.RawMessage, len(roles))
+	for i, role := range roles {
+		messages[i] = json.RawMessage(`{"role":"` + role + `","content":"m"}`)
+	}
+	return messages
+}
+
+func roles(messages []json.RawMessage) []string {
+	out := make([]string, len(messages))
+	for i, message := range messages {
+		out[i] = messageRole(message)
+	}
+	return out
+}
+
+func TestSessionPrompt(t *testing.T) {
+	history := rawMessages("user", "assistant")
+	request := rawMessages("system", "user")
+
+	got := roles(SessionPrompt(history, request))
+	want := []string{"system", "user", "assistant", "user"}
+	if len(got) != len(want) {
+		t.Fatalf("SessionPrompt() roles = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("SessionPrompt() roles = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTrimSessionHistory(t *testing.T) {
+	messages := rawMessages("user", "assistant", "tool", "assistant", "user", "assistant")
+
+	if got := TrimSessionHistory(messages, 10, 1<<20); len(got) != len(messages) {
+		t.Errorf("history within limits was trimmed to %v", roles(got))
+	}
+	// Dropping two messages would start the history at a tool result, so it starts at the next user
+	if got := roles(TrimSessionHistory(messages, 4, 1<<20)); len(got) != 2 || got[0] != "user" {
+		t.Errorf("TrimSessionHistory(max 4) = %v, want [user assistant]", got)
+	}
+	if got := TrimSessionHistory(messages, 10, len(messages[0])*3); len(got) != 2 {
+		t.Errorf("TrimSessionHistory(byte limit) = %v, want the last 2 messages", roles(got))
+	}
+	if got := TrimSessionHistory(messages, 0, 1<<20); len(got) != 0 {
+		t.Errorf("TrimSessionHistory(max 0) = %v, want none", roles(got))
+	}
+}
+
+func TestIsValidSessionID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"support-chat:42": true,
+		"":                false,
+		"has space":       false,
+		"a/b":             false,
+	} {
+		if got := IsValidSessionID(id); got != want {
+			t.Errorf("IsValidSessionID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+// UpdateCostVisibilityRequest sets whether an organization's non-admin members see model
+// prices and spend or tokens only
+type UpdateCostVisibilityRequest struct {
+	HideCosts bool `json:"hide_costs"`
+}
+
+// HideCosts zeroes every cost figure, leaving request and token counts
+func (d *DashboardData) HideCosts() {
+	d.CostsHidden = true
+	d.Metrics.AvgCostPerRequest = 0
+	d.Metrics.TotalCost = 0
+	for i := range d.DailyCosts {
+		d.DailyCosts[i].Cost = 0
+	}
+	for i := range d.TopModels {
+		d.TopModels[i].TotalCost = 0
+	}
+	for i := range d.TopAPIKeys {
+		d.TopAPIKeys[i].TotalCost = 0
+	}
+	for i := range d.ProviderSpend {
+		d.ProviderSpend[i].TotalCost = 0
+		d.ProviderSpend[i].Percentage = 0
+	}
+	for i := range d.ProjectSpend {
+		d.ProjectSpend[i].TotalCost = 0
+	}
+}
+
+// HideCosts zeroes every cost figure, leaving request and token counts
+func (u *KeyUsage) HideCosts() {
+	u.CostsHidden = true
+	u.Cost = 0
+	for i := range u.Series {
+		u.Series[i].Cost = 0
+	}
+	for i := range u.TopModels {
+		u.TopModels[i].TotalCost = 0
+	}
+}
+
+// HideCosts zeroes the spend projection, leaving token burn and quota figures
+func (f *CostForecast) HideCosts() {
+	f.CostsHidden = true
+	f.MonthToDateCost = 0
+	f.ProjectedMonthCost = 0
+	f.ProjectedMonthCostLow = 0
+	f.ProjectedMonthCostHigh = 0
+	f.DailyBurnRate = 0
+	for _, points := range [][]ForecastPoint{f.History, f.Projection} {
+		for i := range points {
+			points[i].Cost, points[i].Low, points[i].High = 0, 0, 0
+		}
+	}
+}
+
+// HideCosts leaves out every model's prices
+func (r *ModelsResponse) HideCosts() {
+	r.CostsHidden = true
+	for i := range r.Models {
+		r.Models[i].InputCostPer1M = nil
+		r.Models[i].OutputCostPer1M = nil
+	}
+}
+
+// HideEndpointCosts zeroes the cost figures of an endpoint breakdown and its series
+func HideEndpointCosts(endpoints []EndpointSpendData, series []EndpointSeriesPoint) {
+	for i := range endpoints {
+		endpoints[i].TotalCost = 0
+		endpoints[i].Percentage = 0
+	}
+	for i := range series {
+		series[i].TotalCost = 0
+	}
+}
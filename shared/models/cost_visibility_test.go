@@ -0,0 +1,54 @@
+package models
+
+import "testing"
+
+func TestDashboardDataHideCosts(t *testing.T) {
+	d := DashboardData{
+		Metrics:    DashboardMetrics{TotalRequests: 10, TotalTokens: 500, TotalCost: 1.5, AvgCostPerRequest: 0.15},
+		DailyCosts: []DailyCostData{{Date: "2024-01-01", Cost: 1.5, RequestCount: 10}},
+		TopModels:  []TopModelData{{Name: "gpt", TotalCost: 1.5, RequestCount: 10}},
+		ProviderSpend: []ProviderSpendData{
+			{Provider: "openai", TotalCost: 1.5, RequestCount: 10, Percentage: 100},
+		},
+		ProjectSpend: []ProjectSpendData{{Name: "search", TotalTokens: 500, TotalCost: 1.5}},
+	}
+	d.HideCosts()
+
+	if !d.CostsHidden {
+		t.Error("CostsHidden = false")
+	}
+	if d.Metrics.TotalCost != 0 || d.Metrics.AvgCostPerRequest != 0 || d.DailyCosts[0].Cost != 0 ||
+		d.TopModels[0].TotalCost != 0 || d.ProviderSpend[0].TotalCost != 0 || d.ProviderSpend[0].Percentage != 0 ||
+		d.ProjectSpend[0].TotalCost != 0 {
+		t.Errorf("cost figures left after HideCosts: %+v", d)
+	}
+	if d.Metrics.TotalRequests != 10 || d.Metrics.TotalTokens != 500 || d.DailyCosts[0].RequestCount != 10 ||
+		d.ProjectSpend[0].TotalTokens != 500 {
+		t.Errorf("HideCosts dropped request or token counts: %+v", d)
+	}
+}
+
+func TestModelsResponseHideCosts(t *testing.T) {
+	price := 2.5
+	r := ModelsResponse{Models: []Model{{Name: "gpt", InputCostPer1M: &price, OutputCostPer1M: &price}}}
+	r.HideCosts()
+	if !r.CostsHidden || r.Models[0].InputCostPer1M != nil || r.Models[0].OutputCostPer1M != nil {
+		t.Errorf("prices left after HideCosts: %+v", r)
+	}
+}
+
+func TestWithoutPermission(t *testing.T) {
+	member := BuiltinRolePermissions[OrgRoleMember]
+	got := WithoutPermission(member, PermissionCostsRead)
+	for _, p := range got {
+		if p == PermissionCostsRead {
+			t.Fatalf("WithoutPermission() = %v, still has %s", got, PermissionCostsRead)
+		}
+	}
+	if len(got) != len(member)-1 {
+		t.Errorf("WithoutPermission() = %v, want one fewer than %v", got, member)
+	}
+	if member[len(member)-1] != PermissionCostsRead {
+		t.Errorf("WithoutPermission() changed the built-in role: %v", member)
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BaseCurrency is the currency every cost is stored and priced in. Reports convert to an
+// organization's display currency when they are built.
+const BaseCurrency = "USD"
+
+// FXRate is how many units of Currency one US dollar buys
+type FXRate struct {
+	Currency   string    `json:"currency" db:"currency"`
+	RatePerUSD float64   `json:"rate_per_usd" db:"rate_per_usd"`
+	Source     string    `json:"source" db:"source"` // Host the rate was fetched from, or "manual"
+	FetchedAt  time.Time `json:"fetched_at" db:"fetched_at"`
+}
+
+// BaseRate is the identity rate reports use when they stay in US dollars
+func BaseRate() FXRate {
+	return FXRate{Currency: BaseCurrency, RatePerUSD: 1}
+}
+
+// NormalizeCurrencyCode upper-cases a three-letter ISO 4217 code, or returns an error
+func NormalizeCurrencyCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", fmt.Errorf("currency must be a three-letter ISO 4217 code")
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", fmt.Errorf("currency must be a three-letter ISO 4217 code")
+		}
+	}
+	return code, nil
+}
+
+// UpdateCurrencyRequest sets the currency an organization's reports are shown in
+type UpdateCurrencyRequest struct {
+	Currency string `json:"currency" binding:"required"`
+}
+
+// SetFXRateRequest sets a currency's rate by hand, e.g. for a gateway without internet access
+type SetFXRateRequest struct {
+	RatePerUSD float64 `json:"rate_per_usd" binding:"required"`
+}
+
+// ConvertCosts converts every cost figure from US dollars at the rate, leaving percentages
+func (d *DashboardData) ConvertCosts(rate FXRate) {
+	d.Currency, d.FXRate = rate.Currency, rate.RatePerUSD
+	r := rate.RatePerUSD
+	d.Metrics.AvgCostPerRequest *= r
+	d.Metrics.TotalCost *= r
+	for i := range d.DailyCosts {
+		d.DailyCosts[i].Cost *= r
+	}
+	for i := range d.TopModels {
+		d.TopModels[i].TotalCost *= r
+	}
+	for i := range d.TopAPIKeys {
+		d.TopAPIKeys[i].TotalCost *= r
+	}
+	for i := range d.ProviderSpend {
+		d.ProviderSpend[i].TotalCost *= r
+	}
+	for i := range d.ProjectSpend {
+		d.ProjectSpend[i].TotalCost *= r
+	}
+}
+
+// ConvertCosts converts every cost figure from US dollars at the rate
+func (u *KeyUsage) ConvertCosts(rate FXRate) {
+	u.Currency, u.FXRate = rate.Currency, rate.RatePerUSD
+	r := rate.RatePerUSD
+	u.Cost *= r
+	for i := range u.Series {
+		u.Series[i].Cost *= r
+	}
+	for i := range u.TopModels {
+		u.TopModels[i].TotalCost *= r
+	}
+}
+
+// ConvertCosts converts the spend projection from US dollars at the rate
+func (f *CostForecast) ConvertCosts(rate FXRate) {
+	f.Currency, f.FXRate = rate.Currency, rate.RatePerUSD
+	r := rate.RatePerUSD
+	f.MonthToDateCost *= r
+	f.ProjectedMonthCost *= r
+	f.ProjectedMonthCostLow *= r
+	f.ProjectedMonthCostHigh *= r
+	f.DailyBurnRate *= r
+	for _, points := range [][]ForecastPoint{f.History, f.Projection} {
+		for i := range points {
+			points[i].Cost *= r
+			points[i].Low *= r
+			points[i].High *= r
+		}
+	}
+}
+
+// ConvertEndpointCosts converts an endpoint breakdown and its series from US dollars at the rate
+func ConvertEndpointCosts(endpoints []EndpointSpendData, series []EndpointSeriesPoint, rate FXRate) {
+	for i := range endpoints {
+		endpoints[i].TotalCost *= rate.RatePerUSD
+	}
+	for i := range series {
+		series[i].TotalCost *= rate.RatePerUSD
+	}
+}
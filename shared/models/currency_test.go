@@ -0,0 +1,52 @@
+package models
+
+import "testing"
+
+func TestNormalizeCurrencyCode(t *testing.T) {
+	if code, err := NormalizeCurrencyCode(" eur "); err != nil || code != "EUR" {
+		t.Errorf("NormalizeCurrencyCode(eur) = %q, %v", code, err)
+	}
+	for _, code := range []string{"", "EU", "EURO", "E1R"} {
+		if _, err := NormalizeCurrencyCode(code); err == nil {
+			t.Errorf("NormalizeCurrencyCode(%q) succeeded", code)
+		}
+	}
+}
+
+func TestDashboardDataConvertCosts(t *testing.T) {
+	d := DashboardData{
+		Metrics:       DashboardMetrics{TotalRequests: 10, TotalCost: 2, AvgCostPerRequest: 0.2},
+		DailyCosts:    []DailyCostData{{Date: "2026-01-01", Cost: 2}},
+		TopModels:     []TopModelData{{Name: "gpt", TotalCost: 2}},
+		ProviderSpend: []ProviderSpendData{{Provider: "openai", TotalCost: 2, Percentage: 100}},
+	}
+	d.ConvertCosts(FXRate{Currency: "EUR", RatePerUSD: 0.5})
+
+	if d.Currency != "EUR" || d.FXRate != 0.5 {
+		t.Errorf("currency = %s at %v", d.Currency, d.FXRate)
+	}
+	if d.Metrics.TotalCost != 1 || d.Metrics.AvgCostPerRequest != 0.1 || d.DailyCosts[0].Cost != 1 ||
+		d.TopModels[0].TotalCost != 1 || d.ProviderSpend[0].TotalCost != 1 {
+		t.Errorf("costs not converted: %+v", d)
+	}
+	if d.ProviderSpend[0].Percentage != 100 || d.Metrics.TotalRequests != 10 {
+		t.Errorf("ConvertCosts changed more than costs: %+v", d)
+	}
+}
+
+func TestCostForecastConvertCosts(t *testing.T) {
+	f := CostForecast{
+		MonthToDateCost: 10,
+		History:         []ForecastPoint{{Cost: 4}},
+		Projection:      []ForecastPoint{{Cost: 6, Low: 5, High: 7}},
+	}
+	f.ConvertCosts(BaseRate())
+	if f.Currency != BaseCurrency || f.MonthToDateCost != 10 || f.Projection[0].High != 7 {
+		t.Errorf("converting to USD changed the forecast: %+v", f)
+	}
+
+	f.ConvertCosts(FXRate{Currency: "JPY", RatePerUSD: 150})
+	if f.MonthToDateCost != 1500 || f.History[0].Cost != 600 || f.Projection[0].Low != 750 {
+		t.Errorf("forecast not converted: %+v", f)
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// DebugTrace is what the gateway did with one request sent with X-RelAI-Debug: the model it
+// resolved to, how long each stage took, each upstream attempt and each policy's decision
+type DebugTrace struct {
+	RequestID   string                 `json:"request_id"`
+	Method      string                 `json:"method"`
+	Path        string                 `json:"path"`
+	Model       *DebugModel            `json:"model"` // Nil when the request was refused before a model was resolved
+	Stages      []DebugStage           `json:"stages"`
+	Attempts    []DebugAttempt         `json:"attempts"`
+	Retries     int                    `json:"retries"`
+	Policies    []DebugPolicy          `json:"policies"`
+	Annotations map[string]interface{} `json:"annotations,omitempty"` // What the usage log's metadata records
+	Status      int                    `json:"status"`
+	StartedAt   time.Time              `json:"started_at"`
+	DurationMS  float64                `json:"duration_ms"`
+
+	stageStart time.Time // Start of the last stage, while it is in progress
+}
+
+// DebugModel is the model a request resolved to
+type DebugModel struct {
+	ID       string `json:"id"`
+	ModelID  string `json:"model_id"`
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Upstream string `json:"upstream"` // Scheme, host and path; never the query
+}
+
+// DebugStage is one stage of the gateway's handling of a request
+type DebugStage struct {
+	Name       string  `json:"name"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// DebugAttempt is one call to the provider
+type DebugAttempt struct {
+	Attempt    int     `json:"attempt"`
+	Status     int     `json:"status,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// DebugPolicy is a policy's decision on a request or its response
+type DebugPolicy struct {
+	Policy  string `json:"policy"` // e.g. opa, request_hooks, response_hooks
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Stage ends the stage in progress at now and starts the named one
+func (t *DebugTrace) Stage(name string, now time.Time) {
+	t.endStage(now)
+	t.Stages = append(t.Stages, DebugStage{Name: name})
+	t.stageStart = now
+}
+
+// endStage records the duration of the stage in progress
+func (t *DebugTrace) endStage(now time.Time) {
+	if n := len(t.Stages); n > 0 && !t.stageStart.IsZero() {
+		t.Stages[n-1].DurationMS = milliseconds(now.Sub(t.stageStart))
+		t.stageStart = time.Time{}
+	}
+}
+
+// milliseconds converts d to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Attempt records a call to the provider
+func (t *DebugTrace) Attempt(status int, err error, duration time.Duration) {
+	attempt := DebugAttempt{Attempt: len(t.Attempts) + 1, Status: status, DurationMS: milliseconds(duration)}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	t.Attempts = append(t.Attempts, attempt)
+	t.Retries = len(t.Attempts) - 1
+}
+
+// Finish ends the stage in progress and records the request's status and total duration
+func (t *DebugTrace) Finish(status int, now time.Time) {
+	t.endStage(now)
+	t.Status = status
+	t.DurationMS = milliseconds(now.Sub(t.StartedAt))
+}
+
+// Policy records a policy's decision
+func (t *DebugTrace) Policy(policy string, allowed bool, reason string) {
+	t.Policies = append(t.Policies, DebugPolicy{Policy: policy, Allowed: allowed, Reason: reason})
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDebugTrace(t *testing.T) {
+	start := time.Now()
+	trace := &DebugTrace{StartedAt: start}
+
+	trace.Stage("prepare", start)
+	trace.Stage("upstream", start.Add(2*time.Millisecond))
+	trace.Attempt(502, nil, 40*time.Millisecond)
+	trace.Attempt(0, errors.New("timeout"), 30*time.Millisecond)
+	trace.Attempt(200, nil, 25*time.Millisecond)
+	trace.Policy("opa", true, "")
+	trace.Finish(200, start.Add(100*time.Millisecond))
+
+	if len(trace.Stages) != 2 || trace.Stages[0].DurationMS != 2 || trace.Stages[1].DurationMS != 98 {
+		t.Errorf("unexpected stages: %+v", trace.Stages)
+	}
+	if trace.Retries != 2 || trace.Attempts[1].Error != "timeout" || trace.Attempts[2].Attempt != 3 {
+		t.Errorf("unexpected attempts: %+v (retries %d)", trace.Attempts, trace.Retries)
+	}
+	if trace.Status != 200 || trace.DurationMS != 100 {
+		t.Errorf("got status %d after %vms, want 200 after 100ms", trace.Status, trace.DurationMS)
+	}
+
+	// Finishing again doesn't stretch the last stage
+	trace.Finish(200, start.Add(time.Second))
+	if trace.Stages[1].DurationMS != 98 {
+		t.Errorf("last stage changed to %vms after it ended", trace.Stages[1].DurationMS)
+	}
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DeclaredOrganization and DeclaredModel are the kinds of resource declarative config manages
+	DeclaredOrganization = "organization"
+	DeclaredModel        = "model"
+)
+
+// DeclarativeConfig is the organizations and models a deployment declares in files, e.g. a
+// Kubernetes ConfigMap, instead of creating them through the admin APIs. Resources are matched
+// by name.
+type DeclarativeConfig struct {
+	Organizations []DeclaredOrganizationSpec `json:"organizations"`
+	Models        []DeclaredModelSpec        `json:"models"`
+}
+
+// DeclaredOrganizationSpec is an organization as declared
+type DeclaredOrganizationSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Parent      string `json:"parent"`      // Name of the parent organization; empty for top-level
+	TotalQuota  int    `json:"total_quota"` // Tokens, for a new top-level organization only
+	Active      *bool  `json:"active"`      // Defaults to true
+}
+
+// DeclaredModelSpec is a model as declared. Credentials never sit in the config itself: the
+// *_env fields name environment variables holding them, e.g. from a Kubernetes Secret.
+type DeclaredModelSpec struct {
+	Name                 string          `json:"name"`
+	Description          string          `json:"description"`
+	Provider             string          `json:"provider"`
+	ModelID              string          `json:"model_id"`
+	APIEndpoint          string          `json:"api_endpoint"`
+	APITokenEnv          string          `json:"api_token_env"`
+	AWSRegion            string          `json:"aws_region"`
+	AWSAccessKeyID       string          `json:"aws_access_key_id"`
+	AWSSecretKeyEnv      string          `json:"aws_secret_access_key_env"`
+	AWSRoleARN           string          `json:"aws_role_arn"`
+	GCPLocation          string          `json:"gcp_location"`
+	GCPServiceAccountEnv string          `json:"gcp_service_account_env"`
+	InputCostPer1M       *float64        `json:"input_cost_per_1m"`
+	OutputCostPer1M      *float64        `json:"output_cost_per_1m"`
+	MaxRetries           *int            `json:"max_retries"`
+	TimeoutSeconds       *int            `json:"timeout_seconds"`
+	RetryDelayMs         *int            `json:"retry_delay_ms"`
+	BackoffMultiplier    *float64        `json:"backoff_multiplier"`
+	MaxRequestBytes      *int            `json:"max_request_bytes"`
+	Metadata             *ModelMetadata  `json:"metadata"`
+	Transport            *ModelTransport `json:"transport"`
+	Active               *bool           `json:"active"`        // Defaults to true
+	Organizations        []string        `json:"organizations"` // Names of the organizations with access; exactly these
+}
+
+// Validate checks every resource is named once, and that parents are declared before their
+// sub-teams so they can be created in order
+func (c *DeclarativeConfig) Validate() error {
+	orgs := map[string]bool{}
+	for i, org := range c.Organizations {
+		name := strings.TrimSpace(org.Name)
+		if name == "" {
+			return fmt.Errorf("organizations[%d]: name is required", i)
+		}
+		if orgs[name] {
+			return fmt.Errorf("organization %q is declared twice", name)
+		}
+		if org.Parent != "" && !orgs[org.Parent] {
+			return fmt.Errorf("organization %q: parent %q must be declared before it", name, org.Parent)
+		}
+		if org.TotalQuota < 0 {
+			return fmt.Errorf("organization %q: total_quota can't be negative", name)
+		}
+		orgs[name] = true
+	}
+
+	names := map[string]bool{}
+	for i, m := range c.Models {
+		name := strings.TrimSpace(m.Name)
+		if name == "" {
+			return fmt.Errorf("models[%d]: name is required", i)
+		}
+		if names[name] {
+			return fmt.Errorf("model %q is declared twice", name)
+		}
+		names[name] = true
+		if m.Provider == "" || m.ModelID == "" {
+			return fmt.Errorf("model %q: provider and model_id are required", name)
+		}
+		if m.TimeoutSeconds != nil && (*m.TimeoutSeconds < 5 || *m.TimeoutSeconds > 300) {
+			return fmt.Errorf("model %q: timeout_seconds must be between 5 and 300", name)
+		}
+		if m.MaxRequestBytes != nil && *m.MaxRequestBytes <= 0 {
+			return fmt.Errorf("model %q: max_request_bytes must be a positive number of bytes", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import "testing"
+
+func TestDeclarativeConfigValidate(t *testing.T) {
+	timeout := func(s int) *int { return &s }
+	cases := []struct {
+		name    string
+		config  DeclarativeConfig
+		wantErr bool
+	}{
+		{"empty", DeclarativeConfig{}, false},
+		{"parent declared first", DeclarativeConfig{Organizations: []DeclaredOrganizationSpec{
+			{Name: "Acme"}, {Name: "Acme Research", Parent: "Acme"}}}, false},
+		{"parent declared after", DeclarativeConfig{Organizations: []DeclaredOrganizationSpec{
+			{Name: "Acme Research", Parent: "Acme"}, {Name: "Acme"}}}, true},
+		{"organization twice", DeclarativeConfig{Organizations: []DeclaredOrganizationSpec{{Name: "Acme"}, {Name: "Acme"}}}, true},
+		{"unnamed organization", DeclarativeConfig{Organizations: []DeclaredOrganizationSpec{{Name: " "}}}, true},
+		{"negative quota", DeclarativeConfig{Organizations: []DeclaredOrganizationSpec{{Name: "Acme", TotalQuota: -1}}}, true},
+		{"model", DeclarativeConfig{Models: []DeclaredModelSpec{{Name: "GPT-4o", Provider: "openai", ModelID: "gpt-4o"}}}, false},
+		{"model twice", DeclarativeConfig{Models: []DeclaredModelSpec{
+			{Name: "GPT-4o", Provider: "openai", ModelID: "gpt-4o"}, {Name: "GPT-4o", Provider: "azure", ModelID: "gpt-4o"}}}, true},
+		{"model without provider", DeclarativeConfig{Models: []DeclaredModelSpec{{Name: "GPT-4o", ModelID: "gpt-4o"}}}, true},
+		{"timeout too long", DeclarativeConfig{Models: []DeclaredModelSpec{
+			{Name: "GPT-4o", Provider: "openai", ModelID: "gpt-4o", TimeoutSeconds: timeout(600)}}}, true},
+	}
+	for _, tc := range cases {
+		if err := tc.config.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
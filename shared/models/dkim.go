@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DKIMKey is the signing key for emails sent from a domain. The private key is stored
+// encrypted and never returned by the API; the public key is published in DNS.
+type DKIMKey struct {
+	ID         string    `json:"id" db:"id"`
+	Domain     string    `json:"domain" db:"domain"`
+	Selector   string    `json:"selector" db:"selector"`
+	PrivateKey string    `json:"-" db:"private_key"` // Encrypted PEM
+	PublicKey  string    `json:"public_key" db:"public_key"`
+	IsEnabled  bool      `json:"is_enabled" db:"is_enabled"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// DNSRecordName is where the public key must be published
+func (k *DKIMKey) DNSRecordName() string {
+	return k.Selector + "._domainkey." + k.Domain
+}
+
+// DNSRecordValue is the TXT record content to publish
+func (k *DKIMKey) DNSRecordValue() string {
+	return "v=DKIM1; k=rsa; p=" + k.PublicKey
+}
+
+var (
+	domainPattern   = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+	selectorPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+)
+
+// CreateDKIMKeyRequest adds or replaces the key for a domain. Without a PrivateKey a new
+// 2048-bit RSA key is generated.
+type CreateDKIMKeyRequest struct {
+	Domain     string `json:"domain" binding:"required"`
+	Selector   string `json:"selector" binding:"required"`
+	PrivateKey string `json:"private_key"` // Optional PEM (PKCS#1 or PKCS#8)
+}
+
+// Validate normalizes and checks the domain and selector
+func (r *CreateDKIMKeyRequest) Validate() error {
+	r.Domain = strings.ToLower(strings.TrimSpace(r.Domain))
+	r.Selector = strings.ToLower(strings.TrimSpace(r.Selector))
+
+	if !domainPattern.MatchString(r.Domain) {
+		return fmt.Errorf("invalid domain: %s", r.Domain)
+	}
+	if !selectorPattern.MatchString(r.Selector) {
+		return fmt.Errorf("invalid selector: %s", r.Selector)
+	}
+	return nil
+}
@@ -16,12 +16,52 @@ type EmailSettings struct {
 	SMTPPassword  sql.NullString `json:"-" db:"smtp_password"` // Encrypted
 	SMTPFromName  sql.NullString `json:"-" db:"smtp_from_name"`
 	SMTPFromEmail sql.NullString `json:"-" db:"smtp_from_email"`
+	SMTPTLSMode   string         `json:"smtp_tls_mode" db:"smtp_tls_mode"`
 	IsEnabled     bool           `json:"is_enabled" db:"is_enabled"`
 	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
+
+	// Secondary server, tried when the primary can't be reached or refuses the login. It sends
+	// from the same address.
+	SecondarySMTPHost     sql.NullString `json:"-" db:"secondary_smtp_host"`
+	SecondarySMTPPort     int            `json:"secondary_smtp_port" db:"secondary_smtp_port"`
+	SecondarySMTPUsername sql.NullString `json:"-" db:"secondary_smtp_username"`
+	SecondarySMTPPassword sql.NullString `json:"-" db:"secondary_smtp_password"`
+	SecondarySMTPTLSMode  string         `json:"secondary_smtp_tls_mode" db:"secondary_smtp_tls_mode"`
+}
+
+// HasSecondarySMTP reports whether a secondary SMTP server is configured
+func (e *EmailSettings) HasSecondarySMTP() bool {
+	return e.SecondarySMTPHost.String != ""
 }
 
-// Custom JSON marshaling to handle sql.NullString properly
+// SMTP servers, as recorded on the email log of the message they delivered
+const (
+	SMTPServerPrimary   = "primary"
+	SMTPServerSecondary = "secondary"
+)
+
+// SMTP connection security modes
+const (
+	SMTPTLSAuto     = "auto"     // Implicit TLS on port 465, otherwise STARTTLS when offered
+	SMTPTLSImplicit = "implicit" // TLS from the start of the connection (SMTPS)
+	SMTPTLSStartTLS = "starttls" // Require STARTTLS; refuse to send in the clear
+)
+
+// SMTPTLSModes lists the supported connection security modes
+var SMTPTLSModes = []string{SMTPTLSAuto, SMTPTLSImplicit, SMTPTLSStartTLS}
+
+// IsValidSMTPTLSMode reports whether mode is a supported connection security mode
+func IsValidSMTPTLSMode(mode string) bool {
+	for _, m := range SMTPTLSModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Custom JSON marshaling to handle sql.NullString properly. The SMTP password is masked.
 func (e EmailSettings) MarshalJSON() ([]byte, error) {
 	type Alias EmailSettings
 	return json.Marshal(&struct {
@@ -29,29 +69,90 @@ func (e EmailSettings) MarshalJSON() ([]byte, error) {
 		SMTPPassword  string `json:"smtp_password"`
 		SMTPFromName  string `json:"smtp_from_name"`
 		SMTPFromEmail string `json:"smtp_from_email"`
+
+		SecondarySMTPHost     string `json:"secondary_smtp_host"`
+		SecondarySMTPUsername string `json:"secondary_smtp_username"`
+		SecondarySMTPPassword string `json:"secondary_smtp_password"`
 		*Alias
 	}{
 		SMTPUsername:  e.SMTPUsername.String,
-		SMTPPassword:  e.SMTPPassword.String,
+		SMTPPassword:  MaskSecret(e.SMTPPassword.String),
 		SMTPFromName:  e.SMTPFromName.String,
 		SMTPFromEmail: e.SMTPFromEmail.String,
-		Alias:         (*Alias)(&e),
+
+		SecondarySMTPHost:     e.SecondarySMTPHost.String,
+		SecondarySMTPUsername: e.SecondarySMTPUsername.String,
+		SecondarySMTPPassword: MaskSecret(e.SecondarySMTPPassword.String),
+		Alias:                 (*Alias)(&e),
 	})
 }
 
 // EmailTemplate represents an email template for notifications
 type EmailTemplate struct {
-	ID        string    `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Type      string    `json:"type" db:"type"` // 'warning', 'expiration', 'usage'
-	Subject   string    `json:"subject" db:"subject"`
-	HTMLBody  string    `json:"html_body" db:"html_body"`
-	TextBody  *string   `json:"text_body" db:"text_body"`
-	IsActive  bool      `json:"is_active" db:"is_active"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID             string    `json:"id" db:"id"`
+	Name           string    `json:"name" db:"name"`
+	Type           string    `json:"type" db:"type"` // 'warning', 'expiration', 'usage'
+	Subject        string    `json:"subject" db:"subject"`
+	HTMLBody       string    `json:"html_body" db:"html_body"`
+	TextBody       *string   `json:"text_body" db:"text_body"`
+	IsActive       bool      `json:"is_active" db:"is_active"`
+	Locale         string    `json:"locale" db:"locale"`                   // e.g. 'en', 'es'
+	CurrentVersion int       `json:"current_version" db:"current_version"` // Published version used for live notifications
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Email template version statuses
+const (
+	EmailTemplateVersionDraft     = "draft"     // Unpublished edits; at most one per template
+	EmailTemplateVersionPublished = "published" // The live content
+	EmailTemplateVersionArchived  = "archived"  // Previously published
+)
+
+// EmailTemplateVersion is a snapshot of a template's content
+type EmailTemplateVersion struct {
+	ID            string     `json:"id" db:"id"`
+	TemplateID    string     `json:"template_id" db:"template_id"`
+	Version       int        `json:"version" db:"version"`
+	Subject       string     `json:"subject" db:"subject"`
+	HTMLBody      string     `json:"html_body" db:"html_body"`
+	TextBody      *string    `json:"text_body" db:"text_body"`
+	Status        string     `json:"status" db:"status"`
+	SourceVersion *int       `json:"source_version" db:"source_version"` // Set when created by a rollback
+	CreatedBy     *string    `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	PublishedAt   *time.Time `json:"published_at" db:"published_at"`
 }
 
+// Diff operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffLine is one line of a line-based diff
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// EmailTemplateDiff compares the content of two template versions
+type EmailTemplateDiff struct {
+	FromVersion int        `json:"from_version"`
+	ToVersion   int        `json:"to_version"`
+	Subject     []DiffLine `json:"subject"`
+	HTMLBody    []DiffLine `json:"html_body"`
+	TextBody    []DiffLine `json:"text_body"`
+}
+
+// Email schedule types
+const (
+	EmailScheduleAPIKeyWarning    = "api_key_warning"
+	EmailScheduleAPIKeyExpiration = "api_key_expiration"
+)
+
 // EmailSchedule represents scheduled email reminders
 type EmailSchedule struct {
 	ID             string    `json:"id" db:"id"`
@@ -70,10 +171,116 @@ type EmailLog struct {
 	TemplateID     *string    `json:"template_id" db:"template_id"`
 	Status         string     `json:"status" db:"status"` // 'sent', 'failed', 'pending'
 	ErrorMessage   *string    `json:"error_message" db:"error_message"`
+	OutboxID       *string    `json:"outbox_id" db:"outbox_id"`     // Outbox message it was sent from; lets a failed email be resent
+	SMTPServer     *string    `json:"smtp_server" db:"smtp_server"` // 'primary' or 'secondary'; the server that delivered it
 	SentAt         *time.Time `json:"sent_at" db:"sent_at"`
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 }
 
+const (
+	defaultEmailLogsPage = 50
+	maxEmailLogsPage     = 500
+)
+
+// EmailLogSearch filters and pages the email logs
+type EmailLogSearch struct {
+	Recipient  string `form:"recipient"` // Case-insensitive substring of the recipient address
+	Status     string `form:"status"`
+	TemplateID string `form:"template_id"`
+	From       string `form:"from"` // Date or RFC 3339 timestamp
+	To         string `form:"to"`   // Date (inclusive) or RFC 3339 timestamp (exclusive)
+	Page       int    `form:"page"`
+	PageSize   int    `form:"page_size"`
+
+	FromTime *time.Time `form:"-"`
+	ToTime   *time.Time `form:"-"` // Exclusive upper bound
+}
+
+// Validate checks the status, parses the date range and defaults and caps the page
+func (s *EmailLogSearch) Validate() error {
+	switch s.Status {
+	case "", "sent", "failed", "pending":
+	default:
+		return fmt.Errorf("status must be sent, failed or pending")
+	}
+
+	if s.Page <= 0 {
+		s.Page = 1
+	}
+	if s.PageSize <= 0 {
+		s.PageSize = defaultEmailLogsPage
+	}
+	s.PageSize = min(s.PageSize, maxEmailLogsPage)
+
+	if s.From != "" {
+		from, _, err := parseLogTime(s.From)
+		if err != nil {
+			return fmt.Errorf("from must be a date or an RFC 3339 timestamp")
+		}
+		s.FromTime = &from
+	}
+	if s.To != "" {
+		to, dateOnly, err := parseLogTime(s.To)
+		if err != nil {
+			return fmt.Errorf("to must be a date or an RFC 3339 timestamp")
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		s.ToTime = &to
+	}
+	if s.FromTime != nil && s.ToTime != nil && !s.FromTime.Before(*s.ToTime) {
+		return fmt.Errorf("from must be before to")
+	}
+	return nil
+}
+
+// Offset returns how many logs come before the requested page
+func (s *EmailLogSearch) Offset() int {
+	return (s.Page - 1) * s.PageSize
+}
+
+// parseLogTime parses a YYYY-MM-DD date, in UTC, or an RFC 3339 timestamp
+func parseLogTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, false, err
+}
+
+// Email outbox statuses
+const (
+	EmailOutboxStatusPending = "pending"
+	EmailOutboxStatusSending = "sending"
+	EmailOutboxStatusSent    = "sent"
+	EmailOutboxStatusFailed  = "failed"
+	// EmailOutboxStatusSuppressed marks notifications dropped because the recipient unsubscribed
+	EmailOutboxStatusSuppressed = "suppressed"
+)
+
+// EmailOutboxMessage is a queued email waiting to be delivered by the background sender
+type EmailOutboxMessage struct {
+	ID             string  `json:"id" db:"id"`
+	RecipientEmail string  `json:"recipient_email" db:"recipient_email"`
+	Subject        string  `json:"subject" db:"subject"`
+	HTMLBody       string  `json:"-" db:"html_body"`
+	TemplateID     *string `json:"template_id" db:"template_id"`
+	OrganizationID *string `json:"organization_id" db:"organization_id"`
+	// NotificationType is the event for notification emails; nil for transactional mail,
+	// which ignores unsubscribe preferences
+	NotificationType *string    `json:"notification_type" db:"notification_type"`
+	Status           string     `json:"status" db:"status"` // 'pending', 'sending', 'sent', 'failed', 'suppressed'
+	Attempts         int        `json:"attempts" db:"attempts"`
+	MaxAttempts      int        `json:"max_attempts" db:"max_attempts"`
+	ScheduledAt      time.Time  `json:"scheduled_at" db:"scheduled_at"`
+	NextAttemptAt    time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError        *string    `json:"last_error" db:"last_error"`
+	SentAt           *time.Time `json:"sent_at" db:"sent_at"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+}
+
 // EmailTemplateVariables represents the variables available for email templates
 type EmailTemplateVariables struct {
 	UserName            string `json:"user_name"`
@@ -82,6 +289,20 @@ type EmailTemplateVariables struct {
 	OrganizationName    string `json:"organization_name"`
 	DaysUntilExpiration int    `json:"days_until_expiration"`
 	ManagementURL       string `json:"management_url"`
+	UnsubscribeURL      string `json:"unsubscribe_url"` // Signed link opting the recipient out of this notification
+
+	// Organization branding, falling back to the active theme
+	LogoURL    string `json:"logo_url"`
+	BrandColor string `json:"brand_color"`
+	BrandName  string `json:"brand_name"`
+
+	// Quota usage notifications
+	Threshold    int                  `json:"threshold"`
+	UsagePercent string               `json:"usage_percent"`
+	UsedTokens   string               `json:"used_tokens"`
+	TotalQuota   string               `json:"total_quota"`
+	ResetDate    string               `json:"reset_date"`
+	TopAPIKeys   []APIKeyUsageSummary `json:"top_api_keys"`
 }
 
 // CreateEmailTemplateRequest represents a request to create a new email template
@@ -92,6 +313,7 @@ type CreateEmailTemplateRequest struct {
 	HTMLBody string  `json:"html_body" binding:"required"`
 	TextBody *string `json:"text_body"`
 	IsActive *bool   `json:"is_active"`
+	Locale   string  `json:"locale"` // Defaults to the default locale
 }
 
 // UpdateEmailTemplateRequest represents a request to update an email template
@@ -102,6 +324,13 @@ type UpdateEmailTemplateRequest struct {
 	HTMLBody *string `json:"html_body"`
 	TextBody *string `json:"text_body"`
 	IsActive *bool   `json:"is_active"`
+	Locale   *string `json:"locale"`
+	Draft    bool    `json:"draft"` // Save content changes as a draft instead of publishing them
+}
+
+// RollbackEmailTemplateRequest republishes a previous version's content
+type RollbackEmailTemplateRequest struct {
+	Version int `json:"version" binding:"required"`
 }
 
 // FlexibleBool is a custom type that can unmarshal from both bool and string
@@ -140,7 +369,15 @@ type UpdateEmailSettingsRequest struct {
 	SMTPPassword  *string       `json:"smtp_password"`
 	SMTPFromName  *string       `json:"smtp_from_name"`
 	SMTPFromEmail *string       `json:"smtp_from_email"`
+	SMTPTLSMode   *string       `json:"smtp_tls_mode"`
 	IsEnabled     *FlexibleBool `json:"is_enabled"` // Can handle both bool and string
+
+	// Secondary server; an empty host removes it
+	SecondarySMTPHost     *string `json:"secondary_smtp_host"`
+	SecondarySMTPPort     *string `json:"secondary_smtp_port"`
+	SecondarySMTPUsername *string `json:"secondary_smtp_username"`
+	SecondarySMTPPassword *string `json:"secondary_smtp_password"`
+	SecondarySMTPTLSMode  *string `json:"secondary_smtp_tls_mode"`
 }
 
 // SendTestEmailRequest represents a request to send a test email
@@ -148,6 +385,8 @@ type SendTestEmailRequest struct {
 	RecipientEmail string                  `json:"recipient_email" binding:"required,email"`
 	TemplateID     string                  `json:"template_id" binding:"required"`
 	TestData       *EmailTemplateVariables `json:"test_data"`
+	SendAt         *time.Time              `json:"send_at"`         // Optional; schedules delivery for later
+	OrganizationID *string                 `json:"organization_id"` // Optional; applies the organization's branding
 }
 
 // EmailTemplateWithVariables includes template and sample variables for preview
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmailLogSearchValidate(t *testing.T) {
+	s := EmailLogSearch{}
+	if err := s.Validate(); err != nil || s.Page != 1 || s.PageSize != defaultEmailLogsPage {
+		t.Errorf("Validate() = %v, page %d size %d; want the first default page", err, s.Page, s.PageSize)
+	}
+
+	s = EmailLogSearch{Status: "failed", Page: 3, PageSize: 10000, From: "2026-03-01", To: "2026-03-31"}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if s.PageSize != maxEmailLogsPage {
+		t.Errorf("page size = %d, want capped at %d", s.PageSize, maxEmailLogsPage)
+	}
+	if s.Offset() != 2*maxEmailLogsPage {
+		t.Errorf("Offset() = %d, want %d", s.Offset(), 2*maxEmailLogsPage)
+	}
+	if want := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC); s.ToTime == nil || !s.ToTime.Equal(want) {
+		t.Errorf("ToTime = %v, want the end of the to date %v", s.ToTime, want)
+	}
+
+	s = EmailLogSearch{To: "2026-03-31T12:00:00Z"}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if want := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC); !s.ToTime.Equal(want) {
+		t.Errorf("ToTime = %v, want the timestamp as given", s.ToTime)
+	}
+
+	for _, bad := range []EmailLogSearch{
+		{Status: "bounced"},
+		{From: "last week"},
+		{From: "2026-03-02", To: "2026-03-01"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", bad)
+		}
+	}
+}
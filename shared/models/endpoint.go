@@ -22,6 +22,7 @@ type Endpoint struct {
 }
 
 type EndpointCreate struct {
+	OrganizationID  string  `json:"organization_id" validate:"omitempty,uuid"`
 	Name            string  `json:"name" validate:"required,min=1,max=255"`
 	PathPrefix      string  `json:"path_prefix" validate:"required,min=1,max=255,alphanum"`
 	Description     *string `json:"description" validate:"omitempty,max=1000"`
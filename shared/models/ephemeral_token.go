@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// EphemeralTokenPath is where an API key is exchanged for an ephemeral token
+const EphemeralTokenPath = "/v1/auth/ephemeral"
+
+// Ephemeral token lifetimes, in seconds
+const (
+	DefaultEphemeralTokenSeconds = 15 * 60
+	MinEphemeralTokenSeconds     = 60
+	MaxEphemeralTokenSeconds     = 60 * 60
+)
+
+// CreateEphemeralTokenRequest asks for a short-lived token limited to some of the parent key's
+// scopes
+type CreateEphemeralTokenRequest struct {
+	Scopes    []string `json:"scopes" binding:"required"`
+	ExpiresIn int      `json:"expires_in"` // Seconds; defaults to 15 minutes
+}
+
+// Validate fills in the default lifetime and checks every scope is known and allowed to the
+// parent key. Tokens can't be unrestricted, so at least one scope is required.
+func (r *CreateEphemeralTokenRequest) Validate(parentScopes []string) error {
+	if r.ExpiresIn == 0 {
+		r.ExpiresIn = DefaultEphemeralTokenSeconds
+	}
+	if r.ExpiresIn < MinEphemeralTokenSeconds || r.ExpiresIn > MaxEphemeralTokenSeconds {
+		return fmt.Errorf("expires_in must be between %d and %d seconds", MinEphemeralTokenSeconds, MaxEphemeralTokenSeconds)
+	}
+	if len(r.Scopes) == 0 {
+		return fmt.Errorf("scopes must name at least one of %v", APIKeyScopes)
+	}
+
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, scope := range r.Scopes {
+		if !IsValidAPIKeyScope(scope) {
+			return fmt.Errorf("unknown scope %q; scopes must be among %v", scope, APIKeyScopes)
+		}
+		if !APIKeyAllows(parentScopes, scope) {
+			return fmt.Errorf("the API key does not have scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	r.Scopes = scopes
+	return nil
+}
+
+// EphemeralToken is a minted token and what it allows
+type EphemeralToken struct {
+	Object    string    `json:"object"`
+	Token     string    `json:"token"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresIn int       `json:"expires_in"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
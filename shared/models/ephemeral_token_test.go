@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestCreateEphemeralTokenRequestValidate(t *testing.T) {
+	r := CreateEphemeralTokenRequest{Scopes: []string{APIKeyScopeChat, APIKeyScopeChat}}
+	if err := r.Validate(nil); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if r.ExpiresIn != DefaultEphemeralTokenSeconds || len(r.Scopes) != 1 {
+		t.Errorf("Validate() = %+v, want the default lifetime and de-duplicated scopes", r)
+	}
+
+	parent := []string{APIKeyScopeChat, APIKeyScopeEmbeddings}
+	for name, bad := range map[string]CreateEphemeralTokenRequest{
+		"no scopes":         {},
+		"unknown scope":     {Scopes: []string{"admin"}},
+		"wider than key":    {Scopes: []string{APIKeyScopeImages}},
+		"lifetime too low":  {Scopes: []string{APIKeyScopeChat}, ExpiresIn: 10},
+		"lifetime too high": {Scopes: []string{APIKeyScopeChat}, ExpiresIn: MaxEphemeralTokenSeconds + 1},
+	} {
+		if err := bad.Validate(parent); err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package models
+
+import "net/http"
+
+// ErrorCode is a stable code the gateway answers an error with, so clients can branch on it
+// rather than on the message. Codes are grouped by their first digit: 1xxx limits, 2xxx
+// authentication and access, 3xxx invalid requests, 4xxx policies and 5xxx the gateway or the
+// provider. A code's meaning never changes once published; retired codes aren't reused.
+type ErrorCode struct {
+	Code        string `json:"code"`                  // e.g. RELAI-2003
+	Status      int    `json:"status"`                // HTTP status it is usually sent with
+	Type        string `json:"type"`                  // The OpenAI-style error type sent alongside it
+	OpenAICode  string `json:"openai_code,omitempty"` // The OpenAI-style code sent alongside it, where there is one
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// Error codes returned by the gateway. Token quotas are reported in the x-ratelimit headers but
+// not enforced, so there is no quota exceeded code yet.
+var (
+	ErrorRateLimited = ErrorCode{
+		Code: "RELAI-1001", Status: http.StatusTooManyRequests, Type: "requests", OpenAICode: "rate_limit_exceeded",
+		Title:       "Rate limit exceeded",
+		Description: "The API key sent more requests per minute than the gateway's rate limit allows. Retry after the x-ratelimit-reset-requests header's interval.",
+	}
+	ErrorGatewaySaturated = ErrorCode{
+		Code: "RELAI-1002", Status: http.StatusTooManyRequests, Type: "requests", OpenAICode: "gateway_saturated",
+		Title:       "Gateway at capacity",
+		Description: "The gateway is serving as many requests as it can and shed this one by priority. Retry shortly.",
+	}
+	ErrorRequestTooLarge = ErrorCode{
+		Code: "RELAI-1003", Status: http.StatusRequestEntityTooLarge, Type: "request_too_large",
+		Title:       "Request body too large",
+		Description: "The request body exceeds the gateway's or the model's size limit.",
+	}
+	ErrorContextLengthExceeded = ErrorCode{
+		Code: "RELAI-1004", Status: http.StatusBadRequest, Type: "invalid_request_error", OpenAICode: "context_length_exceeded",
+		Title:       "Context length exceeded",
+		Description: "The conversation doesn't fit in the model's context window after max_tokens, and the model's context strategy rejects it or can't trim it to fit.",
+	}
+	ErrorRequestCostExceeded = ErrorCode{
+		Code: "RELAI-1005", Status: http.StatusBadRequest, Type: "invalid_request_error",
+		Title:       "Request cost ceiling exceeded",
+		Description: "The request's worst-case cost, its prompt plus max_tokens at the model's prices, is over the organization's per-request ceiling, or can't be bounded because neither max_tokens nor the model's context window is set.",
+	}
+
+	ErrorAuthenticationRequired = ErrorCode{
+		Code: "RELAI-2001", Status: http.StatusUnauthorized, Type: "authentication_error",
+		Title:       "Missing credentials",
+		Description: "The request has no API key, or its bearer token isn't a valid ephemeral or service token.",
+	}
+	ErrorInvalidAPIKey = ErrorCode{
+		Code: "RELAI-2002", Status: http.StatusUnauthorized, Type: "authentication_error",
+		Title:       "Invalid API key",
+		Description: "The API key doesn't exist, has been revoked or has expired.",
+	}
+	ErrorModelAccessDenied = ErrorCode{
+		Code: "RELAI-2003", Status: http.StatusForbidden, Type: "permission_error",
+		Title:       "Model access denied",
+		Description: "The API key's organization doesn't have access to the requested model.",
+	}
+	ErrorScopeRequired = ErrorCode{
+		Code: "RELAI-2004", Status: http.StatusForbidden, Type: "permission_error",
+		Title:       "API key scope required",
+		Description: "The API key is limited to scopes that don't include this endpoint, or to X-RelAI-Debug.",
+	}
+	ErrorAuthorizationDenied = ErrorCode{
+		Code: "RELAI-2005", Status: http.StatusForbidden, Type: "authorization_denied",
+		Title:       "Denied by authorization policy",
+		Description: "The deployment's OPA policy denied the request. The policy may answer with another status.",
+	}
+	ErrorIPBlocked = ErrorCode{
+		Code: "RELAI-2006", Status: http.StatusForbidden, Type: "permission_error",
+		Title:       "Caller blocked",
+		Description: "The caller's IP address was blocked after it used a canary key.",
+	}
+	ErrorAPIKeyRequired = ErrorCode{
+		Code: "RELAI-2007", Status: http.StatusForbidden, Type: "permission_error",
+		Title:       "API key required",
+		Description: "The endpoint needs the API key itself; an ephemeral token can't mint another.",
+	}
+
+	ErrorInvalidRequest = ErrorCode{
+		Code: "RELAI-3001", Status: http.StatusBadRequest, Type: "invalid_request_error",
+		Title:       "Invalid request",
+		Description: "The request body is malformed, or asks for something the model doesn't support, such as tools or images.",
+	}
+	ErrorInvalidModel = ErrorCode{
+		Code: "RELAI-3002", Status: http.StatusBadRequest, Type: "invalid_request_error",
+		Title:       "Model not readable",
+		Description: "The request body isn't JSON with a model field naming the model to use.",
+	}
+	ErrorInvalidSession = ErrorCode{
+		Code: "RELAI-3003", Status: http.StatusBadRequest, Type: "invalid_request_error", OpenAICode: "invalid_session",
+		Title:       "Invalid session",
+		Description: "The X-RelAI-Session header isn't a valid session ID, or session memory is unavailable (sent with 503).",
+	}
+	ErrorRequestStopped = ErrorCode{
+		Code: "RELAI-3004", Status: 499, Type: "request_stopped",
+		Title:       "Request stopped",
+		Description: "The client stopped the request before the provider answered.",
+	}
+	ErrorUnsupportedParameter = ErrorCode{
+		Code: "RELAI-3005", Status: http.StatusBadRequest, Type: "invalid_request_error", OpenAICode: "unsupported_parameter",
+		Title:       "Unsupported parameter",
+		Description: "The request sends parameters the model's provider doesn't accept, and the model is set to reject rather than drop them.",
+	}
+	ErrorNotFound = ErrorCode{
+		Code: "RELAI-3006", Status: http.StatusNotFound, Type: "invalid_request_error", OpenAICode: "not_found",
+		Title:       "Not found",
+		Description: "The collection, document, file, batch, session, debug trace or in-flight request doesn't exist, or belongs to another organization or key.",
+	}
+	ErrorConflict = ErrorCode{
+		Code: "RELAI-3007", Status: http.StatusConflict, Type: "invalid_request_error", OpenAICode: "conflict",
+		Title:       "Conflict",
+		Description: "The request conflicts with the resource as it stands, such as creating a collection whose name is taken or cancelling a batch that has finished.",
+	}
+
+	ErrorPolicyRejected = ErrorCode{
+		Code: "RELAI-4001", Status: http.StatusForbidden, Type: "policy_rejected",
+		Title:       "Rejected by policy hook",
+		Description: "One of the deployment's request or response hooks rejected the request. The hook may answer with another status.",
+	}
+	ErrorSecretDetected = ErrorCode{
+		Code: "RELAI-4002", Status: http.StatusBadRequest, Type: "secret_detected",
+		Title:       "Credentials in prompt",
+		Description: "The prompt appears to contain credentials and the organization blocks such requests.",
+	}
+
+	ErrorProviderUnreachable = ErrorCode{
+		Code: "RELAI-5001", Status: http.StatusBadGateway, Type: "gateway_error",
+		Title:       "Provider unreachable",
+		Description: "The gateway couldn't reach the model's provider, after retries.",
+	}
+	ErrorProviderResponse = ErrorCode{
+		Code: "RELAI-5002", Status: http.StatusInternalServerError, Type: "gateway_error",
+		Title:       "Provider response unreadable",
+		Description: "The provider answered, but the gateway couldn't read its response.",
+	}
+	ErrorAuthorizationUnavailable = ErrorCode{
+		Code: "RELAI-5003", Status: http.StatusServiceUnavailable, Type: "authorization_unavailable",
+		Title:       "Authorization service unavailable",
+		Description: "The deployment's OPA policy couldn't be evaluated and the gateway fails closed.",
+	}
+	ErrorInternal = ErrorCode{
+		Code: "RELAI-5004", Status: http.StatusInternalServerError, Type: "gateway_error",
+		Title:       "Internal gateway error",
+		Description: "The gateway failed to handle the request. Retrying may succeed; report it if it persists.",
+	}
+	ErrorFeatureUnavailable = ErrorCode{
+		Code: "RELAI-5005", Status: http.StatusServiceUnavailable, Type: "service_unavailable",
+		Title:       "Feature unavailable",
+		Description: "The endpoint's feature is turned off on this gateway, or what it relies on, such as the vector store, is unreachable.",
+	}
+	ErrorReadOnly = ErrorCode{
+		Code: "RELAI-5006", Status: http.StatusServiceUnavailable, Type: "service_unavailable",
+		Title:       "Read-only mode",
+		Description: "The gateway is in read-only mode for database maintenance and can't save changes. Proxied requests still work; retry the change after the Retry-After interval.",
+	}
+)
+
+// ErrorCodes lists every error code, in code order, for the catalog
+var ErrorCodes = []ErrorCode{
+	ErrorRateLimited,
+	ErrorGatewaySaturated,
+	ErrorRequestTooLarge,
+	ErrorContextLengthExceeded,
+	ErrorRequestCostExceeded,
+	ErrorAuthenticationRequired,
+	ErrorInvalidAPIKey,
+	ErrorModelAccessDenied,
+	ErrorScopeRequired,
+	ErrorAuthorizationDenied,
+	ErrorIPBlocked,
+	ErrorAPIKeyRequired,
+	ErrorInvalidRequest,
+	ErrorInvalidModel,
+	ErrorInvalidSession,
+	ErrorRequestStopped,
+	ErrorUnsupportedParameter,
+	ErrorNotFound,
+	ErrorConflict,
+	ErrorPolicyRejected,
+	ErrorSecretDetected,
+	ErrorProviderUnreachable,
+	ErrorProviderResponse,
+	ErrorAuthorizationUnavailable,
+	ErrorInternal,
+	ErrorFeatureUnavailable,
+	ErrorReadOnly,
+}
+
+// Body is the OpenAI-style error body answering a request with the code. The RelAI code is sent
+// as relai_code, leaving code to the OpenAI-style value that SDKs already understand.
+func (e ErrorCode) Body(message string) map[string]interface{} {
+	body := map[string]interface{}{
+		"message":    message,
+		"type":       e.Type,
+		"relai_code": e.Code,
+	}
+	if e.OpenAICode != "" {
+		body["code"] = e.OpenAICode
+	}
+	return map[string]interface{}{"error": body}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestErrorCodesAreUniqueAndOrdered(t *testing.T) {
+	format := regexp.MustCompile(`^RELAI-[1-5]\d{3}$`)
+	seen := map[string]bool{}
+	for i, e := range ErrorCodes {
+		if !format.MatchString(e.Code) {
+			t.Errorf("error code %q is not RELAI- and four digits from 1xxx to 5xxx", e.Code)
+		}
+		if seen[e.Code] {
+			t.Errorf("error code %s is listed twice", e.Code)
+		}
+		seen[e.Code] = true
+		if i > 0 && e.Code <= ErrorCodes[i-1].Code {
+			t.Errorf("error code %s is listed after %s", e.Code, ErrorCodes[i-1].Code)
+		}
+		if e.Status == 0 || e.Type == "" || e.Title == "" || e.Description == "" {
+			t.Errorf("error code %s is missing its status, type, title or description", e.Code)
+		}
+	}
+}
+
+func TestErrorCodeBody(t *testing.T) {
+	body := ErrorModelAccessDenied.Body("no access to gpt-4o")["error"].(map[string]interface{})
+	if body["relai_code"] != "RELAI-2003" || body["type"] != "permission_error" || body["message"] != "no access to gpt-4o" {
+		t.Errorf("unexpected body %v", body)
+	}
+	if _, ok := body["code"]; ok {
+		t.Errorf("expected no OpenAI code, got %v", body["code"])
+	}
+
+	body = ErrorRateLimited.Body("slow down")["error"].(map[string]interface{})
+	if body["code"] != "rate_limit_exceeded" || body["relai_code"] != "RELAI-1001" {
+		t.Errorf("unexpected body %v", body)
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Experiment statuses
+const (
+	ExperimentStatusDraft     = "draft"
+	ExperimentStatusRunning   = "running"
+	ExperimentStatusStopped   = "stopped"
+	ExperimentStatusCompleted = "completed"
+)
+
+// Experiment represents an A/B test splitting traffic on an endpoint between variants
+type Experiment struct {
+	ID              string              `json:"id" db:"id"`
+	OrganizationID  string              `json:"organization_id" db:"organization_id"`
+	Name            string              `json:"name" db:"name"`
+	Description     *string             `json:"description" db:"description"`
+	Endpoint        string              `json:"endpoint" db:"endpoint"` // e.g., "/v1/chat/completions"
+	Status          string              `json:"status" db:"status"`     // 'draft', 'running', 'stopped', 'completed'
+	WinnerVariantID *string             `json:"winner_variant_id" db:"winner_variant_id"`
+	CreatedBy       *string             `json:"created_by" db:"created_by"`
+	StartedAt       *time.Time          `json:"started_at" db:"started_at"`
+	EndedAt         *time.Time          `json:"ended_at" db:"ended_at"`
+	CreatedAt       time.Time           `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at" db:"updated_at"`
+	Variants        []ExperimentVariant `json:"variants,omitempty"`
+}
+
+// ExperimentVariant is one arm of an experiment: a model plus an optional system prompt
+type ExperimentVariant struct {
+	ID             string    `json:"id" db:"id"`
+	ExperimentID   string    `json:"experiment_id" db:"experiment_id"`
+	Name           string    `json:"name" db:"name"`
+	ModelID        string    `json:"model_id" db:"model_id"` // Provider model name, e.g. "gpt-4"
+	SystemPrompt   *string   `json:"system_prompt" db:"system_prompt"`
+	TrafficPercent int       `json:"traffic_percent" db:"traffic_percent"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateExperimentVariantRequest struct {
+	Name           string  `json:"name" binding:"required"`
+	ModelID        string  `json:"model_id" binding:"required"`
+	SystemPrompt   *string `json:"system_prompt"`
+	TrafficPercent int     `json:"traffic_percent"`
+}
+
+type CreateExperimentRequest struct {
+	Name           string                           `json:"name" binding:"required"`
+	Description    *string                          `json:"description"`
+	OrganizationID string                           `json:"organization_id" binding:"required"`
+	Endpoint       string                           `json:"endpoint" binding:"required"`
+	Variants       []CreateExperimentVariantRequest `json:"variants" binding:"required"`
+}
+
+type UpdateExperimentRequest struct {
+	Name            *string `json:"name"`
+	Description     *string `json:"description"`
+	Status          *string `json:"status"`
+	WinnerVariantID *string `json:"winner_variant_id"`
+}
+
+// Validate checks that the experiment has at least two variants whose traffic adds up to 100%
+func (r *CreateExperimentRequest) Validate() error {
+	if len(r.Variants) < 2 {
+		return fmt.Errorf("an experiment needs at least two variants")
+	}
+
+	total := 0
+	for _, v := range r.Variants {
+		if v.TrafficPercent < 0 || v.TrafficPercent > 100 {
+			return fmt.Errorf("variant %s has invalid traffic percent %d", v.Name, v.TrafficPercent)
+		}
+		total += v.TrafficPercent
+	}
+
+	if total != 100 {
+		return fmt.Errorf("variant traffic percentages must add up to 100 (got %d)", total)
+	}
+
+	return nil
+}
+
+// IsValidExperimentStatus reports whether status is a known experiment status
+func IsValidExperimentStatus(status string) bool {
+	switch status {
+	case ExperimentStatusDraft, ExperimentStatusRunning, ExperimentStatusStopped, ExperimentStatusCompleted:
+		return true
+	}
+	return false
+}
+
+// ExperimentVariantStats holds the outcome metrics of a single variant
+type ExperimentVariantStats struct {
+	VariantID           string  `json:"variant_id"`
+	VariantName         string  `json:"variant_name"`
+	ModelID             string  `json:"model_id"`
+	TrafficPercent      int     `json:"traffic_percent"`
+	RequestCount        int64   `json:"request_count"`
+	ErrorCount          int64   `json:"error_count"`
+	AvgLatencyMS        float64 `json:"avg_latency_ms"`
+	P95LatencyMS        float64 `json:"p95_latency_ms"`
+	TotalCost           float64 `json:"total_cost"`
+	AvgCost             float64 `json:"avg_cost"`
+	AvgPromptTokens     float64 `json:"avg_prompt_tokens"`
+	AvgCompletionTokens float64 `json:"avg_completion_tokens"`
+	ThumbsUp            int64   `json:"thumbs_up"`
+	ThumbsDown          int64   `json:"thumbs_down"`
+	AvgRating           float64 `json:"avg_rating"`
+	SatisfactionRate    float64 `json:"satisfaction_rate"`
+}
+
+// ExperimentReport compares the variants of an experiment side by side
+type ExperimentReport struct {
+	Experiment  Experiment               `json:"experiment"`
+	Variants    []ExperimentVariantStats `json:"variants"`
+	GeneratedAt time.Time                `json:"generated_at"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ResponseFeedback records an end-user rating of a gateway response
+type ResponseFeedback struct {
+	ID             string    `json:"id" db:"id"`
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	APIKeyID       *string   `json:"api_key_id" db:"api_key_id"`
+	RequestID      string    `json:"request_id" db:"request_id"`
+	UsageLogID     *string   `json:"usage_log_id" db:"usage_log_id"`
+	ThumbsUp       *bool     `json:"thumbs_up" db:"thumbs_up"`
+	Rating         *int      `json:"rating" db:"rating"` // 1-5
+	Comment        *string   `json:"comment" db:"comment"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateFeedbackRequest struct {
+	RequestID string  `json:"request_id" binding:"required"`
+	ThumbsUp  *bool   `json:"thumbs_up"`
+	Rating    *int    `json:"rating"`
+	Comment   *string `json:"comment"`
+}
+
+// Validate checks that the feedback carries a thumbs up/down or a 1-5 rating
+func (r *CreateFeedbackRequest) Validate() error {
+	if r.ThumbsUp == nil && r.Rating == nil {
+		return fmt.Errorf("either thumbs_up or rating is required")
+	}
+	if r.Rating != nil && (*r.Rating < 1 || *r.Rating > 5) {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	if r.Comment != nil && len(*r.Comment) > 4000 {
+		return fmt.Errorf("comment must be at most 4000 characters")
+	}
+	return nil
+}
+
+// FeedbackStats summarizes satisfaction for one model or endpoint.
+// A response counts as positive if it got a thumbs up, or a rating of 4 or 5 when no thumbs was given.
+type FeedbackStats struct {
+	Name             string  `json:"name"`
+	ModelID          string  `json:"model_id,omitempty"`
+	FeedbackCount    int64   `json:"feedback_count"`
+	PositiveCount    int64   `json:"positive_count"`
+	NegativeCount    int64   `json:"negative_count"`
+	RatingCount      int64   `json:"rating_count"`
+	AvgRating        float64 `json:"avg_rating"`
+	SatisfactionRate float64 `json:"satisfaction_rate"`
+}
+
+type FeedbackComment struct {
+	RequestID string    `json:"request_id"`
+	ModelID   *string   `json:"model_id"`
+	Endpoint  *string   `json:"endpoint"`
+	ThumbsUp  *bool     `json:"thumbs_up"`
+	Rating    *int      `json:"rating"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FeedbackAnalytics struct {
+	Overall        FeedbackStats     `json:"overall"`
+	ByModel        []FeedbackStats   `json:"by_model"`
+	ByEndpoint     []FeedbackStats   `json:"by_endpoint"`
+	RecentComments []FeedbackComment `json:"recent_comments"`
+	TimeRange      string            `json:"time_range"`
+	Organization   string            `json:"organization"`
+	GeneratedAt    time.Time         `json:"generated_at"`
+}
@@ -0,0 +1,271 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// ForecastHistoryDays is how many completed days of usage a forecast is fitted to
+	ForecastHistoryDays = 28
+
+	// forecastZ widens projections to a 90% confidence band
+	forecastZ = 1.645
+
+	// quotaHorizonDays bounds how far ahead quota exhaustion is searched for
+	quotaHorizonDays = 366
+)
+
+// DailySpend is one day of an organization's usage
+type DailySpend struct {
+	Date   time.Time `json:"date"`
+	Cost   float64   `json:"cost"`
+	Tokens int64     `json:"tokens"`
+}
+
+// ForecastPoint is one day of actual or projected spend; Low and High bound projections
+type ForecastPoint struct {
+	Date   string  `json:"date"`
+	Cost   float64 `json:"cost"`
+	Low    float64 `json:"low"`
+	High   float64 `json:"high"`
+	Tokens int64   `json:"tokens"`
+}
+
+// CostForecast projects an organization's (or, with no organization, the whole gateway's)
+// spend to the end of the month and its token quota to exhaustion
+type CostForecast struct {
+	OrganizationID            string          `json:"organization_id,omitempty"`
+	MonthToDateCost           float64         `json:"month_to_date_cost"`
+	ProjectedMonthCost        float64         `json:"projected_month_cost"`
+	ProjectedMonthCostLow     float64         `json:"projected_month_cost_low"`
+	ProjectedMonthCostHigh    float64         `json:"projected_month_cost_high"`
+	DailyBurnRate             float64         `json:"daily_burn_rate"` // Average cost per day over the last 7 days
+	TokenBurnRate             int64           `json:"token_burn_rate"` // Average tokens per day over the last 7 days
+	QuotaTotal                *int64          `json:"quota_total"`     // Nil without a quota
+	QuotaUsed                 *int64          `json:"quota_used"`
+	QuotaExhaustionDate       *string         `json:"quota_exhaustion_date"` // Nil when not projected within a year
+	QuotaExhaustedBeforeReset bool            `json:"quota_exhausted_before_reset"`
+	History                   []ForecastPoint `json:"history"`
+	Projection                []ForecastPoint `json:"projection"` // Today through the end of the month
+	CostsHidden               bool            `json:"costs_hidden"`
+	Currency                  string          `json:"currency"`
+	FXRate                    float64         `json:"fx_rate"`
+	GeneratedAt               time.Time       `json:"generated_at"`
+}
+
+// FillDailySpend returns one entry per day from `from` up to but excluding `to`, with zeros for
+// days without usage
+func FillDailySpend(days []DailySpend, from, to time.Time) []DailySpend {
+	byDate := make(map[string]DailySpend, len(days))
+	for _, d := range days {
+		byDate[d.Date.Format("2006-01-02")] = d
+	}
+
+	var filled []DailySpend
+	for day := truncateDay(from); day.Before(truncateDay(to)); day = day.AddDate(0, 0, 1) {
+		d, ok := byDate[day.Format("2006-01-02")]
+		if !ok {
+			d = DailySpend{}
+		}
+		d.Date = day
+		filled = append(filled, d)
+	}
+	return filled
+}
+
+// BuildCostForecast fits a linear trend with weekly seasonality to the completed days of
+// history (oldest first, one per day) and projects it from today to the end of the month and,
+// when a quota is given, until the quota runs out. today holds the usage so far today.
+func BuildCostForecast(history []DailySpend, today DailySpend, monthToDateCost float64, quota *OrganizationQuota) CostForecast {
+	forecast := CostForecast{
+		MonthToDateCost: monthToDateCost,
+		GeneratedAt:     time.Now(),
+	}
+
+	costs := make([]float64, len(history))
+	tokens := make([]float64, len(history))
+	weekdays := make([]time.Weekday, len(history))
+	for i, d := range history {
+		costs[i] = d.Cost
+		tokens[i] = float64(d.Tokens)
+		weekdays[i] = d.Date.Weekday()
+		forecast.History = append(forecast.History, ForecastPoint{
+			Date: d.Date.Format("2006-01-02"), Cost: d.Cost, Low: d.Cost, High: d.Cost, Tokens: d.Tokens,
+		})
+	}
+	costTrend := fitTrend(costs, weekdays)
+	tokenTrend := fitTrend(tokens, weekdays)
+
+	recent := history
+	if len(recent) > 7 {
+		recent = recent[len(recent)-7:]
+	}
+	if len(recent) > 0 {
+		var cost float64
+		var tokenSum int64
+		for _, d := range recent {
+			cost += d.Cost
+			tokenSum += d.Tokens
+		}
+		forecast.DailyBurnRate = cost / float64(len(recent))
+		forecast.TokenBurnRate = tokenSum / int64(len(recent))
+	}
+
+	// Month end: the rest of today plus every remaining day, each at its projected spend
+	day := truncateDay(today.Date)
+	monthEnd := time.Date(day.Year(), day.Month()+1, 1, 0, 0, 0, 0, day.Location())
+	var remaining float64
+	var days int
+	for t, d := len(history), day; d.Before(monthEnd); t, d = t+1, d.AddDate(0, 0, 1) {
+		cost := costTrend.predict(t, d.Weekday())
+		projected := ForecastPoint{Date: d.Format("2006-01-02"), Cost: cost, Tokens: int64(tokenTrend.predict(t, d.Weekday()))}
+		band := forecastZ * costTrend.sigma
+		if d.Equal(day) {
+			// Today has started; only what is left of its projection is still to come
+			cost = math.Max(0, cost-today.Cost)
+			projected.Cost = math.Max(projected.Cost, today.Cost)
+			projected.Tokens = max64(projected.Tokens, today.Tokens)
+		}
+		projected.Low = math.Max(0, projected.Cost-band)
+		projected.High = projected.Cost + band
+		forecast.Projection = append(forecast.Projection, projected)
+		remaining += cost
+		days++
+	}
+	band := forecastZ * costTrend.sigma * math.Sqrt(float64(days))
+	forecast.ProjectedMonthCost = monthToDateCost + remaining
+	forecast.ProjectedMonthCostLow = monthToDateCost + math.Max(0, remaining-band)
+	forecast.ProjectedMonthCostHigh = monthToDateCost + remaining + band
+
+	if quota != nil {
+		total, used := int64(quota.TotalQuota), int64(quota.UsedTokens)
+		forecast.QuotaTotal, forecast.QuotaUsed = &total, &used
+		if date, ok := quotaExhaustion(tokenTrend, len(history), today, total-used); ok {
+			formatted := date.Format("2006-01-02")
+			forecast.QuotaExhaustionDate = &formatted
+			forecast.QuotaExhaustedBeforeReset = date.Before(quota.ResetDate)
+		}
+	}
+
+	return forecast
+}
+
+// quotaExhaustion walks the token projection forward from today until it uses up remaining
+func quotaExhaustion(trend trendModel, start int, today DailySpend, remaining int64) (time.Time, bool) {
+	day := truncateDay(today.Date)
+	if remaining <= 0 {
+		return day, true
+	}
+
+	var used float64
+	for i := 0; i < quotaHorizonDays; i++ {
+		d := day.AddDate(0, 0, i)
+		projected := trend.predict(start+i, d.Weekday())
+		if i == 0 {
+			projected = math.Max(0, projected-float64(today.Tokens))
+		}
+		used += projected
+		if used >= float64(remaining) {
+			return d, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// trendModel is a least-squares line through deseasonalized daily values, scaled back by a
+// per-weekday factor
+type trendModel struct {
+	intercept float64
+	slope     float64
+	seasonal  [7]float64
+	sigma     float64 // Standard deviation of the daily residuals
+}
+
+func (m trendModel) predict(t int, weekday time.Weekday) float64 {
+	return math.Max(0, (m.intercept+m.slope*float64(t))*m.seasonal[weekday])
+}
+
+// fitTrend fits values (one per day, oldest first). The weekday factors are the average ratio
+// of each weekday's values to a first, unseasonal fit, so growth isn't mistaken for
+// seasonality; they need two full weeks of history and stay at 1 with less.
+func fitTrend(values []float64, weekdays []time.Weekday) trendModel {
+	m := trendModel{seasonal: [7]float64{1, 1, 1, 1, 1, 1, 1}}
+	n := len(values)
+	if n == 0 {
+		return m
+	}
+	m.fitLine(values, weekdays)
+
+	if n >= 14 {
+		var sums [7]float64
+		var counts [7]int
+		for i, v := range values {
+			if fitted := m.predict(i, weekdays[i]); fitted > 0 {
+				sums[weekdays[i]] += v / fitted
+				counts[weekdays[i]]++
+			}
+		}
+		var seasonal [7]float64
+		var total float64
+		complete := true
+		for wd := range seasonal {
+			if counts[wd] == 0 {
+				complete = false
+				break
+			}
+			seasonal[wd] = sums[wd] / float64(counts[wd])
+			total += seasonal[wd]
+		}
+		if complete && total > 0 {
+			// Normalize so the factors average 1 and only reshape the week
+			for wd := range seasonal {
+				m.seasonal[wd] = seasonal[wd] * 7 / total
+			}
+			m.fitLine(values, weekdays)
+		}
+	}
+
+	var squares float64
+	for i, v := range values {
+		residual := v - m.predict(i, weekdays[i])
+		squares += residual * residual
+	}
+	if n > 2 {
+		m.sigma = math.Sqrt(squares / float64(n-2))
+	}
+	return m
+}
+
+// fitLine regresses the values, divided by their weekday factor, on the day index
+func (m *trendModel) fitLine(values []float64, weekdays []time.Weekday) {
+	var sumT, sumY, sumTT, sumTY float64
+	for i, v := range values {
+		y := v
+		if s := m.seasonal[weekdays[i]]; s > 0 {
+			y = v / s
+		}
+		t := float64(i)
+		sumT += t
+		sumY += y
+		sumTT += t * t
+		sumTY += t * y
+	}
+	n := float64(len(values))
+	m.slope = 0
+	if denom := n*sumTT - sumT*sumT; len(values) >= 2 && denom != 0 {
+		m.slope = (n*sumTY - sumT*sumY) / denom
+	}
+	m.intercept = (sumY - m.slope*sumT) / n
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFillDailySpend(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	days := []DailySpend{{Date: from.AddDate(0, 0, 1), Cost: 2, Tokens: 20}}
+
+	filled := FillDailySpend(days, from, from.AddDate(0, 0, 3))
+	if len(filled) != 3 {
+		t.Fatalf("got %d days, want 3", len(filled))
+	}
+	if filled[0].Cost != 0 || filled[1].Cost != 2 || filled[2].Cost != 0 {
+		t.Errorf("unexpected costs: %+v", filled)
+	}
+}
+
+func TestBuildCostForecastLinearGrowth(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var history []DailySpend
+	for i := 0; i < ForecastHistoryDays; i++ {
+		history = append(history, DailySpend{Date: start.AddDate(0, 0, i), Cost: float64(i + 1), Tokens: int64(100 * (i + 1))})
+	}
+	today := DailySpend{Date: start.AddDate(0, 0, ForecastHistoryDays)} // March 29
+
+	forecast := BuildCostForecast(history, today, 406, nil)
+
+	// Days 29, 30 and 31 continue the line at 29, 30 and 31
+	if len(forecast.Projection) != 3 {
+		t.Fatalf("got %d projected days, want 3", len(forecast.Projection))
+	}
+	if math.Abs(forecast.ProjectedMonthCost-(406+29+30+31)) > 0.01 {
+		t.Errorf("projected month cost = %.2f, want 496", forecast.ProjectedMonthCost)
+	}
+	if forecast.ProjectedMonthCostLow > forecast.ProjectedMonthCost || forecast.ProjectedMonthCostHigh < forecast.ProjectedMonthCost {
+		t.Errorf("band %.2f-%.2f does not contain %.2f", forecast.ProjectedMonthCostLow, forecast.ProjectedMonthCostHigh, forecast.ProjectedMonthCost)
+	}
+	if math.Abs(forecast.DailyBurnRate-25) > 0.01 {
+		t.Errorf("daily burn rate = %.2f, want 25 (mean of days 22-28)", forecast.DailyBurnRate)
+	}
+}
+
+func TestBuildCostForecastQuotaExhaustion(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	var history []DailySpend
+	for i := 0; i < 14; i++ {
+		history = append(history, DailySpend{Date: start.AddDate(0, 0, i), Cost: 1, Tokens: 1000})
+	}
+	today := DailySpend{Date: start.AddDate(0, 0, 14), Tokens: 400}
+	quota := &OrganizationQuota{TotalQuota: 20000, UsedTokens: 15000, ResetDate: start.AddDate(0, 1, 0)}
+
+	forecast := BuildCostForecast(history, today, 14, quota)
+
+	// 600 more tokens today (March 15), then 1000 a day: the remaining 5000 run out on March 20
+	if forecast.QuotaExhaustionDate == nil || *forecast.QuotaExhaustionDate != "2026-03-20" {
+		t.Fatalf("quota exhaustion date = %s, want 2026-03-20", derefString(forecast.QuotaExhaustionDate))
+	}
+	if !forecast.QuotaExhaustedBeforeReset {
+		t.Error("expected exhaustion before the April reset")
+	}
+}
+
+func TestBuildCostForecastWithoutUsage(t *testing.T) {
+	today := DailySpend{Date: time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)}
+	quota := &OrganizationQuota{TotalQuota: 1000}
+
+	forecast := BuildCostForecast(nil, today, 0, quota)
+	if forecast.ProjectedMonthCost != 0 {
+		t.Errorf("projected month cost = %.2f, want 0", forecast.ProjectedMonthCost)
+	}
+	if forecast.QuotaExhaustionDate != nil {
+		t.Errorf("expected no exhaustion date without usage, got %s", *forecast.QuotaExhaustionDate)
+	}
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// UsageDelta is one request's usage as recorded by a gateway's usage workers, pushed to live
+// dashboards. Quotas holds the organization's quota and its ancestors' after the request was
+// counted against them.
+type UsageDelta struct {
+	OrganizationID string              `json:"organization_id"`
+	APIKeyID       string              `json:"api_key_id"`
+	ModelID        string              `json:"model_id"`
+	ResponseStatus int                 `json:"response_status"`
+	TotalTokens    int                 `json:"total_tokens"`
+	CostUSD        float64             `json:"cost_usd"`
+	Quotas         []OrganizationQuota `json:"quotas,omitempty"`
+	RecordedAt     time.Time           `json:"recorded_at"`
+}
+
+// LiveDashboardUpdate is what an organization's dashboard is pushed: its quota as last recorded
+// and the requests, tokens and spend counted against it since the previous update
+type LiveDashboardUpdate struct {
+	OrganizationID string      `json:"organization_id"`
+	Quota          *QuotaStats `json:"quota,omitempty"`
+	UsedTokens     int         `json:"used_tokens,omitempty"`
+	TotalQuota     int         `json:"total_quota,omitempty"`
+	Requests       int         `json:"requests"`
+	Errors         int         `json:"errors"`
+	Tokens         int         `json:"tokens"`
+	CostUSD        float64     `json:"cost_usd"`
+}
+
+// Add counts a delta that reaches the update's organization, either as its own usage or as a
+// sub-team's usage drawn from its quota, and reports whether it did
+func (u *LiveDashboardUpdate) Add(delta UsageDelta) bool {
+	counted := delta.OrganizationID == u.OrganizationID
+	for i := range delta.Quotas {
+		if delta.Quotas[i].OrganizationID != u.OrganizationID {
+			continue
+		}
+		counted = true
+		// Workers record out of order; the quota that has counted the most is the latest
+		if u.Quota == nil || delta.Quotas[i].UsedTokens >= u.UsedTokens {
+			stats := delta.Quotas[i].CalculateQuotaStats()
+			u.Quota = &stats
+			u.UsedTokens = delta.Quotas[i].UsedTokens
+			u.TotalQuota = delta.Quotas[i].TotalQuota
+		}
+	}
+	if !counted {
+		return false
+	}
+
+	u.Requests++
+	if delta.ResponseStatus >= 400 {
+		u.Errors++
+	}
+	u.Tokens += delta.TotalTokens
+	u.CostUSD += delta.CostUSD
+	return true
+}
+
+// Empty reports whether nothing has been counted since the update was last sent
+func (u *LiveDashboardUpdate) Empty() bool {
+	return u.Requests == 0 && u.Quota == nil
+}
+
+// Reset clears the update once it has been sent
+func (u *LiveDashboardUpdate) Reset() {
+	*u = LiveDashboardUpdate{OrganizationID: u.OrganizationID}
+}
@@ -0,0 +1,44 @@
+package models
+
+import "testing"
+
+func TestLiveDashboardUpdateAdd(t *testing.T) {
+	update := LiveDashboardUpdate{OrganizationID: "parent"}
+
+	if update.Add(UsageDelta{OrganizationID: "other", TotalTokens: 10}) {
+		t.Error("expected another organization's usage to be ignored")
+	}
+	if !update.Empty() {
+		t.Error("expected the update to stay empty")
+	}
+
+	// A sub-team's request reaches the parent through the parent's quota
+	update.Add(UsageDelta{
+		OrganizationID: "child",
+		TotalTokens:    100,
+		CostUSD:        0.5,
+		Quotas: []OrganizationQuota{
+			{OrganizationID: "child", TotalQuota: 1000, UsedTokens: 100},
+			{OrganizationID: "parent", TotalQuota: 10000, UsedTokens: 2500},
+		},
+	})
+	// Recorded out of order: the older quota doesn't replace the newer one
+	update.Add(UsageDelta{
+		OrganizationID: "parent",
+		ResponseStatus: 500,
+		TotalTokens:    20,
+		Quotas:         []OrganizationQuota{{OrganizationID: "parent", TotalQuota: 10000, UsedTokens: 2400}},
+	})
+
+	if update.Requests != 2 || update.Errors != 1 || update.Tokens != 120 || update.CostUSD != 0.5 {
+		t.Errorf("counters = %+v, want 2 requests, 1 error, 120 tokens, $0.50", update)
+	}
+	if update.Quota == nil || update.UsedTokens != 2500 || update.Quota.PercentUsed != "25.0%" {
+		t.Errorf("quota = %+v (%d used), want the parent's latest at 2500 tokens", update.Quota, update.UsedTokens)
+	}
+
+	update.Reset()
+	if !update.Empty() || update.OrganizationID != "parent" {
+		t.Errorf("after Reset = %+v, want empty for the same organization", update)
+	}
+}
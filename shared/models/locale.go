@@ -0,0 +1,12 @@
+package models
+
+// UpdateUserLocaleRequest sets the current user's UI language; empty follows the organization
+type UpdateUserLocaleRequest struct {
+	Locale string `json:"locale"`
+}
+
+// UpdateOrganizationLocaleRequest sets an organization's default language for members and emails
+type UpdateOrganizationLocaleRequest struct {
+	OrganizationID string `json:"organization_id" binding:"required"`
+	Locale         string `json:"locale"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Sign-in methods
+const (
+	LoginMethodLocal = "local"
+	LoginMethodAzure = "azure"
+	// LoginMethodBreakGlass is the emergency account for when Azure AD is down
+	LoginMethodBreakGlass = "break_glass"
+)
+
+// Anomalies flagged on a successful sign-in
+const (
+	// SignInAnomalyNewCountry is a sign-in from a country the account never signed in from
+	SignInAnomalyNewCountry = "new_country"
+	// SignInAnomalyFailuresThenSuccess is a sign-in right after a run of failed attempts
+	SignInAnomalyFailuresThenSuccess = "failures_then_success"
+)
+
+// LoginEvent records a sign-in attempt to the admin UI
+type LoginEvent struct {
+	ID            string    `json:"id" db:"id"`
+	UserID        *string   `json:"user_id" db:"user_id"`
+	Identity      string    `json:"identity" db:"identity"` // Username or email the attempt was for
+	Method        string    `json:"method" db:"method"`     // 'local', 'azure'
+	Success       bool      `json:"success" db:"success"`
+	FailureReason string    `json:"failure_reason,omitempty" db:"failure_reason"`
+	IPAddress     string    `json:"ip_address" db:"ip_address"`
+	UserAgent     string    `json:"user_agent" db:"user_agent"`
+	Country       string    `json:"country,omitempty" db:"country"` // ISO 3166 code from the proxy's header, when configured
+	Anomalies     []string  `json:"anomalies" db:"anomalies"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// SignInAnomalies returns what is unusual about a successful sign-in from country, given the
+// countries the account signed in from before and the failed attempts since its last sign-in.
+// A first sign-in, or one without a known country, is never from a new country.
+func SignInAnomalies(country string, knownCountries []string, recentFailures, failureThreshold int) []string {
+	anomalies := []string{}
+
+	if country != "" && len(knownCountries) > 0 {
+		known := false
+		for _, c := range knownCountries {
+			if strings.EqualFold(c, country) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			anomalies = append(anomalies, SignInAnomalyNewCountry)
+		}
+	}
+
+	if failureThreshold > 0 && recentFailures >= failureThreshold {
+		anomalies = append(anomalies, SignInAnomalyFailuresThenSuccess)
+	}
+
+	return anomalies
+}
+
+// NormalizeCountry returns the upper-case two-letter country code from a geolocation header,
+// or "" for values that don't name a country, like Cloudflare's XX (unknown) and T1 (Tor)
+func NormalizeCountry(value string) string {
+	country := strings.ToUpper(strings.TrimSpace(value))
+	if len(country) != 2 || country == "XX" || country == "T1" {
+		return ""
+	}
+	for _, r := range country {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+	}
+	return country
+}
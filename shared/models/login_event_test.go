@@ -0,0 +1,37 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSignInAnomalies(t *testing.T) {
+	tests := []struct {
+		name           string
+		country        string
+		known          []string
+		recentFailures int
+		want           []string
+	}{
+		{"usual country", "US", []string{"US", "CA"}, 0, []string{}},
+		{"first sign-in", "US", nil, 0, []string{}},
+		{"no country", "", []string{"US"}, 0, []string{}},
+		{"new country", "RU", []string{"us"}, 0, []string{SignInAnomalyNewCountry}},
+		{"failures below threshold", "US", []string{"US"}, 4, []string{}},
+		{"failures then success", "US", []string{"US"}, 5, []string{SignInAnomalyFailuresThenSuccess}},
+		{"both", "BR", []string{"US"}, 9, []string{SignInAnomalyNewCountry, SignInAnomalyFailuresThenSuccess}},
+	}
+	for _, tt := range tests {
+		if got := SignInAnomalies(tt.country, tt.known, tt.recentFailures, 5); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: SignInAnomalies() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCountry(t *testing.T) {
+	for value, want := range map[string]string{"us": "US", " DE ": "DE", "XX": "", "T1": "", "USA": "", "1A": "", "": ""} {
+		if got := NormalizeCountry(value); got != want {
+			t.Errorf("NormalizeCountry(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
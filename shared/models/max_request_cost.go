@@ -0,0 +1,30 @@
+package models
+
+import "fmt"
+
+// UpdateMaxRequestCostRequest sets an organization's ceiling on a request's worst-case cost in
+// US dollars; null removes it
+type UpdateMaxRequestCostRequest struct {
+	MaxRequestCost *float64 `json:"max_request_cost"`
+}
+
+// Validate checks the ceiling is positive
+func (r UpdateMaxRequestCostRequest) Validate() error {
+	if r.MaxRequestCost != nil && *r.MaxRequestCost <= 0 {
+		return fmt.Errorf("max_request_cost must be positive, or null for no ceiling")
+	}
+	return nil
+}
+
+// WorstCaseCompletionTokens returns the most completion tokens a request can be billed for: its
+// max_tokens, else what's left of the model's context window after the prompt. ok is false when
+// neither bounds it.
+func WorstCaseCompletionTokens(maxTokens, promptTokens int, contextWindow *int) (tokens int, ok bool) {
+	if maxTokens > 0 {
+		return maxTokens, true
+	}
+	if contextWindow != nil {
+		return max(*contextWindow-promptTokens, 0), true
+	}
+	return 0, false
+}
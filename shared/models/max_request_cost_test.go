@@ -0,0 +1,32 @@
+package models
+
+import "testing"
+
+func TestUpdateMaxRequestCostRequestValidate(t *testing.T) {
+	ceiling, zero := 0.5, 0.0
+	if err := (UpdateMaxRequestCostRequest{MaxRequestCost: &ceiling}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (UpdateMaxRequestCostRequest{}).Validate(); err != nil {
+		t.Errorf("removing the ceiling should be allowed, got %v", err)
+	}
+	if err := (UpdateMaxRequestCostRequest{MaxRequestCost: &zero}).Validate(); err == nil {
+		t.Error("expected a zero ceiling to be rejected")
+	}
+}
+
+func TestWorstCaseCompletionTokens(t *testing.T) {
+	window := 8192
+	if tokens, ok := WorstCaseCompletionTokens(1000, 500, &window); !ok || tokens != 1000 {
+		t.Errorf("max_tokens should bound the completion, got %d, %v", tokens, ok)
+	}
+	if tokens, ok := WorstCaseCompletionTokens(0, 500, &window); !ok || tokens != 7692 {
+		t.Errorf("the context window should bound the completion, got %d, %v", tokens, ok)
+	}
+	if tokens, ok := WorstCaseCompletionTokens(0, 9000, &window); !ok || tokens != 0 {
+		t.Errorf("a prompt over the window leaves no completion, got %d, %v", tokens, ok)
+	}
+	if _, ok := WorstCaseCompletionTokens(0, 500, nil); ok {
+		t.Error("expected no bound without max_tokens or a context window")
+	}
+}
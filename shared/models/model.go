@@ -1,9 +1,33 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 )
 
+const (
+	// ProviderBedrock models are AWS Bedrock upstreams, signed with SigV4 instead of a bearer token
+	ProviderBedrock = "bedrock"
+	// ProviderVertex models are Gemini models on Google Vertex AI, called with a service account
+	// token and translated from the chat completions shape
+	ProviderVertex = "vertex"
+	// ProviderOllama and ProviderVLLM are self-hosted, OpenAI-compatible servers. ProviderVLLM
+	// also covers llama.cpp's server, which speaks the same dialect.
+	ProviderOllama = "ollama"
+	ProviderVLLM   = "vllm"
+)
+
+// IsSelfHostedProvider reports whether models of the provider run on the organization's own
+// hardware, so they cost nothing unless priced explicitly and may not report usage
+func IsSelfHostedProvider(provider string) bool {
+	return provider == ProviderOllama || provider == ProviderVLLM
+}
+
+var gcpLocationPattern = regexp.MustCompile(`^(global|[a-z]+-[a-z]+[0-9]+)$`)
+
 type Model struct {
 	ID                string         `json:"id" db:"id"`
 	Name              string         `json:"name" db:"name"`
@@ -12,49 +36,218 @@ type Model struct {
 	ModelID           string         `json:"model_id" db:"model_id"`
 	APIEndpoint       *string        `json:"api_endpoint" db:"api_endpoint"`
 	APIToken          *string        `json:"api_token" db:"api_token"`
+	AWSRegion         *string        `json:"aws_region" db:"aws_region"`
+	AWSAccessKeyID    *string        `json:"aws_access_key_id" db:"aws_access_key_id"`
+	AWSSecretKey      *string        `json:"aws_secret_access_key" db:"aws_secret_access_key"` // Encrypted
+	AWSRoleARN        *string        `json:"aws_role_arn" db:"aws_role_arn"`
+	GCPLocation       *string        `json:"gcp_location" db:"gcp_location"`
+	GCPServiceAccount *string        `json:"gcp_service_account" db:"gcp_service_account"` // Encrypted
 	InputCostPer1M    *float64       `json:"input_cost_per_1m" db:"input_cost_per_1m"`
 	OutputCostPer1M   *float64       `json:"output_cost_per_1m" db:"output_cost_per_1m"`
 	MaxRetries        *int           `json:"max_retries" db:"max_retries"`
 	TimeoutSeconds    *int           `json:"timeout_seconds" db:"timeout_seconds"`
 	RetryDelayMs      *int           `json:"retry_delay_ms" db:"retry_delay_ms"`
 	BackoffMultiplier *float64       `json:"backoff_multiplier" db:"backoff_multiplier"`
+	MaxRequestBytes   *int           `json:"max_request_bytes" db:"max_request_bytes"`
+	Metadata          ModelMetadata  `json:"metadata" db:"metadata"`
+	Transport         ModelTransport `json:"transport" db:"transport"`
 	IsActive          bool           `json:"active" db:"is_active"`
 	CreatedAt         time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at" db:"updated_at"`
 	Organizations     []Organization `json:"organizations,omitempty"`
+	// Organizations blocked from access they would inherit from a parent organization
+	DeniedOrganizations []Organization `json:"denied_organizations,omitempty"`
+}
+
+// MarshalJSON masks the provider API token, AWS secret key, GCP service account and proxy
+// password so they never reach the browser
+func (m Model) MarshalJSON() ([]byte, error) {
+	type Alias Model
+	var apiToken, awsSecretKey, gcpServiceAccount *string
+	if m.APIToken != nil {
+		masked := MaskSecret(*m.APIToken)
+		apiToken = &masked
+	}
+	if m.AWSSecretKey != nil && *m.AWSSecretKey != "" {
+		// Stored encrypted, so there are no meaningful trailing characters to show
+		masked := maskedSecretPrefix
+		awsSecretKey = &masked
+	}
+	if m.GCPServiceAccount != nil && *m.GCPServiceAccount != "" {
+		masked := maskedSecretPrefix
+		gcpServiceAccount = &masked
+	}
+	return json.Marshal(&struct {
+		APIToken          *string        `json:"api_token"`
+		AWSSecretKey      *string        `json:"aws_secret_access_key"`
+		GCPServiceAccount *string        `json:"gcp_service_account"`
+		Transport         ModelTransport `json:"transport"`
+		*Alias
+	}{
+		APIToken:          apiToken,
+		AWSSecretKey:      awsSecretKey,
+		GCPServiceAccount: gcpServiceAccount,
+		Transport:         m.Transport.Redacted(),
+		Alias:             (*Alias)(&m),
+	})
 }
 
 type CreateModelRequest struct {
-	Name              string   `json:"name" binding:"required"`
-	Description       *string  `json:"description"`
-	Provider          string   `json:"provider" binding:"required"`
-	ModelID           string   `json:"model_id" binding:"required"`
-	APIEndpoint       *string  `json:"api_endpoint"`
-	APIToken          *string  `json:"api_token"`
-	InputCostPer1M    *string  `json:"input_cost_per_1m"`
-	OutputCostPer1M   *string  `json:"output_cost_per_1m"`
-	MaxRetries        *string  `json:"max_retries"`
-	TimeoutSeconds    *string  `json:"timeout_seconds"`
-	RetryDelayMs      *string  `json:"retry_delay_ms"`
-	BackoffMultiplier *string  `json:"backoff_multiplier"`
-	OrgIDs            []string `json:"organization_ids"`
+	Name              string          `json:"name" binding:"required"`
+	Description       *string         `json:"description"`
+	Provider          string          `json:"provider" binding:"required"`
+	ModelID           string          `json:"model_id" binding:"required"`
+	APIEndpoint       *string         `json:"api_endpoint"`
+	APIToken          *string         `json:"api_token"`
+	AWSRegion         *string         `json:"aws_region"`
+	AWSAccessKeyID    *string         `json:"aws_access_key_id"`
+	AWSSecretKey      *string         `json:"aws_secret_access_key"`
+	AWSRoleARN        *string         `json:"aws_role_arn"`
+	GCPLocation       *string         `json:"gcp_location"`
+	GCPServiceAccount *string         `json:"gcp_service_account"`
+	InputCostPer1M    *string         `json:"input_cost_per_1m"`
+	OutputCostPer1M   *string         `json:"output_cost_per_1m"`
+	MaxRetries        *string         `json:"max_retries"`
+	TimeoutSeconds    *string         `json:"timeout_seconds"`
+	RetryDelayMs      *string         `json:"retry_delay_ms"`
+	BackoffMultiplier *string         `json:"backoff_multiplier"`
+	MaxRequestBytes   *string         `json:"max_request_bytes"`
+	Metadata          *ModelMetadata  `json:"metadata"`
+	Transport         *ModelTransport `json:"transport"`
+	OrgIDs            []string        `json:"organization_ids"`
 }
 
 type UpdateModelRequest struct {
-	Name              *string  `json:"name"`
-	Description       *string  `json:"description"`
-	Provider          *string  `json:"provider"`
-	ModelID           *string  `json:"model_id"`
-	APIEndpoint       *string  `json:"api_endpoint"`
-	APIToken          *string  `json:"api_token"`
-	InputCostPer1M    *string  `json:"input_cost_per_1m"`
-	OutputCostPer1M   *string  `json:"output_cost_per_1m"`
-	MaxRetries        *string  `json:"max_retries"`
-	TimeoutSeconds    *string  `json:"timeout_seconds"`
-	RetryDelayMs      *string  `json:"retry_delay_ms"`
-	BackoffMultiplier *string  `json:"backoff_multiplier"`
-	IsActive          *bool    `json:"is_active"`
-	OrgIDs            []string `json:"organization_ids"`
+	Name              *string         `json:"name"`
+	Description       *string         `json:"description"`
+	Provider          *string         `json:"provider"`
+	ModelID           *string         `json:"model_id"`
+	APIEndpoint       *string         `json:"api_endpoint"`
+	APIToken          *string         `json:"api_token"`
+	AWSRegion         *string         `json:"aws_region"`
+	AWSAccessKeyID    *string         `json:"aws_access_key_id"`
+	AWSSecretKey      *string         `json:"aws_secret_access_key"`
+	AWSRoleARN        *string         `json:"aws_role_arn"`
+	GCPLocation       *string         `json:"gcp_location"`
+	GCPServiceAccount *string         `json:"gcp_service_account"`
+	InputCostPer1M    *string         `json:"input_cost_per_1m"`
+	OutputCostPer1M   *string         `json:"output_cost_per_1m"`
+	MaxRetries        *string         `json:"max_retries"`
+	TimeoutSeconds    *string         `json:"timeout_seconds"`
+	RetryDelayMs      *string         `json:"retry_delay_ms"`
+	BackoffMultiplier *string         `json:"backoff_multiplier"`
+	MaxRequestBytes   *string         `json:"max_request_bytes"`
+	Metadata          *ModelMetadata  `json:"metadata"`  // Replaces the metadata; nil leaves it
+	Transport         *ModelTransport `json:"transport"` // Replaces the transport options; nil leaves them
+	IsActive          *bool           `json:"is_active"`
+	OrgIDs            []string        `json:"organization_ids"`
+}
+
+// ApplyProviderDefaults prices self-hosted models at zero when no costs are given
+func (r *CreateModelRequest) ApplyProviderDefaults() {
+	if !IsSelfHostedProvider(r.Provider) {
+		return
+	}
+	zero := "0"
+	if r.InputCostPer1M == nil || *r.InputCostPer1M == "" {
+		r.InputCostPer1M = &zero
+	}
+	if r.OutputCostPer1M == nil || *r.OutputCostPer1M == "" {
+		r.OutputCostPer1M = &zero
+	}
+}
+
+// Validate checks Bedrock models have a region and consistent AWS credentials, Vertex models a
+// location and service account, and any metadata and transport options are well formed
+func (r *CreateModelRequest) Validate() error {
+	if r.Metadata != nil {
+		if err := r.Metadata.Normalize(); err != nil {
+			return err
+		}
+	}
+	if r.Transport != nil {
+		if err := r.Transport.Normalize(); err != nil {
+			return err
+		}
+	}
+	switch r.Provider {
+	case ProviderBedrock:
+		if r.AWSRegion == nil || strings.TrimSpace(*r.AWSRegion) == "" {
+			return fmt.Errorf("aws_region is required for Bedrock models")
+		}
+		return validateAWSCredentials(r.AWSAccessKeyID, r.AWSSecretKey, r.AWSRoleARN)
+	case ProviderVertex:
+		if r.GCPServiceAccount == nil || strings.TrimSpace(*r.GCPServiceAccount) == "" {
+			return fmt.Errorf("gcp_service_account is required for Vertex AI models")
+		}
+		return validateGCPLocation(r.GCPLocation)
+	}
+	return nil
+}
+
+// Validate checks any AWS or GCP settings, metadata and transport options being changed are
+// consistent
+func (r *UpdateModelRequest) Validate() error {
+	if r.Metadata != nil {
+		if err := r.Metadata.Normalize(); err != nil {
+			return err
+		}
+	}
+	if r.Transport != nil {
+		if err := r.Transport.Normalize(); err != nil {
+			return err
+		}
+	}
+	if r.Provider != nil && *r.Provider == ProviderBedrock && r.AWSRegion != nil && strings.TrimSpace(*r.AWSRegion) == "" {
+		return fmt.Errorf("aws_region is required for Bedrock models")
+	}
+	if r.Provider != nil && *r.Provider == ProviderVertex {
+		if r.GCPLocation != nil {
+			if err := validateGCPLocation(r.GCPLocation); err != nil {
+				return err
+			}
+		}
+		if r.GCPServiceAccount != nil && strings.TrimSpace(*r.GCPServiceAccount) == "" {
+			return fmt.Errorf("gcp_service_account is required for Vertex AI models")
+		}
+	}
+	secret := r.AWSSecretKey
+	if secret != nil && IsMaskedSecret(*secret) {
+		// Unchanged secret; only the other fields are being checked
+		secret = nil
+		if r.AWSAccessKeyID != nil && *r.AWSAccessKeyID != "" {
+			return validateRoleARN(r.AWSRoleARN)
+		}
+	}
+	return validateAWSCredentials(r.AWSAccessKeyID, secret, r.AWSRoleARN)
+}
+
+// validateAWSCredentials requires the access key ID and secret together; neither means the
+// gateway's environment credentials are used
+func validateAWSCredentials(accessKeyID, secretKey, roleARN *string) error {
+	hasKey := accessKeyID != nil && *accessKeyID != ""
+	hasSecret := secretKey != nil && *secretKey != ""
+	if hasKey != hasSecret {
+		return fmt.Errorf("aws_access_key_id and aws_secret_access_key must be given together")
+	}
+	return validateRoleARN(roleARN)
+}
+
+func validateGCPLocation(location *string) error {
+	if location == nil || !gcpLocationPattern.MatchString(*location) {
+		return fmt.Errorf("gcp_location must be a Vertex AI region such as us-central1, or global")
+	}
+	return nil
+}
+
+func validateRoleARN(roleARN *string) error {
+	if roleARN == nil || *roleARN == "" {
+		return nil
+	}
+	if !strings.HasPrefix(*roleARN, "arn:aws") || !strings.Contains(*roleARN, ":iam::") || !strings.Contains(*roleARN, ":role/") {
+		return fmt.Errorf("aws_role_arn must be an IAM role ARN, e.g. arn:aws:iam::123456789012:role/bedrock-invoke")
+	}
+	return nil
 }
 
 type ModelOrganizationAccess struct {
@@ -66,5 +259,6 @@ type ModelOrganizationAccess struct {
 
 // ModelsResponse represents the JSON response for the models API
 type ModelsResponse struct {
-	Models []Model `json:"models"`
+	Models      []Model `json:"models"`
+	CostsHidden bool    `json:"costs_hidden"` // Prices are left out for a caller without costs:read
 }
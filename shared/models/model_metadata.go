@@ -0,0 +1,167 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxModelTags caps the free-form tags on a model
+const maxModelTags = 20
+
+// maxUnsupportedParams caps the request parameters a model can list as unsupported
+const maxUnsupportedParams = 50
+
+var modelTagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,49}$`)
+
+var requestParamPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// requiredRequestParams can't be listed as unsupported; no request works without them
+var requiredRequestParams = map[string]bool{"model": true, "messages": true, "prompt": true, "input": true}
+
+// Context strategies: what the gateway does with a chat conversation too long for the model's
+// context window
+const (
+	ContextStrategyReject    = "reject"
+	ContextStrategyTruncate  = "truncate"  // Drop the oldest messages, keeping system messages and the latest one
+	ContextStrategySummarize = "summarize" // Replace the oldest messages with a summary written by a cheaper model
+)
+
+// IsValidContextStrategy reports whether s is a known context strategy
+func IsValidContextStrategy(s string) bool {
+	return s == ContextStrategyReject || s == ContextStrategyTruncate || s == ContextStrategySummarize
+}
+
+// Unsupported parameter actions: what the gateway does with a request sending parameters the
+// model's provider doesn't accept
+const (
+	UnsupportedParamsReject = "reject" // Answer 400 naming the parameters
+	UnsupportedParamsDrop   = "drop"   // Strip them and send the rest
+)
+
+// IsValidUnsupportedParamsAction reports whether s is a known unsupported parameter action
+func IsValidUnsupportedParamsAction(s string) bool {
+	return s == UnsupportedParamsReject || s == UnsupportedParamsDrop
+}
+
+// ModelMetadata describes a model to clients choosing one and tells the gateway which requests
+// it can serve. Capabilities left unset are not enforced.
+type ModelMetadata struct {
+	ContextWindow  *int     `json:"context_window,omitempty"` // Prompt plus completion tokens
+	SupportsTools  *bool    `json:"supports_tools,omitempty"`
+	SupportsVision *bool    `json:"supports_vision,omitempty"`
+	Region         string   `json:"region,omitempty"` // Where the provider serves the model, e.g. eu-west-1
+	Tags           []string `json:"tags,omitempty"`
+	// ContextStrategy handles conversations longer than ContextWindow; empty uses the gateway's
+	// GATEWAY_CONTEXT_STRATEGY
+	ContextStrategy string `json:"context_strategy,omitempty"`
+	// SummaryModel is the model ID that writes summaries for the summarize strategy; empty uses
+	// GATEWAY_CONTEXT_SUMMARY_MODEL
+	SummaryModel string `json:"summary_model,omitempty"`
+	// SemanticCache serves cached completions to chat prompts similar to earlier ones when the
+	// gateway has GATEWAY_SEMANTIC_CACHE_MODEL set
+	SemanticCache bool `json:"semantic_cache,omitempty"`
+	// UnsupportedParams are top-level request parameters the model's provider rejects, such as
+	// logprobs, so requests sending them fail at the gateway rather than upstream
+	UnsupportedParams []string `json:"unsupported_params,omitempty"`
+	// UnsupportedParamsAction handles requests sending UnsupportedParams; empty uses the
+	// gateway's GATEWAY_UNSUPPORTED_PARAMS_ACTION
+	UnsupportedParamsAction string `json:"unsupported_params_action,omitempty"`
+}
+
+// Normalize trims the region, lowercases and de-duplicates tags, and checks the values are usable
+func (m *ModelMetadata) Normalize() error {
+	if m.ContextWindow != nil && *m.ContextWindow <= 0 {
+		return fmt.Errorf("metadata.context_window must be a positive number of tokens")
+	}
+
+	if m.ContextStrategy != "" && !IsValidContextStrategy(m.ContextStrategy) {
+		return fmt.Errorf("metadata.context_strategy must be reject, truncate or summarize")
+	}
+	m.SummaryModel = strings.TrimSpace(m.SummaryModel)
+
+	if m.UnsupportedParamsAction != "" && !IsValidUnsupportedParamsAction(m.UnsupportedParamsAction) {
+		return fmt.Errorf("metadata.unsupported_params_action must be reject or drop")
+	}
+	params := []string{}
+	seenParams := map[string]bool{}
+	for _, param := range m.UnsupportedParams {
+		param = strings.TrimSpace(param)
+		if param == "" || seenParams[param] {
+			continue
+		}
+		if !requestParamPattern.MatchString(param) {
+			return fmt.Errorf("metadata unsupported parameter %q must be a lowercase request field name", param)
+		}
+		if requiredRequestParams[param] {
+			return fmt.Errorf("metadata unsupported parameter %q is required by every request", param)
+		}
+		seenParams[param] = true
+		params = append(params, param)
+	}
+	if len(params) > maxUnsupportedParams {
+		return fmt.Errorf("a model can list at most %d unsupported parameters", maxUnsupportedParams)
+	}
+	m.UnsupportedParams = params
+
+	m.Region = strings.TrimSpace(m.Region)
+	if len(m.Region) > 50 {
+		return fmt.Errorf("metadata.region must be at most 50 characters")
+	}
+
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, tag := range m.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if !modelTagPattern.MatchString(tag) {
+			return fmt.Errorf("metadata tag %q must be 1-50 lowercase letters, digits, '-', '_', '.' or ':'", tag)
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	if len(tags) > maxModelTags {
+		return fmt.Errorf("a model can have at most %d metadata tags", maxModelTags)
+	}
+	m.Tags = tags
+	return nil
+}
+
+// UnsupportedParamsIn returns the parameters of a request body the model's provider doesn't
+// accept, sorted
+func (m ModelMetadata) UnsupportedParamsIn(body map[string]json.RawMessage) []string {
+	var found []string
+	for _, param := range m.UnsupportedParams {
+		if _, ok := body[param]; ok {
+			found = append(found, param)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// ModelRequestNeeds is what a request asks of the model serving it
+type ModelRequestNeeds struct {
+	Tools     bool // Sends tools or functions
+	Vision    bool // Sends image content
+	MaxTokens int  // Completion tokens asked for; 0 when not given
+}
+
+// CheckRequest returns an error naming the first thing the request needs that the model is known
+// not to offer
+func (m ModelMetadata) CheckRequest(modelID string, needs ModelRequestNeeds) error {
+	if needs.Tools && m.SupportsTools != nil && !*m.SupportsTools {
+		return fmt.Errorf("model %s does not support tools or function calling", modelID)
+	}
+	if needs.Vision && m.SupportsVision != nil && !*m.SupportsVision {
+		return fmt.Errorf("model %s does not support image inputs", modelID)
+	}
+	if m.ContextWindow != nil && needs.MaxTokens > *m.ContextWindow {
+		return fmt.Errorf("max_tokens of %d exceeds the %d token context window of model %s", needs.MaxTokens, *m.ContextWindow, modelID)
+	}
+	return nil
+}
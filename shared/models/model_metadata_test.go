@@ -0,0 +1,84 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestModelMetadataNormalize(t *testing.T) {
+	m := ModelMetadata{Region: "  eu-west-1 ", Tags: []string{"Fast", "fast", " ", "eu"}}
+	if err := m.Normalize(); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if m.Region != "eu-west-1" {
+		t.Errorf("Region = %q, want trimmed", m.Region)
+	}
+	if want := []string{"fast", "eu"}; !reflect.DeepEqual(m.Tags, want) {
+		t.Errorf("Tags = %v, want %v", m.Tags, want)
+	}
+
+	zero := 0
+	if err := (&ModelMetadata{ContextWindow: &zero}).Normalize(); err == nil {
+		t.Error("expected a zero context window to be rejected")
+	}
+	if err := (&ModelMetadata{Tags: []string{"no spaces"}}).Normalize(); err == nil {
+		t.Error("expected a tag with a space to be rejected")
+	}
+	if err := (&ModelMetadata{ContextStrategy: "compress"}).Normalize(); err == nil {
+		t.Error("expected an unknown context strategy to be rejected")
+	}
+
+	params := ModelMetadata{UnsupportedParams: []string{" logprobs", "logprobs", "", "top_logprobs"}}
+	if err := params.Normalize(); err != nil {
+		t.Fatalf("Normalize() error = %v", err)
+	}
+	if want := []string{"logprobs", "top_logprobs"}; !reflect.DeepEqual(params.UnsupportedParams, want) {
+		t.Errorf("UnsupportedParams = %v, want %v", params.UnsupportedParams, want)
+	}
+	if err := (&ModelMetadata{UnsupportedParams: []string{"messages"}}).Normalize(); err == nil {
+		t.Error("expected a required parameter to be rejected")
+	}
+	if err := (&ModelMetadata{UnsupportedParams: []string{"Log Probs"}}).Normalize(); err == nil {
+		t.Error("expected a malformed parameter name to be rejected")
+	}
+	if err := (&ModelMetadata{UnsupportedParamsAction: "ignore"}).Normalize(); err == nil {
+		t.Error("expected an unknown unsupported parameter action to be rejected")
+	}
+}
+
+func TestModelMetadataUnsupportedParamsIn(t *testing.T) {
+	m := ModelMetadata{UnsupportedParams: []string{"top_logprobs", "logprobs", "seed"}}
+	body := map[string]json.RawMessage{
+		"model":        json.RawMessage(`"m"`),
+		"logprobs":     json.RawMessage(`true`),
+		"top_logprobs": json.RawMessage(`2`),
+	}
+	if got, want := m.UnsupportedParamsIn(body), []string{"logprobs", "top_logprobs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnsupportedParamsIn() = %v, want %v", got, want)
+	}
+	if got := (ModelMetadata{}).UnsupportedParamsIn(body); len(got) != 0 {
+		t.Errorf("expected no unsupported parameters without a list, got %v", got)
+	}
+}
+
+func TestModelMetadataCheckRequest(t *testing.T) {
+	yes, no := true, false
+	window := 8192
+
+	unknown := ModelMetadata{}
+	if err := unknown.CheckRequest("m", ModelRequestNeeds{Tools: true, Vision: true, MaxTokens: 1 << 20}); err != nil {
+		t.Errorf("unset capabilities should not be enforced, got %v", err)
+	}
+
+	m := ModelMetadata{SupportsTools: &no, SupportsVision: &yes, ContextWindow: &window}
+	if err := m.CheckRequest("m", ModelRequestNeeds{Tools: true}); err == nil {
+		t.Error("expected tools to be rejected")
+	}
+	if err := m.CheckRequest("m", ModelRequestNeeds{Vision: true, MaxTokens: 4096}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := m.CheckRequest("m", ModelRequestNeeds{MaxTokens: 10000}); err == nil {
+		t.Error("expected max_tokens beyond the context window to be rejected")
+	}
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ModelPrice is one of a model's prices, in effect from EffectiveFrom until EffectiveTo (or
+// until a later price starts). Windows multiply the price at times of day, in Timezone, for
+// peak and off-peak chargeback rates. Without any prices a model is costed at its
+// input_cost_per_1m and output_cost_per_1m.
+type ModelPrice struct {
+	ID              string        `json:"id" db:"id"`
+	ModelID         string        `json:"model_id" db:"model_id"`
+	InputCostPer1M  float64       `json:"input_cost_per_1m" db:"input_cost_per_1m"`
+	OutputCostPer1M float64       `json:"output_cost_per_1m" db:"output_cost_per_1m"`
+	EffectiveFrom   time.Time     `json:"effective_from" db:"effective_from"`
+	EffectiveTo     *time.Time    `json:"effective_to" db:"effective_to"`
+	Timezone        string        `json:"timezone" db:"timezone"`
+	Windows         []PriceWindow `json:"windows" db:"windows"`
+	CreatedBy       *string       `json:"created_by" db:"created_by"`
+	CreatedAt       time.Time     `json:"created_at" db:"created_at"`
+}
+
+// PriceWindow multiplies a price from Start until End each day, as "HH:MM". A window whose end
+// is before its start runs past midnight.
+type PriceWindow struct {
+	Start      string  `json:"start"`
+	End        string  `json:"end"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// minuteOfDay parses "HH:MM"
+func minuteOfDay(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether the window covers the minute of the day
+func (w PriceWindow) contains(minute int) bool {
+	start, err := minuteOfDay(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := minuteOfDay(w.End)
+	if err != nil {
+		return false
+	}
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// Covers reports whether the price is in effect at t
+func (p *ModelPrice) Covers(t time.Time) bool {
+	return !t.Before(p.EffectiveFrom) && (p.EffectiveTo == nil || t.Before(*p.EffectiveTo))
+}
+
+// MultiplierAt returns the multiplier of the first window covering t, or 1
+func (p *ModelPrice) MultiplierAt(t time.Time) float64 {
+	if len(p.Windows) == 0 {
+		return 1
+	}
+	if loc, err := time.LoadLocation(p.Timezone); err == nil {
+		t = t.In(loc)
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range p.Windows {
+		if w.contains(minute) {
+			return w.Multiplier
+		}
+	}
+	return 1
+}
+
+// Cost prices prompt and completion tokens used at t
+func (p *ModelPrice) Cost(promptTokens, completionTokens int, at time.Time) float64 {
+	base := (float64(promptTokens)*p.InputCostPer1M + float64(completionTokens)*p.OutputCostPer1M) / 1000000.0
+	return base * p.MultiplierAt(at)
+}
+
+// PriceAt returns the price in effect at t, the latest to start when they overlap, or nil
+func PriceAt(prices []ModelPrice, t time.Time) *ModelPrice {
+	var current *ModelPrice
+	for i := range prices {
+		if prices[i].Covers(t) && (current == nil || prices[i].EffectiveFrom.After(current.EffectiveFrom)) {
+			current = &prices[i]
+		}
+	}
+	return current
+}
+
+// CreateModelPriceRequest adds a price to a model. EffectiveFrom defaults to now, and Timezone
+// to UTC.
+type CreateModelPriceRequest struct {
+	InputCostPer1M  float64       `json:"input_cost_per_1m"`
+	OutputCostPer1M float64       `json:"output_cost_per_1m"`
+	EffectiveFrom   *time.Time    `json:"effective_from"`
+	EffectiveTo     *time.Time    `json:"effective_to"`
+	Timezone        string        `json:"timezone"`
+	Windows         []PriceWindow `json:"windows"`
+}
+
+// Validate checks the costs aren't negative, the range isn't empty, the timezone exists and
+// every window has valid times and a positive multiplier. It fills in the defaults.
+func (r *CreateModelPriceRequest) Validate(now time.Time) error {
+	if r.InputCostPer1M < 0 || r.OutputCostPer1M < 0 {
+		return fmt.Errorf("costs can't be negative")
+	}
+	if r.EffectiveFrom == nil {
+		r.EffectiveFrom = &now
+	}
+	if r.EffectiveTo != nil && !r.EffectiveTo.After(*r.EffectiveFrom) {
+		return fmt.Errorf("effective_to must be after effective_from")
+	}
+	r.Timezone = strings.TrimSpace(r.Timezone)
+	if r.Timezone == "" {
+		r.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", r.Timezone)
+	}
+	for _, w := range r.Windows {
+		if _, err := minuteOfDay(w.Start); err != nil {
+			return err
+		}
+		if _, err := minuteOfDay(w.End); err != nil {
+			return err
+		}
+		if w.Start == w.End {
+			return fmt.Errorf("window %s-%s is empty", w.Start, w.End)
+		}
+		if w.Multiplier <= 0 {
+			return fmt.Errorf("window %s-%s needs a positive multiplier", w.Start, w.End)
+		}
+	}
+	if r.Windows == nil {
+		r.Windows = []PriceWindow{}
+	}
+	return nil
+}
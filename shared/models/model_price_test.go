@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPriceAt(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	promoEnd := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	prices := []ModelPrice{
+		{ID: "list", EffectiveFrom: jan},
+		{ID: "promo", EffectiveFrom: mar, EffectiveTo: &promoEnd},
+	}
+
+	cases := map[time.Time]string{
+		jan.Add(-time.Hour):       "",
+		jan:                       "list",
+		mar.Add(time.Hour):        "promo",
+		promoEnd:                  "list",
+		promoEnd.AddDate(1, 0, 0): "list",
+	}
+	for at, want := range cases {
+		got := PriceAt(prices, at)
+		if (got == nil && want != "") || (got != nil && got.ID != want) {
+			t.Errorf("PriceAt(%v) = %v, want %q", at, got, want)
+		}
+	}
+}
+
+func TestModelPriceCostWindows(t *testing.T) {
+	price := ModelPrice{
+		InputCostPer1M:  2,
+		OutputCostPer1M: 8,
+		Timezone:        "America/New_York",
+		Windows: []PriceWindow{
+			{Start: "09:00", End: "17:00", Multiplier: 1.5},
+			{Start: "22:00", End: "06:00", Multiplier: 0.5},
+		},
+	}
+	ny, _ := time.LoadLocation("America/New_York")
+
+	cases := map[string]struct {
+		at   time.Time
+		want float64
+	}{
+		"peak":               {time.Date(2026, 6, 1, 10, 0, 0, 0, ny), 15},
+		"shoulder":           {time.Date(2026, 6, 1, 18, 0, 0, 0, ny), 10},
+		"off-peak, evening":  {time.Date(2026, 6, 1, 23, 0, 0, 0, ny), 5},
+		"off-peak, morning":  {time.Date(2026, 6, 2, 5, 59, 0, 0, ny), 5},
+		"window end is open": {time.Date(2026, 6, 1, 17, 0, 0, 0, ny), 10},
+		"given in UTC":       {time.Date(2026, 6, 1, 14, 0, 0, 0, time.UTC), 15},
+	}
+	for name, tc := range cases {
+		if got := price.Cost(1000000, 1000000, tc.at); got != tc.want {
+			t.Errorf("%s: Cost() = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestCreateModelPriceRequestValidate(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	req := CreateModelPriceRequest{InputCostPer1M: 1, OutputCostPer1M: 2}
+	if err := req.Validate(now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !req.EffectiveFrom.Equal(now) || req.Timezone != "UTC" || req.Windows == nil {
+		t.Errorf("defaults not applied: %+v", req)
+	}
+
+	before := now.Add(-time.Hour)
+	invalid := []CreateModelPriceRequest{
+		{InputCostPer1M: -1},
+		{EffectiveFrom: &now, EffectiveTo: &before},
+		{Timezone: "Mars/Olympus"},
+		{Windows: []PriceWindow{{Start: "9am", End: "17:00", Multiplier: 1}}},
+		{Windows: []PriceWindow{{Start: "09:00", End: "09:00", Multiplier: 1}}},
+		{Windows: []PriceWindow{{Start: "09:00", End: "17:00"}}},
+	}
+	for i, r := range invalid {
+		if err := r.Validate(now); err == nil {
+			t.Errorf("case %d: expected an error for %+v", i, r)
+		}
+	}
+}
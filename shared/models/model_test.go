@@ -0,0 +1,66 @@
+package models
+
+import "testing"
+
+func strPtr(s string) *string { return &s }
+
+func TestCreateModelRequestValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		req     CreateModelRequest
+		wantErr bool
+	}{
+		{"non-bedrock ignores aws fields", CreateModelRequest{Provider: "openai"}, false},
+		{"bedrock with environment credentials", CreateModelRequest{Provider: ProviderBedrock, AWSRegion: strPtr("us-east-1")}, false},
+		{"bedrock without region", CreateModelRequest{Provider: ProviderBedrock}, true},
+		{"key without secret", CreateModelRequest{Provider: ProviderBedrock, AWSRegion: strPtr("us-east-1"), AWSAccessKeyID: strPtr("AKIDEXAMPLE")}, true},
+		{"key pair with role", CreateModelRequest{Provider: ProviderBedrock, AWSRegion: strPtr("us-east-1"),
+			AWSAccessKeyID: strPtr("AKIDEXAMPLE"), AWSSecretKey: strPtr("secret"), AWSRoleARN: strPtr("arn:aws:iam::123456789012:role/bedrock")}, false},
+		{"invalid role arn", CreateModelRequest{Provider: ProviderBedrock, AWSRegion: strPtr("us-east-1"), AWSRoleARN: strPtr("bedrock")}, true},
+	}
+	for _, tc := range cases {
+		if err := tc.req.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestUpdateModelRequestValidateMaskedSecret(t *testing.T) {
+	req := UpdateModelRequest{AWSAccessKeyID: strPtr("AKIDEXAMPLE"), AWSSecretKey: strPtr(maskedSecretPrefix)}
+	if err := req.Validate(); err != nil {
+		t.Errorf("an unchanged (masked) secret should be accepted, got %v", err)
+	}
+}
+
+func TestCreateModelRequestValidateVertex(t *testing.T) {
+	key := strPtr(`{"type":"service_account"}`)
+	cases := []struct {
+		name    string
+		req     CreateModelRequest
+		wantErr bool
+	}{
+		{"location and key", CreateModelRequest{Provider: ProviderVertex, GCPLocation: strPtr("us-central1"), GCPServiceAccount: key}, false},
+		{"global location", CreateModelRequest{Provider: ProviderVertex, GCPLocation: strPtr("global"), GCPServiceAccount: key}, false},
+		{"missing key", CreateModelRequest{Provider: ProviderVertex, GCPLocation: strPtr("us-central1")}, true},
+		{"invalid location", CreateModelRequest{Provider: ProviderVertex, GCPLocation: strPtr("https://x"), GCPServiceAccount: key}, true},
+	}
+	for _, tc := range cases {
+		if err := tc.req.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestApplyProviderDefaults(t *testing.T) {
+	req := CreateModelRequest{Provider: ProviderOllama, OutputCostPer1M: strPtr("0.5")}
+	req.ApplyProviderDefaults()
+	if req.InputCostPer1M == nil || *req.InputCostPer1M != "0" || *req.OutputCostPer1M != "0.5" {
+		t.Errorf("self-hosted defaults = %v, %v", req.InputCostPer1M, req.OutputCostPer1M)
+	}
+
+	req = CreateModelRequest{Provider: "openai"}
+	req.ApplyProviderDefaults()
+	if req.InputCostPer1M != nil {
+		t.Error("SaaS models should not get default pricing")
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// maxCABundleBytes caps a model's PEM root CA bundle
+const maxCABundleBytes = 256 << 10
+
+// tlsVersions maps the minimum TLS versions a transport can require to their crypto/tls values
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ModelTransport configures how the gateway connects to a model's provider, for egress proxies
+// and self-hosted models behind private CAs. Options left unset use the gateway's GATEWAY_*
+// transport settings.
+type ModelTransport struct {
+	// ProxyURL sends requests through an http, https or socks5 proxy; it may carry credentials,
+	// which are redacted in API responses
+	ProxyURL string `json:"proxy_url,omitempty"`
+	// CABundle is PEM root certificates trusted alongside the system's
+	CABundle      string `json:"ca_bundle,omitempty"`
+	TLSMinVersion string `json:"tls_min_version,omitempty"` // 1.0 to 1.3
+	// InsecureSkipVerify accepts any certificate the provider presents. For labs only.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	DisableHTTP2       bool `json:"disable_http2,omitempty"`
+}
+
+// Normalize trims the options and checks the proxy URL, CA bundle and TLS version are usable
+func (t *ModelTransport) Normalize() error {
+	t.ProxyURL = strings.TrimSpace(t.ProxyURL)
+	if t.ProxyURL != "" {
+		if _, err := ParseProxyURL(t.ProxyURL); err != nil {
+			return fmt.Errorf("transport.proxy_url: %w", err)
+		}
+	}
+
+	t.CABundle = strings.TrimSpace(t.CABundle)
+	if t.CABundle != "" {
+		if len(t.CABundle) > maxCABundleBytes {
+			return fmt.Errorf("transport.ca_bundle must be at most %d KiB", maxCABundleBytes>>10)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM([]byte(t.CABundle)) {
+			return fmt.Errorf("transport.ca_bundle must hold PEM encoded certificates")
+		}
+	}
+
+	t.TLSMinVersion = strings.TrimSpace(t.TLSMinVersion)
+	if t.TLSMinVersion != "" {
+		if _, ok := TLSVersion(t.TLSMinVersion); !ok {
+			return fmt.Errorf("transport.tls_min_version must be one of 1.0, 1.1, 1.2 or 1.3")
+		}
+	}
+	return nil
+}
+
+// ParseProxyURL parses an http, https or socks5 proxy URL
+func ParseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy URL must use http, https or socks5")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL must have a host")
+	}
+	return u, nil
+}
+
+// TLSVersion returns the crypto/tls value of a minimum TLS version such as 1.2
+func TLSVersion(version string) (uint16, bool) {
+	v, ok := tlsVersions[version]
+	return v, ok
+}
+
+// Redacted returns the options with any proxy password hidden, for API responses
+func (t ModelTransport) Redacted() ModelTransport {
+	t.ProxyURL = redactProxyURL(t.ProxyURL)
+	return t
+}
+
+// RestoreProxyPassword keeps the stored proxy URL when the submitted one is its redacted form,
+// so saving a model without retyping the proxy password leaves it unchanged
+func (t *ModelTransport) RestoreProxyPassword(stored ModelTransport) {
+	if t.ProxyURL != "" && t.ProxyURL != stored.ProxyURL && t.ProxyURL == redactProxyURL(stored.ProxyURL) {
+		t.ProxyURL = stored.ProxyURL
+	}
+}
+
+func redactProxyURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Events only shown in the notification center. They concern the whole gateway rather than an
+// organization, so they go to System Admins and can't be routed to an organization's channels.
+const (
+	NotificationEventSyncFailure     = "sync_failure"
+	NotificationEventUsageAnomaly    = "usage_anomaly"
+	NotificationEventSuspiciousLogin = "suspicious_login"
+	NotificationEventBreakGlass      = "break_glass"
+)
+
+const (
+	defaultNotificationsPage = 20
+	maxNotificationsPage     = 100
+)
+
+// NotificationField is a labelled value shown with a notification
+type NotificationField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Notification is an alert kept in the admin UI's notification center. Notifications of an
+// organization are shown to its admins; those without one to System Admins. Read is per user.
+type Notification struct {
+	ID             string              `json:"id" db:"id"`
+	OrganizationID *string             `json:"organization_id" db:"organization_id"`
+	EventType      string              `json:"event_type" db:"event_type"`
+	Severity       string              `json:"severity" db:"severity"` // 'info', 'warning', 'critical'
+	Title          string              `json:"title" db:"title"`
+	Body           string              `json:"body" db:"body"`
+	Fields         []NotificationField `json:"fields" db:"fields"`
+	LinkURL        string              `json:"link_url,omitempty" db:"link_url"`
+	Read           bool                `json:"read" db:"-"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+}
+
+// NotificationListParams filters the notifications a user sees
+type NotificationListParams struct {
+	OrganizationID string `form:"org_id"`
+	UnreadOnly     bool   `form:"unread"`
+	Limit          int    `form:"limit"`
+	Before         string `form:"before"` // RFC 3339; lists older notifications, for paging
+
+	BeforeTime *time.Time `form:"-"`
+}
+
+// Validate parses before and defaults and caps the page size
+func (p *NotificationListParams) Validate() error {
+	if p.Limit <= 0 {
+		p.Limit = defaultNotificationsPage
+	}
+	p.Limit = min(p.Limit, maxNotificationsPage)
+
+	if p.Before != "" {
+		before, err := time.Parse(time.RFC3339Nano, p.Before)
+		if err != nil {
+			return fmt.Errorf("before must be an RFC 3339 timestamp")
+		}
+		p.BeforeTime = &before
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notification events a channel can subscribe to
+const (
+	NotificationEventAPIKeyExpiry = "api_key_expiry"
+	NotificationEventQuotaUsage   = "quota_usage"
+	NotificationEventAPIKeyLeak   = "api_key_leak"
+	NotificationEventCanaryKey    = "canary_key"
+	NotificationEventSLOBurnRate  = "slo_burn_rate"
+)
+
+// NotificationEvents lists every notification event
+var NotificationEvents = []string{NotificationEventAPIKeyExpiry, NotificationEventQuotaUsage, NotificationEventAPIKeyLeak, NotificationEventCanaryKey, NotificationEventSLOBurnRate}
+
+// IsValidNotificationEvent reports whether e is a known notification event
+func IsValidNotificationEvent(e string) bool {
+	for _, event := range NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationChannel is an organization's Slack or Teams webhook
+type NotificationChannel struct {
+	ID             string    `json:"id" db:"id"`
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	Name           string    `json:"name" db:"name"`
+	Type           string    `json:"type" db:"type"` // 'slack', 'teams'
+	WebhookURL     string    `json:"webhook_url" db:"webhook_url"`
+	Events         []string  `json:"events" db:"events"` // Empty means all events
+	IsEnabled      bool      `json:"is_enabled" db:"is_enabled"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MarshalJSON masks the webhook URL, which embeds the channel's credentials
+func (n NotificationChannel) MarshalJSON() ([]byte, error) {
+	type Alias NotificationChannel
+	return json.Marshal(&struct {
+		WebhookURL string `json:"webhook_url"`
+		*Alias
+	}{
+		WebhookURL: MaskSecret(n.WebhookURL),
+		Alias:      (*Alias)(&n),
+	})
+}
+
+// SubscribesTo reports whether the channel should receive the event
+func (n *NotificationChannel) SubscribesTo(event string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+	for _, e := range n.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateNotificationChannelRequest struct {
+	OrganizationID string   `json:"organization_id" binding:"required"`
+	Name           string   `json:"name" binding:"required"`
+	Type           string   `json:"type" binding:"required"`
+	WebhookURL     string   `json:"webhook_url" binding:"required"`
+	Events         []string `json:"events"`
+	IsEnabled      *bool    `json:"is_enabled"`
+}
+
+type UpdateNotificationChannelRequest struct {
+	Name       *string  `json:"name"`
+	WebhookURL *string  `json:"webhook_url"`
+	Events     []string `json:"events"`
+	IsEnabled  *bool    `json:"is_enabled"`
+}
+
+// ValidateWebhookURL requires an absolute https URL
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("webhook_url must be an https URL")
+	}
+	return nil
+}
+
+// ValidateNotificationEvents checks every event is known
+func ValidateNotificationEvents(events []string) error {
+	for _, e := range events {
+		if !IsValidNotificationEvent(e) {
+			return fmt.Errorf("unknown event: %s", e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Per-recipient delivery channels. Organization-wide Slack/Teams channels are configured
+// separately and aren't subject to personal preferences.
+const (
+	NotificationDeliveryEmail = "email"
+)
+
+// NotificationDeliveryChannels lists the channels a recipient can opt out of
+var NotificationDeliveryChannels = []string{NotificationDeliveryEmail}
+
+// IsValidNotificationDelivery reports whether c is a known per-recipient delivery channel
+func IsValidNotificationDelivery(c string) bool {
+	for _, channel := range NotificationDeliveryChannels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationPreference is whether a recipient receives one notification type on one channel.
+// Preferences are keyed by email address so recipients without an account (e.g. extra quota
+// alert recipients) can unsubscribe too. A missing preference means subscribed.
+type NotificationPreference struct {
+	Email     string     `json:"email" db:"email"`
+	EventType string     `json:"event_type" db:"event_type"`
+	Channel   string     `json:"channel" db:"channel"`
+	IsEnabled bool       `json:"is_enabled" db:"is_enabled"`
+	UpdatedAt *time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NotificationPreferenceSetting turns one event/channel pair on or off
+type NotificationPreferenceSetting struct {
+	EventType string `json:"event_type" binding:"required"`
+	Channel   string `json:"channel" binding:"required"`
+	IsEnabled bool   `json:"is_enabled"`
+}
+
+// UpdateNotificationPreferencesRequest replaces some of the caller's notification preferences
+type UpdateNotificationPreferencesRequest struct {
+	Preferences []NotificationPreferenceSetting `json:"preferences" binding:"required"`
+}
+
+// Validate checks every setting names a known event and channel
+func (r *UpdateNotificationPreferencesRequest) Validate() error {
+	for _, p := range r.Preferences {
+		if !IsValidNotificationEvent(p.EventType) {
+			return fmt.Errorf("unknown event: %s", p.EventType)
+		}
+		if !IsValidNotificationDelivery(p.Channel) {
+			return fmt.Errorf("unknown channel: %s", p.Channel)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestNotificationListParamsValidate(t *testing.T) {
+	p := NotificationListParams{}
+	if err := p.Validate(); err != nil || p.Limit != defaultNotificationsPage {
+		t.Errorf("Validate() = %v, limit %d; want the default page", err, p.Limit)
+	}
+
+	p = NotificationListParams{Limit: 1000, Before: "2026-03-01T12:00:00Z"}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if p.Limit != maxNotificationsPage {
+		t.Errorf("limit = %d, want capped at %d", p.Limit, maxNotificationsPage)
+	}
+	if p.BeforeTime == nil || p.BeforeTime.Day() != 1 {
+		t.Errorf("BeforeTime = %v, want the parsed timestamp", p.BeforeTime)
+	}
+
+	if err := (&NotificationListParams{Before: "yesterday"}).Validate(); err == nil {
+		t.Error("expected a malformed before to be rejected")
+	}
+}
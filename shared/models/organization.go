@@ -13,6 +13,7 @@ type Organization struct {
 	AdAdminGroupName  *string   `json:"ad_admin_group_name" db:"ad_admin_group_name"`
 	AdMemberGroupID   *string   `json:"ad_member_group_id" db:"ad_member_group_id"`
 	AdMemberGroupName *string   `json:"ad_member_group_name" db:"ad_member_group_name"`
+	ParentID          *string   `json:"parent_id" db:"parent_id"` // Set for sub-teams of another organization
 	CreatedAt         time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
 }
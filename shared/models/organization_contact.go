@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Contact roles of an organization's notification roster
+const (
+	ContactRoleBilling   = "billing"
+	ContactRoleTechnical = "technical"
+	ContactRoleSecurity  = "security"
+)
+
+// ContactRoles lists every contact role
+var ContactRoles = []string{ContactRoleBilling, ContactRoleTechnical, ContactRoleSecurity}
+
+// IsValidContactRole reports whether role is a known contact role
+func IsValidContactRole(role string) bool {
+	for _, r := range ContactRoles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// ContactRoleForEvent returns the contacts a notification event is emailed to: quota usage to
+// billing, key expiry to technical, leaked and canary keys to security
+func ContactRoleForEvent(event string) string {
+	switch event {
+	case NotificationEventQuotaUsage:
+		return ContactRoleBilling
+	case NotificationEventAPIKeyExpiry, NotificationEventSLOBurnRate:
+		return ContactRoleTechnical
+	case NotificationEventAPIKeyLeak, NotificationEventCanaryKey:
+		return ContactRoleSecurity
+	}
+	return ""
+}
+
+// OrganizationContact is an address on an organization's notification roster. Notification
+// emails go to the contacts of the event's role, or to the organization's admins when it has none.
+type OrganizationContact struct {
+	ID             string    `json:"id" db:"id"`
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	Role           string    `json:"role" db:"role"` // 'billing', 'technical', 'security'
+	Email          string    `json:"email" db:"email"`
+	Name           string    `json:"name" db:"name"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateOrganizationContactRequest struct {
+	OrganizationID string `json:"organization_id" binding:"required"`
+	Role           string `json:"role" binding:"required"`
+	Email          string `json:"email" binding:"required,email"`
+	Name           string `json:"name"`
+}
+
+// Validate checks the role and normalizes the address
+func (r *CreateOrganizationContactRequest) Validate() error {
+	if !IsValidContactRole(r.Role) {
+		return fmt.Errorf("role must be one of %s", strings.Join(ContactRoles, ", "))
+	}
+	r.Email = strings.ToLower(strings.TrimSpace(r.Email))
+	r.Name = strings.TrimSpace(r.Name)
+	return nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestContactRoleForEvent(t *testing.T) {
+	for _, event := range NotificationEvents {
+		if role := ContactRoleForEvent(event); !IsValidContactRole(role) {
+			t.Errorf("ContactRoleForEvent(%q) = %q, want a contact role", event, role)
+		}
+	}
+	if role := ContactRoleForEvent(NotificationEventSyncFailure); role != "" {
+		t.Errorf("ContactRoleForEvent(sync_failure) = %q, want none for gateway-wide events", role)
+	}
+}
+
+func TestCreateOrganizationContactRequestValidate(t *testing.T) {
+	req := CreateOrganizationContactRequest{Role: ContactRoleBilling, Email: " Billing@Example.com ", Name: " Accounts "}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if req.Email != "billing@example.com" || req.Name != "Accounts" {
+		t.Errorf("Validate() left email %q and name %q, want them normalized", req.Email, req.Name)
+	}
+
+	if err := (&CreateOrganizationContactRequest{Role: "legal", Email: "a@example.com"}).Validate(); err == nil {
+		t.Error("expected an unknown role to be rejected")
+	}
+}
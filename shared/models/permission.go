@@ -0,0 +1,150 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Permissions that can be granted to organization roles
+const (
+	PermissionKeysRead      = "keys:read"
+	PermissionKeysWrite     = "keys:write"
+	PermissionKeysOwn       = "keys:own" // Create and manage keys the user owns, and see their usage
+	PermissionModelsRead    = "models:read"
+	PermissionModelsWrite   = "models:write"
+	PermissionAnalyticsRead = "analytics:read"
+	PermissionBillingRead   = "billing:read"
+	PermissionCostsRead     = "costs:read" // See model prices and spend; without it usage is shown in tokens only
+)
+
+// Permissions lists every permission, in display order
+var Permissions = []string{
+	PermissionKeysRead,
+	PermissionKeysWrite,
+	PermissionKeysOwn,
+	PermissionModelsRead,
+	PermissionModelsWrite,
+	PermissionAnalyticsRead,
+	PermissionBillingRead,
+	PermissionCostsRead,
+}
+
+// BuiltinRolePermissions is the fixed permission set of the built-in roles. Admins also manage
+// members and roles, which isn't a grantable permission.
+var BuiltinRolePermissions = map[string][]string{
+	OrgRoleAdmin: Permissions,
+	OrgRoleMember: {
+		PermissionKeysRead,
+		PermissionKeysWrite,
+		PermissionModelsRead,
+		PermissionAnalyticsRead,
+		PermissionBillingRead,
+		PermissionCostsRead,
+	},
+	OrgRoleDeveloper: {
+		PermissionKeysOwn,
+		PermissionCostsRead,
+	},
+}
+
+// IsValidPermission reports whether p is a known permission
+func IsValidPermission(p string) bool {
+	for _, permission := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutPermission returns permissions minus p, leaving the slice passed in untouched
+func WithoutPermission(permissions []string, p string) []string {
+	out := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if permission != p {
+			out = append(out, permission)
+		}
+	}
+	return out
+}
+
+// IsBuiltinOrgRole reports whether name is a built-in role
+func IsBuiltinOrgRole(name string) bool {
+	_, ok := BuiltinRolePermissions[name]
+	return ok
+}
+
+// OrgRole is a role usable within one organization. Built-in roles have no ID.
+type OrgRole struct {
+	ID             string     `json:"id,omitempty" db:"id"`
+	OrganizationID string     `json:"organization_id,omitempty" db:"organization_id"`
+	Name           string     `json:"name" db:"name"`
+	Description    string     `json:"description" db:"description"`
+	Permissions    []string   `json:"permissions" db:"permissions"`
+	IsBuiltin      bool       `json:"is_builtin"`
+	CreatedAt      *time.Time `json:"created_at,omitempty" db:"created_at"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+var orgRoleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+func validateOrgRoleName(name string) error {
+	if !orgRoleNamePattern.MatchString(name) {
+		return fmt.Errorf("role name must be 1-50 lowercase letters, digits, '-' or '_'")
+	}
+	return nil
+}
+
+// CreateOrgRoleRequest defines a custom role within an organization
+type CreateOrgRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Validate checks the name is a free slug and every permission is known
+func (r *CreateOrgRoleRequest) Validate() error {
+	if err := validateOrgRoleName(r.Name); err != nil {
+		return err
+	}
+	if IsBuiltinOrgRole(r.Name) {
+		return fmt.Errorf("%q is a built-in role", r.Name)
+	}
+	return validatePermissions(r.Permissions)
+}
+
+// UpdateOrgRoleRequest replaces a custom role's description and permissions
+type UpdateOrgRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Validate checks every permission is known
+func (r *UpdateOrgRoleRequest) Validate() error {
+	return validatePermissions(r.Permissions)
+}
+
+func validatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if !IsValidPermission(p) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+	}
+	return nil
+}
+
+// NormalizePermissions drops duplicates and sorts permissions for storage
+func NormalizePermissions(permissions []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, p := range permissions {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
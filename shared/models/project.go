@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Project groups an organization's API keys with an optional token quota of its own, mirroring
+// OpenAI's organization/project split
+type Project struct {
+	ID             string    `json:"id" db:"id"`
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	Name           string    `json:"name" db:"name"`
+	Description    *string   `json:"description" db:"description"`
+	TokenQuota     *int64    `json:"token_quota" db:"token_quota"` // nil draws on the organization's quota alone
+	UsedTokens     int64     `json:"used_tokens" db:"used_tokens"`
+	IsActive       bool      `json:"is_active" db:"is_active"`
+	CreatedBy      *string   `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	APIKeyCount    int       `json:"api_key_count" db:"api_key_count"`
+}
+
+type CreateProjectRequest struct {
+	OrganizationID string  `json:"organization_id" binding:"required"`
+	Name           string  `json:"name" binding:"required"`
+	Description    *string `json:"description"`
+	TokenQuota     *int64  `json:"token_quota"`
+}
+
+// Validate trims the name and checks the quota is positive
+func (r *CreateProjectRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > 255 {
+		return fmt.Errorf("name must be between 1 and 255 characters")
+	}
+	if r.TokenQuota != nil && *r.TokenQuota <= 0 {
+		return fmt.Errorf("token_quota must be positive")
+	}
+	return nil
+}
+
+// UpdateProjectRequest changes the fields given. A token_quota of 0 removes the project's quota.
+type UpdateProjectRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	TokenQuota  *int64  `json:"token_quota"`
+}
+
+// Validate trims the name and checks the quota is not negative
+func (r *UpdateProjectRequest) Validate() error {
+	if r.Name != nil {
+		name := strings.TrimSpace(*r.Name)
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("name must be between 1 and 255 characters")
+		}
+		r.Name = &name
+	}
+	if r.TokenQuota != nil && *r.TokenQuota < 0 {
+		return fmt.Errorf("token_quota must not be negative")
+	}
+	return nil
+}
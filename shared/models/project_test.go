@@ -0,0 +1,36 @@
+package models
+
+import "testing"
+
+func TestCreateProjectRequestValidate(t *testing.T) {
+	req := CreateProjectRequest{OrganizationID: "org", Name: "  search  "}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.Name != "search" {
+		t.Errorf("name = %q, want trimmed", req.Name)
+	}
+
+	if err := (&CreateProjectRequest{Name: "   "}).Validate(); err == nil {
+		t.Error("expected a blank name to be rejected")
+	}
+	zero := int64(0)
+	if err := (&CreateProjectRequest{Name: "search", TokenQuota: &zero}).Validate(); err == nil {
+		t.Error("expected a zero quota to be rejected")
+	}
+}
+
+func TestUpdateProjectRequestValidate(t *testing.T) {
+	zero := int64(0)
+	if err := (&UpdateProjectRequest{TokenQuota: &zero}).Validate(); err != nil {
+		t.Errorf("a zero quota clears the project's quota, got %v", err)
+	}
+	negative := int64(-1)
+	if err := (&UpdateProjectRequest{TokenQuota: &negative}).Validate(); err == nil {
+		t.Error("expected a negative quota to be rejected")
+	}
+	blank := " "
+	if err := (&UpdateProjectRequest{Name: &blank}).Validate(); err == nil {
+		t.Error("expected a blank name to be rejected")
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+// QoS classes decide which organizations' requests the gateway serves first when it is at its
+// concurrency limit. Gold is scheduled ahead of silver; bronze is shed with 429s.
+const (
+	QoSGold   = "gold"
+	QoSSilver = "silver"
+	QoSBronze = "bronze"
+
+	DefaultQoSClass = QoSSilver
+)
+
+// QoSClasses lists the classes from highest to lowest priority
+var QoSClasses = []string{QoSGold, QoSSilver, QoSBronze}
+
+// IsValidQoSClass reports whether class is a known QoS class
+func IsValidQoSClass(class string) bool {
+	for _, c := range QoSClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateQoSClassRequest sets an organization's QoS class
+type UpdateQoSClassRequest struct {
+	QoSClass string `json:"qos_class" binding:"required"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -57,3 +58,52 @@ func formatNumber(n int) string {
 type UpdateQuotaRequest struct {
 	TotalQuota int `json:"total_quota" binding:"required"`
 }
+
+// DefaultQuotaNotificationThresholds are the usage percentages that trigger a notification
+var DefaultQuotaNotificationThresholds = []int{80, 90, 100}
+
+// QuotaNotificationSettings controls quota usage emails for an organization
+type QuotaNotificationSettings struct {
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	IsEnabled      bool      `json:"is_enabled" db:"is_enabled"`
+	Thresholds     []int     `json:"thresholds" db:"thresholds"` // Percent of quota, ascending
+	Recipients     []string  `json:"recipients" db:"recipients"` // Empty means the organization's billing contacts, or admins
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateQuotaNotificationSettingsRequest struct {
+	OrganizationID string   `json:"organization_id" binding:"required"`
+	IsEnabled      bool     `json:"is_enabled"`
+	Thresholds     []int    `json:"thresholds"`
+	Recipients     []string `json:"recipients"`
+}
+
+// Validate checks thresholds are percentages and recipients look like email addresses
+func (r *UpdateQuotaNotificationSettingsRequest) Validate() error {
+	if len(r.Thresholds) == 0 {
+		r.Thresholds = DefaultQuotaNotificationThresholds
+	}
+	for _, t := range r.Thresholds {
+		if t < 1 || t > 100 {
+			return fmt.Errorf("thresholds must be between 1 and 100")
+		}
+	}
+	for _, recipient := range r.Recipients {
+		if !strings.Contains(recipient, "@") {
+			return fmt.Errorf("invalid recipient email: %s", recipient)
+		}
+	}
+	return nil
+}
+
+// APIKeyUsageSummary is a key's token consumption, used in quota notifications
+type APIKeyUsageSummary struct {
+	Name   string `json:"name"`
+	Tokens string `json:"tokens"`
+}
+
+// FormatTokenCount formats a token count for display, e.g. 1.2M
+func FormatTokenCount(n int64) string {
+	return formatNumber(int(n))
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// RateCounter is one organization's traffic to one model over the last one and five minutes,
+// as counted in a gateway's memory
+type RateCounter struct {
+	OrganizationID string `json:"organization_id"`
+	ModelID        string `json:"model_id"`
+	Requests1m     int64  `json:"requests_1m"`
+	Tokens1m       int64  `json:"tokens_1m"`
+	Requests5m     int64  `json:"requests_5m"`
+	Tokens5m       int64  `json:"tokens_5m"`
+}
+
+// ProviderRateLimit is the per-minute limit a provider last reported for a model in its
+// x-ratelimit-* response headers; zero means the header was absent
+type ProviderRateLimit struct {
+	ModelID           string    `json:"model_id"`
+	RequestLimit      int64     `json:"request_limit"`
+	RemainingRequests int64     `json:"remaining_requests"`
+	TokenLimit        int64     `json:"token_limit"`
+	RemainingTokens   int64     `json:"remaining_tokens"`
+	ObservedAt        time.Time `json:"observed_at"`
+}
+
+// RateSnapshot is one gateway's in-memory rate counters
+type RateSnapshot struct {
+	Counters       []RateCounter       `json:"counters"`
+	ProviderLimits []ProviderRateLimit `json:"provider_limits"`
+	GeneratedAt    time.Time           `json:"generated_at"`
+}
+
+// MergeRateSnapshots adds up the counters of several gateway replicas. For provider limits,
+// which describe the shared upstream account, the most recent observation of each model wins.
+func MergeRateSnapshots(snapshots []RateSnapshot) RateSnapshot {
+	merged := RateSnapshot{GeneratedAt: time.Now()}
+	counters := map[[2]string]*RateCounter{}
+	limits := map[string]ProviderRateLimit{}
+	for _, s := range snapshots {
+		for _, rc := range s.Counters {
+			key := [2]string{rc.OrganizationID, rc.ModelID}
+			c, ok := counters[key]
+			if !ok {
+				c = &RateCounter{OrganizationID: rc.OrganizationID, ModelID: rc.ModelID}
+				counters[key] = c
+			}
+			c.Requests1m += rc.Requests1m
+			c.Tokens1m += rc.Tokens1m
+			c.Requests5m += rc.Requests5m
+			c.Tokens5m += rc.Tokens5m
+		}
+		for _, l := range s.ProviderLimits {
+			if existing, ok := limits[l.ModelID]; !ok || l.ObservedAt.After(existing.ObservedAt) {
+				limits[l.ModelID] = l
+			}
+		}
+	}
+	for _, c := range counters {
+		merged.Counters = append(merged.Counters, *c)
+	}
+	for _, l := range limits {
+		merged.ProviderLimits = append(merged.ProviderLimits, l)
+	}
+	return merged
+}
+
+// RateGauge is the live request and token rate of an organization, a model or the whole
+// gateway. The five-minute figures are per-minute averages.
+type RateGauge struct {
+	ID    string  `json:"id,omitempty"`
+	Name  string  `json:"name,omitempty"`
+	RPM1m float64 `json:"rpm_1m"`
+	TPM1m float64 `json:"tpm_1m"`
+	RPM5m float64 `json:"rpm_5m"`
+	TPM5m float64 `json:"tpm_5m"`
+
+	// Models only: the provider's per-minute limits and how close the gateway is to them
+	ProviderRPMLimit *int64  `json:"provider_rpm_limit,omitempty"`
+	ProviderTPMLimit *int64  `json:"provider_tpm_limit,omitempty"`
+	UtilizationPct   float64 `json:"utilization_pct,omitempty"`
+	Warning          bool    `json:"warning,omitempty"`
+}
+
+// LiveRates is what the dashboard's live gauges show
+type LiveRates struct {
+	Total         RateGauge   `json:"total"`
+	Organizations []RateGauge `json:"organizations"`
+	Models        []RateGauge `json:"models"`
+	Sources       int         `json:"sources"` // Gateways the counters came from
+	GeneratedAt   time.Time   `json:"generated_at"`
+}
+
+// providerLimitFreshness is how long a provider's reported remaining allowance is trusted; its
+// limits are per minute, so older readings say nothing about the current window
+const providerLimitFreshness = time.Minute
+
+// BuildLiveRates turns counters into gauges, narrowed to orgIDs when given (nil means every
+// organization). Model gauges are compared to the provider's reported limits, and warn once
+// utilization reaches warnPercent: the one-minute rate against the limit, or the share of the
+// allowance used in the provider's current window.
+func BuildLiveRates(snapshot RateSnapshot, orgIDs []string, warnPercent float64, now time.Time) LiveRates {
+	var allowed map[string]bool
+	if orgIDs != nil {
+		allowed = make(map[string]bool, len(orgIDs))
+		for _, id := range orgIDs {
+			allowed[id] = true
+		}
+	}
+
+	live := LiveRates{GeneratedAt: snapshot.GeneratedAt}
+	orgs := map[string]*RateGauge{}
+	modelGauges := map[string]*RateGauge{}
+	for _, c := range snapshot.Counters {
+		if allowed != nil && !allowed[c.OrganizationID] {
+			continue
+		}
+		addCounter(&live.Total, c)
+		addCounter(gaugeFor(orgs, c.OrganizationID), c)
+		addCounter(gaugeFor(modelGauges, c.ModelID), c)
+	}
+
+	for _, l := range snapshot.ProviderLimits {
+		g, ok := modelGauges[l.ModelID]
+		if !ok {
+			continue
+		}
+		if l.RequestLimit > 0 {
+			limit := l.RequestLimit
+			g.ProviderRPMLimit = &limit
+			g.UtilizationPct = maxFloat(g.UtilizationPct, g.RPM1m/float64(limit)*100)
+		}
+		if l.TokenLimit > 0 {
+			limit := l.TokenLimit
+			g.ProviderTPMLimit = &limit
+			g.UtilizationPct = maxFloat(g.UtilizationPct, g.TPM1m/float64(limit)*100)
+		}
+		if now.Sub(l.ObservedAt) < providerLimitFreshness {
+			if l.RequestLimit > 0 {
+				g.UtilizationPct = maxFloat(g.UtilizationPct, float64(l.RequestLimit-l.RemainingRequests)/float64(l.RequestLimit)*100)
+			}
+			if l.TokenLimit > 0 {
+				g.UtilizationPct = maxFloat(g.UtilizationPct, float64(l.TokenLimit-l.RemainingTokens)/float64(l.TokenLimit)*100)
+			}
+		}
+		g.Warning = warnPercent > 0 && g.UtilizationPct >= warnPercent
+	}
+
+	live.Organizations = sortedGauges(orgs)
+	live.Models = sortedGauges(modelGauges)
+	return live
+}
+
+func gaugeFor(gauges map[string]*RateGauge, id string) *RateGauge {
+	g, ok := gauges[id]
+	if !ok {
+		g = &RateGauge{ID: id}
+		gauges[id] = g
+	}
+	return g
+}
+
+func addCounter(g *RateGauge, c RateCounter) {
+	g.RPM1m += float64(c.Requests1m)
+	g.TPM1m += float64(c.Tokens1m)
+	g.RPM5m += float64(c.Requests5m) / 5
+	g.TPM5m += float64(c.Tokens5m) / 5
+}
+
+// sortedGauges orders gauges busiest first by one-minute token rate
+func sortedGauges(gauges map[string]*RateGauge) []RateGauge {
+	sorted := make([]RateGauge, 0, len(gauges))
+	for _, g := range gauges {
+		sorted = append(sorted, *g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].TPM1m != sorted[j].TPM1m {
+			return sorted[i].TPM1m > sorted[j].TPM1m
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
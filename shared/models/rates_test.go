@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeRateSnapshots(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	merged := MergeRateSnapshots([]RateSnapshot{
+		{
+			Counters:       []RateCounter{{OrganizationID: "org", ModelID: "gpt", Requests1m: 2, Tokens1m: 200}},
+			ProviderLimits: []ProviderRateLimit{{ModelID: "gpt", TokenLimit: 1000, RemainingTokens: 900, ObservedAt: now.Add(-time.Minute)}},
+		},
+		{
+			Counters:       []RateCounter{{OrganizationID: "org", ModelID: "gpt", Requests1m: 3, Tokens1m: 300}},
+			ProviderLimits: []ProviderRateLimit{{ModelID: "gpt", TokenLimit: 1000, RemainingTokens: 400, ObservedAt: now}},
+		},
+	})
+
+	if len(merged.Counters) != 1 || merged.Counters[0].Requests1m != 5 || merged.Counters[0].Tokens1m != 500 {
+		t.Errorf("counters = %+v, want one pair with 5 requests and 500 tokens", merged.Counters)
+	}
+	if len(merged.ProviderLimits) != 1 || merged.ProviderLimits[0].RemainingTokens != 400 {
+		t.Errorf("provider limits = %+v, want the latest observation", merged.ProviderLimits)
+	}
+}
+
+func TestBuildLiveRates(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := RateSnapshot{
+		Counters: []RateCounter{
+			{OrganizationID: "a", ModelID: "gpt", Requests1m: 10, Tokens1m: 7000, Requests5m: 25, Tokens5m: 20000},
+			{OrganizationID: "b", ModelID: "gpt", Requests1m: 5, Tokens1m: 1500, Requests5m: 5, Tokens5m: 1500},
+			{OrganizationID: "b", ModelID: "claude", Requests1m: 1, Tokens1m: 100, Requests5m: 1, Tokens5m: 100},
+		},
+		ProviderLimits: []ProviderRateLimit{{ModelID: "gpt", TokenLimit: 10000, RemainingTokens: 9000, ObservedAt: now.Add(-2 * time.Minute)}},
+	}
+
+	live := BuildLiveRates(snapshot, nil, 80, now)
+	if live.Total.TPM1m != 8600 || live.Total.RPM5m != 31.0/5 {
+		t.Errorf("total = %+v", live.Total)
+	}
+	if len(live.Models) != 2 || live.Models[0].ID != "gpt" {
+		t.Fatalf("models = %+v, want gpt first", live.Models)
+	}
+	gpt := live.Models[0]
+	// 8500 of 10000 tokens in the last minute; the stale remaining count is ignored
+	if gpt.UtilizationPct != 85 || !gpt.Warning {
+		t.Errorf("gpt utilization = %.1f%% warning = %v, want 85%% with a warning", gpt.UtilizationPct, gpt.Warning)
+	}
+	if gpt.ProviderTPMLimit == nil || *gpt.ProviderTPMLimit != 10000 {
+		t.Errorf("gpt provider TPM limit = %v, want 10000", gpt.ProviderTPMLimit)
+	}
+
+	scoped := BuildLiveRates(snapshot, []string{"b"}, 80, now)
+	if len(scoped.Organizations) != 1 || scoped.Total.TPM1m != 1600 {
+		t.Errorf("scoped to b: organizations = %+v, total = %+v", scoped.Organizations, scoped.Total)
+	}
+	if scoped.Models[0].Warning {
+		t.Error("expected no warning for b's 1500 tokens per minute")
+	}
+}
+
+func TestBuildLiveRatesFreshRemaining(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := RateSnapshot{
+		Counters:       []RateCounter{{OrganizationID: "a", ModelID: "gpt", Requests1m: 1, Tokens1m: 100}},
+		ProviderLimits: []ProviderRateLimit{{ModelID: "gpt", RequestLimit: 100, RemainingRequests: 5, ObservedAt: now.Add(-10 * time.Second)}},
+	}
+
+	// Other traffic on the provider account has used 95 of the 100 requests this minute
+	gpt := BuildLiveRates(snapshot, nil, 80, now).Models[0]
+	if gpt.UtilizationPct != 95 || !gpt.Warning {
+		t.Errorf("utilization = %.1f%% warning = %v, want 95%% with a warning", gpt.UtilizationPct, gpt.Warning)
+	}
+}
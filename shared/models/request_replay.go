@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RequestPayload is a logged gateway request and the provider's response, kept when payload
+// logging is enabled so admins can inspect and replay it
+type RequestPayload struct {
+	ID             string    `json:"id" db:"id"`
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	APIKeyID       *string   `json:"api_key_id" db:"api_key_id"`
+	APIKeyName     *string   `json:"api_key_name,omitempty" db:"api_key_name"`
+	ModelID        *string   `json:"model_id" db:"model_id"`
+	ModelName      *string   `json:"model_name,omitempty" db:"model_name"`
+	Endpoint       string    `json:"endpoint" db:"endpoint"`
+	RequestBody    string    `json:"request_body,omitempty" db:"request_body"`
+	ResponseStatus int       `json:"response_status" db:"response_status"`
+	ResponseBody   *string   `json:"response_body,omitempty" db:"response_body"`
+	ResponseTimeMS *int      `json:"response_time_ms" db:"response_time_ms"`
+	Truncated      bool      `json:"truncated" db:"truncated"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// RequestReplay is a sandbox re-run of a logged request. Replays are not usage and never count
+// towards analytics, quotas or billing.
+type RequestReplay struct {
+	ID             string    `json:"id" db:"id"`
+	PayloadID      string    `json:"payload_id" db:"payload_id"`
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	ModelID        *string   `json:"model_id" db:"model_id"`
+	ModelName      *string   `json:"model_name,omitempty" db:"model_name"`
+	RequestBody    string    `json:"request_body" db:"request_body"`
+	ResponseStatus int       `json:"response_status" db:"response_status"`
+	ResponseBody   *string   `json:"response_body" db:"response_body"`
+	ResponseTimeMS *int      `json:"response_time_ms" db:"response_time_ms"`
+	Error          *string   `json:"error,omitempty" db:"error"`
+	ReplayedBy     *string   `json:"replayed_by" db:"replayed_by"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReplayRequestPayloadRequest replays a logged request, against its original model unless
+// another one is given
+type ReplayRequestPayloadRequest struct {
+	ModelID string `json:"model_id"`
+}
+
+// ReplayBody prepares a logged request body for replay: the model is swapped for modelName and
+// streaming is turned off so both responses can be compared whole
+func ReplayBody(body []byte, modelName string) ([]byte, error) {
+	var request map[string]json.RawMessage
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("logged request is not a JSON object")
+	}
+
+	model, err := json.Marshal(modelName)
+	if err != nil {
+		return nil, err
+	}
+	request["model"] = model
+	if _, ok := request["stream"]; ok {
+		request["stream"] = json.RawMessage("false")
+	}
+	delete(request, "stream_options")
+
+	return json.Marshal(request)
+}
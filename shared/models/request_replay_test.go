@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestReplayBody(t *testing.T) {
+	body := []byte(`{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`)
+
+	out, err := ReplayBody(body, "claude-sonnet")
+	if err != nil {
+		t.Fatalf("ReplayBody returned error: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("replay body is not JSON: %v", err)
+	}
+	if got["model"] != "claude-sonnet" {
+		t.Errorf("model = %v, want claude-sonnet", got["model"])
+	}
+	if got["stream"] != false {
+		t.Errorf("stream = %v, want false", got["stream"])
+	}
+	if _, ok := got["stream_options"]; ok {
+		t.Error("stream_options should be dropped")
+	}
+	if _, ok := got["messages"]; !ok {
+		t.Error("messages should be kept")
+	}
+}
+
+func TestReplayBodyRejectsNonObject(t *testing.T) {
+	if _, err := ReplayBody([]byte(`[1,2]`), "gpt-4o"); err == nil {
+		t.Error("expected an error for a non-object body")
+	}
+}
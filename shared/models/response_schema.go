@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ResponseSchema declares the JSON schema that model output on an endpoint must satisfy
+type ResponseSchema struct {
+	ID             string          `json:"id" db:"id"`
+	OrganizationID string          `json:"organization_id" db:"organization_id"`
+	Name           string          `json:"name" db:"name"`
+	Endpoint       string          `json:"endpoint" db:"endpoint"` // e.g., "/v1/chat/completions"
+	Schema         json.RawMessage `json:"schema" db:"schema"`
+	RetryOnFailure bool            `json:"retry_on_failure" db:"retry_on_failure"` // Re-ask the model with a corrective system message
+	MaxRetries     int             `json:"max_retries" db:"max_retries"`
+	IsActive       bool            `json:"is_active" db:"is_active"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type CreateResponseSchemaRequest struct {
+	OrganizationID string          `json:"organization_id" binding:"required"`
+	Name           string          `json:"name" binding:"required"`
+	Endpoint       string          `json:"endpoint" binding:"required"`
+	Schema         json.RawMessage `json:"schema" binding:"required"`
+	RetryOnFailure bool            `json:"retry_on_failure"`
+	MaxRetries     *int            `json:"max_retries"`
+}
+
+type UpdateResponseSchemaRequest struct {
+	Name           *string          `json:"name"`
+	Schema         *json.RawMessage `json:"schema"`
+	RetryOnFailure *bool            `json:"retry_on_failure"`
+	MaxRetries     *int             `json:"max_retries"`
+	IsActive       *bool            `json:"is_active"`
+}
+
+// SchemaValidationResult is recorded in usage log metadata under "schema_validation"
+type SchemaValidationResult struct {
+	SchemaID string   `json:"schema_id"`
+	Valid    bool     `json:"valid"`
+	Skipped  bool     `json:"skipped,omitempty"` // Output could not be extracted from the response
+	Attempts int      `json:"attempts"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// SchemaValidationStats summarizes validation outcomes for an endpoint/model pair
+type SchemaValidationStats struct {
+	Endpoint       string  `json:"endpoint"`
+	ModelID        string  `json:"model_id"`
+	ValidatedCount int64   `json:"validated_count"`
+	FailedCount    int64   `json:"failed_count"`
+	RetriedCount   int64   `json:"retried_count"`
+	FailureRate    float64 `json:"failure_rate"`
+}
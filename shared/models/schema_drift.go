@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Kinds of schema drift
+const (
+	SchemaDriftTable      = "table"
+	SchemaDriftColumn     = "column"
+	SchemaDriftColumnType = "column_type"
+	SchemaDriftConstraint = "constraint"
+	SchemaDriftIndex      = "index"
+)
+
+// SchemaDrift is one way the live database differs from the schema the gateway expects
+type SchemaDrift struct {
+	Kind     string `json:"kind"`
+	Table    string `json:"table"`
+	Name     string `json:"name,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	// Fix is the SQL that repairs the drift; empty when it needs a manual decision, such as a
+	// column whose type differs
+	Fix string `json:"fix,omitempty"`
+}
+
+// SchemaDriftReport compares the live database with the expected schema
+type SchemaDriftReport struct {
+	CheckedAt time.Time     `json:"checked_at"`
+	Drift     []SchemaDrift `json:"drift"`
+}
+
+// Fixable counts the drift that has a generated fix
+func (r *SchemaDriftReport) Fixable() int {
+	n := 0
+	for _, d := range r.Drift {
+		if d.Fix != "" {
+			n++
+		}
+	}
+	return n
+}
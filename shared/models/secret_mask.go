@@ -0,0 +1,25 @@
+package models
+
+import "strings"
+
+// maskedSecretPrefix marks a value as a masked placeholder rather than a real secret
+const maskedSecretPrefix = "••••••••"
+
+// MaskSecret hides a stored secret for API responses, keeping only the last 4 characters
+// so admins can tell which credential is configured
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	runes := []rune(secret)
+	if len(runes) <= 8 {
+		return maskedSecretPrefix
+	}
+	return maskedSecretPrefix + string(runes[len(runes)-4:])
+}
+
+// IsMaskedSecret reports whether a submitted value is the masked placeholder returned by
+// MaskSecret, meaning the client did not change the secret
+func IsMaskedSecret(value string) bool {
+	return strings.HasPrefix(value, maskedSecretPrefix)
+}
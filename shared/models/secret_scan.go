@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// Secret scan policy modes
+const (
+	SecretScanModeOff   = "off"
+	SecretScanModeFlag  = "flag"  // Forward the request and record an incident
+	SecretScanModeBlock = "block" // Reject the request and record an incident
+)
+
+// SecretPattern is an organization-specific regex identifying internal credentials
+type SecretPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// SecretScanPolicy controls how an organization handles credentials detected in prompts
+type SecretScanPolicy struct {
+	OrganizationID string          `json:"organization_id" db:"organization_id"`
+	Mode           string          `json:"mode" db:"mode"` // 'off', 'flag', 'block'
+	CustomPatterns []SecretPattern `json:"custom_patterns" db:"custom_patterns"`
+	UpdatedAt      *time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateSecretScanPolicyRequest struct {
+	OrganizationID string          `json:"organization_id" binding:"required"`
+	Mode           string          `json:"mode" binding:"required"`
+	CustomPatterns []SecretPattern `json:"custom_patterns"`
+}
+
+// IsValidSecretScanMode reports whether mode is a known policy mode
+func IsValidSecretScanMode(mode string) bool {
+	switch mode {
+	case SecretScanModeOff, SecretScanModeFlag, SecretScanModeBlock:
+		return true
+	}
+	return false
+}
+
+// SecretFinding is a detected secret; only a masked preview is stored
+type SecretFinding struct {
+	Type    string `json:"type"`
+	Preview string `json:"preview"`
+}
+
+// SecretScanIncident records a request in which secrets were detected
+type SecretScanIncident struct {
+	ID             string          `json:"id" db:"id"`
+	OrganizationID string          `json:"organization_id" db:"organization_id"`
+	APIKeyID       *string         `json:"api_key_id" db:"api_key_id"`
+	APIKeyName     *string         `json:"api_key_name,omitempty" db:"api_key_name"`
+	ModelID        *string         `json:"model_id" db:"model_id"`
+	Endpoint       string          `json:"endpoint" db:"endpoint"`
+	Action         string          `json:"action" db:"action"` // 'blocked', 'flagged'
+	Findings       []SecretFinding `json:"findings" db:"findings"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
+type SecretTypeCount struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+}
+
+// SecretScanReport summarizes incidents for the admin report
+type SecretScanReport struct {
+	BlockedCount int64                `json:"blocked_count"`
+	FlaggedCount int64                `json:"flagged_count"`
+	ByType       []SecretTypeCount    `json:"by_type"`
+	Incidents    []SecretScanIncident `json:"incidents"`
+	TimeRange    string               `json:"time_range"`
+	Organization string               `json:"organization"`
+	GeneratedAt  time.Time            `json:"generated_at"`
+}
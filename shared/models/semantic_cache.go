@@ -0,0 +1,76 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// SemanticCacheEntry is a chat completion kept to answer later prompts that match it exactly or
+// closely, within one organization and model
+type SemanticCacheEntry struct {
+	ID             string
+	OrganizationID string
+	ModelID        string // The model's row ID
+	EmbeddingModel string // Model ID of the model that embedded the prompt
+	PromptHash     string
+	ParamsHash     string
+	ResponseBody   []byte
+	Similarity     float64 // 1 for an exact match
+	CreatedAt      time.Time
+}
+
+// semanticCacheIgnoredParams don't change the completion, so requests differing only in them
+// share cache entries
+var semanticCacheIgnoredParams = []string{"messages", "stream", "stream_options", "user", "metadata"}
+
+// SemanticCacheKeys hashes a chat completion request for the semantic cache. paramsHash covers
+// every parameter but the messages, and only entries with the same one can answer the request;
+// promptHash adds the messages, for exact matches. ok is false for requests that aren't cached:
+// streamed ones, ones with tools, and bodies without messages.
+func SemanticCacheKeys(body []byte) (promptHash, paramsHash string, messages []json.RawMessage, ok bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var request map[string]interface{}
+	if decoder.Decode(&request) != nil {
+		return "", "", nil, false
+	}
+	if stream, _ := request["stream"].(bool); stream {
+		return "", "", nil, false
+	}
+	if request["tools"] != nil || request["functions"] != nil {
+		return "", "", nil, false
+	}
+
+	conversation, _ := request["messages"].([]interface{})
+	if len(conversation) == 0 {
+		return "", "", nil, false
+	}
+	messages = make([]json.RawMessage, 0, len(conversation))
+	for _, message := range conversation {
+		raw, err := json.Marshal(message)
+		if err != nil {
+			return "", "", nil, false
+		}
+		messages = append(messages, raw)
+	}
+
+	for _, param := range semanticCacheIgnoredParams {
+		delete(request, param)
+	}
+	// Maps marshal with sorted keys, so equal requests hash the same whatever their key order
+	params, err := json.Marshal(request)
+	if err != nil {
+		return "", "", nil, false
+	}
+	prompt, err := json.Marshal(conversation)
+	if err != nil {
+		return "", "", nil, false
+	}
+
+	paramsSum := sha256.Sum256(params)
+	promptSum := sha256.Sum256(append(paramsSum[:], prompt...))
+	return hex.EncodeToString(promptSum[:]), hex.EncodeToString(paramsSum[:]), messages, true
+}
@@ -0,0 +1,40 @@
+package models
+
+import "testing"
+
+func TestSemanticCacheKeys(t *testing.T) {
+	base := `{"model":"gpt","temperature":0,"messages":[{"role":"user","content":"How do I reset my password?"}]}`
+	reordered := `{ "messages": [ {"content": "How do I reset my password?", "role": "user"} ],
+		"temperature": 0, "model": "gpt", "user": "u-42" }`
+
+	prompt, params, messages, ok := SemanticCacheKeys([]byte(base))
+	if !ok || len(messages) != 1 {
+		t.Fatalf("SemanticCacheKeys(base) ok = %v, messages = %d", ok, len(messages))
+	}
+	prompt2, params2, _, _ := SemanticCacheKeys([]byte(reordered))
+	if prompt != prompt2 || params != params2 {
+		t.Error("key order, whitespace and user should not change the keys")
+	}
+
+	otherPrompt, otherParams, _, _ := SemanticCacheKeys([]byte(
+		`{"model":"gpt","temperature":0,"messages":[{"role":"user","content":"How can I reset my password?"}]}`))
+	if otherParams != params || otherPrompt == prompt {
+		t.Error("a different prompt should keep the params hash and change the prompt hash")
+	}
+	_, warmerParams, _, _ := SemanticCacheKeys([]byte(
+		`{"model":"gpt","temperature":0.7,"messages":[{"role":"user","content":"How do I reset my password?"}]}`))
+	if warmerParams == params {
+		t.Error("a different temperature should change the params hash")
+	}
+
+	for _, body := range []string{
+		`{"model":"gpt","stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+		`{"model":"gpt","tools":[{"type":"function"}],"messages":[{"role":"user","content":"hi"}]}`,
+		`{"model":"gpt","messages":[]}`,
+		`not json`,
+	} {
+		if _, _, _, ok := SemanticCacheKeys([]byte(body)); ok {
+			t.Errorf("SemanticCacheKeys(%s) ok = true, want false", body)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultSetupQuota is the token quota given to the first organization when none is requested
+const DefaultSetupQuota = 1000000
+
+// SetupState is what an install already holds, which decides whether first-run setup is open
+type SetupState struct {
+	HasSystemAdmin        bool
+	HasUsers              bool
+	HasMemberships        bool
+	HasOtherOrganizations bool // Besides the seeded Default Organization
+}
+
+// Open reports whether first-run setup may run: only on a fresh install, with no users, no
+// memberships and no organization but the seeded default. An upgraded install that already has
+// users never reopens it, even if none of them is a System Admin.
+func (s SetupState) Open() bool {
+	return !s.HasSystemAdmin && !s.HasUsers && !s.HasMemberships && !s.HasOtherOrganizations
+}
+
+// SetupRequest is the first-run setup of a fresh install: the initial organization, its quota
+// and the user who becomes System Admin
+type SetupRequest struct {
+	OrganizationName string `json:"organization_name" binding:"required"`
+	AdminEmail       string `json:"admin_email" binding:"required"`
+	AdminName        string `json:"admin_name"`
+	AdminAzureOID    string `json:"admin_azure_oid"` // optional; filled in on the admin's first Azure AD sign-in
+	TotalQuota       int    `json:"total_quota"`
+	SetupToken       string `json:"setup_token"`
+}
+
+// Validate trims the fields, defaults the admin's name and the quota, and checks the email
+func (r *SetupRequest) Validate() error {
+	r.OrganizationName = strings.TrimSpace(r.OrganizationName)
+	r.AdminEmail = strings.ToLower(strings.TrimSpace(r.AdminEmail))
+	r.AdminName = strings.TrimSpace(r.AdminName)
+	r.AdminAzureOID = strings.TrimSpace(r.AdminAzureOID)
+
+	if r.OrganizationName == "" || len(r.OrganizationName) > 255 {
+		return fmt.Errorf("organization_name must be between 1 and 255 characters")
+	}
+	if at := strings.Index(r.AdminEmail, "@"); at <= 0 || at == len(r.AdminEmail)-1 {
+		return fmt.Errorf("admin_email must be a valid email address")
+	}
+	if r.AdminName == "" {
+		r.AdminName = r.AdminEmail[:strings.Index(r.AdminEmail, "@")]
+	}
+	if r.TotalQuota < 0 {
+		return fmt.Errorf("total_quota must not be negative")
+	}
+	if r.TotalQuota == 0 {
+		r.TotalQuota = DefaultSetupQuota
+	}
+	return nil
+}
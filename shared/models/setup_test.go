@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestSetupRequestValidate(t *testing.T) {
+	req := SetupRequest{OrganizationName: " Acme ", AdminEmail: " Ops@Example.com "}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.OrganizationName != "Acme" || req.AdminEmail != "ops@example.com" {
+		t.Errorf("fields not normalised: %+v", req)
+	}
+	if req.AdminName != "ops" {
+		t.Errorf("admin name = %q, want it taken from the email", req.AdminName)
+	}
+	if req.TotalQuota != DefaultSetupQuota {
+		t.Errorf("total quota = %d, want the default", req.TotalQuota)
+	}
+
+	for _, bad := range []SetupRequest{
+		{OrganizationName: "", AdminEmail: "ops@example.com"},
+		{OrganizationName: "Acme", AdminEmail: "ops"},
+		{OrganizationName: "Acme", AdminEmail: "@example.com"},
+		{OrganizationName: "Acme", AdminEmail: "ops@example.com", TotalQuota: -1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestSetupStateOpen(t *testing.T) {
+	if !(SetupState{}).Open() {
+		t.Error("setup should be open on a fresh install")
+	}
+
+	// An upgraded install has users but, before setup existed, no System Admin role
+	for _, state := range []SetupState{
+		{HasUsers: true},
+		{HasMemberships: true},
+		{HasOtherOrganizations: true},
+		{HasSystemAdmin: true},
+	} {
+		if state.Open() {
+			t.Errorf("setup should be locked for %+v", state)
+		}
+	}
+}
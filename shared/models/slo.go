@@ -0,0 +1,237 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SLO objectives
+const (
+	SLOObjectiveAvailability = "availability" // Requests not answered with a 5xx
+	SLOObjectiveLatency      = "latency"      // Successful requests answered within the threshold
+)
+
+// SLO alert levels, from the multi-window burn rate alerts in the Google SRE workbook
+const (
+	SLOAlertNone     = ""
+	SLOAlertWarning  = "warning"  // Burning 6x over both the last 6 hours and 30 minutes
+	SLOAlertCritical = "critical" // Burning 14.4x over both the last hour and 5 minutes
+)
+
+const (
+	sloCriticalBurnRate = 14.4 // Spends 2% of a 30 day budget in an hour
+	sloWarningBurnRate  = 6    // Spends 5% of a 30 day budget in 6 hours
+
+	// sloMinAlertRequests keeps a quiet model's single failure from paging anyone
+	sloMinAlertRequests = 10
+
+	defaultSLOWindowDays = 30
+	maxSLOWindowDays     = 90
+)
+
+// SLO is a reliability objective for a model, an endpoint or both, over the traffic of one
+// organization or of every organization, measured from usage logs over a rolling window
+type SLO struct {
+	ID                 string     `json:"id" db:"id"`
+	Name               string     `json:"name" db:"name"`
+	ModelID            *string    `json:"model_id" db:"model_id"`
+	ModelName          *string    `json:"model_name" db:"model_name"`
+	Endpoint           string     `json:"endpoint" db:"endpoint"` // e.g., "/v1/chat/completions"; empty means every endpoint
+	OrganizationID     *string    `json:"organization_id" db:"organization_id"`
+	Objective          string     `json:"objective" db:"objective"`                       // 'availability', 'latency'
+	Target             float64    `json:"target" db:"target"`                             // Percentage of good requests, e.g. 99.9
+	LatencyThresholdMs *int       `json:"latency_threshold_ms" db:"latency_threshold_ms"` // Latency objectives only
+	WindowDays         int        `json:"window_days" db:"window_days"`
+	IsActive           bool       `json:"is_active" db:"is_active"`
+	AlertLevel         string     `json:"alert_level" db:"alert_level"`
+	AlertedAt          *time.Time `json:"alerted_at" db:"alerted_at"`
+	CreatedBy          *string    `json:"created_by" db:"created_by"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateSLORequest struct {
+	Name               string  `json:"name" binding:"required"`
+	ModelID            *string `json:"model_id"`
+	Endpoint           string  `json:"endpoint"`
+	OrganizationID     *string `json:"organization_id"`
+	Objective          string  `json:"objective" binding:"required"`
+	Target             float64 `json:"target" binding:"required"`
+	LatencyThresholdMs *int    `json:"latency_threshold_ms"`
+	WindowDays         int     `json:"window_days"` // Defaults to 30
+}
+
+// SLO builds the objective the request describes
+func (r CreateSLORequest) SLO() SLO {
+	s := SLO{
+		Name:               strings.TrimSpace(r.Name),
+		ModelID:            r.ModelID,
+		Endpoint:           strings.TrimSpace(r.Endpoint),
+		OrganizationID:     r.OrganizationID,
+		Objective:          r.Objective,
+		Target:             r.Target,
+		LatencyThresholdMs: r.LatencyThresholdMs,
+		WindowDays:         r.WindowDays,
+		IsActive:           true,
+	}
+	if s.ModelID != nil && *s.ModelID == "" {
+		s.ModelID = nil
+	}
+	if s.OrganizationID != nil && *s.OrganizationID == "" {
+		s.OrganizationID = nil
+	}
+	if s.WindowDays == 0 {
+		s.WindowDays = defaultSLOWindowDays
+	}
+	return s
+}
+
+// UpdateSLORequest changes an objective's target or window. What it measures can't change, as
+// its error budget would no longer mean anything; create another SLO instead.
+type UpdateSLORequest struct {
+	Name               *string  `json:"name"`
+	Target             *float64 `json:"target"`
+	LatencyThresholdMs *int     `json:"latency_threshold_ms"`
+	WindowDays         *int     `json:"window_days"`
+	IsActive           *bool    `json:"is_active"`
+}
+
+// Apply sets the fields the request gives on s
+func (r UpdateSLORequest) Apply(s *SLO) {
+	if r.Name != nil {
+		s.Name = strings.TrimSpace(*r.Name)
+	}
+	if r.Target != nil {
+		s.Target = *r.Target
+	}
+	if r.LatencyThresholdMs != nil {
+		s.LatencyThresholdMs = r.LatencyThresholdMs
+	}
+	if r.WindowDays != nil {
+		s.WindowDays = *r.WindowDays
+	}
+	if r.IsActive != nil {
+		s.IsActive = *r.IsActive
+	}
+}
+
+// Validate checks the objective is complete and measurable
+func (s *SLO) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.ModelID == nil && s.Endpoint == "" {
+		return fmt.Errorf("model_id or endpoint is required")
+	}
+	if s.Endpoint != "" && !strings.HasPrefix(s.Endpoint, "/") {
+		return fmt.Errorf("endpoint must be a path, e.g. /v1/chat/completions")
+	}
+	if s.Target <= 0 || s.Target >= 100 {
+		return fmt.Errorf("target must be a percentage above 0 and below 100")
+	}
+	if s.WindowDays < 1 || s.WindowDays > maxSLOWindowDays {
+		return fmt.Errorf("window_days must be between 1 and %d", maxSLOWindowDays)
+	}
+	switch s.Objective {
+	case SLOObjectiveAvailability:
+		s.LatencyThresholdMs = nil
+	case SLOObjectiveLatency:
+		if s.LatencyThresholdMs == nil || *s.LatencyThresholdMs <= 0 {
+			return fmt.Errorf("latency_threshold_ms must be positive for a latency objective")
+		}
+	default:
+		return fmt.Errorf("objective must be %s or %s", SLOObjectiveAvailability, SLOObjectiveLatency)
+	}
+	return nil
+}
+
+// ErrorBudget is the fraction of requests the objective allows to be bad
+func (s *SLO) ErrorBudget() float64 {
+	return 1 - s.Target/100
+}
+
+// SLOCount is how many requests an objective counted over a window, and how many were bad
+type SLOCount struct {
+	Total int64 `json:"total"`
+	Bad   int64 `json:"bad"`
+}
+
+// SLOCounts are an objective's counts over its whole window and the short windows burn rate
+// alerts look at
+type SLOCounts struct {
+	Window        SLOCount `json:"window"`
+	SixHours      SLOCount `json:"six_hours"`
+	OneHour       SLOCount `json:"one_hour"`
+	ThirtyMinutes SLOCount `json:"thirty_minutes"`
+	FiveMinutes   SLOCount `json:"five_minutes"`
+}
+
+// SLOBurnRates are how many times faster than the objective allows its error budget is being
+// spent; 1 spends it exactly by the end of the window
+type SLOBurnRates struct {
+	Window        float64 `json:"window"`
+	SixHours      float64 `json:"six_hours"`
+	OneHour       float64 `json:"one_hour"`
+	ThirtyMinutes float64 `json:"thirty_minutes"`
+	FiveMinutes   float64 `json:"five_minutes"`
+}
+
+// SLOStatus is where an objective stands over its window
+type SLOStatus struct {
+	SLO                  SLO          `json:"slo"`
+	Counts               SLOCounts    `json:"counts"`
+	Attainment           *float64     `json:"attainment"`             // Percentage of good requests; nil without traffic
+	ErrorBudgetRemaining float64      `json:"error_budget_remaining"` // Fraction left; negative once overspent
+	BurnRates            SLOBurnRates `json:"burn_rates"`
+	Level                string       `json:"level"` // Alert level the burn rates call for
+	EvaluatedAt          time.Time    `json:"evaluated_at"`
+}
+
+// BurnRate is how fast the bad requests counted spend the objective's error budget
+func (s *SLO) BurnRate(count SLOCount) float64 {
+	if count.Total == 0 {
+		return 0
+	}
+	return float64(count.Bad) / float64(count.Total) / s.ErrorBudget()
+}
+
+// NewSLOStatus works out the objective's attainment, remaining budget, burn rates and alert
+// level from its counts
+func NewSLOStatus(s SLO, counts SLOCounts, now time.Time) SLOStatus {
+	status := SLOStatus{
+		SLO:                  s,
+		Counts:               counts,
+		ErrorBudgetRemaining: 1,
+		BurnRates: SLOBurnRates{
+			Window:        s.BurnRate(counts.Window),
+			SixHours:      s.BurnRate(counts.SixHours),
+			OneHour:       s.BurnRate(counts.OneHour),
+			ThirtyMinutes: s.BurnRate(counts.ThirtyMinutes),
+			FiveMinutes:   s.BurnRate(counts.FiveMinutes),
+		},
+		EvaluatedAt: now,
+	}
+	if counts.Window.Total > 0 {
+		attainment := float64(counts.Window.Total-counts.Window.Bad) / float64(counts.Window.Total) * 100
+		status.Attainment = &attainment
+		status.ErrorBudgetRemaining = 1 - status.BurnRates.Window
+	}
+
+	rates := status.BurnRates
+	switch {
+	case counts.OneHour.Total >= sloMinAlertRequests && rates.OneHour >= sloCriticalBurnRate && rates.FiveMinutes >= sloCriticalBurnRate:
+		status.Level = SLOAlertCritical
+	case counts.SixHours.Total >= sloMinAlertRequests && rates.SixHours >= sloWarningBurnRate && rates.ThirtyMinutes >= sloWarningBurnRate:
+		status.Level = SLOAlertWarning
+	}
+	return status
+}
+
+// SLODay is an objective's counts for one day of its window, for its dashboard
+type SLODay struct {
+	Date       time.Time `json:"date"`
+	Total      int64     `json:"total"`
+	Bad        int64     `json:"bad"`
+	Attainment *float64  `json:"attainment"`
+}
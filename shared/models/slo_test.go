@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSLOValidate(t *testing.T) {
+	modelID := "model-1"
+	threshold := 2000
+
+	valid := CreateSLORequest{Name: " Chat ", ModelID: &modelID, Objective: SLOObjectiveAvailability, Target: 99.9}.SLO()
+	require.NoError(t, valid.Validate())
+	assert.Equal(t, "Chat", valid.Name)
+	assert.Equal(t, 30, valid.WindowDays)
+	assert.True(t, valid.IsActive)
+
+	latency := CreateSLORequest{Name: "Chat latency", Endpoint: "/v1/chat/completions", Objective: SLOObjectiveLatency, Target: 95, LatencyThresholdMs: &threshold}.SLO()
+	require.NoError(t, latency.Validate())
+
+	empty := ""
+	cases := map[string]SLO{
+		"no scope":           CreateSLORequest{Name: "x", ModelID: &empty, Objective: SLOObjectiveAvailability, Target: 99}.SLO(),
+		"relative endpoint":  CreateSLORequest{Name: "x", Endpoint: "v1/chat", Objective: SLOObjectiveAvailability, Target: 99}.SLO(),
+		"target of 100":      CreateSLORequest{Name: "x", ModelID: &modelID, Objective: SLOObjectiveAvailability, Target: 100}.SLO(),
+		"window too long":    CreateSLORequest{Name: "x", ModelID: &modelID, Objective: SLOObjectiveAvailability, Target: 99, WindowDays: 91}.SLO(),
+		"latency, no limit":  CreateSLORequest{Name: "x", ModelID: &modelID, Objective: SLOObjectiveLatency, Target: 99}.SLO(),
+		"unknown objective":  CreateSLORequest{Name: "x", ModelID: &modelID, Objective: "throughput", Target: 99}.SLO(),
+		"name of whitespace": CreateSLORequest{Name: "  ", ModelID: &modelID, Objective: SLOObjectiveAvailability, Target: 99}.SLO(),
+	}
+	for name, s := range cases {
+		assert.Error(t, s.Validate(), name)
+	}
+}
+
+func TestSLOStatus(t *testing.T) {
+	s := SLO{Objective: SLOObjectiveAvailability, Target: 99, WindowDays: 30}
+	now := time.Now()
+
+	quiet := NewSLOStatus(s, SLOCounts{}, now)
+	assert.Nil(t, quiet.Attainment)
+	assert.Equal(t, 1.0, quiet.ErrorBudgetRemaining)
+	assert.Equal(t, SLOAlertNone, quiet.Level)
+
+	// Half the budget spent over the window
+	healthy := NewSLOStatus(s, SLOCounts{Window: SLOCount{Total: 1000, Bad: 5}}, now)
+	require.NotNil(t, healthy.Attainment)
+	assert.InDelta(t, 99.5, *healthy.Attainment, 1e-9)
+	assert.InDelta(t, 0.5, healthy.ErrorBudgetRemaining, 1e-9)
+	assert.InDelta(t, 0.5, healthy.BurnRates.Window, 1e-9)
+
+	outage := SLOCounts{
+		Window:        SLOCount{Total: 1000, Bad: 300},
+		SixHours:      SLOCount{Total: 200, Bad: 100},
+		OneHour:       SLOCount{Total: 100, Bad: 50},
+		ThirtyMinutes: SLOCount{Total: 50, Bad: 25},
+		FiveMinutes:   SLOCount{Total: 20, Bad: 10},
+	}
+	critical := NewSLOStatus(s, outage, now)
+	assert.Equal(t, SLOAlertCritical, critical.Level)
+	assert.InDelta(t, 50, critical.BurnRates.OneHour, 1e-9)
+	assert.Less(t, critical.ErrorBudgetRemaining, 0.0)
+
+	// Recovered in the last five minutes: the long window alone doesn't page
+	outage.FiveMinutes = SLOCount{Total: 20}
+	assert.Equal(t, SLOAlertWarning, NewSLOStatus(s, outage, now).Level)
+
+	// Too few requests to alert on
+	sparse := SLOCounts{SixHours: SLOCount{Total: 3, Bad: 3}, OneHour: SLOCount{Total: 3, Bad: 3}, ThirtyMinutes: SLOCount{Total: 3, Bad: 3}, FiveMinutes: SLOCount{Total: 3, Bad: 3}}
+	assert.Equal(t, SLOAlertNone, NewSLOStatus(s, sparse, now).Level)
+}
+
+func TestUpdateSLORequestApply(t *testing.T) {
+	threshold := 1500
+	s := SLO{Name: "Chat", Objective: SLOObjectiveLatency, Target: 95, LatencyThresholdMs: &threshold, WindowDays: 30, IsActive: true}
+
+	target, days, active := 99.0, 7, false
+	UpdateSLORequest{Target: &target, WindowDays: &days, IsActive: &active}.Apply(&s)
+	assert.Equal(t, 99.0, s.Target)
+	assert.Equal(t, 7, s.WindowDays)
+	assert.False(t, s.IsActive)
+	assert.Equal(t, "Chat", s.Name)
+	assert.Equal(t, 1500, *s.LatencyThresholdMs)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StreamPacing smooths an organization's streamed responses on an endpoint to a steady token
+// rate. Responses are delivered as fast as the provider sends them unless a rule is active.
+type StreamPacing struct {
+	ID              string    `json:"id" db:"id"`
+	OrganizationID  string    `json:"organization_id" db:"organization_id"`
+	Endpoint        string    `json:"endpoint" db:"endpoint"` // e.g. /v1/chat/completions
+	TokensPerSecond int       `json:"tokens_per_second" db:"tokens_per_second"`
+	BurstTokens     int       `json:"burst_tokens" db:"burst_tokens"` // How far delivery may run ahead of the rate
+	IsActive        bool      `json:"is_active" db:"is_active"`
+	CreatedBy       *string   `json:"created_by" db:"created_by"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SaveStreamPacingRequest sets the organization's pacing for an endpoint, replacing any it had
+type SaveStreamPacingRequest struct {
+	Endpoint        string `json:"endpoint" binding:"required"`
+	TokensPerSecond int    `json:"tokens_per_second" binding:"required"`
+	BurstTokens     int    `json:"burst_tokens"`
+	IsActive        *bool  `json:"is_active"`
+}
+
+// Validate checks the endpoint is a path and the rate and burst are in range, and defaults the
+// rule to active
+func (r *SaveStreamPacingRequest) Validate() error {
+	r.Endpoint = strings.TrimSpace(r.Endpoint)
+	if !strings.HasPrefix(r.Endpoint, "/") || len(r.Endpoint) > 255 {
+		return fmt.Errorf("endpoint must be a path such as /v1/chat/completions")
+	}
+	if r.TokensPerSecond < 1 || r.TokensPerSecond > 10000 {
+		return fmt.Errorf("tokens_per_second must be between 1 and 10000")
+	}
+	if r.BurstTokens < 0 || r.BurstTokens > 100000 {
+		return fmt.Errorf("burst_tokens must be between 0 and 100000")
+	}
+	if r.IsActive == nil {
+		active := true
+		r.IsActive = &active
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestSaveStreamPacingRequestValidate(t *testing.T) {
+	req := SaveStreamPacingRequest{Endpoint: " /v1/chat/completions ", TokensPerSecond: 30}
+	if err := req.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if req.Endpoint != "/v1/chat/completions" || req.IsActive == nil || !*req.IsActive {
+		t.Errorf("expected a trimmed, active rule, got %+v", req)
+	}
+
+	invalid := []SaveStreamPacingRequest{
+		{Endpoint: "v1/chat/completions", TokensPerSecond: 30},
+		{Endpoint: "/v1/chat/completions", TokensPerSecond: 0},
+		{Endpoint: "/v1/chat/completions", TokensPerSecond: 10001},
+		{Endpoint: "/v1/chat/completions", TokensPerSecond: 30, BurstTokens: -1},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", r)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+// DeploymentStats are the raw counts opt-in telemetry is built from. They never leave the
+// gateway as they are: the reporter hashes the ID and buckets the volumes.
+type DeploymentStats struct {
+	DeploymentID   string         // Oldest organization's ID, stable for the life of the database
+	Organizations  int64          // Organizations
+	ProviderModels map[string]int // Active models by provider
+	Requests24h    int64          // Requests logged in the last 24 hours
+}
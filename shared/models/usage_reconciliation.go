@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// DailyModelUsage is one model's token usage for one UTC day, as counted by the gateway or
+// reported by a provider's usage export
+type DailyModelUsage struct {
+	Date             string `json:"date"` // YYYY-MM-DD
+	Model            string `json:"model"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	Requests         int64  `json:"requests"`
+}
+
+// TotalTokens returns prompt plus completion tokens
+func (u DailyModelUsage) TotalTokens() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageDiscrepancy is a day and model where the gateway's token count and the provider's differ
+// by more than the reconciliation threshold
+type UsageDiscrepancy struct {
+	ID               string    `json:"id" db:"id"`
+	Source           string    `json:"source" db:"source"` // e.g. "openai_api" or "csv"
+	Provider         string    `json:"provider" db:"provider"`
+	UsageDate        string    `json:"usage_date" db:"usage_date"`
+	Model            string    `json:"model" db:"model"`
+	GatewayTokens    int64     `json:"gateway_tokens" db:"gateway_tokens"`
+	ProviderTokens   int64     `json:"provider_tokens" db:"provider_tokens"`
+	GatewayRequests  int64     `json:"gateway_requests" db:"gateway_requests"`
+	ProviderRequests int64     `json:"provider_requests" db:"provider_requests"`
+	DifferencePct    float64   `json:"difference_pct" db:"difference_pct"` // Relative to the provider's count
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// UsageReconciliationResult summarizes one reconciliation run
+type UsageReconciliationResult struct {
+	Source        string             `json:"source"`
+	Provider      string             `json:"provider"`
+	StartDate     string             `json:"start_date"`
+	EndDate       string             `json:"end_date"`
+	DaysCompared  int                `json:"days_compared"`
+	ThresholdPct  float64            `json:"threshold_pct"`
+	Discrepancies []UsageDiscrepancy `json:"discrepancies"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -49,6 +50,7 @@ type UserOrgMembership struct {
 	OrgID    string `json:"org_id"`
 	OrgName  string `json:"org_name"`
 	RoleName string `json:"role_name"`
+	Source   string `json:"source"` // MembershipSourceADSync or MembershipSourceManual
 }
 
 // UserWithOrganizations represents a user with their organization memberships
@@ -56,3 +58,51 @@ type UserWithOrganizations struct {
 	User
 	Organizations []UserOrgMembership `json:"organizations"`
 }
+
+// Organization roles stored in user_organizations.role_name
+const (
+	OrgRoleAdmin     = "admin"
+	OrgRoleMember    = "member"
+	OrgRoleDeveloper = "developer" // Self-service: own API keys, own usage and the API docs
+)
+
+// Membership sources stored in user_organizations.source
+const (
+	MembershipSourceADSync = "ad_sync" // Added and removed by AD group sync
+	MembershipSourceManual = "manual"  // Pinned by an admin; AD group sync leaves it alone
+)
+
+// AddOrganizationMemberRequest grants a user access to an organization outside AD group sync,
+// e.g. a contractor who isn't in the mapped groups
+type AddOrganizationMemberRequest struct {
+	Email    string `json:"email" binding:"required"`
+	RoleName string `json:"role_name" binding:"required"`
+}
+
+// Validate checks the role name is well-formed; whether a custom role exists is checked per organization
+func (r *AddOrganizationMemberRequest) Validate() error {
+	return validateOrgRoleName(r.RoleName)
+}
+
+// UpdateMembershipSourceRequest pins a membership (manual) or hands it back to AD group sync
+type UpdateMembershipSourceRequest struct {
+	Source string `json:"source" binding:"required"`
+}
+
+// Validate checks the source is known
+func (r *UpdateMembershipSourceRequest) Validate() error {
+	if r.Source != MembershipSourceADSync && r.Source != MembershipSourceManual {
+		return fmt.Errorf("source must be %q or %q", MembershipSourceADSync, MembershipSourceManual)
+	}
+	return nil
+}
+
+// UpdateUserOrganizationRoleRequest changes a user's role within an organization
+type UpdateUserOrganizationRoleRequest struct {
+	RoleName string `json:"role_name" binding:"required"`
+}
+
+// Validate checks the role name is well-formed; whether a custom role exists is checked per organization
+func (r *UpdateUserOrganizationRoleRequest) Validate() error {
+	return validateOrgRoleName(r.RoleName)
+}
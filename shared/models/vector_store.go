@@ -0,0 +1,172 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Vector collection limits
+const (
+	MaxVectorDimensions         = 16000 // pgvector's limit for stored vectors
+	MaxVectorDocumentsPerUpsert = 100
+	MaxVectorDocumentTextBytes  = 32 * 1024
+	MaxVectorMetadataBytes      = 8 * 1024
+	DefaultVectorQueryTopK      = 10
+	MaxVectorQueryTopK          = 100
+)
+
+var (
+	vectorCollectionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{0,63}$`)
+	vectorDocumentIDPattern     = regexp.MustCompile(`^[^\s/]{1,255}$`)
+)
+
+// VectorCollection is an organization's named set of embedded documents. Every document in it
+// has an embedding of Dimensions values.
+type VectorCollection struct {
+	ID             string    `json:"id"`
+	OrganizationID string    `json:"organization_id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description"`
+	Dimensions     int       `json:"dimensions"`
+	EmbeddingModel string    `json:"embedding_model,omitempty"` // Model ID that embeds text sent without an embedding
+	DocumentCount  int       `json:"document_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type CreateVectorCollectionRequest struct {
+	Name           string `json:"name" binding:"required"`
+	Description    string `json:"description"`
+	Dimensions     int    `json:"dimensions" binding:"required"`
+	EmbeddingModel string `json:"embedding_model"`
+}
+
+// Validate checks the name can be used in a URL and the dimensions can be stored
+func (r *CreateVectorCollectionRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.EmbeddingModel = strings.TrimSpace(r.EmbeddingModel)
+	if !vectorCollectionNamePattern.MatchString(r.Name) {
+		return fmt.Errorf("name must be 1-64 letters, digits, '-' or '_', starting with a letter or digit")
+	}
+	if r.Dimensions < 1 || r.Dimensions > MaxVectorDimensions {
+		return fmt.Errorf("dimensions must be between 1 and %d", MaxVectorDimensions)
+	}
+	if len(r.Description) > 1000 {
+		return fmt.Errorf("description must be at most 1000 characters")
+	}
+	return nil
+}
+
+// VectorDocument is a piece of text, its embedding and metadata to filter on
+type VectorDocument struct {
+	ID        string                 `json:"id"`
+	Text      string                 `json:"text,omitempty"`
+	Embedding []float32              `json:"embedding,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at,omitempty"`
+	UpdatedAt time.Time              `json:"updated_at,omitempty"`
+}
+
+// UpsertVectorDocumentsRequest adds documents to a collection, replacing those with the same ID.
+// Documents without an embedding are embedded from their text by the collection's embedding model.
+type UpsertVectorDocumentsRequest struct {
+	Documents []VectorDocument `json:"documents" binding:"required"`
+}
+
+// Validate checks every document against the collection and reports whether any needs its text
+// embedded
+func (r *UpsertVectorDocumentsRequest) Validate(collection *VectorCollection) (needsEmbedding bool, err error) {
+	if len(r.Documents) == 0 || len(r.Documents) > MaxVectorDocumentsPerUpsert {
+		return false, fmt.Errorf("documents must have between 1 and %d entries", MaxVectorDocumentsPerUpsert)
+	}
+	seen := map[string]bool{}
+	for i, doc := range r.Documents {
+		if !vectorDocumentIDPattern.MatchString(doc.ID) {
+			return false, fmt.Errorf("documents[%d].id must be 1-255 characters without spaces or '/'", i)
+		}
+		if seen[doc.ID] {
+			return false, fmt.Errorf("documents[%d].id %q is repeated", i, doc.ID)
+		}
+		seen[doc.ID] = true
+		if len(doc.Text) > MaxVectorDocumentTextBytes {
+			return false, fmt.Errorf("documents[%d].text is larger than %d bytes", i, MaxVectorDocumentTextBytes)
+		}
+		if err := checkVectorMetadata(doc.Metadata); err != nil {
+			return false, fmt.Errorf("documents[%d].%v", i, err)
+		}
+		switch {
+		case len(doc.Embedding) > 0:
+			if len(doc.Embedding) != collection.Dimensions {
+				return false, fmt.Errorf("documents[%d].embedding has %d dimensions, the collection has %d", i, len(doc.Embedding), collection.Dimensions)
+			}
+		case strings.TrimSpace(doc.Text) == "":
+			return false, fmt.Errorf("documents[%d] needs an embedding or text", i)
+		case collection.EmbeddingModel == "":
+			return false, fmt.Errorf("documents[%d] has no embedding and the collection has no embedding_model to embed its text", i)
+		default:
+			needsEmbedding = true
+		}
+	}
+	return needsEmbedding, nil
+}
+
+// VectorQueryRequest finds the documents closest to an embedding, or to text embedded by the
+// collection's embedding model. Filter keeps documents whose metadata contains it.
+type VectorQueryRequest struct {
+	Embedding         []float32              `json:"embedding"`
+	Text              string                 `json:"text"`
+	TopK              int                    `json:"top_k"`
+	Filter            map[string]interface{} `json:"filter"`
+	MinScore          *float64               `json:"min_score"` // Cosine similarity, -1 to 1
+	IncludeEmbeddings bool                   `json:"include_embeddings"`
+}
+
+// Validate fills in the default top_k and checks the query against the collection
+func (r *VectorQueryRequest) Validate(collection *VectorCollection) error {
+	if r.TopK == 0 {
+		r.TopK = DefaultVectorQueryTopK
+	}
+	if r.TopK < 1 || r.TopK > MaxVectorQueryTopK {
+		return fmt.Errorf("top_k must be between 1 and %d", MaxVectorQueryTopK)
+	}
+	if r.MinScore != nil && (*r.MinScore < -1 || *r.MinScore > 1) {
+		return fmt.Errorf("min_score must be between -1 and 1")
+	}
+	if err := checkVectorMetadata(r.Filter); err != nil {
+		return fmt.Errorf("filter: %v", err)
+	}
+	switch {
+	case len(r.Embedding) > 0:
+		if len(r.Embedding) != collection.Dimensions {
+			return fmt.Errorf("embedding has %d dimensions, the collection has %d", len(r.Embedding), collection.Dimensions)
+		}
+	case strings.TrimSpace(r.Text) == "":
+		return fmt.Errorf("embedding or text is required")
+	case collection.EmbeddingModel == "":
+		return fmt.Errorf("the collection has no embedding_model to embed text; send an embedding")
+	}
+	return nil
+}
+
+// VectorQueryMatch is a document found by a query, with its cosine similarity to the query
+type VectorQueryMatch struct {
+	VectorDocument
+	Score float64 `json:"score"`
+}
+
+func checkVectorMetadata(metadata map[string]interface{}) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("metadata must be a JSON object")
+	}
+	if len(encoded) > MaxVectorMetadataBytes {
+		return fmt.Errorf("metadata is larger than %d bytes", MaxVectorMetadataBytes)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import "testing"
+
+func TestCreateVectorCollectionRequestValidate(t *testing.T) {
+	r := CreateVectorCollectionRequest{Name: " handbook ", Dimensions: 1536, EmbeddingModel: " text-embedding-3-small "}
+	if err := r.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if r.Name != "handbook" || r.EmbeddingModel != "text-embedding-3-small" {
+		t.Errorf("Validate() left %q, %q untrimmed", r.Name, r.EmbeddingModel)
+	}
+
+	for _, bad := range []CreateVectorCollectionRequest{
+		{Name: "has space", Dimensions: 3},
+		{Name: "-leading", Dimensions: 3},
+		{Name: "ok", Dimensions: 0},
+		{Name: "ok", Dimensions: MaxVectorDimensions + 1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", bad)
+		}
+	}
+}
+
+func TestUpsertVectorDocumentsRequestValidate(t *testing.T) {
+	collection := &VectorCollection{Dimensions: 3}
+	r := UpsertVectorDocumentsRequest{Documents: []VectorDocument{
+		{ID: "a", Embedding: []float32{1, 0, 0}, Metadata: map[string]interface{}{"team": "hr"}},
+	}}
+	needsEmbedding, err := r.Validate(collection)
+	if err != nil || needsEmbedding {
+		t.Fatalf("Validate() = %v, %v; want false, nil", needsEmbedding, err)
+	}
+
+	textOnly := UpsertVectorDocumentsRequest{Documents: []VectorDocument{{ID: "b", Text: "Vacation policy"}}}
+	if _, err := textOnly.Validate(collection); err == nil {
+		t.Error("expected text without an embedding model to be rejected")
+	}
+	collection.EmbeddingModel = "text-embedding-3-small"
+	if needsEmbedding, err := textOnly.Validate(collection); err != nil || !needsEmbedding {
+		t.Errorf("Validate() = %v, %v; want true, nil", needsEmbedding, err)
+	}
+
+	for name, docs := range map[string][]VectorDocument{
+		"wrong dimensions": {{ID: "a", Embedding: []float32{1, 0}}},
+		"repeated id":      {{ID: "a", Text: "x"}, {ID: "a", Text: "y"}},
+		"slash in id":      {{ID: "a/b", Text: "x"}},
+		"empty":            {{ID: "a"}},
+	} {
+		r := UpsertVectorDocumentsRequest{Documents: docs}
+		if _, err := r.Validate(collection); err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", name)
+		}
+	}
+}
+
+func TestVectorQueryRequestValidate(t *testing.T) {
+	collection := &VectorCollection{Dimensions: 2}
+	r := VectorQueryRequest{Embedding: []float32{0.5, 0.5}}
+	if err := r.Validate(collection); err != nil || r.TopK != DefaultVectorQueryTopK {
+		t.Errorf("Validate() = %v, TopK = %d; want nil, %d", err, r.TopK, DefaultVectorQueryTopK)
+	}
+
+	minScore := 2.0
+	for name, q := range map[string]VectorQueryRequest{
+		"no query":         {},
+		"text, no model":   {Text: "vacation"},
+		"wrong dimensions": {Embedding: []float32{1}},
+		"top_k too large":  {Embedding: []float32{1, 0}, TopK: MaxVectorQueryTopK + 1},
+		"min_score > 1":    {Embedding: []float32{1, 0}, MinScore: &minScore},
+	} {
+		if err := q.Validate(collection); err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxWasmPolicySize caps an uploaded policy module
+	MaxWasmPolicySize = 8 << 20
+	// MaxWasmPolicyMemoryPages is the most memory a policy may ask for: 4096 64 KiB pages, 256 MiB
+	MaxWasmPolicyMemoryPages = 4096
+)
+
+// WasmPolicy is an organization's WebAssembly policy module, run on its requests and responses.
+// The module itself is only loaded by the gateway.
+type WasmPolicy struct {
+	ID             string    `json:"id" db:"id"`
+	OrganizationID string    `json:"organization_id" db:"organization_id"`
+	Name           string    `json:"name" db:"name"`
+	SHA256         string    `json:"sha256" db:"sha256"`
+	SizeBytes      int       `json:"size_bytes" db:"size_bytes"`
+	FuelLimit      *int64    `json:"fuel_limit" db:"fuel_limit"`             // Function calls per call; nil for the gateway's default
+	MaxMemoryPages *int      `json:"max_memory_pages" db:"max_memory_pages"` // 64 KiB pages; nil for the gateway's default
+	IsActive       bool      `json:"is_active" db:"is_active"`
+	CreatedBy      *string   `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CreateWasmPolicyRequest uploads a module. Module is base64 in JSON.
+type CreateWasmPolicyRequest struct {
+	Name           string `json:"name" binding:"required"`
+	Module         []byte `json:"module" binding:"required"`
+	FuelLimit      *int64 `json:"fuel_limit"`
+	MaxMemoryPages *int   `json:"max_memory_pages"`
+	IsActive       *bool  `json:"is_active"`
+}
+
+// Validate trims the name and checks the module's size and the limits
+func (r *CreateWasmPolicyRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > 255 {
+		return fmt.Errorf("name must be between 1 and 255 characters")
+	}
+	return validateWasmPolicy(r.Module, r.FuelLimit, r.MaxMemoryPages)
+}
+
+// UpdateWasmPolicyRequest changes the fields given. A new Module replaces the running one at
+// the gateway's next refresh.
+type UpdateWasmPolicyRequest struct {
+	Name           *string `json:"name"`
+	Module         []byte  `json:"module"`
+	FuelLimit      *int64  `json:"fuel_limit"`
+	MaxMemoryPages *int    `json:"max_memory_pages"`
+	IsActive       *bool   `json:"is_active"`
+}
+
+// Validate checks the fields given
+func (r *UpdateWasmPolicyRequest) Validate() error {
+	if r.Name != nil {
+		name := strings.TrimSpace(*r.Name)
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("name must be between 1 and 255 characters")
+		}
+		r.Name = &name
+	}
+	if r.Module != nil && len(r.Module) == 0 {
+		return fmt.Errorf("module must not be empty")
+	}
+	return validateWasmPolicy(r.Module, r.FuelLimit, r.MaxMemoryPages)
+}
+
+func validateWasmPolicy(module []byte, fuel *int64, pages *int) error {
+	if len(module) > MaxWasmPolicySize {
+		return fmt.Errorf("module must be at most %d MiB", MaxWasmPolicySize>>20)
+	}
+	if fuel != nil && *fuel <= 0 {
+		return fmt.Errorf("fuel_limit must be positive")
+	}
+	if pages != nil && (*pages < 1 || *pages > MaxWasmPolicyMemoryPages) {
+		return fmt.Errorf("max_memory_pages must be between 1 and %d", MaxWasmPolicyMemoryPages)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+const (
+	// defaultRetentionDays is how long the notification center keeps alerts
+	defaultRetentionDays = 90
+
+	retentionInterval = 24 * time.Hour
+)
+
+// Record keeps msg in the admin UI's notification center: for the organization's admins, or for
+// System Admins when orgID is empty. It always succeeds from the caller's point of view, so an
+// alert is never held back for it; failures are logged.
+func Record(database *sql.DB, orgID, event string, msg Message) {
+	if readonly.Enabled() {
+		return
+	}
+
+	notification := models.Notification{
+		EventType: event,
+		Severity:  msg.Severity,
+		Title:     msg.Title,
+		Body:      msg.Text,
+		LinkURL:   msg.URL,
+	}
+	if notification.Severity == "" {
+		notification.Severity = SeverityInfo
+	}
+	if orgID != "" {
+		notification.OrganizationID = &orgID
+	}
+	for _, f := range msg.Fields {
+		notification.Fields = append(notification.Fields, models.NotificationField{Name: f.Name, Value: f.Value})
+	}
+
+	if err := db.CreateNotification(database, &notification); err != nil {
+		log.Printf("Failed to record %s notification for org %q: %v", event, orgID, err)
+	}
+}
+
+// retentionDays returns how many days of notifications are kept, from NOTIFICATION_RETENTION_DAYS
+func retentionDays() int {
+	days, err := strconv.Atoi(os.Getenv("NOTIFICATION_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		return defaultRetentionDays
+	}
+	return days
+}
+
+// StartRetention removes notifications older than the retention period once a day. The
+// returned func stops it.
+func StartRetention(database *sql.DB) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if readonly.Enabled() {
+					continue
+				}
+				removed, err := db.DeleteNotificationsBefore(database, time.Now().AddDate(0, 0, -retentionDays()))
+				if err != nil {
+					log.Printf("Failed to prune notifications: %v", err)
+				} else if removed > 0 {
+					log.Printf("Pruned %d notifications older than %d days", removed, retentionDays())
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
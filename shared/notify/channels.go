@@ -0,0 +1,168 @@
+// Package notify delivers gateway alerts to chat channels (Slack incoming webhooks, Microsoft
+// Teams connectors) alongside email.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Channel types
+const (
+	ChannelTypeSlack = "slack"
+	ChannelTypeTeams = "teams"
+)
+
+// Severity levels, used to colour messages
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Field is a labelled value shown in a message
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Message is a channel-agnostic alert
+type Message struct {
+	Title    string
+	Text     string
+	Severity string
+	Fields   []Field
+	URL      string // Optional link to the relevant admin page
+}
+
+// Channel sends messages to an external chat service
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// httpClient is shared by all webhook channels
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewChannel creates a channel of the given type posting to webhookURL
+func NewChannel(channelType, webhookURL string) (Channel, error) {
+	switch channelType {
+	case ChannelTypeSlack:
+		return &SlackChannel{WebhookURL: webhookURL}, nil
+	case ChannelTypeTeams:
+		return &TeamsChannel{WebhookURL: webhookURL}, nil
+	default:
+		return nil, fmt.Errorf("unsupported channel type: %s", channelType)
+	}
+}
+
+// IsValidChannelType reports whether t is a supported channel type
+func IsValidChannelType(t string) bool {
+	return t == ChannelTypeSlack || t == ChannelTypeTeams
+}
+
+// SlackChannel posts to a Slack incoming webhook
+type SlackChannel struct {
+	WebhookURL string
+}
+
+// Send posts the message as a Slack attachment
+func (s *SlackChannel) Send(ctx context.Context, msg Message) error {
+	fields := make([]map[string]interface{}, 0, len(msg.Fields))
+	for _, f := range msg.Fields {
+		fields = append(fields, map[string]interface{}{"title": f.Name, "value": f.Value, "short": true})
+	}
+
+	attachment := map[string]interface{}{
+		"color":  severityColor(msg.Severity),
+		"title":  msg.Title,
+		"text":   msg.Text,
+		"fields": fields,
+	}
+	if msg.URL != "" {
+		attachment["title_link"] = msg.URL
+	}
+
+	payload := map[string]interface{}{
+		"text":        msg.Title,
+		"attachments": []interface{}{attachment},
+	}
+
+	return postJSON(ctx, s.WebhookURL, payload)
+}
+
+// TeamsChannel posts to a Microsoft Teams incoming webhook connector
+type TeamsChannel struct {
+	WebhookURL string
+}
+
+// Send posts the message as an Office 365 connector card
+func (t *TeamsChannel) Send(ctx context.Context, msg Message) error {
+	facts := make([]map[string]string, 0, len(msg.Fields))
+	for _, f := range msg.Fields {
+		facts = append(facts, map[string]string{"name": f.Name, "value": f.Value})
+	}
+
+	payload := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    msg.Title,
+		"themeColor": severityColor(msg.Severity)[1:],
+		"title":      msg.Title,
+		"sections": []interface{}{
+			map[string]interface{}{"text": msg.Text, "facts": facts},
+		},
+	}
+	if msg.URL != "" {
+		payload["potentialAction"] = []interface{}{
+			map[string]interface{}{
+				"@type":   "OpenUri",
+				"name":    "Open RelAI Gateway",
+				"targets": []interface{}{map[string]string{"os": "default", "uri": msg.URL}},
+			},
+		}
+	}
+
+	return postJSON(ctx, t.WebhookURL, payload)
+}
+
+func severityColor(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "#dc3545"
+	case SeverityWarning:
+		return "#ffc107"
+	default:
+		return "#007bff"
+	}
+}
+
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
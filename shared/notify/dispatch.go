@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// HasChannels reports whether the organization has an enabled channel for event
+func HasChannels(database *sql.DB, orgID, event string) bool {
+	channels, err := db.GetEnabledNotificationChannelsForEvent(database, orgID, event)
+	if err != nil {
+		log.Printf("Failed to get notification channels for org %s: %v", orgID, err)
+		return false
+	}
+	return len(channels) > 0
+}
+
+// Dispatch sends msg to every enabled channel of the organization subscribed to event and returns
+// how many channels accepted it. Failures are logged; one broken webhook doesn't block the others.
+func Dispatch(database *sql.DB, orgID, event string, msg Message) int {
+	channels, err := db.GetEnabledNotificationChannelsForEvent(database, orgID, event)
+	if err != nil {
+		log.Printf("Failed to get notification channels for org %s: %v", orgID, err)
+		return 0
+	}
+
+	delivered := 0
+	for _, channel := range channels {
+		if err := SendTo(context.Background(), channel, msg); err != nil {
+			log.Printf("Failed to send %s notification to %s channel %q: %v", event, channel.Type, channel.Name, err)
+			continue
+		}
+		delivered++
+	}
+
+	return delivered
+}
+
+// SendTo delivers a message to a single configured channel
+func SendTo(ctx context.Context, channel models.NotificationChannel, msg Message) error {
+	ch, err := NewChannel(channel.Type, channel.WebhookURL)
+	if err != nil {
+		return err
+	}
+	return ch.Send(ctx, msg)
+}
@@ -0,0 +1,171 @@
+// Package opa delegates the gateway's authorization decisions to an Open Policy Agent server.
+// The deployment bundles its Rego policies into the OPA server; the gateway asks it, for each
+// proxied request, whether this key may use this model on this path with this payload.
+package opa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPath    = "relai/authz"
+	defaultTimeout = time.Second
+)
+
+// Config locates the policy decision
+type Config struct {
+	URL      string        // OPA server, e.g. http://localhost:8181; empty disables the integration
+	Path     string        // Package or rule of the decision, e.g. relai/authz
+	Timeout  time.Duration // Per decision
+	FailOpen bool          // Allow requests when OPA can't be reached rather than refusing them
+	LogAll   bool          // Log allowed decisions too, not only denials
+}
+
+// Enabled reports whether decisions are delegated to OPA
+func (c Config) Enabled() bool {
+	return c.URL != ""
+}
+
+// ConfigFromEnv reads OPA_URL, OPA_DECISION_PATH (default relai/authz), OPA_TIMEOUT (default
+// 1s), OPA_FAIL_OPEN and OPA_LOG_ALL_DECISIONS
+func ConfigFromEnv() Config {
+	cfg := Config{
+		URL:  strings.TrimRight(os.Getenv("OPA_URL"), "/"),
+		Path: strings.Trim(os.Getenv("OPA_DECISION_PATH"), "/"),
+	}
+	if cfg.Path == "" {
+		cfg.Path = defaultPath
+	}
+	cfg.Timeout, _ = time.ParseDuration(os.Getenv("OPA_TIMEOUT"))
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	switch os.Getenv("OPA_FAIL_OPEN") {
+	case "1", "true":
+		cfg.FailOpen = true
+	}
+	switch os.Getenv("OPA_LOG_ALL_DECISIONS") {
+	case "1", "true":
+		cfg.LogAll = true
+	}
+	return cfg
+}
+
+// Input is what the policy decides on, available to Rego as input
+type Input struct {
+	RequestID      string   `json:"request_id"`
+	OrganizationID string   `json:"organization_id"`
+	APIKeyID       string   `json:"api_key_id"`
+	Scopes         []string `json:"scopes"`
+	Ephemeral      bool     `json:"ephemeral"`
+	Model          string   `json:"model"`
+	Provider       string   `json:"provider"`
+	Method         string   `json:"method"`
+	Path           string   `json:"path"`
+	PayloadBytes   int      `json:"payload_bytes"`
+	ClientIP       string   `json:"client_ip"`
+}
+
+// Decision is the policy's answer
+type Decision struct {
+	Allow      bool
+	Reason     string
+	Status     int    // Status to refuse with; 403 unless the policy says otherwise
+	DecisionID string // OPA's decision ID, when its own decision logging is on
+}
+
+// Client asks an OPA server for decisions
+type Client struct {
+	cfg  Config
+	http *http.Client
+}
+
+// NewClient returns a client for the configured server
+func NewClient(cfg Config) *Client {
+	return &Client{cfg: cfg, http: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// Config returns the client's configuration
+func (c *Client) Config() Config {
+	return c.cfg
+}
+
+// dataResponse is OPA's answer from the data API. Result is absent when the policy doesn't
+// define the decision for the input.
+type dataResponse struct {
+	Result     json.RawMessage `json:"result"`
+	DecisionID string          `json:"decision_id"`
+}
+
+// result is the object form of a decision; a bare boolean is also accepted
+type result struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+	Status int    `json:"status"`
+}
+
+// Decide evaluates the policy for input. An undefined decision denies.
+func (c *Client) Decide(ctx context.Context, input Input) (*Decision, error) {
+	payload, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL+"/v1/data/"+c.cfg.Path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OPA answered %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var data dataResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("invalid OPA response: %w", err)
+	}
+	decision, err := parseResult(data.Result)
+	if err != nil {
+		return nil, err
+	}
+	decision.DecisionID = data.DecisionID
+	return decision, nil
+}
+
+func parseResult(raw json.RawMessage) (*Decision, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return &Decision{Reason: "policy decision is undefined", Status: http.StatusForbidden}, nil
+	}
+
+	var allow bool
+	if err := json.Unmarshal(raw, &allow); err == nil {
+		return &Decision{Allow: allow, Status: http.StatusForbidden}, nil
+	}
+	var r result
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return nil, errors.New(`decision must be a boolean or an object with "allow"`)
+	}
+	d := &Decision{Allow: r.Allow, Reason: r.Reason, Status: r.Status}
+	if d.Status < 400 || d.Status > 599 {
+		d.Status = http.StatusForbidden
+	}
+	return d, nil
+}
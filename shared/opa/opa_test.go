@@ -0,0 +1,92 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOPA answers the data API with whatever answer returns for the input
+func fakeOPA(t *testing.T, answer func(input Input) string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/data/relai/authz", r.URL.Path)
+		var body struct {
+			Input Input `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(answer(body.Input)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDecide(t *testing.T) {
+	server := fakeOPA(t, func(input Input) string {
+		switch {
+		case input.PayloadBytes > 1000:
+			return `{"result": {"allow": false, "reason": "too big", "status": 413}, "decision_id": "d1"}`
+		case input.Model == "undefined":
+			return `{}`
+		case input.Model == "bare":
+			return `{"result": true}`
+		default:
+			return `{"result": {"allow": true}}`
+		}
+	})
+	client := NewClient(Config{URL: server.URL, Path: "relai/authz", Timeout: time.Second})
+	ctx := context.Background()
+
+	d, err := client.Decide(ctx, Input{Model: "gpt-4o", PayloadBytes: 10})
+	require.NoError(t, err)
+	assert.True(t, d.Allow)
+
+	d, err = client.Decide(ctx, Input{PayloadBytes: 5000})
+	require.NoError(t, err)
+	assert.Equal(t, &Decision{Allow: false, Reason: "too big", Status: 413, DecisionID: "d1"}, d)
+
+	d, err = client.Decide(ctx, Input{Model: "bare"})
+	require.NoError(t, err)
+	assert.True(t, d.Allow)
+
+	d, err = client.Decide(ctx, Input{Model: "undefined"})
+	require.NoError(t, err)
+	assert.False(t, d.Allow, "an undefined decision denies")
+	assert.Equal(t, http.StatusForbidden, d.Status)
+}
+
+func TestDecideErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code": "internal_error"}`, http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	_, err := NewClient(Config{URL: server.URL, Path: "relai/authz", Timeout: time.Second}).Decide(context.Background(), Input{})
+	assert.ErrorContains(t, err, "500")
+
+	_, err = NewClient(Config{URL: "http://127.0.0.1:1", Path: "relai/authz", Timeout: time.Second}).Decide(context.Background(), Input{})
+	assert.Error(t, err)
+
+	_, err = parseResult(json.RawMessage(`"yes"`))
+	assert.Error(t, err)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("OPA_URL", "http://opa:8181/")
+	t.Setenv("OPA_DECISION_PATH", "/acme/gateway/")
+	t.Setenv("OPA_FAIL_OPEN", "true")
+	cfg := ConfigFromEnv()
+	assert.True(t, cfg.Enabled())
+	assert.Equal(t, "http://opa:8181", cfg.URL)
+	assert.Equal(t, "acme/gateway", cfg.Path)
+	assert.Equal(t, time.Second, cfg.Timeout)
+	assert.True(t, cfg.FailOpen)
+	assert.False(t, cfg.LogAll)
+
+	t.Setenv("OPA_URL", "")
+	assert.False(t, ConfigFromEnv().Enabled())
+}
@@ -0,0 +1,36 @@
+# Example authorization policy for the gateway. Load it, or your own, into the OPA server the
+# gateway's OPA_URL points at; the gateway asks for data.relai.authz with the request as input
+# (see shared/opa.Input) and expects {"allow": bool, "reason": string, "status": int}.
+package relai.authz
+
+import rego.v1
+
+default allow := false
+
+# Largest body, in bytes, each path accepts
+max_payload := {
+	"/v1/embeddings": 1048576,
+	"/v1/chat/completions": 4194304,
+}
+
+# Models only keys with the "premium" scope may use
+premium_models := {"gpt-4o", "claude-3-opus"}
+
+payload_ok if input.payload_bytes <= object.get(max_payload, input.path, 10485760)
+
+model_ok if not input.model in premium_models
+
+model_ok if "premium" in input.scopes
+
+allow if {
+	payload_ok
+	model_ok
+}
+
+reason := "payload too large for this path" if not payload_ok
+
+else := "model requires the premium scope" if not model_ok
+
+status := 413 if not payload_ok
+
+else := 403
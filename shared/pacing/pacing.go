@@ -0,0 +1,232 @@
+// Package pacing smooths a streamed (SSE) response to a steady token rate, for client UIs that
+// render paced chunks more smoothly than a provider's bursts.
+package pacing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// charsPerToken approximates a token's length when pacing; streams aren't tokenized on the way
+// through
+const charsPerToken = 4
+
+// Pacer schedules tokens at a steady rate, letting delivery run up to a burst of tokens ahead
+type Pacer struct {
+	interval  time.Duration // Time one token takes at the rate
+	tolerance time.Duration // How far ahead of the rate the burst lets delivery run
+	tat       time.Time     // When the tokens scheduled so far are paid for at the rate
+}
+
+// NewPacer paces tokensPerSecond tokens a second, with bursts of up to burstTokens
+func NewPacer(tokensPerSecond, burstTokens int) *Pacer {
+	interval := time.Second / time.Duration(tokensPerSecond)
+	return &Pacer{interval: interval, tolerance: time.Duration(burstTokens) * interval}
+}
+
+// Reserve schedules tokens and returns when they may be sent, which is never before now. Idle
+// time isn't banked beyond the burst.
+func (p *Pacer) Reserve(tokens int, now time.Time) time.Time {
+	at := p.tat.Add(-p.tolerance)
+	if at.Before(now) {
+		at = now
+	}
+	if p.tat.Before(now) {
+		p.tat = now
+	}
+	p.tat = p.tat.Add(time.Duration(tokens) * p.interval)
+	return at
+}
+
+// Writer paces the events of an SSE stream written to it. Writes only queue the stream's whole
+// events and return, so the stream is read as fast as it arrives and only delivery is paced.
+// Each event is held until its tokens are due, and the events due together are written with one
+// flush.
+type Writer struct {
+	w       io.Writer
+	flush   func()
+	pacer   *Pacer
+	pending []byte // Written bytes not yet making up a whole event
+
+	start  sync.Once
+	mu     sync.Mutex
+	queue  [][]byte // Whole events waiting for delivery
+	closed bool
+	err    error         // Why delivery stopped early
+	ready  chan struct{} // Signals queued events or closing
+	done   chan struct{} // Closed once delivery ends
+
+	now   func() time.Time
+	sleep func(time.Duration) error
+}
+
+// NewWriter paces events written to it into w, calling flush after each write. Delivery stops
+// with an error once ctx is done.
+func NewWriter(ctx context.Context, w io.Writer, flush func(), tokensPerSecond, burstTokens int) *Writer {
+	return &Writer{
+		w:     w,
+		flush: flush,
+		pacer: NewPacer(tokensPerSecond, burstTokens),
+		ready: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+		now:   time.Now,
+		sleep: func(d time.Duration) error {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+				return nil
+			}
+		},
+	}
+}
+
+// Write queues the whole events among the next bytes of the stream. It fails once delivery has,
+// so the stream stops being read.
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.failed(); err != nil {
+		return 0, err
+	}
+	w.pending = append(w.pending, p...)
+	var events [][]byte
+	for n := eventEnd(w.pending); n >= 0; n = eventEnd(w.pending) {
+		events = append(events, append([]byte(nil), w.pending[:n]...))
+		w.pending = w.pending[n:]
+	}
+	if len(events) > 0 {
+		w.enqueue(events, false)
+	}
+	return len(p), nil
+}
+
+// Close queues what is left of the stream, such as a final event without a blank line after it,
+// and waits until everything is delivered
+func (w *Writer) Close() error {
+	var rest [][]byte
+	if len(w.pending) > 0 {
+		rest = [][]byte{w.pending}
+		w.pending = nil
+	}
+	w.enqueue(rest, true)
+	<-w.done
+	return w.failed()
+}
+
+func (w *Writer) enqueue(events [][]byte, closing bool) {
+	w.start.Do(func() { go w.deliver() })
+	w.mu.Lock()
+	w.queue = append(w.queue, events...)
+	w.closed = w.closed || closing
+	w.mu.Unlock()
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Writer) failed() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// deliver writes queued events as they come due, flushing before it waits and whenever the
+// queue runs dry
+func (w *Writer) deliver() {
+	defer close(w.done)
+	wrote := false
+	for {
+		w.mu.Lock()
+		if len(w.queue) == 0 {
+			closed := w.closed
+			w.mu.Unlock()
+			if wrote {
+				w.flush()
+				wrote = false
+			}
+			if closed {
+				return
+			}
+			<-w.ready
+			continue
+		}
+		event := w.queue[0]
+		w.queue = w.queue[1:]
+		w.mu.Unlock()
+
+		// Events without tokens follow the event before them straight away
+		if tokens := EventTokens(event); tokens > 0 {
+			at := w.pacer.Reserve(tokens, w.now())
+			if wait := at.Sub(w.now()); wait > 0 {
+				if wrote {
+					w.flush()
+					wrote = false
+				}
+				if err := w.sleep(wait); err != nil {
+					w.stop(err)
+					return
+				}
+			}
+		}
+		if _, err := w.w.Write(event); err != nil {
+			w.stop(err)
+			return
+		}
+		wrote = true
+	}
+}
+
+func (w *Writer) stop(err error) {
+	w.mu.Lock()
+	w.err = err
+	w.queue = nil
+	w.mu.Unlock()
+}
+
+// eventEnd returns the length of the first whole event in b, up to and including the blank line
+// ending it, or -1 when b doesn't hold one
+func eventEnd(b []byte) int {
+	end := -1
+	for _, sep := range [][]byte{[]byte("\n\n"), []byte("\r\n\r\n"), []byte("\r\r")} {
+		if i := bytes.Index(b, sep); i >= 0 && (end < 0 || i+len(sep) < end) {
+			end = i + len(sep)
+		}
+	}
+	return end
+}
+
+type eventChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		Text string `json:"text"`
+	} `json:"choices"`
+}
+
+// EventTokens estimates the completion tokens an SSE event carries. Events without completion
+// text, such as the role, finish reason, usage and [DONE] events, carry none.
+func EventTokens(event []byte) int {
+	chars := 0
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		var chunk eventChunk
+		if json.Unmarshal(bytes.TrimSpace(line[len("data:"):]), &chunk) != nil {
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			chars += utf8.RuneCountInString(choice.Delta.Content) + utf8.RuneCountInString(choice.Text)
+		}
+	}
+	return (chars + charsPerToken - 1) / charsPerToken
+}
@@ -0,0 +1,153 @@
+package pacing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPacerReserve(t *testing.T) {
+	start := time.Unix(0, 0)
+	p := NewPacer(10, 20) // 100ms a token, 20 tokens ahead
+
+	// Delivery runs up to the burst ahead of the rate, then waits for it
+	for i, want := range []time.Duration{0, 0, 0, 0, 0, 500 * time.Millisecond, time.Second} {
+		if at := p.Reserve(5, start); at.Sub(start) != want {
+			t.Errorf("reservation %d at %v, want %v", i, at.Sub(start), want)
+		}
+	}
+
+	// Idle time isn't banked beyond the burst
+	later := start.Add(time.Hour)
+	if at := p.Reserve(20, later); !at.Equal(later) {
+		t.Errorf("burst after idling at %v, want now", at.Sub(later))
+	}
+	if at := p.Reserve(10, later); !at.Equal(later) {
+		t.Errorf("reservation at the end of the burst at %v, want now", at.Sub(later))
+	}
+	if at := p.Reserve(1, later); at.Sub(later) != time.Second {
+		t.Errorf("reservation past the burst at %v, want 1s", at.Sub(later))
+	}
+}
+
+func TestEventTokens(t *testing.T) {
+	tests := []struct {
+		event string
+		want  int
+	}{
+		{"data: {\"choices\":[{\"delta\":{\"content\":\"Hello, world\"}}]}\n\n", 3},
+		{"data: {\"choices\":[{\"text\":\"héllo\"}]}\r\n\r\n", 2},
+		{"data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n", 0},
+		{"data: [DONE]\n\n", 0},
+		{": keep-alive\n\n", 0},
+		{"event: message\ndata: {\"choices\":[{\"delta\":{\"content\":\"a\"}},{\"delta\":{\"content\":\"bcde\"}}]}\n\n", 2},
+	}
+	for _, tt := range tests {
+		if got := EventTokens([]byte(tt.event)); got != tt.want {
+			t.Errorf("EventTokens(%q) = %d, want %d", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestWriterPacesEvents(t *testing.T) {
+	event := "data: {\"choices\":[{\"delta\":{\"content\":\"abcdefgh\"}}]}\n\n" // 2 tokens
+	body := event + event + event + ": keep-alive\r\r" + "data: [DONE]"
+
+	var out bytes.Buffer
+	var flushed []string
+	w := NewWriter(context.Background(), &out, func() { flushed = append(flushed, out.String()) }, 10, 2)
+
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	w.now = func() time.Time { return now }
+	w.sleep = func(d time.Duration) error {
+		slept += d
+		now = now.Add(d)
+		return nil
+	}
+
+	// Split mid-event, the way a provider's chunks arrive
+	for _, part := range []string{body[:10], body[10:20], body[20:]} {
+		if _, err := w.Write([]byte(part)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if out.String() != body {
+		t.Fatalf("stream = %q, want it unchanged", out.String())
+	}
+	// 6 tokens at 10 a second with 2 ahead: the third event waits until 200ms
+	if slept != 200*time.Millisecond {
+		t.Errorf("slept %v, want 200ms", slept)
+	}
+	// The events due at once are flushed together before waiting, and the end is flushed
+	if len(flushed) < 2 || flushed[0] != event+event || flushed[len(flushed)-1] != body {
+		t.Errorf("flushes = %q, want the first two events together first and the whole stream last", flushed)
+	}
+}
+
+func TestWriterStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var out bytes.Buffer
+	w := NewWriter(ctx, &out, func() {}, 1, 0)
+	event := []byte("data: {\"choices\":[{\"delta\":{\"content\":\"abcd\"}}]}\n\n")
+	if _, err := w.Write(append(event, event...)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != context.Canceled {
+		t.Errorf("Close error = %v, want %v", err, context.Canceled)
+	}
+	if out.String() != string(event) {
+		t.Errorf("delivered %q, want only the first event", out.String())
+	}
+	if _, err := w.Write(event); err != context.Canceled {
+		t.Errorf("Write after delivery stopped = %v, want %v", err, context.Canceled)
+	}
+}
+
+// Pacing a stream for longer than the provider client's timeout doesn't cut it off, because the
+// response is read as fast as it arrives
+func TestWriterOutlastsClientTimeout(t *testing.T) {
+	event := "data: {\"choices\":[{\"delta\":{\"content\":\"abcdefgh\"}}]}\n\n" // 2 tokens
+	body := strings.Repeat(event, 10) + "data: [DONE]\n\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	timeout := 100 * time.Millisecond
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// 20 tokens at 40 a second: the last event is due 450ms in
+	var out bytes.Buffer
+	w := NewWriter(context.Background(), &out, func() {}, 40, 0)
+	started := time.Now()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		t.Fatalf("reading the stream: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 4*timeout {
+		t.Errorf("delivered in %v, expected pacing to outlast the %v timeout", elapsed, timeout)
+	}
+	if out.String() != body {
+		t.Errorf("stream = %q, want it whole", out.String())
+	}
+}
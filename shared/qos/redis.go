@@ -0,0 +1,140 @@
+package qos
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisSlotTimeout bounds taking or freeing a slot, which happens on the request path
+	redisSlotTimeout = time.Second
+	// redisSlotLease is how long a slot stays taken without being renewed, so the slots of a
+	// replica that dies are freed
+	redisSlotLease = 30 * time.Second
+)
+
+// takeSlotScript drops expired leases and adds one if fewer than ARGV[1] are held. Leases are
+// scored by their expiry in Redis time, so replicas' clocks don't matter.
+var takeSlotScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[1]) then
+  return 0
+end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[2]), ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// renewSlotsScript extends the leases still held among ARGV[2:] by ARGV[1] milliseconds
+var renewSlotsScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+for i = 2, #ARGV do
+  redis.call('ZADD', KEYS[1], 'XX', now + tonumber(ARGV[1]), ARGV[i])
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return 0
+`)
+
+// RedisSlots is a pool of slots shared by every gateway replica through Redis, so a cap holds
+// for all of them together. Each slot taken is a lease renewed while it is held. If Redis can't
+// be reached it falls back to slots of this process, logging once when it falls back and once
+// when Redis is back.
+type RedisSlots struct {
+	client   *redis.Client
+	key      string
+	capacity int
+	local    Slots
+	degraded atomic.Bool
+
+	mu   sync.Mutex
+	held map[string]struct{}
+}
+
+// NewRedisSlots returns a pool of capacity slots kept under prefix in client. It renews the
+// leases it holds until the client is closed.
+func NewRedisSlots(client *redis.Client, prefix string, capacity int) *RedisSlots {
+	r := &RedisSlots{
+		client:   client,
+		key:      prefix + "qos:slots",
+		capacity: capacity,
+		local:    NewLocalSlots(capacity),
+		held:     map[string]struct{}{},
+	}
+	go r.renew()
+	return r
+}
+
+func (r *RedisSlots) TryTake() (func(), bool) {
+	id := uuid.NewString()
+	ctx, cancel := context.WithTimeout(context.Background(), redisSlotTimeout)
+	defer cancel()
+	taken, err := takeSlotScript.Run(ctx, r.client, []string{r.key}, r.capacity, redisSlotLease.Milliseconds(), id).Int()
+	if err != nil {
+		if r.degraded.CompareAndSwap(false, true) {
+			log.Printf("Redis QoS slots unavailable, capping concurrency per process until it is back: %v", err)
+		}
+		return r.local.TryTake()
+	}
+	if r.degraded.CompareAndSwap(true, false) {
+		log.Printf("Redis QoS slots available again")
+	}
+	if taken == 0 {
+		return nil, false
+	}
+
+	r.mu.Lock()
+	r.held[id] = struct{}{}
+	r.mu.Unlock()
+	return func() { r.give(id) }, true
+}
+
+// give frees a slot; if Redis can't be reached its lease runs out instead
+func (r *RedisSlots) give(id string) {
+	r.mu.Lock()
+	delete(r.held, id)
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisSlotTimeout)
+	defer cancel()
+	if err := r.client.ZRem(ctx, r.key, id).Err(); err != nil {
+		log.Printf("Warning: failed to free Redis QoS slot: %v", err)
+	}
+}
+
+// renew extends the held leases every third of a lease until the client is closed
+func (r *RedisSlots) renew() {
+	ticker := time.NewTicker(redisSlotLease / 3)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.mu.Lock()
+		args := make([]interface{}, 0, len(r.held)+1)
+		args = append(args, redisSlotLease.Milliseconds())
+		for id := range r.held {
+			args = append(args, id)
+		}
+		r.mu.Unlock()
+		if len(args) == 1 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), redisSlotTimeout)
+		err := renewSlotsScript.Run(ctx, r.client, []string{r.key}, args...).Err()
+		cancel()
+		if errors.Is(err, redis.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("Warning: failed to renew Redis QoS slots: %v", err)
+		}
+	}
+}
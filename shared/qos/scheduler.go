@@ -0,0 +1,215 @@
+// Package qos limits how many requests the gateway sends upstream at once and, once it is
+// saturated, schedules the waiting ones by their organization's QoS class
+package qos
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Reasons a request is refused a slot
+var (
+	ErrShed      = errors.New("gateway saturated, bronze requests are shed")
+	ErrQueueFull = errors.New("gateway saturated, queue for the class is full")
+	ErrTimeout   = errors.New("gateway saturated, timed out waiting for a slot")
+)
+
+// pollInterval is how often queued requests try the slot pool for a slot freed outside this
+// scheduler, such as by another replica sharing the pool
+const pollInterval = 50 * time.Millisecond
+
+// waiter is a queued request; ready is closed once a slot is handed to it
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+	free    func()
+}
+
+// Scheduler hands out the slots of a pool. While every slot is taken, gold and silver requests
+// wait in per-class queues and each freed slot goes to the oldest waiter of the highest class;
+// bronze requests are refused at once.
+type Scheduler struct {
+	slots    Slots
+	mu       sync.Mutex
+	inFlight int
+	maxQueue int
+	maxWait  time.Duration
+	queues   map[string][]*waiter
+	polling  bool
+}
+
+// NewScheduler returns a scheduler with capacity slots of its own, queueing up to maxQueue
+// requests per class for at most maxWait each
+func NewScheduler(capacity, maxQueue int, maxWait time.Duration) *Scheduler {
+	return NewSchedulerWithSlots(NewLocalSlots(capacity), maxQueue, maxWait)
+}
+
+// NewSchedulerWithSlots returns a scheduler handing out the slots of a pool it may share with
+// other schedulers
+func NewSchedulerWithSlots(slots Slots, maxQueue int, maxWait time.Duration) *Scheduler {
+	return &Scheduler{
+		slots:    slots,
+		maxQueue: maxQueue,
+		maxWait:  maxWait,
+		queues:   map[string][]*waiter{},
+	}
+}
+
+// Acquire takes a slot for a request of the given class, waiting for one if the class queues.
+// Unknown classes are treated as the default. The returned release must be called once the
+// request is done.
+func (s *Scheduler) Acquire(ctx context.Context, class string) (release func(), err error) {
+	if !models.IsValidQoSClass(class) {
+		class = models.DefaultQoSClass
+	}
+
+	// Requests already queued here go first, so a new one only takes a slot straight from the
+	// pool when none are waiting
+	s.mu.Lock()
+	waiting := s.waiting()
+	s.mu.Unlock()
+	if waiting == 0 {
+		if free, ok := s.slots.TryTake(); ok {
+			s.mu.Lock()
+			s.inFlight++
+			s.mu.Unlock()
+			return s.releaser(free), nil
+		}
+	}
+
+	s.mu.Lock()
+	if class == models.QoSBronze {
+		s.mu.Unlock()
+		return nil, ErrShed
+	}
+	if len(s.queues[class]) >= s.maxQueue {
+		s.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{ready: make(chan struct{})}
+	s.queues[class] = append(s.queues[class], w)
+	if !s.polling {
+		s.polling = true
+		go s.poll()
+	}
+	s.mu.Unlock()
+
+	timer := time.NewTimer(s.maxWait)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return s.releaser(w.free), nil
+	case <-timer.C:
+		err = ErrTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w.granted {
+		// The slot arrived as the wait ended; keep it
+		return s.releaser(w.free), nil
+	}
+	queue := s.queues[class]
+	for i, queued := range queue {
+		if queued == w {
+			s.queues[class] = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	return nil, err
+}
+
+// waiting returns how many requests are queued; s.mu must be held
+func (s *Scheduler) waiting() int {
+	n := 0
+	for _, queue := range s.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// next removes and returns the oldest waiter of the highest class, or nil; s.mu must be held
+func (s *Scheduler) next() *waiter {
+	for _, class := range models.QoSClasses {
+		if queue := s.queues[class]; len(queue) > 0 {
+			s.queues[class] = queue[1:]
+			return queue[0]
+		}
+	}
+	return nil
+}
+
+// grant hands a slot to a waiter; s.mu must be held
+func (s *Scheduler) grant(w *waiter, free func()) {
+	w.free = free
+	w.granted = true
+	close(w.ready)
+}
+
+// poll takes slots from the pool for queued requests until none are left waiting
+func (s *Scheduler) poll() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.mu.Lock()
+		if s.waiting() == 0 {
+			s.polling = false
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		free, ok := s.slots.TryTake()
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		if w := s.next(); w != nil {
+			s.inFlight++
+			s.grant(w, free)
+			s.mu.Unlock()
+			continue
+		}
+		s.mu.Unlock()
+		free()
+	}
+}
+
+// releaser returns a function that frees the slot once, however often it is called
+func (s *Scheduler) releaser(free func()) func() {
+	var once sync.Once
+	return func() { once.Do(func() { s.release(free) }) }
+}
+
+// release hands the slot to the next waiter by class priority, or gives it back to the pool
+func (s *Scheduler) release(free func()) {
+	s.mu.Lock()
+	if w := s.next(); w != nil {
+		s.grant(w, free)
+		s.mu.Unlock()
+		return
+	}
+	s.inFlight--
+	s.mu.Unlock()
+	free()
+}
+
+// QueueDepth returns how many requests of the class are waiting for a slot
+func (s *Scheduler) QueueDepth(class string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queues[class])
+}
+
+// InFlight returns how many slots this scheduler holds
+func (s *Scheduler) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
@@ -0,0 +1,102 @@
+package qos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queued starts an Acquire in the background and waits until it is in the class's queue
+func queued(t *testing.T, s *Scheduler, class string, order chan<- string) {
+	t.Helper()
+	depth := s.QueueDepth(class)
+	go func() {
+		release, err := s.Acquire(context.Background(), class)
+		if err != nil {
+			order <- "error: " + err.Error()
+			return
+		}
+		order <- class
+		release()
+	}()
+	require.Eventually(t, func() bool { return s.QueueDepth(class) == depth+1 }, time.Second, time.Millisecond)
+}
+
+func TestSchedulerServesGoldFirst(t *testing.T) {
+	s := NewScheduler(1, 10, time.Minute)
+	release, err := s.Acquire(context.Background(), models.QoSBronze)
+	require.NoError(t, err)
+
+	order := make(chan string, 2)
+	queued(t, s, models.QoSSilver, order)
+	queued(t, s, models.QoSGold, order)
+
+	release()
+	assert.Equal(t, models.QoSGold, <-order)
+	assert.Equal(t, models.QoSSilver, <-order)
+	assert.Eventually(t, func() bool { return s.InFlight() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestSchedulerShedsBronze(t *testing.T) {
+	s := NewScheduler(1, 10, time.Minute)
+	release, err := s.Acquire(context.Background(), models.QoSGold)
+	require.NoError(t, err)
+
+	_, err = s.Acquire(context.Background(), models.QoSBronze)
+	assert.ErrorIs(t, err, ErrShed)
+
+	release()
+	release() // releasing twice frees one slot
+	next, err := s.Acquire(context.Background(), models.QoSBronze)
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.InFlight())
+	next()
+}
+
+func TestSchedulerQueueLimits(t *testing.T) {
+	s := NewScheduler(1, 1, 20*time.Millisecond)
+	release, err := s.Acquire(context.Background(), models.QoSGold)
+	require.NoError(t, err)
+	defer release()
+
+	order := make(chan string, 1)
+	queued(t, s, models.QoSSilver, order)
+	_, err = s.Acquire(context.Background(), models.QoSSilver)
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	assert.Equal(t, "error: "+ErrTimeout.Error(), <-order)
+	assert.Equal(t, 0, s.QueueDepth(models.QoSSilver))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.Acquire(ctx, "")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, s.QueueDepth(models.DefaultQoSClass))
+}
+
+func TestSchedulersShareSlots(t *testing.T) {
+	// Two schedulers stand in for two replicas sharing one pool
+	slots := NewLocalSlots(1)
+	a := NewSchedulerWithSlots(slots, 10, time.Minute)
+	b := NewSchedulerWithSlots(slots, 10, time.Minute)
+
+	release, err := a.Acquire(context.Background(), models.QoSGold)
+	require.NoError(t, err)
+	_, err = b.Acquire(context.Background(), models.QoSBronze)
+	assert.ErrorIs(t, err, ErrShed)
+
+	order := make(chan string, 1)
+	queued(t, b, models.QoSSilver, order)
+
+	// The slot freed on a reaches the request queued on b
+	release()
+	assert.Equal(t, models.QoSSilver, <-order)
+	assert.Eventually(t, func() bool { return a.InFlight() == 0 && b.InFlight() == 0 }, time.Second, time.Millisecond)
+	free, ok := slots.TryTake()
+	require.True(t, ok)
+	free()
+}
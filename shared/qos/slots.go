@@ -0,0 +1,37 @@
+package qos
+
+import "sync"
+
+// Slots is the pool of upstream slots a Scheduler hands out
+type Slots interface {
+	// TryTake takes a free slot without waiting. free gives it back and must be called once.
+	TryTake() (free func(), ok bool)
+}
+
+// localSlots is a pool of slots in this process
+type localSlots struct {
+	mu       sync.Mutex
+	capacity int
+	taken    int
+}
+
+// NewLocalSlots returns a pool of capacity slots held in this process
+func NewLocalSlots(capacity int) Slots {
+	return &localSlots{capacity: capacity}
+}
+
+func (l *localSlots) TryTake() (func(), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.taken >= l.capacity {
+		return nil, false
+	}
+	l.taken++
+	return l.give, true
+}
+
+func (l *localSlots) give() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.taken--
+}
@@ -0,0 +1,90 @@
+// Package readonly is the switch for database maintenance windows. While it is on, the admin UI
+// rejects writes with 503 and the gateway keeps proxying but skips writes it can do without:
+// key last-used times, payload logs and secret scan incidents. Usage is spooled to disk and
+// recorded once the switch is turned off again.
+//
+// Each process starts with the switch from READ_ONLY_MODE; the admin UI can flip it at runtime
+// for itself and the gateways it knows about.
+package readonly
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	enabled atomic.Bool
+
+	mu        sync.Mutex
+	onDisable []func()
+)
+
+func init() {
+	if v := os.Getenv("READ_ONLY_MODE"); v == "true" || v == "1" {
+		enabled.Store(true)
+		log.Println("Starting in read-only mode (READ_ONLY_MODE)")
+	}
+}
+
+// Enabled reports whether the process is in read-only mode
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Set turns read-only mode on or off. Turning it off runs the OnDisable funcs in the background.
+func Set(on bool) {
+	if enabled.Swap(on) == on {
+		return
+	}
+	if on {
+		log.Println("Read-only mode enabled")
+		return
+	}
+
+	log.Println("Read-only mode disabled")
+	mu.Lock()
+	fns := append([]func(){}, onDisable...)
+	mu.Unlock()
+	for _, fn := range fns {
+		go fn()
+	}
+}
+
+// OnDisable registers fn to run each time read-only mode is turned off, to catch up on the
+// writes deferred while it was on
+func OnDisable(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	onDisable = append(onDisable, fn)
+}
+
+// RejectMessage is the message writes are rejected with
+const RejectMessage = "The gateway is in read-only mode for maintenance; changes can't be saved right now"
+
+// RejectWrites is middleware answering requests other than GET, HEAD and OPTIONS with 503 while
+// read-only mode is on. Routes in except (gin route paths, such as "/api/keys/:id") that use
+// another method without writing are let through.
+func RejectWrites(except ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(except))
+	for _, path := range except {
+		allowed[path] = true
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if Enabled() && !allowed[c.FullPath()] {
+				c.Header("Retry-After", "300")
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": RejectMessage})
+				return
+			}
+		}
+		c.Next()
+	}
+}
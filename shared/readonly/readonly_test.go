@@ -0,0 +1,50 @@
+package readonly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRejectWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RejectWrites("/preview"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/keys", ok)
+	r.POST("/keys", ok)
+	r.POST("/preview", ok)
+
+	serve := func(method, path string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	Set(true)
+	defer Set(false)
+	assert.Equal(t, http.StatusOK, serve("GET", "/keys"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve("POST", "/keys"))
+	assert.Equal(t, http.StatusOK, serve("POST", "/preview"))
+
+	Set(false)
+	assert.Equal(t, http.StatusOK, serve("POST", "/keys"))
+}
+
+func TestOnDisable(t *testing.T) {
+	done := make(chan struct{}, 2)
+	OnDisable(func() { done <- struct{}{} })
+
+	Set(true)
+	Set(true)
+	Set(false)
+	<-done
+
+	// Turning it off again while already off runs nothing
+	Set(false)
+	assert.Len(t, done, 0)
+}
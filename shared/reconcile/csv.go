@@ -0,0 +1,118 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// csvColumns lists the accepted header names for each field of a usage export
+var csvColumns = map[string][]string{
+	"date":              {"date", "day", "usage_date", "start_date"},
+	"model":             {"model", "model_id", "snapshot_id"},
+	"prompt_tokens":     {"prompt_tokens", "input_tokens", "n_context_tokens_total"},
+	"completion_tokens": {"completion_tokens", "output_tokens", "n_generated_tokens_total"},
+	"requests":          {"requests", "num_model_requests", "n_requests"},
+}
+
+// ParseCSV reads a provider usage export with a header row. It needs date, model, and input
+// and output token columns (under any of the names in csvColumns); a requests column is
+// optional. Dates may be YYYY-MM-DD or RFC 3339 timestamps and are taken as UTC days. It returns
+// the usage and the first and last day it covers.
+func ParseCSV(r io.Reader) (usage []models.DailyModelUsage, start, end string, err error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		for field, names := range csvColumns {
+			for _, candidate := range names {
+				if _, seen := index[field]; !seen && name == candidate {
+					index[field] = i
+				}
+			}
+		}
+	}
+	for _, field := range []string{"date", "model", "prompt_tokens", "completion_tokens"} {
+		if _, ok := index[field]; !ok {
+			return nil, "", "", fmt.Errorf("CSV is missing a %s column", field)
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		date, err := parseDay(record[index["date"]])
+		if err != nil {
+			return nil, "", "", fmt.Errorf("line %d: %w", line, err)
+		}
+		u := models.DailyModelUsage{Date: date, Model: strings.TrimSpace(record[index["model"]])}
+		if u.Model == "" {
+			return nil, "", "", fmt.Errorf("line %d: model is empty", line)
+		}
+		if u.PromptTokens, err = parseCount(record[index["prompt_tokens"]]); err != nil {
+			return nil, "", "", fmt.Errorf("line %d: %w", line, err)
+		}
+		if u.CompletionTokens, err = parseCount(record[index["completion_tokens"]]); err != nil {
+			return nil, "", "", fmt.Errorf("line %d: %w", line, err)
+		}
+		if i, ok := index["requests"]; ok {
+			if u.Requests, err = parseCount(record[i]); err != nil {
+				return nil, "", "", fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		if start == "" || date < start {
+			start = date
+		}
+		if date > end {
+			end = date
+		}
+		usage = append(usage, u)
+	}
+
+	if len(usage) == 0 {
+		return nil, "", "", errors.New("CSV has no usage rows")
+	}
+	return usage, start, end, nil
+}
+
+func parseDay(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t.Format("2006-01-02"), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format("2006-01-02"), nil
+	}
+	return "", fmt.Errorf("invalid date %q", value)
+}
+
+func parseCount(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid count %q", value)
+	}
+	return n, nil
+}
@@ -0,0 +1,134 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// SourceOpenAIAPI and SourceCSV name where provider usage came from
+const (
+	SourceOpenAIAPI = "openai_api"
+	SourceCSV       = "csv"
+)
+
+// OpenAIUsageClient reads daily completions usage per model from the OpenAI organization usage
+// API. It needs an admin key (sk-admin-...), not a project API key, and reports all of the
+// organization's usage, so it only reconciles cleanly when the gateway is the organization's
+// sole client.
+type OpenAIUsageClient struct {
+	AdminKey   string
+	BaseURL    string       // Defaults to https://api.openai.com/v1
+	HTTPClient *http.Client // Defaults to a client with a 30 second timeout
+}
+
+// NewOpenAIUsageClientFromEnv returns a client for OPENAI_ADMIN_KEY, or nil if it isn't set
+func NewOpenAIUsageClientFromEnv() *OpenAIUsageClient {
+	key := os.Getenv("OPENAI_ADMIN_KEY")
+	if key == "" {
+		return nil
+	}
+	return &OpenAIUsageClient{AdminKey: key}
+}
+
+type openAIUsagePage struct {
+	Data []struct {
+		StartTime int64 `json:"start_time"`
+		Results   []struct {
+			Model            string `json:"model"`
+			InputTokens      int64  `json:"input_tokens"`
+			OutputTokens     int64  `json:"output_tokens"`
+			NumModelRequests int64  `json:"num_model_requests"`
+		} `json:"results"`
+	} `json:"data"`
+	HasMore  bool   `json:"has_more"`
+	NextPage string `json:"next_page"`
+}
+
+// DailyUsage returns usage per UTC day and model from start to end (YYYY-MM-DD, inclusive)
+func (c *OpenAIUsageClient) DailyUsage(ctx context.Context, start, end string) ([]models.DailyModelUsage, error) {
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date: %w", err)
+	}
+	to, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date: %w", err)
+	}
+
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	params := url.Values{
+		"start_time":   {strconv.FormatInt(from.Unix(), 10)},
+		"end_time":     {strconv.FormatInt(to.AddDate(0, 0, 1).Unix(), 10)},
+		"bucket_width": {"1d"},
+		"group_by":     {"model"},
+		"limit":        {"31"},
+	}
+
+	var usage []models.DailyModelUsage
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/organization/usage/completions?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.AdminKey)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("OpenAI usage API returned %d: %s", resp.StatusCode, truncate(body, 300))
+		}
+
+		var page openAIUsagePage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("invalid OpenAI usage response: %w", err)
+		}
+		for _, bucket := range page.Data {
+			date := time.Unix(bucket.StartTime, 0).UTC().Format("2006-01-02")
+			for _, r := range bucket.Results {
+				usage = append(usage, models.DailyModelUsage{
+					Date:             date,
+					Model:            r.Model,
+					PromptTokens:     r.InputTokens,
+					CompletionTokens: r.OutputTokens,
+					Requests:         r.NumModelRequests,
+				})
+			}
+		}
+
+		if !page.HasMore || page.NextPage == "" {
+			return usage, nil
+		}
+		params.Set("page", page.NextPage)
+	}
+}
+
+func truncate(body []byte, n int) string {
+	if len(body) > n {
+		return string(body[:n]) + "..."
+	}
+	return string(body)
+}
@@ -0,0 +1,141 @@
+// Package reconcile compares the gateway's usage_logs with the usage providers bill for, per
+// day and model, so counting gaps (missed streams, estimated usage, traffic bypassing the
+// gateway) surface before they become billing disputes. Provider usage comes from the OpenAI
+// usage API or a CSV export.
+package reconcile
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// DefaultThresholdPct is the token difference, relative to the provider's count, above which
+// a day and model is flagged
+const DefaultThresholdPct = 2.0
+
+// snapshotSuffix matches the dated snapshot providers report models under, e.g. the
+// -2024-08-06 of gpt-4o-2024-08-06 or the -0613 of gpt-4-0613
+var snapshotSuffix = regexp.MustCompile(`-(\d{4}-\d{2}-\d{2}|\d{4})$`)
+
+// ThresholdFromEnv returns USAGE_RECONCILIATION_THRESHOLD_PCT, or DefaultThresholdPct
+func ThresholdFromEnv() float64 {
+	if pct, err := strconv.ParseFloat(os.Getenv("USAGE_RECONCILIATION_THRESHOLD_PCT"), 64); err == nil && pct >= 0 {
+		return pct
+	}
+	return DefaultThresholdPct
+}
+
+// normalizeModel drops a dated snapshot suffix, so usage the provider reports for a snapshot
+// matches a gateway model configured with its alias
+func normalizeModel(model string) string {
+	return snapshotSuffix.ReplaceAllString(model, "")
+}
+
+type usageKey struct{ date, model string }
+
+func aggregate(usage []models.DailyModelUsage) map[usageKey]models.DailyModelUsage {
+	totals := make(map[usageKey]models.DailyModelUsage)
+	for _, u := range usage {
+		key := usageKey{u.Date, normalizeModel(u.Model)}
+		total := totals[key]
+		total.Date, total.Model = key.date, key.model
+		total.PromptTokens += u.PromptTokens
+		total.CompletionTokens += u.CompletionTokens
+		total.Requests += u.Requests
+		totals[key] = total
+	}
+	return totals
+}
+
+// Compare matches gateway and provider usage by day and model and returns those whose total
+// tokens differ by more than thresholdPct of the provider's count. Usage seen on only one side
+// is always flagged.
+func Compare(gateway, provider []models.DailyModelUsage, thresholdPct float64) []models.UsageDiscrepancy {
+	gatewayTotals, providerTotals := aggregate(gateway), aggregate(provider)
+	keys := make(map[usageKey]bool)
+	for key := range gatewayTotals {
+		keys[key] = true
+	}
+	for key := range providerTotals {
+		keys[key] = true
+	}
+
+	discrepancies := []models.UsageDiscrepancy{}
+	for key := range keys {
+		g, p := gatewayTotals[key], providerTotals[key]
+		gatewayTokens, providerTokens := g.TotalTokens(), p.TotalTokens()
+		if gatewayTokens == providerTokens {
+			continue
+		}
+
+		pct := 100.0
+		if providerTokens > 0 {
+			pct = math.Abs(float64(gatewayTokens-providerTokens)) / float64(providerTokens) * 100
+		}
+		if pct <= thresholdPct {
+			continue
+		}
+
+		discrepancies = append(discrepancies, models.UsageDiscrepancy{
+			UsageDate:        key.date,
+			Model:            key.model,
+			GatewayTokens:    gatewayTokens,
+			ProviderTokens:   providerTokens,
+			GatewayRequests:  g.Requests,
+			ProviderRequests: p.Requests,
+			DifferencePct:    math.Round(pct*100) / 100,
+		})
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		if discrepancies[i].UsageDate != discrepancies[j].UsageDate {
+			return discrepancies[i].UsageDate < discrepancies[j].UsageDate
+		}
+		return discrepancies[i].Model < discrepancies[j].Model
+	})
+	return discrepancies
+}
+
+// Reconcile compares provider usage for start to end (YYYY-MM-DD, inclusive) against the
+// gateway's usage of that provider's models, and records the discrepancies found
+func Reconcile(database *sql.DB, source, provider, start, end string, providerUsage []models.DailyModelUsage, thresholdPct float64) (*models.UsageReconciliationResult, error) {
+	gatewayUsage, err := db.GetDailyModelUsage(database, provider, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gateway usage: %w", err)
+	}
+
+	discrepancies := Compare(gatewayUsage, providerUsage, thresholdPct)
+	for i := range discrepancies {
+		discrepancies[i].Source = source
+		discrepancies[i].Provider = provider
+	}
+	if err := db.ReplaceUsageDiscrepancies(database, provider, start, end, discrepancies); err != nil {
+		return nil, fmt.Errorf("failed to store discrepancies: %w", err)
+	}
+
+	days := make(map[string]bool)
+	for _, u := range providerUsage {
+		days[u.Date] = true
+	}
+	for _, u := range gatewayUsage {
+		days[u.Date] = true
+	}
+
+	return &models.UsageReconciliationResult{
+		Source:        source,
+		Provider:      provider,
+		StartDate:     start,
+		EndDate:       end,
+		DaysCompared:  len(days),
+		ThresholdPct:  thresholdPct,
+		Discrepancies: discrepancies,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package reconcile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+func TestCompare(t *testing.T) {
+	gateway := []models.DailyModelUsage{
+		{Date: "2025-01-01", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 500, Requests: 10},
+		{Date: "2025-01-01", Model: "gpt-4o-mini", PromptTokens: 900, CompletionTokens: 100, Requests: 4},
+		{Date: "2025-01-02", Model: "gpt-4o", PromptTokens: 10, Requests: 1},
+	}
+	provider := []models.DailyModelUsage{
+		// Snapshots are matched to the gateway's alias and summed
+		{Date: "2025-01-01", Model: "gpt-4o-2024-08-06", PromptTokens: 1000, CompletionTokens: 400, Requests: 9},
+		{Date: "2025-01-01", Model: "gpt-4o-2024-11-20", PromptTokens: 0, CompletionTokens: 110, Requests: 1},
+		{Date: "2025-01-01", Model: "gpt-4o-mini-2024-07-18", PromptTokens: 1500, CompletionTokens: 500, Requests: 8},
+		{Date: "2025-01-03", Model: "o1", PromptTokens: 50, CompletionTokens: 50, Requests: 1},
+	}
+
+	got := Compare(gateway, provider, 2)
+	if len(got) != 3 {
+		t.Fatalf("discrepancies = %+v", got)
+	}
+	if got[0].Model != "gpt-4o-mini" || got[0].GatewayTokens != 1000 || got[0].ProviderTokens != 2000 || got[0].DifferencePct != 50 {
+		t.Errorf("under-counted day = %+v", got[0])
+	}
+	if got[1].UsageDate != "2025-01-02" || got[1].ProviderTokens != 0 || got[1].DifferencePct != 100 {
+		t.Errorf("gateway-only day = %+v", got[1])
+	}
+	if got[2].Model != "o1" || got[2].GatewayTokens != 0 {
+		t.Errorf("provider-only day = %+v", got[2])
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	export := "\ufeffDate,Model,input_tokens,output_tokens,num_model_requests\n" +
+		"2025-01-02,gpt-4o,100,20,3\n" +
+		"2025-01-01T00:00:00Z,gpt-4o-mini,5,,1\n"
+
+	usage, start, end, err := ParseCSV(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != "2025-01-01" || end != "2025-01-02" || len(usage) != 2 {
+		t.Fatalf("start %s, end %s, usage %+v", start, end, usage)
+	}
+	if usage[0] != (models.DailyModelUsage{Date: "2025-01-02", Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 20, Requests: 3}) {
+		t.Errorf("row = %+v", usage[0])
+	}
+
+	for _, bad := range []string{
+		"date,model,input_tokens\n2025-01-01,gpt-4o,1\n",
+		"date,model,input_tokens,output_tokens\n01/02/2025,gpt-4o,1,1\n",
+		"date,model,input_tokens,output_tokens\n2025-01-01,gpt-4o,-1,1\n",
+		"date,model,input_tokens,output_tokens\n",
+	} {
+		if _, _, _, err := ParseCSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestOpenAIUsageClientPaginates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-admin-test" || r.URL.Query().Get("bucket_width") != "1d" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if r.URL.Query().Get("page") == "" {
+			w.Write([]byte(`{"data":[{"start_time":1735689600,"results":[{"model":"gpt-4o","input_tokens":10,"output_tokens":5,"num_model_requests":1}]}],"has_more":true,"next_page":"p2"}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"start_time":1735776000,"results":[{"model":"gpt-4o","input_tokens":7,"output_tokens":3,"num_model_requests":2}]}],"has_more":false}`))
+	}))
+	defer server.Close()
+
+	client := &OpenAIUsageClient{AdminKey: "sk-admin-test", BaseURL: server.URL}
+	usage, err := client.DailyUsage(context.Background(), "2025-01-01", "2025-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage[0].Date != "2025-01-01" || usage[1].Date != "2025-01-02" || usage[1].Requests != 2 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
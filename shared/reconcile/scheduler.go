@@ -0,0 +1,128 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// defaultLookbackDays is how many completed days each scheduled run re-checks; provider usage
+// can take a day or more to settle
+const defaultLookbackDays = 3
+
+// Scheduler reconciles recent OpenAI usage against the gateway's counts once a day
+type Scheduler struct {
+	db           *sql.DB
+	client       *OpenAIUsageClient
+	interval     time.Duration
+	lookbackDays int
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// NewSchedulerFromEnv returns a scheduler when USAGE_RECONCILIATION_ENABLED and OPENAI_ADMIN_KEY
+// are set, or nil. USAGE_RECONCILIATION_INTERVAL (default 24h) and
+// USAGE_RECONCILIATION_LOOKBACK_DAYS (default 3) tune it.
+func NewSchedulerFromEnv(db *sql.DB) *Scheduler {
+	switch os.Getenv("USAGE_RECONCILIATION_ENABLED") {
+	case "1", "true":
+	default:
+		return nil
+	}
+	client := NewOpenAIUsageClientFromEnv()
+	if client == nil {
+		log.Println("USAGE_RECONCILIATION_ENABLED is set but OPENAI_ADMIN_KEY is not; usage reconciliation disabled")
+		return nil
+	}
+
+	interval, _ := time.ParseDuration(os.Getenv("USAGE_RECONCILIATION_INTERVAL"))
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	lookback, err := strconv.Atoi(os.Getenv("USAGE_RECONCILIATION_LOOKBACK_DAYS"))
+	if err != nil || lookback < 1 {
+		lookback = defaultLookbackDays
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{db: db, client: client, interval: interval, lookbackDays: lookback, ctx: ctx, cancel: cancel}
+}
+
+// Start runs a reconciliation immediately and then on every interval
+func (s *Scheduler) Start() {
+	log.Printf("Starting usage reconciliation scheduler (interval %s, %d days)", s.interval, s.lookbackDays)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			if readonly.Enabled() {
+				log.Println("Skipping usage reconciliation in read-only mode")
+			} else if err := s.RunOnce(); err != nil {
+				log.Printf("Usage reconciliation run failed: %v", err)
+				notify.Record(s.db, "", models.NotificationEventSyncFailure, notify.Message{
+					Title:    "Usage reconciliation failed",
+					Text:     "The gateway's usage couldn't be checked against the OpenAI usage API: " + err.Error(),
+					Severity: notify.SeverityWarning,
+				})
+			}
+
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop gracefully shuts down the scheduler
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	log.Println("Usage reconciliation scheduler stopped")
+}
+
+// RunOnce reconciles the last lookbackDays completed UTC days
+func (s *Scheduler) RunOnce() error {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, -s.lookbackDays).Format("2006-01-02")
+	end := today.AddDate(0, 0, -1).Format("2006-01-02")
+
+	providerUsage, err := s.client.DailyUsage(s.ctx, start, end)
+	if err != nil {
+		return err
+	}
+	result, err := Reconcile(s.db, SourceOpenAIAPI, "openai", start, end, providerUsage, ThresholdFromEnv())
+	if err != nil {
+		return err
+	}
+
+	if len(result.Discrepancies) > 0 {
+		log.Printf("Usage reconciliation flagged %d day/model discrepancies between %s and %s", len(result.Discrepancies), start, end)
+		notify.Record(s.db, "", models.NotificationEventUsageAnomaly, notify.Message{
+			Title:    fmt.Sprintf("Usage differs from OpenAI's on %d day/model pairs", len(result.Discrepancies)),
+			Text:     "The gateway's token counts differ from the provider's by more than the reconciliation threshold.",
+			Severity: notify.SeverityWarning,
+			Fields: []notify.Field{
+				{Name: "Period", Value: start + " to " + end},
+				{Name: "Threshold", Value: fmt.Sprintf("%.1f%%", result.ThresholdPct)},
+			},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Package secretfile reads secrets from files, such as Kubernetes Secrets mounted as volumes,
+// and picks up rotated values without a restart. A secret NAME is read from the file NAME_FILE
+// names when that is set, and from the NAME environment variable otherwise.
+package secretfile
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultInterval = 10 * time.Second
+
+type secret struct {
+	path  string
+	value string
+}
+
+var (
+	mu        sync.Mutex
+	secrets   = map[string]*secret{}
+	listeners []func(name string)
+	watching  bool
+)
+
+// Get returns the current value of the secret. A file-backed secret is read the first time it
+// is asked for and then kept up to date by Start; a file that can't be read gives "".
+func Get(name string) string {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return os.Getenv(name)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if s, ok := secrets[name]; ok && s.path == path {
+		return s.value
+	}
+	value, err := read(path)
+	if err != nil {
+		log.Printf("Failed to read %s from %s: %v", name, path, err)
+	}
+	secrets[name] = &secret{path: path, value: value}
+	return value
+}
+
+// OnChange registers fn to be called with the name of each file-backed secret whose value
+// changes, after Get starts returning the new value
+func OnChange(fn func(name string)) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// read returns the file's contents without the trailing newline editors and kubectl leave
+func read(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(data, "\r\n")), nil
+}
+
+// Reload rereads every file-backed secret read so far, telling listeners about those that
+// changed. A file that can't be read keeps its last value, as it may be mid-rotation.
+func Reload() {
+	mu.Lock()
+	var changed []string
+	for name, s := range secrets {
+		value, err := read(s.path)
+		if err != nil {
+			log.Printf("Failed to reload %s from %s: %v", name, s.path, err)
+			continue
+		}
+		if value != s.value {
+			s.value = value
+			changed = append(changed, name)
+		}
+	}
+	notify := append([]func(string){}, listeners...)
+	mu.Unlock()
+
+	for _, name := range changed {
+		log.Printf("Reloaded rotated secret %s", name)
+		for _, fn := range notify {
+			fn(name)
+		}
+	}
+}
+
+// Start rereads file-backed secrets every SECRET_FILES_INTERVAL (default 10s). Polling, rather
+// than watching for file events, also catches the symlink swap Kubernetes rotates mounted
+// Secrets with. It runs once per process; the returned func stops it.
+func Start() (stop func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	if watching {
+		return func() {}
+	}
+	watching = true
+
+	interval, _ := time.ParseDuration(os.Getenv("SECRET_FILES_INTERVAL"))
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				Reload()
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		mu.Lock()
+		watching = false
+		mu.Unlock()
+	}
+}
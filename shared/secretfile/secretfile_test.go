@@ -0,0 +1,58 @@
+package secretfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reset(t *testing.T) {
+	t.Cleanup(func() {
+		mu.Lock()
+		secrets = map[string]*secret{}
+		listeners = nil
+		mu.Unlock()
+	})
+}
+
+func TestGetFromEnv(t *testing.T) {
+	reset(t)
+	t.Setenv("TEST_SECRET", "from-env")
+	assert.Equal(t, "from-env", Get("TEST_SECRET"))
+}
+
+func TestGetFromFileAndReload(t *testing.T) {
+	reset(t)
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+	t.Setenv("TEST_SECRET", "ignored")
+	t.Setenv("TEST_SECRET_FILE", path)
+
+	var changed []string
+	OnChange(func(name string) { changed = append(changed, name) })
+
+	assert.Equal(t, "first", Get("TEST_SECRET"), "the file wins over the variable, without its newline")
+
+	Reload()
+	assert.Empty(t, changed, "an unchanged file isn't reported")
+
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	Reload()
+	assert.Equal(t, []string{"TEST_SECRET"}, changed)
+	assert.Equal(t, "second", Get("TEST_SECRET"))
+
+	// Mid-rotation the file may briefly be missing; the last value is kept
+	require.NoError(t, os.Remove(path))
+	Reload()
+	assert.Equal(t, "second", Get("TEST_SECRET"))
+	assert.Len(t, changed, 1)
+}
+
+func TestGetUnreadableFile(t *testing.T) {
+	reset(t)
+	t.Setenv("TEST_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+	assert.Equal(t, "", Get("TEST_SECRET"))
+}
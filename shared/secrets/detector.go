@@ -0,0 +1,175 @@
+// Package secrets detects likely credentials (cloud keys, private keys, API tokens) in prompt text
+// so they can be blocked or flagged before leaving the gateway.
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Rule is a named pattern that identifies a kind of secret
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// Finding is a single detected secret. The secret itself is never retained; only a masked preview.
+type Finding struct {
+	Type    string `json:"type"`
+	Preview string `json:"preview"`
+}
+
+type compiledRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// builtinRules cover common credential formats
+var builtinRules = []Rule{
+	{Name: "aws_access_key_id", Pattern: `\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA)[0-9A-Z]{16}\b`},
+	{Name: "aws_secret_access_key", Pattern: `(?i)aws.{0,20}secret.{0,20}?[=:]\s*["']?[A-Za-z0-9/+=]{40}\b`},
+	{Name: "private_key", Pattern: `-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`},
+	{Name: "github_token", Pattern: `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`},
+	{Name: "slack_token", Pattern: `\bxox[abposr]-[A-Za-z0-9-]{10,}\b`},
+	{Name: "openai_api_key", Pattern: `\bsk-(?:proj-)?[A-Za-z0-9_-]{32,}\b`},
+	{Name: "google_api_key", Pattern: `\bAIza[0-9A-Za-z_-]{35}\b`},
+	{Name: "stripe_secret_key", Pattern: `\b(?:sk|rk)_live_[0-9A-Za-z]{20,}\b`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b`},
+	{Name: "connection_string_password", Pattern: `\b[a-z][a-z0-9+.-]*://[^\s:/@]+:[^\s:/@]{6,}@[^\s/]+`},
+}
+
+// assignmentPattern finds "password = value" style assignments; values are kept only if high-entropy
+var assignmentPattern = regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|client[_-]?secret|auth)["']?\s*[:=]\s*["']?([^\s"',;]{16,})`)
+
+// minAssignmentEntropy is the Shannon entropy (bits per char) above which an assigned value looks random
+const minAssignmentEntropy = 3.5
+
+// Detector scans text for secrets using the built-in rules plus organization-specific patterns
+type Detector struct {
+	rules []compiledRule
+}
+
+// NewDetector builds a detector with the built-in rules and any custom rules.
+// An error is returned if a custom pattern does not compile.
+func NewDetector(customRules []Rule) (*Detector, error) {
+	d := &Detector{}
+	for _, r := range builtinRules {
+		d.rules = append(d.rules, compiledRule{name: r.Name, re: regexp.MustCompile(r.Pattern)})
+	}
+	for _, r := range customRules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for rule %q: %w", r.Name, err)
+		}
+		name := r.Name
+		if name == "" {
+			name = "custom"
+		}
+		d.rules = append(d.rules, compiledRule{name: name, re: re})
+	}
+	return d, nil
+}
+
+// ValidateRules checks that custom rules compile and are named
+func ValidateRules(rules []Rule) error {
+	for _, r := range rules {
+		if strings.TrimSpace(r.Name) == "" {
+			return fmt.Errorf("every custom rule needs a name")
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern for rule %q: %w", r.Name, err)
+		}
+	}
+	return nil
+}
+
+// Scan returns the secrets found in text, at most one finding per distinct match
+func (d *Detector) Scan(text string) []Finding {
+	var findings []Finding
+	seen := make(map[string]bool)
+
+	add := func(ruleName, match string) {
+		if seen[match] {
+			return
+		}
+		seen[match] = true
+		findings = append(findings, Finding{Type: ruleName, Preview: Mask(match)})
+	}
+
+	for _, rule := range d.rules {
+		for _, match := range rule.re.FindAllString(text, -1) {
+			add(rule.name, match)
+		}
+	}
+
+	for _, groups := range assignmentPattern.FindAllStringSubmatch(text, -1) {
+		value := groups[1]
+		if !seen[value] && ShannonEntropy(value) >= minAssignmentEntropy {
+			add("high_entropy_assignment", value)
+		}
+	}
+
+	return findings
+}
+
+// ScanAll scans several pieces of text and merges the findings
+func (d *Detector) ScanAll(texts []string) []Finding {
+	var findings []Finding
+	for _, text := range texts {
+		findings = append(findings, d.Scan(text)...)
+	}
+	return findings
+}
+
+// Mask keeps the first four characters of a secret and hides the rest
+func Mask(secret string) string {
+	runes := []rune(secret)
+	if len(runes) <= 8 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:4]) + strings.Repeat("*", 8) + fmt.Sprintf(" (%d chars)", len(runes))
+}
+
+// ShannonEntropy returns the entropy of s in bits per character
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// ExtractStrings returns every string value in a decoded JSON document, so prompts are scanned
+// regardless of request shape (messages, prompt, input, tool arguments, ...)
+func ExtractStrings(doc interface{}) []string {
+	var out []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case string:
+			out = append(out, t)
+		case []interface{}:
+			for _, item := range t {
+				walk(item)
+			}
+		case map[string]interface{}:
+			for _, item := range t {
+				walk(item)
+			}
+		}
+	}
+	walk(doc)
+	return out
+}
@@ -0,0 +1,92 @@
+package servicetoken
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ReplayGuard remembers the IDs of the tokens already used until they expire, so each token is
+// accepted once
+type ReplayGuard interface {
+	// Use records the token ID, reporting false when it was used before
+	Use(id string, expires time.Time) bool
+}
+
+// replayGuard is per-process unless SetReplayGuard shares it across replicas
+var replayGuard ReplayGuard = newMemoryReplayGuard()
+
+// SetReplayGuard replaces the per-process replay guard; call before serving
+func SetReplayGuard(g ReplayGuard) {
+	replayGuard = g
+}
+
+// memoryReplayGuard keeps used token IDs in this process
+type memoryReplayGuard struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func newMemoryReplayGuard() *memoryReplayGuard {
+	return &memoryReplayGuard{used: map[string]time.Time{}}
+}
+
+func (g *memoryReplayGuard) Use(id string, expires time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Tokens live a minute, so dropping the expired IDs on each use keeps the map small
+	now := time.Now()
+	for usedID, until := range g.used {
+		if !until.After(now) {
+			delete(g.used, usedID)
+		}
+	}
+	if _, ok := g.used[id]; ok {
+		return false
+	}
+	g.used[id] = expires
+	return true
+}
+
+// redisReplayTimeout bounds recording a token ID, which happens on the request path
+const redisReplayTimeout = time.Second
+
+// RedisReplayGuard keeps used token IDs in Redis so a token used on one gateway replica is
+// refused on the others. If Redis can't be reached it falls back to this process's guard,
+// logging once when it falls back and once when Redis is back.
+type RedisReplayGuard struct {
+	client   *redis.Client
+	prefix   string
+	local    *memoryReplayGuard
+	degraded atomic.Bool
+}
+
+// NewRedisReplayGuard returns a guard keeping token IDs under prefix in client
+func NewRedisReplayGuard(client *redis.Client, prefix string) *RedisReplayGuard {
+	return &RedisReplayGuard{client: client, prefix: prefix, local: newMemoryReplayGuard()}
+}
+
+func (g *RedisReplayGuard) Use(id string, expires time.Time) bool {
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisReplayTimeout)
+	defer cancel()
+	first, err := g.client.SetNX(ctx, g.prefix+"servicetoken:"+id, 1, ttl).Result()
+	if err != nil {
+		if g.degraded.CompareAndSwap(false, true) {
+			log.Printf("Redis service token replay guard unavailable, checking locally until it is back: %v", err)
+		}
+		return g.local.Use(id, expires)
+	}
+	if g.degraded.CompareAndSwap(true, false) {
+		log.Printf("Redis service token replay guard available again")
+	}
+	return first
+}
@@ -0,0 +1,108 @@
+// Package servicetoken signs the short-lived tokens the admin UI sends the gateway in place of an
+// organization's API key, so the UI never needs to read raw keys. Tokens are HS256 JWTs keyed
+// with GATEWAY_INTERNAL_TOKEN, the secret the two services already share, and name the key by ID.
+package servicetoken
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	issuer   = "relai-ui"
+	audience = "relai-gateway"
+	// lifetime covers clock skew between the services; a token is used once, right away, and
+	// Verify refuses it after that
+	lifetime = time.Minute
+)
+
+// ErrNotConfigured means GATEWAY_INTERNAL_TOKEN is not set, so tokens can't be signed or checked
+var ErrNotConfigured = errors.New("GATEWAY_INTERNAL_TOKEN is not set")
+
+// Claims identify the API key a request acts as and the admin UI user who made it
+type Claims struct {
+	OrganizationID string `json:"org"`
+	UserID         string `json:"uid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// APIKeyID is the key the request acts as
+func (c *Claims) APIKeyID() string {
+	return c.Subject
+}
+
+func secret() ([]byte, error) {
+	s := os.Getenv("GATEWAY_INTERNAL_TOKEN")
+	if s == "" {
+		return nil, ErrNotConfigured
+	}
+	return []byte(s), nil
+}
+
+// Sign returns a token letting the holder call the gateway as the API key apiKeyID of
+// organization orgID, on behalf of userID, for the next minute
+func Sign(apiKeyID, orgID, userID string) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := Claims{
+		OrganizationID: orgID,
+		UserID:         userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			Subject:   apiKeyID,
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+// IsToken reports whether a bearer credential looks like a service token rather than an API key
+func IsToken(credential string) bool {
+	return strings.Count(credential, ".") == 2 && !strings.HasPrefix(credential, "sk-")
+}
+
+// Verify checks a token's signature, issuer, audience and expiry and returns its claims. Each
+// token is accepted once; a replayed one is refused.
+func Verify(token string) (*Claims, error) {
+	key, err := secret()
+	if err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	_, err = jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service token: %w", err)
+	}
+	if claims.Subject == "" || claims.OrganizationID == "" {
+		return nil, errors.New("invalid service token: missing API key or organization")
+	}
+	if claims.ID == "" {
+		return nil, errors.New("invalid service token: missing token ID")
+	}
+	if !replayGuard.Use(claims.ID, claims.ExpiresAt.Time) {
+		return nil, errors.New("invalid service token: already used")
+	}
+	return &claims, nil
+}
@@ -0,0 +1,46 @@
+package servicetoken
+
+import (
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	t.Setenv("GATEWAY_INTERNAL_TOKEN", "shared-secret")
+
+	token, err := Sign("key-1", "org-1", "user-1")
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if !IsToken(token) {
+		t.Errorf("IsToken(%q) = false", token)
+	}
+	if IsToken("sk-0123456789abcdef") {
+		t.Error("IsToken() accepted an API key")
+	}
+
+	claims, err := Verify(token)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.APIKeyID() != "key-1" || claims.OrganizationID != "org-1" || claims.UserID != "user-1" {
+		t.Errorf("Verify() claims = %+v", claims)
+	}
+
+	if _, err := Verify(token); err == nil {
+		t.Error("Verify() accepted a replayed token")
+	}
+
+	if _, err := Verify(token + "x"); err == nil {
+		t.Error("Verify() accepted a tampered token")
+	}
+
+	t.Setenv("GATEWAY_INTERNAL_TOKEN", "other-secret")
+	if _, err := Verify(token); err == nil {
+		t.Error("Verify() accepted a token signed with another secret")
+	}
+
+	t.Setenv("GATEWAY_INTERNAL_TOKEN", "")
+	if _, err := Sign("key-1", "org-1", ""); err != ErrNotConfigured {
+		t.Errorf("Sign() without a secret error = %v, want ErrNotConfigured", err)
+	}
+}
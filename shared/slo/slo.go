@@ -0,0 +1,138 @@
+// Package slo tracks availability and latency objectives for models and endpoints against the
+// gateway's usage logs, and alerts when their error budgets burn too fast.
+package slo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+const defaultInterval = time.Minute
+
+// Status works out where the SLO stands as of now
+func Status(conn *sql.DB, s models.SLO, now time.Time) (*models.SLOStatus, error) {
+	counts, err := db.GetSLOCounts(conn, &s, now)
+	if err != nil {
+		return nil, err
+	}
+	status := models.NewSLOStatus(s, *counts, now)
+	return &status, nil
+}
+
+// Evaluate checks every active SLO's burn rates, alerting when one starts burning its budget
+// too fast, escalates, or recovers
+func Evaluate(conn *sql.DB) {
+	slos, err := db.ListSLOs(conn, true)
+	if err != nil {
+		log.Printf("Failed to list SLOs: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, s := range slos {
+		status, err := Status(conn, s, now)
+		if err != nil {
+			log.Printf("Failed to evaluate SLO %s: %v", s.Name, err)
+			continue
+		}
+		if status.Level == s.AlertLevel {
+			continue
+		}
+		changed, err := db.SetSLOAlertLevel(conn, s.ID, status.Level)
+		if err != nil {
+			log.Printf("Failed to record SLO %s alert level: %v", s.Name, err)
+			continue
+		}
+		if !changed {
+			continue // Another replica got there first
+		}
+		alert(conn, s, status)
+	}
+}
+
+// alert tells the SLO's organization, or System Admins for an SLO over every organization, that
+// its alert level changed
+func alert(conn *sql.DB, s models.SLO, status *models.SLOStatus) {
+	msg := message(s, status)
+	orgID := ""
+	if s.OrganizationID != nil {
+		orgID = *s.OrganizationID
+	}
+	notify.Record(conn, orgID, models.NotificationEventSLOBurnRate, msg)
+	if orgID != "" {
+		notify.Dispatch(conn, orgID, models.NotificationEventSLOBurnRate, msg)
+	}
+	log.Printf("SLO %s alert level changed from %q to %q", s.Name, s.AlertLevel, status.Level)
+}
+
+// message builds the notification for the SLO's new alert level
+func message(s models.SLO, status *models.SLOStatus) notify.Message {
+	msg := notify.Message{
+		Title:    fmt.Sprintf("SLO %s recovered", s.Name),
+		Text:     "The error budget is no longer burning faster than the alert thresholds.",
+		Severity: notify.SeverityInfo,
+	}
+	switch status.Level {
+	case models.SLOAlertCritical:
+		msg.Title = fmt.Sprintf("SLO %s is burning its error budget fast", s.Name)
+		msg.Text = "At this rate the error budget for the window will be gone within days."
+		msg.Severity = notify.SeverityCritical
+	case models.SLOAlertWarning:
+		msg.Title = fmt.Sprintf("SLO %s is burning its error budget", s.Name)
+		msg.Text = "At this rate the error budget will be spent before the window ends."
+		msg.Severity = notify.SeverityWarning
+	}
+
+	scope := s.Endpoint
+	if s.ModelName != nil {
+		scope = *s.ModelName
+		if s.Endpoint != "" {
+			scope += " on " + s.Endpoint
+		}
+	}
+	objective := fmt.Sprintf("%g%% %s over %d days", s.Target, s.Objective, s.WindowDays)
+	if s.LatencyThresholdMs != nil {
+		objective = fmt.Sprintf("%g%% of requests within %dms over %d days", s.Target, *s.LatencyThresholdMs, s.WindowDays)
+	}
+	msg.Fields = []notify.Field{
+		{Name: "Scope", Value: scope},
+		{Name: "Objective", Value: objective},
+		{Name: "Burn Rate", Value: fmt.Sprintf("%.1fx over 1h, %.1fx over 6h", status.BurnRates.OneHour, status.BurnRates.SixHours)},
+		{Name: "Error Budget Left", Value: fmt.Sprintf("%.1f%%", status.ErrorBudgetRemaining*100)},
+	}
+	return msg
+}
+
+// Start evaluates SLOs every SLO_EVALUATION_INTERVAL (default 1m). The returned func stops it.
+func Start(conn *sql.DB) (stop func()) {
+	interval, _ := time.ParseDuration(os.Getenv("SLO_EVALUATION_INTERVAL"))
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !readonly.Enabled() {
+					Evaluate(conn)
+				}
+			}
+		}
+	}()
+	return cancel
+}
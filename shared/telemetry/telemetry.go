@@ -0,0 +1,198 @@
+// Package telemetry sends opt-in, anonymized deployment stats to the maintainers so they can
+// see which providers and scales the gateway runs at. It is off unless TELEMETRY_ENABLED is set.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/version"
+)
+
+const defaultInterval = 24 * time.Hour
+
+// knownProviders are reported by name; any other provider label is counted as "other", as
+// it may name something internal
+var knownProviders = map[string]bool{
+	"openai":               true,
+	"anthropic":            true,
+	models.ProviderBedrock: true,
+	models.ProviderVertex:  true,
+	models.ProviderOllama:  true,
+	models.ProviderVLLM:    true,
+}
+
+// Report is everything a telemetry request carries. Nothing in it identifies an organization,
+// user, key, model or prompt.
+type Report struct {
+	DeploymentID  string         `json:"deployment_id"` // Salted hash, so reports from one deployment can be counted once
+	Version       string         `json:"version"`
+	GoVersion     string         `json:"go_version"`
+	OS            string         `json:"os"`
+	Arch          string         `json:"arch"`
+	Providers     map[string]int `json:"providers"` // Active models by provider
+	Organizations string         `json:"organizations"`
+	Requests24h   string         `json:"requests_24h"`
+	SentAt        time.Time      `json:"sent_at"`
+}
+
+// Config is the reporter's settings
+type Config struct {
+	Enabled  bool
+	Endpoint string
+	Interval time.Duration
+}
+
+// ConfigFromEnv reads TELEMETRY_ENABLED, TELEMETRY_ENDPOINT and TELEMETRY_INTERVAL (default 24h)
+func ConfigFromEnv() Config {
+	cfg := Config{Endpoint: os.Getenv("TELEMETRY_ENDPOINT")}
+	switch os.Getenv("TELEMETRY_ENABLED") {
+	case "1", "true":
+		cfg.Enabled = true
+	}
+	cfg.Interval, _ = time.ParseDuration(os.Getenv("TELEMETRY_INTERVAL"))
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	return cfg
+}
+
+// Bucket rounds a count to the range it falls in, so a report shows scale but not exact volume
+func Bucket(n int64) string {
+	switch {
+	case n <= 0:
+		return "0"
+	case n < 100:
+		return "1-99"
+	case n < 1000:
+		return "100-999"
+	case n < 10000:
+		return "1k-10k"
+	case n < 100000:
+		return "10k-100k"
+	case n < 1000000:
+		return "100k-1M"
+	default:
+		return "1M+"
+	}
+}
+
+// anonymize hashes the deployment ID with a fixed salt, so the report can't be matched to
+// the organization the ID belongs to
+func anonymize(id string) string {
+	if id == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte("relai-gateway-telemetry:" + id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// BuildReport turns the raw counts into the anonymized report
+func BuildReport(stats *models.DeploymentStats, now time.Time) Report {
+	providers := map[string]int{}
+	for provider, count := range stats.ProviderModels {
+		if !knownProviders[provider] {
+			provider = "other"
+		}
+		providers[provider] += count
+	}
+	return Report{
+		DeploymentID:  anonymize(stats.DeploymentID),
+		Version:       version.Get().Version,
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		Providers:     providers,
+		Organizations: Bucket(stats.Organizations),
+		Requests24h:   Bucket(stats.Requests24h),
+		SentAt:        now.UTC(),
+	}
+}
+
+// Collect builds the report from the database's current counts
+func Collect(database *sql.DB) (*Report, error) {
+	stats, err := db.GetDeploymentStats(database)
+	if err != nil {
+		return nil, err
+	}
+	report := BuildReport(stats, time.Now())
+	return &report, nil
+}
+
+// Send posts the report to the endpoint as JSON
+func Send(ctx context.Context, client *http.Client, endpoint string, report *Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// report collects and sends one report. Failures are only logged: telemetry must never get in
+// the way of the gateway.
+func report(ctx context.Context, database *sql.DB, client *http.Client, endpoint string) {
+	r, err := Collect(database)
+	if err != nil {
+		log.Printf("Failed to collect telemetry: %v", err)
+		return
+	}
+	if err := Send(ctx, client, endpoint, r); err != nil {
+		log.Printf("Failed to send telemetry: %v", err)
+	}
+}
+
+// StartReporter sends a report at start and every TELEMETRY_INTERVAL when TELEMETRY_ENABLED and
+// TELEMETRY_ENDPOINT are both set. Otherwise it does nothing. The returned func stops it.
+func StartReporter(database *sql.DB) (stop func()) {
+	cfg := ConfigFromEnv()
+	if !cfg.Enabled {
+		return func() {}
+	}
+	if cfg.Endpoint == "" {
+		log.Println("TELEMETRY_ENABLED is set but TELEMETRY_ENDPOINT is not; telemetry disabled")
+		return func() {}
+	}
+	log.Printf("Sending anonymized telemetry to %s every %s", cfg.Endpoint, cfg.Interval)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &http.Client{Timeout: 30 * time.Second}
+	go func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			report(ctx, database, client, cfg.Endpoint)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,78 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+func TestBucket(t *testing.T) {
+	cases := map[int64]string{0: "0", 1: "1-99", 99: "1-99", 100: "100-999", 9999: "1k-10k", 250000: "100k-1M", 5000000: "1M+"}
+	for n, want := range cases {
+		assert.Equal(t, want, Bucket(n), "Bucket(%d)", n)
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	stats := &models.DeploymentStats{
+		DeploymentID:   "5b0c2a9e-4d7c-4f43-9c8e-1f1f6a3c2b10",
+		Organizations:  3,
+		ProviderModels: map[string]int{"openai": 4, "anthropic": 1, "acme-internal": 2, "corp-llm": 1},
+		Requests24h:    12345,
+	}
+	report := BuildReport(stats, time.Now())
+
+	assert.Equal(t, map[string]int{"openai": 4, "anthropic": 1, "other": 3}, report.Providers)
+	assert.Equal(t, "1-99", report.Organizations)
+	assert.Equal(t, "10k-100k", report.Requests24h)
+	assert.Len(t, report.DeploymentID, 32)
+	assert.NotContains(t, report.DeploymentID, "5b0c2a9e")
+	assert.Equal(t, report.DeploymentID, BuildReport(stats, time.Now()).DeploymentID)
+}
+
+func TestSend(t *testing.T) {
+	var received Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	report := BuildReport(&models.DeploymentStats{ProviderModels: map[string]int{}}, time.Now())
+	require.NoError(t, Send(context.Background(), http.DefaultClient, server.URL, &report))
+	assert.Equal(t, report.Version, received.Version)
+	assert.Equal(t, "0", received.Requests24h)
+}
+
+func TestSendRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	report := BuildReport(&models.DeploymentStats{}, time.Now())
+	assert.Error(t, Send(context.Background(), http.DefaultClient, server.URL, &report))
+}
+
+func TestConfigFromEnvDefaultsOff(t *testing.T) {
+	t.Setenv("TELEMETRY_ENABLED", "")
+	t.Setenv("TELEMETRY_INTERVAL", "")
+	cfg := ConfigFromEnv()
+	assert.False(t, cfg.Enabled)
+	assert.Equal(t, defaultInterval, cfg.Interval)
+
+	t.Setenv("TELEMETRY_ENABLED", "true")
+	t.Setenv("TELEMETRY_INTERVAL", "6h")
+	cfg = ConfigFromEnv()
+	assert.True(t, cfg.Enabled)
+	assert.Equal(t, 6*time.Hour, cfg.Interval)
+}
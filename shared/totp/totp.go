@@ -0,0 +1,70 @@
+// Package totp checks time-based one-time passwords (RFC 6238) as produced by authenticator
+// apps: six digits from HMAC-SHA1 over 30-second steps of a base32 secret.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is how long each code is valid
+	Period = 30 * time.Second
+	digits = 6
+	// skew is how many steps either side of now are accepted, for clock drift and slow typing
+	skew = 1
+)
+
+// DecodeSecret parses a base32 secret as shown by authenticator apps, ignoring case, spaces
+// and padding
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("invalid TOTP secret: empty")
+	}
+	return key, nil
+}
+
+// Step returns the time step t falls in
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code for a time step
+func Code(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%1000000)
+}
+
+// Validate reports whether code is valid at now, and for which time step, so callers can
+// refuse a code that was already used
+func Validate(key []byte, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != digits {
+		return 0, false
+	}
+	current := Step(now)
+	for step := current - skew; step <= current+skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(Code(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
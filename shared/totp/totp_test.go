@@ -0,0 +1,63 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeMatchesRFCVectors(t *testing.T) {
+	key, err := DecodeSecret(rfcSecret)
+	if err != nil {
+		t.Fatalf("DecodeSecret() error = %v", err)
+	}
+	// The RFC lists eight digits; six-digit codes are their last six
+	for unix, want := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		if got := Code(key, Step(time.Unix(unix, 0))); got != want {
+			t.Errorf("Code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	key, _ := DecodeSecret(rfcSecret)
+	now := time.Unix(1111111111, 0)
+	code := Code(key, Step(now))
+
+	if step, ok := Validate(key, code, now); !ok || step != Step(now) {
+		t.Errorf("Validate(current code) = %d, %v", step, ok)
+	}
+	if _, ok := Validate(key, code, now.Add(Period)); !ok {
+		t.Error("Validate() refused the previous step's code")
+	}
+	if _, ok := Validate(key, code, now.Add(3*Period)); ok {
+		t.Error("Validate() accepted a code three steps old")
+	}
+	if _, ok := Validate(key, "000000", now); ok {
+		t.Error("Validate() accepted a wrong code")
+	}
+	if _, ok := Validate(key, "12345", now); ok {
+		t.Error("Validate() accepted a short code")
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	if _, err := DecodeSecret("gezd gnbv gy3t qojq"); err != nil {
+		t.Errorf("DecodeSecret() with spaces and lower case: %v", err)
+	}
+	if _, err := DecodeSecret("not base32!"); err == nil {
+		t.Error("DecodeSecret() accepted an invalid secret")
+	}
+	if _, err := DecodeSecret(""); err == nil {
+		t.Error("DecodeSecret() accepted an empty secret")
+	}
+}
@@ -0,0 +1,90 @@
+package usage
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ContextFit is a chat conversation cut down to fit a token budget
+type ContextFit struct {
+	Messages     []json.RawMessage // Kept messages, in order
+	Dropped      []json.RawMessage // Removed messages, oldest first
+	Instructions int               // Leading system and developer messages at the front of Messages
+	PromptTokens int               // Prompt tokens of Messages
+}
+
+// PromptTokens counts the prompt tokens of a chat or legacy completion request with the
+// model's tokenizer
+func PromptTokens(modelID string, requestBody []byte) (int, error) {
+	return NewTiktokenExtractor(modelID).countPromptTokens(requestBody)
+}
+
+// FitMessages drops the oldest messages until the conversation's prompt is at most budget
+// tokens. Leading system and developer messages and the latest message are always kept, and
+// tool results don't outlive the assistant message that asked for them. ok is false when the
+// messages that must be kept are already over the budget.
+func FitMessages(modelID string, messages []json.RawMessage, budget int) (fit ContextFit, ok bool) {
+	return fitMessages(messages, budget, replyPrimingTokens, NewTiktokenExtractor(modelID).messageTokens)
+}
+
+func fitMessages(messages []json.RawMessage, budget, overhead int, count func(promptMessage) int) (ContextFit, bool) {
+	decoded := make([]promptMessage, len(messages))
+	tokens := make([]int, len(messages))
+	total := overhead
+	for i, raw := range messages {
+		_ = json.Unmarshal(raw, &decoded[i])
+		tokens[i] = count(decoded[i])
+		total += tokens[i]
+	}
+
+	instructions := 0
+	for instructions < len(messages)-1 && isInstructionRole(decoded[instructions].Role) {
+		instructions++
+	}
+
+	// Drop from just after the instructions, never the latest message
+	start := instructions
+	last := len(messages) - 1
+	for total > budget && start < last {
+		total -= tokens[start]
+		start++
+		for start < last && decoded[start].Role == "tool" {
+			total -= tokens[start]
+			start++
+		}
+	}
+
+	kept := make([]json.RawMessage, 0, instructions+len(messages)-start)
+	kept = append(kept, messages[:instructions]...)
+	kept = append(kept, messages[start:]...)
+	return ContextFit{
+		Messages:     kept,
+		Dropped:      messages[instructions:start],
+		Instructions: instructions,
+		PromptTokens: total,
+	}, total <= budget
+}
+
+func isInstructionRole(role string) bool {
+	return role == "system" || role == "developer"
+}
+
+// ConversationText renders messages as "role: text" paragraphs, e.g. for a model to summarize
+func ConversationText(messages []json.RawMessage) string {
+	var builder strings.Builder
+	for _, raw := range messages {
+		var msg promptMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			continue
+		}
+		text := messageText(msg.Content)
+		if text == "" {
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("\n\n")
+		}
+		builder.WriteString(msg.Role + ": " + text)
+	}
+	return builder.String()
+}
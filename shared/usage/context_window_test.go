@@ -0,0 +1,53 @@
+package usage
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFitMessages(t *testing.T) {
+	messages := []json.RawMessage{
+		json.RawMessage(`{"role":"system","content":"be brief"}`),
+		json.RawMessage(`{"role":"user","content":"one"}`),
+		json.RawMessage(`{"role":"assistant","content":null,"tool_calls":[]}`),
+		json.RawMessage(`{"role":"tool","content":"result"}`),
+		json.RawMessage(`{"role":"user","content":"two"}`),
+		json.RawMessage(`{"role":"assistant","content":"three"}`),
+		json.RawMessage(`{"role":"user","content":"four"}`),
+	}
+	// Every message costs 10 tokens, plus 3 for the reply
+	count := func(promptMessage) int { return 10 }
+
+	fit, ok := fitMessages(messages, 100, 3, count)
+	if !ok || len(fit.Messages) != len(messages) || len(fit.Dropped) != 0 || fit.PromptTokens != 73 {
+		t.Fatalf("conversation within budget = %+v, %v", fit, ok)
+	}
+
+	// Dropping the assistant tool call takes its tool result with it
+	fit, ok = fitMessages(messages, 50, 3, count)
+	if !ok {
+		t.Fatal("expected the conversation to fit")
+	}
+	if fit.Instructions != 1 || len(fit.Dropped) != 3 || fit.PromptTokens != 43 {
+		t.Errorf("fit = %+v", fit)
+	}
+	if string(fit.Messages[0]) != string(messages[0]) || string(fit.Messages[1]) != string(messages[4]) {
+		t.Errorf("kept %s", fit.Messages)
+	}
+
+	// The system prompt and latest message alone are over budget
+	if _, ok := fitMessages(messages, 20, 3, count); ok {
+		t.Error("expected a budget below the required messages to fail")
+	}
+}
+
+func TestConversationText(t *testing.T) {
+	messages := []json.RawMessage{
+		json.RawMessage(`{"role":"user","content":"hi"}`),
+		json.RawMessage(`{"role":"assistant","content":null}`),
+		json.RawMessage(`{"role":"assistant","content":[{"type":"text","text":"hello"}]}`),
+	}
+	if got, want := ConversationText(messages), "user: hi\n\nassistant: hello"; got != want {
+		t.Errorf("ConversationText() = %q, want %q", got, want)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/like-mike/relai-gateway/shared/db"
 	"github.com/like-mike/relai-gateway/shared/models"
 )
 
@@ -45,6 +47,31 @@ func (c *DatabaseCostCalculator) CalculateCost(usage *models.AIProviderUsage, mo
 		return c.calculateFallbackCost(usage, modelID)
 	}
 
+	// A price in effect now, with its time-of-day multiplier, takes precedence over the model's
+	// own costs; those apply until the model is given prices
+	now := time.Now()
+	price, err := db.GetModelPriceAt(c.database, modelID, now)
+	if err == nil {
+		cost := price.Cost(usage.PromptTokens, usage.CompletionTokens, now)
+		log.Printf("Calculated cost for model %s using price %s: $%.6f", modelID, price.ID, cost)
+		return cost, nil
+	} else if err != sql.ErrNoRows {
+		log.Printf("Failed to get price for model %s, using its own costs: %v", modelID, err)
+	}
+
+	// Self-hosted models cost nothing unless priced explicitly (e.g. to charge back GPU time), so
+	// they never fall back to SaaS pricing
+	if models.IsSelfHostedProvider(model.Provider) {
+		var inputCostPer1M, outputCostPer1M float64
+		if model.InputCostPer1M != nil {
+			inputCostPer1M = *model.InputCostPer1M
+		}
+		if model.OutputCostPer1M != nil {
+			outputCostPer1M = *model.OutputCostPer1M
+		}
+		return (float64(usage.PromptTokens)*inputCostPer1M + float64(usage.CompletionTokens)*outputCostPer1M) / 1000000.0, nil
+	}
+
 	// Use model's cost fields if available
 	if model.InputCostPer1M != nil && model.OutputCostPer1M != nil &&
 		*model.InputCostPer1M > 0 && *model.OutputCostPer1M > 0 {
@@ -0,0 +1,126 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// knownEncodings are the encodings getEncodingForModel can return, loaded by WarmEncoders
+var knownEncodings = []string{"o200k_base", "cl100k_base", "p50k_base"}
+
+// encoderEntry lazily builds one encoding's encoder; building it parses the BPE ranks and
+// compiles its regexes, so it's done once per process rather than once per request
+type encoderEntry struct {
+	once sync.Once
+	tkm  *tiktoken.Tiktoken
+	err  error
+}
+
+var (
+	encodersMu sync.Mutex
+	encoders   = map[string]*encoderEntry{}
+)
+
+// getEncoder returns the cached encoder for an encoding, building it on first use. A failed
+// build (e.g. the BPE file couldn't be fetched) isn't cached, so the next call retries.
+func getEncoder(encodingName string) (*tiktoken.Tiktoken, error) {
+	encodersMu.Lock()
+	entry, ok := encoders[encodingName]
+	if !ok {
+		entry = &encoderEntry{}
+		encoders[encodingName] = entry
+	}
+	encodersMu.Unlock()
+
+	entry.once.Do(func() {
+		entry.tkm, entry.err = tiktoken.GetEncoding(encodingName)
+	})
+	if entry.err != nil {
+		encodersMu.Lock()
+		if encoders[encodingName] == entry {
+			delete(encoders, encodingName)
+		}
+		encodersMu.Unlock()
+	}
+	return entry.tkm, entry.err
+}
+
+// ConfigureEncoders reads BPE files from TIKTOKEN_BPE_DIR, when set, instead of downloading
+// them from OpenAI at runtime. The directory holds the files under their published names,
+// e.g. cl100k_base.tiktoken. Call it before any tokens are counted.
+func ConfigureEncoders() {
+	if dir := os.Getenv("TIKTOKEN_BPE_DIR"); dir != "" {
+		tiktoken.SetBpeLoader(offlineBpeLoader{dir: dir})
+		log.Printf("Tiktoken BPE files will be read from %s", dir)
+	}
+}
+
+// WarmEncoders builds the encoders for all known models so the first requests don't pay for it
+func WarmEncoders() {
+	for _, name := range knownEncodings {
+		if _, err := getEncoder(name); err != nil {
+			log.Printf("Failed to load tiktoken encoding %s: %v", name, err)
+		}
+	}
+}
+
+// VerifyEncoders builds the encoders for all known models and returns the first that fails
+func VerifyEncoders() error {
+	for _, name := range knownEncodings {
+		tkm, err := getEncoder(name)
+		if err != nil {
+			return fmt.Errorf("tiktoken encoding %s: %w", name, err)
+		}
+		if len(tkm.Encode("preflight", nil, nil)) == 0 {
+			return fmt.Errorf("tiktoken encoding %s produced no tokens", name)
+		}
+	}
+	return nil
+}
+
+// offlineBpeLoader loads BPE ranks from a local directory and never goes to the network
+type offlineBpeLoader struct {
+	dir string
+}
+
+// LoadTiktokenBpe implements tiktoken.BpeLoader; bpeURL is the file's download URL
+func (l offlineBpeLoader) LoadTiktokenBpe(bpeURL string) (map[string]int, error) {
+	file, err := os.Open(filepath.Join(l.dir, path.Base(bpeURL)))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("malformed BPE line in %s", file.Name())
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, err
+		}
+		ranks[string(decoded)] = n
+	}
+	return ranks, scanner.Err()
+}
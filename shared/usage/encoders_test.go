@@ -0,0 +1,27 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOfflineBpeLoader(t *testing.T) {
+	dir := t.TempDir()
+	// "!" and "ab", base64 encoded
+	if err := os.WriteFile(filepath.Join(dir, "test_base.tiktoken"), []byte("IQ== 0\nYWI= 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ranks, err := offlineBpeLoader{dir: dir}.LoadTiktokenBpe("https://openaipublic.blob.core.windows.net/encodings/test_base.tiktoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks) != 2 || ranks["!"] != 0 || ranks["ab"] != 1 {
+		t.Errorf("ranks = %v", ranks)
+	}
+
+	if _, err := (offlineBpeLoader{dir: dir}).LoadTiktokenBpe("https://example.com/missing.tiktoken"); err == nil {
+		t.Error("expected a missing file to fail instead of being downloaded")
+	}
+}
@@ -1,18 +1,12 @@
 package usage
 
 import (
-	"bytes"
-	"compress/flate"
-	"compress/gzip"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"strings"
 
-	"github.com/andybalholm/brotli"
 	"github.com/like-mike/relai-gateway/shared/models"
 )
 
@@ -35,14 +29,7 @@ func (e *OpenAIExtractor) ExtractUsage(responseBody []byte) (*models.AIProviderU
 		return nil, errors.New("response too small to contain usage data")
 	}
 
-	// Try to decompress if response appears to be gzipped
-	decompressedBody, err := e.decompressIfNeeded(responseBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decompress response: %w", err)
-	}
-
-	// Use decompressed body for further processing
-	responseBody = decompressedBody
+	// The proxy decodes any Content-Encoding before the body gets here, so the body is plain
 
 	// Log response info for debugging (only if extraction fails)
 	defer func() {
@@ -88,132 +75,6 @@ func (e *OpenAIExtractor) ExtractUsage(responseBody []byte) (*models.AIProviderU
 	return &response.Usage, nil
 }
 
-// decompressIfNeeded checks for various compression formats and decompresses them
-func (e *OpenAIExtractor) decompressIfNeeded(data []byte) ([]byte, error) {
-	if len(data) < 2 {
-		return data, nil
-	}
-
-	// Log hex dump of first 32 bytes for debugging
-	debugHex := hex.EncodeToString(data[:min(32, len(data))])
-	log.Printf("Response hex dump (first 32 bytes): %s", debugHex)
-
-	// Try gzip first (magic: 1f 8b)
-	if data[0] == 0x1f && data[1] == 0x8b {
-		log.Printf("Detected gzip compression, decompressing...")
-		return e.decompressGzip(data)
-	}
-
-	// Try deflate/zlib (magic: 78 xx where xx can be 01, 5e, 9c, da)
-	if data[0] == 0x78 && (data[1] == 0x01 || data[1] == 0x5e || data[1] == 0x9c || data[1] == 0xda) {
-		log.Printf("Detected zlib/deflate compression, decompressing...")
-		return e.decompressZlib(data)
-	}
-
-	// Try Brotli decompression (binary format, no clear magic number)
-	if !e.isPrintableText(data[:min(100, len(data))]) {
-		log.Printf("Attempting Brotli decompression...")
-		if decompressed, err := e.decompressBrotli(data); err == nil {
-			return decompressed, nil
-		}
-
-		log.Printf("Attempting raw deflate decompression...")
-		if decompressed, err := e.decompressRawDeflate(data); err == nil {
-			return decompressed, nil
-		}
-
-		// If all decompression attempts fail, log detailed info
-		log.Printf("Failed all decompression attempts. Response details:")
-		log.Printf("  Length: %d bytes", len(data))
-		log.Printf("  First 4 bytes: %02x %02x %02x %02x", data[0], data[1], data[2], data[3])
-		log.Printf("  Appears to be binary/compressed data")
-
-		return nil, errors.New("response appears to be compressed in unsupported format")
-	}
-
-	// Return original data if it appears to be text
-	return data, nil
-}
-
-// Helper function for min
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
-
-// decompressGzip decompresses gzip data
-func (e *OpenAIExtractor) decompressGzip(data []byte) ([]byte, error) {
-	reader, err := gzip.NewReader(bytes.NewReader(data))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
-	}
-	defer reader.Close()
-
-	decompressed, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decompress gzip data: %w", err)
-	}
-
-	log.Printf("Successfully decompressed gzip: %d bytes -> %d bytes", len(data), len(decompressed))
-	return decompressed, nil
-}
-
-// decompressZlib decompresses zlib/deflate data with headers
-func (e *OpenAIExtractor) decompressZlib(data []byte) ([]byte, error) {
-	reader := flate.NewReader(bytes.NewReader(data))
-	defer reader.Close()
-
-	decompressed, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decompress zlib data: %w", err)
-	}
-
-	log.Printf("Successfully decompressed zlib: %d bytes -> %d bytes", len(data), len(decompressed))
-	return decompressed, nil
-}
-
-// decompressRawDeflate attempts raw deflate decompression (no headers)
-func (e *OpenAIExtractor) decompressRawDeflate(data []byte) ([]byte, error) {
-	reader := flate.NewReader(bytes.NewReader(data))
-	defer reader.Close()
-
-	var buf bytes.Buffer
-	_, err := io.Copy(&buf, reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decompress raw deflate data: %w", err)
-	}
-
-	decompressed := buf.Bytes()
-	log.Printf("Successfully decompressed raw deflate: %d bytes -> %d bytes", len(data), len(decompressed))
-	return decompressed, nil
-}
-
-// decompressBrotli attempts Brotli decompression
-func (e *OpenAIExtractor) decompressBrotli(data []byte) ([]byte, error) {
-	reader := brotli.NewReader(bytes.NewReader(data))
-
-	decompressed, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decompress Brotli data: %w", err)
-	}
-
-	log.Printf("Successfully decompressed Brotli: %d bytes -> %d bytes", len(data), len(decompressed))
-	return decompressed, nil
-}
-
-// isPrintableText checks if the data contains mostly printable ASCII characters
-func (e *OpenAIExtractor) isPrintableText(data []byte) bool {
-	printableCount := 0
-	for _, b := range data {
-		if b >= 32 && b <= 126 || b == 9 || b == 10 || b == 13 { // printable ASCII + tab/newline/CR
-			printableCount++
-		}
-	}
-	return float64(printableCount)/float64(len(data)) > 0.7 // 70% printable characters
-}
-
 // AnthropicExtractor extracts usage from Anthropic API responses
 type AnthropicExtractor struct{}
 
@@ -309,7 +170,9 @@ func NewExtractorFactory() *ExtractorFactory {
 
 func (f *ExtractorFactory) GetExtractor(provider string) UsageExtractor {
 	switch provider {
-	case "openai":
+	case "openai", "vertex", "ollama", "vllm":
+		// Vertex AI responses are translated to the OpenAI shape before they get here, and the
+		// self-hosted servers speak it natively
 		return &OpenAIExtractor{}
 	case "anthropic":
 		return &AnthropicExtractor{}
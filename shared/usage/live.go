@@ -0,0 +1,63 @@
+package usage
+
+import (
+	"sync"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// liveBuffer is how many deltas a subscriber can fall behind before new ones are dropped for it
+const liveBuffer = 256
+
+// LiveUsage fans recorded usage out to the live dashboards subscribed to it, in this process
+// only. A subscriber too slow to keep up misses deltas rather than holding up the workers.
+type LiveUsage struct {
+	mu          sync.Mutex
+	subscribers map[chan models.UsageDelta]struct{}
+}
+
+// NewLiveUsage returns a feed with no subscribers
+func NewLiveUsage() *LiveUsage {
+	return &LiveUsage{subscribers: map[chan models.UsageDelta]struct{}{}}
+}
+
+// Live is fed by the usage workers as each request's usage is recorded
+var Live = NewLiveUsage()
+
+// Subscribe returns a channel of the deltas published from now on. The returned func
+// unsubscribes and closes the channel.
+func (l *LiveUsage) Subscribe() (<-chan models.UsageDelta, func()) {
+	ch := make(chan models.UsageDelta, liveBuffer)
+	l.mu.Lock()
+	l.subscribers[ch] = struct{}{}
+	l.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.mu.Lock()
+			delete(l.subscribers, ch)
+			l.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish sends a delta to every subscriber with room for it
+func (l *LiveUsage) Publish(delta models.UsageDelta) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ch := range l.subscribers {
+		select {
+		case ch <- delta:
+		default:
+		}
+	}
+}
+
+// Subscribers returns how many dashboards are listening
+func (l *LiveUsage) Subscribers() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.subscribers)
+}
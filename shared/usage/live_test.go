@@ -0,0 +1,38 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+func TestLiveUsagePublish(t *testing.T) {
+	live := NewLiveUsage()
+	first, unsubscribeFirst := live.Subscribe()
+	second, unsubscribeSecond := live.Subscribe()
+	defer unsubscribeSecond()
+
+	live.Publish(models.UsageDelta{OrganizationID: "org", TotalTokens: 5})
+	for _, ch := range []<-chan models.UsageDelta{first, second} {
+		if delta := <-ch; delta.TotalTokens != 5 {
+			t.Errorf("delta = %+v, want 5 tokens", delta)
+		}
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, open := <-first; open {
+		t.Error("expected the channel to be closed after unsubscribing")
+	}
+	if n := live.Subscribers(); n != 1 {
+		t.Errorf("Subscribers() = %d, want 1", n)
+	}
+
+	// A subscriber that stops reading misses deltas instead of blocking the publisher
+	for i := 0; i < liveBuffer+10; i++ {
+		live.Publish(models.UsageDelta{OrganizationID: "org"})
+	}
+	if n := len(second); n != liveBuffer {
+		t.Errorf("buffered = %d, want %d", n, liveBuffer)
+	}
+}
@@ -0,0 +1,80 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// defaultQuotaReconcileInterval is how often quota counters are recomputed from usage_logs
+const defaultQuotaReconcileInterval = time.Hour
+
+// QuotaReconciler periodically recomputes quota and project counters from usage_logs. Every
+// replica may run one; the database lets only one reconcile at a time.
+type QuotaReconciler struct {
+	db       *sql.DB
+	interval time.Duration
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewQuotaReconcilerFromEnv returns a reconciler running every QUOTA_RECONCILE_INTERVAL (default
+// 1h), or nil when it is set to "off"
+func NewQuotaReconcilerFromEnv(conn *sql.DB) *QuotaReconciler {
+	setting := os.Getenv("QUOTA_RECONCILE_INTERVAL")
+	if setting == "off" {
+		return nil
+	}
+	interval, _ := time.ParseDuration(setting)
+	if interval <= 0 {
+		interval = defaultQuotaReconcileInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &QuotaReconciler{db: conn, interval: interval, ctx: ctx, cancel: cancel}
+}
+
+// Start reconciles on every interval; the first run waits one interval so startup stays fast
+func (r *QuotaReconciler) Start() {
+	log.Printf("Starting quota reconciler (interval %s)", r.interval)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if readonly.Enabled() {
+				continue
+			}
+
+			corrected, err := db.ReconcileQuotaUsage(r.db)
+			if err != nil {
+				log.Printf("Quota reconciliation failed: %v", err)
+			} else if corrected > 0 {
+				log.Printf("Quota reconciliation corrected %d counters from usage logs", corrected)
+			}
+		}
+	}()
+}
+
+// Stop gracefully shuts down the reconciler
+func (r *QuotaReconciler) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	log.Println("Quota reconciler stopped")
+}
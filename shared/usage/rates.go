@@ -0,0 +1,129 @@
+package usage
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// rateWindowSeconds is the longest window the rate counters answer for
+const rateWindowSeconds = 300
+
+type rateBucket struct {
+	second   int64
+	requests int64
+	tokens   int64
+}
+
+// rateRing holds one organization/model pair's traffic in one-second buckets, reused as the
+// window slides
+type rateRing struct {
+	buckets [rateWindowSeconds]rateBucket
+	last    int64 // Second of the most recent record
+}
+
+// RateCounters counts requests and tokens per organization and model over sliding one- and
+// five-minute windows, in this process only. Each gateway replica keeps its own.
+type RateCounters struct {
+	mu     sync.Mutex
+	rings  map[[2]string]*rateRing
+	limits map[string]models.ProviderRateLimit
+}
+
+// NewRateCounters returns empty counters
+func NewRateCounters() *RateCounters {
+	return &RateCounters{
+		rings:  map[[2]string]*rateRing{},
+		limits: map[string]models.ProviderRateLimit{},
+	}
+}
+
+// Rates are the gateway's live rate counters, fed as usage is submitted
+var Rates = NewRateCounters()
+
+// Record counts one request and its tokens at now
+func (r *RateCounters) Record(orgID, modelID string, tokens int64, now time.Time) {
+	second := now.Unix()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{orgID, modelID}
+	ring, ok := r.rings[key]
+	if !ok {
+		ring = &rateRing{}
+		r.rings[key] = ring
+	}
+	b := &ring.buckets[second%rateWindowSeconds]
+	if b.second != second {
+		*b = rateBucket{second: second}
+	}
+	b.requests++
+	b.tokens += tokens
+	if second > ring.last {
+		ring.last = second
+	}
+}
+
+// ObserveProviderLimits remembers the per-minute limits a provider reported for a model in its
+// x-ratelimit-* response headers. Responses without them are ignored.
+func (r *RateCounters) ObserveProviderLimits(modelID string, header http.Header, now time.Time) {
+	limit := models.ProviderRateLimit{
+		ModelID:           modelID,
+		RequestLimit:      headerInt(header, "X-Ratelimit-Limit-Requests"),
+		RemainingRequests: headerInt(header, "X-Ratelimit-Remaining-Requests"),
+		TokenLimit:        headerInt(header, "X-Ratelimit-Limit-Tokens"),
+		RemainingTokens:   headerInt(header, "X-Ratelimit-Remaining-Tokens"),
+		ObservedAt:        now,
+	}
+	if limit.RequestLimit == 0 && limit.TokenLimit == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	r.limits[modelID] = limit
+	r.mu.Unlock()
+}
+
+func headerInt(header http.Header, name string) int64 {
+	n, _ := strconv.ParseInt(header.Get(name), 10, 64)
+	return n
+}
+
+// Snapshot sums the buckets of the last one and five minutes. Pairs without traffic in the
+// last five minutes are dropped.
+func (r *RateCounters) Snapshot(now time.Time) models.RateSnapshot {
+	second := now.Unix()
+	snapshot := models.RateSnapshot{GeneratedAt: now}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, ring := range r.rings {
+		if second-ring.last >= rateWindowSeconds {
+			delete(r.rings, key)
+			continue
+		}
+		counter := models.RateCounter{OrganizationID: key[0], ModelID: key[1]}
+		for _, b := range ring.buckets {
+			age := second - b.second
+			if age < 0 || age >= rateWindowSeconds || b.requests == 0 {
+				continue
+			}
+			counter.Requests5m += b.requests
+			counter.Tokens5m += b.tokens
+			if age < 60 {
+				counter.Requests1m += b.requests
+				counter.Tokens1m += b.tokens
+			}
+		}
+		snapshot.Counters = append(snapshot.Counters, counter)
+	}
+	for _, l := range r.limits {
+		snapshot.ProviderLimits = append(snapshot.ProviderLimits, l)
+	}
+	return snapshot
+}
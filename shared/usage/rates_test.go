@@ -0,0 +1,57 @@
+package usage
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateCountersWindows(t *testing.T) {
+	rates := NewRateCounters()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	rates.Record("org", "gpt", 100, now.Add(-4*time.Minute))
+	rates.Record("org", "gpt", 50, now.Add(-30*time.Second))
+	rates.Record("org", "gpt", 25, now)
+	rates.Record("org", "old", 10, now.Add(-6*time.Minute))
+
+	snapshot := rates.Snapshot(now)
+	if len(snapshot.Counters) != 1 {
+		t.Fatalf("counters = %+v, want only the pair with recent traffic", snapshot.Counters)
+	}
+	c := snapshot.Counters[0]
+	if c.Requests1m != 2 || c.Tokens1m != 75 {
+		t.Errorf("1m = %d requests, %d tokens; want 2 and 75", c.Requests1m, c.Tokens1m)
+	}
+	if c.Requests5m != 3 || c.Tokens5m != 175 {
+		t.Errorf("5m = %d requests, %d tokens; want 3 and 175", c.Requests5m, c.Tokens5m)
+	}
+
+	// A bucket reused five minutes later starts over
+	later := now.Add(5 * time.Minute)
+	rates.Record("org", "gpt", 1, later)
+	c = rates.Snapshot(later).Counters[0]
+	if c.Requests5m != 1 || c.Tokens5m != 1 {
+		t.Errorf("after five minutes: %d requests, %d tokens; want 1 and 1", c.Requests5m, c.Tokens5m)
+	}
+}
+
+func TestObserveProviderLimits(t *testing.T) {
+	rates := NewRateCounters()
+	now := time.Now()
+
+	rates.ObserveProviderLimits("gpt", http.Header{}, now)
+	if len(rates.Snapshot(now).ProviderLimits) != 0 {
+		t.Fatal("expected responses without rate limit headers to be ignored")
+	}
+
+	header := http.Header{}
+	header.Set("x-ratelimit-limit-tokens", "30000")
+	header.Set("x-ratelimit-remaining-tokens", "29000")
+	rates.ObserveProviderLimits("gpt", header, now)
+
+	limits := rates.Snapshot(now).ProviderLimits
+	if len(limits) != 1 || limits[0].TokenLimit != 30000 || limits[0].RemainingTokens != 29000 {
+		t.Errorf("provider limits = %+v", limits)
+	}
+}
@@ -0,0 +1,120 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// spoolFile is where jobs are appended; replays move it aside first
+const spoolFile = "usage.jsonl"
+
+// usageSpool keeps usage jobs on disk while the database can't be written (read-only mode), one
+// JSON job per line, so they can be recorded once it can
+type usageSpool struct {
+	dir string
+	mu  sync.Mutex
+	// replaying serializes replays so a job is never recorded twice
+	replaying sync.Mutex
+}
+
+// defaultSpoolDir is USAGE_SPOOL_DIR, or data/usage-spool under the working directory. It must
+// outlive restarts, so in a container it belongs on a persistent volume; the temp dir doesn't.
+func defaultSpoolDir() string {
+	if dir := os.Getenv("USAGE_SPOOL_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("data", "usage-spool")
+}
+
+// Append writes job to the end of the spool
+func (s *usageSpool) Append(job *UsageLogJob) error {
+	line, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, spoolFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Replay hands every spooled job to record, oldest first. Jobs record fails on are spooled
+// again. Files left by a replay that was interrupted are picked up too.
+func (s *usageSpool) Replay(record func(*UsageLogJob) error) {
+	s.replaying.Lock()
+	defer s.replaying.Unlock()
+
+	// Move the spool aside so jobs appended meanwhile go to a fresh file
+	s.mu.Lock()
+	current := filepath.Join(s.dir, spoolFile)
+	if _, err := os.Stat(current); err == nil {
+		aside := filepath.Join(s.dir, fmt.Sprintf("replay-%d.jsonl", time.Now().UnixNano()))
+		if err := os.Rename(current, aside); err != nil {
+			log.Printf("Failed to move usage spool aside: %v", err)
+		}
+	}
+	s.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "replay-*.jsonl"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+	sort.Strings(files)
+
+	for _, path := range files {
+		recorded, respooled, err := s.replayFile(path, record)
+		if err != nil {
+			log.Printf("Failed to replay usage spool %s: %v", path, err)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("Failed to remove replayed usage spool %s: %v", path, err)
+		}
+		log.Printf("Replayed usage spool %s: %d recorded, %d spooled again", filepath.Base(path), recorded, respooled)
+	}
+}
+
+func (s *usageSpool) replayFile(path string, record func(*UsageLogJob) error) (recorded, respooled int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var job UsageLogJob
+		if err := json.Unmarshal(scanner.Bytes(), &job); err != nil {
+			log.Printf("Skipping unreadable usage spool line: %v", err)
+			continue
+		}
+		if err := record(&job); err != nil {
+			if err := s.Append(&job); err != nil {
+				return recorded, respooled, err
+			}
+			respooled++
+			continue
+		}
+		recorded++
+	}
+	return recorded, respooled, scanner.Err()
+}
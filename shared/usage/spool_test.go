@@ -0,0 +1,55 @@
+package usage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+func TestUsageSpoolReplay(t *testing.T) {
+	spool := &usageSpool{dir: t.TempDir()}
+	madeAt := time.Date(2026, 9, 30, 23, 59, 0, 0, time.UTC)
+	for _, org := range []string{"org-1", "org-2", "org-3"} {
+		job := &UsageLogJob{OrganizationID: org, Usage: &models.AIProviderUsage{TotalTokens: 10}, CreatedAt: madeAt}
+		if err := spool.Append(job); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	// org-2 fails and stays spooled
+	var recorded []string
+	spool.Replay(func(job *UsageLogJob) error {
+		if job.OrganizationID == "org-2" {
+			return errors.New("database unavailable")
+		}
+		recorded = append(recorded, job.OrganizationID)
+		return nil
+	})
+	if len(recorded) != 2 || recorded[0] != "org-1" || recorded[1] != "org-3" {
+		t.Errorf("first replay recorded %v", recorded)
+	}
+
+	recorded = nil
+	spool.Replay(func(job *UsageLogJob) error {
+		// The replayed job keeps the time it was made, so it is logged in its own period
+		if job.Usage == nil || job.Usage.TotalTokens != 10 || !job.CreatedAt.Equal(madeAt) {
+			t.Errorf("replayed job = %+v", job)
+		}
+		recorded = append(recorded, job.OrganizationID)
+		return nil
+	})
+	if len(recorded) != 1 || recorded[0] != "org-2" {
+		t.Errorf("second replay recorded %v", recorded)
+	}
+
+	recorded = nil
+	spool.Replay(func(job *UsageLogJob) error {
+		recorded = append(recorded, job.OrganizationID)
+		return nil
+	})
+	if len(recorded) != 0 {
+		t.Errorf("empty spool replayed %v", recorded)
+	}
+}
@@ -0,0 +1,122 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// StreamCollector is an incremental parser for streamed (SSE) responses. The response is teed
+// into it as it passes through the gateway; it keeps only the completion text and the usage
+// chunk providers send when stream_options.include_usage is set, so usage can be counted
+// without holding the whole response in memory. Lines may be split across writes and end in
+// LF, CRLF or CR.
+type StreamCollector struct {
+	pending    []byte // Incomplete trailing line from the last write
+	skipLF     bool   // The last write ended in CR, so a leading LF finishes that line ending
+	completion strings.Builder
+	usage      *models.AIProviderUsage
+	size       int64
+}
+
+// NewStreamCollector creates an empty collector
+func NewStreamCollector() *StreamCollector {
+	return &StreamCollector{}
+}
+
+// Write consumes the next bytes of the stream. It never fails.
+func (s *StreamCollector) Write(p []byte) (int, error) {
+	s.size += int64(len(p))
+	data := p
+	if s.skipLF && len(data) > 0 {
+		s.skipLF = false
+		if data[0] == '\n' {
+			data = data[1:]
+		}
+	}
+
+	for {
+		i := bytes.IndexAny(data, "\r\n")
+		if i < 0 {
+			break
+		}
+		if len(s.pending) > 0 {
+			s.consumeLine(append(s.pending, data[:i]...))
+			s.pending = s.pending[:0]
+		} else {
+			s.consumeLine(data[:i])
+		}
+
+		if data[i] == '\r' {
+			if i+1 == len(data) {
+				s.skipLF = true
+			} else if data[i+1] == '\n' {
+				i++
+			}
+		}
+		data = data[i+1:]
+	}
+	s.pending = append(s.pending, data...)
+	return len(p), nil
+}
+
+// Completion returns the text streamed so far, including a final line without a line ending
+func (s *StreamCollector) Completion() string {
+	s.flush()
+	return s.completion.String()
+}
+
+// Usage returns the usage the provider reported in the stream, or nil if it sent none
+func (s *StreamCollector) Usage() *models.AIProviderUsage {
+	s.flush()
+	return s.usage
+}
+
+// Size returns the number of bytes streamed
+func (s *StreamCollector) Size() int64 {
+	return s.size
+}
+
+func (s *StreamCollector) flush() {
+	if len(s.pending) > 0 {
+		s.consumeLine(s.pending)
+		s.pending = s.pending[:0]
+	}
+}
+
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		Text string `json:"text"`
+	} `json:"choices"`
+	Usage *models.AIProviderUsage `json:"usage"`
+}
+
+func (s *StreamCollector) consumeLine(line []byte) {
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	payload := bytes.TrimSpace(line[len("data:"):])
+	if len(payload) == 0 || string(payload) == "[DONE]" {
+		return
+	}
+
+	var chunk streamChunk
+	if err := json.Unmarshal(payload, &chunk); err != nil {
+		return
+	}
+	if len(chunk.Choices) > 0 {
+		s.completion.WriteString(chunk.Choices[0].Delta.Content)
+		s.completion.WriteString(chunk.Choices[0].Text)
+	}
+	if chunk.Usage != nil && (chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0) {
+		s.usage = chunk.Usage
+		if s.usage.TotalTokens == 0 {
+			s.usage.TotalTokens = s.usage.PromptTokens + s.usage.CompletionTokens
+		}
+	}
+}
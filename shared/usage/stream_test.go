@@ -0,0 +1,41 @@
+package usage
+
+import "testing"
+
+func TestStreamCollector(t *testing.T) {
+	body := "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\r\n\r\n" +
+		": keep-alive\r\r" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":2,\"total_tokens\":11}}\r\n\r\n" +
+		"data: [DONE]"
+
+	// Every split point, including between the CR and LF of a line ending
+	for size := 1; size <= len(body); size++ {
+		stream := NewStreamCollector()
+		for i := 0; i < len(body); i += size {
+			end := i + size
+			if end > len(body) {
+				end = len(body)
+			}
+			stream.Write([]byte(body[i:end]))
+		}
+
+		if got := stream.Completion(); got != "Hello" {
+			t.Fatalf("chunk size %d: completion = %q", size, got)
+		}
+		if u := stream.Usage(); u == nil || u.PromptTokens != 9 || u.CompletionTokens != 2 || u.TotalTokens != 11 {
+			t.Fatalf("chunk size %d: usage = %+v", size, u)
+		}
+		if stream.Size() != int64(len(body)) {
+			t.Fatalf("chunk size %d: size = %d", size, stream.Size())
+		}
+	}
+}
+
+func TestStreamCollectorWithoutUsage(t *testing.T) {
+	stream := NewStreamCollector()
+	stream.Write([]byte("data: {\"choices\":[{\"text\":\"legacy\"}]}\n\ndata: {\"choices\":[{\"text\":\"\"}],\"usage\":null}\n\ndata: not json\n\n"))
+	if stream.Completion() != "legacy" || stream.Usage() != nil {
+		t.Errorf("completion = %q, usage = %+v", stream.Completion(), stream.Usage())
+	}
+}
@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"regexp"
 	"strings"
 
 	"github.com/like-mike/relai-gateway/shared/models"
-	"github.com/pkoukk/tiktoken-go"
 )
 
 // TiktokenExtractor uses OpenAI's official tiktoken for accurate token counting
@@ -24,33 +24,68 @@ func NewTiktokenExtractor(modelID string) *TiktokenExtractor {
 
 // ExtractFromStreamingResponse counts tokens accurately for streaming responses
 func (e *TiktokenExtractor) ExtractFromStreamingResponse(responseBody []byte, requestBody []byte) (*models.AIProviderUsage, error) {
-	// Extract prompt from request
-	promptText, err := e.extractPromptFromRequest(requestBody)
-	if err != nil {
-		return nil, err
-	}
-
 	// Extract completion from streaming response
 	completionText, err := e.extractCompletionFromStream(responseBody)
 	if err != nil {
 		return nil, err
 	}
+	return e.ExtractFromCompletion(completionText, requestBody)
+}
 
+// ExtractFromCompletion counts tokens for a request and the completion text already collected
+// from its stream
+func (e *TiktokenExtractor) ExtractFromCompletion(completionText string, requestBody []byte) (*models.AIProviderUsage, error) {
 	// Count tokens accurately with tiktoken
-	promptTokens, err := e.countTokens(promptText)
+	promptTokens, err := e.countPromptTokens(requestBody)
 	if err != nil {
-		log.Printf("Failed to count prompt tokens, using estimation: %v", err)
-		promptTokens = e.estimateTokens(promptText)
+		return nil, err
 	}
+	completionTokens := e.countOrEstimate(completionText)
+
+	log.Printf("Tiktoken usage - Prompt: %d tokens, Completion: %d tokens (model: %s)",
+		promptTokens, completionTokens, e.modelID)
 
-	completionTokens, err := e.countTokens(completionText)
+	return &models.AIProviderUsage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}, nil
+}
+
+// ExtractFromResponse estimates usage for a complete (non-streaming) or streaming response,
+// for providers that don't report it
+func (e *TiktokenExtractor) ExtractFromResponse(responseBody []byte, requestBody []byte) (*models.AIProviderUsage, error) {
+	if !json.Valid(responseBody) {
+		return e.ExtractFromStreamingResponse(responseBody, requestBody)
+	}
+
+	promptTokens, err := e.countPromptTokens(requestBody)
 	if err != nil {
-		log.Printf("Failed to count completion tokens, using estimation: %v", err)
-		completionTokens = e.estimateTokens(completionText)
+		return nil, err
 	}
 
-	log.Printf("Tiktoken usage - Prompt: %d tokens, Completion: %d tokens (model: %s)",
-		promptTokens, completionTokens, e.modelID)
+	var response struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Text string `json:"text"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Choices) == 0 {
+		return nil, errors.New("response has no choices to count")
+	}
+
+	var completion strings.Builder
+	for _, choice := range response.Choices {
+		completion.WriteString(choice.Message.Content)
+		completion.WriteString(choice.Text)
+	}
+
+	completionTokens := e.countOrEstimate(completion.String())
 
 	return &models.AIProviderUsage{
 		PromptTokens:     promptTokens,
@@ -59,6 +94,16 @@ func (e *TiktokenExtractor) ExtractFromStreamingResponse(responseBody []byte, re
 	}, nil
 }
 
+// countOrEstimate counts text's tokens with tiktoken, estimating them if the encoder can't load
+func (e *TiktokenExtractor) countOrEstimate(text string) int {
+	tokens, err := e.countTokens(text)
+	if err != nil {
+		log.Printf("Failed to count tokens, using estimation: %v", err)
+		return e.estimateTokens(text)
+	}
+	return tokens
+}
+
 // countTokens uses tiktoken for accurate token counting
 func (e *TiktokenExtractor) countTokens(text string) (int, error) {
 	if text == "" {
@@ -68,7 +113,7 @@ func (e *TiktokenExtractor) countTokens(text string) (int, error) {
 	// Get the appropriate encoding for the model
 	encodingName := e.getEncodingForModel()
 
-	tkm, err := tiktoken.GetEncoding(encodingName)
+	tkm, err := getEncoder(encodingName)
 	if err != nil {
 		return 0, err
 	}
@@ -82,6 +127,9 @@ func (e *TiktokenExtractor) getEncodingForModel() string {
 	modelID := strings.ToLower(e.modelID)
 
 	switch {
+	case strings.Contains(modelID, "gpt-4o"), strings.Contains(modelID, "gpt-4.1"),
+		strings.Contains(modelID, "gpt-5"), o200kReasoningModel.MatchString(modelID):
+		return "o200k_base"
 	case strings.Contains(modelID, "gpt-4"):
 		return "cl100k_base"
 	case strings.Contains(modelID, "gpt-3.5-turbo"):
@@ -98,83 +146,97 @@ func (e *TiktokenExtractor) getEncodingForModel() string {
 	}
 }
 
-// extractPromptFromRequest extracts prompt text from request body
-func (e *TiktokenExtractor) extractPromptFromRequest(requestBody []byte) (string, error) {
-	var request map[string]interface{}
+// o200kReasoningModel matches the o1/o3/o4 model families, optionally behind a provider prefix
+var o200kReasoningModel = regexp.MustCompile(`(^|/)o[1-9](-|$)`)
+
+// Chat requests are framed with special tokens around every message, so their prompt is
+// larger than the text of its messages. These are the constants from OpenAI's cookbook
+// (num_tokens_from_messages): every message costs tokensPerMessage plus its role, content and
+// name; a name adds tokensPerName; and every reply is primed with replyPrimingTokens.
+const replyPrimingTokens = 3
+
+// chatFraming returns the per-message and per-name token overheads for the model
+func (e *TiktokenExtractor) chatFraming() (tokensPerMessage, tokensPerName int) {
+	if strings.Contains(strings.ToLower(e.modelID), "gpt-3.5-turbo-0301") {
+		// The name replaces the role in the earliest chat format
+		return 4, -1
+	}
+	return 3, 1
+}
+
+type promptMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+	Name    string          `json:"name"`
+}
+
+// countPromptTokens counts the prompt tokens of a chat or legacy completion request
+func (e *TiktokenExtractor) countPromptTokens(requestBody []byte) (int, error) {
+	var request struct {
+		Messages []promptMessage `json:"messages"`
+		Prompt   json.RawMessage `json:"prompt"`
+	}
 	if err := json.Unmarshal(requestBody, &request); err != nil {
-		return "", err
+		return 0, err
 	}
 
 	// Handle chat completion format (most common)
-	if messages, ok := request["messages"].([]interface{}); ok {
-		var fullPrompt strings.Builder
-		for _, msg := range messages {
-			if msgMap, ok := msg.(map[string]interface{}); ok {
-				if role, ok := msgMap["role"].(string); ok {
-					if content, ok := msgMap["content"].(string); ok {
-						// Format like OpenAI does internally
-						fullPrompt.WriteString(role)
-						fullPrompt.WriteString(": ")
-						fullPrompt.WriteString(content)
-						fullPrompt.WriteString("\n")
-					}
-				}
-			}
+	if request.Messages != nil {
+		tokens := replyPrimingTokens
+		for _, msg := range request.Messages {
+			tokens += e.messageTokens(msg)
 		}
-		return fullPrompt.String(), nil
+		return tokens, nil
 	}
 
 	// Handle legacy completion format
-	if prompt, ok := request["prompt"].(string); ok {
-		return prompt, nil
+	var prompt string
+	if err := json.Unmarshal(request.Prompt, &prompt); err == nil {
+		return e.countOrEstimate(prompt), nil
 	}
 
-	return "", errors.New("could not extract prompt from request")
+	return 0, errors.New("could not extract prompt from request")
 }
 
-// extractCompletionFromStream extracts completion text from streaming response
-func (e *TiktokenExtractor) extractCompletionFromStream(responseBody []byte) (string, error) {
-	lines := strings.Split(string(responseBody), "\n")
-	var completion strings.Builder
+// messageTokens counts one chat message, including its framing
+func (e *TiktokenExtractor) messageTokens(msg promptMessage) int {
+	tokensPerMessage, tokensPerName := e.chatFraming()
+	tokens := tokensPerMessage + e.countOrEstimate(msg.Role) + e.countOrEstimate(messageText(msg.Content))
+	if msg.Name != "" {
+		tokens += e.countOrEstimate(msg.Name) + tokensPerName
+	}
+	return tokens
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if strings.HasPrefix(line, "data: ") {
-			jsonStr := strings.TrimPrefix(line, "data: ")
-
-			if jsonStr == "[DONE]" {
-				continue
-			}
-
-			if !json.Valid([]byte(jsonStr)) {
-				continue
-			}
-
-			var chunk map[string]interface{}
-			if err := json.Unmarshal([]byte(jsonStr), &chunk); err != nil {
-				continue
-			}
-
-			// Extract content from streaming chunk
-			if choices, ok := chunk["choices"].([]interface{}); ok && len(choices) > 0 {
-				if choice, ok := choices[0].(map[string]interface{}); ok {
-					// Chat completion delta
-					if delta, ok := choice["delta"].(map[string]interface{}); ok {
-						if content, ok := delta["content"].(string); ok {
-							completion.WriteString(content)
-						}
-					}
-					// Legacy completion text
-					if text, ok := choice["text"].(string); ok {
-						completion.WriteString(text)
-					}
-				}
-			}
+// messageText returns a message's text: the content string, or the text parts of a multimodal
+// content array. Images are priced by size rather than tokenized, so they aren't counted.
+func messageText(content json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text
+	}
+
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return ""
+	}
+	var builder strings.Builder
+	for _, part := range parts {
+		if part.Type == "text" {
+			builder.WriteString(part.Text)
 		}
 	}
+	return builder.String()
+}
 
-	return completion.String(), nil
+// extractCompletionFromStream extracts completion text from streaming response
+func (e *TiktokenExtractor) extractCompletionFromStream(responseBody []byte) (string, error) {
+	stream := NewStreamCollector()
+	stream.Write(responseBody)
+	return stream.Completion(), nil
 }
 
 // estimateTokens provides fallback estimation if tiktoken fails
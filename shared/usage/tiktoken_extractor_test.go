@@ -0,0 +1,43 @@
+package usage
+
+import "testing"
+
+func TestGetEncodingForModel(t *testing.T) {
+	cases := map[string]string{
+		"gpt-4o-mini":       "o200k_base",
+		"openai/o3-mini":    "o200k_base",
+		"o1":                "o200k_base",
+		"gpt-4-turbo":       "cl100k_base",
+		"gpt-3.5-turbo":     "cl100k_base",
+		"text-davinci-003":  "p50k_base",
+		"llama3.1:8b":       "cl100k_base",
+		"claude-3-5-sonnet": "cl100k_base",
+	}
+	for model, want := range cases {
+		if got := NewTiktokenExtractor(model).getEncodingForModel(); got != want {
+			t.Errorf("getEncodingForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestMessageText(t *testing.T) {
+	if got := messageText([]byte(`"hello"`)); got != "hello" {
+		t.Errorf("string content = %q", got)
+	}
+	parts := `[{"type":"text","text":"What is "},{"type":"image_url","image_url":{"url":"data:,"}},{"type":"text","text":"this?"}]`
+	if got := messageText([]byte(parts)); got != "What is this?" {
+		t.Errorf("multimodal content = %q", got)
+	}
+	if got := messageText(nil); got != "" {
+		t.Errorf("missing content = %q", got)
+	}
+}
+
+func TestChatFraming(t *testing.T) {
+	if perMessage, perName := NewTiktokenExtractor("gpt-3.5-turbo-0301").chatFraming(); perMessage != 4 || perName != -1 {
+		t.Errorf("gpt-3.5-turbo-0301 framing = %d, %d", perMessage, perName)
+	}
+	if perMessage, perName := NewTiktokenExtractor("gpt-4o").chatFraming(); perMessage != 3 || perName != 1 {
+		t.Errorf("gpt-4o framing = %d, %d", perMessage, perName)
+	}
+}
@@ -49,7 +49,7 @@ func (t *UsageTracker) IsEnabled() bool {
 func (t *UsageTracker) TrackUsage(
 	orgID, apiKeyID, modelID, provider, endpoint string,
 	requestID *string, responseStatus int, responseTimeMS *int,
-	responseBody []byte,
+	responseBody []byte, extraMetadata map[string]interface{},
 ) {
 	if !t.enabled {
 		return
@@ -59,7 +59,7 @@ func (t *UsageTracker) TrackUsage(
 	go func() {
 		if err := t.processUsage(
 			orgID, apiKeyID, modelID, provider, endpoint,
-			requestID, responseStatus, responseTimeMS, responseBody,
+			requestID, responseStatus, responseTimeMS, responseBody, extraMetadata,
 		); err != nil {
 			// If standard extraction failed, check if we can use tiktoken
 			// This handles streaming responses automatically
@@ -80,7 +80,7 @@ func (t *UsageTracker) TrackUsage(
 func (t *UsageTracker) processUsage(
 	orgID, apiKeyID, modelID, provider, endpoint string,
 	requestID *string, responseStatus int, responseTimeMS *int,
-	responseBody []byte,
+	responseBody []byte, extraMetadata map[string]interface{},
 ) error {
 	// Extract usage from response
 	extractor := t.extractorFactory.GetExtractor(provider)
@@ -108,6 +108,7 @@ func (t *UsageTracker) processUsage(
 		"extraction_type": "standard",
 		"extracted_at":    time.Now().UTC().Format(time.RFC3339),
 	}
+	mergeMetadata(metadata, extraMetadata)
 
 	// Submit to worker pool
 	success := t.workerPool.SubmitUsage(
@@ -172,7 +173,7 @@ func (t *UsageTracker) TrackUsageWithData(
 func (t *UsageTracker) TrackUsageWithTiktoken(
 	orgID, apiKeyID, modelID, provider, endpoint string,
 	requestID *string, responseStatus int, responseTimeMS *int,
-	responseBody []byte, requestBody []byte,
+	responseBody []byte, requestBody []byte, extraMetadata map[string]interface{},
 ) {
 	if !t.enabled {
 		return
@@ -180,6 +181,17 @@ func (t *UsageTracker) TrackUsageWithTiktoken(
 
 	// Process in background
 	go func() {
+		// Prefer the usage chunk the provider sent when stream_options.include_usage was set
+		stream := NewStreamCollector()
+		stream.Write(responseBody)
+		if reported := stream.Usage(); reported != nil {
+			t.submitStreamUsage(
+				orgID, apiKeyID, modelID, provider, endpoint,
+				requestID, responseStatus, responseTimeMS, reported, extraMetadata,
+			)
+			return
+		}
+
 		// Use tiktoken extractor for accurate token counting
 		extractor := NewTiktokenExtractor(modelID)
 		usage, err := extractor.ExtractFromStreamingResponse(responseBody, requestBody)
@@ -188,46 +200,181 @@ func (t *UsageTracker) TrackUsageWithTiktoken(
 			// Fall back to normal processing
 			if err := t.processUsage(
 				orgID, apiKeyID, modelID, provider, endpoint,
-				requestID, responseStatus, responseTimeMS, responseBody,
+				requestID, responseStatus, responseTimeMS, responseBody, extraMetadata,
 			); err != nil {
 				log.Printf("Both tiktoken and normal extraction failed: %v", err)
 			}
 			return
 		}
 
-		// Calculate cost
-		calculator := t.calculatorFactory.GetCalculator(provider)
-		cost, err := calculator.CalculateCost(usage, modelID)
-		if err != nil {
-			log.Printf("Failed to calculate cost for provider %s, model %s: %v", provider, modelID, err)
-			cost = 0
+		t.submitTiktokenUsage(
+			orgID, apiKeyID, modelID, provider, endpoint,
+			requestID, responseStatus, responseTimeMS, usage, extraMetadata,
+		)
+	}()
+}
+
+// TrackStreamUsage tracks a streamed response's usage from its StreamCollector: the usage chunk
+// the provider reported if there was one, otherwise a tiktoken count of the completion text
+func (t *UsageTracker) TrackStreamUsage(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	stream *StreamCollector, requestBody []byte, extraMetadata map[string]interface{},
+) {
+	if !t.enabled {
+		return
+	}
+
+	reported, completion := stream.Usage(), stream.Completion()
+	go func() {
+		if reported != nil {
+			t.submitStreamUsage(
+				orgID, apiKeyID, modelID, provider, endpoint,
+				requestID, responseStatus, responseTimeMS, reported, extraMetadata,
+			)
+			return
 		}
 
-		// Prepare metadata
-		metadata := map[string]interface{}{
-			"provider":     provider,
-			"model_id":     modelID,
-			"tiktoken":     true,
-			"extracted_at": time.Now().UTC().Format(time.RFC3339),
+		usage, err := NewTiktokenExtractor(modelID).ExtractFromCompletion(completion, requestBody)
+		if err != nil {
+			log.Printf("Failed to count streamed usage for model %s: %v", modelID, err)
+			return
 		}
 
-		// Submit to worker pool
-		success := t.workerPool.SubmitUsage(
+		t.submitTiktokenUsage(
 			orgID, apiKeyID, modelID, provider, endpoint,
-			requestID, responseStatus, responseTimeMS,
-			usage, &cost, metadata,
+			requestID, responseStatus, responseTimeMS, usage, extraMetadata,
 		)
+	}()
+}
 
-		if !success {
-			log.Printf("Failed to submit tiktoken usage job to worker pool (queue full)")
+// TrackUsageWithEstimate tracks usage from the response's usage block like TrackUsage, and
+// estimates it with tiktoken when there is none, as self-hosted servers often omit it
+func (t *UsageTracker) TrackUsageWithEstimate(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	responseBody []byte, requestBody []byte, extraMetadata map[string]interface{},
+) {
+	if !t.enabled {
+		return
+	}
+
+	go func() {
+		err := t.processUsage(
+			orgID, apiKeyID, modelID, provider, endpoint,
+			requestID, responseStatus, responseTimeMS, responseBody, extraMetadata,
+		)
+		if err == nil || responseStatus >= 400 {
+			return
+		}
+
+		usage, err := NewTiktokenExtractor(modelID).ExtractFromResponse(responseBody, requestBody)
+		if err != nil {
+			log.Printf("Failed to estimate usage for model %s: %v", modelID, err)
 			return
 		}
 
-		log.Printf("Successfully tracked usage with tiktoken for org %s: %d tokens, $%.6f",
-			orgID, usage.TotalTokens, cost)
+		metadata := map[string]interface{}{"estimated": true}
+		mergeMetadata(metadata, extraMetadata)
+		t.submitTiktokenUsage(
+			orgID, apiKeyID, modelID, provider, endpoint,
+			requestID, responseStatus, responseTimeMS, usage, metadata,
+		)
 	}()
 }
 
+// TrackCachedResponse logs a request the gateway answered from its cache. No provider tokens
+// were used, so it is logged with none and no cost.
+func (t *UsageTracker) TrackCachedResponse(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	extraMetadata map[string]interface{},
+) {
+	if !t.enabled {
+		return
+	}
+
+	go t.submitCountedUsage(
+		orgID, apiKeyID, modelID, provider, endpoint,
+		requestID, responseStatus, responseTimeMS, &models.AIProviderUsage{}, extraMetadata,
+	)
+}
+
+// submitTiktokenUsage prices tokenizer-counted usage and queues it for logging
+func (t *UsageTracker) submitTiktokenUsage(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	usage *models.AIProviderUsage, extraMetadata map[string]interface{},
+) {
+	metadata := map[string]interface{}{"tiktoken": true}
+	mergeMetadata(metadata, extraMetadata)
+	t.submitCountedUsage(
+		orgID, apiKeyID, modelID, provider, endpoint,
+		requestID, responseStatus, responseTimeMS, usage, metadata,
+	)
+}
+
+// submitStreamUsage prices the usage a provider reported at the end of a stream and queues it
+// for logging
+func (t *UsageTracker) submitStreamUsage(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	usage *models.AIProviderUsage, extraMetadata map[string]interface{},
+) {
+	metadata := map[string]interface{}{"extraction_type": "stream_usage"}
+	mergeMetadata(metadata, extraMetadata)
+	t.submitCountedUsage(
+		orgID, apiKeyID, modelID, provider, endpoint,
+		requestID, responseStatus, responseTimeMS, usage, metadata,
+	)
+}
+
+// submitCountedUsage prices usage that was counted or reported outside of a response's usage
+// block and queues it for logging
+func (t *UsageTracker) submitCountedUsage(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	usage *models.AIProviderUsage, extraMetadata map[string]interface{},
+) {
+	// Calculate cost
+	calculator := t.calculatorFactory.GetCalculator(provider)
+	cost, err := calculator.CalculateCost(usage, modelID)
+	if err != nil {
+		log.Printf("Failed to calculate cost for provider %s, model %s: %v", provider, modelID, err)
+		cost = 0
+	}
+
+	// Prepare metadata
+	metadata := map[string]interface{}{
+		"provider":     provider,
+		"model_id":     modelID,
+		"extracted_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	mergeMetadata(metadata, extraMetadata)
+
+	// Submit to worker pool
+	success := t.workerPool.SubmitUsage(
+		orgID, apiKeyID, modelID, provider, endpoint,
+		requestID, responseStatus, responseTimeMS,
+		usage, &cost, metadata,
+	)
+
+	if !success {
+		log.Printf("Failed to submit counted usage job to worker pool (queue full)")
+		return
+	}
+
+	log.Printf("Successfully tracked counted usage for org %s: %d tokens, $%.6f",
+		orgID, usage.TotalTokens, cost)
+}
+
+// mergeMetadata copies request-scoped metadata (experiment tags etc.) into the usage metadata
+func mergeMetadata(metadata, extra map[string]interface{}) {
+	for k, v := range extra {
+		metadata[k] = v
+	}
+}
+
 // Stop gracefully shuts down the usage tracker
 func (t *UsageTracker) Stop() {
 	log.Println("Stopping usage tracker...")
@@ -283,12 +430,12 @@ func StopGlobalUsageTracker() {
 func TrackUsage(
 	orgID, apiKeyID, modelID, provider, endpoint string,
 	requestID *string, responseStatus int, responseTimeMS *int,
-	responseBody []byte,
+	responseBody []byte, extraMetadata map[string]interface{},
 ) {
 	if globalUsageTracker != nil {
 		globalUsageTracker.TrackUsage(
 			orgID, apiKeyID, modelID, provider, endpoint,
-			requestID, responseStatus, responseTimeMS, responseBody,
+			requestID, responseStatus, responseTimeMS, responseBody, extraMetadata,
 		)
 	}
 }
@@ -311,12 +458,61 @@ func TrackUsageWithData(
 func TrackUsageWithTiktoken(
 	orgID, apiKeyID, modelID, provider, endpoint string,
 	requestID *string, responseStatus int, responseTimeMS *int,
-	responseBody []byte, requestBody []byte,
+	responseBody []byte, requestBody []byte, extraMetadata map[string]interface{},
 ) {
 	if globalUsageTracker != nil {
 		globalUsageTracker.TrackUsageWithTiktoken(
 			orgID, apiKeyID, modelID, provider, endpoint,
-			requestID, responseStatus, responseTimeMS, responseBody, requestBody,
+			requestID, responseStatus, responseTimeMS, responseBody, requestBody, extraMetadata,
+		)
+	}
+}
+
+// TrackStreamUsage is a convenience function to track a streamed response's usage with the
+// global tracker
+func TrackStreamUsage(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	stream *StreamCollector, requestBody []byte, extraMetadata map[string]interface{},
+) {
+	if globalUsageTracker != nil {
+		globalUsageTracker.TrackStreamUsage(
+			orgID, apiKeyID, modelID, provider, endpoint,
+			requestID, responseStatus, responseTimeMS, stream, requestBody, extraMetadata,
+		)
+	}
+}
+
+// TrackUsageWithEstimate is a convenience function to track usage, estimating it when the
+// response has none, with the global tracker
+func TrackUsageWithEstimate(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	responseBody []byte, requestBody []byte, extraMetadata map[string]interface{},
+) {
+	if globalUsageTracker != nil {
+		globalUsageTracker.TrackUsageWithEstimate(
+			orgID, apiKeyID, modelID, provider, endpoint,
+			requestID, responseStatus, responseTimeMS, responseBody, requestBody, extraMetadata,
 		)
 	}
 }
+
+// TrackCachedResponse is a convenience function to log a cache hit with the global tracker
+func TrackCachedResponse(
+	orgID, apiKeyID, modelID, provider, endpoint string,
+	requestID *string, responseStatus int, responseTimeMS *int,
+	extraMetadata map[string]interface{},
+) {
+	if globalUsageTracker != nil {
+		globalUsageTracker.TrackCachedResponse(
+			orgID, apiKeyID, modelID, provider, endpoint,
+			requestID, responseStatus, responseTimeMS, extraMetadata,
+		)
+	}
+}
+
+// EstimateUsage counts a request's and response's tokens with tiktoken
+func EstimateUsage(modelID string, requestBody, responseBody []byte) (*models.AIProviderUsage, error) {
+	return NewTiktokenExtractor(modelID).ExtractFromResponse(responseBody, requestBody)
+}
@@ -3,14 +3,21 @@ package usage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/hooks"
 	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
 )
 
+// errReadOnly stops a spool replay when read-only mode is turned back on
+var errReadOnly = errors.New("read-only mode")
+
 // UsageLogJob represents a usage logging job
 type UsageLogJob struct {
 	OrganizationID string
@@ -37,6 +44,7 @@ type UsageWorkerPool struct {
 	cancel   context.CancelFunc
 	wg       sync.WaitGroup
 	config   *WorkerConfig
+	spool    *usageSpool
 }
 
 // WorkerConfig configures the worker pool behavior
@@ -48,6 +56,8 @@ type WorkerConfig struct {
 	BatchSize      int           `json:"batch_size"`
 	BatchTimeout   time.Duration `json:"batch_timeout"`
 	EnableBatching bool          `json:"enable_batching"`
+	// SpoolDir holds usage recorded while the gateway is in read-only mode
+	SpoolDir string `json:"spool_dir"`
 }
 
 // DefaultWorkerConfig returns a sensible default configuration
@@ -60,6 +70,7 @@ func DefaultWorkerConfig() *WorkerConfig {
 		BatchSize:      10,
 		BatchTimeout:   time.Second * 5,
 		EnableBatching: false, // Start with simple single inserts
+		SpoolDir:       defaultSpoolDir(),
 	}
 }
 
@@ -68,6 +79,9 @@ func NewUsageWorkerPool(database *sql.DB, config *WorkerConfig) *UsageWorkerPool
 	if config == nil {
 		config = DefaultWorkerConfig()
 	}
+	if config.SpoolDir == "" {
+		config.SpoolDir = defaultSpoolDir()
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -78,6 +92,7 @@ func NewUsageWorkerPool(database *sql.DB, config *WorkerConfig) *UsageWorkerPool
 		ctx:      ctx,
 		cancel:   cancel,
 		config:   config,
+		spool:    &usageSpool{dir: config.SpoolDir},
 	}
 
 	return pool
@@ -91,6 +106,12 @@ func (p *UsageWorkerPool) Start() {
 		p.wg.Add(1)
 		go p.worker(i)
 	}
+
+	// Record usage spooled during a read-only window, now or once the window ends
+	readonly.OnDisable(p.replaySpool)
+	if !readonly.Enabled() {
+		go p.replaySpool()
+	}
 }
 
 // Stop gracefully shuts down the worker pool
@@ -120,6 +141,11 @@ func (p *UsageWorkerPool) SubmitUsage(
 	usage *models.AIProviderUsage, cost *float64,
 	metadata map[string]interface{},
 ) bool {
+	// Live rates count the request even if the queue turns out to be full
+	if usage != nil {
+		Rates.Record(orgID, modelID, int64(usage.TotalTokens), time.Now())
+	}
+
 	job := &UsageLogJob{
 		OrganizationID: orgID,
 		APIKeyID:       apiKeyID,
@@ -167,6 +193,43 @@ func (p *UsageWorkerPool) processJob(workerID int, job *UsageLogJob) {
 		return
 	}
 
+	// Keep usage on disk until the database can be written again
+	if readonly.Enabled() {
+		if err := p.spool.Append(job); err != nil {
+			log.Printf("Worker %d: failed to spool usage during read-only mode: %v", workerID, err)
+		}
+		return
+	}
+
+	quotas, err := p.recordJob(job)
+	if err != nil {
+		log.Printf("Worker %d: failed to record usage: %v", workerID, err)
+
+		// Retry logic
+		if job.RetryCount < p.config.MaxRetries {
+			job.RetryCount++
+			log.Printf("Worker %d: retrying job (attempt %d/%d)", workerID, job.RetryCount, p.config.MaxRetries)
+
+			// Schedule retry with delay
+			go func() {
+				time.Sleep(p.config.RetryDelay * time.Duration(job.RetryCount))
+				p.SubmitJob(job)
+			}()
+		} else {
+			log.Printf("Worker %d: max retries exceeded for usage log, dropping job", workerID)
+		}
+		return
+	}
+	publishDelta(job, quotas)
+	go runUsageHooks(job)
+
+	log.Printf("Worker %d: successfully logged usage: %d tokens for org %s",
+		workerID, job.Usage.TotalTokens, job.OrganizationID)
+}
+
+// recordJob writes the usage log, counts it against the quotas and sends any threshold alerts.
+// It returns the quotas the usage was counted against.
+func (p *UsageWorkerPool) recordJob(job *UsageLogJob) ([]models.OrganizationQuota, error) {
 	// Create usage log request
 	usageReq := db.CreateUsageLogRequest{
 		OrganizationID:   job.OrganizationID,
@@ -181,36 +244,84 @@ func (p *UsageWorkerPool) processJob(workerID int, job *UsageLogJob) {
 		ResponseTimeMS:   job.ResponseTimeMS,
 		CostUSD:          job.Cost,
 		Metadata:         job.Metadata,
+		CreatedAt:        job.CreatedAt, // Spooled usage is replayed later but belongs to when it was made
 	}
 
-	// Attempt to log usage
-	if err := db.CreateUsageLog(p.db, usageReq); err != nil {
-		log.Printf("Worker %d: failed to create usage log: %v", workerID, err)
-
-		// Retry logic
-		if job.RetryCount < p.config.MaxRetries {
-			job.RetryCount++
-			log.Printf("Worker %d: retrying job (attempt %d/%d)", workerID, job.RetryCount, p.config.MaxRetries)
+	// Log usage and count it against the quotas in one transaction, so a retry can't count twice
+	quotas, err := db.RecordUsage(p.db, usageReq)
+	if err != nil {
+		return nil, err
+	}
 
-			// Schedule retry with delay
-			go func() {
-				time.Sleep(p.config.RetryDelay * time.Duration(job.RetryCount))
-				p.SubmitJob(job)
-			}()
-		} else {
-			log.Printf("Worker %d: max retries exceeded for usage log, dropping job", workerID)
+	for i := range quotas {
+		if err := email.NewService(p.db).NotifyQuotaThresholds(&quotas[i]); err != nil {
+			log.Printf("Failed to send quota notification: %v", err)
 		}
-		return
 	}
+	return quotas, nil
+}
 
-	// Update organization quota
-	if err := db.UpdateOrganizationUsage(p.db, job.OrganizationID, job.Usage.TotalTokens); err != nil {
-		log.Printf("Worker %d: failed to update organization usage: %v", workerID, err)
-		// Note: We don't retry quota updates to avoid duplicate increments
+// publishDelta pushes a recorded job to the live dashboards. Spooled usage replayed after a
+// read-only window isn't published; it would show as a burst of requests that happened earlier.
+func publishDelta(job *UsageLogJob, quotas []models.OrganizationQuota) {
+	delta := models.UsageDelta{
+		OrganizationID: job.OrganizationID,
+		APIKeyID:       job.APIKeyID,
+		ModelID:        job.ModelID,
+		ResponseStatus: job.ResponseStatus,
+		TotalTokens:    job.Usage.TotalTokens,
+		Quotas:         quotas,
+		RecordedAt:     time.Now(),
+	}
+	if job.Cost != nil {
+		delta.CostUSD = *job.Cost
 	}
+	Live.Publish(delta)
+}
 
-	log.Printf("Worker %d: successfully logged usage: %d tokens for org %s",
-		workerID, job.Usage.TotalTokens, job.OrganizationID)
+// runUsageHooks tells the deployment's usage hooks of a recorded job
+func runUsageHooks(job *UsageLogJob) {
+	u := &hooks.Usage{
+		OrganizationID:   job.OrganizationID,
+		APIKeyID:         job.APIKeyID,
+		ModelID:          job.ModelID,
+		Provider:         job.Provider,
+		Endpoint:         job.Endpoint,
+		ResponseStatus:   job.ResponseStatus,
+		PromptTokens:     job.Usage.PromptTokens,
+		CompletionTokens: job.Usage.CompletionTokens,
+		TotalTokens:      job.Usage.TotalTokens,
+		RecordedAt:       time.Now(),
+	}
+	if job.RequestID != nil {
+		u.RequestID = *job.RequestID
+	}
+	if job.Cost != nil {
+		u.CostUSD = *job.Cost
+	}
+	if job.ResponseTimeMS != nil {
+		u.ResponseTimeMS = *job.ResponseTimeMS
+	}
+	hooks.RunUsage(context.Background(), u)
+}
+
+// replaySpool records the usage spooled during read-only mode. Jobs go straight to the database
+// rather than through the queue, so a large spool can't overflow it.
+func (p *UsageWorkerPool) replaySpool() {
+	p.spool.Replay(func(job *UsageLogJob) error {
+		if job.Usage == nil {
+			return nil
+		}
+		// Read-only mode came back on mid-replay; keep the rest spooled
+		if readonly.Enabled() {
+			return errReadOnly
+		}
+		if _, err := p.recordJob(job); err != nil {
+			return err
+		}
+		go runUsageHooks(job)
+		return nil
+	})
 }
 
 // GetQueueSize returns the current number of jobs in the queue
@@ -0,0 +1,173 @@
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRepository    = "like-mike/relai-gateway"
+	defaultCheckInterval = 24 * time.Hour
+)
+
+// githubAPI is where releases are looked up; tests point it at a fake
+var githubAPI = "https://api.github.com"
+
+// Release is a published GitHub release
+type Release struct {
+	Version     string    `json:"version"`
+	URL         string    `json:"url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// UpdateStatus is the outcome of the last update check
+type UpdateStatus struct {
+	Latest          *Release  `json:"latest"`
+	UpdateAvailable bool      `json:"update_available"`
+	CheckedAt       time.Time `json:"checked_at"`
+}
+
+var (
+	statusMu sync.RWMutex
+	status   *UpdateStatus
+)
+
+// Status returns the last update check, or nil when checks are off or none has finished
+func Status() *UpdateStatus {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+	return status
+}
+
+// LatestRelease looks up the repository's latest published release
+func LatestRelease(ctx context.Context, client *http.Client, repository string) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, githubAPI+"/repos/"+repository+"/releases/latest", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub returned %s", resp.Status)
+	}
+
+	var body struct {
+		TagName     string    `json:"tag_name"`
+		HTMLURL     string    `json:"html_url"`
+		PublishedAt time.Time `json:"published_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+	if body.TagName == "" {
+		return nil, fmt.Errorf("release has no tag")
+	}
+	return &Release{Version: body.TagName, URL: body.HTMLURL, PublishedAt: body.PublishedAt}, nil
+}
+
+// Newer reports whether release is a later version than current. Both are read as
+// "v1.2.3"-style versions; a pre-release suffix is ignored. A current version that isn't one,
+// such as a development build, is never reported as outdated.
+func Newer(release, current string) bool {
+	r, ok := parseVersion(release)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range r {
+		if r[i] != c[i] {
+			return r[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion reads major, minor and patch from a version such as v1.2.3 or 1.2.3-rc.1
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// check looks up the latest release and records whether it's newer than the running build
+func check(ctx context.Context, client *http.Client, repository string) {
+	release, err := LatestRelease(ctx, client, repository)
+	if err != nil {
+		log.Printf("Failed to check for updates: %v", err)
+		return
+	}
+	current := Get().Version
+	next := &UpdateStatus{Latest: release, UpdateAvailable: Newer(release.Version, current), CheckedAt: time.Now()}
+	if next.UpdateAvailable {
+		log.Printf("A new version is available: %s (running %s)", release.Version, current)
+	}
+
+	statusMu.Lock()
+	status = next
+	statusMu.Unlock()
+}
+
+// StartUpdateCheck checks GitHub for a newer release at start and every UPDATE_CHECK_INTERVAL
+// (default 24h) when UPDATE_CHECK_ENABLED is set. UPDATE_CHECK_REPOSITORY overrides the
+// repository, e.g. for a fork. The returned func stops it.
+func StartUpdateCheck() (stop func()) {
+	switch os.Getenv("UPDATE_CHECK_ENABLED") {
+	case "1", "true":
+	default:
+		return func() {}
+	}
+	repository := os.Getenv("UPDATE_CHECK_REPOSITORY")
+	if repository == "" {
+		repository = defaultRepository
+	}
+	interval, _ := time.ParseDuration(os.Getenv("UPDATE_CHECK_INTERVAL"))
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &http.Client{Timeout: 30 * time.Second}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			check(ctx, client, repository)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return cancel
+}
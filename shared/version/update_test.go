@@ -0,0 +1,54 @@
+package version
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewer(t *testing.T) {
+	cases := []struct {
+		release, current string
+		want             bool
+	}{
+		{"v1.3.0", "v1.2.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "1.9.9", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.3", "v1.3.0", false},
+		{"v1.2.4-rc.1", "v1.2.3", true},
+		{"v1.2", "v1.2.0", false},
+		{"v1.3.0", "dev", false},
+		{"nightly", "v1.0.0", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, Newer(tc.release, tc.current), "Newer(%s, %s)", tc.release, tc.current)
+	}
+}
+
+func TestLatestRelease(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repos/acme/gateway/releases/latest", r.URL.Path)
+		w.Write([]byte(`{"tag_name": "v1.4.0", "html_url": "https://github.com/acme/gateway/releases/tag/v1.4.0", "published_at": "2026-09-01T12:00:00Z"}`))
+	}))
+	defer server.Close()
+	original := githubAPI
+	githubAPI = server.URL
+	defer func() { githubAPI = original }()
+
+	release, err := LatestRelease(context.Background(), http.DefaultClient, "acme/gateway")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.4.0", release.Version)
+	assert.Equal(t, "https://github.com/acme/gateway/releases/tag/v1.4.0", release.URL)
+	assert.Equal(t, 2026, release.PublishedAt.Year())
+}
+
+func TestGet(t *testing.T) {
+	info := Get()
+	assert.NotEmpty(t, info.Version)
+	assert.NotEmpty(t, info.GoVersion)
+}
@@ -0,0 +1,50 @@
+// Package version describes the running build and checks GitHub for newer releases
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version, Commit and BuildDate are set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/like-mike/relai-gateway/shared/version.Version=v1.2.3
+//	  -X github.com/like-mike/relai-gateway/shared/version.Commit=$(git rev-parse HEAD)
+//	  -X github.com/like-mike/relai-gateway/shared/version.BuildDate=$(date -u +%FT%TZ)"
+//
+// Any left unset fall back to what the Go toolchain stamped into the binary.
+var (
+	Version   = ""
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info is the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the running build, preferring the values set at build time
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
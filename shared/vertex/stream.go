@@ -0,0 +1,101 @@
+package vertex
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxEventBytes bounds a single server-sent event from Vertex
+const maxEventBytes = 10 << 20
+
+// NewStreamTranslator converts a streamGenerateContent?alt=sse body into a chat completion
+// chunk stream ending with [DONE]. Closing it closes upstream.
+func NewStreamTranslator(upstream io.ReadCloser, opts ResponseOptions) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer upstream.Close()
+		pw.CloseWithError(translateStream(upstream, pw, opts, time.Now()))
+	}()
+	return &streamReader{PipeReader: pr, upstream: upstream}
+}
+
+type streamReader struct {
+	*io.PipeReader
+	upstream io.Closer
+}
+
+func (s *streamReader) Close() error {
+	s.PipeReader.Close()
+	return s.upstream.Close()
+}
+
+func translateStream(upstream io.Reader, w io.Writer, opts ResponseOptions, now time.Time) error {
+	scanner := bufio.NewScanner(upstream)
+	scanner.Buffer(make([]byte, 64<<10), maxEventBytes)
+
+	id := ""
+	sentRole := map[int]bool{}
+	var lastUsage *usageMetadata
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var resp generateResponse
+		if err := json.Unmarshal([]byte(data), &resp); err != nil {
+			continue
+		}
+		if id == "" {
+			id = completionID(resp.ResponseID)
+		}
+		if resp.UsageMetadata != nil {
+			lastUsage = resp.UsageMetadata
+		}
+
+		chunk := chatResponse{ID: id, Object: "chat.completion.chunk", Created: now.Unix(), Model: opts.Model, Choices: []chatChoice{}}
+		for _, cand := range resp.Candidates {
+			delta := &chatMessageOut{Content: candidateText(cand)}
+			if !sentRole[cand.Index] {
+				delta.Role = "assistant"
+				sentRole[cand.Index] = true
+			}
+			chunk.Choices = append(chunk.Choices, chatChoice{Index: cand.Index, Delta: delta, FinishReason: finishReason(cand.FinishReason)})
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if err := writeEvent(w, chunk); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if opts.IncludeUsage && lastUsage != nil {
+		if id == "" {
+			id = completionID("")
+		}
+		usageChunk := chatResponse{ID: id, Object: "chat.completion.chunk", Created: now.Unix(), Model: opts.Model, Choices: []chatChoice{}, Usage: lastUsage.chat()}
+		if err := writeEvent(w, usageChunk); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "data: [DONE]\n\n")
+	return err
+}
+
+func writeEvent(w io.Writer, chunk chatResponse) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "data: "+string(data)+"\n\n")
+	return err
+}
@@ -0,0 +1,403 @@
+// Package vertex lets OpenAI-style chat completion clients call Gemini models on Google Vertex
+// AI. It translates requests to the publishers/google/models generateContent shape and the
+// responses, including server-sent event streams, back to chat completions.
+package vertex
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ModelName maps a gateway model ID to its Vertex publisher model, so "gemini-1.5-pro",
+// "google/gemini-1.5-pro" and "publishers/google/models/gemini-1.5-pro" all work
+func ModelName(modelID string) string {
+	name := modelID
+	for _, prefix := range []string{"publishers/google/models/", "vertex_ai/", "vertex/", "google/"} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	return name
+}
+
+// BaseURL returns the Vertex AI endpoint for a location such as "us-central1" or "global"
+func BaseURL(location string) string {
+	if location == "global" {
+		return "https://aiplatform.googleapis.com"
+	}
+	return fmt.Sprintf("https://%s-aiplatform.googleapis.com", location)
+}
+
+// ModelPath returns the generateContent (or streamGenerateContent) path of a publisher model
+func ModelPath(projectID, location, model string, stream bool) string {
+	method := "generateContent"
+	if stream {
+		method = "streamGenerateContent"
+	}
+	return fmt.Sprintf("/v1/projects/%s/locations/%s/publishers/google/models/%s:%s", projectID, location, model, method)
+}
+
+// ResponseOptions carry what the response translation needs to know about the request
+type ResponseOptions struct {
+	Model        string // Reported back as the response's model
+	Stream       bool
+	IncludeUsage bool // stream_options.include_usage
+}
+
+type chatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []chatMessage   `json:"messages"`
+	Temperature         *float64        `json:"temperature"`
+	TopP                *float64        `json:"top_p"`
+	MaxTokens           *int            `json:"max_tokens"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens"`
+	Stop                json.RawMessage `json:"stop"`
+	N                   *int            `json:"n"`
+	Seed                *int            `json:"seed"`
+	PresencePenalty     *float64        `json:"presence_penalty"`
+	FrequencyPenalty    *float64        `json:"frequency_penalty"`
+	ResponseFormat      *struct {
+		Type string `json:"type"`
+	} `json:"response_format"`
+	Tools         json.RawMessage `json:"tools"`
+	Stream        bool            `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type chatContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+type generateRequest struct {
+	Contents          []content         `json:"contents"`
+	SystemInstruction *content          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *generationConfig `json:"generationConfig,omitempty"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text       string    `json:"text,omitempty"`
+	Thought    bool      `json:"thought,omitempty"`
+	InlineData *blob     `json:"inlineData,omitempty"`
+	FileData   *fileData `json:"fileData,omitempty"`
+}
+
+type blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type fileData struct {
+	MimeType string `json:"mimeType"`
+	FileURI  string `json:"fileUri"`
+}
+
+type generationConfig struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"topP,omitempty"`
+	MaxOutputTokens  *int     `json:"maxOutputTokens,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	CandidateCount   *int     `json:"candidateCount,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	PresencePenalty  *float64 `json:"presencePenalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequencyPenalty,omitempty"`
+	ResponseMimeType string   `json:"responseMimeType,omitempty"`
+}
+
+// TranslateRequest converts an OpenAI chat completion request body into a Vertex
+// generateContent body
+func TranslateRequest(body []byte) ([]byte, ResponseOptions, error) {
+	var req chatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, ResponseOptions{}, fmt.Errorf("invalid JSON body: %w", err)
+	}
+	if len(req.Messages) == 0 {
+		return nil, ResponseOptions{}, fmt.Errorf("messages is required")
+	}
+	if len(req.Tools) > 0 && string(req.Tools) != "null" {
+		return nil, ResponseOptions{}, fmt.Errorf("tools are not supported for Vertex AI models")
+	}
+
+	out := generateRequest{}
+	for i, msg := range req.Messages {
+		parts, err := translateContent(msg.Content)
+		if err != nil {
+			return nil, ResponseOptions{}, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+
+		switch msg.Role {
+		case "system", "developer":
+			if out.SystemInstruction == nil {
+				out.SystemInstruction = &content{}
+			}
+			out.SystemInstruction.Parts = append(out.SystemInstruction.Parts, parts...)
+		case "user":
+			out.Contents = append(out.Contents, content{Role: "user", Parts: parts})
+		case "assistant":
+			out.Contents = append(out.Contents, content{Role: "model", Parts: parts})
+		default:
+			return nil, ResponseOptions{}, fmt.Errorf("messages[%d]: role %q is not supported for Vertex AI models", i, msg.Role)
+		}
+	}
+	if len(out.Contents) == 0 {
+		return nil, ResponseOptions{}, fmt.Errorf("messages must include at least one user message")
+	}
+
+	stop, err := stopSequences(req.Stop)
+	if err != nil {
+		return nil, ResponseOptions{}, err
+	}
+
+	cfg := generationConfig{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		MaxOutputTokens:  req.MaxTokens,
+		StopSequences:    stop,
+		CandidateCount:   req.N,
+		Seed:             req.Seed,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+	if req.MaxCompletionTokens != nil {
+		cfg.MaxOutputTokens = req.MaxCompletionTokens
+	}
+	if req.ResponseFormat != nil && (req.ResponseFormat.Type == "json_object" || req.ResponseFormat.Type == "json_schema") {
+		cfg.ResponseMimeType = "application/json"
+	}
+	out.GenerationConfig = &cfg
+
+	converted, err := json.Marshal(out)
+	if err != nil {
+		return nil, ResponseOptions{}, err
+	}
+	opts := ResponseOptions{Model: req.Model, Stream: req.Stream}
+	if req.StreamOptions != nil {
+		opts.IncludeUsage = req.StreamOptions.IncludeUsage
+	}
+	return converted, opts, nil
+}
+
+// translateContent accepts a plain string or an array of text and image_url parts
+func translateContent(raw json.RawMessage) ([]part, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []part{{Text: text}}, nil
+	}
+
+	var chatParts []chatContentPart
+	if err := json.Unmarshal(raw, &chatParts); err != nil {
+		return nil, fmt.Errorf("content must be a string or an array of parts")
+	}
+
+	parts := make([]part, 0, len(chatParts))
+	for _, p := range chatParts {
+		switch p.Type {
+		case "text":
+			parts = append(parts, part{Text: p.Text})
+		case "image_url":
+			if p.ImageURL == nil || p.ImageURL.URL == "" {
+				return nil, fmt.Errorf("image_url part has no url")
+			}
+			parts = append(parts, imagePart(p.ImageURL.URL))
+		default:
+			return nil, fmt.Errorf("content part type %q is not supported for Vertex AI models", p.Type)
+		}
+	}
+	return parts, nil
+}
+
+// imagePart inlines data: URLs and passes other URLs (gs:// or https://) by reference
+func imagePart(url string) part {
+	if strings.HasPrefix(url, "data:") {
+		meta, data, _ := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		return part{InlineData: &blob{MimeType: strings.TrimSuffix(meta, ";base64"), Data: data}}
+	}
+
+	mimeType := "image/jpeg"
+	lower := strings.ToLower(url)
+	switch {
+	case strings.HasSuffix(lower, ".png"):
+		mimeType = "image/png"
+	case strings.HasSuffix(lower, ".webp"):
+		mimeType = "image/webp"
+	case strings.HasSuffix(lower, ".gif"):
+		mimeType = "image/gif"
+	}
+	return part{FileData: &fileData{MimeType: mimeType, FileURI: url}}
+}
+
+func stopSequences(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("stop must be a string or an array of strings")
+	}
+	return list, nil
+}
+
+type generateResponse struct {
+	Candidates    []candidate    `json:"candidates"`
+	UsageMetadata *usageMetadata `json:"usageMetadata"`
+	ResponseID    string         `json:"responseId"`
+}
+
+type candidate struct {
+	Index        int     `json:"index"`
+	Content      content `json:"content"`
+	FinishReason string  `json:"finishReason"`
+}
+
+type usageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *usageMetadata) chat() *chatUsage {
+	if u == nil {
+		return nil
+	}
+	return &chatUsage{PromptTokens: u.PromptTokenCount, CompletionTokens: u.CandidatesTokenCount, TotalTokens: u.TotalTokenCount}
+}
+
+type chatMessageOut struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+type chatChoice struct {
+	Index        int             `json:"index"`
+	Message      *chatMessageOut `json:"message,omitempty"`
+	Delta        *chatMessageOut `json:"delta,omitempty"`
+	FinishReason *string         `json:"finish_reason"`
+}
+
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+// TranslateResponse converts a generateContent response body into a chat completion
+func TranslateResponse(body []byte, opts ResponseOptions, now time.Time) ([]byte, error) {
+	var resp generateResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid Vertex AI response: %w", err)
+	}
+
+	out := chatResponse{
+		ID:      completionID(resp.ResponseID),
+		Object:  "chat.completion",
+		Created: now.Unix(),
+		Model:   opts.Model,
+		Choices: []chatChoice{},
+		Usage:   resp.UsageMetadata.chat(),
+	}
+	for _, cand := range resp.Candidates {
+		out.Choices = append(out.Choices, chatChoice{
+			Index:        cand.Index,
+			Message:      &chatMessageOut{Role: "assistant", Content: candidateText(cand)},
+			FinishReason: finishReason(cand.FinishReason),
+		})
+	}
+	return json.Marshal(out)
+}
+
+// TranslateError converts a Vertex error body, a single object or the array form used by
+// streaming calls, into the OpenAI error shape
+func TranslateError(body []byte) []byte {
+	type googleError struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+
+	var single googleError
+	if err := json.Unmarshal(body, &single); err != nil || single.Error.Message == "" {
+		var list []googleError
+		if err := json.Unmarshal(body, &list); err == nil && len(list) > 0 {
+			single = list[0]
+		}
+	}
+	if single.Error.Message == "" {
+		return ErrorBody(strings.TrimSpace(string(body)), "provider_error")
+	}
+	return ErrorBody(single.Error.Message, strings.ToLower(single.Error.Status))
+}
+
+// ErrorBody builds an OpenAI-style error body
+func ErrorBody(message, errorType string) []byte {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": errorType},
+	})
+	return body
+}
+
+func candidateText(cand candidate) string {
+	var b strings.Builder
+	for _, p := range cand.Content.Parts {
+		if !p.Thought {
+			b.WriteString(p.Text)
+		}
+	}
+	return b.String()
+}
+
+// finishReason maps Vertex finish reasons to OpenAI's; nil means the candidate isn't finished
+func finishReason(reason string) *string {
+	var mapped string
+	switch reason {
+	case "":
+		return nil
+	case "MAX_TOKENS":
+		mapped = "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		mapped = "content_filter"
+	default:
+		mapped = "stop"
+	}
+	return &mapped
+}
+
+func completionID(responseID string) string {
+	if responseID != "" {
+		return "chatcmpl-" + responseID
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
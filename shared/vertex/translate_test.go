@@ -0,0 +1,141 @@
+package vertex
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModelName(t *testing.T) {
+	for _, id := range []string{"gemini-1.5-pro", "google/gemini-1.5-pro", "publishers/google/models/gemini-1.5-pro", "vertex_ai/gemini-1.5-pro"} {
+		if got := ModelName(id); got != "gemini-1.5-pro" {
+			t.Errorf("ModelName(%q) = %q", id, got)
+		}
+	}
+}
+
+func TestTranslateRequest(t *testing.T) {
+	body := `{"model":"gemini-1.5-pro","stream":true,"stream_options":{"include_usage":true},
+		"messages":[
+			{"role":"system","content":"Be brief."},
+			{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBOR"}}]},
+			{"role":"assistant","content":"A logo."},
+			{"role":"user","content":"Whose?"}],
+		"temperature":0.2,"max_tokens":100,"stop":"END","response_format":{"type":"json_object"}}`
+
+	converted, opts, err := TranslateRequest([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts != (ResponseOptions{Model: "gemini-1.5-pro", Stream: true, IncludeUsage: true}) {
+		t.Errorf("opts = %+v", opts)
+	}
+
+	var got generateRequest
+	if err := json.Unmarshal(converted, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.SystemInstruction == nil || got.SystemInstruction.Parts[0].Text != "Be brief." {
+		t.Errorf("system instruction = %+v", got.SystemInstruction)
+	}
+	if len(got.Contents) != 3 || got.Contents[1].Role != "model" {
+		t.Fatalf("contents = %+v", got.Contents)
+	}
+	if img := got.Contents[0].Parts[1].InlineData; img == nil || img.MimeType != "image/png" || img.Data != "iVBOR" {
+		t.Errorf("inline image = %+v", img)
+	}
+	cfg := got.GenerationConfig
+	if *cfg.Temperature != 0.2 || *cfg.MaxOutputTokens != 100 || cfg.StopSequences[0] != "END" || cfg.ResponseMimeType != "application/json" {
+		t.Errorf("generation config = %+v", cfg)
+	}
+}
+
+func TestTranslateRequestRejectsTools(t *testing.T) {
+	if _, _, err := TranslateRequest([]byte(`{"model":"m","messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function"}]}`)); err == nil {
+		t.Error("expected tools to be rejected")
+	}
+	if _, _, err := TranslateRequest([]byte(`{"model":"m","messages":[{"role":"tool","content":"42"}]}`)); err == nil {
+		t.Error("expected tool messages to be rejected")
+	}
+}
+
+func TestTranslateResponse(t *testing.T) {
+	body := `{"candidates":[{"index":0,"content":{"role":"model","parts":[{"text":"thinking","thought":true},{"text":"Hello"}]},"finishReason":"MAX_TOKENS"}],
+		"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":7,"totalTokenCount":12},"responseId":"abc"}`
+
+	out, err := TranslateResponse([]byte(body), ResponseOptions{Model: "gemini-1.5-pro"}, time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got chatResponse
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != "chatcmpl-abc" || got.Object != "chat.completion" || got.Model != "gemini-1.5-pro" || got.Created != 1700000000 {
+		t.Errorf("response = %+v", got)
+	}
+	if got.Choices[0].Message.Content != "Hello" || *got.Choices[0].FinishReason != "length" {
+		t.Errorf("choice = %+v", got.Choices[0])
+	}
+	if got.Usage == nil || got.Usage.PromptTokens != 5 || got.Usage.CompletionTokens != 7 || got.Usage.TotalTokens != 12 {
+		t.Errorf("usage = %+v", got.Usage)
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	got := string(TranslateError([]byte(`[{"error":{"code":400,"message":"bad request","status":"INVALID_ARGUMENT"}}]`)))
+	if got != `{"error":{"message":"bad request","type":"invalid_argument"}}` {
+		t.Errorf("TranslateError = %s", got)
+	}
+}
+
+func TestTransportStream(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/p/locations/us-central1/publishers/google/models/gemini-1.5-pro:streamGenerateContent" || r.URL.RawQuery != "alt=sse" {
+			t.Errorf("unexpected upstream URL %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Hel\"}]}}],\"responseId\":\"r1\"}\r\n\r\n")
+		io.WriteString(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"lo\"}]},\"finishReason\":\"STOP\"}],\"usageMetadata\":{\"promptTokenCount\":3,\"candidatesTokenCount\":2,\"totalTokenCount\":5}}\r\n\r\n")
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: &Transport{ProjectID: "p", Location: "us-central1", Model: "gemini-1.5-pro"}}
+	body := `{"model":"gemini-1.5-pro","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`
+	resp, err := client.Post(upstream.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(resp.Body)
+
+	events := strings.Split(strings.TrimSpace(string(out)), "\n\n")
+	if len(events) != 4 || events[3] != "data: [DONE]" {
+		t.Fatalf("events = %q", events)
+	}
+	var first, last chatResponse
+	json.Unmarshal([]byte(strings.TrimPrefix(events[0], "data: ")), &first)
+	json.Unmarshal([]byte(strings.TrimPrefix(events[2], "data: ")), &last)
+	if first.ID != "chatcmpl-r1" || first.Choices[0].Delta.Role != "assistant" || first.Choices[0].Delta.Content != "Hel" {
+		t.Errorf("first chunk = %s", events[0])
+	}
+	if last.Usage == nil || last.Usage.TotalTokens != 5 || len(last.Choices) != 0 {
+		t.Errorf("usage chunk = %s", events[2])
+	}
+}
+
+func TestTransportRejectsOtherEndpoints(t *testing.T) {
+	client := &http.Client{Transport: &Transport{ProjectID: "p", Location: "us-central1", Model: "m"}}
+	resp, err := client.Post("http://vertex.invalid/v1/embeddings", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
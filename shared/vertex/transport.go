@@ -0,0 +1,117 @@
+package vertex
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transport turns chat completion requests into generateContent calls on a Vertex publisher
+// model and translates the responses back, so callers see an OpenAI-compatible upstream. Only
+// the request's scheme and host are kept; Base must add authentication.
+type Transport struct {
+	Base      http.RoundTripper
+	ProjectID string
+	Location  string
+	Model     string // Vertex model name, see ModelName
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/chat/completions") {
+		return errorResponse(req, http.StatusNotFound, "Vertex AI models only support /v1/chat/completions", "invalid_request_error"), nil
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	converted, opts, err := TranslateRequest(body)
+	if err != nil {
+		return errorResponse(req, http.StatusBadRequest, err.Error(), "invalid_request_error"), nil
+	}
+
+	target := *req.URL
+	target.Path = ModelPath(t.ProjectID, t.Location, t.Model, opts.Stream)
+	target.RawPath = ""
+	target.RawQuery = ""
+	if opts.Stream {
+		target.RawQuery = "alt=sse"
+	}
+
+	// A fresh request, so client headers such as Accept-Encoding don't leak upstream
+	out, err := http.NewRequestWithContext(req.Context(), http.MethodPost, target.String(), bytes.NewReader(converted))
+	if err != nil {
+		return nil, err
+	}
+	out.Header.Set("Content-Type", "application/json")
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		setBody(resp, TranslateError(raw), "application/json")
+		return resp, nil
+	}
+
+	if opts.Stream {
+		resp.Body = NewStreamTranslator(resp.Body, opts)
+		resp.Header.Set("Content-Type", "text/event-stream")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		return resp, nil
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	translated, err := TranslateResponse(raw, opts, time.Now())
+	if err != nil {
+		resp.StatusCode = http.StatusBadGateway
+		resp.Status = "502 " + http.StatusText(http.StatusBadGateway)
+		translated = ErrorBody(err.Error(), "provider_error")
+	}
+	setBody(resp, translated, "application/json")
+	return resp, nil
+}
+
+func setBody(resp *http.Response, body []byte, contentType string) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Type", contentType)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// errorResponse answers a request the gateway can't translate without calling Vertex
+func errorResponse(req *http.Request, status int, message, errorType string) *http.Response {
+	resp := &http.Response{
+		Status:     strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	setBody(resp, ErrorBody(message, errorType), "application/json")
+	return resp
+}
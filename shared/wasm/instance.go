@@ -0,0 +1,137 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// DefaultFuel is how many function calls a call may make when Limits leaves it unset
+const DefaultFuel = 1_000_000
+
+// ErrFuelExhausted is returned when a call runs out of its function call budget
+var ErrFuelExhausted = errors.New("wasm: function call budget exhausted")
+
+// Limits bound a module's instances. Zero values take the defaults.
+type Limits struct {
+	// Fuel is how many function calls, the first included, each call may make; DefaultFuel when
+	// zero. Loops within a function aren't metered, so calls also need a context deadline.
+	Fuel int64
+	// MaxMemoryPages caps each instance's memory, in 64 KiB pages, below the module's own maximum
+	MaxMemoryPages uint32
+}
+
+// HostFunc is a function the embedder provides for a module to import
+type HostFunc struct {
+	Type FuncType
+	Call func(ctx context.Context, inst *Instance, args []uint64) ([]uint64, error)
+}
+
+// goFunction adapts the host function to wazero, which turns the panic of a failed call into
+// the error of the module's
+func (fn HostFunc) goFunction() api.GoModuleFunction {
+	return api.GoModuleFunc(func(ctx context.Context, caller api.Module, stack []uint64) {
+		results, err := fn.Call(ctx, &Instance{module: caller}, append([]uint64(nil), stack[:len(fn.Type.Params)]...))
+		if err != nil {
+			panic(err)
+		}
+		if len(results) != len(fn.Type.Results) {
+			panic(fmt.Errorf("host function returned %d results, want %d", len(results), len(fn.Type.Results)))
+		}
+		copy(stack, results)
+	})
+}
+
+// Instance is a module with its own memory, globals and table. It is not safe for concurrent
+// use; instantiate once per caller instead, and close it when done.
+type Instance struct {
+	module api.Module
+	limits Limits
+}
+
+// Instantiate creates an instance of the module and runs its start function
+func Instantiate(ctx context.Context, m *Module) (*Instance, error) {
+	// Anonymous, so any number of instances can run at once; no WASI _start either
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions()
+	mod, err := m.runtime.InstantiateModule(withMeter(ctx, m.limits.Fuel), m.compiled, config)
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+	return &Instance{module: mod, limits: m.limits}, nil
+}
+
+// Call runs an exported function with a fresh budget. Once ctx is done the call stops and the
+// instance is closed.
+func (inst *Instance) Call(ctx context.Context, name string, args ...uint64) ([]uint64, error) {
+	fn := inst.module.ExportedFunction(name)
+	if fn == nil {
+		return nil, fmt.Errorf("unknown export %q", name)
+	}
+	results, err := fn.Call(withMeter(ctx, inst.limits.Fuel), args...)
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+	return results, nil
+}
+
+// callError reports a call stopped by its context as the context's error
+func callError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	return err
+}
+
+// Close releases the instance
+func (inst *Instance) Close(ctx context.Context) error {
+	return inst.module.Close(ctx)
+}
+
+// ReadMemory copies length bytes of the instance's memory from offset, reporting false when
+// they're out of bounds
+func (inst *Instance) ReadMemory(offset, length uint32) ([]byte, bool) {
+	mem := inst.module.Memory()
+	if mem == nil {
+		return nil, false
+	}
+	data, ok := mem.Read(offset, length)
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), data...), true
+}
+
+// WriteMemory copies data into the instance's memory at offset, reporting false when it doesn't
+// fit
+func (inst *Instance) WriteMemory(offset uint32, data []byte) bool {
+	mem := inst.module.Memory()
+	return mem != nil && mem.Write(offset, data)
+}
+
+// meter counts down a call's fuel as the module calls its functions
+type meter struct {
+	fuel int64
+}
+
+type meterKey struct{}
+
+func withMeter(ctx context.Context, fuel int64) context.Context {
+	return context.WithValue(ctx, meterKey{}, &meter{fuel: fuel})
+}
+
+// meterFactory charges every call of a module's function to the meter of the call it runs in
+var meterFactory = experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener {
+	return experimental.FunctionListenerFunc(func(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+		m, ok := ctx.Value(meterKey{}).(*meter)
+		if !ok {
+			return
+		}
+		if m.fuel--; m.fuel < 0 {
+			panic(ErrFuelExhausted)
+		}
+	})
+})
@@ -0,0 +1,120 @@
+// Package wasm runs sandboxed WebAssembly policy modules on wazero, a pure Go runtime, using its
+// interpreter so a replaced module's code is garbage collected like any other value. Every call
+// runs under a budget of function calls, a memory cap and its context, whose cancellation stops
+// even a module spinning in a loop, and any fault traps the call rather than the gateway.
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// ValueType is a WebAssembly value type
+type ValueType = api.ValueType
+
+const (
+	I32 = api.ValueTypeI32
+	I64 = api.ValueTypeI64
+	F32 = api.ValueTypeF32
+	F64 = api.ValueTypeF64
+)
+
+// PageSize is the size of a WebAssembly memory page
+const PageSize = 65536
+
+// FuncType is a function signature
+type FuncType struct {
+	Params  []ValueType
+	Results []ValueType
+}
+
+// Equal reports whether two signatures match
+func (t FuncType) Equal(o FuncType) bool {
+	return string(t.Params) == string(o.Params) && string(t.Results) == string(o.Results)
+}
+
+// Module is a compiled module with its own runtime, ready to be instantiated any number of times
+type Module struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	limits   Limits
+}
+
+// Compile decodes and validates a binary module, resolving its imports from host, keyed
+// "module.name", when it is instantiated. Imports missing from host fail instantiation.
+func Compile(ctx context.Context, binary []byte, host map[string]HostFunc, limits Limits) (*Module, error) {
+	if limits.Fuel <= 0 {
+		limits.Fuel = DefaultFuel
+	}
+	config := wazero.NewRuntimeConfigInterpreter().WithCloseOnContextDone(true)
+	if limits.MaxMemoryPages > 0 {
+		config = config.WithMemoryLimitPages(limits.MaxMemoryPages)
+	}
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+
+	if err := instantiateHost(ctx, runtime, host); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	compiled, err := runtime.CompileModule(experimental.WithFunctionListenerFactory(ctx, meterFactory), binary)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("invalid module: %w", err)
+	}
+	return &Module{runtime: runtime, compiled: compiled, limits: limits}, nil
+}
+
+// instantiateHost registers the host functions in the runtime, one host module per import module
+func instantiateHost(ctx context.Context, runtime wazero.Runtime, host map[string]HostFunc) error {
+	builders := map[string]wazero.HostModuleBuilder{}
+	for key, fn := range host {
+		moduleName, name, ok := strings.Cut(key, ".")
+		if !ok {
+			return fmt.Errorf("host function %q must be named module.name", key)
+		}
+		builder, ok := builders[moduleName]
+		if !ok {
+			builder = runtime.NewHostModuleBuilder(moduleName)
+			builders[moduleName] = builder
+		}
+		builder.NewFunctionBuilder().
+			WithGoModuleFunction(fn.goFunction(), fn.Type.Params, fn.Type.Results).
+			Export(name)
+	}
+	for moduleName, builder := range builders {
+		if _, err := builder.Instantiate(ctx); err != nil {
+			return fmt.Errorf("host module %s: %w", moduleName, err)
+		}
+	}
+	return nil
+}
+
+// ExportedFunction returns the signature of an exported function
+func (m *Module) ExportedFunction(name string) (FuncType, bool) {
+	def, ok := m.compiled.ExportedFunctions()[name]
+	if !ok {
+		return FuncType{}, false
+	}
+	return FuncType{Params: def.ParamTypes(), Results: def.ResultTypes()}, true
+}
+
+// ExportsMemory reports whether the module exports its memory under name
+func (m *Module) ExportsMemory(name string) bool {
+	_, ok := m.compiled.ExportedMemories()[name]
+	return ok
+}
+
+// Imports lists the functions the module imports, as "module.name"
+func (m *Module) Imports() []string {
+	var names []string
+	for _, def := range m.compiled.ImportedFunctions() {
+		moduleName, name, _ := def.Import()
+		names = append(names, moduleName+"."+name)
+	}
+	return names
+}
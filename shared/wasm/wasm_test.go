@@ -0,0 +1,275 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A few helpers to assemble modules by hand
+
+func uleb(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		if v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+func cat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func vec(items ...[]byte) []byte {
+	return cat(uleb(uint64(len(items))), cat(items...))
+}
+
+func str(s string) []byte {
+	return cat(uleb(uint64(len(s))), []byte(s))
+}
+
+func section(id byte, payload []byte) []byte {
+	return cat([]byte{id}, uleb(uint64(len(payload))), payload)
+}
+
+func functype(params, results []ValueType) []byte {
+	p := make([][]byte, len(params))
+	for i, t := range params {
+		p[i] = []byte{byte(t)}
+	}
+	r := make([][]byte, len(results))
+	for i, t := range results {
+		r[i] = []byte{byte(t)}
+	}
+	return cat([]byte{0x60}, vec(p...), vec(r...))
+}
+
+func exportFunc(name string, index byte) []byte {
+	return cat(str(name), []byte{0x00, index})
+}
+
+// body wraps code, which must end with 0x0B, with its local declarations
+func body(locals []byte, code ...byte) []byte {
+	b := cat(locals, code)
+	return cat(uleb(uint64(len(b))), b)
+}
+
+func module(sections ...[]byte) []byte {
+	return cat([]byte("\x00asm\x01\x00\x00\x00"), cat(sections...))
+}
+
+var (
+	noLocals = []byte{0x00}
+	i32      = []ValueType{I32}
+	i64      = []ValueType{I64}
+)
+
+func instantiate(t *testing.T, binary []byte, host map[string]HostFunc, limits Limits) *Instance {
+	t.Helper()
+	m, err := Compile(context.Background(), binary, host, limits)
+	require.NoError(t, err)
+	inst, err := Instantiate(context.Background(), m)
+	require.NoError(t, err)
+	t.Cleanup(func() { inst.Close(context.Background()) })
+	return inst
+}
+
+// loopModule's sum(n) adds up 1 to n, calling add for each
+func loopModule() []byte {
+	return module(
+		section(1, vec(functype(i32, i32), functype([]ValueType{I32, I32}, i32))),
+		section(3, vec([]byte{0}, []byte{1})),
+		section(7, vec(exportFunc("sum", 0))),
+		section(10, vec(
+			body([]byte{0x01, 0x01, byte(I32)},
+				0x02, 0x40, 0x03, 0x40, // block, loop
+				0x20, 0x00, 0x45, 0x0D, 0x01, // exit when n == 0
+				0x20, 0x01, 0x20, 0x00, 0x10, 0x01, 0x21, 0x01, // acc = add(acc, n)
+				0x20, 0x00, 0x41, 0x01, 0x6B, 0x21, 0x00, // n--
+				0x0C, 0x00, 0x0B, 0x0B, // br 0
+				0x20, 0x01, 0x0B,
+			),
+			body(noLocals, 0x20, 0x00, 0x20, 0x01, 0x6A, 0x0B),
+		)),
+	)
+}
+
+func TestLoop(t *testing.T) {
+	inst := instantiate(t, loopModule(), nil, Limits{})
+	results, err := inst.Call(context.Background(), "sum", 100)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{5050}, results)
+}
+
+func TestFuelLimit(t *testing.T) {
+	inst := instantiate(t, loopModule(), nil, Limits{Fuel: 100})
+
+	_, err := inst.Call(context.Background(), "sum", 1000)
+	assert.ErrorIs(t, err, ErrFuelExhausted)
+
+	// Each call gets a fresh budget
+	results, err := inst.Call(context.Background(), "sum", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{55}, results)
+}
+
+func TestContextStopsLoops(t *testing.T) {
+	// Spins without calling anything, so only the deadline stops it
+	inst := instantiate(t, module(
+		section(1, vec(functype(nil, nil))),
+		section(3, vec([]byte{0})),
+		section(7, vec(exportFunc("spin", 0))),
+		section(10, vec(body(noLocals, 0x03, 0x40, 0x0C, 0x00, 0x0B, 0x0B))),
+	), nil, Limits{Fuel: math.MaxInt64})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := inst.Call(ctx, "spin")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func memoryModule() []byte {
+	return module(
+		section(1, vec(functype(i32, i32), functype([]ValueType{I32, I32}, nil))),
+		section(3, vec([]byte{0}, []byte{0}, []byte{1})),
+		section(5, vec([]byte{0x01, 0x01, 0x02})), // 1 page, at most 2
+		section(7, vec(exportFunc("load8", 0), exportFunc("grow", 1), exportFunc("store", 2), cat(str("memory"), []byte{0x02, 0}))),
+		section(10, vec(
+			body(noLocals, 0x20, 0x00, 0x2D, 0x00, 0x00, 0x0B),
+			body(noLocals, 0x20, 0x00, 0x40, 0x00, 0x0B),
+			body(noLocals, 0x20, 0x00, 0x20, 0x01, 0x36, 0x02, 0x00, 0x0B),
+		)),
+		section(11, vec(cat([]byte{0x00, 0x41, 0x10, 0x0B}, str("hi")))),
+	)
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m, err := Compile(ctx, memoryModule(), nil, Limits{})
+	require.NoError(t, err)
+	assert.True(t, m.ExportsMemory("memory"))
+
+	inst, err := Instantiate(ctx, m)
+	require.NoError(t, err)
+
+	results, err := inst.Call(ctx, "load8", 16)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{'h'}, results)
+
+	_, err = inst.Call(ctx, "store", 32, 0x64636261)
+	require.NoError(t, err)
+	data, ok := inst.ReadMemory(32, 4)
+	require.True(t, ok)
+	assert.Equal(t, "abcd", string(data))
+	assert.True(t, inst.WriteMemory(36, []byte("ef")))
+	_, ok = inst.ReadMemory(PageSize-1, 2)
+	assert.False(t, ok)
+	assert.False(t, inst.WriteMemory(PageSize-1, []byte("gh")))
+
+	_, err = inst.Call(ctx, "load8", PageSize)
+	assert.ErrorContains(t, err, "out of bounds")
+
+	results, err = inst.Call(ctx, "grow", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1}, results)
+	results, err = inst.Call(ctx, "grow", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0xFFFFFFFF}, results, "growing past the module's maximum fails")
+
+	// The embedder's cap applies below the module's own
+	capped := instantiate(t, memoryModule(), nil, Limits{MaxMemoryPages: 1})
+	results, err = capped.Call(ctx, "grow", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0xFFFFFFFF}, results)
+}
+
+func TestHostImports(t *testing.T) {
+	ctx := context.Background()
+	binary := module(
+		section(1, vec(functype(i32, i32))),
+		section(2, vec(cat(str("env"), str("double"), []byte{0x00, 0}))),
+		section(3, vec([]byte{0})),
+		section(7, vec(exportFunc("run", 1))),
+		section(10, vec(body(noLocals, 0x20, 0x00, 0x10, 0x00, 0x41, 0x01, 0x6A, 0x0B))),
+	)
+	m, err := Compile(ctx, binary, nil, Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env.double"}, m.Imports())
+
+	_, err = Instantiate(ctx, m)
+	assert.Error(t, err, "unresolved imports fail instantiation")
+
+	host := map[string]HostFunc{"env.double": {
+		Type: FuncType{Params: i32, Results: i32},
+		Call: func(_ context.Context, _ *Instance, args []uint64) ([]uint64, error) {
+			return []uint64{args[0] * 2}, nil
+		},
+	}}
+	inst := instantiate(t, binary, host, Limits{})
+	results, err := inst.Call(ctx, "run", 20)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{41}, results)
+
+	failing := errors.New("host failure")
+	host["env.double"] = HostFunc{
+		Type: FuncType{Params: i32, Results: i32},
+		Call: func(context.Context, *Instance, []uint64) ([]uint64, error) { return nil, failing },
+	}
+	inst = instantiate(t, binary, host, Limits{})
+	_, err = inst.Call(ctx, "run", 1)
+	assert.ErrorIs(t, err, failing)
+}
+
+func TestTraps(t *testing.T) {
+	inst := instantiate(t, module(
+		section(1, vec(functype([]ValueType{I32, I32}, i32), functype(nil, nil))),
+		section(3, vec([]byte{0}, []byte{1})),
+		section(7, vec(exportFunc("div", 0), exportFunc("crash", 1))),
+		section(10, vec(
+			body(noLocals, 0x20, 0x00, 0x20, 0x01, 0x6D, 0x0B),
+			body(noLocals, 0x00, 0x0B),
+		)),
+	), nil, Limits{})
+	ctx := context.Background()
+
+	_, err := inst.Call(ctx, "div", 1, 0)
+	assert.ErrorContains(t, err, "divide by zero")
+	_, err = inst.Call(ctx, "crash")
+	assert.ErrorContains(t, err, "unreachable")
+	_, err = inst.Call(ctx, "missing")
+	assert.Error(t, err)
+}
+
+func TestCompileRejectsMalformedModules(t *testing.T) {
+	valid := loopModule()
+	cases := map[string][]byte{
+		"empty":           nil,
+		"wrong magic":     []byte("\x00elf\x01\x00\x00\x00"),
+		"truncated":       valid[:len(valid)-3],
+		"unknown opcode":  module(section(1, vec(functype(nil, nil))), section(3, vec([]byte{0})), section(10, vec(body(noLocals, 0xFF, 0x0B)))),
+		"unbalanced end":  module(section(1, vec(functype(nil, nil))), section(3, vec([]byte{0})), section(10, vec(body(noLocals, 0x0B, 0x0B)))),
+		"missing body":    module(section(1, vec(functype(nil, nil))), section(3, vec([]byte{0}))),
+		"bad local":       module(section(1, vec(functype(nil, nil))), section(3, vec([]byte{0})), section(10, vec(body(noLocals, 0x20, 0x05, 0x1A, 0x0B)))),
+		"stack underflow": module(section(1, vec(functype(nil, nil))), section(3, vec([]byte{0})), section(10, vec(body(noLocals, 0x1A, 0x0B)))),
+	}
+	for name, binary := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Compile(context.Background(), binary, nil, Limits{})
+			assert.Error(t, err)
+		})
+	}
+}
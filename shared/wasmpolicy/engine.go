@@ -0,0 +1,217 @@
+package wasmpolicy
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/hooks"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/wasm"
+)
+
+const (
+	defaultMemoryMB        = 16
+	defaultTimeout         = 250 * time.Millisecond
+	defaultRefreshInterval = 30 * time.Second
+)
+
+// Config bounds every policy the gateway runs. A policy may ask for less, never more.
+type Config struct {
+	Enabled         bool
+	Fuel            int64         // Function calls per call
+	MaxMemoryPages  uint32        // 64 KiB pages
+	Timeout         time.Duration // Wall clock per call
+	RefreshInterval time.Duration // How often changed policies are reloaded
+}
+
+// ConfigFromEnv reads WASM_POLICIES_ENABLED, WASM_POLICY_FUEL (default 1M function calls),
+// WASM_POLICY_MAX_MEMORY_MB (default 16), WASM_POLICY_TIMEOUT (default 250ms) and
+// WASM_POLICY_REFRESH_INTERVAL (default 30s)
+func ConfigFromEnv() Config {
+	cfg := Config{Fuel: wasm.DefaultFuel, MaxMemoryPages: defaultMemoryMB * 16}
+	switch os.Getenv("WASM_POLICIES_ENABLED") {
+	case "1", "true":
+		cfg.Enabled = true
+	}
+	if fuel, err := strconv.ParseInt(os.Getenv("WASM_POLICY_FUEL"), 10, 64); err == nil && fuel > 0 {
+		cfg.Fuel = fuel
+	}
+	if mb, err := strconv.Atoi(os.Getenv("WASM_POLICY_MAX_MEMORY_MB")); err == nil && mb > 0 {
+		cfg.MaxMemoryPages = uint32(mb) * 16
+		if cfg.MaxMemoryPages > models.MaxWasmPolicyMemoryPages {
+			cfg.MaxMemoryPages = models.MaxWasmPolicyMemoryPages
+		}
+	}
+	cfg.Timeout, _ = time.ParseDuration(os.Getenv("WASM_POLICY_TIMEOUT"))
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	cfg.RefreshInterval, _ = time.ParseDuration(os.Getenv("WASM_POLICY_REFRESH_INTERVAL"))
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultRefreshInterval
+	}
+	return cfg
+}
+
+// limitsFor caps a policy's own limits at the deployment's
+func (c Config) limitsFor(p models.WasmPolicy) wasm.Limits {
+	limits := wasm.Limits{Fuel: c.Fuel, MaxMemoryPages: c.MaxMemoryPages}
+	if p.FuelLimit != nil && *p.FuelLimit < limits.Fuel {
+		limits.Fuel = *p.FuelLimit
+	}
+	if p.MaxMemoryPages != nil && uint32(*p.MaxMemoryPages) < limits.MaxMemoryPages {
+		limits.MaxMemoryPages = uint32(*p.MaxMemoryPages)
+	}
+	return limits
+}
+
+// loaded is a compiled policy and the version it was compiled from
+type loaded struct {
+	id        string
+	updatedAt time.Time
+	policy    *Policy
+}
+
+// Engine is the hook that runs each organization's active policies, in upload order. It only
+// sees policies as of its last refresh, so changes reach the gateway within a refresh interval.
+type Engine struct {
+	cfg Config
+
+	mu    sync.RWMutex
+	byOrg map[string][]loaded
+}
+
+// NewEngine returns an engine with no policies loaded
+func NewEngine(cfg Config) *Engine {
+	return &Engine{cfg: cfg, byOrg: map[string][]loaded{}}
+}
+
+// Name identifies the engine among the gateway's hooks
+func (e *Engine) Name() string {
+	return "wasm-policies"
+}
+
+func (e *Engine) policies(orgID string) []loaded {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.byOrg[orgID]
+}
+
+// OnRequest runs the organization's policies on a request, stopping at the first rejection
+func (e *Engine) OnRequest(ctx context.Context, req *hooks.Request) error {
+	for _, l := range e.policies(req.OrganizationID) {
+		callCtx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+		err := l.policy.OnRequest(callCtx, req)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnResponse runs the organization's policies on a response, stopping at the first rejection
+func (e *Engine) OnResponse(ctx context.Context, resp *hooks.Response) error {
+	if resp.Request == nil {
+		return nil
+	}
+	for _, l := range e.policies(resp.Request.OrganizationID) {
+		callCtx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+		err := l.policy.OnResponse(callCtx, resp)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Refresh reloads the active policies from the database
+func (e *Engine) Refresh(conn *sql.DB) error {
+	active, err := db.GetActiveWasmPolicies(conn)
+	if err != nil {
+		return err
+	}
+	e.load(active, func(id string) ([]byte, error) { return db.GetWasmPolicyModule(conn, id) })
+	return nil
+}
+
+// load swaps in the given active policies, compiling only those that are new or changed since
+// the last load. A policy that no longer loads keeps running its previous version, if any.
+func (e *Engine) load(active []models.WasmPolicy, fetch func(id string) ([]byte, error)) {
+	previous := map[string]loaded{}
+	e.mu.RLock()
+	for _, policies := range e.byOrg {
+		for _, l := range policies {
+			previous[l.id] = l
+		}
+	}
+	e.mu.RUnlock()
+
+	byOrg := map[string][]loaded{}
+	for _, p := range active {
+		if l, ok := previous[p.ID]; ok && l.updatedAt.Equal(p.UpdatedAt) {
+			byOrg[p.OrganizationID] = append(byOrg[p.OrganizationID], l)
+			continue
+		}
+
+		binary, err := fetch(p.ID)
+		var policy *Policy
+		if err == nil {
+			policy, err = Compile(p.Name, binary, e.cfg.limitsFor(p))
+		}
+		if err != nil {
+			log.Printf("Failed to load WASM policy %s (%s): %v", p.Name, p.ID, err)
+			if l, ok := previous[p.ID]; ok {
+				byOrg[p.OrganizationID] = append(byOrg[p.OrganizationID], l)
+			}
+			continue
+		}
+		log.Printf("Loaded WASM policy %s (%s) for organization %s", p.Name, p.SHA256[:min(12, len(p.SHA256))], p.OrganizationID)
+		byOrg[p.OrganizationID] = append(byOrg[p.OrganizationID], loaded{id: p.ID, updatedAt: p.UpdatedAt, policy: policy})
+	}
+
+	e.mu.Lock()
+	e.byOrg = byOrg
+	e.mu.Unlock()
+}
+
+// Start registers the policy engine as a hook when WASM_POLICIES_ENABLED is set, and reloads
+// changed policies every refresh interval. The returned func stops the reloads.
+func Start(conn *sql.DB) (stop func()) {
+	cfg := ConfigFromEnv()
+	if !cfg.Enabled {
+		return func() {}
+	}
+
+	engine := NewEngine(cfg)
+	if err := engine.Refresh(conn); err != nil {
+		log.Printf("Failed to load WASM policies: %v", err)
+	}
+	hooks.Register(engine)
+	log.Printf("Running WASM policies (%d function calls, %d MiB, %s per call; reloaded every %s)",
+		cfg.Fuel, cfg.MaxMemoryPages/16, cfg.Timeout, cfg.RefreshInterval)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(cfg.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := engine.Refresh(conn); err != nil {
+					log.Printf("Failed to reload WASM policies: %v", err)
+				}
+			}
+		}
+	}()
+	return cancel
+}
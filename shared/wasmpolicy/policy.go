@@ -0,0 +1,266 @@
+// Package wasmpolicy runs organizations' uploaded WebAssembly policy modules on their requests
+// and responses, sandboxed by package wasm.
+//
+// A module exports its memory as "memory", an "alloc(size i32) i32" the gateway uses to pass it
+// input, and "on_request(ptr i32, len i32) i64" and/or "on_response(ptr i32, len i32) i64". Each
+// is handed a JSON document describing the request or response, and returns 0 to let it through
+// unchanged, or the location of a JSON decision packed as ptr<<32 | len:
+//
+//	{"action": "reject", "status": 403, "message": "..."}
+//	{"headers": {"X-Policy": "checked"}, "body": "...", "status_code": 200}
+//
+// The only import a module may use is "env.log(ptr i32, len i32)", which writes to the gateway log.
+package wasmpolicy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/like-mike/relai-gateway/shared/hooks"
+	"github.com/like-mike/relai-gateway/shared/wasm"
+)
+
+const (
+	exportMemory     = "memory"
+	exportAlloc      = "alloc"
+	exportOnRequest  = "on_request"
+	exportOnResponse = "on_response"
+
+	// maxLogMessage truncates what a module writes to the log
+	maxLogMessage = 1024
+)
+
+var (
+	allocType   = wasm.FuncType{Params: []wasm.ValueType{wasm.I32}, Results: []wasm.ValueType{wasm.I32}}
+	handlerType = wasm.FuncType{Params: []wasm.ValueType{wasm.I32, wasm.I32}, Results: []wasm.ValueType{wasm.I64}}
+	logType     = wasm.FuncType{Params: []wasm.ValueType{wasm.I32, wasm.I32}}
+)
+
+// Policy is a compiled module ready to run
+type Policy struct {
+	Name       string
+	module     *wasm.Module
+	onRequest  bool
+	onResponse bool
+}
+
+// Compile checks a module against the policy ABI and prepares it to run within limits
+func Compile(name string, binary []byte, limits wasm.Limits) (*Policy, error) {
+	m, err := wasm.Compile(context.Background(), binary, logImport(name), limits)
+	if err != nil {
+		return nil, err
+	}
+	if !m.ExportsMemory(exportMemory) {
+		return nil, errors.New(`module must export its memory as "memory"`)
+	}
+	if t, ok := m.ExportedFunction(exportAlloc); !ok || !t.Equal(allocType) {
+		return nil, errors.New(`module must export "alloc(i32) -> i32"`)
+	}
+
+	p := &Policy{Name: name, module: m}
+	for _, handler := range []struct {
+		name string
+		set  *bool
+	}{{exportOnRequest, &p.onRequest}, {exportOnResponse, &p.onResponse}} {
+		t, ok := m.ExportedFunction(handler.name)
+		if !ok {
+			continue
+		}
+		if !t.Equal(handlerType) {
+			return nil, fmt.Errorf(`%q must be "(i32, i32) -> i64"`, handler.name)
+		}
+		*handler.set = true
+	}
+	if !p.onRequest && !p.onResponse {
+		return nil, errors.New(`module must export "on_request" or "on_response"`)
+	}
+
+	for _, imp := range m.Imports() {
+		if imp != "env.log" {
+			return nil, fmt.Errorf("module imports %s; only env.log is available", imp)
+		}
+	}
+	return p, nil
+}
+
+// HandlesRequests reports whether the module exports on_request
+func (p *Policy) HandlesRequests() bool {
+	return p.onRequest
+}
+
+// HandlesResponses reports whether the module exports on_response
+func (p *Policy) HandlesResponses() bool {
+	return p.onResponse
+}
+
+// logImport is env.log, writing what the module named name logs to the gateway log
+func logImport(name string) map[string]wasm.HostFunc {
+	return map[string]wasm.HostFunc{"env.log": {
+		Type: logType,
+		Call: func(_ context.Context, inst *wasm.Instance, args []uint64) ([]uint64, error) {
+			length := uint32(args[1])
+			if length > maxLogMessage {
+				length = maxLogMessage
+			}
+			if msg, ok := inst.ReadMemory(uint32(args[0]), length); ok {
+				log.Printf("WASM policy %s: %s", name, strings.ToValidUTF8(string(msg), "?"))
+			}
+			return nil, nil
+		},
+	}}
+}
+
+// decision is a module's answer, the same shape as a hook sidecar's
+type decision struct {
+	Action     string            `json:"action"` // "allow" (the default) or "reject"
+	Status     int               `json:"status"`
+	Message    string            `json:"message"`
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	Body       *string           `json:"body"`
+}
+
+// call runs one of the module's handlers on input in a fresh instance, so nothing a module keeps
+// in memory outlives the call
+func (p *Policy) call(ctx context.Context, handler string, input interface{}) (*decision, error) {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	inst, err := wasm.Instantiate(ctx, p.module)
+	if err != nil {
+		return nil, err
+	}
+	defer inst.Close(ctx)
+
+	results, err := inst.Call(ctx, exportAlloc, uint64(len(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("alloc: %w", err)
+	}
+	ptr := uint32(results[0])
+	if !inst.WriteMemory(ptr, raw) {
+		return nil, errors.New("alloc returned memory out of bounds")
+	}
+
+	results, err = inst.Call(ctx, handler, uint64(ptr), uint64(len(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", handler, err)
+	}
+	if results[0] == 0 {
+		return &decision{}, nil
+	}
+	out, ok := inst.ReadMemory(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return nil, fmt.Errorf("%s returned a decision out of bounds", handler)
+	}
+	var d decision
+	if err := json.Unmarshal(out, &d); err != nil {
+		return nil, fmt.Errorf("%s returned an invalid decision: %w", handler, err)
+	}
+	return &d, nil
+}
+
+// requestInput is what on_request sees, and on_response as well under "request"
+type requestInput struct {
+	RequestID      string            `json:"request_id"`
+	OrganizationID string            `json:"organization_id"`
+	APIKeyID       string            `json:"api_key_id"`
+	Model          string            `json:"model"`
+	Method         string            `json:"method"`
+	Path           string            `json:"path"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body"`
+}
+
+type responseInput struct {
+	Request    requestInput      `json:"request"`
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	Streaming  bool              `json:"streaming"`
+}
+
+func newRequestInput(req *hooks.Request) requestInput {
+	if req == nil {
+		return requestInput{}
+	}
+	return requestInput{
+		RequestID:      req.RequestID,
+		OrganizationID: req.OrganizationID,
+		APIKeyID:       req.APIKeyID,
+		Model:          req.Model,
+		Method:         req.Method,
+		Path:           req.Path,
+		Headers:        flatten(req.Header),
+		Body:           string(req.Body),
+	}
+}
+
+// flatten keeps the first value of each header
+func flatten(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for name, values := range header {
+		if len(values) > 0 {
+			flat[name] = values[0]
+		}
+	}
+	return flat
+}
+
+// OnRequest runs the module's on_request and applies its decision to req
+func (p *Policy) OnRequest(ctx context.Context, req *hooks.Request) error {
+	if !p.onRequest {
+		return nil
+	}
+	d, err := p.call(ctx, exportOnRequest, newRequestInput(req))
+	if err != nil {
+		return fmt.Errorf("WASM policy %s: %w", p.Name, err)
+	}
+	if d.Action == "reject" {
+		return hooks.Reject(d.Status, d.Message)
+	}
+	for name, value := range d.Headers {
+		req.Header.Set(name, value)
+	}
+	if d.Body != nil {
+		req.Body = []byte(*d.Body)
+	}
+	return nil
+}
+
+// OnResponse runs the module's on_response and applies its decision to resp. Streamed
+// responses have no body to see or replace.
+func (p *Policy) OnResponse(ctx context.Context, resp *hooks.Response) error {
+	if !p.onResponse {
+		return nil
+	}
+	d, err := p.call(ctx, exportOnResponse, responseInput{
+		Request:    newRequestInput(resp.Request),
+		StatusCode: resp.StatusCode,
+		Headers:    flatten(resp.Header),
+		Body:       string(resp.Body),
+		Streaming:  resp.Streaming,
+	})
+	if err != nil {
+		return fmt.Errorf("WASM policy %s: %w", p.Name, err)
+	}
+	if d.Action == "reject" {
+		return hooks.Reject(d.Status, d.Message)
+	}
+	if d.StatusCode != 0 {
+		resp.StatusCode = d.StatusCode
+	}
+	for name, value := range d.Headers {
+		resp.Header.Set(name, value)
+	}
+	if d.Body != nil && !resp.Streaming {
+		resp.Body = []byte(*d.Body)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package wasmpolicy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/like-mike/relai-gateway/shared/hooks"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/wasm"
+)
+
+// Helpers to assemble policy modules by hand
+
+func leb(v int64, signed bool) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7F)
+		v >>= 7
+		done := v == 0 && (!signed || b&0x40 == 0)
+		if signed && v == -1 && b&0x40 != 0 {
+			done = true
+		}
+		if done {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+func cat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func vec(items ...[]byte) []byte {
+	return cat(leb(int64(len(items)), false), cat(items...))
+}
+
+func str(s string) []byte {
+	return cat(leb(int64(len(s)), false), []byte(s))
+}
+
+func section(id byte, payload []byte) []byte {
+	return cat([]byte{id}, leb(int64(len(payload)), false), payload)
+}
+
+func body(code ...byte) []byte {
+	b := cat([]byte{0x00}, code)
+	return cat(leb(int64(len(b)), false), b)
+}
+
+// returnDecision is a handler body answering with the decision stored at offset
+func returnDecision(offset int, decision string) []byte {
+	if decision == "" {
+		return body(0x42, 0x00, 0x0B)
+	}
+	return body(cat([]byte{0x42}, leb(int64(offset)<<32|int64(len(decision)), true), []byte{0x0B})...)
+}
+
+const (
+	requestDecisionAt  = 0
+	responseDecisionAt = 512
+)
+
+// policyModule exports memory, an alloc that always hands out offset 1024, and handlers that
+// answer with fixed decisions. With logged set, on_request first logs it.
+func policyModule(onRequest, onResponse, logged string) []byte {
+	types := vec(
+		cat([]byte{0x60}, vec([]byte{0x7F}), vec([]byte{0x7F})),               // alloc
+		cat([]byte{0x60}, vec([]byte{0x7F}, []byte{0x7F}), vec([]byte{0x7E})), // handlers
+		cat([]byte{0x60}, vec([]byte{0x7F}, []byte{0x7F}), vec()),             // env.log
+	)
+	var imports []byte
+	first := byte(0)
+	requestBody := returnDecision(requestDecisionAt, onRequest)
+	if logged != "" {
+		imports = section(2, vec(cat(str("env"), str("log"), []byte{0x00, 0x02})))
+		first = 1
+		requestBody = body(cat(
+			[]byte{0x41}, leb(2048, true), []byte{0x41}, leb(int64(len(logged)), true), []byte{0x10, 0x00},
+			[]byte{0x42}, leb(int64(requestDecisionAt)<<32|int64(len(onRequest)), true), []byte{0x0B},
+		)...)
+	}
+
+	return cat(
+		[]byte("\x00asm\x01\x00\x00\x00"),
+		section(1, types),
+		imports,
+		section(3, vec([]byte{0}, []byte{1}, []byte{1})),
+		section(5, vec([]byte{0x00, 0x01})),
+		section(7, vec(
+			cat(str("memory"), []byte{0x02, 0x00}),
+			cat(str("alloc"), []byte{0x00, first}),
+			cat(str("on_request"), []byte{0x00, first + 1}),
+			cat(str("on_response"), []byte{0x00, first + 2}),
+		)),
+		section(10, vec(
+			body(0x41, 0x80, 0x08, 0x0B), // i32.const 1024
+			requestBody,
+			returnDecision(responseDecisionAt, onResponse),
+		)),
+		section(11, vec(
+			cat([]byte{0x00, 0x41}, leb(requestDecisionAt, true), []byte{0x0B}, str(onRequest)),
+			cat([]byte{0x00, 0x41}, leb(responseDecisionAt, true), []byte{0x0B}, str(onResponse)),
+			cat([]byte{0x00, 0x41}, leb(2048, true), []byte{0x0B}, str(logged)),
+		)),
+	)
+}
+
+func compile(t *testing.T, binary []byte) *Policy {
+	t.Helper()
+	p, err := Compile("test", binary, wasm.Limits{Fuel: 10000, MaxMemoryPages: 4})
+	require.NoError(t, err)
+	return p
+}
+
+func TestPolicyDecisions(t *testing.T) {
+	p := compile(t, policyModule(
+		`{"headers": {"X-Policy": "checked"}, "body": "{\"model\": \"small\"}"}`,
+		`{"status_code": 299, "headers": {"X-Watermark": "1"}, "body": "rewritten"}`,
+		"inspecting request",
+	))
+	assert.True(t, p.HandlesRequests())
+	assert.True(t, p.HandlesResponses())
+	ctx := context.Background()
+
+	req := &hooks.Request{OrganizationID: "org", Header: http.Header{}, Body: []byte(`{"model": "large"}`)}
+	require.NoError(t, p.OnRequest(ctx, req))
+	assert.Equal(t, "checked", req.Header.Get("X-Policy"))
+	assert.Equal(t, `{"model": "small"}`, string(req.Body))
+
+	resp := &hooks.Response{Request: req, StatusCode: 200, Header: http.Header{}, Body: []byte("original")}
+	require.NoError(t, p.OnResponse(ctx, resp))
+	assert.Equal(t, 299, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("X-Watermark"))
+	assert.Equal(t, "rewritten", string(resp.Body))
+
+	streamed := &hooks.Response{Request: req, StatusCode: 200, Header: http.Header{}, Streaming: true}
+	require.NoError(t, p.OnResponse(ctx, streamed))
+	assert.Nil(t, streamed.Body, "streamed bodies can't be replaced")
+}
+
+func TestPolicyRejectsAndAllows(t *testing.T) {
+	p := compile(t, policyModule(`{"action": "reject", "status": 402, "message": "over budget"}`, "", ""))
+	ctx := context.Background()
+
+	err := p.OnRequest(ctx, &hooks.Request{Header: http.Header{}})
+	var rejection *hooks.Rejection
+	require.ErrorAs(t, err, &rejection)
+	assert.Equal(t, 402, rejection.Status)
+	assert.Equal(t, "over budget", rejection.Message)
+
+	resp := &hooks.Response{Request: &hooks.Request{}, StatusCode: 200, Header: http.Header{}, Body: []byte("ok")}
+	require.NoError(t, p.OnResponse(ctx, resp))
+	assert.Equal(t, "ok", string(resp.Body), "0 lets the response through unchanged")
+}
+
+func TestPolicyLimits(t *testing.T) {
+	// on_request spins forever, which its deadline stops
+	binary := policyModule("", "", "")
+	loop := body(0x03, 0x40, 0x0C, 0x00, 0x0B, 0x42, 0x00, 0x0B)
+	old := returnDecision(requestDecisionAt, "")
+	p := compile(t, replaceOnce(t, binary, old, loop))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := p.OnRequest(ctx, &hooks.Request{Header: http.Header{}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// on_request calls itself forever, which runs out of fuel
+	recurse := body(0x20, 0x00, 0x20, 0x01, 0x10, 0x01, 0x0B)
+	p, err = Compile("test", replaceOnce(t, policyModule("", "", ""), old, recurse), wasm.Limits{Fuel: 100})
+	require.NoError(t, err)
+	err = p.OnRequest(context.Background(), &hooks.Request{Header: http.Header{}})
+	assert.ErrorIs(t, err, wasm.ErrFuelExhausted)
+
+	// Input bigger than the memory the policy may use can't be written
+	p = compile(t, policyModule("", "", ""))
+	err = p.OnRequest(context.Background(), &hooks.Request{Header: http.Header{}, Body: make([]byte, 2*wasm.PageSize)})
+	assert.Error(t, err)
+}
+
+func replaceOnce(t *testing.T, b, old, replacement []byte) []byte {
+	t.Helper()
+	for i := 0; i+len(old) <= len(b); i++ {
+		if string(b[i:i+len(old)]) == string(old) {
+			out := cat(b[:i], replacement, b[i+len(old):])
+			// Fix up the code section's size, which precedes its vector
+			return fixCodeSection(t, out, len(replacement)-len(old))
+		}
+	}
+	t.Fatal("pattern not found")
+	return nil
+}
+
+// fixCodeSection adjusts the code section's size by delta; sizes here fit in one LEB byte
+func fixCodeSection(t *testing.T, b []byte, delta int) []byte {
+	t.Helper()
+	pos := 8
+	for pos < len(b) {
+		id, size := b[pos], int(b[pos+1])
+		require.Less(t, size, 0x80)
+		if id == 10 {
+			b[pos+1] = byte(size + delta)
+			return b
+		}
+		pos += 2 + size
+	}
+	t.Fatal("no code section")
+	return nil
+}
+
+func TestCompileChecksABI(t *testing.T) {
+	_, err := Compile("garbage", []byte("not wasm"), wasm.Limits{})
+	assert.Error(t, err)
+
+	noAlloc := cat(
+		[]byte("\x00asm\x01\x00\x00\x00"),
+		section(1, vec(cat([]byte{0x60}, vec([]byte{0x7F}, []byte{0x7F}), vec([]byte{0x7E})))),
+		section(3, vec([]byte{0})),
+		section(5, vec([]byte{0x00, 0x01})),
+		section(7, vec(cat(str("memory"), []byte{0x02, 0x00}), cat(str("on_request"), []byte{0x00, 0x00}))),
+		section(10, vec(body(0x42, 0x00, 0x0B))),
+	)
+	_, err = Compile("no alloc", noAlloc, wasm.Limits{})
+	assert.ErrorContains(t, err, "alloc")
+
+	// Any import but env.log is refused
+	withImport := policyModule("", "", "hello")
+	withImport = replaceBytes(withImport, []byte("\x03log"), []byte("\x03net"))
+	_, err = Compile("imports", withImport, wasm.Limits{})
+	assert.ErrorContains(t, err, "env.net")
+}
+
+func replaceBytes(b, old, replacement []byte) []byte {
+	for i := 0; i+len(old) <= len(b); i++ {
+		if string(b[i:i+len(old)]) == string(old) {
+			return cat(b[:i], replacement, b[i+len(old):])
+		}
+	}
+	return b
+}
+
+func TestEngineHotReload(t *testing.T) {
+	engine := NewEngine(Config{Fuel: 10000, MaxMemoryPages: 4, Timeout: time.Second})
+	modules := map[string][]byte{
+		"p1": policyModule(`{"headers": {"X-Version": "1"}}`, "", ""),
+		"p2": policyModule(`{"action": "reject", "status": 403, "message": "org b blocked"}`, "", ""),
+	}
+	fetches := 0
+	fetch := func(id string) ([]byte, error) {
+		fetches++
+		if m, ok := modules[id]; ok {
+			return m, nil
+		}
+		return nil, errors.New("not found")
+	}
+	v1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	active := []models.WasmPolicy{
+		{ID: "p1", OrganizationID: "a", Name: "version", UpdatedAt: v1},
+		{ID: "p2", OrganizationID: "b", Name: "block", UpdatedAt: v1},
+	}
+	engine.load(active, fetch)
+	ctx := context.Background()
+
+	req := &hooks.Request{OrganizationID: "a", Header: http.Header{}}
+	require.NoError(t, engine.OnRequest(ctx, req))
+	assert.Equal(t, "1", req.Header.Get("X-Version"))
+	assert.Error(t, engine.OnRequest(ctx, &hooks.Request{OrganizationID: "b", Header: http.Header{}}))
+	require.NoError(t, engine.OnRequest(ctx, &hooks.Request{OrganizationID: "c", Header: http.Header{}}), "no policies, no changes")
+
+	// Unchanged policies aren't fetched again
+	engine.load(active, fetch)
+	assert.Equal(t, 2, fetches)
+
+	// A new version replaces the running one
+	modules["p1"] = policyModule(`{"headers": {"X-Version": "2"}}`, "", "")
+	active[0].UpdatedAt = v1.Add(time.Minute)
+	engine.load(active, fetch)
+	req = &hooks.Request{OrganizationID: "a", Header: http.Header{}}
+	require.NoError(t, engine.OnRequest(ctx, req))
+	assert.Equal(t, "2", req.Header.Get("X-Version"))
+
+	// A version that won't compile leaves the previous one running
+	modules["p1"] = []byte("broken")
+	active[0].UpdatedAt = v1.Add(2 * time.Minute)
+	engine.load(active, fetch)
+	req = &hooks.Request{OrganizationID: "a", Header: http.Header{}}
+	require.NoError(t, engine.OnRequest(ctx, req))
+	assert.Equal(t, "2", req.Header.Get("X-Version"))
+
+	// Deactivated policies stop running
+	engine.load(active[:1], fetch)
+	require.NoError(t, engine.OnRequest(ctx, &hooks.Request{OrganizationID: "b", Header: http.Header{}}))
+}
+
+func TestLimitsFor(t *testing.T) {
+	cfg := Config{Fuel: 1000, MaxMemoryPages: 64}
+	fuel, pages := int64(500), 128
+	limits := cfg.limitsFor(models.WasmPolicy{FuelLimit: &fuel, MaxMemoryPages: &pages})
+	assert.Equal(t, int64(500), limits.Fuel, "a policy may ask for less")
+	assert.Equal(t, uint32(64), limits.MaxMemoryPages, "but never more than the deployment allows")
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+	"github.com/like-mike/relai-gateway/shared/graph"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+const (
+	// azureSessionCookie holds the ID of the user's server-side Azure session
+	azureSessionCookie = "azure_session"
+
+	// azureScope is requested at sign-in and on refresh; offline_access brings a refresh token
+	azureScope = "openid email profile offline_access"
+
+	// sessionMaxAge is how long a session lasts when it can't be refreshed, in seconds
+	sessionMaxAge = 3600
+
+	defaultAzureSessionMaxHours = 12
+)
+
+// azureSessionMaxAge returns how long an Azure session is extended before the user must sign
+// in again, from AZURE_SESSION_MAX_HOURS
+func azureSessionMaxAge() time.Duration {
+	hours, err := strconv.Atoi(os.Getenv("AZURE_SESSION_MAX_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = defaultAzureSessionMaxHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func contextDB(c *gin.Context) (*sql.DB, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		return nil, false
+	}
+	sqlDB, ok := database.(*sql.DB)
+	return sqlDB, ok
+}
+
+// startAzureSession keeps the sign-in's refresh token server-side so the session can outlive
+// its access token, and returns how long the session cookies should last. Without a refresh
+// token or ENCRYPTION_KEY the session ends with its first access token, as an hour-long one.
+func startAzureSession(c *gin.Context, email, oid, refreshToken string, expiresIn int) int {
+	sqlDB, ok := contextDB(c)
+	if !ok || refreshToken == "" || readonly.Enabled() {
+		return sessionMaxAge
+	}
+
+	sealed, err := encryption.Encrypt(refreshToken)
+	if err != nil {
+		log.Printf("Not keeping Azure refresh token for %s, sessions can't be extended: %v", email, err)
+		return sessionMaxAge
+	}
+
+	session := models.AzureSession{
+		AzureOID:     oid,
+		Email:        email,
+		RefreshToken: sealed,
+		ExpiresAt:    time.Now().Add(time.Duration(expiresIn) * time.Second),
+	}
+	if err := db.CreateAzureSession(sqlDB, &session); err != nil {
+		log.Printf("Failed to store Azure session for %s: %v", email, err)
+		return sessionMaxAge
+	}
+
+	// Sessions nobody signed out of are removed once they can no longer be extended
+	if _, err := db.DeleteAzureSessionsBefore(sqlDB, time.Now().Add(-azureSessionMaxAge())); err != nil {
+		log.Printf("Failed to prune Azure sessions: %v", err)
+	}
+
+	maxAge := int(azureSessionMaxAge().Seconds())
+	setSessionCookie(c, azureSessionCookie, session.ID, maxAge)
+	return maxAge
+}
+
+// checkAzureSession refreshes the request's Azure session shortly before its access token
+// expires, re-syncing the user's groups with it. It reports false, having sent the user back
+// to sign in, when the session has ended or Azure AD refuses to refresh it.
+func checkAzureSession(c *gin.Context) bool {
+	sessionID, err := c.Cookie(azureSessionCookie)
+	if err != nil || sessionID == "" || readonly.Enabled() {
+		return true
+	}
+	sqlDB, ok := contextDB(c)
+	if !ok {
+		return true
+	}
+
+	session, err := db.GetAzureSession(sqlDB, sessionID)
+	if err == sql.ErrNoRows {
+		forceReauth(c, sqlDB, "")
+		return false
+	} else if err != nil {
+		// Don't sign everyone out over a database hiccup
+		log.Printf("Failed to load Azure session: %v", err)
+		return true
+	}
+
+	now := time.Now()
+	if session.PastMaxAge(now, azureSessionMaxAge()) {
+		forceReauth(c, sqlDB, session.ID)
+		return false
+	}
+	if !session.NeedsRefresh(now) {
+		return true
+	}
+
+	claimed, err := db.ClaimAzureSessionRefresh(sqlDB, session.ID)
+	if err != nil || !claimed {
+		// Another request is refreshing it; the current token is still good for a few minutes
+		return true
+	}
+
+	if err := refreshAzureSession(sqlDB, session); err != nil {
+		if errors.Is(err, graph.ErrRefreshRejected) || session.Expired(now) {
+			log.Printf("Azure session for %s ended, signing in again: %v", session.Email, err)
+			forceReauth(c, sqlDB, session.ID)
+			return false
+		}
+		log.Printf("Failed to refresh Azure session for %s, retrying: %v", session.Email, err)
+	}
+	return true
+}
+
+// refreshAzureSession redeems the session's refresh token, stores the rotated one and syncs
+// the user's organization memberships from their current AD groups
+func refreshAzureSession(sqlDB *sql.DB, session *models.AzureSession) error {
+	refreshToken, err := encryption.Decrypt(session.RefreshToken)
+	if err != nil {
+		// Sealed with a key that is gone; nothing can extend the session
+		return graph.ErrRefreshRejected
+	}
+
+	tokens, err := GraphClient().RefreshUserTokens(refreshToken, azureScope)
+	if err != nil {
+		return err
+	}
+
+	sealed := session.RefreshToken
+	if tokens.RefreshToken != "" {
+		if sealed, err = encryption.Encrypt(tokens.RefreshToken); err != nil {
+			return err
+		}
+	}
+	if err := db.UpdateAzureSessionTokens(sqlDB, session.ID, sealed, tokens.ExpiresAt); err != nil {
+		return err
+	}
+
+	syncAzureGroups(sqlDB, session)
+	return nil
+}
+
+// syncAzureGroups brings a refreshed session's organization memberships in line with the user's
+// AD groups. A failed sync leaves the memberships as they were.
+func syncAzureGroups(sqlDB *sql.DB, session *models.AzureSession) {
+	user, err := db.GetUserByAzureOID(sqlDB, session.AzureOID)
+	if err != nil {
+		return
+	}
+
+	groups, err := GraphClient().UserGroups(session.AzureOID)
+	if err == nil {
+		err = db.SyncUserOrganizationMemberships(sqlDB, user.ID, groups)
+	}
+	if err != nil {
+		log.Printf("Failed to sync AD groups for %s on session refresh: %v", session.Email, err)
+		notify.Record(sqlDB, "", models.NotificationEventSyncFailure, notify.Message{
+			Title:    "AD group sync failed",
+			Text:     "A signed-in user's organization memberships couldn't be refreshed from their AD groups; their previous memberships stay in place.",
+			Severity: notify.SeverityWarning,
+			Fields:   []notify.Field{{Name: "User", Value: session.Email}, {Name: "Error", Value: err.Error()}},
+		})
+	}
+}
+
+// endAzureSession removes the request's Azure session, if it has one
+func endAzureSession(c *gin.Context, sqlDB *sql.DB) {
+	if sessionID, err := c.Cookie(azureSessionCookie); err == nil && sessionID != "" && sqlDB != nil {
+		if err := db.DeleteAzureSession(sqlDB, sessionID); err != nil {
+			log.Printf("Failed to delete Azure session: %v", err)
+		}
+	}
+	setSessionCookie(c, azureSessionCookie, "", -1)
+}
+
+// forceReauth ends the session and sends the user to sign in again, which goes straight to
+// Azure AD when it's enabled
+func forceReauth(c *gin.Context, sqlDB *sql.DB, sessionID string) {
+	if sessionID != "" {
+		if err := db.DeleteAzureSession(sqlDB, sessionID); err != nil {
+			log.Printf("Failed to delete Azure session: %v", err)
+		}
+	}
+	clearSessionCookies(c)
+	c.Redirect(http.StatusFound, "/login")
+	c.Abort()
+}
+
+// clearSessionCookies removes every cookie of the user's session
+func clearSessionCookies(c *gin.Context) {
+	for _, cookie := range []string{"session", "email", "name", "oid", azureSessionCookie, breakGlassCookie} {
+		setSessionCookie(c, cookie, "", -1)
+	}
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// breakGlassCookie holds the break-glass session's token, checked against the hash stored
+// with the account on every request
+const breakGlassCookie = "break_glass"
+
+// breakGlassCredentials provision the break-glass account. The password is a bcrypt hash from
+// BREAK_GLASS_PASSWORD_HASH, or a one-time password in the file named by
+// BREAK_GLASS_PASSWORD_FILE, which is deleted once used; a TOTP code for the base32 secret in
+// BREAK_GLASS_TOTP_SECRET is always required as well.
+type breakGlassCredentials struct {
+	passwordHash string
+	passwordFile string
+	totpKey      []byte
+}
+
+func loadBreakGlassCredentials() (*breakGlassCredentials, bool) {
+	creds := breakGlassCredentials{
+		passwordHash: os.Getenv("BREAK_GLASS_PASSWORD_HASH"),
+		passwordFile: os.Getenv("BREAK_GLASS_PASSWORD_FILE"),
+	}
+	secret := os.Getenv("BREAK_GLASS_TOTP_SECRET")
+	if secret == "" || (creds.passwordHash == "" && creds.passwordFile == "") {
+		return nil, false
+	}
+	key, err := totp.DecodeSecret(secret)
+	if err != nil {
+		log.Printf("Break-glass account disabled: BREAK_GLASS_TOTP_SECRET: %v", err)
+		return nil, false
+	}
+	creds.totpKey = key
+	return &creds, true
+}
+
+// BreakGlassConfigured reports whether the environment provisions the break-glass account
+func BreakGlassConfigured() bool {
+	_, ok := loadBreakGlassCredentials()
+	return ok
+}
+
+// checkPassword reports whether password matches, and whether it was the one-time password,
+// which must then be consumed
+func (b *breakGlassCredentials) checkPassword(password string) (oneTime bool, ok bool) {
+	if b.passwordHash != "" && bcrypt.CompareHashAndPassword([]byte(b.passwordHash), []byte(password)) == nil {
+		return false, true
+	}
+	if b.passwordFile != "" {
+		contents, err := os.ReadFile(b.passwordFile)
+		if err != nil {
+			// Already used, or never written
+			return false, false
+		}
+		expected := strings.TrimSpace(string(contents))
+		if expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1 {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+var (
+	// lastBreakGlassStep is the TOTP step last signed in with, so a code can't be replayed
+	lastBreakGlassStep   int64
+	lastBreakGlassStepMu sync.Mutex
+)
+
+// useTOTPStep claims a TOTP step, reporting false if it or a later one was already used
+func useTOTPStep(step int64) bool {
+	lastBreakGlassStepMu.Lock()
+	defer lastBreakGlassStepMu.Unlock()
+	if step <= lastBreakGlassStep {
+		return false
+	}
+	lastBreakGlassStep = step
+	return true
+}
+
+// BreakGlassLoginPageHandler shows the break-glass sign-in form, which works while Azure AD is
+// down
+func BreakGlassLoginPageHandler(c *gin.Context) {
+	if !BreakGlassConfigured() {
+		c.String(http.StatusNotFound, "Break-glass account not configured")
+		return
+	}
+	c.HTML(http.StatusOK, "login.html", gin.H{"isAuthenticated": false, "breakGlass": true})
+}
+
+// BreakGlassLoginHandler signs in the break-glass account with its password and a TOTP code.
+// Every sign-in is audited and raised to System Admins as a critical notification.
+func BreakGlassLoginHandler(c *gin.Context) {
+	creds, ok := loadBreakGlassCredentials()
+	if !ok {
+		c.String(http.StatusNotFound, "Break-glass account not configured")
+		return
+	}
+	sqlDB, ok := contextDB(c)
+	if !ok {
+		c.String(http.StatusInternalServerError, "Database connection error")
+		return
+	}
+
+	fail := func(reason, message string) {
+		recordLogin(c, models.LoginMethodBreakGlass, models.BreakGlassEmail, false, reason)
+		c.HTML(http.StatusUnauthorized, "login.html", gin.H{"breakGlass": true, "error": message})
+	}
+
+	account, err := db.GetBreakGlassAccount(sqlDB)
+	if err != nil {
+		log.Printf("Failed to load break-glass account: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to load break-glass account")
+		return
+	}
+	if !account.Enabled {
+		fail("disabled by policy", "The break-glass account is disabled")
+		return
+	}
+
+	oneTime, ok := creds.checkPassword(c.PostForm("password"))
+	if !ok {
+		fail("invalid password", "Invalid credentials")
+		return
+	}
+	step, ok := totp.Validate(creds.totpKey, c.PostForm("code"), time.Now())
+	if !ok {
+		fail("invalid TOTP code", "Invalid credentials")
+		return
+	}
+	if !useTOTPStep(step) {
+		fail("TOTP code reused", "Invalid credentials")
+		return
+	}
+	if oneTime {
+		if err := os.Remove(creds.passwordFile); err != nil {
+			log.Printf("Refusing break-glass sign-in, one-time password file can't be removed: %v", err)
+			fail("one-time password not consumed", "The one-time password can't be used")
+			return
+		}
+	}
+
+	userID, err := db.EnsureBreakGlassUser(sqlDB)
+	if err != nil {
+		log.Printf("Failed to provision break-glass user: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		c.String(http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+	sessionToken := hex.EncodeToString(token)
+	err = db.StartBreakGlassSession(sqlDB, models.HashBreakGlassToken(sessionToken), time.Now().Add(models.BreakGlassSessionLifetime))
+	if err == sql.ErrNoRows {
+		fail("disabled by policy", "The break-glass account is disabled")
+		return
+	} else if err != nil {
+		log.Printf("Failed to start break-glass session: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+
+	recordLogin(c, models.LoginMethodBreakGlass, models.BreakGlassEmail, true, "")
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionBreakGlassLogin, "user", userID, c.ClientIP(),
+		map[string]interface{}{"one_time_password": oneTime, "user_agent": c.Request.UserAgent()}); err != nil {
+		log.Printf("Failed to write audit log for break-glass sign-in: %v", err)
+	}
+	log.Printf("BREAK-GLASS: emergency account signed in from %s", c.ClientIP())
+	notify.Record(sqlDB, "", models.NotificationEventBreakGlass, notify.Message{
+		Title:    "Break-glass account signed in",
+		Text:     "Someone signed in with the emergency break-glass account. Every change it makes is audited; disable it in System settings if this wasn't expected.",
+		Severity: notify.SeverityCritical,
+		URL:      "/admin/audit-logs",
+		Fields: []notify.Field{
+			{Name: "IP address", Value: c.ClientIP()},
+			{Name: "User agent", Value: c.Request.UserAgent()},
+		},
+	})
+
+	maxAge := int(models.BreakGlassSessionLifetime.Seconds())
+	setSessionCookie(c, "email", models.BreakGlassEmail, maxAge)
+	setSessionCookie(c, "name", models.BreakGlassName, maxAge)
+	setSessionCookie(c, "oid", models.BreakGlassAzureOID, maxAge)
+	setSessionCookie(c, breakGlassCookie, sessionToken, maxAge)
+	setSessionCookie(c, "session", "dummy-session", maxAge)
+	c.Redirect(http.StatusFound, "/admin")
+}
+
+// checkBreakGlassSession reports whether the request carries the break-glass account's current
+// session while the policy allows it; otherwise the user is sent back to sign in
+func checkBreakGlassSession(c *gin.Context, sqlDB *sql.DB) bool {
+	token, _ := c.Cookie(breakGlassCookie)
+	account, err := db.GetBreakGlassAccount(sqlDB)
+	if err != nil {
+		log.Printf("Failed to load break-glass account: %v", err)
+	}
+	if err != nil || !account.Enabled || !account.SessionValid(token, time.Now()) {
+		if err == nil {
+			if err := db.DeactivateBreakGlassUser(sqlDB); err != nil {
+				log.Printf("Failed to deactivate break-glass user: %v", err)
+			}
+		}
+		clearSessionCookies(c)
+		c.Redirect(http.StatusFound, "/login/break-glass")
+		c.Abort()
+		return false
+	}
+	c.Set("break_glass", true)
+	return true
+}
+
+// IsBreakGlass reports whether the request is made by the break-glass account
+func IsBreakGlass(c *gin.Context) bool {
+	return c.GetBool("break_glass")
+}
+
+// auditBreakGlassRequest records a change made by the break-glass account, once it is handled
+func auditBreakGlassRequest(c *gin.Context, sqlDB *sql.DB, userID string) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+	err := db.CreateAuditLog(sqlDB, userID, models.AuditActionBreakGlassRequest, "request", c.Request.URL.Path, c.ClientIP(),
+		map[string]interface{}{"method": c.Request.Method, "path": c.Request.URL.Path, "status": c.Writer.Status()})
+	if err != nil {
+		log.Printf("BREAK-GLASS: failed to audit %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+}
+
+// endBreakGlassSession signs the break-glass account out, if the request is its session
+func endBreakGlassSession(c *gin.Context, sqlDB *sql.DB) {
+	if token, err := c.Cookie(breakGlassCookie); err == nil && token != "" && sqlDB != nil {
+		if err := db.EndBreakGlassSession(sqlDB); err != nil {
+			log.Printf("Failed to end break-glass session: %v", err)
+		}
+	}
+	setSessionCookie(c, breakGlassCookie, "", -1)
+}
@@ -1,12 +1,20 @@
 package auth
 
 import (
+	"database/sql"
 	"log"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/like-mike/relai-gateway/shared/config"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/i18n"
 )
 
+// SelectedOrgCookie is set by the org selector so server-rendered pages and assets know the
+// active organization
+const SelectedOrgCookie = "selected_org_id"
+
 // UserContext represents the authenticated user's context data
 type UserContext struct {
 	UserName        string
@@ -49,6 +57,10 @@ func GetUserContext(c *gin.Context) gin.H {
 		"azure_oid":       azureOID,
 		"memberships":     userMemberships,
 		"isAuthenticated": isAuthenticated,
+		"developerOnly":   DeveloperOnly(c),
+		"breakGlass":      IsBreakGlass(c),
+		"Locale":          ResolveLocale(c),
+		"Locales":         i18n.Locales(),
 	}
 
 	// Add theme data if available
@@ -61,6 +73,36 @@ func GetUserContext(c *gin.Context) gin.H {
 	return context
 }
 
+// ResolveLocale picks the UI locale: the user's preference, then the selected organization's
+// default, then the browser's Accept-Language
+func ResolveLocale(c *gin.Context) string {
+	var candidates []string
+
+	if database, exists := c.Get("db"); exists {
+		if sqlDB, ok := database.(*sql.DB); ok {
+			if userID, ok := c.Get("user_id"); ok {
+				if id, ok := userID.(string); ok && id != "" {
+					if locale, err := db.GetUserLocale(sqlDB, id); err == nil {
+						candidates = append(candidates, locale)
+					}
+				}
+			}
+			if orgID, err := c.Cookie(SelectedOrgCookie); err == nil && orgID != "" {
+				if locale, err := db.GetOrganizationLocale(sqlDB, orgID); err == nil {
+					candidates = append(candidates, locale)
+				}
+			}
+		}
+	}
+
+	// Accept-Language: "es-MX,es;q=0.9,en;q=0.8" - listed in preference order
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		candidates = append(candidates, strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+	}
+
+	return i18n.Resolve(candidates...)
+}
+
 // RequireAuth is a helper that can be used to check if user is authenticated
 func RequireAuth(c *gin.Context) bool {
 	isAuth, exists := c.Get("isAuthenticated")
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// Routes open to developer-only users: their keys and usage, the API docs, and the account menu
+var (
+	developerPaths = map[string]bool{
+		"/admin":                true,
+		"/admin/docs":           true,
+		"/admin/logout":         true,
+		"/admin/refresh-access": true,
+		"/api-keys":             true,
+		"/api/keys":             true,
+		"/api/organizations":    true,
+	}
+	developerPathPrefixes = []string{"/api/keys/", "/api/me/", "/api/i18n/"}
+)
+
+func developerPathAllowed(path string) bool {
+	if developerPaths[path] {
+		return true
+	}
+	for _, prefix := range developerPathPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeveloperOnly reports whether the current user has the developer role in every organization
+// they belong to, and so only gets the self-service portal. System admins never do.
+func DeveloperOnly(c *gin.Context) bool {
+	if cached, ok := c.Get("developer_only"); ok {
+		developerOnly, _ := cached.(bool)
+		return developerOnly
+	}
+
+	developerOnly := false
+	userID, ok := GetUserID(c)
+	database, exists := c.Get("db")
+	cached, entry, found := sessionPermissions.developerOnly(userID)
+	if ok && found {
+		developerOnly = cached
+	} else if ok && exists {
+		if sqlDB, ok := database.(*sql.DB); ok {
+			var err error
+			developerOnly, err = isDeveloperOnly(sqlDB, userID)
+			if err != nil {
+				log.Printf("Failed to check developer access for user %s: %v", userID, err)
+			} else {
+				sessionPermissions.storeDeveloperOnly(userID, entry, developerOnly)
+			}
+		}
+	}
+
+	c.Set("developer_only", developerOnly)
+	return developerOnly
+}
+
+func isDeveloperOnly(sqlDB *sql.DB, userID string) (bool, error) {
+	isAdmin, err := db.IsSystemAdmin(sqlDB, userID)
+	if err != nil || isAdmin {
+		return false, err
+	}
+
+	memberships, err := db.GetUserOrganizationMemberships(sqlDB, userID)
+	if err != nil || len(memberships) == 0 {
+		return false, err
+	}
+	for _, role := range memberships {
+		if role != models.OrgRoleDeveloper {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// DeveloperScope keeps developer-only users inside the self-service portal. Pages outside it
+// redirect to the API keys page; API calls get a 403.
+func DeveloperScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if developerPathAllowed(c.Request.URL.Path) || !DeveloperOnly(c) {
+			c.Next()
+			return
+		}
+
+		if c.Request.Method == http.MethodGet && c.GetHeader("HX-Request") == "" &&
+			!strings.HasPrefix(c.Request.URL.Path, "/api") && c.GetHeader("Accept") != "application/json" {
+			c.Redirect(http.StatusFound, "/admin")
+			c.Abort()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Not available with developer access"})
+	}
+}
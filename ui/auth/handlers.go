@@ -1,20 +1,21 @@
 package auth
 
 import (
-	"bytes"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/graph"
 	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/secretfile"
 )
 
 const (
@@ -40,10 +41,30 @@ func LoadConfig() Config {
 		AzureClientID:     os.Getenv("AZURE_AD_CLIENT_ID"),
 		AzureTenantID:     os.Getenv("AZURE_AD_TENANT_ID"),
 		AzureRedirectURI:  os.Getenv("AZURE_AD_REDIRECT_URI"),
-		AzureClientSecret: os.Getenv("AZURE_AD_CLIENT_SECRET"),
+		AzureClientSecret: secretfile.Get("AZURE_AD_CLIENT_SECRET"),
 	}
 }
 
+var (
+	graphClient     *graph.Client
+	graphClientOnce sync.Once
+)
+
+// GraphClient returns the Microsoft Graph client shared by sign-in, group sync and group search,
+// so its token is cached across requests
+func GraphClient() *graph.Client {
+	graphClientOnce.Do(func() {
+		config := LoadConfig()
+		graphClient = graph.NewClient(config.AzureTenantID, config.AzureClientID, config.AzureClientSecret)
+		secretfile.OnChange(func(name string) {
+			if name == "AZURE_AD_CLIENT_SECRET" {
+				graphClient.SetClientSecret(secretfile.Get(name))
+			}
+		})
+	})
+	return graphClient
+}
+
 // setSessionCookie sets the session cookie.
 func setSessionCookie(c *gin.Context, key, value string, maxAge int) {
 	c.SetCookie(key, value, maxAge, "/", "", false, true)
@@ -68,6 +89,15 @@ func RegisterRoutes(router gin.IRoutes, config Config) {
 func RegisterPublicRoutes(router gin.IRoutes, config Config) {
 	// Login page
 	router.GET("/login", func(c *gin.Context) {
+		// Fresh installs have nobody who could sign in yet
+		if database, exists := c.Get("db"); exists {
+			if sqlDB, ok := database.(*sql.DB); ok {
+				if required, err := db.IsSetupRequired(sqlDB); err == nil && required {
+					c.Redirect(http.StatusFound, "/setup")
+					return
+				}
+			}
+		}
 		if config.EnableAzureAD {
 			c.Redirect(http.StatusFound, "/auth/azure")
 			return
@@ -84,6 +114,10 @@ func RegisterPublicRoutes(router gin.IRoutes, config Config) {
 		LocalLoginHandler(c, config)
 	})
 
+	// Break-glass sign-in for when Azure AD is down
+	router.GET("/login/break-glass", BreakGlassLoginPageHandler)
+	router.POST("/login/break-glass", BreakGlassLoginHandler)
+
 	// Azure AD login
 	router.GET("/auth/azure", func(c *gin.Context) {
 		AzureLoginHandler(c, config)
@@ -97,6 +131,9 @@ func RegisterPublicRoutes(router gin.IRoutes, config Config) {
 
 // LogoutHandler handles user logout
 func LogoutHandler(c *gin.Context, config Config) {
+	sqlDB, _ := contextDB(c)
+	endAzureSession(c, sqlDB)
+	endBreakGlassSession(c, sqlDB)
 	setSessionCookie(c, "session", "", -1)
 	setSessionCookie(c, "email", "", -1)
 	setSessionCookie(c, "name", "", -1)
@@ -126,10 +163,16 @@ func LocalLoginHandler(c *gin.Context, config Config) {
 	password := c.PostForm("password")
 
 	if config.EnableLocalLogin && username == adminUser && password == adminPass {
-		setSessionCookie(c, "session", "dummy-session", 3600)
+		recordLogin(c, models.LoginMethodLocal, username, true, "")
+		setSessionCookie(c, "session", "dummy-session", sessionMaxAge)
 		c.Redirect(http.StatusFound, "/admin")
 		return
 	}
+	reason := "invalid credentials"
+	if !config.EnableLocalLogin {
+		reason = "local login disabled"
+	}
+	recordLogin(c, models.LoginMethodLocal, username, false, reason)
 	c.HTML(http.StatusUnauthorized, "login.html", gin.H{"error": "Invalid credentials"})
 }
 
@@ -144,7 +187,7 @@ func AzureLoginHandler(c *gin.Context, config Config) {
 		"&response_type=code" +
 		"&redirect_uri=" + config.AzureRedirectURI +
 		"&response_mode=query" +
-		"&scope=openid email profile" +
+		"&scope=" + azureScope +
 		"&state=xyz"
 	c.Redirect(http.StatusFound, authURL)
 }
@@ -160,33 +203,40 @@ func AzureCallbackHandler(c *gin.Context, config Config) {
 	tokenEndpoint := "https://login.microsoftonline.com/" + config.AzureTenantID + "/oauth2/v2.0/token"
 	resp, err := http.PostForm(tokenEndpoint, map[string][]string{
 		"client_id":     {config.AzureClientID},
-		"client_secret": {config.AzureClientSecret},
-		"scope":         {"openid email profile"},
+		"client_secret": {secretfile.Get("AZURE_AD_CLIENT_SECRET")}, // Not config's, which may have been rotated since
+		"scope":         {azureScope},
 		"code":          {code},
 		"redirect_uri":  {config.AzureRedirectURI},
 		"grant_type":    {"authorization_code"},
 	})
 	if err != nil || resp.StatusCode != http.StatusOK {
+		// The account is unknown until the token is exchanged
+		recordLogin(c, models.LoginMethodAzure, "", false, "token exchange failed")
 		c.String(http.StatusUnauthorized, "Azure AD token exchange failed")
 		return
 	}
 	defer resp.Body.Close()
 	var tokenResp struct {
-		IDToken     string `json:"id_token"`
-		AccessToken string `json:"access_token"`
+		IDToken      string `json:"id_token"`
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		recordLogin(c, models.LoginMethodAzure, "", false, "invalid token response")
 		c.String(http.StatusUnauthorized, "Failed to parse Azure token response")
 		return
 	}
 	// Validate ID token (JWT)
 	token, _, err := jwt.NewParser().ParseUnverified(tokenResp.IDToken, jwt.MapClaims{})
 	if err != nil {
+		recordLogin(c, models.LoginMethodAzure, "", false, "invalid ID token")
 		c.String(http.StatusUnauthorized, "Invalid Azure ID token")
 		return
 	}
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
+		recordLogin(c, models.LoginMethodAzure, "", false, "invalid ID token claims")
 		c.String(http.StatusUnauthorized, "Invalid Azure token claims")
 		return
 	}
@@ -194,102 +244,25 @@ func AzureCallbackHandler(c *gin.Context, config Config) {
 	name, _ := claims["name"].(string)
 	oid, _ := claims["oid"].(string)
 
-	setSessionCookie(c, "email", email, 3600)
-	setSessionCookie(c, "name", name, 3600)
-	setSessionCookie(c, "oid", oid, 3600)
-
 	// Get user groups
-	accessToken, err := getAccessToken(config.AzureTenantID, config.AzureClientID, config.AzureClientSecret)
-	if err != nil {
-		c.String(http.StatusInternalServerError, "Failed to get access token")
-		return
-	}
-	results, err := getUserGroups(accessToken, oid)
+	results, err := GraphClient().UserGroups(oid)
 	if err != nil {
+		recordLogin(c, models.LoginMethodAzure, email, false, "failed to get user groups")
 		c.String(http.StatusInternalServerError, "Failed to get user groups")
 		return
 	}
 	fmt.Println("User groups:", results)
 
-	setSessionCookie(c, "session", "dummy-session", 3600)
-
-	c.Redirect(http.StatusFound, "/admin")
-}
+	recordLogin(c, models.LoginMethodAzure, email, true, "")
 
-func getAccessToken(tenantID, clientID, clientSecret string) (string, error) {
-	form := url.Values{}
-	form.Set("grant_type", "client_credentials")
-	form.Set("client_id", clientID)
-	form.Set("client_secret", clientSecret)
-	form.Set("scope", "https://graph.microsoft.com/.default")
+	// With a refresh token the session is extended in the background until its maximum age
+	maxAge := startAzureSession(c, email, oid, tokenResp.RefreshToken, tokenResp.ExpiresIn)
+	setSessionCookie(c, "email", email, maxAge)
+	setSessionCookie(c, "name", name, maxAge)
+	setSessionCookie(c, "oid", oid, maxAge)
+	setSessionCookie(c, "session", "dummy-session", maxAge)
 
-	url := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID)
-	resp, err := http.Post(url, "application/x-www-form-urlencoded", bytes.NewBufferString(form.Encode()))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("token request failed: %s", string(body))
-	}
-
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-	}
-	err = json.Unmarshal(body, &tokenResp)
-	if err != nil {
-		return "", err
-	}
-	return tokenResp.AccessToken, nil
-}
-
-func getUserGroups(accessToken, userID string) ([]string, error) {
-	results := []string{}
-
-	url := fmt.Sprintf("https://graph.microsoft.com/v1.0/users/%s/memberOf", userID)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return results, err
-	}
-	req.Header.Add("Authorization", "Bearer "+accessToken)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return results, err
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		return results, fmt.Errorf("graph request failed: %s", string(body))
-	}
-
-	var result struct {
-		Value []struct {
-			ID          string `json:"id"`
-			DisplayName string `json:"displayName"`
-			OdataType   string `json:"@odata.type"`
-		} `json:"value"`
-	}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		log.Printf("Failed to parse Graph API response: %v", err)
-		return results, err
-	}
-
-	log.Printf("Found %d directory objects for user %s", len(result.Value), userID)
-	for _, item := range result.Value {
-		if item.OdataType == "#microsoft.graph.group" {
-			results = append(results, item.ID)
-			log.Printf("User is member of group: %s (%s)", item.DisplayName, item.ID)
-		}
-	}
-
-	return results, nil
+	c.Redirect(http.StatusFound, "/admin")
 }
 
 // RefreshAccessHandler handles refresh access requests
@@ -309,18 +282,8 @@ func RefreshAccessHandler(c *gin.Context, config Config) {
 
 	log.Printf("=== REFRESH ACCESS REQUEST for %s (%s) ===", name, email)
 
-	// Get fresh access token and user groups
-	accessToken, err := getAccessToken(config.AzureTenantID, config.AzureClientID, config.AzureClientSecret)
-	if err != nil {
-		log.Printf("Failed to get access token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Failed to get access token",
-		})
-		return
-	}
-
-	userGroups, err := getUserGroups(accessToken, oid)
+	// Get fresh user groups
+	userGroups, err := GraphClient().UserGroups(oid)
 	if err != nil {
 		log.Printf("Failed to get user groups: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -376,11 +339,24 @@ func RefreshUserAccess(c *gin.Context, email, name, oid string, userGroups []str
 		})
 		return
 	}
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   "Your account has been deactivated",
+		})
+		return
+	}
 
 	// Sync user organization memberships based on AD groups
 	err = db.SyncUserOrganizationMemberships(sqlDB, user.ID, userGroups)
 	if err != nil {
 		log.Printf("Failed to sync user organization memberships: %v", err)
+		notify.Record(sqlDB, "", models.NotificationEventSyncFailure, notify.Message{
+			Title:    "AD group sync failed",
+			Text:     "A user's organization memberships couldn't be synced from their AD groups at sign-in, so they were refused.",
+			Severity: notify.SeverityWarning,
+			Fields:   []notify.Field{{Name: "User", Value: email}, {Name: "Error", Value: err.Error()}},
+		})
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"error":   "Failed to sync organization memberships",
@@ -410,8 +386,3 @@ func RefreshUserAccess(c *gin.Context, email, name, oid string, userGroups []str
 		"memberships": len(memberships),
 	})
 }
-
-// GetAccessToken gets an access token for Microsoft Graph API calls
-func GetAccessToken(tenantID, clientID, clientSecret string) (string, error) {
-	return getAccessToken(tenantID, clientID, clientSecret)
-}
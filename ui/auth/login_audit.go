@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+const (
+	// defaultFailureAlertThreshold is how many failed attempts before a successful sign-in
+	// make it suspicious
+	defaultFailureAlertThreshold = 5
+
+	// failureWindow bounds the failed attempts counted against a sign-in
+	failureWindow = time.Hour
+)
+
+// failureAlertThreshold returns LOGIN_FAILURE_ALERT_THRESHOLD, or the default
+func failureAlertThreshold() int {
+	threshold, err := strconv.Atoi(os.Getenv("LOGIN_FAILURE_ALERT_THRESHOLD"))
+	if err != nil || threshold <= 0 {
+		return defaultFailureAlertThreshold
+	}
+	return threshold
+}
+
+// loginCountry returns the caller's country from the header a geolocating proxy in front of the
+// UI sets, named by LOGIN_COUNTRY_HEADER (e.g. CF-IPCountry), or "" when it isn't configured
+func loginCountry(c *gin.Context) string {
+	header := os.Getenv("LOGIN_COUNTRY_HEADER")
+	if header == "" {
+		return ""
+	}
+	return models.NormalizeCountry(c.GetHeader(header))
+}
+
+// recordLogin records a sign-in attempt for identity, the username or email it was for. A
+// successful one is checked against the account's history, and System Admins are alerted in the
+// notification center when it looks suspicious. It never fails the sign-in; errors are logged.
+func recordLogin(c *gin.Context, method, identity string, success bool, failureReason string) {
+	if readonly.Enabled() {
+		return
+	}
+	database, exists := c.Get("db")
+	if !exists {
+		return
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return
+	}
+
+	event := models.LoginEvent{
+		Identity:      identity,
+		Method:        method,
+		Success:       success,
+		FailureReason: failureReason,
+		IPAddress:     c.ClientIP(),
+		UserAgent:     c.Request.UserAgent(),
+		Country:       loginCountry(c),
+	}
+	if strings.Contains(identity, "@") {
+		if user, err := db.GetUserByEmail(sqlDB, identity); err == nil && user != nil {
+			event.UserID = &user.ID
+		}
+	}
+
+	if success && identity != "" {
+		countries, failures, err := db.GetSignInHistory(sqlDB, identity, time.Now().Add(-failureWindow))
+		if err != nil {
+			log.Printf("Failed to get sign-in history of %s: %v", identity, err)
+		} else {
+			event.Anomalies = models.SignInAnomalies(event.Country, countries, failures, failureAlertThreshold())
+			if len(event.Anomalies) > 0 {
+				alertSuspiciousLogin(sqlDB, event, failures)
+			}
+		}
+	}
+
+	if err := db.CreateLoginEvent(sqlDB, &event); err != nil {
+		log.Printf("Failed to record %s sign-in of %q: %v", method, identity, err)
+	}
+}
+
+// alertSuspiciousLogin tells System Admins about an anomalous sign-in
+func alertSuspiciousLogin(sqlDB *sql.DB, event models.LoginEvent, failures int) {
+	fields := []notify.Field{
+		{Name: "Account", Value: event.Identity},
+		{Name: "IP address", Value: event.IPAddress},
+	}
+	var reasons []string
+	for _, anomaly := range event.Anomalies {
+		switch anomaly {
+		case models.SignInAnomalyNewCountry:
+			reasons = append(reasons, "from a country the account never signed in from")
+			fields = append(fields, notify.Field{Name: "Country", Value: event.Country})
+		case models.SignInAnomalyFailuresThenSuccess:
+			reasons = append(reasons, fmt.Sprintf("after %d failed attempts", failures))
+		}
+	}
+	if event.UserAgent != "" {
+		fields = append(fields, notify.Field{Name: "User agent", Value: event.UserAgent})
+	}
+
+	log.Printf("Suspicious sign-in of %s from %s: %s", event.Identity, event.IPAddress, strings.Join(event.Anomalies, ", "))
+	notify.Record(sqlDB, "", models.NotificationEventSuspiciousLogin, notify.Message{
+		Title:    fmt.Sprintf("Suspicious sign-in of %s", event.Identity),
+		Text:     "The account signed in " + strings.Join(reasons, " and ") + ". Check its sign-in history if this wasn't expected.",
+		Severity: notify.SeverityWarning,
+		Fields:   fields,
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
 )
 
 // Middleware provides authentication middleware for the UI
@@ -20,8 +21,14 @@ func Middleware() gin.HandlerFunc {
 			return
 		}
 
+		// Azure sessions are refreshed before their token expires, and end when Azure AD refuses
+		if !checkAzureSession(c) {
+			return
+		}
+
 		// Extract user information from cookies and set in context
 		var userName, userEmail, userRole, userID string
+		var user *models.User
 
 		if name, err := c.Cookie("name"); err == nil && name != "" {
 			userName = name
@@ -61,7 +68,8 @@ func Middleware() gin.HandlerFunc {
 			if exists {
 				if sqlDB, ok := database.(*sql.DB); ok {
 					log.Printf("DEBUG: Looking up user by email: %s", userEmail)
-					user, err := db.GetUserByEmail(sqlDB, userEmail)
+					var err error
+					user, err = db.GetUserByEmail(sqlDB, userEmail)
 					if err == nil && user != nil {
 						userID = user.ID
 						log.Printf("DEBUG: Found user ID %s for email %s", userID, userEmail)
@@ -97,6 +105,47 @@ func Middleware() gin.HandlerFunc {
 
 		log.Printf("DEBUG: Final userID being set: %s", userID)
 
+		// Deactivated accounts lose access immediately, not when the session expires
+		if userEmail != "" {
+			if database, exists := c.Get("db"); exists {
+				if sqlDB, ok := database.(*sql.DB); ok {
+					if deactivated, err := db.IsUserDeactivated(sqlDB, userEmail); err == nil && deactivated {
+						log.Printf("Rejecting session for deactivated user %s", userEmail)
+						setSessionCookie(c, "session", "", -1)
+						c.Redirect(http.StatusFound, "/login")
+						c.Abort()
+						return
+					}
+				}
+			}
+		}
+
+		// The break-glass account needs its current session, and every change it makes is audited.
+		// The resolved user decides, since the email cookie alone doesn't say who the request is.
+		if userEmail == models.BreakGlassEmail || (user != nil && user.AzureOID == models.BreakGlassAzureOID) {
+			if database, exists := c.Get("db"); exists {
+				if sqlDB, ok := database.(*sql.DB); ok {
+					if !checkBreakGlassSession(c, sqlDB) {
+						return
+					}
+					defer auditBreakGlassRequest(c, sqlDB, userID)
+				}
+			}
+		}
+
+		// Role and membership changes reach signed-in sessions on their next request
+		if userID != "" && userID != userEmail {
+			if database, exists := c.Get("db"); exists {
+				if sqlDB, ok := database.(*sql.DB); ok {
+					if version, err := db.GetPermissionsVersion(sqlDB, userID); err == nil {
+						sessionPermissions.validate(userID, version)
+					} else {
+						sessionPermissions.forget(userID)
+					}
+				}
+			}
+		}
+
 		// Set user data in context for all handlers to use
 		c.Set("userName", userName)
 		c.Set("userEmail", userEmail)
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// permissionCacheTTL bounds how long cached permissions are trusted without a version change,
+// for changes that don't bump it, such as an organization being deactivated
+const permissionCacheTTL = 30 * time.Second
+
+// cachedPermissions is what is known about one user's access, as of a permissions version
+type cachedPermissions struct {
+	version       int64
+	loadedAt      time.Time
+	permissions   map[string][]string // orgID -> permissions
+	developerOnly *bool
+}
+
+// permissionCache keeps users' permissions across requests. The middleware checks each request's
+// user against their permissions version in the database, so a role or membership change
+// reaches signed-in sessions on their next request.
+type permissionCache struct {
+	mu    sync.Mutex
+	users map[string]*cachedPermissions
+}
+
+var sessionPermissions = &permissionCache{users: make(map[string]*cachedPermissions)}
+
+// validate drops the user's cached permissions if their version has moved on or they have
+// expired; only a validated user's permissions are cached
+func (p *permissionCache) validate(userID string, version int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.users[userID]; ok && entry.version == version && time.Since(entry.loadedAt) < permissionCacheTTL {
+		return
+	}
+	p.users[userID] = &cachedPermissions{
+		version:     version,
+		loadedAt:    time.Now(),
+		permissions: make(map[string][]string),
+	}
+}
+
+// forget drops the user's cached permissions, when their version can't be checked
+func (p *permissionCache) forget(userID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.users, userID)
+}
+
+// orgPermissions returns the user's cached permissions in orgID, and the cache entry they were
+// looked up in, to hand back when storing permissions loaded on a miss
+func (p *permissionCache) orgPermissions(userID, orgID string) ([]string, *cachedPermissions, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.users[userID]
+	if !ok {
+		return nil, nil, false
+	}
+	permissions, ok := entry.permissions[orgID]
+	return permissions, entry, ok
+}
+
+// storeOrgPermissions caches permissions loaded while entry was the user's. If validate has
+// replaced it since, they may predate the change that did, so they are dropped.
+func (p *permissionCache) storeOrgPermissions(userID, orgID string, entry *cachedPermissions, permissions []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if current, ok := p.users[userID]; ok && current == entry {
+		current.permissions[orgID] = permissions
+	}
+}
+
+// developerOnly returns whether the user is cached as developer only, and the cache entry it was
+// looked up in, as orgPermissions does
+func (p *permissionCache) developerOnly(userID string) (bool, *cachedPermissions, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.users[userID]
+	if !ok || entry.developerOnly == nil {
+		return false, entry, false
+	}
+	return *entry.developerOnly, entry, true
+}
+
+// storeDeveloperOnly caches a developer-only check made while entry was the user's, as
+// storeOrgPermissions does
+func (p *permissionCache) storeDeveloperOnly(userID string, entry *cachedPermissions, developerOnly bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if current, ok := p.users[userID]; ok && current == entry {
+		current.developerOnly = &developerOnly
+	}
+}
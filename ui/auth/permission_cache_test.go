@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionsLoadedBeforeAChangeAreDropped(t *testing.T) {
+	cache := &permissionCache{users: make(map[string]*cachedPermissions)}
+	cache.validate("user", 1)
+
+	// A request misses and starts loading version 1's permissions...
+	_, entry, ok := cache.orgPermissions("user", "org")
+	assert.False(t, ok)
+	_, devEntry, _ := cache.developerOnly("user")
+
+	// ...while the user's role changes and another request validates the new version
+	cache.validate("user", 2)
+	cache.storeOrgPermissions("user", "org", entry, []string{"admin"})
+	cache.storeDeveloperOnly("user", devEntry, false)
+
+	_, _, ok = cache.orgPermissions("user", "org")
+	assert.False(t, ok, "permissions loaded under the old version must not be cached")
+	_, _, ok = cache.developerOnly("user")
+	assert.False(t, ok)
+
+	// Loads under the current version are cached
+	_, entry, _ = cache.orgPermissions("user", "org")
+	cache.storeOrgPermissions("user", "org", entry, []string{"read"})
+	permissions, _, ok := cache.orgPermissions("user", "org")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"read"}, permissions)
+}
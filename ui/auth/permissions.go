@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+)
+
+// Permission reports whether the current user holds permission (e.g. "keys:write") in the
+// organization the request targets: the org_id route parameter, org_id query parameter,
+// organization_id form field, or the selected organization, in that order
+func Permission(c *gin.Context, permission string) bool {
+	return OrgPermission(c, requestOrgID(c), permission)
+}
+
+// OrgPermission reports whether the current user holds permission in orgID. Use it when the
+// organization comes from the resource being changed rather than the request.
+func OrgPermission(c *gin.Context, orgID, permission string) bool {
+	for _, p := range userPermissions(c, orgID) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func requestOrgID(c *gin.Context) string {
+	if orgID := c.Param("org_id"); orgID != "" {
+		return orgID
+	}
+	if orgID := c.Query("org_id"); orgID != "" {
+		return orgID
+	}
+	if orgID := c.PostForm("organization_id"); orgID != "" {
+		return orgID
+	}
+	orgID, _ := c.Cookie(SelectedOrgCookie)
+	return orgID
+}
+
+// userPermissions loads the user's permissions in orgID once per request, and across requests
+// until their permissions version changes
+func userPermissions(c *gin.Context, orgID string) []string {
+	key := "permissions:" + orgID
+	if cached, ok := c.Get(key); ok {
+		permissions, _ := cached.([]string)
+		return permissions
+	}
+
+	userID, ok := GetUserID(c)
+	if !ok {
+		return nil
+	}
+	known, entry, ok := sessionPermissions.orgPermissions(userID, orgID)
+	if ok {
+		c.Set(key, known)
+		return known
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		return nil
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		return nil
+	}
+
+	permissions, err := db.GetUserPermissions(sqlDB, userID, orgID)
+	if err != nil {
+		log.Printf("Failed to load permissions for user %s in organization %s: %v", userID, orgID, err)
+		return nil
+	}
+
+	sessionPermissions.storeOrgPermissions(userID, orgID, entry, permissions)
+	c.Set(key, permissions)
+	return permissions
+}
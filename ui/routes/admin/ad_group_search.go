@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/graph"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+const (
+	graphGroupsURL = graph.BaseURL + "/groups"
+
+	adGroupSearchDefaultLimit = 25
+	adGroupSearchMaxLimit     = 100
+	adGroupSearchCacheTTL     = 5 * time.Minute
+	adGroupSearchCacheSize    = 256
+)
+
+// adGroupSearchResult is one page of matching groups. Next is passed back as ?next= to fetch
+// the following page.
+type adGroupSearchResult struct {
+	Groups []graph.Group `json:"groups"`
+	Next   string        `json:"next,omitempty"`
+}
+
+type adGroupSearchCacheEntry struct {
+	result  *adGroupSearchResult
+	expires time.Time
+}
+
+// adGroupSearchCache keeps recent Graph lookups so typeahead searches don't hit Graph per keystroke
+var adGroupSearchCache = struct {
+	sync.Mutex
+	entries map[string]adGroupSearchCacheEntry
+}{entries: map[string]adGroupSearchCacheEntry{}}
+
+func cachedADGroupSearch(key string) (*adGroupSearchResult, bool) {
+	adGroupSearchCache.Lock()
+	defer adGroupSearchCache.Unlock()
+
+	entry, ok := adGroupSearchCache.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.result, true
+}
+
+func cacheADGroupSearch(key string, result *adGroupSearchResult) {
+	adGroupSearchCache.Lock()
+	defer adGroupSearchCache.Unlock()
+
+	now := time.Now()
+	if len(adGroupSearchCache.entries) >= adGroupSearchCacheSize {
+		// Drop expired entries, then the one closest to expiry if still full
+		var oldestKey string
+		var oldest time.Time
+		for k, e := range adGroupSearchCache.entries {
+			if now.After(e.expires) {
+				delete(adGroupSearchCache.entries, k)
+			} else if oldestKey == "" || e.expires.Before(oldest) {
+				oldestKey, oldest = k, e.expires
+			}
+		}
+		if len(adGroupSearchCache.entries) >= adGroupSearchCacheSize {
+			delete(adGroupSearchCache.entries, oldestKey)
+		}
+	}
+
+	adGroupSearchCache.entries[key] = adGroupSearchCacheEntry{result: result, expires: now.Add(adGroupSearchCacheTTL)}
+}
+
+// adGroupSearchURL builds the Graph request for a search. q matches display names; filter is
+// passed through as an OData $filter, e.g. "securityEnabled eq true".
+func adGroupSearchURL(q, filter string, limit int) string {
+	params := url.Values{}
+	params.Set("$select", "id,displayName,description")
+	params.Set("$top", strconv.Itoa(limit))
+	// Advanced queries ($search, $count) need ConsistencyLevel: eventual
+	params.Set("$count", "true")
+	if q != "" {
+		q = strings.NewReplacer(`"`, "", `\`, "").Replace(q)
+		params.Set("$search", fmt.Sprintf(`"displayName:%s"`, q))
+	} else {
+		params.Set("$orderby", "displayName")
+	}
+	if filter != "" {
+		params.Set("$filter", filter)
+	}
+	return graphGroupsURL + "?" + params.Encode()
+}
+
+// ADGroupSearchHandler searches Azure AD groups server-side, one page at a time.
+// Query parameters: q (display name search), filter (OData $filter), limit (1-100, default 25)
+// and next (the previous page's next value).
+func ADGroupSearchHandler(c *gin.Context) {
+	config := auth.LoadConfig()
+	if !config.EnableAzureAD {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Azure AD integration is disabled"})
+		return
+	}
+
+	requestURL := c.Query("next")
+	if requestURL != "" {
+		// Only follow Graph's own paging links
+		if !strings.HasPrefix(requestURL, graphGroupsURL+"?") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid next link"})
+			return
+		}
+	} else {
+		limit := adGroupSearchDefaultLimit
+		if s := c.Query("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > adGroupSearchMaxLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", adGroupSearchMaxLimit)})
+				return
+			}
+			limit = n
+		}
+		requestURL = adGroupSearchURL(strings.TrimSpace(c.Query("q")), strings.TrimSpace(c.Query("filter")), limit)
+	}
+
+	if result, ok := cachedADGroupSearch(requestURL); ok {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	page, err := auth.GraphClient().Groups(requestURL, "eventual")
+	if err != nil {
+		log.Printf("Failed to search AD groups: %v", err)
+		if graphErr, ok := err.(*graph.Error); ok && graphErr.StatusCode == http.StatusBadRequest {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Azure AD rejected the search; check the filter"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to search Azure AD groups"})
+		return
+	}
+	result := &adGroupSearchResult{Groups: page.Groups, Next: page.NextLink}
+
+	cacheADGroupSearch(requestURL, result)
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// GetOrganizationADGroupMappingsHandler lists the AD groups mapped to an organization in
+// resolution order; requires admin of the organization or System Admin
+func GetOrganizationADGroupMappingsHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	mappings, err := db.GetOrganizationADGroupMappings(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get AD group mappings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load AD group mappings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mappings": mappings})
+}
+
+// CreateOrganizationADGroupMappingHandler maps an AD group to a role in the organization; requires
+// admin of the organization or System Admin and is audited. Takes effect at members' next sign-in.
+func CreateOrganizationADGroupMappingHandler(c *gin.Context) {
+	var req models.CreateOrgADGroupMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+	if !ensureRoleExists(c, sqlDB, orgID, req.RoleName) {
+		return
+	}
+
+	mapping, err := db.CreateOrganizationADGroupMapping(sqlDB, orgID, req)
+	if err != nil {
+		log.Printf("Failed to create AD group mapping: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create AD group mapping"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionADGroupMappingCreate, "ad_group_mapping", mapping.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "ad_group_id": mapping.ADGroupID, "role_name": mapping.RoleName, "priority": mapping.Priority}); err != nil {
+		log.Printf("Failed to write audit log for AD group mapping create: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"mapping": mapping})
+}
+
+// UpdateOrganizationADGroupMappingHandler changes a mapping's role, priority or active state;
+// requires admin of the organization or System Admin and is audited
+func UpdateOrganizationADGroupMappingHandler(c *gin.Context) {
+	var req models.UpdateOrgADGroupMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID, mappingID := c.Param("id"), c.Param("mapping_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+	if req.RoleName != nil && !ensureRoleExists(c, sqlDB, orgID, *req.RoleName) {
+		return
+	}
+
+	mapping, err := db.UpdateOrganizationADGroupMapping(sqlDB, orgID, mappingID, req)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "AD group mapping not found"})
+		return
+	} else if err != nil {
+		// Most likely the group is already mapped to the new role
+		log.Printf("Failed to update AD group mapping: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update AD group mapping"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionADGroupMappingUpdate, "ad_group_mapping", mapping.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "ad_group_id": mapping.ADGroupID, "role_name": mapping.RoleName,
+			"priority": mapping.Priority, "is_active": mapping.IsActive}); err != nil {
+		log.Printf("Failed to write audit log for AD group mapping update: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mapping": mapping})
+}
+
+// DeleteOrganizationADGroupMappingHandler removes a mapping; requires admin of the organization
+// or System Admin and is audited
+func DeleteOrganizationADGroupMappingHandler(c *gin.Context) {
+	orgID, mappingID := c.Param("id"), c.Param("mapping_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	err := db.DeleteOrganizationADGroupMapping(sqlDB, orgID, mappingID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "AD group mapping not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete AD group mapping: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete AD group mapping"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionADGroupMappingDelete, "ad_group_mapping", mappingID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID}); err != nil {
+		log.Printf("Failed to write audit log for AD group mapping delete: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// SimulateADSyncHandler reports which memberships and roles AD group sync would produce for a
+// user, a list of groups, or a user with substitute groups, without writing anything. When only
+// an email is given the user's current groups are fetched from Graph. Requires System Admin.
+func SimulateADSyncHandler(c *gin.Context) {
+	var req models.ADSyncSimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	simulation := models.ADSyncSimulation{Email: req.Email, ADGroupIDs: req.ADGroupIDs}
+
+	var user *models.User
+	if req.Email != "" {
+		var err error
+		user, err = db.GetUserByEmail(sqlDB, req.Email)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No active user with that email"})
+			return
+		} else if err != nil {
+			log.Printf("Failed to get user by email: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+			return
+		}
+		simulation.UserID = user.ID
+	}
+
+	if len(req.ADGroupIDs) == 0 {
+		config := auth.LoadConfig()
+		if !config.EnableAzureAD {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Azure AD integration is disabled; pass ad_group_ids instead"})
+			return
+		}
+
+		groups, err := auth.GraphClient().UserGroups(user.AzureOID)
+		if err != nil {
+			log.Printf("Failed to get user groups: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch the user's Azure AD groups"})
+			return
+		}
+		simulation.ADGroupIDs = groups
+	}
+
+	results, err := db.SimulateADSync(sqlDB, simulation.UserID, simulation.ADGroupIDs)
+	if err != nil {
+		log.Printf("Failed to simulate AD sync: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to simulate AD sync"})
+		return
+	}
+	simulation.Organizations = results
+
+	c.JSON(http.StatusOK, simulation)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/like-mike/relai-gateway/shared/db"
 	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
 )
 
 func AnalyticsDashboardHandler(c *gin.Context) {
@@ -25,12 +26,19 @@ func AnalyticsDashboardHandler(c *gin.Context) {
 		return
 	}
 
+	if !auth.OrgPermission(c, c.Query("org_id"), models.PermissionAnalyticsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+		return
+	}
+
 	// Parse query parameters
 	filter := models.AnalyticsFilter{
-		TimeRange:    c.DefaultQuery("range", "7d"),
-		StartDate:    c.Query("start_date"),
-		EndDate:      c.Query("end_date"),
-		Organization: c.Query("org_id"),
+		TimeRange:       c.DefaultQuery("range", "7d"),
+		StartDate:       c.Query("start_date"),
+		EndDate:         c.Query("end_date"),
+		Organization:    c.Query("org_id"),
+		IncludeChildren: c.Query("include_children") == "true",
+		Models:          c.QueryArray("model_id"),
 	}
 
 	// Fetch dashboard data
@@ -85,11 +93,293 @@ func AnalyticsDashboardHandler(c *gin.Context) {
 	}
 	dashboardData.ProviderSpend = providerSpend
 
+	// Get project spend breakdown
+	projectSpend, err := db.GetProjectSpendBreakdown(sqlDB, filter)
+	if err != nil {
+		log.Printf("Failed to get project spend: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project spend"})
+		return
+	}
+	dashboardData.ProjectSpend = projectSpend
+
+	if !auth.OrgPermission(c, filter.Organization, models.PermissionCostsRead) {
+		dashboardData.HideCosts()
+	}
+	dashboardData.ConvertCosts(displayRate(sqlDB, filter.Organization))
+
 	c.JSON(http.StatusOK, dashboardData)
 }
 
+// AnalyticsCompareHandler compares usage in the selected range with the period before it, for
+// the dashboard's "vs last period" badges
+func AnalyticsCompareHandler(c *gin.Context) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	if !auth.OrgPermission(c, c.Query("org_id"), models.PermissionAnalyticsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+		return
+	}
+
+	filter := models.AnalyticsFilter{
+		TimeRange:       c.DefaultQuery("range", "7d"),
+		StartDate:       c.Query("start_date"),
+		EndDate:         c.Query("end_date"),
+		Organization:    c.Query("org_id"),
+		IncludeChildren: c.Query("include_children") == "true",
+		Models:          c.QueryArray("model_id"),
+	}
+
+	comparison, err := db.GetPeriodComparison(sqlDB, filter)
+	if err != nil {
+		log.Printf("Failed to compare periods: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare periods"})
+		return
+	}
+	comparison.TimeRange = filter.TimeRange
+	comparison.Organization = filter.Organization
+	comparison.GeneratedAt = time.Now()
+
+	if !auth.OrgPermission(c, filter.Organization, models.PermissionCostsRead) {
+		comparison.HideCosts()
+	}
+	comparison.ConvertCosts(displayRate(sqlDB, filter.Organization))
+
+	c.JSON(http.StatusOK, comparison)
+}
+
+// FeedbackAnalyticsHandler summarizes response satisfaction by model and endpoint
+func FeedbackAnalyticsHandler(c *gin.Context) {
+	// Get database connection
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	if !auth.OrgPermission(c, c.Query("org_id"), models.PermissionAnalyticsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+		return
+	}
+
+	// Parse query parameters
+	filter := models.AnalyticsFilter{
+		TimeRange:    c.DefaultQuery("range", "7d"),
+		StartDate:    c.Query("start_date"),
+		EndDate:      c.Query("end_date"),
+		Organization: c.Query("org_id"),
+	}
+
+	feedback, err := db.GetFeedbackAnalytics(sqlDB, filter)
+	if err != nil {
+		log.Printf("Failed to get feedback analytics: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feedback analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, feedback)
+}
+
+// SchemaValidationAnalyticsHandler reports how often model output fails its declared response schema
+func SchemaValidationAnalyticsHandler(c *gin.Context) {
+	// Get database connection
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	if !auth.OrgPermission(c, c.Query("org_id"), models.PermissionAnalyticsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+		return
+	}
+
+	// Parse query parameters
+	filter := models.AnalyticsFilter{
+		TimeRange:    c.DefaultQuery("range", "7d"),
+		StartDate:    c.Query("start_date"),
+		EndDate:      c.Query("end_date"),
+		Organization: c.Query("org_id"),
+	}
+
+	stats, err := db.GetSchemaValidationStats(sqlDB, filter)
+	if err != nil {
+		log.Printf("Failed to get schema validation stats: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schema validation stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats":        stats,
+		"time_range":   filter.TimeRange,
+		"organization": filter.Organization,
+		"generated_at": time.Now(),
+	})
+}
+
 func AnalyticsPageHandler(c *gin.Context) {
 	c.HTML(http.StatusOK, "analytics.html", gin.H{
 		"title": "Usage Analytics",
 	})
 }
+
+// ForecastAnalyticsHandler projects end-of-month spend and, for an organization with a quota,
+// the day the quota runs out, from the last four weeks of usage
+func ForecastAnalyticsHandler(c *gin.Context) {
+	// Get database connection
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	orgID := c.Query("org_id")
+	if !auth.OrgPermission(c, orgID, models.PermissionAnalyticsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	historyStart := today.AddDate(0, 0, -models.ForecastHistoryDays)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+	since := historyStart
+	if monthStart.Before(since) {
+		since = monthStart
+	}
+
+	days, err := db.GetDailySpend(sqlDB, orgID, since)
+	if err != nil {
+		log.Printf("Failed to get daily spend: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage history"})
+		return
+	}
+
+	var monthToDate float64
+	usageToday := models.DailySpend{Date: today}
+	for _, d := range days {
+		day := time.Date(d.Date.Year(), d.Date.Month(), d.Date.Day(), 0, 0, 0, 0, today.Location())
+		if !day.Before(monthStart) {
+			monthToDate += d.Cost
+		}
+		if day.Equal(today) {
+			usageToday.Cost, usageToday.Tokens = d.Cost, d.Tokens
+		}
+	}
+
+	var quota *models.OrganizationQuota
+	if orgID != "" {
+		quota, err = db.GetOrganizationQuota(sqlDB, orgID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Failed to get organization quota: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quota"})
+			return
+		}
+	}
+
+	history := models.FillDailySpend(days, historyStart, today)
+	forecast := models.BuildCostForecast(history, usageToday, monthToDate, quota)
+	forecast.OrganizationID = orgID
+	if !auth.OrgPermission(c, orgID, models.PermissionCostsRead) {
+		forecast.HideCosts()
+	}
+	forecast.ConvertCosts(displayRate(sqlDB, orgID))
+
+	c.JSON(http.StatusOK, forecast)
+}
+
+// EndpointAnalyticsHandler breaks spend down by request path or, with group_by=custom_endpoint,
+// by custom endpoint, with a time series of the top groups
+func EndpointAnalyticsHandler(c *gin.Context) {
+	// Get database connection
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	if !auth.OrgPermission(c, c.Query("org_id"), models.PermissionAnalyticsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+		return
+	}
+
+	grouping := c.DefaultQuery("group_by", models.EndpointGroupPath)
+	if !models.IsValidEndpointGrouping(grouping) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be path or custom_endpoint"})
+		return
+	}
+
+	// Parse query parameters
+	filter := models.AnalyticsFilter{
+		TimeRange:       c.DefaultQuery("range", "7d"),
+		StartDate:       c.Query("start_date"),
+		EndDate:         c.Query("end_date"),
+		Organization:    c.Query("org_id"),
+		IncludeChildren: c.Query("include_children") == "true",
+		Models:          c.QueryArray("model_id"),
+	}
+
+	endpoints, err := db.GetEndpointSpendBreakdown(sqlDB, filter, grouping, 25)
+	if err != nil {
+		log.Printf("Failed to get endpoint spend: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch endpoint spend"})
+		return
+	}
+
+	series, err := db.GetEndpointSpendSeries(sqlDB, filter, grouping, 5)
+	if err != nil {
+		log.Printf("Failed to get endpoint spend series: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch endpoint spend series"})
+		return
+	}
+
+	costsHidden := !auth.OrgPermission(c, filter.Organization, models.PermissionCostsRead)
+	if costsHidden {
+		models.HideEndpointCosts(endpoints, series)
+	}
+	rate := displayRate(sqlDB, filter.Organization)
+	models.ConvertEndpointCosts(endpoints, series, rate)
+
+	c.JSON(http.StatusOK, gin.H{
+		"group_by":     grouping,
+		"endpoints":    endpoints,
+		"series":       series,
+		"time_range":   filter.TimeRange,
+		"costs_hidden": costsHidden,
+		"currency":     rate.Currency,
+		"fx_rate":      rate.RatePerUSD,
+	})
+}
@@ -0,0 +1,239 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// AnalyticsViewsHandler lists the user's saved analytics views and the views shared with them
+func AnalyticsViewsHandler(c *gin.Context) {
+	sqlDB, userID, ok := analyticsViewUser(c)
+	if !ok {
+		return
+	}
+
+	memberships, err := db.GetUserOrganizationMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return
+	}
+
+	// Shared views are only listed where the user can read the analytics they show
+	var orgIDs []string
+	for orgID := range memberships {
+		if auth.OrgPermission(c, orgID, models.PermissionAnalyticsRead) {
+			orgIDs = append(orgIDs, orgID)
+		}
+	}
+
+	views, err := db.GetAnalyticsViews(sqlDB, userID, orgIDs)
+	if err != nil {
+		log.Printf("Failed to get analytics views: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load views"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"views": views,
+	})
+}
+
+// CreateAnalyticsViewHandler saves the current dashboard filters as a named view; requires
+// analytics:read in the filtered organization
+func CreateAnalyticsViewHandler(c *gin.Context) {
+	var req models.CreateAnalyticsViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind analytics view request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, userID, ok := analyticsViewUser(c)
+	if !ok {
+		return
+	}
+	if !auth.OrgPermission(c, req.Filters.Organization, models.PermissionAnalyticsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+		return
+	}
+
+	view, err := db.CreateAnalyticsView(sqlDB, userID, req)
+	if err == db.ErrAnalyticsViewNameTaken {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create analytics view: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save view"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"view":    view,
+		"message": "View saved successfully",
+	})
+}
+
+// AnalyticsViewHandler returns one view. Its owner can always open it; other users can open a
+// shared view when they can read analytics in its organization.
+func AnalyticsViewHandler(c *gin.Context) {
+	sqlDB, userID, ok := analyticsViewUser(c)
+	if !ok {
+		return
+	}
+
+	view, ok := loadAnalyticsView(c, sqlDB)
+	if !ok {
+		return
+	}
+
+	if view.UserID != userID {
+		if !view.Shared || view.OrganizationID == nil || !auth.OrgPermission(c, *view.OrganizationID, models.PermissionAnalyticsRead) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"view": view,
+	})
+}
+
+// UpdateAnalyticsViewHandler renames, re-filters or (un)shares a view; only its owner can
+func UpdateAnalyticsViewHandler(c *gin.Context) {
+	sqlDB, view, ok := loadOwnAnalyticsView(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateAnalyticsViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind analytics view update request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, shared := view.Filters, view.Shared
+	if req.Filters != nil {
+		filters = *req.Filters
+		if !auth.OrgPermission(c, filters.Organization, models.PermissionAnalyticsRead) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+			return
+		}
+	}
+	if req.Shared != nil {
+		shared = *req.Shared
+	}
+	if shared && filters.Organization == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only views filtered to an organization can be shared"})
+		return
+	}
+
+	updated, err := db.UpdateAnalyticsView(sqlDB, view.ID, req)
+	if err == db.ErrAnalyticsViewNameTaken {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update analytics view: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update view"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"view":    updated,
+		"message": "View updated successfully",
+	})
+}
+
+// DeleteAnalyticsViewHandler deletes a view; only its owner can
+func DeleteAnalyticsViewHandler(c *gin.Context) {
+	sqlDB, view, ok := loadOwnAnalyticsView(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteAnalyticsView(sqlDB, view.ID); err != nil {
+		log.Printf("Failed to delete analytics view: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete view"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "View deleted successfully",
+	})
+}
+
+// analyticsViewUser returns the database and the signed-in user. It writes the error response
+// itself.
+func analyticsViewUser(c *gin.Context) (*sql.DB, string, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", false
+	}
+
+	userID, _ := auth.GetUserContext(c)["id"].(string)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return nil, "", false
+	}
+
+	return sqlDB, userID, true
+}
+
+// loadAnalyticsView loads the view named by :id. It writes the error response itself.
+func loadAnalyticsView(c *gin.Context, sqlDB *sql.DB) (*models.AnalyticsView, bool) {
+	view, err := db.GetAnalyticsView(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to get analytics view: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load view"})
+		return nil, false
+	}
+	return view, true
+}
+
+// loadOwnAnalyticsView loads the view named by :id when the signed-in user owns it; other users'
+// views are reported as not found. It writes the error response itself.
+func loadOwnAnalyticsView(c *gin.Context) (*sql.DB, *models.AnalyticsView, bool) {
+	sqlDB, userID, ok := analyticsViewUser(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	view, ok := loadAnalyticsView(c, sqlDB)
+	if !ok {
+		return nil, nil, false
+	}
+	if view.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "View not found"})
+		return nil, nil, false
+	}
+
+	return sqlDB, view, true
+}
@@ -4,17 +4,38 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/like-mike/relai-gateway/shared/db"
 	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/servicetoken"
 	"github.com/like-mike/relai-gateway/ui/auth"
 )
 
+// ownsAPIKey reports whether userID is responsible for the key
+func ownsAPIKey(key models.APIKey, userID string) bool {
+	return key.OwnerUserID != nil && *key.OwnerUserID == userID
+}
+
+// canSeeAPIKey reports whether the user may list the key: keys:read in its organization, or
+// keys:own for a key they own
+func canSeeAPIKey(c *gin.Context, key models.APIKey, userID string) bool {
+	return auth.OrgPermission(c, key.OrganizationID, models.PermissionKeysRead) ||
+		(ownsAPIKey(key, userID) && auth.OrgPermission(c, key.OrganizationID, models.PermissionKeysOwn))
+}
+
+// canManageAPIKey reports whether the user may regenerate or delete the key: keys:write in its
+// organization, or keys:own for a key they own
+func canManageAPIKey(c *gin.Context, key models.APIKey, userID string) bool {
+	return auth.OrgPermission(c, key.OrganizationID, models.PermissionKeysWrite) ||
+		(ownsAPIKey(key, userID) && auth.OrgPermission(c, key.OrganizationID, models.PermissionKeysOwn))
+}
+
 func APIKeysHandler(c *gin.Context) {
 	// Get database connection from context
 	database, exists := c.Get("db")
@@ -99,7 +120,29 @@ func APIKeysHandler(c *gin.Context) {
 			return
 		}
 
+		if !auth.OrgPermission(c, orgID, models.PermissionKeysRead) && !auth.OrgPermission(c, orgID, models.PermissionKeysOwn) {
+			acceptHeader := c.GetHeader("Accept")
+			if acceptHeader == "application/json" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:read required"})
+			} else {
+				c.HTML(http.StatusForbidden, "api-keys-table.html", gin.H{
+					"error": "Permission keys:read required",
+				})
+			}
+			return
+		}
+
 		apiKeys, err = db.GetAPIKeysByOrganization(sqlDB, orgID)
+		if err == nil {
+			// Developers only see keys they own
+			var visibleAPIKeys []models.APIKey
+			for _, apiKey := range apiKeys {
+				if canSeeAPIKey(c, apiKey, userID) {
+					visibleAPIKeys = append(visibleAPIKeys, apiKey)
+				}
+			}
+			apiKeys = visibleAPIKeys
+		}
 		log.Printf("Found %d API keys for organization %s", len(apiKeys), orgID)
 	} else {
 		// Get API keys for all organizations the user has access to
@@ -108,7 +151,7 @@ func APIKeysHandler(c *gin.Context) {
 			// Filter API keys to only those from organizations the user has access to
 			var filteredAPIKeys []models.APIKey
 			for _, apiKey := range apiKeys {
-				if _, hasAccess := memberships[apiKey.OrganizationID]; hasAccess {
+				if _, hasAccess := memberships[apiKey.OrganizationID]; hasAccess && canSeeAPIKey(c, apiKey, userID) {
 					filteredAPIKeys = append(filteredAPIKeys, apiKey)
 				}
 			}
@@ -184,6 +227,10 @@ func CreateAPIKeyHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid form data"})
 		return
 	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	log.Printf("SUCCESS: Parsed request: %+v", req)
 
@@ -233,6 +280,48 @@ func CreateAPIKeyHandler(c *gin.Context) {
 		return
 	}
 
+	if !auth.OrgPermission(c, req.OrganizationID, models.PermissionKeysWrite) {
+		if !auth.OrgPermission(c, req.OrganizationID, models.PermissionKeysOwn) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required"})
+			return
+		}
+		if req.IsCanary {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required to create canary keys"})
+			return
+		}
+		if models.APIKeyAllows(req.Scopes, models.APIKeyScopeDebug) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required to grant the debug scope"})
+			return
+		}
+		// Self-service keys always belong to their creator
+		req.OwnerUserID = nil
+	}
+
+	// A key can only be owned by a member of its organization
+	if req.OwnerUserID != nil && *req.OwnerUserID != *req.UserID {
+		if _, err := db.GetUserOrganizationRole(sqlDB, *req.OwnerUserID, req.OrganizationID); err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Owner must be a member of the organization"})
+			return
+		} else if err != nil {
+			log.Printf("Failed to check API key owner membership: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate owner"})
+			return
+		}
+	}
+
+	// A key can only join a project of its organization
+	if req.ProjectID != nil {
+		project, err := db.GetProjectByID(sqlDB, *req.ProjectID)
+		if err == sql.ErrNoRows || (err == nil && project.OrganizationID != req.OrganizationID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found in the organization"})
+			return
+		} else if err != nil {
+			log.Printf("Failed to check API key project: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate project"})
+			return
+		}
+	}
+
 	// Create API key in database
 	log.Printf("Creating API key with request: %+v", req)
 	response, err := db.CreateAPIKey(sqlDB, req)
@@ -321,6 +410,11 @@ func DeleteAPIKeyHandler(c *gin.Context) {
 		return
 	}
 
+	if !canManageAPIKey(c, *targetAPIKey, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required"})
+		return
+	}
+
 	// Delete API key (soft delete)
 	err = db.DeleteAPIKey(sqlDB, keyID)
 	if err != nil {
@@ -342,6 +436,15 @@ func DeleteAPIKeyHandler(c *gin.Context) {
 			return
 		}
 		apiKeys, err = db.GetAPIKeysByOrganization(sqlDB, orgID)
+		if err == nil {
+			var visibleAPIKeys []models.APIKey
+			for _, apiKey := range apiKeys {
+				if canSeeAPIKey(c, apiKey, userID) {
+					visibleAPIKeys = append(visibleAPIKeys, apiKey)
+				}
+			}
+			apiKeys = visibleAPIKeys
+		}
 	} else {
 		// Get API keys for all organizations the user has access to
 		apiKeys, err = db.GetAPIKeysWithOrganizations(sqlDB)
@@ -349,7 +452,7 @@ func DeleteAPIKeyHandler(c *gin.Context) {
 			// Filter API keys to only those from organizations the user has access to
 			var filteredAPIKeys []models.APIKey
 			for _, apiKey := range apiKeys {
-				if _, hasAccess := memberships[apiKey.OrganizationID]; hasAccess {
+				if _, hasAccess := memberships[apiKey.OrganizationID]; hasAccess && canSeeAPIKey(c, apiKey, userID) {
 					filteredAPIKeys = append(filteredAPIKeys, apiKey)
 				}
 			}
@@ -442,8 +545,6 @@ func CompletionsProxyHandler(c *gin.Context) {
 	}
 	log.Printf("ProxyHandler: Incoming request: %+v", req)
 
-	fmt.Println()
-	// Lookup API key securely from DB using req.APIKeyID
 	database, exists := c.Get("db")
 	if !exists {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
@@ -454,11 +555,44 @@ func CompletionsProxyHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid database connection"})
 		return
 	}
-	apiKey, err := db.GetAPIKeyByID(sqlDB, req.APIKeyID)
-	if err != nil || apiKey == "" {
+	userID, _ := auth.GetUserContext(c)["id"].(string)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	// The gateway is called as the key through a signed service token; the raw key is never read
+	key, err := db.GetAPIKey(sqlDB, req.APIKeyID)
+	if err != nil || !canSeeAPIKey(c, *key, userID) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
 		return
 	}
+	if req.OrganizationID != "" && req.OrganizationID != key.OrganizationID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API key does not belong to the organization"})
+		return
+	}
+
+	// Only models the key's organization may use are proxied, so the browser can't pick another
+	if req.ModelID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model_id is required"})
+		return
+	}
+	allowed, err := db.OrganizationCanUseModel(sqlDB, key.OrganizationID, req.ModelID)
+	if err != nil {
+		log.Printf("ProxyHandler: Failed to check model access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check model access"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "The organization does not have access to this model"})
+		return
+	}
+	serviceToken, err := servicetoken.Sign(key.ID, key.OrganizationID, userID)
+	if err != nil {
+		log.Printf("ProxyHandler: Failed to sign service token: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Gateway calls need GATEWAY_INTERNAL_TOKEN set on the UI and gateway"})
+		return
+	}
 
 	// Build the request to the completions API
 	payload := map[string]interface{}{
@@ -468,65 +602,91 @@ func CompletionsProxyHandler(c *gin.Context) {
 	}
 	body, _ := json.Marshal(payload)
 	log.Printf("ProxyHandler: Upstream payload: %s", string(body))
-	providerURL := "http://localhost:8081/v1/chat/completions"
+	providerURL := gatewayURL() + "/v1/chat/completions"
 
-	httpReq, err := http.NewRequest("POST", providerURL, io.NopCloser(bytes.NewReader(body)))
+	// The upstream call follows the browser's request, so closing the tab ends the generation
+	httpReq, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, providerURL, bytes.NewReader(body))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build upstream request"})
 		return
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	httpReq.Header.Set("Authorization", "Bearer "+serviceToken)
+	if req.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
 
 	resp, err := http.DefaultClient.Do(httpReq)
 	if err != nil {
+		if c.Request.Context().Err() != nil {
+			return
+		}
 		c.JSON(http.StatusBadGateway, gin.H{"error": "Upstream provider error"})
 		return
 	}
 	defer resp.Body.Close()
 
-	// Handle streaming vs non-streaming responses
-	if req.Stream {
-		// Copy all headers from upstream response
-		for k, v := range resp.Header {
-			for _, vv := range v {
-				c.Writer.Header().Add(k, vv)
-			}
+	relayCompletion(c, resp, req.Stream)
+}
+
+// proxyHopByHopHeaders describe the connection to the gateway and aren't forwarded (RFC 9110)
+var proxyHopByHopHeaders = map[string]bool{
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Te":                true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// relayCompletion writes the gateway's response to the browser, headers first. A successful
+// streamed completion is sent as an event stream, flushed chunk by chunk and marked so proxies
+// in front of the UI don't buffer it; errors are forwarded as they came.
+func relayCompletion(c *gin.Context, resp *http.Response, stream bool) {
+	streaming := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") ||
+		(stream && resp.StatusCode == http.StatusOK)
+
+	for name, values := range resp.Header {
+		if proxyHopByHopHeaders[name] || (streaming && name == "Content-Length") {
+			continue
+		}
+		for _, v := range values {
+			c.Writer.Header().Add(name, v)
 		}
+	}
+
+	if !streaming {
 		c.Status(resp.StatusCode)
+		if _, err := io.Copy(c.Writer, resp.Body); err != nil {
+			log.Printf("ProxyHandler: Error relaying response: %v", err)
+		}
+		return
+	}
 
-		// Stream response with proper flushing for real-time delivery
-		buffer := make([]byte, 1024)
-		for {
-			n, err := resp.Body.Read(buffer)
-			if n > 0 {
-				if _, writeErr := c.Writer.Write(buffer[:n]); writeErr != nil {
-					log.Printf("ProxyHandler: Error writing streaming chunk: %v", writeErr)
-					return
-				}
-				// Flush immediately to ensure real-time streaming
-				if flusher, ok := c.Writer.(http.Flusher); ok {
-					flusher.Flush()
-				}
-			}
-			if err != nil {
-				if err == io.EOF {
-					log.Printf("ProxyHandler: Streaming completed")
-					break
-				}
-				log.Printf("ProxyHandler: Error reading streaming response: %v", err)
-				break
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(resp.StatusCode)
+	c.Writer.Flush()
+
+	buffer := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buffer)
+		if n > 0 {
+			if _, writeErr := c.Writer.Write(buffer[:n]); writeErr != nil {
+				log.Printf("ProxyHandler: Browser went away mid-stream: %v", writeErr)
+				return
 			}
+			c.Writer.Flush()
 		}
-	} else {
-		// Forward non-streaming response
-		c.Status(resp.StatusCode)
-		for k, v := range resp.Header {
-			for _, vv := range v {
-				c.Writer.Header().Add(k, vv)
+		if err != nil {
+			// A cancelled request context is the browser disconnecting, not an upstream failure
+			if err != io.EOF && c.Request.Context().Err() == nil {
+				log.Printf("ProxyHandler: Error reading streaming response: %v", err)
 			}
+			return
 		}
-		io.Copy(c.Writer, resp.Body)
 	}
 }
 
@@ -706,6 +866,11 @@ func RegenerateAPIKeyHandler(c *gin.Context) {
 		return
 	}
 
+	if !canManageAPIKey(c, *targetAPIKey, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required"})
+		return
+	}
+
 	// Regenerate the API key
 	log.Printf("Regenerating API key %s for user %s", keyID, userID)
 	response, err := db.RegenerateAPIKey(sqlDB, keyID)
@@ -726,3 +891,159 @@ func RegenerateAPIKeyHandler(c *gin.Context) {
 		"keyId":   response.APIKey.ID,
 	})
 }
+
+// TransferAPIKeyOwnerHandler hands an API key to another member of its organization; requires
+// keys:write in the key's organization and is audited
+func TransferAPIKeyOwnerHandler(c *gin.Context) {
+	var req models.TransferAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+	actorID, _ := auth.GetUserContext(c)["id"].(string)
+	if actorID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	key, err := db.GetAPIKey(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API key"})
+		return
+	}
+
+	if !auth.OrgPermission(c, key.OrganizationID, models.PermissionKeysWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required"})
+		return
+	}
+
+	ownerID := req.OwnerUserID
+	if req.OwnerEmail != "" {
+		owner, err := db.GetUserByEmail(sqlDB, req.OwnerEmail)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No active user with that email"})
+			return
+		} else if err != nil {
+			log.Printf("Failed to get user by email: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+			return
+		}
+		ownerID = owner.ID
+	}
+
+	if _, ok := membershipRole(c, sqlDB, ownerID, key.OrganizationID); !ok {
+		return
+	}
+
+	if err := db.TransferAPIKeyOwner(sqlDB, key.ID, ownerID); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to transfer API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer API key"})
+		return
+	}
+
+	var previousOwner interface{}
+	if key.OwnerUserID != nil {
+		previousOwner = *key.OwnerUserID
+	}
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionAPIKeyTransfer, "api_key", key.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": key.OrganizationID, "from_user_id": previousOwner, "to_user_id": ownerID}); err != nil {
+		log.Printf("Failed to write audit log for API key transfer: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "owner_user_id": ownerID})
+}
+
+// APIKeyUsageHandler returns a usage drill-down for one key: totals, a time series and top
+// models. Members can see keys they own or created; anyone else needs analytics:read in the
+// key's organization.
+func APIKeyUsageHandler(c *gin.Context) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+	userID, _ := auth.GetUserContext(c)["id"].(string)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	key, err := db.GetAPIKey(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API key"})
+		return
+	}
+
+	if !auth.OrgPermission(c, key.OrganizationID, models.PermissionAnalyticsRead) {
+		ownKey := (key.OwnerUserID != nil && *key.OwnerUserID == userID) || (key.UserID != nil && *key.UserID == userID)
+		if !ownKey {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+			return
+		}
+		// Owning a key doesn't outlast leaving its organization
+		if _, err := db.GetUserOrganizationRole(sqlDB, userID, key.OrganizationID); err == sql.ErrNoRows {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+			return
+		} else if err != nil {
+			log.Printf("Failed to get organization role: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+			return
+		}
+	}
+
+	filter := models.AnalyticsFilter{
+		TimeRange:    c.DefaultQuery("range", "7d"),
+		StartDate:    c.Query("start_date"),
+		Organization: key.OrganizationID,
+	}
+	if filter.TimeRange == "custom" {
+		if _, err := time.Parse("2006-01-02", filter.StartDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date (YYYY-MM-DD) is required for a custom range"})
+			return
+		}
+	}
+
+	usage, err := db.GetAPIKeyUsage(sqlDB, key, filter, 5)
+	if err != nil {
+		log.Printf("Failed to get API key usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API key usage"})
+		return
+	}
+	if !auth.OrgPermission(c, key.OrganizationID, models.PermissionCostsRead) {
+		usage.HideCosts()
+	}
+	usage.ConvertCosts(displayRate(sqlDB, key.OrganizationID))
+
+	c.JSON(http.StatusOK, usage)
+}
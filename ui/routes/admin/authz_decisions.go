@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// AuthzDecisionsHandler lists the gateway's logged authorization decisions, newest first, by
+// organization, key, outcome and date range; requires System Admin
+func AuthzDecisionsHandler(c *gin.Context) {
+	var filter models.AuthzDecisionFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return
+	}
+	if err := filter.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	decisions, err := db.GetAuthzDecisions(sqlDB, filter)
+	if err != nil {
+		log.Printf("Failed to get authorization decisions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load authorization decisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"decisions": decisions, "limit": filter.Limit})
+}
@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// ThemeCSSHandler renders theme.css, applying the active organization's branding over the
+// global theme. The organization comes from ?org_id= or the org selector's cookie.
+func ThemeCSSHandler(c *gin.Context) {
+	userData := auth.GetUserContext(c)
+
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		orgID, _ = c.Cookie(auth.SelectedOrgCookie)
+	}
+
+	database, _ := c.Get("db")
+	if theme, ok := userData["Theme"].(*models.Theme); ok && orgID != "" {
+		if sqlDB, ok := database.(*sql.DB); ok {
+			branding, err := db.GetOrganizationBranding(sqlDB, orgID)
+			if err != nil {
+				log.Printf("Failed to get branding for org %s: %v", orgID, err)
+			} else if branding != nil {
+				branded := branding.ApplyTo(*theme)
+				userData["Theme"] = &branded
+			}
+		}
+	}
+
+	c.Header("Content-Type", "text/css")
+	c.HTML(http.StatusOK, "theme.css", userData)
+}
+
+// GetOrganizationBrandingHandler returns the organization's branding overrides
+func GetOrganizationBrandingHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := orgAccess(c, c.Query("org_id"))
+	if !ok {
+		return
+	}
+
+	branding, err := db.GetOrganizationBranding(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization branding: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organization branding"})
+		return
+	}
+	if branding == nil {
+		branding = &models.OrganizationBranding{OrganizationID: orgID}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"branding": branding,
+	})
+}
+
+// UpdateOrganizationBrandingHandler sets the logo, colors and from-name; requires the org admin role
+func UpdateOrganizationBrandingHandler(c *gin.Context) {
+	var req models.UpdateOrganizationBrandingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind organization branding request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, orgID, role, ok := orgAccess(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return
+	}
+
+	req.OrganizationID = orgID
+	branding, err := db.UpsertOrganizationBranding(sqlDB, req)
+	if err != nil {
+		log.Printf("Failed to update organization branding: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization branding"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"branding": branding,
+		"message":  "Organization branding updated successfully",
+	})
+}
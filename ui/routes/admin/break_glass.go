@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// BreakGlassStatusHandler reports whether the break-glass account is provisioned, whether the
+// policy allows it and when it was last used; requires System Admin
+func BreakGlassStatusHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	account, err := db.GetBreakGlassAccount(sqlDB)
+	if err != nil {
+		log.Printf("Failed to load break-glass account: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load break-glass account"})
+		return
+	}
+	account.Configured = auth.BreakGlassConfigured()
+
+	c.JSON(http.StatusOK, gin.H{"break_glass": account})
+}
+
+// SetBreakGlassHandler allows or disallows the break-glass account by policy; disallowing it
+// also signs it out. Requires System Admin and is audited.
+func SetBreakGlassHandler(c *gin.Context) {
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+		return
+	}
+
+	if err := db.SetBreakGlassEnabled(sqlDB, *req.Enabled, userID); err != nil {
+		log.Printf("Failed to set break-glass policy: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update break-glass policy"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionBreakGlassPolicy, "system", "break_glass", c.ClientIP(),
+		map[string]interface{}{"enabled": *req.Enabled}); err != nil {
+		log.Printf("Failed to write audit log for break-glass policy change: %v", err)
+	}
+
+	log.Printf("User %s set the break-glass account to enabled=%t", userID, *req.Enabled)
+	c.JSON(http.StatusOK, gin.H{"enabled": *req.Enabled})
+}
@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// canaryHitsLimit caps the requests listed for a canary key
+const canaryHitsLimit = 100
+
+// CanaryHitsHandler lists the latest requests made with a canary key; requires keys:read in
+// the key's organization
+func CanaryHitsHandler(c *gin.Context) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	key, err := db.GetAPIKey(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows || (err == nil && !key.IsCanary) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Canary key not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API key"})
+		return
+	}
+
+	if !auth.OrgPermission(c, key.OrganizationID, models.PermissionKeysRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:read required"})
+		return
+	}
+
+	hits, err := db.GetCanaryHits(sqlDB, key.ID, canaryHitsLimit)
+	if err != nil {
+		log.Printf("Failed to get canary key hits: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load canary key hits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hits": hits})
+}
+
+// BlockedIPsHandler lists the addresses the gateway refuses; requires System Admin
+func BlockedIPsHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	blocked, err := db.GetBlockedIPs(sqlDB)
+	if err != nil {
+		log.Printf("Failed to get blocked IPs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load blocked IPs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocked_ips": blocked})
+}
+
+// UnblockIPHandler removes an address from the block list; requires System Admin and is
+// audited. Gateways pick the change up within a minute.
+func UnblockIPHandler(c *gin.Context) {
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	ip := c.Param("ip")
+	if net.ParseIP(ip) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address"})
+		return
+	}
+
+	if err := db.UnblockIP(sqlDB, ip); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "IP address is not blocked"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to unblock %s: %v", ip, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock IP address"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionIPUnblock, "blocked_ip", ip, c.ClientIP(), nil); err != nil {
+		log.Printf("Failed to write audit log for unblocking %s: %v", ip, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "IP address unblocked"})
+}
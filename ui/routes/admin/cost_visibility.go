@@ -0,0 +1,63 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetOrganizationCostVisibilityHandler reports whether the organization hides model prices and
+// spend from its non-admin members; requires admin of the organization or System Admin
+func GetOrganizationCostVisibilityHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	hide, err := db.GetOrganizationHideCosts(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization cost visibility: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load cost visibility"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization_id": orgID, "hide_costs": hide})
+}
+
+// UpdateOrganizationCostVisibilityHandler switches non-admin members between the full view and a
+// tokens-only view of models and analytics; requires admin of the organization or System Admin
+// and is audited
+func UpdateOrganizationCostVisibilityHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateCostVisibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if err := db.UpdateOrganizationHideCosts(sqlDB, orgID, req.HideCosts); err != nil {
+		log.Printf("Failed to update organization cost visibility: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cost visibility"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionCostVisibility, "organization", orgID, c.ClientIP(),
+		map[string]interface{}{"hide_costs": req.HideCosts}); err != nil {
+		log.Printf("Failed to write audit log for cost visibility change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization_id": orgID,
+		"hide_costs":      req.HideCosts,
+		"message":         "Cost visibility updated successfully",
+	})
+}
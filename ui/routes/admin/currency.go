@@ -0,0 +1,154 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// displayRate returns the rate an organization's reports are converted at. Reports across every
+// organization, and organizations whose currency has no rate yet, stay in US dollars.
+func displayRate(sqlDB *sql.DB, orgID string) models.FXRate {
+	if orgID == "" {
+		return models.BaseRate()
+	}
+	currency, err := db.GetOrganizationCurrency(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization currency, reporting in USD: %v", err)
+		return models.BaseRate()
+	}
+	if currency == models.BaseCurrency {
+		return models.BaseRate()
+	}
+	rate, err := db.GetFXRate(sqlDB, currency)
+	if err != nil {
+		log.Printf("No exchange rate for %s, reporting in USD: %v", currency, err)
+		return models.BaseRate()
+	}
+	return *rate
+}
+
+// GetOrganizationCurrencyHandler reports the currency the organization's analytics are shown in;
+// requires admin of the organization or System Admin
+func GetOrganizationCurrencyHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	currency, err := db.GetOrganizationCurrency(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization currency: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load currency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization_id": orgID, "currency": currency, "fx_rate": displayRate(sqlDB, orgID)})
+}
+
+// UpdateOrganizationCurrencyHandler sets the currency the organization's analytics are shown in.
+// Costs stay stored in US dollars. Requires admin of the organization or System Admin and is
+// audited.
+func UpdateOrganizationCurrencyHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateCurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency is required"})
+		return
+	}
+	currency, err := models.NormalizeCurrencyCode(req.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if currency != models.BaseCurrency {
+		if _, err := db.GetFXRate(sqlDB, currency); err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No exchange rate for " + currency})
+			return
+		} else if err != nil {
+			log.Printf("Failed to get exchange rate: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load exchange rate"})
+			return
+		}
+	}
+
+	if err := db.UpdateOrganizationCurrency(sqlDB, orgID, currency); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to update organization currency: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update currency"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionCurrency, "organization", orgID, c.ClientIP(),
+		map[string]interface{}{"currency": currency}); err != nil {
+		log.Printf("Failed to write audit log for currency change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization_id": orgID,
+		"currency":        currency,
+		"message":         "Currency updated successfully",
+	})
+}
+
+// FXRatesHandler lists the latest exchange rates; requires System Admin
+func FXRatesHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	rates, err := db.ListFXRates(sqlDB)
+	if err != nil {
+		log.Printf("Failed to list exchange rates: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"base_currency": models.BaseCurrency, "rates": rates})
+}
+
+// SetFXRateHandler sets a currency's rate by hand until the next fetch replaces it; requires
+// System Admin and is audited
+func SetFXRateHandler(c *gin.Context) {
+	sqlDB, actorID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	currency, err := models.NormalizeCurrencyCode(c.Param("currency"))
+	if err != nil || currency == models.BaseCurrency {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a three-letter ISO 4217 code other than USD"})
+		return
+	}
+	var req models.SetFXRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RatePerUSD <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rate_per_usd must be positive"})
+		return
+	}
+
+	if err := db.SaveFXRates(sqlDB, map[string]float64{currency: req.RatePerUSD}, "manual"); err != nil {
+		log.Printf("Failed to save exchange rate: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save exchange rate"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionFXRate, "currency", currency, c.ClientIP(),
+		map[string]interface{}{"rate_per_usd": req.RatePerUSD}); err != nil {
+		log.Printf("Failed to write audit log for exchange rate change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"currency": currency, "rate_per_usd": req.RatePerUSD})
+}
@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// dkimKeyResponse adds the DNS record to publish for a key
+func dkimKeyResponse(key *models.DKIMKey) gin.H {
+	return gin.H{
+		"key":              key,
+		"dns_record_name":  key.DNSRecordName(),
+		"dns_record_value": key.DNSRecordValue(),
+	}
+}
+
+// DKIMKeysHandler lists the DKIM keys with their DNS records; requires System Admin
+func DKIMKeysHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	keys, err := db.GetDKIMKeys(sqlDB)
+	if err != nil {
+		log.Printf("Failed to get DKIM keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load DKIM keys"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(keys))
+	for i := range keys {
+		result = append(result, dkimKeyResponse(&keys[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"dkim_keys": result})
+}
+
+// CreateDKIMKeyHandler imports or generates a domain's DKIM key, replacing any existing one;
+// requires System Admin and is audited
+func CreateDKIMKeyHandler(c *gin.Context) {
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateDKIMKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	privatePEM := req.PrivateKey
+	var publicKey string
+	var err error
+	if privatePEM == "" {
+		privatePEM, publicKey, err = email.GenerateDKIMKey()
+		if err != nil {
+			log.Printf("Failed to generate DKIM key: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate DKIM key"})
+			return
+		}
+	} else if publicKey, err = email.DKIMPublicKey(privatePEM); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sealed, err := encryption.Encrypt(privatePEM)
+	if err == encryption.ErrNoKey {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ENCRYPTION_KEY must be set to store DKIM keys"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to encrypt DKIM key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt DKIM key"})
+		return
+	}
+
+	key, err := db.UpsertDKIMKey(sqlDB, req.Domain, req.Selector, sealed, publicKey)
+	if err != nil {
+		log.Printf("Failed to save DKIM key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save DKIM key"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionDKIMKeyCreate, "dkim_key", key.ID, c.ClientIP(),
+		map[string]interface{}{"domain": key.Domain, "selector": key.Selector, "imported": req.PrivateKey != ""}); err != nil {
+		log.Printf("Failed to write audit log for DKIM key: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, dkimKeyResponse(key))
+}
+
+// DeleteDKIMKeyHandler removes a DKIM key; requires System Admin and is audited
+func DeleteDKIMKeyHandler(c *gin.Context) {
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	key, err := db.DeleteDKIMKey(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "DKIM key not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete DKIM key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete DKIM key"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionDKIMKeyDelete, "dkim_key", key.ID, c.ClientIP(),
+		map[string]interface{}{"domain": key.Domain, "selector": key.Selector}); err != nil {
+		log.Printf("Failed to write audit log for DKIM key: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// EmailLogsHandler searches the email logs, newest first, by recipient, status, template and
+// date range, a page at a time; requires System Admin
+func EmailLogsHandler(c *gin.Context) {
+	var search models.EmailLogSearch
+	if err := c.ShouldBindQuery(&search); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return
+	}
+	if err := search.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if search.TemplateID != "" {
+		if _, err := uuid.Parse(search.TemplateID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+			return
+		}
+	}
+
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	logs, total, err := db.SearchEmailLogs(sqlDB, search)
+	if err != nil {
+		log.Printf("Failed to search email logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load email logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":      logs,
+		"total":     total,
+		"page":      search.Page,
+		"page_size": search.PageSize,
+	})
+}
+
+// ResendEmailHandler queues a failed email to be sent again; requires System Admin and is audited
+func ResendEmailHandler(c *gin.Context) {
+	logID := c.Param("id")
+	if _, err := uuid.Parse(logID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email log not found"})
+		return
+	}
+
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	entry, err := email.NewService(sqlDB).ResendEmail(logID)
+	switch {
+	case err == sql.ErrNoRows:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email log not found"})
+		return
+	case errors.Is(err, email.ErrNotFailed), errors.Is(err, email.ErrNotResendable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Failed to resend email %s: %v", logID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resend email"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionEmailResend, "email_log", entry.ID, c.ClientIP(),
+		map[string]interface{}{"recipient": entry.RecipientEmail, "outbox_id": *entry.OutboxID}); err != nil {
+		log.Printf("Failed to write audit log for email resend: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Email queued for resending", "outbox_id": *entry.OutboxID})
+}
+
+// PurgeEmailLogsHandler removes the email logs created before the given date or timestamp, and
+// the outbox messages that finished sending by then; requires System Admin and is audited
+func PurgeEmailLogsHandler(c *gin.Context) {
+	// Parsed like from, so a date purges everything before its start
+	search := models.EmailLogSearch{From: c.Query("before")}
+	if search.From == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "before is required"})
+		return
+	}
+	if err := search.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "before must be a date or an RFC 3339 timestamp"})
+		return
+	}
+	if search.FromTime.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "before can't be in the future"})
+		return
+	}
+
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	removed, err := db.DeleteEmailLogsBefore(sqlDB, *search.FromTime)
+	if err != nil {
+		log.Printf("Failed to purge email logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge email logs"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionEmailLogsPurge, "email_log", "", c.ClientIP(),
+		map[string]interface{}{"before": search.FromTime.Format(time.RFC3339), "removed": removed}); err != nil {
+		log.Printf("Failed to write audit log for email log purge: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
@@ -0,0 +1,174 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// emailServiceFromContext builds an email service from the request's database connection
+func emailServiceFromContext(c *gin.Context) (*email.Service, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, false
+	}
+
+	return email.NewService(sqlDB), true
+}
+
+// EmailTemplateVersionsHandler lists a template's version history
+func EmailTemplateVersionsHandler(c *gin.Context) {
+	emailService, ok := emailServiceFromContext(c)
+	if !ok {
+		return
+	}
+
+	versions, err := emailService.GetEmailTemplateVersions(c.Param("id"))
+	if err != nil {
+		log.Printf("Failed to get email template versions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load template versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// EmailTemplateVersionPreviewHandler renders a version with sample data, e.g. to preview a draft
+func EmailTemplateVersionPreviewHandler(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	emailService, ok := emailServiceFromContext(c)
+	if !ok {
+		return
+	}
+
+	subject, htmlBody, err := emailService.PreviewEmailTemplateVersion(c.Param("id"), version)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template preview failed: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subject":   subject,
+		"html_body": htmlBody,
+	})
+}
+
+// EmailTemplateDiffHandler returns line diffs between two versions (?from=&to=)
+func EmailTemplateDiffHandler(c *gin.Context) {
+	from, fromErr := strconv.Atoi(c.Query("from"))
+	to, toErr := strconv.Atoi(c.Query("to"))
+	if fromErr != nil || toErr != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to versions are required"})
+		return
+	}
+
+	emailService, ok := emailServiceFromContext(c)
+	if !ok {
+		return
+	}
+
+	diff, err := emailService.DiffEmailTemplateVersions(c.Param("id"), from, to)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to diff email template versions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to diff template versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"diff": diff})
+}
+
+// EmailTemplatePublishHandler publishes the template's draft
+func EmailTemplatePublishHandler(c *gin.Context) {
+	emailService, ok := emailServiceFromContext(c)
+	if !ok {
+		return
+	}
+
+	template, err := emailService.PublishEmailTemplateDraft(c.Param("id"))
+	if err == email.ErrNoDraft || err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No draft to publish"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to publish email template draft: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish draft"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "template": template})
+}
+
+// EmailTemplateDiscardDraftHandler deletes the template's draft
+func EmailTemplateDiscardDraftHandler(c *gin.Context) {
+	emailService, ok := emailServiceFromContext(c)
+	if !ok {
+		return
+	}
+
+	err := emailService.DiscardEmailTemplateDraft(c.Param("id"))
+	if err == email.ErrNoDraft {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No draft to discard"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to discard email template draft: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discard draft"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// EmailTemplateRollbackHandler republishes a previous version
+func EmailTemplateRollbackHandler(c *gin.Context) {
+	var req models.RollbackEmailTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	emailService, ok := emailServiceFromContext(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := auth.GetUserContext(c)["id"].(string)
+	template, err := emailService.RollbackEmailTemplate(c.Param("id"), req.Version, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to roll back email template: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "template": template})
+}
@@ -0,0 +1,287 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// ExperimentsHandler lists experiments for the user's organizations
+func ExperimentsHandler(c *gin.Context) {
+	// Get database connection from context
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return
+	}
+
+	// Optionally filter by organization
+	var orgIDs []string
+	if orgID := c.Query("org_id"); orgID != "" {
+		if _, hasAccess := memberships[orgID]; !hasAccess {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+			return
+		}
+		orgIDs = []string{orgID}
+	} else {
+		for orgID := range memberships {
+			orgIDs = append(orgIDs, orgID)
+		}
+	}
+
+	experiments, err := db.GetExperimentsByOrganization(sqlDB, orgIDs)
+	if err != nil {
+		log.Printf("Failed to get experiments: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load experiments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"experiments": experiments,
+	})
+}
+
+// CreateExperimentHandler creates a draft experiment with its variants
+func CreateExperimentHandler(c *gin.Context) {
+	// Get database connection from context
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	var req models.CreateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind experiment request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return
+	}
+
+	if _, hasAccess := memberships[req.OrganizationID]; !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+		return
+	}
+
+	experiment, err := db.CreateExperiment(sqlDB, req, &userID)
+	if err != nil {
+		log.Printf("Failed to create experiment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create experiment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"experiment": experiment,
+		"message":    "Experiment created successfully",
+	})
+}
+
+// GetExperimentHandler returns a single experiment with its variants
+func GetExperimentHandler(c *gin.Context) {
+	_, experiment, ok := loadExperimentForUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"experiment": experiment,
+	})
+}
+
+// UpdateExperimentHandler updates an experiment; setting status to "running" starts traffic splitting
+func UpdateExperimentHandler(c *gin.Context) {
+	sqlDB, experiment, ok := loadExperimentForUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind experiment update request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if req.Status != nil && !models.IsValidExperimentStatus(*req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment status"})
+		return
+	}
+
+	// Only one experiment may run per endpoint
+	if req.Status != nil && *req.Status == models.ExperimentStatusRunning && experiment.Status != models.ExperimentStatusRunning {
+		running, err := db.GetRunningExperimentForEndpoint(sqlDB, experiment.OrganizationID, experiment.Endpoint)
+		if err == nil && running.ID != experiment.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another experiment is already running on this endpoint"})
+			return
+		}
+	}
+
+	if req.WinnerVariantID != nil {
+		found := false
+		for _, v := range experiment.Variants {
+			if v.ID == *req.WinnerVariantID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Winner must be one of the experiment's variants"})
+			return
+		}
+	}
+
+	updated, err := db.UpdateExperiment(sqlDB, experiment.ID, req)
+	if err != nil {
+		log.Printf("Failed to update experiment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update experiment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"experiment": updated,
+		"message":    "Experiment updated successfully",
+	})
+}
+
+// DeleteExperimentHandler deletes an experiment
+func DeleteExperimentHandler(c *gin.Context) {
+	sqlDB, experiment, ok := loadExperimentForUser(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteExperiment(sqlDB, experiment.ID); err != nil {
+		log.Printf("Failed to delete experiment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete experiment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Experiment deleted successfully",
+	})
+}
+
+// ExperimentReportHandler returns the per-variant comparison report
+func ExperimentReportHandler(c *gin.Context) {
+	sqlDB, experiment, ok := loadExperimentForUser(c)
+	if !ok {
+		return
+	}
+
+	report, err := db.GetExperimentReport(sqlDB, experiment.ID)
+	if err != nil {
+		log.Printf("Failed to build experiment report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build experiment report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// loadExperimentForUser loads the experiment from the :id parameter and checks the user
+// belongs to its organization. It writes the error response itself and returns ok=false on failure.
+func loadExperimentForUser(c *gin.Context) (*sql.DB, *models.Experiment, bool) {
+	// Get database connection from context
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, false
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return nil, nil, false
+	}
+
+	experimentID := c.Param("id")
+	if experimentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Experiment ID is required"})
+		return nil, nil, false
+	}
+
+	experiment, err := db.GetExperimentByID(sqlDB, experimentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to get experiment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load experiment"})
+		return nil, nil, false
+	}
+
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return nil, nil, false
+	}
+
+	if _, hasAccess := memberships[experiment.OrganizationID]; !hasAccess {
+		log.Printf("User %s denied access to experiment %s", userID, experimentID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+		return nil, nil, false
+	}
+
+	return sqlDB, experiment, true
+}
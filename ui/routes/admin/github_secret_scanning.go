@@ -0,0 +1,104 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/githubscan"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+)
+
+// maxSecretScanningBody caps an alert; GitHub batches a few hundred matches at most
+const maxSecretScanningBody = 1 << 20
+
+var githubVerifier = githubscan.NewVerifier()
+
+// GitHubSecretScanningHandler receives leaked-key alerts from GitHub's secret scanning partner
+// program. Alerts must carry a valid GitHub signature. Each reported gateway key is revoked and
+// its organization's admins are notified; the response labels every token so GitHub can tell
+// the repository owner whether it was live.
+func GitHubSecretScanningHandler(c *gin.Context) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+	sqlDB := database.(*sql.DB)
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSecretScanningBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	err = githubVerifier.Verify(body, c.GetHeader(githubscan.KeyIdentifierHeader), c.GetHeader(githubscan.SignatureHeader))
+	if errors.Is(err, githubscan.ErrInvalidSignature) {
+		log.Printf("Rejected GitHub secret scanning alert from %s: %v", c.ClientIP(), err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to verify GitHub secret scanning alert: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify signature"})
+		return
+	}
+
+	var matches []githubscan.Match
+	if err := json.Unmarshal(body, &matches); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert payload"})
+		return
+	}
+
+	// Keys can't be revoked during a maintenance window; GitHub retries failed deliveries
+	if readonly.Enabled() {
+		c.Header("Retry-After", "300")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The gateway is in read-only mode for maintenance"})
+		return
+	}
+
+	emailService := email.NewService(sqlDB)
+	results := make([]githubscan.Result, 0, len(matches))
+	for _, match := range matches {
+		result := githubscan.Result{TokenRaw: match.Token, TokenType: match.Type, Label: githubscan.LabelFalsePositive}
+		if !strings.HasPrefix(match.Token, "sk-") {
+			results = append(results, result)
+			continue
+		}
+
+		key, revoked, err := db.RevokeLeakedAPIKey(sqlDB, match.Token)
+		if err == sql.ErrNoRows {
+			results = append(results, result)
+			continue
+		} else if err != nil {
+			log.Printf("Failed to revoke leaked API key reported by GitHub: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process alert"})
+			return
+		}
+		result.Label = githubscan.LabelTruePositive
+		results = append(results, result)
+
+		if !revoked {
+			continue
+		}
+		log.Printf("Revoked API key %s of organization %s: GitHub found it at %s", key.ID, key.OrganizationID, match.URL)
+		if err := db.CreateAuditLog(sqlDB, "", models.AuditActionAPIKeyLeakRevoke, "api_key", key.ID, c.ClientIP(),
+			map[string]interface{}{"source": "github", "url": match.URL, "organization_id": key.OrganizationID}); err != nil {
+			log.Printf("Failed to write audit log for leaked key %s: %v", key.ID, err)
+		}
+		go func(key *models.APIKey, url string) {
+			if err := emailService.NotifyAPIKeyLeak(key, url); err != nil {
+				log.Printf("Failed to notify admins of leaked key %s: %v", key.ID, err)
+			}
+		}(key, match.URL)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
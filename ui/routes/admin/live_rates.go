@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/usage"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// rateFetchTimeout bounds each gateway's answer so one slow replica doesn't stall the gauges
+const rateFetchTimeout = 2 * time.Second
+
+// LiveRatesHandler returns current requests and tokens per minute, per organization and per
+// model, over sliding one- and five-minute windows. The counters come from the gateway running
+// in this process (combined mode) and from each gateway listed in GATEWAY_RATES_URLS.
+func LiveRatesHandler(c *gin.Context) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	orgID := c.Query("org_id")
+	if !auth.OrgPermission(c, orgID, models.PermissionAnalyticsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission analytics:read required"})
+		return
+	}
+
+	var orgIDs []string
+	if orgID != "" {
+		orgIDs = []string{orgID}
+		if c.Query("include_children") == "true" {
+			subtree, err := db.GetOrganizationSubtreeIDs(sqlDB, orgID)
+			if err != nil {
+				log.Printf("Failed to get organization subtree: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organizations"})
+				return
+			}
+			orgIDs = subtree
+		}
+	}
+
+	snapshots := gatewayRateSnapshots()
+	live := models.BuildLiveRates(models.MergeRateSnapshots(snapshots), orgIDs, rateWarningPercent(), time.Now())
+	live.Sources = len(snapshots)
+
+	orgNames, modelNames, err := db.GetRateGaugeNames(sqlDB)
+	if err != nil {
+		log.Printf("Failed to get rate gauge names: %v", err)
+	}
+	for i := range live.Organizations {
+		live.Organizations[i].Name = orgNames[live.Organizations[i].ID]
+	}
+	for i := range live.Models {
+		live.Models[i].Name = modelNames[live.Models[i].ID]
+	}
+
+	c.JSON(http.StatusOK, live)
+}
+
+// gatewayRateSnapshots collects the counters of every gateway it can reach. Unreachable gateways
+// are logged and left out, so the gauges show what is known rather than failing.
+func gatewayRateSnapshots() []models.RateSnapshot {
+	var snapshots []models.RateSnapshot
+	if usage.GetGlobalUsageTracker() != nil {
+		snapshots = append(snapshots, usage.Rates.Snapshot(time.Now()))
+	}
+
+	token := os.Getenv("GATEWAY_INTERNAL_TOKEN")
+	for _, baseURL := range gatewayInternalURLs() {
+		snapshot, err := fetchRateSnapshot(baseURL+"/internal/rates", token)
+		if err != nil {
+			log.Printf("Failed to fetch live rates from %s: %v", baseURL, err)
+			continue
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+	return snapshots
+}
+
+// gatewayInternalURLs returns the base URLs of the gateways listed in GATEWAY_RATES_URLS, whose
+// /internal routes the UI calls
+func gatewayInternalURLs() []string {
+	var urls []string
+	for _, baseURL := range strings.Split(os.Getenv("GATEWAY_RATES_URLS"), ",") {
+		if baseURL = strings.TrimSpace(baseURL); baseURL != "" {
+			urls = append(urls, strings.TrimSuffix(baseURL, "/"))
+		}
+	}
+	return urls
+}
+
+// gatewayURL returns the base URL of the gateway the UI sends test calls to, from GATEWAY_URL.
+// Combined mode sets it to the gateway in the same process.
+func gatewayURL() string {
+	if baseURL := strings.TrimSpace(os.Getenv("GATEWAY_URL")); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return "http://localhost:8081"
+}
+
+func fetchRateSnapshot(url, token string) (*models.RateSnapshot, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := (&http.Client{Timeout: rateFetchTimeout}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var snapshot models.RateSnapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// rateWarningPercent is how close to a provider's limit a model gets before its gauge warns,
+// from GATEWAY_RATE_WARNING_PERCENT (default 80)
+func rateWarningPercent() float64 {
+	if pct, err := strconv.ParseFloat(os.Getenv("GATEWAY_RATE_WARNING_PERCENT"), 64); err == nil && pct > 0 {
+		return pct
+	}
+	return 80
+}
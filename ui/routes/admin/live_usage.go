@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/usage"
+	"github.com/like-mike/relai-gateway/ui/auth"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// livePushInterval is how often a dashboard is pushed what changed; deltas in between are
+	// added up so a busy organization doesn't flood the browser
+	livePushInterval = time.Second
+
+	// Reconnect delays of a gateway's usage stream, doubling up to the maximum
+	liveRetryDelay    = 2 * time.Second
+	liveMaxRetryDelay = time.Minute
+
+	// maxDeltaBytes caps a line of a gateway's usage stream
+	maxDeltaBytes = 1 << 20
+)
+
+// dashboardUsage carries the usage of every gateway this UI hears from to its dashboards
+var dashboardUsage = usage.NewLiveUsage()
+
+// StartLiveUsage relays recorded usage to the dashboards' WebSockets: from the gateway running in
+// this process (combined mode) and from the usage stream of each gateway listed in
+// GATEWAY_RATES_URLS. The returned func stops it.
+func StartLiveUsage() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	local, unsubscribe := usage.Live.Subscribe()
+	go func() {
+		for delta := range local {
+			dashboardUsage.Publish(delta)
+		}
+	}()
+
+	token := os.Getenv("GATEWAY_INTERNAL_TOKEN")
+	for _, baseURL := range gatewayInternalURLs() {
+		go relayGatewayUsage(ctx, baseURL+"/internal/usage/stream", token)
+	}
+
+	return func() {
+		cancel()
+		unsubscribe()
+	}
+}
+
+// relayGatewayUsage follows a gateway's usage stream until ctx is done, reconnecting with backoff
+// when the gateway restarts or can't be reached
+func relayGatewayUsage(ctx context.Context, streamURL, token string) {
+	delay := liveRetryDelay
+	for {
+		start := time.Now()
+		err := followUsageStream(ctx, streamURL, token)
+		if ctx.Err() != nil {
+			return
+		}
+		// A stream that stayed up a while was healthy; start the backoff over
+		if time.Since(start) > liveMaxRetryDelay {
+			delay = liveRetryDelay
+		}
+		log.Printf("Live usage stream from %s ended: %v; reconnecting in %s", streamURL, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, liveMaxRetryDelay)
+	}
+}
+
+func followUsageStream(ctx context.Context, streamURL, token string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxDeltaBytes)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var delta models.UsageDelta
+		if err := json.Unmarshal(line, &delta); err != nil {
+			log.Printf("Skipping malformed usage delta from %s: %v", streamURL, err)
+			continue
+		}
+		dashboardUsage.Publish(delta)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("closed by the gateway")
+}
+
+// LiveUsageSocketHandler upgrades to a WebSocket that pushes the organization's quota, request,
+// token and spend changes to its dashboard at most once a second. It needs billing:read, like
+// the quota cards it updates.
+func LiveUsageSocketHandler(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "org_id is required"})
+		return
+	}
+	if !auth.OrgPermission(c, orgID, models.PermissionBillingRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission billing:read required"})
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: sameOriginHandshake,
+		Handler:   func(ws *websocket.Conn) { pushLiveUsage(ws, orgID) },
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// sameOriginHandshake refuses WebSockets opened by other sites' pages, which would otherwise
+// ride on the user's session cookie
+func sameOriginHandshake(config *websocket.Config, req *http.Request) error {
+	origin, err := url.Parse(req.Header.Get("Origin"))
+	if err != nil || origin.Host != req.Host {
+		return fmt.Errorf("cross-origin WebSocket from %q refused", req.Header.Get("Origin"))
+	}
+	config.Origin = origin
+	return nil
+}
+
+// pushLiveUsage sends the organization's dashboard what changed every push interval until the
+// browser goes away
+func pushLiveUsage(ws *websocket.Conn, orgID string) {
+	defer ws.Close()
+
+	deltas, unsubscribe := dashboardUsage.Subscribe()
+	defer unsubscribe()
+
+	// The browser sends nothing; a failed read means it has closed the socket
+	closed := make(chan struct{})
+	go func() {
+		var discard string
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(livePushInterval)
+	defer ticker.Stop()
+	update := models.LiveDashboardUpdate{OrganizationID: orgID}
+	for {
+		select {
+		case <-closed:
+			return
+		case delta := <-deltas:
+			update.Add(delta)
+		case <-ticker.C:
+			if update.Empty() {
+				continue
+			}
+			if err := websocket.JSON.Send(ws, update); err != nil {
+				return
+			}
+			update.Reset()
+		}
+	}
+}
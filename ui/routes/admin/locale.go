@@ -0,0 +1,143 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/i18n"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// TranslationCatalogHandler returns the UI messages for ?locale= (default: the caller's locale)
+// so client-side scripts can translate strings
+func TranslationCatalogHandler(c *gin.Context) {
+	locale := c.Query("locale")
+	if locale == "" {
+		locale = auth.ResolveLocale(c)
+	}
+	locale = i18n.Resolve(locale)
+
+	c.JSON(http.StatusOK, gin.H{
+		"locale":   locale,
+		"locales":  i18n.Locales(),
+		"messages": i18n.Catalog(locale),
+	})
+}
+
+// UpdateUserLocaleHandler saves the current user's language preference
+func UpdateUserLocaleHandler(c *gin.Context) {
+	var req models.UpdateUserLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	locale, ok := normalizeLocaleParam(c, req.Locale)
+	if !ok {
+		return
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	userID, ok := auth.GetUserContext(c)["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	if err := db.UpdateUserLocale(sqlDB, userID, locale); err != nil {
+		log.Printf("Failed to update user locale: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update language"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"locale":  locale,
+		"message": "Language updated successfully",
+	})
+}
+
+// GetOrganizationLocaleHandler returns the organization's default language
+func GetOrganizationLocaleHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := orgAccess(c, c.Query("org_id"))
+	if !ok {
+		return
+	}
+
+	locale, err := db.GetOrganizationLocale(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization locale: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organization language"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization_id": orgID,
+		"locale":          locale,
+		"locales":         i18n.Locales(),
+	})
+}
+
+// UpdateOrganizationLocaleHandler sets the organization's default language; requires the org admin role
+func UpdateOrganizationLocaleHandler(c *gin.Context) {
+	var req models.UpdateOrganizationLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	locale, ok := normalizeLocaleParam(c, req.Locale)
+	if !ok {
+		return
+	}
+
+	sqlDB, orgID, role, ok := orgAccess(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return
+	}
+
+	if err := db.UpdateOrganizationLocale(sqlDB, orgID, locale); err != nil {
+		log.Printf("Failed to update organization locale: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization language"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization_id": orgID,
+		"locale":          locale,
+		"message":         "Organization language updated successfully",
+	})
+}
+
+// normalizeLocaleParam maps a requested locale to a supported one; "" (clear) is allowed
+func normalizeLocaleParam(c *gin.Context, requested string) (string, bool) {
+	if requested == "" {
+		return "", true
+	}
+
+	locale := i18n.Normalize(requested)
+	if locale == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported locale: " + requested})
+		return "", false
+	}
+	return locale, true
+}
@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetOrganizationMaxRequestCostHandler reports the organization's ceiling on a request's
+// worst-case cost in US dollars; requires admin of the organization or System Admin
+func GetOrganizationMaxRequestCostHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	ceiling, err := db.GetOrganizationMaxRequestCost(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization max request cost: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load max request cost"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization_id": orgID, "max_request_cost": ceiling})
+}
+
+// UpdateOrganizationMaxRequestCostHandler sets or removes the organization's ceiling on a
+// request's worst-case cost in US dollars. Requires admin of the organization or System Admin
+// and is audited.
+func UpdateOrganizationMaxRequestCostHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateMaxRequestCostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.UpdateOrganizationMaxRequestCost(sqlDB, orgID, req.MaxRequestCost); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to update organization max request cost: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update max request cost"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionMaxRequestCost, "organization", orgID, c.ClientIP(),
+		map[string]interface{}{"max_request_cost": req.MaxRequestCost}); err != nil {
+		log.Printf("Failed to write audit log for max request cost change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization_id":  orgID,
+		"max_request_cost": req.MaxRequestCost,
+		"message":          "Max request cost updated successfully",
+	})
+}
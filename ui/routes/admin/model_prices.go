@@ -0,0 +1,107 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// modelPriceContext returns the database for a model price request once the user holds every
+// permission given. It writes the error response itself and returns ok=false on failure.
+func modelPriceContext(c *gin.Context, permissions ...string) (*sql.DB, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, false
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, false
+	}
+
+	for _, permission := range permissions {
+		if !auth.Permission(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission " + permission + " required"})
+			return nil, false
+		}
+	}
+	return sqlDB, true
+}
+
+// ListModelPricesHandler returns a model's prices, newest first; requires models:read and
+// costs:read
+func ListModelPricesHandler(c *gin.Context) {
+	sqlDB, ok := modelPriceContext(c, models.PermissionModelsRead, models.PermissionCostsRead)
+	if !ok {
+		return
+	}
+
+	prices, err := db.ListModelPrices(sqlDB, c.Param("id"))
+	if err != nil {
+		log.Printf("Failed to list model prices: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load model prices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prices": prices, "current": models.PriceAt(prices, time.Now())})
+}
+
+// CreateModelPriceHandler adds a price to a model, such as a provider's new list price from a
+// given date or peak and off-peak chargeback rates. Prices apply to every organization using the
+// model, so this requires System Admin.
+func CreateModelPriceHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateModelPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	price, err := db.CreateModelPrice(sqlDB, c.Param("id"), userID, req)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to create model price: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create model price"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"price": price, "message": "Model price created successfully"})
+}
+
+// DeleteModelPriceHandler removes one of a model's prices; usage already recorded keeps the
+// cost it was logged with. Requires System Admin.
+func DeleteModelPriceHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteModelPrice(sqlDB, c.Param("id"), c.Param("price_id")); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model price not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete model price: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete model price"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Model price deleted successfully"})
+}
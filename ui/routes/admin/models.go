@@ -7,7 +7,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/encryption"
+	"github.com/like-mike/relai-gateway/shared/gcpauth"
 	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
 )
 
 func ModelsHandler(c *gin.Context) {
@@ -26,6 +29,11 @@ func ModelsHandler(c *gin.Context) {
 		return
 	}
 
+	if !auth.Permission(c, models.PermissionModelsRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission models:read required"})
+		return
+	}
+
 	// Get models from database with organization access
 	modelsList, err := db.GetModelsWithOrganizations(sqlDB)
 	if err != nil {
@@ -34,23 +42,21 @@ func ModelsHandler(c *gin.Context) {
 		return
 	}
 
-	// Return JSON response for JavaScript to render
-	c.JSON(http.StatusOK, models.ModelsResponse{
+	response := models.ModelsResponse{
 		Models: modelsList,
-	})
+	}
+	if !auth.Permission(c, models.PermissionCostsRead) {
+		response.HideCosts()
+	}
+
+	// Return JSON response for JavaScript to render
+	c.JSON(http.StatusOK, response)
 }
 
 func CreateModelHandler(c *gin.Context) {
-	// Get database connection from context
-	database, exists := c.Get("db")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
-	}
-
-	sqlDB, ok := database.(*sql.DB)
+	// Models are shared by every organization, so only System Admins change them
+	sqlDB, _, ok := requireSystemAdmin(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
 		return
 	}
 
@@ -61,6 +67,14 @@ func CreateModelHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
 		return
 	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !sealModelSecrets(c, req.AWSSecretKey, req.GCPServiceAccount) {
+		return
+	}
+	req.ApplyProviderDefaults()
 
 	// Create model in database
 	model, err := db.CreateModel(sqlDB, req)
@@ -78,16 +92,9 @@ func CreateModelHandler(c *gin.Context) {
 }
 
 func DeleteModelHandler(c *gin.Context) {
-	// Get database connection from context
-	database, exists := c.Get("db")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
-	}
-
-	sqlDB, ok := database.(*sql.DB)
+	// Models are shared by every organization, so only System Admins change them
+	sqlDB, _, ok := requireSystemAdmin(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
 		return
 	}
 
@@ -112,16 +119,9 @@ func DeleteModelHandler(c *gin.Context) {
 }
 
 func UpdateModelHandler(c *gin.Context) {
-	// Get database connection from context
-	database, exists := c.Get("db")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
-	}
-
-	sqlDB, ok := database.(*sql.DB)
+	// Models are shared by every organization, so only System Admins change them
+	sqlDB, _, ok := requireSystemAdmin(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
 		return
 	}
 
@@ -139,6 +139,13 @@ func UpdateModelHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
 		return
 	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !sealModelSecrets(c, req.AWSSecretKey, req.GCPServiceAccount) {
+		return
+	}
 
 	// Update model in database
 	model, err := db.UpdateModel(sqlDB, modelID, req)
@@ -155,6 +162,39 @@ func UpdateModelHandler(c *gin.Context) {
 	})
 }
 
+// sealModelSecrets encrypts a newly entered AWS secret key and GCP service account key in
+// place; masked (unchanged) and empty values are left alone. It writes the error response
+// itself and returns false on failure.
+func sealModelSecrets(c *gin.Context, awsSecretKey, gcpServiceAccount *string) bool {
+	if isNewSecret(gcpServiceAccount) {
+		if _, err := gcpauth.ParseServiceAccount([]byte(*gcpServiceAccount)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid gcp_service_account: " + err.Error()})
+			return false
+		}
+	}
+
+	for _, secret := range []*string{awsSecretKey, gcpServiceAccount} {
+		if !isNewSecret(secret) {
+			continue
+		}
+		sealed, err := encryption.Encrypt(*secret)
+		if err == encryption.ErrNoKey {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ENCRYPTION_KEY must be set to store provider credentials"})
+			return false
+		} else if err != nil {
+			log.Printf("Failed to encrypt provider credentials: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt provider credentials"})
+			return false
+		}
+		*secret = sealed
+	}
+	return true
+}
+
+func isNewSecret(secret *string) bool {
+	return secret != nil && *secret != "" && !models.IsMaskedSecret(*secret)
+}
+
 func ManageModelAccessHandler(c *gin.Context) {
 	// Get database connection from context
 	database, exists := c.Get("db")
@@ -186,6 +226,14 @@ func ManageModelAccessHandler(c *gin.Context) {
 		return
 	}
 
+	// Each organization whose access changes must be one the user manages models in
+	for _, change := range req.Changes {
+		if !auth.OrgPermission(c, change.OrgID, models.PermissionModelsWrite) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission models:write required in organization " + change.OrgID})
+			return
+		}
+	}
+
 	// Update model access in database
 	err := db.ManageModelAccess(sqlDB, modelID, req.Changes)
 	if err != nil {
@@ -212,24 +260,36 @@ func ManageModelAccessHandler(c *gin.Context) {
 }
 
 // Endpoints handlers
+
+// EndpointsHandler lists the endpoints of the caller's organizations, optionally narrowed by org_id
 func EndpointsHandler(c *gin.Context) {
-	// Get database connection from context
-	database, exists := c.Get("db")
-	if !exists {
-		log.Printf("Database connection not found in context")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+	sqlDB, userID, ok := endpointRequestContext(c)
+	if !ok {
 		return
 	}
 
-	sqlDB, ok := database.(*sql.DB)
-	if !ok {
-		log.Printf("Invalid database connection type")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
 		return
 	}
 
-	// Get endpoints from database
-	endpointsList, err := db.GetEndpointsWithModels(sqlDB)
+	// Optionally filter by organization
+	var orgIDs []string
+	if orgID := c.Query("org_id"); orgID != "" {
+		if _, hasAccess := memberships[orgID]; !hasAccess {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+			return
+		}
+		orgIDs = []string{orgID}
+	} else {
+		for orgID := range memberships {
+			orgIDs = append(orgIDs, orgID)
+		}
+	}
+
+	endpointsList, err := db.GetEndpointsByOrganization(sqlDB, orgIDs)
 	if err != nil {
 		log.Printf("Failed to get endpoints: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load endpoints"})
@@ -242,17 +302,11 @@ func EndpointsHandler(c *gin.Context) {
 	})
 }
 
+// CreateEndpointHandler creates an endpoint in organization_id, which may be omitted when the
+// caller belongs to a single organization
 func CreateEndpointHandler(c *gin.Context) {
-	// Get database connection from context
-	database, exists := c.Get("db")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
-	}
-
-	sqlDB, ok := database.(*sql.DB)
+	sqlDB, userID, ok := endpointRequestContext(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
 		return
 	}
 
@@ -264,11 +318,26 @@ func CreateEndpointHandler(c *gin.Context) {
 		return
 	}
 
-	// Get organization ID from request or use a default (in real app, this would come from user context)
-	orgID := c.PostForm("organization_id")
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return
+	}
+
+	orgID := req.OrganizationID
 	if orgID == "" {
-		// For demo, use the first organization
-		orgID = "11111111-1111-1111-1111-111111111111"
+		if len(memberships) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "organization_id is required"})
+			return
+		}
+		for id := range memberships {
+			orgID = id
+		}
+	}
+	if _, hasAccess := memberships[orgID]; !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+		return
 	}
 
 	// Create endpoint in database
@@ -286,24 +355,10 @@ func CreateEndpointHandler(c *gin.Context) {
 	})
 }
 
+// UpdateEndpointHandler updates an endpoint in one of the caller's organizations
 func UpdateEndpointHandler(c *gin.Context) {
-	// Get database connection from context
-	database, exists := c.Get("db")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
-	}
-
-	sqlDB, ok := database.(*sql.DB)
+	sqlDB, endpoint, ok := loadEndpointForUser(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
-	}
-
-	// Get endpoint ID from URL parameter
-	endpointID := c.Param("id")
-	if endpointID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Endpoint ID is required"})
 		return
 	}
 
@@ -316,7 +371,7 @@ func UpdateEndpointHandler(c *gin.Context) {
 	}
 
 	// Update endpoint in database
-	endpoint, err := db.UpdateEndpoint(sqlDB, endpointID, req)
+	updated, err := db.UpdateEndpoint(sqlDB, endpoint.ID, req)
 	if err != nil {
 		log.Printf("Failed to update endpoint: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update endpoint"})
@@ -325,35 +380,20 @@ func UpdateEndpointHandler(c *gin.Context) {
 
 	// Return the updated endpoint
 	c.JSON(http.StatusOK, gin.H{
-		"endpoint": endpoint,
+		"endpoint": updated,
 		"message":  "Endpoint updated successfully",
 	})
 }
 
+// DeleteEndpointHandler deactivates an endpoint in one of the caller's organizations
 func DeleteEndpointHandler(c *gin.Context) {
-	// Get database connection from context
-	database, exists := c.Get("db")
-	if !exists {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
-	}
-
-	sqlDB, ok := database.(*sql.DB)
+	sqlDB, endpoint, ok := loadEndpointForUser(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
-	}
-
-	// Get endpoint ID from URL parameter
-	endpointID := c.Param("id")
-	if endpointID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Endpoint ID is required"})
 		return
 	}
 
 	// Delete endpoint (soft delete)
-	err := db.DeleteEndpoint(sqlDB, endpointID)
-	if err != nil {
+	if err := db.DeleteEndpoint(sqlDB, endpoint.ID); err != nil {
 		log.Printf("Failed to delete endpoint: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete endpoint"})
 		return
@@ -364,36 +404,82 @@ func DeleteEndpointHandler(c *gin.Context) {
 	})
 }
 
+// GetEndpointHandler returns an endpoint in one of the caller's organizations
 func GetEndpointHandler(c *gin.Context) {
+	_, endpoint, ok := loadEndpointForUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"endpoint": endpoint,
+	})
+}
+
+// endpointRequestContext resolves the database connection and authenticated user ID, writing
+// the error response itself
+func endpointRequestContext(c *gin.Context) (*sql.DB, string, bool) {
 	// Get database connection from context
 	database, exists := c.Get("db")
 	if !exists {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
+		return nil, "", false
 	}
 
 	sqlDB, ok := database.(*sql.DB)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
-		return
+		return nil, "", false
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return nil, "", false
+	}
+
+	return sqlDB, userID, true
+}
+
+// loadEndpointForUser loads the endpoint named by :id and checks it belongs to one of the
+// caller's organizations, writing the error response itself
+func loadEndpointForUser(c *gin.Context) (*sql.DB, *models.Endpoint, bool) {
+	sqlDB, userID, ok := endpointRequestContext(c)
+	if !ok {
+		return nil, nil, false
 	}
 
 	// Get endpoint ID from URL parameter
 	endpointID := c.Param("id")
 	if endpointID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Endpoint ID is required"})
-		return
+		return nil, nil, false
 	}
 
-	// Get endpoint from database
 	endpoint, err := db.GetEndpointByID(sqlDB, endpointID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Endpoint not found"})
+		return nil, nil, false
+	}
 	if err != nil {
 		log.Printf("Failed to get endpoint: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get endpoint"})
-		return
+		return nil, nil, false
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"endpoint": endpoint,
-	})
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return nil, nil, false
+	}
+
+	if _, hasAccess := memberships[endpoint.OrganizationID]; !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+		return nil, nil, false
+	}
+
+	return sqlDB, endpoint, true
 }
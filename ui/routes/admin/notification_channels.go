@@ -0,0 +1,193 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/notify"
+)
+
+// NotificationChannelsHandler lists the organization's Slack / Teams channels
+func NotificationChannelsHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := orgAccess(c, c.Query("org_id"))
+	if !ok {
+		return
+	}
+
+	channels, err := db.GetNotificationChannels(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get notification channels: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification channels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channels": channels,
+	})
+}
+
+// CreateNotificationChannelHandler adds a channel; requires the org admin role
+func CreateNotificationChannelHandler(c *gin.Context) {
+	var req models.CreateNotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind notification channel request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if !notify.IsValidChannelType(req.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be one of slack, teams"})
+		return
+	}
+	if err := models.ValidateWebhookURL(req.WebhookURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := models.ValidateNotificationEvents(req.Events); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, orgID, role, ok := orgAccess(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return
+	}
+
+	req.OrganizationID = orgID
+	channel, err := db.CreateNotificationChannel(sqlDB, req)
+	if err != nil {
+		log.Printf("Failed to create notification channel: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notification channel"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"channel": channel,
+		"message": "Notification channel created successfully",
+	})
+}
+
+// UpdateNotificationChannelHandler updates a channel; requires the org admin role
+func UpdateNotificationChannelHandler(c *gin.Context) {
+	var req models.UpdateNotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind notification channel request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if req.WebhookURL != nil && !models.IsMaskedSecret(*req.WebhookURL) {
+		if err := models.ValidateWebhookURL(*req.WebhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err := models.ValidateNotificationEvents(req.Events); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, channel, ok := loadNotificationChannelForAdmin(c)
+	if !ok {
+		return
+	}
+
+	updated, err := db.UpdateNotificationChannel(sqlDB, channel.ID, req)
+	if err != nil {
+		log.Printf("Failed to update notification channel: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification channel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channel": updated,
+		"message": "Notification channel updated successfully",
+	})
+}
+
+// DeleteNotificationChannelHandler removes a channel; requires the org admin role
+func DeleteNotificationChannelHandler(c *gin.Context) {
+	sqlDB, channel, ok := loadNotificationChannelForAdmin(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteNotificationChannel(sqlDB, channel.ID); err != nil {
+		log.Printf("Failed to delete notification channel: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification channel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channel deleted successfully"})
+}
+
+// TestNotificationChannelHandler sends a test message to a channel and reports the webhook's response
+func TestNotificationChannelHandler(c *gin.Context) {
+	_, channel, ok := loadNotificationChannelForAdmin(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	err := notify.SendTo(ctx, *channel, notify.Message{
+		Title:    "RelAI Gateway test notification",
+		Text:     "This channel is configured to receive gateway alerts.",
+		Severity: notify.SeverityInfo,
+		Fields:   []notify.Field{{Name: "Channel", Value: channel.Name}},
+	})
+	if err != nil {
+		log.Printf("Test notification to channel %s failed: %v", channel.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Test notification failed: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Test notification sent"})
+}
+
+// loadNotificationChannelForAdmin loads the :id channel and checks the user is an admin of its organization
+func loadNotificationChannelForAdmin(c *gin.Context) (*sql.DB, *models.NotificationChannel, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, false
+	}
+
+	channel, err := db.GetNotificationChannel(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification channel not found"})
+		return nil, nil, false
+	} else if err != nil {
+		log.Printf("Failed to get notification channel: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification channel"})
+		return nil, nil, false
+	}
+
+	_, _, role, ok := orgAccess(c, channel.OrganizationID)
+	if !ok {
+		return nil, nil, false
+	}
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return nil, nil, false
+	}
+
+	return sqlDB, channel, true
+}
@@ -0,0 +1,177 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// unsubscribeEventLabels describes each event on the unsubscribe page
+var unsubscribeEventLabels = map[string]string{
+	"":                                   "notification emails",
+	models.NotificationEventAPIKeyExpiry: "API key expiration reminders",
+	models.NotificationEventQuotaUsage:   "quota usage alerts",
+}
+
+// UnsubscribePageHandler shows the unsubscribe confirmation for a signed link. It doesn't
+// change anything, so link scanners that prefetch URLs can't unsubscribe anyone.
+func UnsubscribePageHandler(c *gin.Context) {
+	token := c.Query("token")
+	emailService, ok := emailServiceFromContext(c)
+	if !ok {
+		return
+	}
+
+	recipient, event, err := emailService.ParseUnsubscribeToken(token)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "unsubscribe.html", gin.H{"error": "This unsubscribe link is invalid or has expired."})
+		return
+	}
+
+	c.HTML(http.StatusOK, "unsubscribe.html", gin.H{
+		"token":      token,
+		"email":      recipient,
+		"eventLabel": unsubscribeEventLabels[event],
+	})
+}
+
+// UnsubscribeHandler opts the link's recipient out of its event (or all notifications). It
+// serves both the confirmation form and one-click unsubscribe from mail clients (RFC 8058),
+// which posts to the List-Unsubscribe URL with the token in the query string.
+func UnsubscribeHandler(c *gin.Context) {
+	token := c.PostForm("token")
+	if token == "" {
+		token = c.Query("token")
+	}
+
+	emailService, ok := emailServiceFromContext(c)
+	if !ok {
+		return
+	}
+
+	recipient, event, err := emailService.ParseUnsubscribeToken(token)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "unsubscribe.html", gin.H{"error": "This unsubscribe link is invalid or has expired."})
+		return
+	}
+
+	events := []string{event}
+	if event == "" {
+		events = models.NotificationEvents
+	}
+
+	var settings []models.NotificationPreferenceSetting
+	for _, e := range events {
+		settings = append(settings, models.NotificationPreferenceSetting{
+			EventType: e,
+			Channel:   models.NotificationDeliveryEmail,
+			IsEnabled: false,
+		})
+	}
+
+	database, _ := c.Get("db") // Checked by emailServiceFromContext
+	if err := db.SetNotificationPreferences(database.(*sql.DB), recipient, settings); err != nil {
+		log.Printf("Failed to unsubscribe %s: %v", recipient, err)
+		c.HTML(http.StatusInternalServerError, "unsubscribe.html", gin.H{"error": "Failed to unsubscribe. Please try again later."})
+		return
+	}
+
+	log.Printf("Unsubscribed %s from %s", recipient, unsubscribeEventLabels[event])
+	c.HTML(http.StatusOK, "unsubscribe.html", gin.H{
+		"unsubscribed": true,
+		"email":        recipient,
+		"eventLabel":   unsubscribeEventLabels[event],
+	})
+}
+
+// currentUserEmail returns the signed-in user's email address
+func currentUserEmail(c *gin.Context) (string, bool) {
+	userEmail, _ := auth.GetUserContext(c)["userEmail"].(string)
+	if userEmail == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return "", false
+	}
+	return userEmail, true
+}
+
+// GetNotificationPreferencesHandler returns the current user's notification preferences
+func GetNotificationPreferencesHandler(c *gin.Context) {
+	userEmail, ok := currentUserEmail(c)
+	if !ok {
+		return
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	preferences, err := db.GetNotificationPreferences(sqlDB, userEmail)
+	if err != nil {
+		log.Printf("Failed to get notification preferences: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": preferences})
+}
+
+// UpdateNotificationPreferencesHandler saves the current user's notification preferences
+func UpdateNotificationPreferencesHandler(c *gin.Context) {
+	var req models.UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userEmail, ok := currentUserEmail(c)
+	if !ok {
+		return
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	if err := db.SetNotificationPreferences(sqlDB, userEmail, req.Preferences); err != nil {
+		log.Printf("Failed to update notification preferences: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	preferences, err := db.GetNotificationPreferences(sqlDB, userEmail)
+	if err != nil {
+		log.Printf("Failed to get notification preferences: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences": preferences,
+		"message":     "Notification preferences updated successfully",
+	})
+}
@@ -0,0 +1,159 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// notificationUser returns the database, the current user and whether they are a System Admin,
+// whose notification center also holds gateway-wide alerts
+func notificationUser(c *gin.Context) (*sql.DB, string, bool, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", false, false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", false, false
+	}
+
+	userID, _ := auth.GetUserContext(c)["id"].(string)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, "", false, false
+	}
+
+	isAdmin, err := db.IsSystemAdmin(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to check system admin role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, "", false, false
+	}
+
+	return sqlDB, userID, isAdmin, true
+}
+
+// NotificationsHandler lists the user's notifications, newest first, with their unread count.
+// unread=true lists only unread ones, org_id one organization's, and before pages back.
+func NotificationsHandler(c *gin.Context) {
+	var params models.NotificationListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameters"})
+		return
+	}
+	if err := params.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if params.OrganizationID != "" {
+		if _, err := uuid.Parse(params.OrganizationID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+			return
+		}
+	}
+
+	sqlDB, userID, isAdmin, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	notifications, err := db.GetNotifications(sqlDB, userID, isAdmin, params)
+	if err != nil {
+		log.Printf("Failed to get notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notifications"})
+		return
+	}
+
+	unread, err := db.CountUnreadNotifications(sqlDB, userID, isAdmin)
+	if err != nil {
+		log.Printf("Failed to count unread notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread_count":  unread,
+	})
+}
+
+// UnreadNotificationCountHandler returns how many of the user's notifications are unread, for
+// the badge on the notification bell
+func UnreadNotificationCountHandler(c *gin.Context) {
+	sqlDB, userID, isAdmin, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	unread, err := db.CountUnreadNotifications(sqlDB, userID, isAdmin)
+	if err != nil {
+		log.Printf("Failed to count unread notifications: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": unread})
+}
+
+// MarkNotificationReadHandler marks one of the user's notifications as read
+func MarkNotificationReadHandler(c *gin.Context) {
+	notificationID := c.Param("id")
+	if _, err := uuid.Parse(notificationID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	sqlDB, userID, isAdmin, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	err := db.MarkNotificationRead(sqlDB, userID, isAdmin, notificationID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to mark notification read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// MarkAllNotificationsReadHandler marks all of the user's notifications as read, or only an
+// organization's with org_id
+func MarkAllNotificationsReadHandler(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID != "" {
+		if _, err := uuid.Parse(orgID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+			return
+		}
+	}
+
+	sqlDB, userID, isAdmin, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+
+	marked, err := db.MarkAllNotificationsRead(sqlDB, userID, isAdmin, orgID)
+	if err != nil {
+		log.Printf("Failed to mark notifications read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked": marked, "message": "Notifications marked as read"})
+}
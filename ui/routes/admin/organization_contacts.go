@@ -0,0 +1,124 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// OrganizationContactsHandler lists the organization's notification contacts, with the role
+// each notification event is emailed to
+func OrganizationContactsHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := orgAccess(c, c.Query("org_id"))
+	if !ok {
+		return
+	}
+
+	contacts, err := db.GetOrganizationContacts(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization contacts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load contacts"})
+		return
+	}
+
+	eventRoles := make(map[string]string, len(models.NotificationEvents))
+	for _, event := range models.NotificationEvents {
+		eventRoles[event] = models.ContactRoleForEvent(event)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"contacts":    contacts,
+		"event_roles": eventRoles,
+	})
+}
+
+// CreateOrganizationContactHandler adds a contact to the roster; requires the org admin role
+func CreateOrganizationContactHandler(c *gin.Context) {
+	var req models.CreateOrganizationContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A role and a valid email address are required"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, orgID, role, ok := orgAccess(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return
+	}
+
+	req.OrganizationID = orgID
+	contact, err := db.CreateOrganizationContact(sqlDB, req)
+	if err == db.ErrContactExists {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		log.Printf("Failed to create organization contact: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add contact"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"contact": contact,
+		"message": "Contact added successfully",
+	})
+}
+
+// DeleteOrganizationContactHandler removes a contact from the roster; requires the org admin role
+func DeleteOrganizationContactHandler(c *gin.Context) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	contactID := c.Param("id")
+	if _, err := uuid.Parse(contactID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
+		return
+	}
+
+	contact, err := db.GetOrganizationContact(sqlDB, contactID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Contact not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get organization contact: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load contact"})
+		return
+	}
+
+	_, _, role, ok := orgAccess(c, contact.OrganizationID)
+	if !ok {
+		return
+	}
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return
+	}
+
+	if err := db.DeleteOrganizationContact(sqlDB, contact.ID); err != nil {
+		log.Printf("Failed to delete organization contact: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove contact"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Contact removed successfully"})
+}
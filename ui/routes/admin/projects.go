@@ -0,0 +1,251 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// ProjectsHandler lists the projects of the user's organizations, optionally narrowed by org_id
+func ProjectsHandler(c *gin.Context) {
+	// Get database connection from context
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	memberships, err := db.GetUserOrganizationMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return
+	}
+
+	var orgIDs []string
+	if orgID := c.Query("org_id"); orgID != "" {
+		if _, hasAccess := memberships[orgID]; !hasAccess {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+			return
+		}
+		orgIDs = []string{orgID}
+	} else {
+		for orgID := range memberships {
+			orgIDs = append(orgIDs, orgID)
+		}
+	}
+
+	projects, err := db.GetProjectsByOrganization(sqlDB, orgIDs)
+	if err != nil {
+		log.Printf("Failed to get projects: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load projects"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects": projects,
+	})
+}
+
+// CreateProjectHandler creates a project; requires keys:write in the organization
+func CreateProjectHandler(c *gin.Context) {
+	var req models.CreateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind project request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, _, _, ok := orgAccess(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+	if !auth.OrgPermission(c, req.OrganizationID, models.PermissionKeysWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required"})
+		return
+	}
+
+	userID, _ := auth.GetUserContext(c)["id"].(string)
+	project, err := db.CreateProject(sqlDB, req, userID)
+	if err != nil {
+		log.Printf("Failed to create project: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project (is the name already in use?)"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"project": project,
+		"message": "Project created successfully",
+	})
+}
+
+// UpdateProjectHandler renames a project or changes its quota; requires keys:write
+func UpdateProjectHandler(c *gin.Context) {
+	sqlDB, project, ok := loadProjectForUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind project update request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := db.UpdateProject(sqlDB, project.ID, req)
+	if err != nil {
+		log.Printf("Failed to update project: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"project": updated,
+		"message": "Project updated successfully",
+	})
+}
+
+// DeleteProjectHandler deactivates a project, moving its keys back to the organization;
+// requires keys:write
+func DeleteProjectHandler(c *gin.Context) {
+	sqlDB, project, ok := loadProjectForUser(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteProject(sqlDB, project.ID); err != nil {
+		log.Printf("Failed to delete project: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project deleted successfully",
+	})
+}
+
+// loadProjectForUser loads the project named by :id and checks the user has keys:write in its
+// organization. It writes the error response itself.
+func loadProjectForUser(c *gin.Context) (*sql.DB, *models.Project, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, false
+	}
+
+	project, err := db.GetProjectByID(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load project"})
+		return nil, nil, false
+	}
+
+	if _, _, _, ok := orgAccess(c, project.OrganizationID); !ok {
+		return nil, nil, false
+	}
+	if !auth.OrgPermission(c, project.OrganizationID, models.PermissionKeysWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required"})
+		return nil, nil, false
+	}
+
+	return sqlDB, project, true
+}
+
+// MoveAPIKeyProjectHandler moves a key into a project of its organization, or out of any project
+// when project_id is empty; requires keys:write
+func MoveAPIKeyProjectHandler(c *gin.Context) {
+	var req struct {
+		ProjectID string `json:"project_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	key, err := db.GetAPIKey(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get API key: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API key"})
+		return
+	}
+
+	if !auth.OrgPermission(c, key.OrganizationID, models.PermissionKeysWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission keys:write required"})
+		return
+	}
+
+	if req.ProjectID != "" {
+		project, err := db.GetProjectByID(sqlDB, req.ProjectID)
+		if err == sql.ErrNoRows || (err == nil && project.OrganizationID != key.OrganizationID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found in the organization"})
+			return
+		} else if err != nil {
+			log.Printf("Failed to get project: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load project"})
+			return
+		}
+	}
+
+	if err := db.AssignAPIKeyProject(sqlDB, key.ID, req.ProjectID); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to move API key to project: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "project_id": req.ProjectID})
+}
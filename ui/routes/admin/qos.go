@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetOrganizationQoSHandler reports the QoS class the organization's requests are scheduled
+// with; requires admin of the organization or System Admin
+func GetOrganizationQoSHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	class, err := db.GetOrganizationQoSClass(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization QoS class: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load QoS class"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization_id": orgID, "qos_class": class, "qos_classes": models.QoSClasses})
+}
+
+// UpdateOrganizationQoSHandler sets the organization's QoS class. Only System Admins may, since
+// raising one organization's class lowers everyone else's share; audited.
+func UpdateOrganizationQoSHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateQoSClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !models.IsValidQoSClass(req.QoSClass) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "qos_class must be gold, silver or bronze"})
+		return
+	}
+
+	if err := db.UpdateOrganizationQoSClass(sqlDB, orgID, req.QoSClass); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to update organization QoS class: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update QoS class"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionQoSClass, "organization", orgID, c.ClientIP(),
+		map[string]interface{}{"qos_class": req.QoSClass}); err != nil {
+		log.Printf("Failed to write audit log for QoS class change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization_id": orgID,
+		"qos_class":       req.QoSClass,
+		"message":         "QoS class updated successfully",
+	})
+}
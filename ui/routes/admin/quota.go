@@ -70,6 +70,13 @@ func GetQuotaHandler(c *gin.Context) {
 			return
 		}
 
+		if !auth.OrgPermission(c, orgID, models.PermissionBillingRead) {
+			c.HTML(http.StatusForbidden, "quota-cards.html", gin.H{
+				"error": "Permission billing:read required",
+			})
+			return
+		}
+
 		// Get quota for specific organization
 		quota, err := db.GetOrganizationQuota(sqlDB, orgID)
 		if err != nil {
@@ -132,3 +139,125 @@ func GetQuotaHandler(c *gin.Context) {
 		"PercentUsed":    quotaStats.PercentUsed,
 	})
 }
+
+// GetQuotaNotificationSettingsHandler returns the organization's quota usage email settings
+func GetQuotaNotificationSettingsHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := orgAccess(c, c.Query("org_id"))
+	if !ok {
+		return
+	}
+
+	settings, err := db.GetQuotaNotificationSettings(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get quota notification settings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load quota notification settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings": settings,
+	})
+}
+
+// UpdateQuotaNotificationSettingsHandler sets thresholds, recipients and opt-out; requires the org admin role
+func UpdateQuotaNotificationSettingsHandler(c *gin.Context) {
+	var req models.UpdateQuotaNotificationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind quota notification settings request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, orgID, role, ok := orgAccess(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return
+	}
+
+	req.OrganizationID = orgID
+	settings, err := db.UpsertQuotaNotificationSettings(sqlDB, req)
+	if err != nil {
+		log.Printf("Failed to update quota notification settings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quota notification settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings": settings,
+		"message":  "Quota notification settings updated successfully",
+	})
+}
+
+// UpdateOrganizationQuotaHandler sets an organization's token quota. A sub-team's quota is carved
+// out of its parent's, so it requires the admin role in the parent; a top-level organization's
+// quota requires System Admin.
+func UpdateOrganizationQuotaHandler(c *gin.Context) {
+	var req models.UpdateQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.TotalQuota <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "total_quota must be a positive number of tokens"})
+		return
+	}
+
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	orgID := c.Param("id")
+	parentID, err := db.GetOrganizationParentID(sqlDB, orgID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to get organization parent: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organization"})
+		return
+	}
+
+	var actorID string
+	if parentID != "" {
+		_, actorID, ok = requireOrgAdmin(c, parentID)
+	} else {
+		_, actorID, ok = requireSystemAdmin(c)
+	}
+	if !ok {
+		return
+	}
+
+	quota, err := db.SetOrganizationQuota(sqlDB, orgID, req.TotalQuota)
+	if err == db.ErrQuotaExceedsParent || err == db.ErrQuotaBelowChildren {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to set organization quota: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quota"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionQuotaAllocate, "organization", orgID, c.ClientIP(),
+		map[string]interface{}{"parent_id": parentID, "total_quota": req.TotalQuota}); err != nil {
+		log.Printf("Failed to write audit log for quota allocation: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"quota":   quota,
+		"message": "Quota updated successfully",
+	})
+}
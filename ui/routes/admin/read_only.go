@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+	"github.com/like-mike/relai-gateway/shared/usage"
+)
+
+// GatewayReadOnly is one gateway's answer to a read-only switch
+type GatewayReadOnly struct {
+	URL     string `json:"url"`
+	Enabled bool   `json:"enabled"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReadOnlyStatusHandler reports whether the UI and each gateway in GATEWAY_RATES_URLS are in
+// read-only mode; requires System Admin
+func ReadOnlyStatusHandler(c *gin.Context) {
+	if _, _, ok := requireSystemAdmin(c); !ok {
+		return
+	}
+
+	var gateways []GatewayReadOnly
+	for _, baseURL := range gatewayInternalURLs() {
+		gateways = append(gateways, callGatewayReadOnly(http.MethodGet, baseURL, nil))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":          readonly.Enabled(),
+		"combined_gateway": usage.GetGlobalUsageTracker() != nil,
+		"gateways":         gateways,
+	})
+}
+
+// SetReadOnlyHandler turns read-only mode on or off for the UI, the gateway in this process
+// (combined mode) and each gateway in GATEWAY_RATES_URLS; requires System Admin and is audited.
+// Gateways that can't be reached are reported, not treated as a failure, so a switch can still
+// be made while a replica is down.
+func SetReadOnlyHandler(c *gin.Context) {
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "enabled is required"})
+		return
+	}
+
+	// The audit entry is written while the database still takes writes: before switching on,
+	// after switching off. It is best effort since the database may already be going down.
+	audit := func() {
+		if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionReadOnlyChange, "system", "read_only", c.ClientIP(),
+			map[string]interface{}{"enabled": *req.Enabled}); err != nil {
+			log.Printf("Failed to write audit log for read-only change: %v", err)
+		}
+	}
+	if *req.Enabled {
+		audit()
+	}
+	readonly.Set(*req.Enabled)
+	if !*req.Enabled {
+		audit()
+	}
+
+	body, _ := json.Marshal(gin.H{"enabled": *req.Enabled})
+	var gateways []GatewayReadOnly
+	for _, baseURL := range gatewayInternalURLs() {
+		gateways = append(gateways, callGatewayReadOnly(http.MethodPut, baseURL, body))
+	}
+
+	log.Printf("User %s set read-only mode to %t", userID, *req.Enabled)
+	c.JSON(http.StatusOK, gin.H{"enabled": readonly.Enabled(), "gateways": gateways})
+}
+
+// callGatewayReadOnly reads or sets a gateway's switch through its /internal/read-only route
+func callGatewayReadOnly(method, baseURL string, body []byte) GatewayReadOnly {
+	result := GatewayReadOnly{URL: baseURL}
+
+	req, err := http.NewRequest(method, baseURL+"/internal/read-only", bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("GATEWAY_INTERNAL_TOKEN"))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: rateFetchTimeout}).Do(req)
+	if err != nil {
+		log.Printf("Failed to reach gateway %s for read-only mode: %v", baseURL, err)
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		result.Error = fmt.Sprintf("status %d", resp.StatusCode)
+		return result
+	}
+
+	var status struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Enabled = status.Enabled
+	return result
+}
@@ -0,0 +1,241 @@
+package admin
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+const (
+	requestLogsDefaultLimit = 50
+	requestLogsMaxLimit     = 200
+
+	replayDefaultTimeout = 60 * time.Second
+	maxReplayResponse    = 1 << 20
+)
+
+// RequestLogsHandler lists an organization's logged requests, without bodies. Requests are only
+// logged while the gateway runs with PAYLOAD_LOGGING_ENABLED. Requires admin of the organization
+// or System Admin.
+func RequestLogsHandler(c *gin.Context) {
+	orgID := c.Query("org_id")
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization ID is required"})
+		return
+	}
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	limit := requestLogsDefaultLimit
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > requestLogsMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	payloads, err := db.GetRequestPayloads(sqlDB, orgID, limit)
+	if err != nil {
+		log.Printf("Failed to get request logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load request logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": payloads})
+}
+
+// GetRequestLogHandler returns a logged request with its bodies and earlier replays; requires
+// admin of the request's organization or System Admin
+func GetRequestLogHandler(c *gin.Context) {
+	sqlDB, payload, _, ok := loadRequestPayload(c)
+	if !ok {
+		return
+	}
+
+	replays, err := db.GetRequestReplays(sqlDB, payload.ID)
+	if err != nil {
+		log.Printf("Failed to get request replays: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load replays"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"request": payload, "replays": replays})
+}
+
+// ReplayRequestLogHandler re-sends a logged request in a sandbox, to the original model or another
+// model the organization can use, and returns both responses side by side. The replay goes
+// straight to the provider rather than through the gateway, so it is never counted as usage and
+// stays out of analytics, quotas and billing. Requires admin of the request's organization or
+// System Admin and is audited.
+func ReplayRequestLogHandler(c *gin.Context) {
+	var req models.ReplayRequestPayloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	sqlDB, payload, actorID, ok := loadRequestPayload(c)
+	if !ok {
+		return
+	}
+	if payload.Truncated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This request was truncated when logged and can't be replayed"})
+		return
+	}
+
+	modelID := req.ModelID
+	if modelID == "" {
+		if payload.ModelID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The original model no longer exists; choose a model to replay against"})
+			return
+		}
+		modelID = *payload.ModelID
+	}
+
+	model, err := db.GetModelWithOrganizations(sqlDB, modelID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get model: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load model"})
+		return
+	}
+	if !model.IsActive || !modelAvailableToOrg(model, payload.OrganizationID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The organization does not have access to this model"})
+		return
+	}
+	if model.APIEndpoint == nil || *model.APIEndpoint == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The model has no API endpoint configured"})
+		return
+	}
+
+	body, err := models.ReplayBody([]byte(payload.RequestBody), model.ModelID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	replay := models.RequestReplay{
+		PayloadID:      payload.ID,
+		OrganizationID: payload.OrganizationID,
+		ModelID:        &model.ID,
+		ModelName:      &model.Name,
+		RequestBody:    string(body),
+		ReplayedBy:     &actorID,
+	}
+	sendReplay(model, payload.Endpoint, body, &replay)
+
+	if err := db.CreateRequestReplay(sqlDB, &replay); err != nil {
+		log.Printf("Failed to save request replay: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save replay"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionRequestReplay, "request_payload", payload.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": payload.OrganizationID, "model_id": model.ID, "replay_id": replay.ID,
+			"response_status": replay.ResponseStatus}); err != nil {
+		log.Printf("Failed to write audit log for request replay: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"original": payload, "replay": replay, "billable": false})
+}
+
+// loadRequestPayload loads the logged request named in the URL and checks the user is an admin
+// of its organization. It writes the error response itself and returns ok=false on failure.
+func loadRequestPayload(c *gin.Context) (*sql.DB, *models.RequestPayload, string, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, "", false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, "", false
+	}
+
+	payload, err := db.GetRequestPayload(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Request log not found"})
+		return nil, nil, "", false
+	} else if err != nil {
+		log.Printf("Failed to get request log: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load request log"})
+		return nil, nil, "", false
+	}
+
+	_, actorID, ok := requireOrgAdmin(c, payload.OrganizationID)
+	if !ok {
+		return nil, nil, "", false
+	}
+
+	return sqlDB, payload, actorID, true
+}
+
+func modelAvailableToOrg(model *models.Model, orgID string) bool {
+	for _, org := range model.Organizations {
+		if org.ID == orgID {
+			return true
+		}
+	}
+	return false
+}
+
+// sendReplay posts the prepared body to the model's provider and records the outcome on replay
+func sendReplay(model *models.Model, endpoint string, body []byte, replay *models.RequestReplay) {
+	timeout := replayDefaultTimeout
+	if model.TimeoutSeconds != nil && *model.TimeoutSeconds > 0 {
+		timeout = time.Duration(*model.TimeoutSeconds) * time.Second
+	}
+
+	fail := func(status int, err error) {
+		message := err.Error()
+		replay.ResponseStatus = status
+		replay.Error = &message
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(*model.APIEndpoint, "/")+endpoint, bytes.NewReader(body))
+	if err != nil {
+		fail(http.StatusBadGateway, err)
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if model.APIToken != nil && *model.APIToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+*model.APIToken)
+	}
+
+	start := time.Now()
+	resp, err := (&http.Client{Timeout: timeout}).Do(httpReq)
+	if err != nil {
+		fail(http.StatusBadGateway, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, maxReplayResponse))
+	responseTimeMS := int(time.Since(start).Milliseconds())
+	replay.ResponseTimeMS = &responseTimeMS
+	if err != nil {
+		fail(http.StatusBadGateway, err)
+		return
+	}
+
+	response := string(responseBody)
+	replay.ResponseStatus = resp.StatusCode
+	replay.ResponseBody = &response
+}
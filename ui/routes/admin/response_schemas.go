@@ -0,0 +1,238 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/jsonschema"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// ResponseSchemasHandler lists response schemas for the user's organizations
+func ResponseSchemasHandler(c *gin.Context) {
+	// Get database connection from context
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return
+	}
+
+	var orgIDs []string
+	if orgID := c.Query("org_id"); orgID != "" {
+		if _, hasAccess := memberships[orgID]; !hasAccess {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+			return
+		}
+		orgIDs = []string{orgID}
+	} else {
+		for orgID := range memberships {
+			orgIDs = append(orgIDs, orgID)
+		}
+	}
+
+	schemas, err := db.GetResponseSchemasByOrganization(sqlDB, orgIDs)
+	if err != nil {
+		log.Printf("Failed to get response schemas: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load response schemas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response_schemas": schemas,
+	})
+}
+
+// CreateResponseSchemaHandler declares the expected JSON schema for an endpoint's output
+func CreateResponseSchemaHandler(c *gin.Context) {
+	// Get database connection from context
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return
+	}
+
+	var req models.CreateResponseSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind response schema request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if _, err := jsonschema.Parse(req.Schema); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON schema: " + err.Error()})
+		return
+	}
+
+	if req.MaxRetries != nil && (*req.MaxRetries < 0 || *req.MaxRetries > 3) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_retries must be between 0 and 3"})
+		return
+	}
+
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return
+	}
+
+	if _, hasAccess := memberships[req.OrganizationID]; !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+		return
+	}
+
+	schema, err := db.CreateResponseSchema(sqlDB, req)
+	if err != nil {
+		log.Printf("Failed to create response schema: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create response schema (is another schema already active for this endpoint?)"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"response_schema": schema,
+		"message":         "Response schema created successfully",
+	})
+}
+
+func UpdateResponseSchemaHandler(c *gin.Context) {
+	sqlDB, existing, ok := loadResponseSchemaForUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateResponseSchemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind response schema update request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if req.Schema != nil {
+		if _, err := jsonschema.Parse(*req.Schema); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON schema: " + err.Error()})
+			return
+		}
+	}
+
+	if req.MaxRetries != nil && (*req.MaxRetries < 0 || *req.MaxRetries > 3) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_retries must be between 0 and 3"})
+		return
+	}
+
+	schema, err := db.UpdateResponseSchema(sqlDB, existing.ID, req)
+	if err != nil {
+		log.Printf("Failed to update response schema: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update response schema"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"response_schema": schema,
+		"message":         "Response schema updated successfully",
+	})
+}
+
+func DeleteResponseSchemaHandler(c *gin.Context) {
+	sqlDB, existing, ok := loadResponseSchemaForUser(c)
+	if !ok {
+		return
+	}
+
+	if err := db.DeleteResponseSchema(sqlDB, existing.ID); err != nil {
+		log.Printf("Failed to delete response schema: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete response schema"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Response schema deleted successfully",
+	})
+}
+
+// loadResponseSchemaForUser loads the schema from the :id parameter and checks organization access.
+// It writes the error response itself and returns ok=false on failure.
+func loadResponseSchemaForUser(c *gin.Context) (*sql.DB, *models.ResponseSchema, bool) {
+	// Get database connection from context
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, nil, false
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return nil, nil, false
+	}
+
+	schema, err := db.GetResponseSchemaByID(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Response schema not found"})
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to get response schema: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load response schema"})
+		return nil, nil, false
+	}
+
+	memberships, err := orgWideMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return nil, nil, false
+	}
+
+	if _, hasAccess := memberships[schema.OrganizationID]; !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+		return nil, nil, false
+	}
+
+	return sqlDB, schema, true
+}
@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// ensureRoleExists writes 400 unless role is built-in or one of the organization's custom roles
+func ensureRoleExists(c *gin.Context, sqlDB *sql.DB, orgID, role string) bool {
+	exists, err := db.OrganizationRoleExists(sqlDB, orgID, role)
+	if err != nil {
+		log.Printf("Failed to check organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check role"})
+		return false
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role for this organization"})
+		return false
+	}
+	return true
+}
+
+// GetOrganizationRolesHandler lists an organization's built-in and custom roles and the
+// permissions that can be granted; requires admin of the organization or System Admin
+func GetOrganizationRolesHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	roles, err := db.GetOrganizationRoles(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get organization roles: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles, "permissions": models.Permissions})
+}
+
+// CreateOrganizationRoleHandler adds a custom role; requires admin of the organization or
+// System Admin and is audited
+func CreateOrganizationRoleHandler(c *gin.Context) {
+	var req models.CreateOrgRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	exists, err := db.OrganizationRoleExists(sqlDB, orgID, req.Name)
+	if err != nil {
+		log.Printf("Failed to check organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+	if exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "A role with that name already exists"})
+		return
+	}
+
+	role, err := db.CreateOrganizationRole(sqlDB, orgID, req)
+	if err != nil {
+		log.Printf("Failed to create organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionRoleCreate, "organization_role", role.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "name": role.Name, "permissions": role.Permissions}); err != nil {
+		log.Printf("Failed to write audit log for role create: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"role": role})
+}
+
+// UpdateOrganizationRoleHandler replaces a custom role's permissions; requires admin of the
+// organization or System Admin and is audited
+func UpdateOrganizationRoleHandler(c *gin.Context) {
+	var req models.UpdateOrgRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID, roleID := c.Param("id"), c.Param("role_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	previous, err := db.GetOrganizationRole(sqlDB, orgID, roleID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	role, err := db.UpdateOrganizationRole(sqlDB, orgID, roleID, req)
+	if err != nil {
+		log.Printf("Failed to update organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionRoleUpdate, "organization_role", role.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "name": role.Name, "from": previous.Permissions, "to": role.Permissions}); err != nil {
+		log.Printf("Failed to write audit log for role update: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"role": role})
+}
+
+// DeleteOrganizationRoleHandler removes a custom role nothing uses; requires admin of the
+// organization or System Admin and is audited
+func DeleteOrganizationRoleHandler(c *gin.Context) {
+	orgID, roleID := c.Param("id"), c.Param("role_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	err := db.DeleteOrganizationRole(sqlDB, orgID, roleID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	} else if err == db.ErrRoleInUse {
+		c.JSON(http.StatusConflict, gin.H{"error": "Reassign members and AD group mappings using this role before deleting it"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionRoleDelete, "organization_role", roleID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID}); err != nil {
+		log.Printf("Failed to write audit log for role delete: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// SchemaDriftHandler compares the database with the schema this release expects and lists the
+// differences with the SQL that fixes each; requires System Admin
+func SchemaDriftHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	report, err := db.CheckSchemaDrift(sqlDB)
+	if err != nil {
+		log.Printf("Failed to check schema drift: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the database schema"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ApplySchemaFixesHandler runs the upgrade steps and generated fixes for the schema drift, then
+// returns what is left; requires System Admin and is audited
+func ApplySchemaFixesHandler(c *gin.Context) {
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	before, err := db.CheckSchemaDrift(sqlDB)
+	if err != nil {
+		log.Printf("Failed to check schema drift: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the database schema"})
+		return
+	}
+
+	report, err := db.ApplySchemaFixes(sqlDB)
+	if err != nil {
+		log.Printf("Failed to apply schema fixes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply schema fixes: " + err.Error()})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionSchemaFix, "system", "schema", c.ClientIP(),
+		map[string]interface{}{"drift_before": len(before.Drift), "drift_after": len(report.Drift)}); err != nil {
+		log.Printf("Failed to write audit log for schema fixes: %v", err)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
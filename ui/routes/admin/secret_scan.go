@@ -0,0 +1,175 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/secrets"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// GetSecretScanPolicyHandler returns the organization's secret scanning policy
+func GetSecretScanPolicyHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := orgAccess(c, c.Query("org_id"))
+	if !ok {
+		return
+	}
+
+	policy, err := db.GetSecretScanPolicy(sqlDB, orgID)
+	if err == sql.ErrNoRows {
+		// No policy configured yet - report the gateway default
+		mode := os.Getenv("SECRET_SCAN_DEFAULT_MODE")
+		if !models.IsValidSecretScanMode(mode) {
+			mode = models.SecretScanModeFlag
+		}
+		policy = &models.SecretScanPolicy{OrganizationID: orgID, Mode: mode, CustomPatterns: []models.SecretPattern{}}
+	} else if err != nil {
+		log.Printf("Failed to get secret scan policy: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load secret scan policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policy": policy,
+	})
+}
+
+// UpdateSecretScanPolicyHandler sets the mode and custom patterns; requires the org admin role
+func UpdateSecretScanPolicyHandler(c *gin.Context) {
+	var req models.UpdateSecretScanPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind secret scan policy request: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	if !models.IsValidSecretScanMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be one of off, flag, block"})
+		return
+	}
+
+	rules := make([]secrets.Rule, 0, len(req.CustomPatterns))
+	for _, p := range req.CustomPatterns {
+		rules = append(rules, secrets.Rule{Name: p.Name, Pattern: p.Pattern})
+	}
+	if err := secrets.ValidateRules(rules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, orgID, role, ok := orgAccess(c, req.OrganizationID)
+	if !ok {
+		return
+	}
+
+	if role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return
+	}
+
+	req.OrganizationID = orgID
+	policy, err := db.UpsertSecretScanPolicy(sqlDB, req)
+	if err != nil {
+		log.Printf("Failed to update secret scan policy: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update secret scan policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policy":  policy,
+		"message": "Secret scan policy updated successfully",
+	})
+}
+
+// SecretScanReportHandler returns detected-secret incidents for an organization
+func SecretScanReportHandler(c *gin.Context) {
+	sqlDB, orgID, _, ok := orgAccess(c, c.Query("org_id"))
+	if !ok {
+		return
+	}
+
+	filter := models.AnalyticsFilter{
+		TimeRange:    c.DefaultQuery("range", "7d"),
+		StartDate:    c.Query("start_date"),
+		EndDate:      c.Query("end_date"),
+		Organization: orgID,
+	}
+
+	report, err := db.GetSecretScanReport(sqlDB, filter, 100)
+	if err != nil {
+		log.Printf("Failed to get secret scan report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load secret scan report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// orgWideMemberships returns the user's memberships minus organizations where they only have
+// developer access, for handlers that show organization-wide data
+func orgWideMemberships(sqlDB *sql.DB, userID string) (map[string]string, error) {
+	memberships, err := db.GetUserOrganizationMemberships(sqlDB, userID)
+	if err != nil {
+		return nil, err
+	}
+	for orgID, role := range memberships {
+		if role == models.OrgRoleDeveloper {
+			delete(memberships, orgID)
+		}
+	}
+	return memberships, nil
+}
+
+// orgAccess resolves the database and checks the user belongs to orgID, returning their role.
+// It writes the error response itself and returns ok=false on failure.
+func orgAccess(c *gin.Context, orgID string) (*sql.DB, string, string, bool) {
+	// Get database connection from context
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", "", false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", "", false
+	}
+
+	// Get user context for RBAC
+	userContext := auth.GetUserContext(c)
+	userID, ok := userContext["id"].(string)
+	if !ok || userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User authentication required"})
+		return nil, "", "", false
+	}
+
+	if orgID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization ID is required"})
+		return nil, "", "", false
+	}
+
+	memberships, err := db.GetUserOrganizationMemberships(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to get user memberships: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		return nil, "", "", false
+	}
+
+	role, hasAccess := memberships[orgID]
+	if !hasAccess {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied to organization"})
+		return nil, "", "", false
+	}
+	if role == models.OrgRoleDeveloper {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not available with developer access"})
+		return nil, "", "", false
+	}
+
+	return sqlDB, orgID, role, true
+}
@@ -0,0 +1,138 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// RevealModelTokenHandler returns a model's unmasked API token; requires System Admin and is audited
+func RevealModelTokenHandler(c *gin.Context) {
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	modelID := c.Param("id")
+	model, err := db.GetModelWithOrganizations(sqlDB, modelID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get model for token reveal: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load model"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionSecretReveal, "model", modelID, c.ClientIP(),
+		map[string]interface{}{"field": "api_token", "model_name": model.Name}); err != nil {
+		// Never hand out a secret without a record of it
+		log.Printf("Failed to write audit log for token reveal: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	log.Printf("User %s revealed API token for model %s", userID, modelID)
+
+	token := ""
+	if model.APIToken != nil {
+		token = *model.APIToken
+	}
+	c.JSON(http.StatusOK, gin.H{"api_token": token})
+}
+
+// RevealSMTPPasswordHandler returns the unmasked SMTP password; requires System Admin and is audited
+func RevealSMTPPasswordHandler(c *gin.Context) {
+	sqlDB, userID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	settings, err := email.NewService(sqlDB).GetEmailSettings()
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email settings not configured"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get email settings for password reveal: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load email settings"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionSecretReveal, "email_settings", settings.ID, c.ClientIP(),
+		map[string]interface{}{"field": "smtp_password"}); err != nil {
+		log.Printf("Failed to write audit log for SMTP password reveal: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	log.Printf("User %s revealed SMTP password", userID)
+
+	c.JSON(http.StatusOK, gin.H{"smtp_password": settings.SMTPPassword.String})
+}
+
+// AuditLogsHandler lists recent audit log entries; requires System Admin
+func AuditLogsHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	logs, err := db.GetAuditLogs(sqlDB, limit)
+	if err != nil {
+		log.Printf("Failed to get audit logs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"audit_logs": logs,
+	})
+}
+
+// requireSystemAdmin resolves the database and current user, writing an error response unless
+// the user holds the System Admin role
+func requireSystemAdmin(c *gin.Context) (*sql.DB, string, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", false
+	}
+
+	userID, _ := auth.GetUserContext(c)["id"].(string)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, "", false
+	}
+
+	isAdmin, err := db.IsSystemAdmin(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to check system admin role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, "", false
+	}
+
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "System Admin role required"})
+		return nil, "", false
+	}
+
+	return sqlDB, userID, true
+}
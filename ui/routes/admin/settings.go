@@ -2,9 +2,6 @@ package admin
 
 import (
 	"database/sql"
-	"encoding/json"
-	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/like-mike/relai-gateway/shared/db"
 	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/graph"
 	"github.com/like-mike/relai-gateway/shared/models"
 	"github.com/like-mike/relai-gateway/ui/auth"
 )
@@ -83,6 +81,7 @@ func CreateOrganizationHandler(c *gin.Context) {
 	description := c.PostForm("description")
 	quotaStr := c.PostForm("quota")
 	isActiveStr := c.PostForm("is_active")
+	parentID := c.PostForm("parent_id")
 
 	// Parse AD group fields
 	adAdminGroupID := c.PostForm("ad_admin_group_id")
@@ -110,8 +109,15 @@ func CreateOrganizationHandler(c *gin.Context) {
 	// Parse is_active
 	isActive := isActiveStr == "on" || isActiveStr == "true"
 
+	if parentID != "" {
+		if _, err := db.GetOrganizationByID(sqlDB, parentID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent organization not found"})
+			return
+		}
+	}
+
 	// Create organization with AD groups
-	orgID, err := createOrganizationWithADGroups(sqlDB, name, description, isActive, quota,
+	orgID, err := createOrganizationWithADGroups(sqlDB, name, description, isActive, quota, parentID,
 		adAdminGroupID, adAdminGroupName, adMemberGroupID, adMemberGroupName)
 	if err != nil {
 		log.Printf("Failed to create organization: %v", err)
@@ -195,6 +201,19 @@ func UpdateOrganizationHandler(c *gin.Context) {
 	// Parse is_active
 	isActive := isActiveStr == "on" || isActiveStr == "true"
 
+	// Only move the organization when the form carries a parent_id field; "" makes it top-level
+	if parentID, present := c.GetPostForm("parent_id"); present {
+		if err := db.SetOrganizationParent(sqlDB, orgID, parentID); err != nil {
+			if err == db.ErrOrganizationCycle || err == db.ErrQuotaExceedsParent {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Failed to set parent organization: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+			return
+		}
+	}
+
 	err := updateOrganizationWithADGroups(sqlDB, orgID, name, description, isActive,
 		adAdminGroupID, adAdminGroupName, adMemberGroupID, adMemberGroupName)
 	if err != nil {
@@ -273,16 +292,8 @@ func GetADGroupsHandler(c *gin.Context) {
 		return
 	}
 
-	// Get access token for Microsoft Graph
-	accessToken, err := auth.GetAccessToken(config.AzureTenantID, config.AzureClientID, config.AzureClientSecret)
-	if err != nil {
-		log.Printf("Failed to get access token: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate with Azure AD"})
-		return
-	}
-
 	// Get all groups from Azure AD
-	groups, err := getAllADGroups(accessToken)
+	groups, err := getAllADGroups(auth.GraphClient())
 	if err != nil {
 		log.Printf("Failed to get AD groups: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch Azure AD groups"})
@@ -334,7 +345,7 @@ func getOrganizationsWithDetails(sqlDB *sql.DB) ([]models.OrganizationWithDetail
 	query := `
 		SELECT
 			o.id, o.name, o.description, o.is_active, o.created_at, o.updated_at,
-			o.ad_admin_group_id, o.ad_admin_group_name, o.ad_member_group_id, o.ad_member_group_name,
+			o.ad_admin_group_id, o.ad_admin_group_name, o.ad_member_group_id, o.ad_member_group_name, o.parent_id,
 			COALESCE(oq.total_quota, 100000) as total_quota,
 			COALESCE(oq.used_tokens, 0) as used_tokens
 		FROM organizations o
@@ -354,7 +365,7 @@ func getOrganizationsWithDetails(sqlDB *sql.DB) ([]models.OrganizationWithDetail
 
 		err := rows.Scan(
 			&org.ID, &org.Name, &org.Description, &org.IsActive, &org.CreatedAt, &org.UpdatedAt,
-			&org.AdAdminGroupID, &org.AdAdminGroupName, &org.AdMemberGroupID, &org.AdMemberGroupName,
+			&org.AdAdminGroupID, &org.AdAdminGroupName, &org.AdMemberGroupID, &org.AdMemberGroupName, &org.ParentID,
 			&quota.TotalQuota, &quota.UsedTokens,
 		)
 		if err != nil {
@@ -374,7 +385,9 @@ func getOrganizationsWithDetails(sqlDB *sql.DB) ([]models.OrganizationWithDetail
 	return organizations, nil
 }
 
-func createOrganizationWithADGroups(sqlDB *sql.DB, name, description string, isActive bool, quota int,
+// createOrganizationWithADGroups creates the organization. A top-level organization gets a quota
+// of its own; a sub-team draws on its parent's until one is allocated to it.
+func createOrganizationWithADGroups(sqlDB *sql.DB, name, description string, isActive bool, quota int, parentID string,
 	adAdminGroupID, adAdminGroupName, adMemberGroupID, adMemberGroupName string) (string, error) {
 	tx, err := sqlDB.Begin()
 	if err != nil {
@@ -385,23 +398,25 @@ func createOrganizationWithADGroups(sqlDB *sql.DB, name, description string, isA
 	// Create organization with AD group fields
 	var orgID string
 	err = tx.QueryRow(`
-		INSERT INTO organizations (name, description, is_active, ad_admin_group_id, ad_admin_group_name, ad_member_group_id, ad_member_group_name)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO organizations (name, description, is_active, ad_admin_group_id, ad_admin_group_name, ad_member_group_id, ad_member_group_name, parent_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
 	`, name, nullIfEmpty(description), isActive,
 		nullIfEmpty(adAdminGroupID), nullIfEmpty(adAdminGroupName),
-		nullIfEmpty(adMemberGroupID), nullIfEmpty(adMemberGroupName)).Scan(&orgID)
+		nullIfEmpty(adMemberGroupID), nullIfEmpty(adMemberGroupName), nullIfEmpty(parentID)).Scan(&orgID)
 	if err != nil {
 		return "", err
 	}
 
 	// Create quota for organization
-	_, err = tx.Exec(`
-		INSERT INTO organization_quotas (organization_id, total_quota, used_tokens)
-		VALUES ($1, $2, 0)
-	`, orgID, quota)
-	if err != nil {
-		return "", err
+	if parentID == "" {
+		_, err = tx.Exec(`
+			INSERT INTO organization_quotas (organization_id, total_quota, used_tokens)
+			VALUES ($1, $2, 0)
+		`, orgID, quota)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	// Create AD group mappings if provided
@@ -430,6 +445,15 @@ func updateOrganizationWithADGroups(sqlDB *sql.DB, id, name, description string,
 	}
 	defer tx.Rollback()
 
+	// The form owns one admin and one member mapping; mappings added through the AD group
+	// API are left alone
+	var oldAdminGroupID, oldMemberGroupID sql.NullString
+	err = tx.QueryRow(`SELECT ad_admin_group_id, ad_member_group_id FROM organizations WHERE id = $1`, id).
+		Scan(&oldAdminGroupID, &oldMemberGroupID)
+	if err != nil {
+		return err
+	}
+
 	// Update organization with AD group fields
 	_, err = tx.Exec(`
 		UPDATE organizations 
@@ -445,12 +469,13 @@ func updateOrganizationWithADGroups(sqlDB *sql.DB, id, name, description string,
 	}
 
 	// Update AD group mappings
-	// First, deactivate existing mappings
+	// First, deactivate the form's previous mappings
 	_, err = tx.Exec(`
 		UPDATE organization_ad_groups 
 		SET is_active = false 
 		WHERE organization_id = $1
-	`, id)
+		  AND ((ad_group_id = $2 AND role_type = 'admin') OR (ad_group_id = $3 AND role_type = 'member'))
+	`, id, oldAdminGroupID, oldMemberGroupID)
 	if err != nil {
 		return err
 	}
@@ -500,53 +525,31 @@ func createOrgADGroupMapping(tx *sql.Tx, orgID, adGroupID, adGroupName, roleType
 	return err
 }
 
-// ADGroup represents an Azure AD group
-type ADGroup struct {
-	ID          string `json:"id"`
-	DisplayName string `json:"displayName"`
-	Description string `json:"description,omitempty"`
-}
-
-// getAllADGroups fetches all Azure AD groups
-func getAllADGroups(accessToken string) ([]ADGroup, error) {
-	var groups []ADGroup
-
-	url := "https://graph.microsoft.com/v1.0/groups"
-
-	for url != "" {
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			return groups, err
-		}
-		req.Header.Add("Authorization", "Bearer "+accessToken)
+// getAllADGroups fetches all Azure AD groups. Large tenants should use ADGroupSearchHandler.
+func getAllADGroups(client *graph.Client) ([]graph.Group, error) {
+	groups := []graph.Group{}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
+	path := "/groups"
+	for path != "" {
+		page, err := client.Groups(path, "")
 		if err != nil {
 			return groups, err
 		}
-		defer resp.Body.Close()
-
-		body, _ := io.ReadAll(resp.Body)
-		if resp.StatusCode != 200 {
-			return groups, fmt.Errorf("graph request failed: %s", string(body))
-		}
-
-		var result struct {
-			Value    []ADGroup `json:"value"`
-			NextLink string    `json:"@odata.nextLink,omitempty"`
-		}
+		groups = append(groups, page.Groups...)
+		path = page.NextLink // Handle pagination
+	}
 
-		err = json.Unmarshal(body, &result)
-		if err != nil {
-			return groups, err
-		}
+	return groups, nil
+}
 
-		groups = append(groups, result.Value...)
-		url = result.NextLink // Handle pagination
+// GraphStatsHandler reports Microsoft Graph traffic, retries and throttling since startup;
+// requires System Admin
+func GraphStatsHandler(c *gin.Context) {
+	if _, _, ok := requireSystemAdmin(c); !ok {
+		return
 	}
 
-	return groups, nil
+	c.JSON(http.StatusOK, auth.GraphClient().Stats())
 }
 
 // Email-related handlers
@@ -590,6 +593,11 @@ func EmailConfigHandler(c *gin.Context) {
 
 		log.Printf("Received email settings update request: %+v", req)
 
+		if req.SMTPTLSMode != nil && !models.IsValidSMTPTLSMode(*req.SMTPTLSMode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SMTP TLS mode"})
+			return
+		}
+
 		err := emailService.UpdateEmailSettings(req)
 		if err != nil {
 			log.Printf("Failed to update email settings: %v", err)
@@ -638,7 +646,8 @@ func EmailTemplatesHandler(c *gin.Context) {
 			return
 		}
 
-		template, err := emailService.CreateEmailTemplate(req)
+		userID, _ := auth.GetUserContext(c)["id"].(string)
+		template, err := emailService.CreateEmailTemplate(req, userID)
 		if err != nil {
 			log.Printf("Failed to create email template: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
@@ -687,7 +696,12 @@ func EmailTemplateHandler(c *gin.Context) {
 			return
 		}
 
-		template, err := emailService.UpdateEmailTemplate(templateID, req)
+		userID, _ := auth.GetUserContext(c)["id"].(string)
+		template, err := emailService.UpdateEmailTemplate(templateID, req, userID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
 		if err != nil {
 			log.Printf("Failed to update email template: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
@@ -748,17 +762,21 @@ func EmailTestHandler(c *gin.Context) {
 
 	emailService := email.NewService(sqlDB)
 
-	err := emailService.SendTestEmail(req)
+	messageID, err := emailService.SendTestEmail(req)
 	if err != nil {
-		log.Printf("Failed to send test email: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send test email: " + err.Error()})
+		log.Printf("Failed to queue test email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue test email: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Test email sent successfully"})
+	message := "Test email queued for delivery"
+	if req.SendAt != nil {
+		message = "Test email scheduled for " + req.SendAt.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "message": message, "outbox_id": messageID})
 }
 
-// EmailConnectionTestHandler tests the SMTP connection
+// EmailConnectionTestHandler tests the SMTP connection, to the secondary server with server=secondary
 func EmailConnectionTestHandler(c *gin.Context) {
 	database, exists := c.Get("db")
 	if !exists {
@@ -791,16 +809,19 @@ func EmailConnectionTestHandler(c *gin.Context) {
 		return
 	}
 
-	// Test SMTP connection
+	// Test the primary SMTP server, or the secondary with server=secondary
+	config := email.NewSMTPConfig(settings)
+	if c.Query("server") == models.SMTPServerSecondary {
+		secondary := email.NewSecondarySMTPConfig(settings, config)
+		if secondary == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No secondary SMTP server configured"})
+			return
+		}
+		config = *secondary
+	}
+
 	smtpClient := email.NewSMTPClient()
-	err = smtpClient.TestConnection(email.SMTPConfig{
-		Host:      settings.SMTPHost,
-		Port:      settings.SMTPPort,
-		Username:  settings.SMTPUsername.String,
-		Password:  settings.SMTPPassword.String,
-		FromName:  settings.SMTPFromName.String,
-		FromEmail: settings.SMTPFromEmail.String,
-	})
+	err = smtpClient.TestConnection(config)
 
 	if err != nil {
 		log.Printf("SMTP connection test failed: %v", err)
@@ -811,97 +832,35 @@ func EmailConnectionTestHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Connection test successful"})
 }
 
-// EmailLogsHandler handles email logs requests
-func EmailLogsHandler(c *gin.Context) {
+// EmailOutboxHandler lists queued, retrying and failed outbox messages with their failure reasons
+func EmailOutboxHandler(c *gin.Context) {
 	database, exists := c.Get("db")
 	if !exists {
-		c.HTML(http.StatusInternalServerError, "email-logs-table.html", gin.H{"error": "Database connection error"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
 		return
 	}
 
 	sqlDB, ok := database.(*sql.DB)
 	if !ok {
-		c.HTML(http.StatusInternalServerError, "email-logs-table.html", gin.H{"error": "Database connection error"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
 		return
 	}
 
-	// Get recent email logs
-	query := `
-		SELECT id, recipient_email, subject, status, error_message, sent_at, created_at
-		FROM email_logs 
-		ORDER BY created_at DESC 
-		LIMIT 50`
+	limit := 100
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
 
-	rows, err := sqlDB.Query(query)
+	messages, err := email.NewService(sqlDB).GetOutboxMessages(c.Query("status"), limit)
 	if err != nil {
-		log.Printf("Failed to get email logs: %v", err)
-		c.HTML(http.StatusInternalServerError, "email-logs-table.html", gin.H{"error": "Failed to load email logs"})
+		log.Printf("Failed to get email outbox: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load email outbox"})
 		return
 	}
-	defer rows.Close()
-
-	var logs []EmailLogDisplay
-	for rows.Next() {
-		var logEntry models.EmailLog
-		err := rows.Scan(
-			&logEntry.ID, &logEntry.RecipientEmail, &logEntry.Subject,
-			&logEntry.Status, &logEntry.ErrorMessage, &logEntry.SentAt, &logEntry.CreatedAt,
-		)
-		if err != nil {
-			log.Printf("Failed to scan email log: %v", err)
-			continue
-		}
-
-		// Convert to display format
-		display := EmailLogDisplay{
-			RecipientEmail: logEntry.RecipientEmail,
-			Subject:        getStringValue(logEntry.Subject),
-			Status:         logEntry.Status,
-			SentAt:         logEntry.SentAt,
-			CreatedAt:      logEntry.CreatedAt,
-		}
-		logs = append(logs, display)
-	}
-
-	// Return simplified JSON structure
-	if len(logs) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"logs":    []map[string]interface{}{},
-			"message": "No emails sent yet",
-		})
-	} else {
-		// Convert to simpler format
-		var simplifiedLogs []map[string]interface{}
-		for _, log := range logs {
-			simplifiedLogs = append(simplifiedLogs, map[string]interface{}{
-				"recipient": log.RecipientEmail,
-				"subject":   log.Subject,
-				"status":    log.Status,
-				"sent_at":   log.SentAt,
-			})
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"logs":  simplifiedLogs,
-			"count": len(logs),
-		})
-	}
-}
 
-// EmailLogDisplay represents a simplified email log for display
-type EmailLogDisplay struct {
-	RecipientEmail string     `json:"recipient_email"`
-	Subject        string     `json:"subject"`
-	Status         string     `json:"status"`
-	SentAt         *time.Time `json:"sent_at"`
-	CreatedAt      time.Time  `json:"created_at"`
-}
-
-// Helper function to safely get string value from pointer
-func getStringValue(ptr *string) string {
-	if ptr != nil {
-		return *ptr
-	}
-	return ""
+	c.JSON(http.StatusOK, gin.H{
+		"messages": messages,
+	})
 }
 
 // Page handlers for individual admin sections
@@ -0,0 +1,120 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// SetupPageHandler serves the first-run setup page, or sends the browser to sign in once setup
+// is done
+func SetupPageHandler(c *gin.Context) {
+	sqlDB, ok := setupDB(c)
+	if !ok {
+		return
+	}
+
+	required, err := db.IsSetupRequired(sqlDB)
+	if err != nil {
+		log.Printf("Failed to check setup state: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to check setup state")
+		return
+	}
+	if !required {
+		c.Redirect(http.StatusFound, "/login")
+		return
+	}
+
+	c.HTML(http.StatusOK, "setup.html", gin.H{
+		"tokenRequired": os.Getenv("SETUP_TOKEN") != "",
+	})
+}
+
+// SetupStatusHandler reports whether first-run setup is still open
+func SetupStatusHandler(c *gin.Context) {
+	sqlDB, ok := setupDB(c)
+	if !ok {
+		return
+	}
+
+	required, err := db.IsSetupRequired(sqlDB)
+	if err != nil {
+		log.Printf("Failed to check setup state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check setup state"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"setup_required": required,
+		"token_required": os.Getenv("SETUP_TOKEN") != "",
+	})
+}
+
+// SetupHandler runs first-run setup: it creates the initial organization and quota and makes the
+// given user System Admin. It is public but only works on a fresh install, before any user,
+// membership or organization of its own exists; when SETUP_TOKEN is set the request must carry it.
+func SetupHandler(c *gin.Context) {
+	var req models.SetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if token := os.Getenv("SETUP_TOKEN"); token != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(req.SetupToken)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid setup token"})
+		return
+	}
+
+	sqlDB, ok := setupDB(c)
+	if !ok {
+		return
+	}
+
+	orgID, userID, err := db.RunInitialSetup(sqlDB, req)
+	if err == db.ErrSetupComplete {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		log.Printf("Failed to run initial setup: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run initial setup"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, userID, models.AuditActionSetupComplete, "organization", orgID, c.ClientIP(),
+		map[string]interface{}{"admin_email": req.AdminEmail, "total_quota": req.TotalQuota}); err != nil {
+		log.Printf("Failed to write audit log for initial setup: %v", err)
+	}
+
+	log.Printf("Initial setup completed: organization %s, System Admin %s", orgID, req.AdminEmail)
+	c.JSON(http.StatusCreated, gin.H{
+		"organization_id": orgID,
+		"user_id":         userID,
+		"message":         "Setup complete. Sign in as " + req.AdminEmail + " to continue.",
+	})
+}
+
+func setupDB(c *gin.Context) (*sql.DB, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, false
+	}
+	return sqlDB, true
+}
@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/like-mike/relai-gateway/shared/db"
+)
+
+// UserSignInsHandler returns a user's sign-in attempts, newest first, with the anomalies flagged
+// on them; System Admins can see anyone's, other users only their own
+func UserSignInsHandler(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	sqlDB, currentUserID, isAdmin, ok := notificationUser(c)
+	if !ok {
+		return
+	}
+	if !isAdmin && userID != currentUserID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "System Admin role required"})
+		return
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	user, err := db.GetUserByID(sqlDB, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	events, err := db.GetUserLoginEvents(sqlDB, user.ID, user.Email, limit)
+	if err != nil {
+		log.Printf("Failed to get sign-ins of user %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sign-in history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sign_ins": events})
+}
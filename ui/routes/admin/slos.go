@@ -0,0 +1,186 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/slo"
+)
+
+// SLOsHandler lists every SLO with its attainment, error budget and burn rates; requires System
+// Admin
+func SLOsHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	slos, err := db.ListSLOs(sqlDB, false)
+	if err != nil {
+		log.Printf("Failed to list SLOs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load SLOs"})
+		return
+	}
+
+	now := time.Now()
+	statuses := make([]models.SLOStatus, 0, len(slos))
+	for _, s := range slos {
+		status, err := slo.Status(sqlDB, s, now)
+		if err != nil {
+			log.Printf("Failed to evaluate SLO %s: %v", s.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate SLOs"})
+			return
+		}
+		statuses = append(statuses, *status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slos": statuses})
+}
+
+// GetSLOHandler reports an SLO's status with its attainment for each day of its window, for its
+// dashboard; requires System Admin
+func GetSLOHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	s, err := db.GetSLO(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLO not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get SLO: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load SLO"})
+		return
+	}
+
+	now := time.Now()
+	status, err := slo.Status(sqlDB, *s, now)
+	if err != nil {
+		log.Printf("Failed to evaluate SLO %s: %v", s.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate SLO"})
+		return
+	}
+	days, err := db.GetSLODays(sqlDB, s, now)
+	if err != nil {
+		log.Printf("Failed to get SLO %s daily attainment: %v", s.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate SLO"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": status, "days": days})
+}
+
+// CreateSLOHandler adds an availability or latency objective for a model, an endpoint or both;
+// requires System Admin and is audited
+func CreateSLOHandler(c *gin.Context) {
+	var req models.CreateSLORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	s := req.SLO()
+	if err := s.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sqlDB, actorID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	created, err := db.CreateSLO(sqlDB, s, actorID)
+	if err != nil {
+		log.Printf("Failed to create SLO: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create SLO (do the model and organization exist?)"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionSLOCreate, "slo", created.ID, c.ClientIP(),
+		map[string]interface{}{"name": created.Name, "objective": created.Objective, "target": created.Target,
+			"model_id": created.ModelID, "endpoint": created.Endpoint, "organization_id": created.OrganizationID}); err != nil {
+		log.Printf("Failed to write audit log for SLO create: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"slo": created})
+}
+
+// UpdateSLOHandler changes an SLO's name, target or window, or enables or disables it; requires
+// System Admin and is audited
+func UpdateSLOHandler(c *gin.Context) {
+	var req models.UpdateSLORequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+
+	sqlDB, actorID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	s, err := db.GetSLO(sqlDB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLO not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get SLO: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load SLO"})
+		return
+	}
+	req.Apply(s)
+	if err := s.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := db.UpdateSLO(sqlDB, *s)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLO not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to update SLO: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update SLO"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionSLOUpdate, "slo", updated.ID, c.ClientIP(),
+		map[string]interface{}{"name": updated.Name, "target": updated.Target, "latency_threshold_ms": updated.LatencyThresholdMs,
+			"window_days": updated.WindowDays, "is_active": updated.IsActive}); err != nil {
+		log.Printf("Failed to write audit log for SLO update: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"slo": updated})
+}
+
+// DeleteSLOHandler removes an SLO; requires System Admin and is audited
+func DeleteSLOHandler(c *gin.Context) {
+	sqlDB, actorID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	sloID := c.Param("id")
+	err := db.DeleteSLO(sqlDB, sloID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SLO not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete SLO: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SLO"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionSLODelete, "slo", sloID, c.ClientIP(), nil); err != nil {
+		log.Printf("Failed to write audit log for SLO delete: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+)
+
+// GetStreamPacingHandler lists the organization's stream pacing rules; requires admin of the
+// organization or System Admin
+func GetStreamPacingHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	rules, err := db.GetStreamPacing(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get stream pacing: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load stream pacing"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organization_id": orgID, "stream_pacing": rules})
+}
+
+// SaveStreamPacingHandler sets the organization's pacing for an endpoint. Requires admin of the
+// organization or System Admin and is audited.
+func SaveStreamPacingHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	var req models.SaveStreamPacingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An endpoint and tokens_per_second are required"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pacing, err := db.SaveStreamPacing(sqlDB, orgID, actorID, req)
+	if err != nil {
+		log.Printf("Failed to save stream pacing: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save stream pacing"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionStreamPacingUpdate, "organization", orgID, c.ClientIP(),
+		map[string]interface{}{
+			"endpoint":          pacing.Endpoint,
+			"tokens_per_second": pacing.TokensPerSecond,
+			"burst_tokens":      pacing.BurstTokens,
+			"is_active":         pacing.IsActive,
+		}); err != nil {
+		log.Printf("Failed to write audit log for stream pacing change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stream_pacing": pacing,
+		"message":       "Stream pacing saved successfully",
+	})
+}
+
+// DeleteStreamPacingHandler removes one of the organization's pacing rules, so its endpoint
+// streams unpaced. Requires admin of the organization or System Admin and is audited.
+func DeleteStreamPacingHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	pacingID := c.Param("pacing_id")
+	if _, err := uuid.Parse(pacingID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stream pacing not found"})
+		return
+	}
+
+	if err := db.DeleteStreamPacing(sqlDB, orgID, pacingID); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stream pacing not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete stream pacing: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove stream pacing"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionStreamPacingDelete, "organization", orgID, c.ClientIP(),
+		map[string]interface{}{"stream_pacing_id": pacingID}); err != nil {
+		log.Printf("Failed to write audit log for stream pacing removal: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stream pacing removed successfully"})
+}
@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/telemetry"
+)
+
+// TelemetryHandler shows whether telemetry is on and the exact report it would send now, so
+// operators can review it before opting in; requires System Admin
+func TelemetryHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	report, err := telemetry.Collect(sqlDB)
+	if err != nil {
+		log.Printf("Failed to collect telemetry: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to collect telemetry"})
+		return
+	}
+
+	cfg := telemetry.ConfigFromEnv()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":  cfg.Enabled && cfg.Endpoint != "",
+		"endpoint": cfg.Endpoint,
+		"interval": cfg.Interval.String(),
+		"report":   report,
+	})
+}
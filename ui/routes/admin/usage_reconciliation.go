@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/reconcile"
+)
+
+// maxReconciliationDays bounds the date range of a single reconciliation run
+const maxReconciliationDays = 92
+
+// UsageDiscrepanciesHandler lists the day/model discrepancies recorded by reconciliation runs;
+// requires System Admin
+func UsageDiscrepanciesHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	limit := 200
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	discrepancies, err := db.GetUsageDiscrepancies(sqlDB, limit)
+	if err != nil {
+		log.Printf("Failed to get usage discrepancies: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage discrepancies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"discrepancies": discrepancies, "threshold_pct": reconcile.ThresholdFromEnv()})
+}
+
+// ImportUsageReconciliationHandler reconciles an uploaded provider usage export (CSV, form field
+// "file") against the gateway's usage of that provider's models (form field "provider", default
+// openai) over the days the export covers; requires System Admin
+func ImportUsageReconciliationHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	provider := c.DefaultPostForm("provider", "openai")
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A CSV file is required"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	usage, start, end, err := reconcile.ParseCSV(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validReconciliationRange(c, start, end) {
+		return
+	}
+
+	result, err := reconcile.Reconcile(sqlDB, reconcile.SourceCSV, provider, start, end, usage, reconcile.ThresholdFromEnv())
+	if err != nil {
+		log.Printf("Failed to reconcile usage import: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RunUsageReconciliationHandler pulls OpenAI usage for start_date to end_date (YYYY-MM-DD,
+// default the last seven days) from the usage API and reconciles it. Needs OPENAI_ADMIN_KEY;
+// requires System Admin.
+func RunUsageReconciliationHandler(c *gin.Context) {
+	sqlDB, _, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	client := reconcile.NewOpenAIUsageClientFromEnv()
+	if client == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OPENAI_ADMIN_KEY is not configured; import a CSV export instead"})
+		return
+	}
+
+	today := time.Now().UTC()
+	start := c.DefaultQuery("start_date", today.AddDate(0, 0, -7).Format("2006-01-02"))
+	end := c.DefaultQuery("end_date", today.AddDate(0, 0, -1).Format("2006-01-02"))
+	if !validReconciliationRange(c, start, end) {
+		return
+	}
+
+	usage, err := client.DailyUsage(c.Request.Context(), start, end)
+	if err != nil {
+		log.Printf("Failed to fetch OpenAI usage: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch usage from OpenAI"})
+		return
+	}
+
+	result, err := reconcile.Reconcile(sqlDB, reconcile.SourceOpenAIAPI, "openai", start, end, usage, reconcile.ThresholdFromEnv())
+	if err != nil {
+		log.Printf("Failed to reconcile OpenAI usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// validReconciliationRange checks start and end are dates in order and not too far apart,
+// writing the error response itself
+func validReconciliationRange(c *gin.Context, start, end string) bool {
+	from, err1 := time.Parse("2006-01-02", start)
+	to, err2 := time.Parse("2006-01-02", end)
+	if err1 != nil || err2 != nil || to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date must be YYYY-MM-DD dates, in order"})
+		return false
+	}
+	if to.Sub(from) > maxReconciliationDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reconcile at most 92 days at a time"})
+		return false
+	}
+	return true
+}
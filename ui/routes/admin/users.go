@@ -0,0 +1,339 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/ui/auth"
+)
+
+// requireOrgAdmin resolves the database and current user, writing an error response unless the
+// user administers orgID or is a System Admin
+func requireOrgAdmin(c *gin.Context, orgID string) (*sql.DB, string, bool) {
+	database, exists := c.Get("db")
+	if !exists {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", false
+	}
+
+	sqlDB, ok := database.(*sql.DB)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection error"})
+		return nil, "", false
+	}
+
+	userID, _ := auth.GetUserContext(c)["id"].(string)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, "", false
+	}
+
+	isAdmin, err := db.IsSystemAdmin(sqlDB, userID)
+	if err != nil {
+		log.Printf("Failed to check system admin role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, "", false
+	}
+	if isAdmin {
+		return sqlDB, userID, true
+	}
+
+	role, err := db.GetUserOrganizationRole(sqlDB, userID, orgID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Failed to get organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return nil, "", false
+	}
+	if role != models.OrgRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization admin role required"})
+		return nil, "", false
+	}
+
+	return sqlDB, userID, true
+}
+
+// DeactivateUserHandler blocks a user from signing in; requires System Admin and is audited
+func DeactivateUserHandler(c *gin.Context) {
+	setUserActive(c, false)
+}
+
+// ReactivateUserHandler restores a deactivated user; requires System Admin and is audited
+func ReactivateUserHandler(c *gin.Context) {
+	setUserActive(c, true)
+}
+
+func setUserActive(c *gin.Context, active bool) {
+	sqlDB, actorID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	if userID == actorID && !active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot deactivate your own account"})
+		return
+	}
+
+	user, err := db.GetUserByID(sqlDB, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	if err := db.SetUserActive(sqlDB, userID, active); err != nil {
+		log.Printf("Failed to update user status: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	action := models.AuditActionUserDeactivate
+	if active {
+		action = models.AuditActionUserReactivate
+	}
+	if err := db.CreateAuditLog(sqlDB, actorID, action, "user", userID, c.ClientIP(),
+		map[string]interface{}{"email": user.Email}); err != nil {
+		log.Printf("Failed to write audit log for %s: %v", action, err)
+	}
+
+	user.IsActive = active
+	c.JSON(http.StatusOK, gin.H{"success": true, "user": user})
+}
+
+// UpdateUserOrganizationRoleHandler changes a member's role in an organization; requires admin
+// of that organization or System Admin and is audited
+func UpdateUserOrganizationRoleHandler(c *gin.Context) {
+	var req models.UpdateUserOrganizationRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, orgID := c.Param("id"), c.Param("org_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+	if !ensureRoleExists(c, sqlDB, orgID, req.RoleName) {
+		return
+	}
+
+	previousRole, ok := membershipRole(c, sqlDB, userID, orgID)
+	if !ok {
+		return
+	}
+	if previousRole == req.RoleName {
+		c.JSON(http.StatusOK, gin.H{"success": true, "role_name": req.RoleName})
+		return
+	}
+	if previousRole == models.OrgRoleAdmin && !ensureAnotherAdmin(c, sqlDB, orgID, userID) {
+		return
+	}
+
+	if err := db.UpdateUserOrganizationRole(sqlDB, userID, orgID, req.RoleName); err != nil {
+		log.Printf("Failed to update organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionUserRoleChange, "user", userID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "from": previousRole, "to": req.RoleName}); err != nil {
+		log.Printf("Failed to write audit log for role change: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "role_name": req.RoleName})
+}
+
+// RemoveUserFromOrganizationHandler removes a member from an organization; requires admin of
+// that organization or System Admin and is audited
+func RemoveUserFromOrganizationHandler(c *gin.Context) {
+	userID, orgID := c.Param("id"), c.Param("org_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	role, ok := membershipRole(c, sqlDB, userID, orgID)
+	if !ok {
+		return
+	}
+	if role == models.OrgRoleAdmin && !ensureAnotherAdmin(c, sqlDB, orgID, userID) {
+		return
+	}
+
+	if err := db.RemoveUserFromOrganization(sqlDB, userID, orgID); err != nil {
+		log.Printf("Failed to remove user from organization: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove user"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionUserOrgRemove, "user", userID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "role_name": role}); err != nil {
+		log.Printf("Failed to write audit log for organization removal: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// DeleteUserHandler permanently deletes a user; requires System Admin and is audited
+func DeleteUserHandler(c *gin.Context) {
+	sqlDB, actorID, ok := requireSystemAdmin(c)
+	if !ok {
+		return
+	}
+
+	userID := c.Param("id")
+	if userID == actorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot delete your own account"})
+		return
+	}
+
+	user, err := db.GetUserByID(sqlDB, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	// Written first: afterwards there is nothing left to show who the user was
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionUserDelete, "user", userID, c.ClientIP(),
+		map[string]interface{}{"email": user.Email, "name": user.Name}); err != nil {
+		log.Printf("Failed to write audit log for user deletion: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := db.DeleteUser(sqlDB, userID); err != nil {
+		log.Printf("Failed to delete user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// membershipRole returns the user's role in orgID, writing a 404 if they aren't a member
+func membershipRole(c *gin.Context, sqlDB *sql.DB, userID, orgID string) (string, bool) {
+	role, err := db.GetUserOrganizationRole(sqlDB, userID, orgID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this organization"})
+		return "", false
+	} else if err != nil {
+		log.Printf("Failed to get organization role: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load membership"})
+		return "", false
+	}
+	return role, true
+}
+
+// ensureAnotherAdmin refuses to demote or remove an organization's last active admin
+func ensureAnotherAdmin(c *gin.Context, sqlDB *sql.DB, orgID, userID string) bool {
+	others, err := db.CountOrganizationAdmins(sqlDB, orgID, userID)
+	if err != nil {
+		log.Printf("Failed to count organization admins: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check organization admins"})
+		return false
+	}
+	if others == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An organization must keep at least one admin"})
+		return false
+	}
+	return true
+}
+
+// AddOrganizationMemberHandler grants an existing user a manual membership that AD group sync
+// won't remove; requires admin of the organization or System Admin and is audited
+func AddOrganizationMemberHandler(c *gin.Context) {
+	var req models.AddOrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+	if !ensureRoleExists(c, sqlDB, orgID, req.RoleName) {
+		return
+	}
+
+	user, err := db.GetUserByEmail(sqlDB, req.Email)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No active user with that email; they must sign in once before they can be added"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to get user by email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return
+	}
+
+	if err := db.AssignUserToOrganization(sqlDB, user.ID, orgID, req.RoleName, &actorID); err != nil {
+		log.Printf("Failed to add organization member: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionUserOrgAdd, "user", user.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "role_name": req.RoleName, "source": models.MembershipSourceManual}); err != nil {
+		log.Printf("Failed to write audit log for organization member: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "user": user, "role_name": req.RoleName, "source": models.MembershipSourceManual})
+}
+
+// UpdateMembershipSourceHandler pins a membership or hands it back to AD group sync; requires
+// admin of the organization or System Admin and is audited
+func UpdateMembershipSourceHandler(c *gin.Context) {
+	var req models.UpdateMembershipSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, orgID := c.Param("id"), c.Param("org_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	err := db.SetMembershipSource(sqlDB, userID, orgID, req.Source)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this organization"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to update membership source: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update membership"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionUserOrgSource, "user", userID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "source": req.Source}); err != nil {
+		log.Printf("Failed to write audit log for membership source: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "source": req.Source})
+}
@@ -0,0 +1,23 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/version"
+)
+
+// VersionHandler reports the running build, the schema version it expects and, when update
+// checks are on, the latest release
+func VersionHandler(c *gin.Context) {
+	info := version.Get()
+	c.JSON(http.StatusOK, gin.H{
+		"version":        info.Version,
+		"commit":         info.Commit,
+		"build_date":     info.BuildDate,
+		"go_version":     info.GoVersion,
+		"schema_version": db.SchemaVersion(),
+		"update":         version.Status(),
+	})
+}
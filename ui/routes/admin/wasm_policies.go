@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/shared/db"
+	"github.com/like-mike/relai-gateway/shared/models"
+	"github.com/like-mike/relai-gateway/shared/wasm"
+	"github.com/like-mike/relai-gateway/shared/wasmpolicy"
+)
+
+// checkWasmPolicyModule compiles an uploaded module against the policy ABI, answering with the
+// reason it was refused. The gateway picks up accepted modules at its next refresh.
+func checkWasmPolicyModule(c *gin.Context, name string, module []byte) bool {
+	if _, err := wasmpolicy.Compile(name, module, wasm.Limits{}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy module: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// GetWasmPoliciesHandler lists an organization's WASM policies in the order they run; requires
+// admin of the organization or System Admin
+func GetWasmPoliciesHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	sqlDB, _, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	policies, err := db.GetWasmPolicies(sqlDB, orgID)
+	if err != nil {
+		log.Printf("Failed to get WASM policies: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load WASM policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// CreateWasmPolicyHandler uploads a policy module for the organization; requires admin of the
+// organization or System Admin and is audited
+func CreateWasmPolicyHandler(c *gin.Context) {
+	var req models.CreateWasmPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID := c.Param("id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+	if !checkWasmPolicyModule(c, req.Name, req.Module) {
+		return
+	}
+
+	policy, err := db.CreateWasmPolicy(sqlDB, orgID, actorID, req)
+	if err != nil {
+		log.Printf("Failed to create WASM policy: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create WASM policy"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionWasmPolicyCreate, "wasm_policy", policy.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "name": policy.Name, "sha256": policy.SHA256, "is_active": policy.IsActive}); err != nil {
+		log.Printf("Failed to write audit log for WASM policy create: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"policy": policy})
+}
+
+// UpdateWasmPolicyHandler renames, replaces, re-limits, enables or disables a policy; requires
+// admin of the organization or System Admin and is audited
+func UpdateWasmPolicyHandler(c *gin.Context) {
+	var req models.UpdateWasmPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orgID, policyID := c.Param("id"), c.Param("policy_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+	if req.Module != nil && !checkWasmPolicyModule(c, policyID, req.Module) {
+		return
+	}
+
+	policy, err := db.UpdateWasmPolicy(sqlDB, orgID, policyID, req)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WASM policy not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to update WASM policy: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update WASM policy"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionWasmPolicyUpdate, "wasm_policy", policy.ID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID, "name": policy.Name, "sha256": policy.SHA256,
+			"module_replaced": req.Module != nil, "is_active": policy.IsActive}); err != nil {
+		log.Printf("Failed to write audit log for WASM policy update: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy})
+}
+
+// DeleteWasmPolicyHandler removes a policy; requires admin of the organization or System Admin
+// and is audited
+func DeleteWasmPolicyHandler(c *gin.Context) {
+	orgID, policyID := c.Param("id"), c.Param("policy_id")
+	sqlDB, actorID, ok := requireOrgAdmin(c, orgID)
+	if !ok {
+		return
+	}
+
+	err := db.DeleteWasmPolicy(sqlDB, orgID, policyID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "WASM policy not found"})
+		return
+	} else if err != nil {
+		log.Printf("Failed to delete WASM policy: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete WASM policy"})
+		return
+	}
+
+	if err := db.CreateAuditLog(sqlDB, actorID, models.AuditActionWasmPolicyDelete, "wasm_policy", policyID, c.ClientIP(),
+		map[string]interface{}{"organization_id": orgID}); err != nil {
+		log.Printf("Failed to write audit log for WASM policy delete: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,416 @@
+// Package server assembles the admin UI: its background jobs and its routes. The UI binary runs
+// it on its own; the gateway can also mount it next to the proxy in combined mode.
+package server
+
+import (
+	"database/sql"
+	"html/template"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/like-mike/relai-gateway/docs"
+	"github.com/like-mike/relai-gateway/shared/declarative"
+	"github.com/like-mike/relai-gateway/shared/email"
+	"github.com/like-mike/relai-gateway/shared/fx"
+	"github.com/like-mike/relai-gateway/shared/i18n"
+	"github.com/like-mike/relai-gateway/shared/middleware"
+	"github.com/like-mike/relai-gateway/shared/notify"
+	"github.com/like-mike/relai-gateway/shared/readonly"
+	"github.com/like-mike/relai-gateway/shared/reconcile"
+	"github.com/like-mike/relai-gateway/shared/secretfile"
+	"github.com/like-mike/relai-gateway/shared/slo"
+	"github.com/like-mike/relai-gateway/shared/telemetry"
+	"github.com/like-mike/relai-gateway/shared/version"
+	"github.com/like-mike/relai-gateway/ui/auth"
+	"github.com/like-mike/relai-gateway/ui/routes/admin"
+	"github.com/like-mike/relai-gateway/ui/routes/health"
+	"github.com/like-mike/relai-gateway/ui/templates"
+)
+
+// Port returns the port the UI listens on, from UI_PORT
+func Port(fallback string) string {
+	if port := os.Getenv("UI_PORT"); port != "" {
+		return port
+	}
+	return fallback
+}
+
+// StartBackground starts the UI's background jobs: secret file reloads, the email sender, the
+// key expiry reminders, the live usage relay, notification and email log retention, SLO burn
+// rate alerts and, when enabled, FX rate refresh, telemetry, update checks, usage
+// reconciliation and declarative config. The returned func stops them.
+func StartBackground(conn *sql.DB) (stop func()) {
+	var stops []func()
+
+	// Pick up secrets rotated in their *_FILE mounts
+	stops = append(stops, secretfile.Start())
+
+	// Start the background email sender
+	emailSender := email.NewSender(conn, nil)
+	emailSender.Start()
+	stops = append(stops, emailSender.Stop)
+
+	// Start the API key expiry reminder scheduler
+	reminderInterval, _ := time.ParseDuration(os.Getenv("EMAIL_REMINDER_INTERVAL"))
+	reminderScheduler := email.NewReminderScheduler(conn, reminderInterval)
+	reminderScheduler.Start()
+	stops = append(stops, reminderScheduler.Stop)
+
+	// Relay the gateways' recorded usage to the dashboards' WebSockets
+	stops = append(stops, admin.StartLiveUsage())
+
+	// Drop notification center alerts past their retention
+	stops = append(stops, notify.StartRetention(conn))
+
+	// Drop email logs and finished outbox messages past their retention
+	stops = append(stops, email.StartLogRetention(conn))
+
+	// Keep exchange rates for reports shown in other currencies, when a rate source is set
+	stops = append(stops, fx.StartRefresh(conn))
+
+	// Send anonymized deployment stats, only when an operator has opted in
+	stops = append(stops, telemetry.StartReporter(conn))
+
+	// Look for newer releases to announce in the console, when enabled
+	stops = append(stops, version.StartUpdateCheck())
+
+	// Apply organizations and models declared in files, e.g. a Kubernetes ConfigMap
+	stops = append(stops, declarative.Start(conn))
+
+	// Alert on SLOs burning their error budgets too fast
+	stops = append(stops, slo.Start(conn))
+
+	// Start the daily usage reconciliation against the OpenAI usage API, when enabled
+	if reconciliationScheduler := reconcile.NewSchedulerFromEnv(conn); reconciliationScheduler != nil {
+		reconciliationScheduler.Start()
+		stops = append(stops, reconciliationScheduler.Stop)
+	}
+
+	return func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+}
+
+// NewRouter builds the UI router with every page and API route registered
+func NewRouter(conn *sql.DB, authConfig auth.Config) *gin.Engine {
+	// Setup Gin router
+	r := gin.New()
+	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.CustomLogger())
+	r.Use(gin.Recovery())
+
+	// Parse the embedded templates; each is named by its file name
+	templateFiles := []string{
+		"pages/auth/login.html",
+		"pages/auth/unsubscribe.html",
+		"pages/auth/setup.html",
+		"pages/admin/api-keys.html",
+		"pages/admin/models.html",
+		"pages/admin/audit-logs.html",
+		"pages/admin/analytics.html",
+		"pages/admin/test-api.html",
+		"pages/admin/settings.html",
+		"pages/admin/organizations.html",
+		"pages/admin/users.html",
+		"pages/admin/system.html",
+		"pages/admin/email.html",
+		"pages/admin/docs.html",
+		"components/ui/banner.html",
+		"components/ui/sidebar.html",
+		"components/ui/user-dropdown.html",
+		"partials/org-selector.html",
+		"partials/quota-cards.html",
+		"partials/api-keys-table.html",
+		"partials/organizations-table.html",
+		"partials/users-table.html",
+		"components/modals/api-keys/new-key-modal.html",
+		"components/modals/api-keys/view-key-modal.html",
+		"components/modals/api-keys/delete-confirmation-modal.html",
+		"components/modals/models/add-model-modal.html",
+		"components/modals/models/edit-model-modal.html",
+		"components/modals/models/delete-model-modal.html",
+		"components/modals/models/manage-access-modal.html",
+		"components/modals/organizations/create-org-modal.html",
+		"components/modals/organizations/edit-org-modal.html",
+		"shared/theme.css",
+	}
+	r.SetHTMLTemplate(template.Must(template.New("").Funcs(i18n.FuncMap()).ParseFS(templates.Files, templateFiles...)))
+
+	// Attach DB to Gin context
+	r.Use(middleware.DBMiddleware(conn))
+
+	// Health check
+	r.GET("/health", health.Handler)
+
+	// Dynamic theme CSS endpoint
+	r.GET("/theme.css", admin.ThemeCSSHandler)
+
+	// Serve docs publicly (for Swagger UI to fetch)
+	r.StaticFS("/docs", http.FS(docs.Files))
+
+	// Unsubscribe links in notification emails, signed so no login is needed
+	r.GET("/unsubscribe", admin.UnsubscribePageHandler)
+	r.POST("/unsubscribe", admin.UnsubscribeHandler)
+
+	// Leaked-key alerts from GitHub secret scanning, authenticated by GitHub's signature
+	r.POST("/api/github/secret-scanning", admin.GitHubSecretScanningHandler)
+
+	// First-run setup; only open on a fresh install, and locks itself once it has run
+	r.GET("/setup", admin.SetupPageHandler)
+	r.GET("/api/setup", admin.SetupStatusHandler)
+	r.POST("/api/setup", admin.SetupHandler)
+
+	// Register public authentication routes
+	auth.RegisterPublicRoutes(r, authConfig)
+
+	// Root route redirect
+	r.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "/admin")
+	})
+
+	// Protected routes
+	authorized := r.Group("/")
+	authorized.Use(auth.Middleware())
+	authorized.Use(auth.DeveloperScope())
+	// Maintenance windows: changes are refused, except on the routes that only read or preview
+	authorized.Use(readonly.RejectWrites(
+		"/admin/refresh-access",
+		"/api/system/read-only",
+		"/api/completions-proxy",
+		"/admin/api/ad-sync/simulate",
+		"/admin/settings/email/templates/preview",
+		"/admin/settings/email/test-connection",
+	))
+	auth.RegisterRoutes(authorized, authConfig)
+
+	// Admin dashboard - API Keys page
+	authorized.GET("/admin", admin.DashboardHandler)
+	authorized.GET("/admin/models", func(c *gin.Context) {
+		userData := auth.GetUserContext(c)
+		userData["activePage"] = "models"
+		userData["title"] = "Models Management"
+		c.HTML(http.StatusOK, "models.html", userData)
+	})
+	authorized.GET("/admin/test-api", func(c *gin.Context) {
+		userData := auth.GetUserContext(c)
+		userData["activePage"] = "test_api"
+		userData["title"] = "Test API"
+		c.HTML(http.StatusOK, "test-api.html", userData)
+	})
+	authorized.GET("/admin/settings", admin.SettingsHandler)
+	authorized.GET("/admin/settings/organizations", admin.OrganizationsPageHandler)
+	authorized.GET("/admin/settings/users", admin.UsersPageHandler)
+	authorized.GET("/admin/settings/system", admin.SystemPageHandler)
+	authorized.GET("/admin/settings/email", admin.EmailPageHandler)
+	authorized.GET("/admin/analytics/usage", func(c *gin.Context) {
+		userData := auth.GetUserContext(c)
+		userData["activePage"] = "usage_analytics"
+		userData["title"] = "Usage Analytics"
+		c.HTML(http.StatusOK, "analytics.html", userData)
+	})
+	authorized.GET("/admin/analytics/audit-logs", admin.AuditLogsPageHandler)
+	authorized.GET("/admin/docs", func(c *gin.Context) {
+		userData := auth.GetUserContext(c)
+		userData["activePage"] = "docs"
+		userData["title"] = "API Documentation"
+		c.HTML(http.StatusOK, "docs.html", userData)
+	})
+
+	// API endpoints with database integration
+	authorized.GET("/quota", admin.GetQuotaHandler)
+	authorized.GET("/api/quota-notifications", admin.GetQuotaNotificationSettingsHandler)
+	authorized.PUT("/api/quota-notifications", admin.UpdateQuotaNotificationSettingsHandler)
+	authorized.GET("/api/organizations/branding", admin.GetOrganizationBrandingHandler)
+	authorized.PUT("/api/organizations/branding", admin.UpdateOrganizationBrandingHandler)
+	authorized.GET("/api/organizations/locale", admin.GetOrganizationLocaleHandler)
+	authorized.PUT("/api/organizations/locale", admin.UpdateOrganizationLocaleHandler)
+	authorized.GET("/api/i18n/catalog", admin.TranslationCatalogHandler)
+	authorized.PUT("/api/me/locale", admin.UpdateUserLocaleHandler)
+	authorized.GET("/api/me/notification-preferences", admin.GetNotificationPreferencesHandler)
+	authorized.GET("/api/notifications", admin.NotificationsHandler)
+	authorized.GET("/api/notifications/unread-count", admin.UnreadNotificationCountHandler)
+	authorized.PUT("/api/notifications/read", admin.MarkAllNotificationsReadHandler)
+	authorized.PUT("/api/notifications/:id/read", admin.MarkNotificationReadHandler)
+	authorized.PUT("/api/me/notification-preferences", admin.UpdateNotificationPreferencesHandler)
+	authorized.GET("/api-keys", admin.APIKeysHandler)
+	authorized.POST("/api/keys", admin.CreateAPIKeyHandler)
+	authorized.POST("/api/keys/:id/regenerate", admin.RegenerateAPIKeyHandler)
+	authorized.DELETE("/api/keys/:id", admin.DeleteAPIKeyHandler)
+	authorized.PUT("/api/keys/:id/owner", admin.TransferAPIKeyOwnerHandler)
+	authorized.GET("/api/keys/:id/usage", admin.APIKeyUsageHandler)
+	authorized.GET("/api/keys/:id/canary-hits", admin.CanaryHitsHandler)
+	authorized.PUT("/api/keys/:id/project", admin.MoveAPIKeyProjectHandler)
+	authorized.GET("/api/projects", admin.ProjectsHandler)
+	authorized.POST("/api/projects", admin.CreateProjectHandler)
+	authorized.PUT("/api/projects/:id", admin.UpdateProjectHandler)
+	authorized.DELETE("/api/projects/:id", admin.DeleteProjectHandler)
+	authorized.GET("/api/organizations", admin.OrganizationsHandler)
+	authorized.PUT("/api/organizations/:id/quota", admin.UpdateOrganizationQuotaHandler)
+	authorized.GET("/api/models", admin.ModelsHandler)
+	authorized.POST("/api/models", admin.CreateModelHandler)
+	authorized.PUT("/api/models/:id", admin.UpdateModelHandler)
+	authorized.DELETE("/api/models/:id", admin.DeleteModelHandler)
+	authorized.POST("/api/models/:id/access", admin.ManageModelAccessHandler)
+	authorized.POST("/api/models/:id/reveal-token", admin.RevealModelTokenHandler)
+	authorized.GET("/api/models/:id/prices", admin.ListModelPricesHandler)
+	authorized.POST("/api/models/:id/prices", admin.CreateModelPriceHandler)
+	authorized.DELETE("/api/models/:id/prices/:price_id", admin.DeleteModelPriceHandler)
+	authorized.GET("/api/analytics/dashboard", admin.AnalyticsDashboardHandler)
+	authorized.GET("/api/analytics/compare", admin.AnalyticsCompareHandler)
+	authorized.GET("/api/analytics/feedback", admin.FeedbackAnalyticsHandler)
+	authorized.GET("/api/analytics/forecast", admin.ForecastAnalyticsHandler)
+	authorized.GET("/api/analytics/endpoints", admin.EndpointAnalyticsHandler)
+	authorized.GET("/api/analytics/rates", admin.LiveRatesHandler)
+	authorized.GET("/ws/usage", admin.LiveUsageSocketHandler)
+	authorized.GET("/api/analytics/schema-validation", admin.SchemaValidationAnalyticsHandler)
+	authorized.GET("/api/analytics/views", admin.AnalyticsViewsHandler)
+	authorized.POST("/api/analytics/views", admin.CreateAnalyticsViewHandler)
+	authorized.GET("/api/analytics/views/:id", admin.AnalyticsViewHandler)
+	authorized.PUT("/api/analytics/views/:id", admin.UpdateAnalyticsViewHandler)
+	authorized.DELETE("/api/analytics/views/:id", admin.DeleteAnalyticsViewHandler)
+	authorized.POST("/api/completions-proxy", admin.CompletionsProxyHandler)
+
+	// TEMP: Test endpoint for debugging streaming without auth (remove in production)
+	r.POST("/api/test-streaming", admin.TestStreamingHandler)
+
+	// Settings API endpoints (for tables and forms)
+	authorized.GET("/admin/settings/organizations/table", admin.OrganizationsTableHandler)
+	authorized.POST("/admin/settings/organizations", admin.CreateOrganizationHandler)
+	authorized.GET("/admin/settings/organizations/:id", admin.GetOrganizationHandler)
+	authorized.PUT("/admin/settings/organizations/:id", admin.UpdateOrganizationHandler)
+	authorized.POST("/admin/settings/organizations/:id", admin.UpdateOrganizationHandler) // HTMX form support
+	authorized.DELETE("/admin/settings/organizations/:id", admin.DeleteOrganizationHandler)
+	authorized.POST("/admin/settings/organizations/:id/members", admin.AddOrganizationMemberHandler)
+	authorized.GET("/admin/settings/organizations/:id/roles", admin.GetOrganizationRolesHandler)
+	authorized.POST("/admin/settings/organizations/:id/roles", admin.CreateOrganizationRoleHandler)
+	authorized.PUT("/admin/settings/organizations/:id/roles/:role_id", admin.UpdateOrganizationRoleHandler)
+	authorized.DELETE("/admin/settings/organizations/:id/roles/:role_id", admin.DeleteOrganizationRoleHandler)
+	authorized.GET("/admin/settings/organizations/:id/cost-visibility", admin.GetOrganizationCostVisibilityHandler)
+	authorized.PUT("/admin/settings/organizations/:id/cost-visibility", admin.UpdateOrganizationCostVisibilityHandler)
+	authorized.GET("/admin/settings/organizations/:id/qos", admin.GetOrganizationQoSHandler)
+	authorized.PUT("/admin/settings/organizations/:id/qos", admin.UpdateOrganizationQoSHandler)
+	authorized.GET("/admin/settings/organizations/:id/currency", admin.GetOrganizationCurrencyHandler)
+	authorized.PUT("/admin/settings/organizations/:id/currency", admin.UpdateOrganizationCurrencyHandler)
+	authorized.GET("/admin/settings/organizations/:id/max-request-cost", admin.GetOrganizationMaxRequestCostHandler)
+	authorized.PUT("/admin/settings/organizations/:id/max-request-cost", admin.UpdateOrganizationMaxRequestCostHandler)
+	authorized.GET("/admin/settings/organizations/:id/stream-pacing", admin.GetStreamPacingHandler)
+	authorized.PUT("/admin/settings/organizations/:id/stream-pacing", admin.SaveStreamPacingHandler)
+	authorized.DELETE("/admin/settings/organizations/:id/stream-pacing/:pacing_id", admin.DeleteStreamPacingHandler)
+	authorized.GET("/admin/settings/organizations/:id/wasm-policies", admin.GetWasmPoliciesHandler)
+	authorized.POST("/admin/settings/organizations/:id/wasm-policies", admin.CreateWasmPolicyHandler)
+	authorized.PUT("/admin/settings/organizations/:id/wasm-policies/:policy_id", admin.UpdateWasmPolicyHandler)
+	authorized.DELETE("/admin/settings/organizations/:id/wasm-policies/:policy_id", admin.DeleteWasmPolicyHandler)
+	authorized.GET("/admin/settings/organizations/:id/ad-groups", admin.GetOrganizationADGroupMappingsHandler)
+	authorized.POST("/admin/settings/organizations/:id/ad-groups", admin.CreateOrganizationADGroupMappingHandler)
+	authorized.PUT("/admin/settings/organizations/:id/ad-groups/:mapping_id", admin.UpdateOrganizationADGroupMappingHandler)
+	authorized.DELETE("/admin/settings/organizations/:id/ad-groups/:mapping_id", admin.DeleteOrganizationADGroupMappingHandler)
+	authorized.POST("/admin/api/ad-sync/simulate", admin.SimulateADSyncHandler)
+	authorized.GET("/admin/settings/users/table", admin.UsersTableHandler)
+	authorized.POST("/admin/settings/users/:id/deactivate", admin.DeactivateUserHandler)
+	authorized.POST("/admin/settings/users/:id/reactivate", admin.ReactivateUserHandler)
+	authorized.DELETE("/admin/settings/users/:id", admin.DeleteUserHandler)
+	authorized.PUT("/admin/settings/users/:id/organizations/:org_id", admin.UpdateUserOrganizationRoleHandler)
+	authorized.DELETE("/admin/settings/users/:id/organizations/:org_id", admin.RemoveUserFromOrganizationHandler)
+	authorized.PUT("/admin/settings/users/:id/organizations/:org_id/source", admin.UpdateMembershipSourceHandler)
+	authorized.GET("/admin/settings/users/:id/sign-ins", admin.UserSignInsHandler)
+	authorized.GET("/admin/settings/ad-groups", admin.GetADGroupsHandler)
+	authorized.GET("/admin/settings/ad-groups/search", admin.ADGroupSearchHandler)
+	authorized.GET("/admin/api/graph/stats", admin.GraphStatsHandler)
+	authorized.GET("/admin/api/version", admin.VersionHandler)
+
+	// Email settings routes
+	authorized.GET("/admin/settings/email/config", admin.EmailConfigHandler)
+	authorized.POST("/admin/settings/email/config", admin.EmailConfigHandler)
+	authorized.POST("/admin/settings/email/config/reveal-password", admin.RevealSMTPPasswordHandler)
+	authorized.GET("/admin/settings/email/dkim", admin.DKIMKeysHandler)
+	authorized.POST("/admin/settings/email/dkim", admin.CreateDKIMKeyHandler)
+	authorized.DELETE("/admin/settings/email/dkim/:id", admin.DeleteDKIMKeyHandler)
+	authorized.GET("/admin/settings/email/templates", admin.EmailTemplatesHandler)
+	authorized.POST("/admin/settings/email/templates", admin.EmailTemplatesHandler)
+	authorized.GET("/admin/settings/email/templates/:id", admin.EmailTemplateHandler)
+	authorized.PUT("/admin/settings/email/templates/:id", admin.EmailTemplateHandler)
+	authorized.POST("/admin/settings/email/templates/preview", admin.EmailTemplatePreviewHandler)
+	authorized.GET("/admin/settings/email/templates/:id/versions", admin.EmailTemplateVersionsHandler)
+	authorized.GET("/admin/settings/email/templates/:id/versions/:version/preview", admin.EmailTemplateVersionPreviewHandler)
+	authorized.GET("/admin/settings/email/templates/:id/diff", admin.EmailTemplateDiffHandler)
+	authorized.POST("/admin/settings/email/templates/:id/publish", admin.EmailTemplatePublishHandler)
+	authorized.POST("/admin/settings/email/templates/:id/rollback", admin.EmailTemplateRollbackHandler)
+	authorized.DELETE("/admin/settings/email/templates/:id/draft", admin.EmailTemplateDiscardDraftHandler)
+	authorized.POST("/admin/settings/email/test", admin.EmailTestHandler)
+	authorized.POST("/admin/settings/email/test-connection", admin.EmailConnectionTestHandler)
+	authorized.GET("/admin/settings/email/outbox", admin.EmailOutboxHandler)
+	authorized.GET("/admin/settings/email/logs", admin.EmailLogsHandler)
+	authorized.DELETE("/admin/settings/email/logs", admin.PurgeEmailLogsHandler)
+	authorized.POST("/admin/settings/email/logs/:id/resend", admin.ResendEmailHandler)
+
+	// Notification channel (Slack / Teams) routes
+	authorized.GET("/api/notification-channels", admin.NotificationChannelsHandler)
+	authorized.POST("/api/notification-channels", admin.CreateNotificationChannelHandler)
+	authorized.PUT("/api/notification-channels/:id", admin.UpdateNotificationChannelHandler)
+	authorized.DELETE("/api/notification-channels/:id", admin.DeleteNotificationChannelHandler)
+	authorized.POST("/api/notification-channels/:id/test", admin.TestNotificationChannelHandler)
+	authorized.GET("/api/organization-contacts", admin.OrganizationContactsHandler)
+	authorized.POST("/api/organization-contacts", admin.CreateOrganizationContactHandler)
+	authorized.DELETE("/api/organization-contacts/:id", admin.DeleteOrganizationContactHandler)
+
+	// Experiments (A/B testing) routes
+	authorized.GET("/api/experiments", admin.ExperimentsHandler)
+	authorized.POST("/api/experiments", admin.CreateExperimentHandler)
+	authorized.GET("/api/experiments/:id", admin.GetExperimentHandler)
+	authorized.PUT("/api/experiments/:id", admin.UpdateExperimentHandler)
+	authorized.DELETE("/api/experiments/:id", admin.DeleteExperimentHandler)
+	authorized.GET("/api/experiments/:id/report", admin.ExperimentReportHandler)
+
+	// Response schema (structured output guardrail) routes
+	authorized.GET("/api/response-schemas", admin.ResponseSchemasHandler)
+	authorized.POST("/api/response-schemas", admin.CreateResponseSchemaHandler)
+	authorized.PUT("/api/response-schemas/:id", admin.UpdateResponseSchemaHandler)
+	authorized.DELETE("/api/response-schemas/:id", admin.DeleteResponseSchemaHandler)
+
+	// Secret scanning routes
+	authorized.GET("/api/secret-scan/policy", admin.GetSecretScanPolicyHandler)
+	authorized.PUT("/api/secret-scan/policy", admin.UpdateSecretScanPolicyHandler)
+	authorized.GET("/api/secret-scan/incidents", admin.SecretScanReportHandler)
+
+	// Request payload log and replay routes
+	authorized.GET("/api/request-logs", admin.RequestLogsHandler)
+	authorized.GET("/api/request-logs/:id", admin.GetRequestLogHandler)
+	authorized.POST("/api/request-logs/:id/replay", admin.ReplayRequestLogHandler)
+
+	// Audit log routes
+	authorized.GET("/api/audit-logs", admin.AuditLogsHandler)
+
+	// Read-only mode for database maintenance windows
+	authorized.GET("/api/system/read-only", admin.ReadOnlyStatusHandler)
+	authorized.PUT("/api/system/read-only", admin.SetReadOnlyHandler)
+	authorized.GET("/api/system/break-glass", admin.BreakGlassStatusHandler)
+	authorized.PUT("/api/system/break-glass", admin.SetBreakGlassHandler)
+	authorized.GET("/api/system/fx-rates", admin.FXRatesHandler)
+	authorized.PUT("/api/system/fx-rates/:currency", admin.SetFXRateHandler)
+	authorized.GET("/api/system/telemetry", admin.TelemetryHandler)
+	authorized.GET("/api/system/authz-decisions", admin.AuthzDecisionsHandler)
+
+	// Availability and latency SLOs with error budgets and burn rates
+	authorized.GET("/api/system/slos", admin.SLOsHandler)
+	authorized.POST("/api/system/slos", admin.CreateSLOHandler)
+	authorized.GET("/api/system/slos/:id", admin.GetSLOHandler)
+	authorized.PUT("/api/system/slos/:id", admin.UpdateSLOHandler)
+	authorized.DELETE("/api/system/slos/:id", admin.DeleteSLOHandler)
+
+	// Schema drift report and explicit repair
+	authorized.GET("/api/system/schema", admin.SchemaDriftHandler)
+	authorized.POST("/api/system/schema/apply", admin.ApplySchemaFixesHandler)
+	authorized.GET("/api/system/blocked-ips", admin.BlockedIPsHandler)
+	authorized.DELETE("/api/system/blocked-ips/:ip", admin.UnblockIPHandler)
+
+	// Usage reconciliation routes
+	authorized.GET("/api/usage-reconciliation", admin.UsageDiscrepanciesHandler)
+	authorized.POST("/api/usage-reconciliation/import", admin.ImportUsageReconciliationHandler)
+	authorized.POST("/api/usage-reconciliation/run", admin.RunUsageReconciliationHandler)
+
+	return r
+}
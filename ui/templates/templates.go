@@ -0,0 +1,10 @@
+// Package templates embeds the admin UI's page templates, partials and theme.css, so the UI runs
+// from any working directory
+package templates
+
+import "embed"
+
+// Files holds the templates, keyed by their path below ui/templates
+//
+//go:embed pages components partials shared
+var Files embed.FS